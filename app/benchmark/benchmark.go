@@ -0,0 +1,235 @@
+// Package benchmark drives a running server with many clients sending pipelined commands,
+// and reports the throughput and latency percentiles of each command, like redis-benchmark,
+// so performance regressions are measured without external tools.
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// Options configures a benchmark run
+type Options struct {
+	Addr     string   // Address of the server, like 127.0.0.1:6379
+	Password string   // Password sent with AUTH when each client connects, empty for none
+	Clients  int      // Number of clients sending commands at once
+	Requests int      // Number of commands sent by all the clients for each test
+	Pipeline int      // Number of commands a client sends before reading their replies
+	DataSize int      // Size in bytes of the values written
+	KeySpace int      // Number of distinct keys picked at random, 1 always uses the same key
+	Tests    []string // Tests run in order, among Tests
+}
+
+// DefaultOptions returns the options of a run when none is changed
+func DefaultOptions() Options {
+	return Options{
+		Addr:     "127.0.0.1:6379",
+		Clients:  50,
+		Requests: 100000,
+		Pipeline: 1,
+		DataSize: 3,
+		KeySpace: 100000,
+		Tests:    []string{"ping", "set", "get", "incr", "lpush", "xadd"},
+	}
+}
+
+// Tests maps the names of the tests to the command each one sends, given the key and the value
+var Tests = map[string]func(key, value string) (string, []string){
+	"ping":  func(key, value string) (string, []string) { return "PING", nil },
+	"set":   func(key, value string) (string, []string) { return "SET", []string{"key:" + key, value} },
+	"get":   func(key, value string) (string, []string) { return "GET", []string{"key:" + key} },
+	"incr":  func(key, value string) (string, []string) { return "INCR", []string{"counter:" + key} },
+	"lpush": func(key, value string) (string, []string) { return "LPUSH", []string{"list:" + key, value} },
+	"xadd": func(key, value string) (string, []string) {
+		return "XADD", []string{"stream:" + key, "*", "field", value}
+	},
+}
+
+// Result holds the measures of a test
+type Result struct {
+	Test     string
+	Requests int64         // Commands that got a reply
+	Errors   int64         // Commands that got an error reply
+	Duration time.Duration // Time from the first command sent to the last reply read
+	Latency  server.LatencyHistogram
+}
+
+// RequestsPerSecond returns the throughput of the test
+func (r *Result) RequestsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// Run runs the tests of opts one after the other and writes a report of each to w as it
+// completes. It stops at the first test a client can't connect or send commands for.
+//
+// Examples:
+//
+//	opts := benchmark.DefaultOptions()
+//	opts.Pipeline, opts.Tests = 16, []string{"set", "get"}
+//	err := benchmark.Run(opts, os.Stdout)
+func Run(opts Options, w io.Writer) error {
+	if opts.Clients <= 0 || opts.Requests <= 0 || opts.Pipeline <= 0 || opts.KeySpace <= 0 || opts.DataSize < 0 {
+		return fmt.Errorf("clients, requests, pipeline and keyspace must be positive")
+	}
+	for _, test := range opts.Tests {
+		if _, ok := Tests[strings.ToLower(test)]; !ok {
+			return fmt.Errorf("unknown test %q", test)
+		}
+	}
+
+	for _, test := range opts.Tests {
+		result, err := RunTest(opts, strings.ToLower(test))
+		if err != nil {
+			return fmt.Errorf("%s: %w", test, err)
+		}
+		writeReport(w, opts, result)
+	}
+	return nil
+}
+
+// RunTest sends the commands of a test with opts.Clients clients and returns its measures
+func RunTest(opts Options, test string) (*Result, error) {
+	command, ok := Tests[test]
+	if !ok {
+		return nil, fmt.Errorf("unknown test %q", test)
+	}
+
+	conns := make([]net.Conn, 0, opts.Clients)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for range opts.Clients {
+		conn, err := connect(opts)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+
+	result := &Result{Test: test}
+	value := strings.Repeat("x", opts.DataSize)
+	remaining := atomic.Int64{}
+	remaining.Store(int64(opts.Requests))
+	var firstErr error
+	var errMu sync.Mutex
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runClient(conn, opts, command, value, &remaining, result); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				remaining.Store(0)
+			}
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+	return result, firstErr
+}
+
+// connect opens the connection of a client and authenticates it when a password is set
+func connect(opts Options) (net.Conn, error) {
+	conn, err := net.Dial("tcp", opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Password != "" {
+		buf := protocol.AppendCommand(nil, "AUTH", []protocol.Value{{Typ: "bulk", Bulk: opts.Password}})
+		reply, err := roundTrip(conn, protocol.NewResp(conn), buf)
+		if err == nil && reply.Typ == "error" {
+			err = fmt.Errorf("%s", reply.Str)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// roundTrip sends a command and reads its reply
+func roundTrip(conn net.Conn, reader *protocol.Resp, buf []byte) (protocol.Value, error) {
+	if _, err := conn.Write(buf); err != nil {
+		return protocol.Value{}, err
+	}
+	return reader.Read()
+}
+
+// runClient sends batches of opts.Pipeline commands on conn until every request of the test
+// was claimed, recording the time each command waited for its reply since its batch was sent
+func runClient(conn net.Conn, opts Options, command func(key, value string) (string, []string), value string, remaining *atomic.Int64, result *Result) error {
+	reader := protocol.NewResp(conn)
+	var buf []byte
+	args := make([]protocol.Value, 0, 4)
+	for {
+		batch := min(int64(opts.Pipeline), remaining.Add(-int64(opts.Pipeline))+int64(opts.Pipeline))
+		if batch <= 0 {
+			return nil
+		}
+
+		buf = buf[:0]
+		for range batch {
+			name, strs := command(strconv.Itoa(rand.IntN(opts.KeySpace)), value)
+			args = args[:0]
+			for _, s := range strs {
+				args = append(args, protocol.Value{Typ: "bulk", Bulk: s})
+			}
+			buf = protocol.AppendCommand(buf, name, args)
+		}
+
+		sent := time.Now()
+		if _, err := conn.Write(buf); err != nil {
+			return err
+		}
+		for range batch {
+			reply, err := reader.Read()
+			if err != nil {
+				return err
+			}
+			result.Latency.Record(time.Since(sent).Nanoseconds())
+			atomic.AddInt64(&result.Requests, 1)
+			if reply.Typ == "error" {
+				atomic.AddInt64(&result.Errors, 1)
+			}
+		}
+	}
+}
+
+// writeReport writes the measures of a test, latencies in milliseconds
+func writeReport(w io.Writer, opts Options, result *Result) {
+	ms := func(ns int64) string { return strconv.FormatFloat(float64(ns)/1e6, 'f', 3, 64) }
+	fmt.Fprintf(w, "====== %s ======\n", strings.ToUpper(result.Test))
+	fmt.Fprintf(w, "  %d requests completed in %.2f seconds\n", result.Requests, result.Duration.Seconds())
+	fmt.Fprintf(w, "  %d parallel clients\n", opts.Clients)
+	fmt.Fprintf(w, "  %d bytes payload\n", opts.DataSize)
+	fmt.Fprintf(w, "  pipeline %d\n", opts.Pipeline)
+	if result.Errors > 0 {
+		fmt.Fprintf(w, "  %d error replies\n", result.Errors)
+	}
+	fmt.Fprintf(w, "  throughput: %.2f requests per second\n", result.RequestsPerSecond())
+	fmt.Fprintf(w, "  latency (msec): p50=%s p95=%s p99=%s p99.9=%s max=%s\n\n",
+		ms(result.Latency.Percentile(50)), ms(result.Latency.Percentile(95)), ms(result.Latency.Percentile(99)),
+		ms(result.Latency.Percentile(99.9)), ms(result.Latency.Percentile(100)))
+}
@@ -0,0 +1,202 @@
+package benchmark
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// fakeServer replies to the commands of the clients connecting to it: +OK to AUTH with
+// password, an error to INCR and to AUTH with another password, +PONG to the others. It
+// returns its address and the number of commands it replied +PONG to.
+func fakeServer(t *testing.T, password string) (string, *atomic.Int64) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commands atomic.Int64
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		l.Close()
+		wg.Wait()
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				reader := protocol.NewResp(conn)
+				for {
+					command, err := reader.Read()
+					if err != nil {
+						return
+					}
+					reply := "+PONG\r\n"
+					switch name := strings.ToUpper(command.Array[0].Bulk); {
+					case name == "AUTH" && command.Array[1].Bulk == password:
+						reply = "+OK\r\n"
+					case name == "AUTH":
+						reply = "-WRONGPASS invalid password\r\n"
+					case name == "INCR":
+						reply = "-ERR not an integer\r\n"
+					default:
+						commands.Add(1)
+					}
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), &commands
+}
+
+func TestTests(t *testing.T) {
+	tests := []struct {
+		test     string
+		command  string
+		expected []string
+	}{
+		{"ping", "PING", nil},
+		{"set", "SET", []string{"key:7", "v"}},
+		{"get", "GET", []string{"key:7"}},
+		{"incr", "INCR", []string{"counter:7"}},
+		{"lpush", "LPUSH", []string{"list:7", "v"}},
+		{"xadd", "XADD", []string{"stream:7", "*", "field", "v"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.test, func(t *testing.T) {
+			command, args := Tests[tt.test]("7", "v")
+			if command != tt.command || !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("Expected %s %q, got %s %q", tt.command, tt.expected, command, args)
+			}
+		})
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(opts *Options)
+		expected string
+	}{
+		{"no clients", func(opts *Options) { opts.Clients = 0 }, "clients, requests, pipeline and keyspace must be positive"},
+		{"no requests", func(opts *Options) { opts.Requests = 0 }, "clients, requests, pipeline and keyspace must be positive"},
+		{"no pipeline", func(opts *Options) { opts.Pipeline = 0 }, "clients, requests, pipeline and keyspace must be positive"},
+		{"no keyspace", func(opts *Options) { opts.KeySpace = 0 }, "clients, requests, pipeline and keyspace must be positive"},
+		{"negative data size", func(opts *Options) { opts.DataSize = -1 }, "clients, requests, pipeline and keyspace must be positive"},
+		{"unknown test", func(opts *Options) { opts.Tests = []string{"ping", "flushall"} }, `unknown test "flushall"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.change(&opts)
+			var out bytes.Buffer
+			if err := Run(opts, &out); err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected the error %q, got %v", tt.expected, err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("Expected no test to run, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestRunTest(t *testing.T) {
+	tests := []struct {
+		name     string
+		test     string
+		clients  int
+		requests int
+		pipeline int
+		errors   int64
+	}{
+		{"one client", "ping", 1, 10, 1, 0},
+		{"pipelined", "set", 4, 100, 16, 0},
+		{"pipeline longer than the requests", "get", 2, 5, 16, 0},
+		{"error replies", "incr", 3, 30, 4, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, commands := fakeServer(t, "")
+			opts := DefaultOptions()
+			opts.Addr, opts.Clients, opts.Requests, opts.Pipeline = addr, tt.clients, tt.requests, tt.pipeline
+
+			result, err := RunTest(opts, tt.test)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if result.Test != tt.test || result.Requests != int64(tt.requests) || result.Errors != tt.errors {
+				t.Errorf("Expected %d requests and %d errors, got %d and %d", tt.requests, tt.errors, result.Requests, result.Errors)
+			}
+			if served := commands.Load() + tt.errors; served != int64(tt.requests) {
+				t.Errorf("Expected the server to get %d commands, got %d", tt.requests, served)
+			}
+			if result.Latency.Count() != int64(tt.requests) {
+				t.Errorf("Expected %d latencies, got %d", tt.requests, result.Latency.Count())
+			}
+		})
+	}
+}
+
+func TestRunAuthenticates(t *testing.T) {
+	addr, _ := fakeServer(t, "secret")
+	opts := DefaultOptions()
+	opts.Addr, opts.Clients, opts.Requests, opts.Tests = addr, 2, 10, []string{"PING"}
+
+	opts.Password = "secret"
+	var out bytes.Buffer
+	if err := Run(opts, &out); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	for _, line := range []string{"====== PING ======", "10 requests completed", "2 parallel clients", "throughput:", "latency (msec):"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected the report to contain %q, got %q", line, out.String())
+		}
+	}
+
+	opts.Password = "wrong"
+	err := Run(opts, &out)
+	if err == nil || !strings.Contains(err.Error(), "authenticating: WRONGPASS") {
+		t.Errorf("Expected the authentication to fail, got %v", err)
+	}
+}
+
+func TestRequestsPerSecond(t *testing.T) {
+	tests := []struct {
+		requests int64
+		duration time.Duration
+		expected float64
+	}{
+		{10, 0, 0},
+		{10, time.Second, 10},
+		{500, 250 * time.Millisecond, 2000},
+	}
+
+	for _, tt := range tests {
+		result := &Result{Requests: tt.requests, Duration: tt.duration}
+		if rps := result.RequestsPerSecond(); rps != tt.expected {
+			t.Errorf("%d requests in %v: expected %v per second, got %v", tt.requests, tt.duration, tt.expected, rps)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SplitArgs splits a line typed by the user into arguments separated by spaces, like
+// redis-cli. Arguments may be double quoted, with escapes like \n and \x41, or single quoted.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg strings.Builder
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i == len(line) {
+					return nil, fmt.Errorf("unbalanced quotes")
+				}
+				c := line[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(line) {
+					i++
+					c = line[i]
+					switch c {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					case 'x':
+						if i+2 < len(line) {
+							if n, err := strconv.ParseUint(line[i+1:i+3], 16, 8); err == nil {
+								c = byte(n)
+								i += 2
+							}
+						}
+					}
+				}
+				arg.WriteByte(c)
+				i++
+			}
+		case '\'':
+			i++
+			for {
+				if i == len(line) {
+					return nil, fmt.Errorf("unbalanced quotes")
+				}
+				if line[i] == '\'' {
+					i++
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+				}
+				arg.WriteByte(line[i])
+				i++
+			}
+		default:
+			for i < len(line) && !isSpace(line[i]) {
+				arg.WriteByte(line[i])
+				i++
+			}
+			args = append(args, arg.String())
+			continue
+		}
+
+		// A closing quote must be followed by a space or the end of the line
+		if i < len(line) && !isSpace(line[i]) {
+			return nil, fmt.Errorf("closing quote must be followed by a space")
+		}
+		args = append(args, arg.String())
+	}
+}
+
+// isSpace reports whether c separates arguments
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
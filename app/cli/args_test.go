@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+	}{
+		{"empty line", "", nil},
+		{"only spaces", "  \t ", nil},
+		{"words", "SET key value", []string{"SET", "key", "value"}},
+		{"extra spaces", "  GET\t key  ", []string{"GET", "key"}},
+		{"double quotes", `SET key "hello world"`, []string{"SET", "key", "hello world"}},
+		{"empty double quotes", `SET key ""`, []string{"SET", "key", ""}},
+		{"escapes", `"a\nb\r\t\\\"c"`, []string{"a\nb\r\t\\\"c"}},
+		{"hex escape", `"\x41\x00B"`, []string{"A\x00B"}},
+		{"invalid hex escape", `"\xzz"`, []string{"xzz"}},
+		{"unknown escape", `"\q"`, []string{"q"}},
+		{"single quotes", `SET key 'hello world'`, []string{"SET", "key", "hello world"}},
+		{"single quotes keep backslashes", `'a\nb'`, []string{`a\nb`}},
+		{"escaped single quote", `'it\'s'`, []string{"it's"}},
+		{"quote inside a word", `key"x`, []string{`key"x`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := SplitArgs(tt.line)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, args)
+			}
+		})
+	}
+}
+
+func TestSplitArgsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{"unbalanced double quotes", `SET key "value`, "unbalanced quotes"},
+		{"unbalanced single quotes", `SET key 'value`, "unbalanced quotes"},
+		{"trailing escape", `"value\`, "unbalanced quotes"},
+		{"text after double quotes", `"a"b`, "closing quote must be followed by a space"},
+		{"text after single quotes", `'a'b`, "closing quote must be followed by a space"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := SplitArgs(tt.line)
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Expected the error %q, got %q and %v", tt.expected, args, err)
+			}
+		})
+	}
+}
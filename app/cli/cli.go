@@ -0,0 +1,124 @@
+// Package cli is a command line client of the server, like redis-cli: it sends the commands
+// typed in a terminal, with line editing and a history, and pretty-prints their replies, so
+// the server can be tried without installing redis-cli.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// client is a connection to the server
+type client struct {
+	conn   net.Conn
+	reader *protocol.Resp
+}
+
+// send sends a command and returns its reply
+func (c *client) send(args []string) (protocol.Value, error) {
+	values := make([]protocol.Value, len(args)-1)
+	for i, arg := range args[1:] {
+		values[i] = protocol.Value{Typ: "bulk", Bulk: arg}
+	}
+	if _, err := c.conn.Write(protocol.AppendCommand(nil, args[0], values)); err != nil {
+		return protocol.Value{}, err
+	}
+	return c.reader.ReadReply()
+}
+
+// Run connects to the server at addr and runs the command args when given, printing its reply
+// to out. Without args it reads commands from in, one per line, until "quit" or the end of the
+// input. When in is a terminal each line is edited with a prompt; otherwise, like a script piped
+// to the client, lines are read without prompt.
+//
+// Examples:
+//
+//	err := cli.Run("127.0.0.1:6379", nil, os.Stdin, os.Stdout)              // Interactive session
+//	err := cli.Run("127.0.0.1:6379", []string{"GET", "key"}, os.Stdin, os.Stdout)   // Prints "value"
+func Run(addr string, args []string, in *os.File, out io.Writer) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	c := &client{conn: conn, reader: protocol.NewResp(conn)}
+
+	if len(args) > 0 {
+		return c.run(args, out)
+	}
+
+	editor, interactive := newLineEditor(in, out)
+	lines := bufio.NewScanner(in)
+	lines.Buffer(nil, 1<<30)
+	prompt := addr + "> "
+	for {
+		var line string
+		if interactive {
+			line, err = editor.readLine(prompt)
+			if errors.Is(err, errInterrupted) {
+				return nil
+			}
+		} else if lines.Scan() {
+			line = lines.Text()
+		} else {
+			err = lines.Err()
+			if err == nil {
+				err = io.EOF
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		args, err := SplitArgs(line)
+		if err != nil {
+			fmt.Fprintf(out, "Invalid argument(s): %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if command := strings.ToLower(args[0]); command == "quit" || command == "exit" {
+			return nil
+		}
+		if err := c.run(args, out); err != nil {
+			return err
+		}
+	}
+}
+
+// run sends a command and prints its reply. Subscriptions and MONITOR keep printing the
+// messages the server sends until the connection is closed.
+func (c *client) run(args []string, out io.Writer) error {
+	reply, err := c.send(args)
+	if err != nil {
+		return fmt.Errorf("reading the reply: %w", err)
+	}
+	fmt.Fprintln(out, FormatReply(reply))
+
+	switch strings.ToLower(args[0]) {
+	case "subscribe", "psubscribe", "ssubscribe", "monitor":
+		if reply.Typ == "error" {
+			return nil
+		}
+		fmt.Fprintln(out, "Reading messages... (press Ctrl-C to quit)")
+		for {
+			reply, err := c.reader.ReadReply()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, FormatReply(reply))
+		}
+	}
+	return nil
+}
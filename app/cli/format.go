@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// FormatReply returns a reply as redis-cli prints it to a terminal: bulk strings quoted,
+// integers, nulls and errors tagged with their type, and the elements of arrays, sets and
+// maps numbered, nested aggregates indented under their index.
+//
+// Examples:
+//
+//	FormatReply(protocol.Value{Typ: "integer", Num: 3})   // (integer) 3
+//	FormatReply(protocol.Value{Typ: "array", Array: []protocol.Value{{Typ: "bulk", Bulk: "a"}}})   // 1) "a"
+func FormatReply(v protocol.Value) string {
+	var b strings.Builder
+	formatReply(&b, v, "")
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatReply writes v followed by a newline, its lines after the first prefixed with indent
+func formatReply(b *strings.Builder, v protocol.Value, indent string) {
+	switch v.Typ {
+	case "string":
+		b.WriteString(v.Str)
+	case "error":
+		b.WriteString("(error) " + v.Str)
+	case "integer":
+		b.WriteString("(integer) " + strconv.Itoa(v.Num))
+	case "bulk":
+		b.WriteString(quote(v.Bulk))
+	case "verbatim":
+		b.WriteString(v.Bulk)
+	case "null":
+		b.WriteString("(nil)")
+	case "double":
+		b.WriteString("(double) " + formatDouble(v.Double))
+	case "boolean":
+		b.WriteString(fmt.Sprintf("(%t)", v.Bool))
+	case "big_number":
+		b.WriteString("(big number) " + v.Str)
+	case "array", "set", "push":
+		formatAggregate(b, v, indent)
+		return
+	case "map":
+		formatMap(b, v, indent)
+		return
+	default:
+		b.WriteString(fmt.Sprintf("(unknown reply type %s)", v.Typ))
+	}
+	b.WriteByte('\n')
+}
+
+// formatAggregate writes the elements of an array, a set or a push value, one per line
+func formatAggregate(b *strings.Builder, v protocol.Value, indent string) {
+	if len(v.Array) == 0 {
+		b.WriteString("(empty " + v.Typ + ")\n")
+		return
+	}
+	mark := ")"
+	if v.Typ == "set" {
+		mark = "~"
+	}
+	width := len(strconv.Itoa(len(v.Array)))
+	for i, element := range v.Array {
+		if i > 0 {
+			b.WriteString(indent)
+		}
+		index := fmt.Sprintf("%*d%s ", width, i+1, mark)
+		b.WriteString(index)
+		formatReply(b, element, indent+strings.Repeat(" ", len(index)))
+	}
+}
+
+// formatMap writes the entries of a map, one "key => value" per line
+func formatMap(b *strings.Builder, v protocol.Value, indent string) {
+	if len(v.Array) == 0 {
+		b.WriteString("(empty hash)\n")
+		return
+	}
+	entries := len(v.Array) / 2
+	width := len(strconv.Itoa(entries))
+	for i := range entries {
+		if i > 0 {
+			b.WriteString(indent)
+		}
+		index := fmt.Sprintf("%*d# ", width, i+1)
+		b.WriteString(index)
+		key := FormatReply(v.Array[2*i])
+		b.WriteString(key + " => ")
+		formatReply(b, v.Array[2*i+1], indent+strings.Repeat(" ", len(index)+len(key)+4))
+	}
+}
+
+// formatDouble returns a double like the server writes it, inf and -inf included
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return protocol.FormatDouble(f)
+}
+
+// quote returns s double quoted, with the bytes that are not printable escaped like
+// redis-cli does, so binary values can be told apart
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if c < 0x20 || c > 0x7e {
+				fmt.Fprintf(&b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
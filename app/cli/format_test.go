@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"math"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+func TestFormatReply(t *testing.T) {
+	bulk := func(s string) protocol.Value { return protocol.Value{Typ: "bulk", Bulk: s} }
+	tests := []struct {
+		name     string
+		reply    protocol.Value
+		expected string
+	}{
+		{"simple string", protocol.Value{Typ: "string", Str: "OK"}, "OK"},
+		{"error", protocol.Value{Typ: "error", Str: "ERR wrong"}, "(error) ERR wrong"},
+		{"integer", protocol.Value{Typ: "integer", Num: -3}, "(integer) -3"},
+		{"bulk", bulk("hello"), `"hello"`},
+		{"bulk escapes", bulk("a\"b\\c\n\x01\xff"), `"a\"b\\c\n\x01\xff"`},
+		{"null", protocol.Null(), "(nil)"},
+		{"double", protocol.Double(1.5), "(double) 1.5"},
+		{"double inf", protocol.Double(math.Inf(-1)), "(double) -inf"},
+		{"boolean", protocol.Boolean(true), "(true)"},
+		{"big number", protocol.BigNumber("12345678901234567890"), "(big number) 12345678901234567890"},
+		{"verbatim", protocol.Value{Typ: "verbatim", Str: "txt", Bulk: "line 1\nline 2"}, "line 1\nline 2"},
+		{"unknown type", protocol.Value{Typ: "strange"}, "(unknown reply type strange)"},
+		{"empty array", protocol.Value{Typ: "array"}, "(empty array)"},
+		{"empty set", protocol.Value{Typ: "set"}, "(empty set)"},
+		{"empty map", protocol.Value{Typ: "map"}, "(empty hash)"},
+		{"array", protocol.Value{Typ: "array", Array: []protocol.Value{bulk("a"), {Typ: "integer", Num: 1}}},
+			"1) \"a\"\n2) (integer) 1"},
+		{"set", protocol.Value{Typ: "set", Array: []protocol.Value{bulk("a"), bulk("b")}},
+			"1~ \"a\"\n2~ \"b\""},
+		{"indexes are aligned", protocol.Value{Typ: "array", Array: []protocol.Value{
+			bulk("1"), bulk("2"), bulk("3"), bulk("4"), bulk("5"), bulk("6"), bulk("7"), bulk("8"), bulk("9"), bulk("10"),
+		}}, " 1) \"1\"\n 2) \"2\"\n 3) \"3\"\n 4) \"4\"\n 5) \"5\"\n 6) \"6\"\n 7) \"7\"\n 8) \"8\"\n 9) \"9\"\n10) \"10\""},
+		{"nested array", protocol.Value{Typ: "array", Array: []protocol.Value{
+			bulk("a"),
+			{Typ: "array", Array: []protocol.Value{bulk("b"), bulk("c")}},
+		}}, "1) \"a\"\n2) 1) \"b\"\n   2) \"c\""},
+		{"map", protocol.Value{Typ: "map", Array: []protocol.Value{bulk("k1"), bulk("v1"), bulk("k2"), {Typ: "integer", Num: 2}}},
+			"1# \"k1\" => \"v1\"\n2# \"k2\" => (integer) 2"},
+		{"map of arrays", protocol.Value{Typ: "map", Array: []protocol.Value{
+			bulk("k"),
+			{Typ: "array", Array: []protocol.Value{bulk("x"), bulk("y")}},
+		}}, "1# \"k\" => 1) \"x\"\n          2) \"y\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := FormatReply(tt.reply); result != tt.expected {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+)
+
+// errInterrupted is returned by readLine when the user pressed Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// Control keys the line editor handles
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// lineEditor reads the lines typed in a terminal, which it puts in raw mode while a line is
+// edited: the cursor moves with the arrows, Home, End and the Emacs keys, and the up and down
+// arrows go through the lines typed before.
+type lineEditor struct {
+	fd      int
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+}
+
+// newLineEditor returns the editor of the lines typed in the terminal in, or false when in
+// is not a terminal that can be put in raw mode
+func newLineEditor(in *os.File, out io.Writer) (*lineEditor, bool) {
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, false
+	}
+	restore()
+	return &lineEditor{fd: int(in.Fd()), in: bufio.NewReader(in), out: out}, true
+}
+
+// readLine shows prompt and returns the line typed, io.EOF when Ctrl-D is pressed on an empty
+// line and errInterrupted on Ctrl-C
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	var line []rune
+	cursor := 0
+	historyIndex := len(e.history)
+	editing := "" // The line being typed while browsing the history
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - cursor; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	showHistory := func(index int) {
+		if index < 0 || index > len(e.history) || index == historyIndex {
+			return
+		}
+		if historyIndex == len(e.history) {
+			editing = string(line)
+		}
+		historyIndex = index
+		if index == len(e.history) {
+			line = []rune(editing)
+		} else {
+			line = []rune(e.history[index])
+		}
+		cursor = len(line)
+	}
+
+	refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			if len(line) > 0 && (len(e.history) == 0 || e.history[len(e.history)-1] != string(line)) {
+				e.history = append(e.history, string(line))
+			}
+			return string(line), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case keyBackspace, keyCtrlH:
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case keyCtrlA:
+			cursor = 0
+		case keyCtrlE:
+			cursor = len(line)
+		case keyCtrlB:
+			cursor = max(cursor-1, 0)
+		case keyCtrlF:
+			cursor = min(cursor+1, len(line))
+		case keyCtrlK:
+			line = line[:cursor]
+		case keyCtrlU:
+			line = append([]rune(nil), line[cursor:]...)
+			cursor = 0
+		case keyCtrlW:
+			start := cursor
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[cursor:]...)
+			cursor = start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			showHistory(historyIndex - 1)
+		case keyCtrlN:
+			showHistory(historyIndex + 1)
+		case keyEscape:
+			switch e.readEscape() {
+			case 'A':
+				showHistory(historyIndex - 1)
+			case 'B':
+				showHistory(historyIndex + 1)
+			case 'C':
+				cursor = min(cursor+1, len(line))
+			case 'D':
+				cursor = max(cursor-1, 0)
+			case 'H':
+				cursor = 0
+			case 'F':
+				cursor = len(line)
+			case '3':
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:cursor], append([]rune{r}, line[cursor:]...)...)
+				cursor++
+			}
+		}
+		refresh()
+	}
+}
+
+// readEscape reads the rest of an escape sequence sent by a key, and returns the letter of
+// the arrows, H or F for Home and End, '3' for Delete, or 0 for the keys it ignores
+func (e *lineEditor) readEscape() rune {
+	prefix, _, err := e.in.ReadRune()
+	if err != nil || (prefix != '[' && prefix != 'O') {
+		return 0
+	}
+	key, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if key < '0' || key > '9' {
+		return key
+	}
+	// Sequences like ESC [ 3 ~ end with a tilde
+	for {
+		next, _, err := e.in.ReadRune()
+		if err != nil || next == '~' {
+			break
+		}
+		if next < '0' || next > '9' {
+			return 0
+		}
+	}
+	switch key {
+	case '1', '7':
+		return 'H'
+	case '4', '8':
+		return 'F'
+	}
+	return key
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package cli
+
+import "errors"
+
+// makeRaw reports that the terminal can't be put in raw mode on this platform, so lines are
+// read without editing
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Acl handles the ACL command
+// Usage: ACL SETUSER username [rule ...] | ACL GETUSER username | ACL DELUSER username [username ...] |
+// ACL LIST | ACL USERS | ACL WHOAMI | ACL CAT [category] | ACL LOAD | ACL SAVE
+// Returns: OK, the description of the users, the requested names, or the number of deleted users.
+//
+// Rules enable or disable a user (on, off), manage its passwords (>pass, <pass, nopass, resetpass),
+// the commands it may run (+get, -@dangerous, +@all), and the keys and channels it may access
+// (~cache:*, allkeys, &news.*, allchannels).
+//
+// Examples:
+//
+//	ACL SETUSER alice on >secret ~cache:* +@read   // Lets alice read keys starting with cache:
+//	ACL GETUSER alice                              // Returns the flags, passwords and permissions of alice
+//	ACL LIST                                       // Returns the rules of every user
+//	ACL CAT dangerous                              // Returns the commands in the dangerous category
+//	ACL DELUSER alice                              // Removes alice and disconnects her clients
+func Acl(client *network.Client, args []shared.Value) shared.Value {
+	subcommand := strings.ToUpper(args[0].Bulk)
+
+	switch subcommand {
+	case "SETUSER":
+		return aclSetuser(args[1:])
+	case "GETUSER":
+		return aclGetuser(args[1].Bulk)
+	case "DELUSER":
+		return aclDeluser(args[1:])
+	case "LIST":
+		return aclList()
+	case "USERS":
+		return bulkArray(network.ACLUserNames())
+	case "WHOAMI":
+		user := network.DefaultUser
+		if info, ok := client.Info(); ok && info.User != "" {
+			user = info.User
+		}
+		return shared.Value{Typ: "bulk", Bulk: user}
+	case "CAT":
+		return aclCat(args[1:])
+	case "LOAD", "SAVE":
+		return aclLoadSave(subcommand)
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'acl' command")
+	}
+}
+
+// aclSetuser handles the ACL SETUSER subcommand
+func aclSetuser(args []shared.Value) shared.Value {
+	rules := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		rules = append(rules, arg.Bulk)
+	}
+	if err := network.ACLSetUser(args[0].Bulk, rules...); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// aclGetuser handles the ACL GETUSER subcommand, describing a user as a map of its
+// flags, password hashes, command rules, key patterns and channel patterns
+func aclGetuser(name string) shared.Value {
+	user, exists := network.ACLGetUser(name)
+	if !exists {
+		return shared.Null()
+	}
+
+	flags := []string{"off"}
+	if user.Enabled {
+		flags[0] = "on"
+	}
+	if user.NoPass {
+		flags = append(flags, "nopass")
+	}
+
+	return shared.Value{Typ: "map", Array: []shared.Value{
+		{Typ: "bulk", Bulk: "flags"}, bulkArray(flags),
+		{Typ: "bulk", Bulk: "passwords"}, bulkArray(user.Passwords),
+		{Typ: "bulk", Bulk: "commands"}, {Typ: "bulk", Bulk: strings.Join(user.CommandRules, " ")},
+		{Typ: "bulk", Bulk: "keys"}, {Typ: "bulk", Bulk: strings.Join(user.DescribeKeys(), " ")},
+		{Typ: "bulk", Bulk: "channels"}, {Typ: "bulk", Bulk: strings.Join(user.DescribeChannels(), " ")},
+		{Typ: "bulk", Bulk: "selectors"}, {Typ: "array", Array: []shared.Value{}},
+	}}
+}
+
+// aclDeluser handles the ACL DELUSER subcommand
+func aclDeluser(args []shared.Value) shared.Value {
+	for _, arg := range args {
+		if arg.Bulk == network.DefaultUser {
+			return createErrorResponse("ERR The 'default' user cannot be removed")
+		}
+	}
+
+	deleted := 0
+	for _, arg := range args {
+		if network.ACLDeleteUser(arg.Bulk) {
+			deleted++
+		}
+	}
+	return shared.Value{Typ: "integer", Num: deleted}
+}
+
+// aclList handles the ACL LIST subcommand, one "user <name> <rules>" line per user
+func aclList() shared.Value {
+	var lines []string
+	for _, name := range network.ACLUserNames() {
+		if user, exists := network.ACLGetUser(name); exists {
+			lines = append(lines, fmt.Sprintf("user %s %s", user.Name, user.Describe()))
+		}
+	}
+	return bulkArray(lines)
+}
+
+// aclCat handles the ACL CAT subcommand, listing the categories or the commands in one of them
+func aclCat(args []shared.Value) shared.Value {
+	switch len(args) {
+	case 0:
+		return bulkArray(network.ACLCategories)
+	case 1:
+		category := strings.ToLower(args[0].Bulk)
+		if !network.IsACLCategory(category) {
+			return createErrorResponse(fmt.Sprintf("ERR Unknown category '%s'", args[0].Bulk))
+		}
+		commands := network.ACLCategoryCommands(category)
+		sort.Strings(commands)
+		return bulkArray(commands)
+	default:
+		return shared.ErrWrongArity("acl|cat")
+	}
+}
+
+// aclLoadSave handles the ACL LOAD and ACL SAVE subcommands, which need an aclfile
+func aclLoadSave(subcommand string) shared.Value {
+	path := server.StoreState.ACLFile
+	if path == "" {
+		return createErrorResponse("ERR This Redis instance is not configured to use an ACL file. You may want to specify users via the ACL SETUSER command and then issue a CONFIG REWRITE (assuming you have a Redis configuration file set) in order to store users in the Redis configuration.")
+	}
+
+	var err error
+	if subcommand == "LOAD" {
+		err = network.LoadACLFile(path)
+	} else {
+		err = network.SaveACLFile(path)
+	}
+	if err != nil {
+		return createErrorResponse("ERR " + err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// bulkArray returns an array reply of bulk strings
+func bulkArray(values []string) shared.Value {
+	array := make([]shared.Value, len(values))
+	for i, value := range values {
+		array[i] = shared.Value{Typ: "bulk", Bulk: value}
+	}
+	return shared.Value{Typ: "array", Array: array}
+}
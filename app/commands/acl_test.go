@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// resetACLUsers removes every user but default and restores its initial rules
+func resetACLUsers() {
+	for _, name := range network.ACLUserNames() {
+		if name != network.DefaultUser {
+			network.ACLDeleteUser(name)
+		}
+	}
+	network.ACLSetUser(network.DefaultUser, "reset", "on", "nopass", "~*", "&*", "+@all")
+}
+
+// aclArgs returns the arguments of an ACL command
+func aclArgs(args ...string) []shared.Value {
+	values := make([]shared.Value, len(args))
+	for i, arg := range args {
+		values[i] = shared.Value{Typ: "bulk", Bulk: arg}
+	}
+	return values
+}
+
+func TestAclSetuserGetuser(t *testing.T) {
+	defer resetACLUsers()
+
+	result := Acl(network.ClientOf("acl-conn"), aclArgs("SETUSER", "alice", "on", ">secret", "~cache:*", "&news.*", "+@read", "-debug"))
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+
+	reply := Acl(network.ClientOf("acl-conn"), aclArgs("GETUSER", "alice"))
+	if reply.Typ != "map" {
+		t.Fatalf("Expected a map, got %v", reply)
+	}
+	fields := map[string]shared.Value{}
+	for i := 0; i+1 < len(reply.Array); i += 2 {
+		fields[reply.Array[i].Bulk] = reply.Array[i+1]
+	}
+	if flags := fields["flags"]; len(flags.Array) != 1 || flags.Array[0].Bulk != "on" {
+		t.Errorf("Expected flags [on], got %v", flags)
+	}
+	if passwords := fields["passwords"]; len(passwords.Array) != 1 || passwords.Array[0].Bulk != network.HashPassword("secret") {
+		t.Errorf("Expected the SHA-256 of the password, got %v", passwords)
+	}
+	if commands := fields["commands"]; commands.Bulk != "-@all +@read -debug" {
+		t.Errorf("Expected commands '-@all +@read -debug', got %q", commands.Bulk)
+	}
+	if keys := fields["keys"]; keys.Bulk != "~cache:*" {
+		t.Errorf("Expected keys '~cache:*', got %q", keys.Bulk)
+	}
+	if channels := fields["channels"]; channels.Bulk != "resetchannels &news.*" {
+		t.Errorf("Expected channels 'resetchannels &news.*', got %q", channels.Bulk)
+	}
+
+	if reply := Acl(network.ClientOf("acl-conn"), aclArgs("GETUSER", "bob")); reply.Typ != "null" {
+		t.Errorf("Expected null for an unknown user, got %v", reply)
+	}
+
+	// A failing rule leaves the user unchanged
+	result = Acl(network.ClientOf("acl-conn"), aclArgs("SETUSER", "alice", "off", "+nosuchcommand"))
+	if result.Typ != "error" || result.Str != "ERR Error in ACL SETUSER modifier '+nosuchcommand': Unknown command or category name in ACL" {
+		t.Errorf("Unexpected error %v", result)
+	}
+	if user, _ := network.ACLGetUser("alice"); !user.Enabled {
+		t.Errorf("Expected alice to stay enabled after a failed SETUSER")
+	}
+}
+
+func TestAclListUsersCat(t *testing.T) {
+	defer resetACLUsers()
+	network.ACLSetUser("alice", "on", "nopass", "allkeys", "+get")
+
+	list := Acl(network.ClientOf("acl-conn"), aclArgs("LIST"))
+	expected := []string{
+		"user alice on nopass ~* resetchannels -@all +get",
+		"user default on nopass ~* &* +@all",
+	}
+	if len(list.Array) != len(expected) {
+		t.Fatalf("Expected %d users, got %v", len(expected), list)
+	}
+	for i, line := range expected {
+		if list.Array[i].Bulk != line {
+			t.Errorf("Expected %q, got %q", line, list.Array[i].Bulk)
+		}
+	}
+
+	users := Acl(network.ClientOf("acl-conn"), aclArgs("USERS"))
+	if len(users.Array) != 2 || users.Array[0].Bulk != "alice" || users.Array[1].Bulk != "default" {
+		t.Errorf("Expected [alice default], got %v", users)
+	}
+
+	categories := Acl(network.ClientOf("acl-conn"), aclArgs("CAT"))
+	if len(categories.Array) != len(network.ACLCategories) {
+		t.Errorf("Expected %d categories, got %d", len(network.ACLCategories), len(categories.Array))
+	}
+	dangerous := Acl(network.ClientOf("acl-conn"), aclArgs("CAT", "dangerous"))
+	found := false
+	for _, command := range dangerous.Array {
+		if command.Bulk == "debug" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected debug in the dangerous category, got %v", dangerous)
+	}
+}
+
+func TestAclDeluser(t *testing.T) {
+	defer resetACLUsers()
+	defer registerTestClient(t, "acl-conn")()
+	network.ACLSetUser("alice", "on", ">secret", "+@all", "allkeys")
+	network.ACLSetUser("bob", "on", ">secret")
+
+	if err := network.ACLAuthenticate("acl-conn", "alice", "secret"); err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+	if whoami := Acl(network.ClientOf("acl-conn"), aclArgs("WHOAMI")); whoami.Bulk != "alice" {
+		t.Errorf("Expected WHOAMI alice, got %v", whoami)
+	}
+
+	result := Acl(network.ClientOf("acl-conn"), aclArgs("DELUSER", "alice", "bob", "carol"))
+	if result.Typ != "integer" || result.Num != 2 {
+		t.Errorf("Expected 2 deleted users, got %v", result)
+	}
+	if !network.ClientKilled("acl-conn") {
+		t.Errorf("Expected the client authenticated as alice to be disconnected")
+	}
+
+	result = Acl(network.ClientOf("acl-conn"), aclArgs("DELUSER", "default"))
+	if result.Typ != "error" || result.Str != "ERR The 'default' user cannot be removed" {
+		t.Errorf("Unexpected reply %v", result)
+	}
+}
+
+func TestAclErrors(t *testing.T) {
+	server.SetStoreState(shared.State{})
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "No subcommand", args: []string{}, expected: "ERR wrong number of arguments for 'acl' command"},
+		{name: "Unknown subcommand", args: []string{"FOO"}, expected: "ERR unknown subcommand for 'acl' command"},
+		{name: "SETUSER without a name", args: []string{"SETUSER"}, expected: "ERR wrong number of arguments for 'acl|setuser' command"},
+		{name: "Bad rule", args: []string{"SETUSER", "alice", "bogus"}, expected: "ERR Error in ACL SETUSER modifier 'bogus': Syntax error"},
+		{name: "GETUSER without a name", args: []string{"GETUSER"}, expected: "ERR wrong number of arguments for 'acl|getuser' command"},
+		{name: "Unknown category", args: []string{"CAT", "nope"}, expected: "ERR Unknown category 'nope'"},
+		{name: "LOAD without aclfile", args: []string{"LOAD"}, expected: "ERR This Redis instance is not configured to use an ACL file."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("ACL", Acl, "acl-conn", aclArgs(tt.args...))
+			if result.Typ != "error" || !strings.HasPrefix(result.Str, tt.expected) {
+				t.Errorf("Expected error %q, got %v", tt.expected, result)
+			}
+		})
+	}
+	if _, exists := network.ACLGetUser("alice"); exists {
+		t.Errorf("Expected a failed SETUSER not to create the user")
+	}
+}
+
+func TestAclPermissions(t *testing.T) {
+	defer resetACLUsers()
+	defer registerTestClient(t, "acl-conn")()
+	initCommandHandlers()
+	clearMemory()
+	network.ACLSetUser("reader", "on", ">secret", "~cache:*", "+@read", "+ping")
+
+	if err := network.ACLAuthenticate("acl-conn", "reader", "secret"); err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		args     []string
+		expected string
+	}{
+		{name: "Allowed command and key", command: "GET", args: []string{"cache:a"}, expected: ""},
+		{name: "Allowed command without keys", command: "PING", args: []string{}, expected: ""},
+		{name: "Denied command", command: "SET", args: []string{"cache:a", "1"}, expected: "NOPERM User reader has no permissions to run the 'set' command"},
+		{name: "Denied key", command: "GET", args: []string{"other"}, expected: "NOPERM No permissions to access a key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := network.ExecuteCommand(tt.command, "acl-conn", aclArgs(tt.args...))
+			if tt.expected == "" && result.Typ == "error" {
+				t.Errorf("Expected the command to run, got %v", result)
+			}
+			if tt.expected != "" && (result.Typ != "error" || result.Str != tt.expected) {
+				t.Errorf("Expected error %q, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestAclLoadSave(t *testing.T) {
+	defer resetACLUsers()
+	path := filepath.Join(t.TempDir(), "users.acl")
+	server.SetStoreState(shared.State{ACLFile: path})
+	defer server.SetStoreState(shared.State{})
+
+	network.ACLSetUser("alice", "on", ">secret", "~cache:*", "+get")
+	if result := Acl(network.ClientOf("acl-conn"), aclArgs("SAVE")); result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+
+	network.ACLDeleteUser("alice")
+	if result := Acl(network.ClientOf("acl-conn"), aclArgs("LOAD")); result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	user, exists := network.ACLGetUser("alice")
+	if !exists || !user.CheckPassword("secret") || !user.CanAccessKey("cache:1") || user.CanAccessKey("other") {
+		t.Errorf("Expected alice to be restored from the ACL file, got %+v", user)
+	}
+
+	// A broken file is rejected without touching the users
+	os.WriteFile(path, []byte("user bob on +nosuchcommand\n"), 0644)
+	result := Acl(network.ClientOf("acl-conn"), aclArgs("LOAD"))
+	if result.Typ != "error" || !strings.Contains(result.Str, "Unknown command or category name in ACL") {
+		t.Errorf("Expected a load error, got %v", result)
+	}
+	if _, exists := network.ACLGetUser("alice"); !exists {
+		t.Errorf("Expected the users to be kept after a failed load")
+	}
+}
+
+func BenchmarkAclSetuser(b *testing.B) {
+	defer resetACLUsers()
+	args := aclArgs("SETUSER", "bench", "on", ">secret", "~cache:*", "+@read", "-@dangerous")
+	for i := 0; i < b.N; i++ {
+		Acl(network.ClientOf("acl-conn"), args)
+	}
+}
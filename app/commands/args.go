@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Errors replied to arguments that don't parse
+var (
+	errSyntax     = errors.New(shared.SyntaxMessage)
+	errNotInteger = errors.New(shared.NotIntegerMessage)
+)
+
+// isOption reports whether arg is the option name, given uppercase, written in any case
+func isOption(arg shared.Value, name string) bool {
+	return strings.EqualFold(arg.Bulk, name)
+}
+
+// argScanner reads the arguments of a command one at a time, matching option names in any
+// case, so every command parses its options the same way and replies the same errors.
+//
+// Examples:
+//
+//	scanner := newArgScanner(args[2:])
+//	for !scanner.Done() {
+//		switch scanner.NextOption() {
+//		case "PX":
+//			ms, err := scanner.Int64()   // errSyntax when PX is the last argument
+//		default:
+//			return shared.ErrSyntax()
+//		}
+//	}
+type argScanner struct {
+	args []shared.Value
+	pos  int
+}
+
+// newArgScanner returns a scanner reading args from the first one
+func newArgScanner(args []shared.Value) *argScanner {
+	return &argScanner{args: args}
+}
+
+// Done reports whether every argument was read
+func (s *argScanner) Done() bool {
+	return s.pos >= len(s.args)
+}
+
+// NextOption reads the next argument and returns it uppercase, or "" when none is left
+func (s *argScanner) NextOption() string {
+	if s.Done() {
+		return ""
+	}
+	s.pos++
+	return strings.ToUpper(s.args[s.pos-1].Bulk)
+}
+
+// Option reads the next argument when it is one of names, given uppercase, and returns the
+// name it matched. Nothing is read otherwise.
+func (s *argScanner) Option(names ...string) (string, bool) {
+	if s.Done() {
+		return "", false
+	}
+	for _, name := range names {
+		if isOption(s.args[s.pos], name) {
+			s.pos++
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Value reads the value of an option as given, failing with errSyntax when none is left
+func (s *argScanner) Value() (string, error) {
+	if s.Done() {
+		return "", errSyntax
+	}
+	s.pos++
+	return s.args[s.pos-1].Bulk, nil
+}
+
+// Int64 reads the value of an option as an integer, failing with errSyntax when none is left
+// and errNotInteger when it is not an integer
+func (s *argScanner) Int64() (int64, error) {
+	value, err := s.Value()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
+
+// Rest reads every argument left
+func (s *argScanner) Rest() []shared.Value {
+	rest := s.args[s.pos:]
+	s.pos = len(s.args)
+	return rest
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Asking handles the ASKING command
+// Usage: ASKING
+// Returns: OK, or an error when cluster mode is disabled.
+//
+// A client redirected with -ASK sends ASKING before retrying its command on the node
+// importing the slot, which then serves the command although it doesn't own the slot yet.
+// The flag only applies to the next command.
+//
+// Examples:
+//
+//	ASKING          // Returns OK
+//	GET mykey       // Served by the node importing the slot of mykey
+func Asking(client *network.Client, args []shared.Value) shared.Value {
+	if !network.ClusterEnabled() {
+		return createErrorResponse("ERR This instance has cluster support disabled")
+	}
+	client.SetAsking()
+	return shared.Value{Typ: "string", Str: "OK"}
+}
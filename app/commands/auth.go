@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Auth handles the AUTH command
+// Usage: AUTH [username] password
+// Returns: OK when the credentials are valid, or an error.
+//
+// Without a username, the password is checked against the default user, set with requirepass.
+//
+// Examples:
+//
+//	AUTH secret           // Authenticates as the default user
+//	AUTH alice wonderland // Authenticates as alice
+func Auth(client *network.Client, args []shared.Value) shared.Value {
+	var username, password string
+	switch len(args) {
+	case 1:
+		username, password = network.DefaultUser, args[0].Bulk
+		if network.ACLDefaultUserAuthenticates() {
+			return createErrorResponse("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		}
+	case 2:
+		username, password = args[0].Bulk, args[1].Bulk
+	default:
+		return shared.ErrSyntax()
+	}
+
+	if err := network.ACLAuthenticate(client.ConnID, username, password); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
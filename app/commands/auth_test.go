@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+)
+
+func TestAuth(t *testing.T) {
+	defer resetACLUsers()
+	defer registerTestClient(t, "auth-conn")()
+
+	result := Auth(network.ClientOf("auth-conn"), aclArgs("secret"))
+	if result.Typ != "error" || result.Str != "ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?" {
+		t.Errorf("Unexpected reply without requirepass %v", result)
+	}
+
+	network.ACLSetDefaultPassword("secret")
+	network.ACLSetUser("alice", "on", ">wonderland", "+@all", "allkeys")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+		user     string
+	}{
+		{name: "Wrong default password", args: []string{"nope"}, expected: "WRONGPASS invalid username-password pair or user is disabled."},
+		{name: "Default password", args: []string{"secret"}, user: "default"},
+		{name: "Unknown user", args: []string{"bob", "secret"}, expected: "WRONGPASS invalid username-password pair or user is disabled."},
+		{name: "Named user", args: []string{"alice", "wonderland"}, user: "alice"},
+		{name: "Too many arguments", args: []string{"alice", "wonderland", "x"}, expected: "ERR syntax error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Auth(network.ClientOf("auth-conn"), aclArgs(tt.args...))
+			if tt.expected != "" {
+				if result.Typ != "error" || result.Str != tt.expected {
+					t.Errorf("Expected error %q, got %v", tt.expected, result)
+				}
+				return
+			}
+			if result.Typ != "string" || result.Str != "OK" {
+				t.Errorf("Expected OK, got %v", result)
+			}
+			if info, _ := network.ClientInfoGet("auth-conn"); info.User != tt.user || !info.Authenticated {
+				t.Errorf("Expected the connection to be authenticated as %s, got %+v", tt.user, info)
+			}
+		})
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	defer resetACLUsers()
+	initCommandHandlers()
+	network.ACLSetDefaultPassword("secret")
+
+	defer registerTestClient(t, "auth-conn")()
+	result := network.ExecuteCommand("PING", "auth-conn", nil)
+	if result.Typ != "error" || result.Str != "NOAUTH Authentication required." {
+		t.Errorf("Expected NOAUTH before AUTH, got %v", result)
+	}
+
+	Auth(network.ClientOf("auth-conn"), aclArgs("secret"))
+	if result := network.ExecuteCommand("PING", "auth-conn", nil); result.Typ == "error" {
+		t.Errorf("Expected PING to run after AUTH, got %v", result)
+	}
+}
+
+func BenchmarkAuth(b *testing.B) {
+	defer resetACLUsers()
+	defer registerTestClient(b, "auth-conn")()
+	network.ACLSetUser("alice", "on", ">wonderland")
+	args := aclArgs("alice", "wonderland")
+	for i := 0; i < b.N; i++ {
+		Auth(network.ClientOf("auth-conn"), args)
+	}
+}
@@ -0,0 +1,19 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// bgrewriteaof handles the BGREWRITEAOF command.
+// Usage: BGREWRITEAOF
+// Returns: "Background append only file rewriting started", or an error if a rewrite is already running.
+// This command regenerates the append only file from the dataset in a background goroutine.
+// Writes made during the rewrite are buffered and added to the new file before it replaces the old one.
+func Bgrewriteaof(client *network.Client, args []shared.Value) shared.Value {
+	if err := storage.BackgroundRewriteAppendOnlyFile(); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "Background append only file rewriting started"}
+}
@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// waitForAppendOnlyRewrite waits for a running BGREWRITEAOF to finish
+func waitForAppendOnlyRewrite(t testing.TB) {
+	deadline := time.Now().Add(time.Second)
+	for storage.AppendOnlyRewriteInProgress() {
+		if time.Now().After(deadline) {
+			t.Fatalf("AOF rewrite did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// replayCommand runs a command loaded from the append only file through the test handlers
+func replayCommand(command string, args []shared.Value) shared.Value {
+	handler, ok := network.CommandHandlers[command]
+	if !ok {
+		return createErrorResponse("ERR unknown command '" + command + "'")
+	}
+	return handler(network.ClientOf("aof-loader"), args)
+}
+
+func TestBgrewriteaof(t *testing.T) {
+	t.Run("Commands", func(t *testing.T) { testBgrewriteaof(t, false) })
+	t.Run("RDB preamble", func(t *testing.T) { testBgrewriteaof(t, true) })
+}
+
+// testBgrewriteaof checks that a rewrite in either format rebuilds the logged dataset
+func testBgrewriteaof(t *testing.T, preamble bool) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:              "master",
+		Replicas:          make(map[string]net.Conn),
+		ConfigDir:         dir,
+		AppendOnly:        true,
+		AppendFilename:    "appendonly.aof",
+		AppendDirname:     "appendonlydir",
+		AppendFsync:       storage.AppendFsyncNo,
+		AOFUseRDBPreamble: preamble,
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer storage.CloseAppendOnlyFile()
+	clearMemory()
+	initCommandHandlers()
+	// Sets and hashes only come from RDB files
+	server.Memory.Set("set", shared.MemoryEntry{Set: map[string]struct{}{"a": {}, "b": {}}, Expires: 4102444800000})
+	server.Memory.Set("hash", shared.MemoryEntry{Hash: map[string]string{"f": "v"}})
+
+	if err := storage.OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Failed to open append only file: %v", err)
+	}
+
+	// Writes are appended as they are propagated
+	writes := [][]string{
+		{"SET", "string", "value"},
+		{"SET", "temp", "value", "PX", "100000"},
+		{"RPUSH", "list", "a", "b", "c"},
+		{"LPOP", "list"},
+		{"ZADD", "zset", "1", "one", "2.5", "two"},
+		{"XADD", "stream", "1-1", "field", "value"},
+		{"INCR", "counter"},
+		{"INCR", "counter"},
+		{"PEXPIREAT", "list", "4102444800000"},
+		{"PEXPIREAT", "zset", "4102444800000"},
+		{"PEXPIREAT", "stream", "4102444800000"},
+	}
+	for _, write := range writes {
+		args := make([]shared.Value, len(write)-1)
+		for i, arg := range write[1:] {
+			args[i] = shared.Value{Typ: "bulk", Bulk: arg}
+		}
+		if result := network.ExecuteAndPropagate(write[0], "test-conn", args); result.Typ == "error" {
+			t.Fatalf("%v failed: %v", write, result)
+		}
+	}
+	expected := server.Memory.Clone()
+
+	before := storage.GetPersistenceStats().AOFCurrentSize
+
+	result := Bgrewriteaof(network.ClientOf("test-conn"), []shared.Value{})
+	if result.Typ != "string" || result.Str != "Background append only file rewriting started" {
+		t.Fatalf("Expected rewrite to start, got %v", result)
+	}
+	waitForAppendOnlyRewrite(t)
+
+	// The new base is smaller than the log of writes and rebuilds the same dataset
+	if after := storage.GetPersistenceStats().AOFCurrentSize; after >= before {
+		t.Errorf("Expected the rewrite to shrink the file, %d >= %d bytes", after, before)
+	}
+	base := "appendonly.aof.2.base.aof"
+	if preamble {
+		base = "appendonly.aof.2.base.rdb"
+	}
+	if _, err := os.Stat(filepath.Join(dir, "appendonlydir", base)); err != nil {
+		t.Errorf("Expected the new base file %s: %v", base, err)
+	}
+	clearMemory()
+	if err := storage.LoadAppendOnlyFile(dir, "appendonly.aof", replayCommand); err != nil {
+		t.Fatalf("Failed to load append only file: %v", err)
+	}
+
+	for key, entry := range expected {
+		loaded := getEntry(key)
+		if loaded.Expires != entry.Expires {
+			t.Errorf("Expected %q to expire at %d, got %d", key, entry.Expires, loaded.Expires)
+		}
+		switch {
+		case entry.List != nil:
+			if !reflect.DeepEqual(getListAsArray(key), entry.List.ToArray()) {
+				t.Errorf("Expected list %v, got %v", entry.List.ToArray(), getListAsArray(key))
+			}
+		case entry.SortedSet != nil:
+			if loaded.SortedSet == nil || !reflect.DeepEqual(loaded.SortedSet.Scores(), entry.SortedSet.Scores()) {
+				t.Errorf("Expected sorted set %v, got %+v", entry.SortedSet.Scores(), loaded)
+			}
+		case entry.Stream != nil:
+			if !reflect.DeepEqual(loaded.Stream, entry.Stream) {
+				t.Errorf("Expected stream %v, got %v", entry.Stream, loaded.Stream)
+			}
+		case entry.Set != nil:
+			if !reflect.DeepEqual(loaded.Set, entry.Set) {
+				t.Errorf("Expected set %v, got %v", entry.Set, loaded.Set)
+			}
+		case entry.Hash != nil:
+			if !reflect.DeepEqual(loaded.Hash, entry.Hash) {
+				t.Errorf("Expected hash %v, got %v", entry.Hash, loaded.Hash)
+			}
+		default:
+			if loaded.Value != entry.Value {
+				t.Errorf("Expected %q to be %+v, got %+v", key, entry, loaded)
+			}
+		}
+	}
+}
+
+func TestBgrewriteaofWhileWriting(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:           "master",
+		Replicas:       make(map[string]net.Conn),
+		ConfigDir:      dir,
+		AppendOnly:     true,
+		AppendFilename: "appendonly.aof",
+		AppendDirname:  "appendonlydir",
+		AppendFsync:    storage.AppendFsyncNo,
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer storage.CloseAppendOnlyFile()
+	clearMemory()
+	initCommandHandlers()
+	network.CommandHandlers["BGREWRITEAOF"] = Bgrewriteaof
+	if err := storage.OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Failed to open append only file: %v", err)
+	}
+
+	// Every INCR is either in the base of a rewrite or in the incremental file opened with it
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			connID := "test-conn-" + strconv.Itoa(i)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					network.ExecuteAndPropagate("INCR", connID, []shared.Value{{Typ: "bulk", Bulk: "counter"}})
+				}
+			}
+		}()
+	}
+	for range 20 {
+		network.ExecuteAndPropagate("BGREWRITEAOF", "test-conn", []shared.Value{})
+		waitForAppendOnlyRewrite(t)
+	}
+	close(stop)
+	wg.Wait()
+
+	expected := getEntry("counter").Value
+	clearMemory()
+	if err := storage.LoadAppendOnlyFile(dir, "appendonly.aof", replayCommand); err != nil {
+		t.Fatalf("Failed to load append only file: %v", err)
+	}
+	if value := getEntry("counter").Value; value != expected {
+		t.Errorf("Expected counter %s, got %s", expected, value)
+	}
+}
+
+func TestBgrewriteaofInvalidArgs(t *testing.T) {
+	result := runCommand("BGREWRITEAOF", Bgrewriteaof, "test-conn", []shared.Value{{Typ: "bulk", Bulk: "extra"}})
+	if result.Typ != "error" {
+		t.Errorf("Expected error, got %v", result)
+	}
+}
+
+func BenchmarkBgrewriteaof(b *testing.B) {
+	server.SetStoreState(shared.State{
+		Role:           "master",
+		Replicas:       make(map[string]net.Conn),
+		ConfigDir:      b.TempDir(),
+		AppendFilename: "appendonly.aof",
+	})
+	clearMemory()
+	for i := 0; i < 100; i++ {
+		server.Memory.Set("key"+string(rune('a'+i%26))+string(rune('a'+i/26)), shared.MemoryEntry{Value: "value"})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Bgrewriteaof(network.ClientOf("test-conn"), []shared.Value{})
+		waitForAppendOnlyRewrite(b)
+	}
+}
@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// bgsave handles the BGSAVE command.
+// Usage: BGSAVE
+// Returns: "Background saving started", or an error if a save is already in progress.
+// This command snapshots the dataset and writes it to the RDB file from a background goroutine,
+// so clients keep being served while the file is written.
+func Bgsave(client *network.Client, args []shared.Value) shared.Value {
+	if len(args) != 0 {
+		return shared.ErrWrongArity("bgsave")
+	}
+
+	if err := storage.BackgroundSave(); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "Background saving started"}
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// waitForBackgroundSave waits for a running BGSAVE to finish
+func waitForBackgroundSave(t testing.TB) {
+	deadline := time.Now().Add(time.Second)
+	for storage.BackgroundSaveInProgress() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Background save did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBgsave(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        dir,
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	result := Bgsave(network.ClientOf("test-conn"), []shared.Value{})
+	if result.Typ != "string" || result.Str != "Background saving started" {
+		t.Fatalf("Expected background saving to start, got %v", result)
+	}
+
+	// Writes made after BGSAVE started are not part of the snapshot
+	server.Memory.Set("later", shared.MemoryEntry{Value: "value"})
+	waitForBackgroundSave(t)
+
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); err != nil {
+		t.Fatalf("Expected RDB file to be written: %v", err)
+	}
+
+	clearMemory()
+	if err := storage.LoadRDBFile(dir, "dump.rdb"); err != nil {
+		t.Fatalf("Failed to load saved RDB file: %v", err)
+	}
+	if getEntry("key").Value != "value" {
+		t.Errorf("Expected key to be restored, got %v", getEntry("key"))
+	}
+	if _, exists := server.Memory.Get("later"); exists {
+		t.Errorf("Expected key written after BGSAVE not to be saved")
+	}
+}
+
+func TestBgsaveInvalidArgs(t *testing.T) {
+	result := Bgsave(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "extra"}})
+	if result.Typ != "error" {
+		t.Errorf("Expected error, got %v", result)
+	}
+}
+
+func BenchmarkBgsave(b *testing.B) {
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        b.TempDir(),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Bgsave(network.ClientOf("test-conn"), []shared.Value{})
+		waitForBackgroundSave(b)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 // blpop handles the BLPOP command.
@@ -29,13 +31,8 @@ import (
 //	BLPOP mylist 1.5                  // Wait up to 1.5 seconds
 //	BLPOP nonexistent 1               // Returns null after 1 second timeout
 //
-// Note: This implementation uses polling to check for available elements every 100ms.
-// For production use, consider implementing an event-driven approach for better performance.
-func Blpop(connID string, args []shared.Value) shared.Value {
-	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'blpop' command")
-	}
-
+// Note: A waiting client is woken by the writes to its lists, see network.BlockOnKeys.
+func Blpop(client *network.Client, args []shared.Value) shared.Value {
 	// Last argument is the timeout (can be integer or float)
 	timeoutStr := args[len(args)-1].Bulk
 	timeout, err := strconv.ParseFloat(timeoutStr, 64)
@@ -47,14 +44,17 @@ func Blpop(connID string, args []shared.Value) shared.Value {
 	checkAndPop := func() *shared.Value {
 		for i := 0; i < len(args)-1; i++ {
 			key := args[i].Bulk
-			entry, exists := server.Memory[key]
-
-			if exists {
-				var value string
-				var found bool
+			var value string
+			var found bool
 
+			// Checking and popping under the lock of the key, so two clients can't pop the same element
+			server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+				if !exists {
+					return entry, false
+				}
 				// Check linked list first
 				if entry.List != nil && entry.List.Size > 0 {
+					storage.CopyOnWrite(key)
 					value = entry.List.RemoveFromHead()
 					found = true
 				} else if len(entry.Array) > 0 {
@@ -63,16 +63,18 @@ func Blpop(connID string, args []shared.Value) shared.Value {
 					entry.Array = entry.Array[1:]
 					found = true
 				}
+				return entry, found
+			})
 
-				if found {
-					server.Memory[key] = entry
+			if found {
+				server.NotifyKeyModified(0, key, "blpop")
+				client.MarkDirty(1)
 
-					// Return [key, value] array
-					return &shared.Value{Typ: "array", Array: []shared.Value{
-						{Typ: "string", Str: key},
-						{Typ: "string", Str: value},
-					}}
-				}
+				// Return [key, value] array
+				return &shared.Value{Typ: "array", Array: []shared.Value{
+					{Typ: "string", Str: key},
+					{Typ: "string", Str: value},
+				}}
 			}
 		}
 		return nil
@@ -82,27 +84,27 @@ func Blpop(connID string, args []shared.Value) shared.Value {
 	if result := checkAndPop(); result != nil {
 		return *result
 	}
+	// In a transaction or a script it times out at once
+	if inExecContext(client.ConnID) {
+		return shared.NullArray()
+	}
 
-	// No elements available, block until timeout or element becomes available
-	if timeout == 0 {
-		// Block indefinitely
-		for {
-			time.Sleep(10 * time.Millisecond) // Reduced polling interval for better responsiveness
-			if result := checkAndPop(); result != nil {
-				return *result
-			}
-		}
-	} else {
-		// Block with timeout (convert float seconds to duration)
-		deadline := time.Now().Add(time.Duration(timeout * float64(time.Second)))
-		for time.Now().Before(deadline) {
-			time.Sleep(10 * time.Millisecond) // Reduced polling interval for better responsiveness
-			if result := checkAndPop(); result != nil {
-				return *result
-			}
+	// No elements available, wait for a push to one of the lists or the timeout.
+	// A client killed meanwhile stops waiting, so nothing is popped for a closed connection.
+	keys := make([]string, 0, len(args)-1)
+	for _, arg := range args[:len(args)-1] {
+		keys = append(keys, arg.Bulk)
+	}
+	serve := func() (shared.Value, bool) {
+		if result := checkAndPop(); result != nil {
+			return *result, true
 		}
+		return shared.Value{}, false
+	}
+	if result, ok := network.BlockOnKeys(client, keys, time.Duration(timeout*float64(time.Second)), serve); ok {
+		return result
 	}
 
 	// Timeout reached, return null array
-	return shared.Value{Typ: "null_array", Str: ""}
+	return shared.NullArray()
 }
@@ -1,8 +1,11 @@
 package commands
 
 import (
+	"net"
 	"testing"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 )
@@ -27,17 +30,17 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "mylist"},
 				{Typ: "string", Str: "a"},
 			}},
 			verify: func() {
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after BLPOP")
 				}
@@ -58,14 +61,14 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["list1"] = shared.MemoryEntry{
+				server.Memory.Set("list1", shared.MemoryEntry{
 					Array:   []string{"first"},
 					Expires: 0,
-				}
-				server.Memory["list2"] = shared.MemoryEntry{
+				})
+				server.Memory.Set("list2", shared.MemoryEntry{
 					Array:   []string{"second"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "list1"},
@@ -73,7 +76,7 @@ func TestBlpop(t *testing.T) {
 			}},
 			verify: func() {
 				// Verify list1 is popped from
-				entry1, exists := server.Memory["list1"]
+				entry1, exists := server.Memory.Get("list1")
 				if !exists {
 					t.Error("List1 should still exist after BLPOP")
 				}
@@ -82,7 +85,7 @@ func TestBlpop(t *testing.T) {
 				}
 
 				// Verify list2 is unchanged
-				entry2, exists := server.Memory["list2"]
+				entry2, exists := server.Memory.Get("list2")
 				if !exists {
 					t.Error("List2 should still exist after BLPOP")
 				}
@@ -99,15 +102,15 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "0.1"}, // 100ms timeout
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
-			expected: shared.Value{Typ: "null_array", Str: ""},
+			expected: shared.NullArray(),
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["emptylist"]
+				entry, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should still exist after BLPOP timeout")
 				}
@@ -124,7 +127,7 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "0.1"}, // 100ms timeout
 			},
 			setup:    func() {},
-			expected: shared.Value{Typ: "null_array", Str: ""},
+			expected: shared.NullArray(),
 			verify:   func() {},
 		},
 		{
@@ -135,15 +138,15 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "0.001"}, // 1ms timeout
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
-			expected: shared.Value{Typ: "null_array", Str: ""},
+			expected: shared.NullArray(),
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["emptylist"]
+				entry, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should still exist after BLPOP")
 				}
@@ -160,17 +163,17 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "0.5"}, // 500ms timeout
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "mylist"},
 				{Typ: "string", Str: "a"},
 			}},
 			verify: func() {
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after BLPOP")
 				}
@@ -187,15 +190,15 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "-1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR timeout is not a float or out of range"},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after error")
 				}
@@ -212,15 +215,15 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "invalid"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR timeout is not a float or out of range"},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after error")
 				}
@@ -247,17 +250,17 @@ func TestBlpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["unicodelist"] = shared.MemoryEntry{
+				server.Memory.Set("unicodelist", shared.MemoryEntry{
 					Array:   []string{"Hello 世界", "🌍", "测试"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "unicodelist"},
 				{Typ: "string", Str: "Hello 世界"},
 			}},
 			verify: func() {
-				entry, exists := server.Memory["unicodelist"]
+				entry, exists := server.Memory.Get("unicodelist")
 				if !exists {
 					t.Error("List should still exist after BLPOP")
 				}
@@ -276,7 +279,7 @@ func TestBlpop(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Blpop(tt.connID, tt.args)
+			result := runCommand("BLPOP", Blpop, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Blpop() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -303,12 +306,93 @@ func TestBlpop(t *testing.T) {
 	}
 }
 
+func TestBlpopWokenByPush(t *testing.T) {
+	clearMemory()
+	initCommandHandlers()
+
+	done := make(chan shared.Value, 1)
+	go func() {
+		done <- Blpop(network.ClientOf("test-conn-blocked"), []shared.Value{
+			{Typ: "bulk", Bulk: "queue"},
+			{Typ: "bulk", Bulk: "5"},
+		})
+	}()
+	for server.BlockedClients() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The push wakes the waiting client right away, long before its timeout
+	start := time.Now()
+	network.ExecuteCommand("RPUSH", "test-conn-pusher", []shared.Value{
+		{Typ: "bulk", Bulk: "queue"},
+		{Typ: "bulk", Bulk: "job"},
+	})
+	select {
+	case result := <-done:
+		if len(result.Array) != 2 || result.Array[0].Str != "queue" || result.Array[1].Str != "job" {
+			t.Errorf("Blpop() = %v, expected [queue job]", result)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Blpop() woke after %v, expected right after the push", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Blpop() was not woken by the push")
+	}
+	if length := len(getListAsArray("queue")); length != 0 {
+		t.Errorf("list length = %d, expected 0", length)
+	}
+}
+
+func TestBlpopCanceledByDisconnect(t *testing.T) {
+	clearMemory()
+	initCommandHandlers()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	client := network.ClientRegister("test-conn-gone", serverConn)
+	defer network.ClientUnregister("test-conn-gone")
+
+	done := make(chan shared.Value, 1)
+	go func() {
+		done <- Blpop(network.ClientOf("test-conn-gone"), []shared.Value{
+			{Typ: "bulk", Bulk: "queue"},
+			{Typ: "bulk", Bulk: "0"},
+		})
+	}()
+	for server.BlockedClients() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The client leaving stops the wait, which would last forever otherwise
+	client.Disconnected()
+	select {
+	case result := <-done:
+		if !result.IsNull() {
+			t.Errorf("Blpop() = %v, expected a null reply", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Blpop() kept waiting after the client disconnected")
+	}
+	if blocked := server.BlockedClients(); blocked != 0 {
+		t.Errorf("BlockedClients() = %d, expected 0 once the wait was canceled", blocked)
+	}
+
+	// Nothing pushed afterwards is popped for the client that left
+	network.ExecuteCommand("RPUSH", "test-conn-pusher", []shared.Value{
+		{Typ: "bulk", Bulk: "queue"},
+		{Typ: "bulk", Bulk: "job"},
+	})
+	if length := len(getListAsArray("queue")); length != 1 {
+		t.Errorf("list length = %d, expected 1", length)
+	}
+}
+
 func BenchmarkBlpop(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -318,20 +402,20 @@ func BenchmarkBlpop(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Blpop(connID, args)
+		Blpop(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkBlpopMultipleLists(b *testing.B) {
 	clearMemory()
-	server.Memory["list1"] = shared.MemoryEntry{
+	server.Memory.Set("list1", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c"},
 		Expires: 0,
-	}
-	server.Memory["list2"] = shared.MemoryEntry{
+	})
+	server.Memory.Set("list2", shared.MemoryEntry{
 		Array:   []string{"d", "e", "f"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -342,7 +426,7 @@ func BenchmarkBlpopMultipleLists(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Blpop(connID, args)
+		Blpop(network.ClientOf(connID), args)
 	}
 }
 
@@ -357,16 +441,16 @@ func BenchmarkBlpopNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Blpop(connID, args)
+		Blpop(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkBlpopEmpty(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -376,6 +460,6 @@ func BenchmarkBlpopEmpty(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Blpop(connID, args)
+		Blpop(network.ClientOf(connID), args)
 	}
 }
@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Client handles the CLIENT command
+// Usage: CLIENT SETNAME name | CLIENT GETNAME | CLIENT ID | CLIENT INFO | CLIENT LIST |
+// CLIENT KILL addr | CLIENT KILL [ID id] [ADDR addr] [LADDR laddr] [TYPE type] [SKIPME yes|no] |
+// CLIENT NO-EVICT ON|OFF | CLIENT NO-TOUCH ON|OFF
+// Returns: The result of the subcommand, or error if the subcommand is unknown.
+//
+// NO-EVICT and NO-TOUCH are accepted and do nothing. In Redis they exempt the connection
+// from client eviction and keep its reads from updating the access time of keys. This server
+// neither evicts clients nor tracks access times, so there is no effect to turn off: the
+// tools sending them, like backups scanning the whole dataset, get OK and no flag is set.
+//
+// Examples:
+//
+//	CLIENT SETNAME worker-1    // Names the connection worker-1
+//	CLIENT SETNAME ""          // Removes the name of the connection
+//	CLIENT GETNAME             // Returns worker-1, or null when no name is set
+//	CLIENT LIST                // Returns a line describing each connected client
+//	CLIENT KILL TYPE pubsub    // Disconnects every subscriber and returns how many there were
+//	CLIENT NO-TOUCH ON         // Returns OK and changes nothing
+func Client(client *network.Client, args []shared.Value) shared.Value {
+	subcommand := strings.ToUpper(args[0].Bulk)
+
+	switch subcommand {
+	case "SETNAME":
+		return clientSetname(client, args[1:])
+	case "GETNAME":
+		return clientGetname(client, args[1:])
+	case "ID":
+		return clientID(client, args[1:])
+	case "INFO":
+		return clientInfo(client, args[1:])
+	case "LIST":
+		return clientList(args[1:])
+	case "KILL":
+		return clientKill(client, args[1:])
+	case "NO-EVICT", "NO-TOUCH":
+		return clientNoOpFlag(args[1:])
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'client' command")
+	}
+}
+
+// clientSetname handles the CLIENT SETNAME subcommand, an empty name removes the current one
+func clientSetname(client *network.Client, args []shared.Value) shared.Value {
+	name := args[0].Bulk
+	if !isValidClientName(name) {
+		return createErrorResponse("ERR Client names cannot contain spaces, newlines or special characters.")
+	}
+
+	client.UpdateInfo(func(info *shared.ClientInfo) {
+		info.Name = name
+	})
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// clientNoOpFlag handles NO-EVICT and NO-TOUCH, checking their ON or OFF argument only
+func clientNoOpFlag(args []shared.Value) shared.Value {
+	switch strings.ToUpper(args[0].Bulk) {
+	case "ON", "OFF":
+		return shared.Value{Typ: "string", Str: "OK"}
+	default:
+		return shared.ErrSyntax()
+	}
+}
+
+// clientGetname handles the CLIENT GETNAME subcommand
+func clientGetname(client *network.Client, args []shared.Value) shared.Value {
+	info, _ := client.Info()
+	if info.Name == "" {
+		return shared.Null()
+	}
+	return shared.Value{Typ: "bulk", Bulk: info.Name}
+}
+
+// clientID handles the CLIENT ID subcommand
+func clientID(client *network.Client, args []shared.Value) shared.Value {
+	info, _ := client.Info()
+	return shared.Value{Typ: "integer", Num: int(info.ID)}
+}
+
+// clientInfo handles the CLIENT INFO subcommand, describing the calling connection
+func clientInfo(client *network.Client, args []shared.Value) shared.Value {
+	info, _ := client.Info()
+	return shared.Value{Typ: "bulk", Bulk: formatClientInfo(client.ConnID, info)}
+}
+
+// clientList handles the CLIENT LIST subcommand
+func clientList(args []shared.Value) shared.Value {
+	if len(args) != 0 {
+		return shared.ErrSyntax()
+	}
+
+	var list strings.Builder
+	for _, clientConnID := range network.ClientIDs() {
+		if info, exists := network.ClientInfoGet(clientConnID); exists {
+			list.WriteString(formatClientInfo(clientConnID, info))
+		}
+	}
+	return shared.Value{Typ: "bulk", Bulk: list.String()}
+}
+
+// clientKillFilter selects the clients CLIENT KILL disconnects, unset fields match every client
+type clientKillFilter struct {
+	id         int64  // Client ID, 0 when unset
+	addr       string // Address the client connected from
+	laddr      string // Address of the server the client connected to
+	clientType string // normal, master, replica or pubsub
+	skipMe     bool   // Whether the calling client is left connected
+}
+
+// clientKill handles the CLIENT KILL subcommand. The old form, with a single address,
+// returns OK or an error when no client matches. The filter form returns the number of
+// killed clients, and leaves the caller connected unless SKIPME no is given.
+func clientKill(client *network.Client, args []shared.Value) shared.Value {
+	if len(args) == 1 {
+		filter := clientKillFilter{addr: args[0].Bulk}
+		if killClients(client.ConnID, filter) == 0 {
+			return createErrorResponse("ERR No such client")
+		}
+		return shared.Value{Typ: "string", Str: "OK"}
+	}
+
+	if len(args)%2 != 0 {
+		return shared.ErrSyntax()
+	}
+	filter := clientKillFilter{skipMe: true}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1].Bulk
+		switch strings.ToUpper(args[i].Bulk) {
+		case "ID":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return createErrorResponse("ERR client-id should be greater than 0")
+			}
+			filter.id = id
+		case "ADDR":
+			filter.addr = value
+		case "LADDR":
+			filter.laddr = value
+		case "TYPE":
+			clientType := strings.ToLower(value)
+			switch clientType {
+			case "normal", "master", "replica", "pubsub":
+			case "slave":
+				clientType = "replica"
+			default:
+				return createErrorResponse(fmt.Sprintf("ERR Unknown client type '%s'", value))
+			}
+			filter.clientType = clientType
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				filter.skipMe = true
+			case "no":
+				filter.skipMe = false
+			default:
+				return shared.ErrSyntax()
+			}
+		default:
+			return shared.ErrSyntax()
+		}
+	}
+
+	return shared.Value{Typ: "integer", Num: killClients(client.ConnID, filter)}
+}
+
+// killClients disconnects the clients matching filter and returns how many there were.
+// Their blocking commands stop waiting, and their subscriptions and transaction are dropped
+// once the connection is closed. The caller itself is disconnected after getting the reply.
+func killClients(connID string, filter clientKillFilter) int {
+	killed := 0
+	for _, clientConnID := range network.ClientIDs() {
+		info, exists := network.ClientInfoGet(clientConnID)
+		if !exists || info.Killed {
+			continue
+		}
+		if filter.skipMe && clientConnID == connID {
+			continue
+		}
+		if (filter.id != 0 && info.ID != filter.id) ||
+			(filter.addr != "" && info.Addr != filter.addr) ||
+			(filter.laddr != "" && info.LocalAddr != filter.laddr) ||
+			(filter.clientType != "" && clientType(clientConnID) != filter.clientType) {
+			continue
+		}
+
+		network.ClientKill(clientConnID, clientConnID == connID)
+		killed++
+	}
+	return killed
+}
+
+// clientType returns the type of a client used by CLIENT KILL TYPE
+func clientType(connID string) string {
+	if connID == network.MasterLinkID() {
+		return "master"
+	}
+	if _, isReplica := network.ReplicasGet(connID); isReplica {
+		return "replica"
+	}
+	if network.SubscribedModeGet(connID) {
+		return "pubsub"
+	}
+	return "normal"
+}
+
+// formatClientInfo returns the line describing a client in CLIENT LIST, like
+// id=3 addr=127.0.0.1:51234 laddr=127.0.0.1:6379 name=worker age=5 idle=0 flags=N db=0 sub=1 psub=0 multi=-1 cmd=client user=default resp=2
+func formatClientInfo(connID string, info shared.ClientInfo) string {
+	now := time.Now().UnixMilli()
+
+	// Flags: S for a replica, O for a monitor, P for a subscriber, b while blocked, x inside MULTI,
+	// r for a READONLY cluster client, N for none
+	flags := ""
+	if _, isReplica := network.ReplicasGet(connID); isReplica {
+		flags += "S"
+	}
+	if network.IsMonitor(connID) {
+		flags += "O"
+	}
+	if network.SubscribedModeGet(connID) {
+		flags += "P"
+	}
+	if info.Blocked {
+		flags += "b"
+	}
+	multi := -1
+	if transaction, inMulti := network.TransactionsGet(connID); inMulti {
+		flags += "x"
+		multi = len(transaction.Commands)
+	}
+	if info.ReadOnly {
+		flags += "r"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+
+	channels, _ := network.SubscriptionsGet(connID)
+	cmd := info.LastCommand
+	if cmd == "" {
+		cmd = "NULL"
+	}
+
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=0 sub=%d psub=0 multi=%d omem=%d cmd=%s user=%s resp=%d\n",
+		info.ID, info.Addr, info.LocalAddr, info.Name, (now-info.CreatedAt)/1000, (now-info.LastInteraction)/1000,
+		flags, len(channels), multi, network.OutputBufferPending(connID), cmd, info.User, info.Protocol)
+}
+
+// isValidClientName reports whether name only holds printable characters other than spaces
+func isValidClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,523 @@
+package commands
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// registerTestClient registers a client connection and returns a function removing it
+func registerTestClient(t testing.TB, connID string) func() {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	network.ConnectionsSet(connID, serverConn)
+	network.ClientInfoRegister(connID, serverConn)
+	return func() {
+		network.ClientInfoDelete(connID)
+		network.ConnectionsDelete(connID)
+		serverConn.Close()
+		clientConn.Close()
+	}
+}
+
+func TestClientSetnameGetname(t *testing.T) {
+	defer registerTestClient(t, "client-conn")()
+
+	tests := []struct {
+		name     string
+		args     []shared.Value
+		expected shared.Value
+	}{
+		{
+			name:     "GETNAME without a name",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "GETNAME"}},
+			expected: shared.Null(),
+		},
+		{
+			name:     "SETNAME",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "SETNAME"}, {Typ: "bulk", Bulk: "worker-1"}},
+			expected: shared.Value{Typ: "string", Str: "OK"},
+		},
+		{
+			name:     "GETNAME after SETNAME",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "getname"}},
+			expected: shared.Value{Typ: "bulk", Bulk: "worker-1"},
+		},
+		{
+			name:     "SETNAME with a space",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "SETNAME"}, {Typ: "bulk", Bulk: "my worker"}},
+			expected: shared.Value{Typ: "error", Str: "ERR Client names cannot contain spaces, newlines or special characters."},
+		},
+		{
+			name:     "SETNAME with a newline",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "SETNAME"}, {Typ: "bulk", Bulk: "worker\n"}},
+			expected: shared.Value{Typ: "error", Str: "ERR Client names cannot contain spaces, newlines or special characters."},
+		},
+		{
+			name:     "Invalid names keep the current one",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "GETNAME"}},
+			expected: shared.Value{Typ: "bulk", Bulk: "worker-1"},
+		},
+		{
+			name:     "SETNAME with an empty name removes it",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "SETNAME"}, {Typ: "bulk", Bulk: ""}},
+			expected: shared.Value{Typ: "string", Str: "OK"},
+		},
+		{
+			name:     "GETNAME after removing the name",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "GETNAME"}},
+			expected: shared.Null(),
+		},
+		{
+			name:     "SETNAME without a name",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "SETNAME"}},
+			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'client|setname' command"},
+		},
+		{
+			name:     "Unknown subcommand",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "UNKNOWN"}},
+			expected: shared.Value{Typ: "error", Str: "ERR unknown subcommand for 'client' command"},
+		},
+		{
+			name:     "No subcommand",
+			args:     []shared.Value{},
+			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'client' command"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("CLIENT", Client, "client-conn", tt.args)
+			if result.Typ != tt.expected.Typ || result.Str != tt.expected.Str || result.Bulk != tt.expected.Bulk {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestClientList(t *testing.T) {
+	defer registerTestClient(t, "client-a")()
+	defer registerTestClient(t, "client-b")()
+	initCommandHandlers()
+
+	Client(network.ClientOf("client-a"), []shared.Value{{Typ: "bulk", Bulk: "SETNAME"}, {Typ: "bulk", Bulk: "first"}})
+	network.ExecuteCommand("PING", "client-b", []shared.Value{})
+
+	result := Client(network.ClientOf("client-a"), []shared.Value{{Typ: "bulk", Bulk: "LIST"}})
+	if result.Typ != "bulk" {
+		t.Fatalf("Expected bulk type, got %s", result.Typ)
+	}
+	lines := strings.Split(strings.TrimSuffix(result.Bulk, "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("Expected a line per client, got %q", result.Bulk)
+	}
+
+	a, _ := network.ClientInfoGet("client-a")
+	b, _ := network.ClientInfoGet("client-b")
+	if a.ID >= b.ID {
+		t.Errorf("Expected client IDs to increase, got %d and %d", a.ID, b.ID)
+	}
+	found := 0
+	for _, line := range lines {
+		if strings.Contains(line, " name=first ") && strings.Contains(line, " flags=N ") {
+			found++
+		}
+		if strings.Contains(line, " name= ") && strings.Contains(line, " cmd=ping ") {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("Expected both clients to be listed with their name and last command, got %q", result.Bulk)
+	}
+
+	info := Client(network.ClientOf("client-a"), []shared.Value{{Typ: "bulk", Bulk: "INFO"}})
+	if !strings.HasPrefix(info.Bulk, "id=") || !strings.Contains(info.Bulk, " name=first ") {
+		t.Errorf("Expected CLIENT INFO to describe the caller, got %q", info.Bulk)
+	}
+
+	id := Client(network.ClientOf("client-b"), []shared.Value{{Typ: "bulk", Bulk: "ID"}})
+	if id.Typ != "integer" || int64(id.Num) != b.ID {
+		t.Errorf("Expected CLIENT ID %d, got %v", b.ID, id)
+	}
+}
+
+func TestClientNoEvictNoTouch(t *testing.T) {
+	defer registerTestClient(t, "client-conn")()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected shared.Value
+		flags    string
+	}{
+		// The flags are accepted and have no effect, so none is reported
+		{"NO-EVICT ON", []string{"NO-EVICT", "on"}, shared.Value{Typ: "string", Str: "OK"}, "flags=N "},
+		{"NO-TOUCH ON", []string{"NO-TOUCH", "ON"}, shared.Value{Typ: "string", Str: "OK"}, "flags=N "},
+		{"NO-EVICT OFF", []string{"NO-EVICT", "OFF"}, shared.Value{Typ: "string", Str: "OK"}, "flags=N "},
+		{"NO-TOUCH OFF", []string{"no-touch", "off"}, shared.Value{Typ: "string", Str: "OK"}, "flags=N "},
+		{"Invalid value", []string{"NO-TOUCH", "maybe"}, shared.ErrSyntax(), "flags=N "},
+		{"Missing value", []string{"NO-EVICT"}, shared.ErrWrongArity("client|no-evict"), "flags=N "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("CLIENT", Client, "client-conn", evalArgs(tt.args...))
+			if result.Typ != tt.expected.Typ || result.Str != tt.expected.Str {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+			if info := Client(network.ClientOf("client-conn"), evalArgs("INFO")); !strings.Contains(info.Bulk, " "+tt.flags) {
+				t.Errorf("Expected %q in CLIENT INFO, got %q", tt.flags, info.Bulk)
+			}
+		})
+	}
+}
+
+func TestClientKill(t *testing.T) {
+	defer registerTestClient(t, "caller")()
+	defer registerTestClient(t, "normal-conn")()
+	defer registerTestClient(t, "subscriber")()
+	defer network.SubscribedModeDelete("subscriber")
+	network.SubscribedModeSet("subscriber")
+
+	kill := func(connID string, args ...string) shared.Value {
+		values := []shared.Value{{Typ: "bulk", Bulk: "KILL"}}
+		for _, arg := range args {
+			values = append(values, shared.Value{Typ: "bulk", Bulk: arg})
+		}
+		return Client(network.ClientOf(connID), values)
+	}
+	idOf := func(connID string) string {
+		info, _ := network.ClientInfoGet(connID)
+		return strconv.FormatInt(info.ID, 10)
+	}
+
+	errors := []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"127.0.0.1:1"}, expected: "ERR No such client"},
+		{args: []string{"ID", "0"}, expected: "ERR client-id should be greater than 0"},
+		{args: []string{"ID", "abc"}, expected: "ERR client-id should be greater than 0"},
+		{args: []string{"TYPE", "unknown"}, expected: "ERR Unknown client type 'unknown'"},
+		{args: []string{"SKIPME", "maybe"}, expected: "ERR syntax error"},
+		{args: []string{"UNKNOWN", "1"}, expected: "ERR syntax error"},
+		{args: []string{"ID", "1", "ADDR"}, expected: "ERR syntax error"},
+	}
+	for _, tt := range errors {
+		if result := kill("caller", tt.args...); result.Typ != "error" || result.Str != tt.expected {
+			t.Errorf("CLIENT KILL %v: expected %q, got %v", tt.args, tt.expected, result)
+		}
+	}
+
+	// Filters that match nothing kill nobody
+	if result := kill("caller", "ID", "999999"); result.Typ != "integer" || result.Num != 0 {
+		t.Errorf("Expected no client to be killed, got %v", result)
+	}
+	if result := kill("caller", "ID", idOf("normal-conn"), "TYPE", "pubsub"); result.Num != 0 {
+		t.Errorf("Expected filters to all have to match, got %v", result)
+	}
+
+	// TYPE pubsub only kills subscribers
+	if result := kill("caller", "TYPE", "pubsub"); result.Typ != "integer" || result.Num != 1 {
+		t.Errorf("Expected 1 subscriber to be killed, got %v", result)
+	}
+	if !network.ClientKilled("subscriber") || network.ClientKilled("normal-conn") {
+		t.Errorf("Expected only the subscriber to be killed")
+	}
+
+	// The caller is skipped by default
+	if result := kill("caller", "TYPE", "normal"); result.Num != 1 {
+		t.Errorf("Expected 1 normal client to be killed, got %v", result)
+	}
+	if network.ClientKilled("caller") {
+		t.Errorf("Expected the caller to be skipped")
+	}
+
+	// With SKIPME no, the caller is killed but its connection stays open for the reply
+	if result := kill("caller", "ID", idOf("caller"), "SKIPME", "no"); result.Num != 1 {
+		t.Errorf("Expected the caller to be killed, got %v", result)
+	}
+	if !network.ClientKilled("caller") {
+		t.Errorf("Expected the caller to be marked as killed")
+	}
+	if _, ok := network.ConnectionsGet("caller"); !ok {
+		t.Errorf("Expected the caller's connection to stay registered until the reply is written")
+	}
+}
+
+func TestClientKillClosesConnection(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	network.ConnectionsSet("target", serverConn)
+	network.ClientInfoRegister("target", serverConn)
+	defer network.ConnectionsDelete("target")
+	defer network.ClientInfoDelete("target")
+	defer registerTestClient(t, "caller")()
+
+	info, _ := network.ClientInfoGet("target")
+	result := Client(network.ClientOf("caller"), []shared.Value{{Typ: "bulk", Bulk: "KILL"}, {Typ: "bulk", Bulk: "ID"}, {Typ: "bulk", Bulk: strconv.FormatInt(info.ID, 10)}})
+	if result.Typ != "integer" || result.Num != 1 {
+		t.Fatalf("Expected 1 client to be killed, got %v", result)
+	}
+
+	clientConn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := clientConn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestClientKillStopsBlockedWait(t *testing.T) {
+	clearMemory()
+	defer registerTestClient(t, "blocked-conn")()
+	defer registerTestClient(t, "caller")()
+
+	done := make(chan shared.Value)
+	go func() {
+		done <- Blpop(network.ClientOf("blocked-conn"), []shared.Value{{Typ: "bulk", Bulk: "queue"}, {Typ: "bulk", Bulk: "0"}})
+	}()
+	time.Sleep(30 * time.Millisecond)
+
+	info, _ := network.ClientInfoGet("blocked-conn")
+	Client(network.ClientOf("caller"), []shared.Value{{Typ: "bulk", Bulk: "KILL"}, {Typ: "bulk", Bulk: "ID"}, {Typ: "bulk", Bulk: strconv.FormatInt(info.ID, 10)}})
+
+	select {
+	case result := <-done:
+		if !result.IsNull() || !result.NullArray {
+			t.Errorf("Expected the blocked BLPOP to give up, got %v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the blocked BLPOP to stop waiting")
+	}
+}
+
+// BenchmarkClientList benchmarks the CLIENT LIST command
+// remoteConn is a connection whose peer has a given address
+type remoteConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c remoteConn) RemoteAddr() net.Addr { return c.addr }
+
+func TestProtectedMode(t *testing.T) {
+	defer resetACLUsers()
+	defer server.SetStoreState(shared.State{})
+	initCommandHandlers()
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	network.ClientInfoRegister("remote-conn", remoteConn{serverConn, &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 50000}})
+	defer network.ClientInfoDelete("remote-conn")
+	network.ClientInfoRegister("local-conn", remoteConn{serverConn, &net.TCPAddr{IP: net.IPv6loopback, Port: 50001}})
+	defer network.ClientInfoDelete("local-conn")
+
+	tests := []struct {
+		name     string
+		state    shared.State
+		password string
+		connID   string
+		denied   bool
+	}{
+		{name: "Remote client", state: shared.State{ProtectedMode: true}, connID: "remote-conn", denied: true},
+		{name: "Loopback client", state: shared.State{ProtectedMode: true}, connID: "local-conn"},
+		{name: "Protected mode disabled", state: shared.State{}, connID: "remote-conn"},
+		{name: "Bind address set", state: shared.State{ProtectedMode: true, Bind: "0.0.0.0"}, connID: "remote-conn"},
+		{name: "Password set", state: shared.State{ProtectedMode: true}, password: "secret", connID: "remote-conn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.SetStoreState(tt.state)
+			network.ACLSetDefaultPassword(tt.password)
+
+			result := network.ExecuteCommand("PING", tt.connID, nil)
+			denied := result.Typ == "error" && strings.HasPrefix(result.Str, "DENIED Redis is running in protected mode")
+			if denied != tt.denied {
+				t.Errorf("Expected denied %v, got %v", tt.denied, result)
+			}
+		})
+	}
+}
+
+func TestBindAddresses(t *testing.T) {
+	addresses, optional := network.BindAddresses("127.0.0.1 -::1 * ::*", "6379")
+	expected := []string{"127.0.0.1:6379", "[::1]:6379", "0.0.0.0:6379", "[::]:6379"}
+	if strings.Join(addresses, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, addresses)
+	}
+	if optional[0] || !optional[1] || optional[2] || optional[3] {
+		t.Errorf("Expected only ::1 to be optional, got %v", optional)
+	}
+
+	if addresses, _ := network.BindAddresses("", "6379"); len(addresses) != 1 || addresses[0] != "0.0.0.0:6379" {
+		t.Errorf("Expected every address without bind, got %v", addresses)
+	}
+}
+
+func TestCloseIdleClients(t *testing.T) {
+	defer server.SetStoreState(shared.State{})
+	for _, connID := range []string{"idle-conn", "active-conn", "blocked-conn", "subscribed-conn"} {
+		defer registerTestClient(t, connID)()
+	}
+	defer network.SubscribedModeDelete("subscribed-conn")
+
+	now := time.Now()
+	idleSince := now.Add(-time.Minute).UnixMilli()
+	for _, connID := range []string{"idle-conn", "blocked-conn", "subscribed-conn"} {
+		network.ClientInfoUpdate(connID, func(info *shared.ClientInfo) { info.LastInteraction = idleSince })
+	}
+	network.ClientInfoUpdate("blocked-conn", func(info *shared.ClientInfo) { info.Blocked = true })
+	network.SubscribedModeSet("subscribed-conn")
+
+	// Without a timeout no client is idle
+	server.SetStoreState(shared.State{Timeout: 0})
+	if closed := network.CloseIdleClients(now); closed != 0 {
+		t.Errorf("Expected no client closed without timeout, got %d", closed)
+	}
+
+	server.SetStoreState(shared.State{Timeout: 30})
+	if closed := network.CloseIdleClients(now); closed != 1 {
+		t.Errorf("Expected 1 client closed, got %d", closed)
+	}
+	for connID, killed := range map[string]bool{"idle-conn": true, "active-conn": false, "blocked-conn": false, "subscribed-conn": false} {
+		if network.ClientKilled(connID) != killed {
+			t.Errorf("Expected %s killed %v", connID, killed)
+		}
+	}
+}
+
+func TestClientOutputBufferLimit(t *testing.T) {
+	defer server.SetStoreState(shared.State{})
+	server.SetStoreState(shared.State{ClientOutputBufferLimits: map[string]shared.OutputBufferLimit{
+		"normal": {Hard: 64},
+		"pubsub": {Soft: 16, SoftSeconds: 1},
+	}})
+	register := func(connID string) (net.Conn, net.Conn) {
+		serverConn, clientConn := net.Pipe()
+		network.ClientRegister(connID, network.TrackOutputBuffer(connID, serverConn))
+		t.Cleanup(func() {
+			network.ClientUnregister(connID)
+			clientConn.Close()
+		})
+		conn, _ := network.ConnectionsGet(connID)
+		return conn, clientConn
+	}
+	disconnections := server.OutputBufferLimitDisconnections()
+
+	// Replies the client reads don't pile up, a reply reaching the hard limit disconnects it
+	conn, clientConn := register("normal-conn")
+	go io.ReadFull(clientConn, make([]byte, 32))
+	if _, err := conn.Write(make([]byte, 32)); err != nil {
+		t.Fatalf("Expected the reply to be written, got %v", err)
+	}
+	if _, err := conn.Write(make([]byte, 64)); err == nil {
+		t.Error("Expected the reply over the hard limit to fail")
+	}
+	if !network.ClientKilled("normal-conn") {
+		t.Error("Expected the client over the hard limit to be killed")
+	}
+
+	// A subscriber not reading its messages may stay over the soft limit for the time allowed
+	network.SubscribedModeSet("subscriber-conn")
+	defer network.SubscribedModeDelete("subscriber-conn")
+	conn, _ = register("subscriber-conn")
+	if _, err := conn.Write(make([]byte, 20)); err != nil {
+		t.Fatalf("Expected the message to be queued, got %v", err)
+	}
+	info, _ := network.ClientInfoGet("subscriber-conn")
+	if line := formatClientInfo("subscriber-conn", info); !strings.Contains(line, " omem=20 ") {
+		t.Errorf("Expected omem=20 in %q", line)
+	}
+	if closed := network.CloseOutputBufferOffenders(time.Now()); closed != 0 {
+		t.Errorf("Expected no client closed before the soft limit time passed, got %d", closed)
+	}
+	if closed := network.CloseOutputBufferOffenders(time.Now().Add(2 * time.Second)); closed != 1 {
+		t.Errorf("Expected the subscriber to be closed, got %d", closed)
+	}
+	if _, err := conn.Write(make([]byte, 1)); err == nil {
+		t.Error("Expected writes to fail once the subscriber is closed")
+	}
+
+	if got := server.OutputBufferLimitDisconnections() - disconnections; got != 2 {
+		t.Errorf("Expected 2 disconnections counted, got %d", got)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	defer server.SetStoreState(shared.State{})
+	defer network.ResetRateLimits()
+	server.SetStoreState(shared.State{ClientRateLimitCommands: 3, ClientRateLimitBytes: 20})
+	network.ResetRateLimits()
+	client := func(connID string) *network.Client {
+		serverConn, clientConn := net.Pipe()
+		t.Cleanup(func() {
+			network.ClientUnregister(connID)
+			clientConn.Close()
+		})
+		return network.ClientRegister(connID, serverConn)
+	}
+	args := func(values ...string) []shared.Value {
+		args := make([]shared.Value, len(values))
+		for i, value := range values {
+			args[i] = shared.Value{Typ: "bulk", Bulk: value}
+		}
+		return args
+	}
+	limited := server.RateLimitedCommands()
+
+	// A client may send a second worth of commands at once
+	fast := client("fast-conn")
+	for i := 0; i < 3; i++ {
+		if err := fast.RateLimited("PING", nil); err != "" {
+			t.Fatalf("Expected command %d to be allowed, got %q", i, err)
+		}
+	}
+	if err := fast.RateLimited("PING", nil); err != "ERR rate limit exceeded, this client may send 3 commands per second" {
+		t.Errorf("Expected the fourth command to be refused, got %q", err)
+	}
+
+	// Bytes of arguments are counted apart, other clients are not affected
+	big := client("big-conn")
+	if err := big.RateLimited("SET", args("key", "0123456789")); err != "" {
+		t.Errorf("Expected 16 bytes to be allowed, got %q", err)
+	}
+	if err := big.RateLimited("SET", args("key", "0123456789")); err != "ERR rate limit exceeded, this client may send 20 bytes per second" {
+		t.Errorf("Expected 32 bytes to be refused, got %q", err)
+	}
+
+	// The server-wide limit refuses every client once reached
+	server.StoreState.ClientRateLimitCommands, server.StoreState.ClientRateLimitBytes = 0, 0
+	server.StoreState.RateLimitCommands = 2
+	network.ResetRateLimits()
+	other := client("other-conn")
+	for _, c := range []*network.Client{fast, big} {
+		if err := c.RateLimited("PING", nil); err != "" {
+			t.Errorf("Expected the command to be allowed, got %q", err)
+		}
+	}
+	if err := other.RateLimited("PING", nil); err != "BUSY the server is over its rate limit, try again later" {
+		t.Errorf("Expected BUSY, got %q", err)
+	}
+
+	if got := server.RateLimitedCommands() - limited; got != 3 {
+		t.Errorf("Expected 3 refused commands counted, got %d", got)
+	}
+}
+
+func BenchmarkClientList(b *testing.B) {
+	defer registerTestClient(b, "bench-conn")()
+	args := []shared.Value{{Typ: "bulk", Bulk: "LIST"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Client(network.ClientOf("bench-conn"), args)
+	}
+}
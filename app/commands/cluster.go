@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Cluster handles the CLUSTER command
+// Usage: CLUSTER INFO | CLUSTER MYID | CLUSTER SLOTS | CLUSTER SHARDS | CLUSTER KEYSLOT key |
+// CLUSTER MEET ip port | CLUSTER SETSLOT slot IMPORTING|MIGRATING|NODE node-id |
+// CLUSTER SETSLOT slot STABLE | CLUSTER GETKEYSINSLOT slot count | CLUSTER COUNTKEYSINSLOT slot
+// Returns: The result of the subcommand, or an error when cluster mode is disabled.
+//
+// A slot moves from node A to node B by setting it IMPORTING from A on B and MIGRATING to B
+// on A, moving the keys GETKEYSINSLOT lists on A, then assigning it to B with SETSLOT NODE on
+// both nodes.
+//
+// Examples:
+//
+//	CLUSTER INFO                     // Returns cluster_state:ok, the assigned slots and the known nodes
+//	CLUSTER MYID                     // Returns the 40 characters ID of this node
+//	CLUSTER SLOTS                    // Returns each range of slots with the node serving it
+//	CLUSTER SHARDS                   // Returns each shard with its slots and its nodes
+//	CLUSTER KEYSLOT mykey            // Returns 14687
+//	CLUSTER MEET 127.0.0.1 7001      // Adds the node listening on port 7001
+//	CLUSTER SETSLOT 14687 NODE <id>  // Assigns slot 14687 to the node <id>
+//	CLUSTER GETKEYSINSLOT 14687 10   // Returns up to 10 keys of slot 14687
+func Cluster(client *network.Client, args []shared.Value) shared.Value {
+	if !network.ClusterEnabled() {
+		return createErrorResponse("ERR This instance has cluster support disabled")
+	}
+
+	switch strings.ToUpper(args[0].Bulk) {
+	case "INFO":
+		return clusterInfoCommand(args[1:])
+	case "MYID":
+		return clusterMyID(args[1:])
+	case "SLOTS":
+		return clusterSlots(client.ConnID, args[1:])
+	case "SHARDS":
+		return clusterShards(client.ConnID, args[1:])
+	case "KEYSLOT":
+		return clusterKeyslot(args[1:])
+	case "MEET":
+		return clusterMeet(args[1:])
+	case "SETSLOT":
+		return clusterSetslot(args[1:])
+	case "GETKEYSINSLOT":
+		return clusterGetkeysinslot(args[1:])
+	case "COUNTKEYSINSLOT":
+		return clusterCountkeysinslot(args[1:])
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'cluster' command")
+	}
+}
+
+// clusterInfoCommand handles the CLUSTER INFO subcommand. There is no failure detection, so
+// the state is ok as long as every slot is assigned.
+func clusterInfoCommand(args []shared.Value) shared.Value {
+	assigned := 0
+	servingNodes := make(map[string]bool)
+	for _, slotRange := range network.ClusterSlotRanges() {
+		assigned += slotRange.End - slotRange.Start + 1
+		servingNodes[slotRange.Node.ID] = true
+	}
+	state := "ok"
+	if assigned < network.ClusterSlots {
+		state = "fail"
+	}
+
+	info := "cluster_state:" + state + "\r\n"
+	info += "cluster_slots_assigned:" + strconv.Itoa(assigned) + "\r\n"
+	info += "cluster_slots_ok:" + strconv.Itoa(assigned) + "\r\n"
+	info += "cluster_slots_pfail:0\r\n"
+	info += "cluster_slots_fail:0\r\n"
+	info += "cluster_known_nodes:" + strconv.Itoa(network.ClusterKnownNodes()) + "\r\n"
+	info += "cluster_size:" + strconv.Itoa(len(servingNodes)) + "\r\n"
+	info += "cluster_current_epoch:0\r\n"
+	info += "cluster_my_epoch:0\r\n"
+	return shared.Value{Typ: "verbatim", Str: "txt", Bulk: info}
+}
+
+// clusterMyID handles the CLUSTER MYID subcommand
+func clusterMyID(args []shared.Value) shared.Value {
+	myself, _ := network.ClusterMyself()
+	return shared.Value{Typ: "bulk", Bulk: myself.ID}
+}
+
+// clusterSlots handles the CLUSTER SLOTS subcommand, replying each range of slots with the
+// address and ID of the node serving it
+func clusterSlots(connID string, args []shared.Value) shared.Value {
+	ranges := network.ClusterSlotRanges()
+	result := make([]shared.Value, 0, len(ranges))
+	for _, slotRange := range ranges {
+		port, _ := strconv.Atoi(slotRange.Node.Port)
+		result = append(result, shared.Value{Typ: "array", Array: []shared.Value{
+			{Typ: "integer", Num: slotRange.Start},
+			{Typ: "integer", Num: slotRange.End},
+			{Typ: "array", Array: []shared.Value{
+				{Typ: "bulk", Bulk: network.ClusterNodeIP(connID, slotRange.Node)},
+				{Typ: "integer", Num: port},
+				{Typ: "bulk", Bulk: slotRange.Node.ID},
+				{Typ: "map", Array: []shared.Value{}},
+			}},
+		}})
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
+
+// clusterShards handles the CLUSTER SHARDS subcommand. Every node is a master of its own
+// shard, replying its slots as start and end pairs and a description of the node.
+func clusterShards(connID string, args []shared.Value) shared.Value {
+	// Slots are grouped by node, nodes serving no slot still have a shard
+	var nodes []network.ClusterNode
+	slots := make(map[string][]shared.Value)
+	for _, slotRange := range network.ClusterSlotRanges() {
+		if _, ok := slots[slotRange.Node.ID]; !ok {
+			nodes = append(nodes, slotRange.Node)
+		}
+		slots[slotRange.Node.ID] = append(slots[slotRange.Node.ID],
+			shared.Value{Typ: "integer", Num: slotRange.Start}, shared.Value{Typ: "integer", Num: slotRange.End})
+	}
+	if myself, ok := network.ClusterMyself(); ok && slots[myself.ID] == nil {
+		nodes = append(nodes, myself)
+	}
+
+	result := make([]shared.Value, 0, len(nodes))
+	for _, node := range nodes {
+		port, _ := strconv.Atoi(node.Port)
+		ip := network.ClusterNodeIP(connID, node)
+		description := shared.Value{Typ: "map", Array: []shared.Value{
+			{Typ: "bulk", Bulk: "id"}, {Typ: "bulk", Bulk: node.ID},
+			{Typ: "bulk", Bulk: "port"}, {Typ: "integer", Num: port},
+			{Typ: "bulk", Bulk: "ip"}, {Typ: "bulk", Bulk: ip},
+			{Typ: "bulk", Bulk: "endpoint"}, {Typ: "bulk", Bulk: ip},
+			{Typ: "bulk", Bulk: "role"}, {Typ: "bulk", Bulk: "master"},
+			{Typ: "bulk", Bulk: "replication-offset"}, {Typ: "integer", Num: int(server.StoreState.MasterReplOffset)},
+			{Typ: "bulk", Bulk: "health"}, {Typ: "bulk", Bulk: "online"},
+		}}
+		result = append(result, shared.Value{Typ: "map", Array: []shared.Value{
+			{Typ: "bulk", Bulk: "slots"}, {Typ: "array", Array: append([]shared.Value{}, slots[node.ID]...)},
+			{Typ: "bulk", Bulk: "nodes"}, {Typ: "array", Array: []shared.Value{description}},
+		}})
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
+
+// clusterKeyslot handles the CLUSTER KEYSLOT subcommand
+func clusterKeyslot(args []shared.Value) shared.Value {
+	return shared.Value{Typ: "integer", Num: network.KeyHashSlot(args[0].Bulk)}
+}
+
+// clusterMeet handles the CLUSTER MEET subcommand
+func clusterMeet(args []shared.Value) shared.Value {
+	if port, err := strconv.Atoi(args[1].Bulk); err != nil || port < 1 || port > 65535 {
+		return createErrorResponse("ERR Invalid base port specified: " + args[1].Bulk)
+	}
+	if _, err := network.ClusterMeet(args[0].Bulk, args[1].Bulk); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// clusterSetslot handles the CLUSTER SETSLOT subcommand
+func clusterSetslot(args []shared.Value) shared.Value {
+	slot, err := network.ParseClusterSlot(args[0].Bulk)
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+
+	state := strings.ToUpper(args[1].Bulk)
+	if state == "STABLE" {
+		if len(args) != 2 {
+			return shared.ErrSyntax()
+		}
+		network.ClusterSetSlotStable(slot)
+		return shared.Value{Typ: "string", Str: "OK"}
+	}
+	if len(args) != 3 {
+		return shared.ErrSyntax()
+	}
+	nodeID := args[2].Bulk
+	switch state {
+	case "IMPORTING":
+		err = network.ClusterSetSlotImporting(slot, nodeID)
+	case "MIGRATING":
+		err = network.ClusterSetSlotMigrating(slot, nodeID)
+	case "NODE":
+		err = network.ClusterSetSlotNode(slot, nodeID)
+	default:
+		return createErrorResponse("ERR Invalid CLUSTER SETSLOT action or number of arguments. Try CLUSTER HELP")
+	}
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// clusterGetkeysinslot handles the CLUSTER GETKEYSINSLOT subcommand
+func clusterGetkeysinslot(args []shared.Value) shared.Value {
+	slot, err := network.ParseClusterSlot(args[0].Bulk)
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	count, err := strconv.Atoi(args[1].Bulk)
+	if err != nil || count < 0 {
+		return createErrorResponse("ERR Invalid number of keys")
+	}
+
+	keys := network.ClusterKeysInSlot(slot, count)
+	result := make([]shared.Value, len(keys))
+	for i, key := range keys {
+		result[i] = shared.Value{Typ: "bulk", Bulk: key}
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
+
+// clusterCountkeysinslot handles the CLUSTER COUNTKEYSINSLOT subcommand
+func clusterCountkeysinslot(args []shared.Value) shared.Value {
+	slot, err := network.ParseClusterSlot(args[0].Bulk)
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "integer", Num: network.ClusterCountKeysInSlot(slot)}
+}
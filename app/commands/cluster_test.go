@@ -0,0 +1,341 @@
+package commands
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// enableCluster runs the server as a single node cluster until the test ends
+func enableCluster(t *testing.T) {
+	t.Helper()
+	server.StoreState.ClusterEnabled = true
+	network.ClusterInit("", "7000")
+	t.Cleanup(func() { server.StoreState.ClusterEnabled = false })
+}
+
+func clusterArgs(args ...string) []shared.Value {
+	values := make([]shared.Value, len(args))
+	for i, arg := range args {
+		values[i] = shared.Value{Typ: "bulk", Bulk: arg}
+	}
+	return values
+}
+
+func TestClusterDisabled(t *testing.T) {
+	result := Cluster(network.ClientOf("test-conn"), clusterArgs("INFO"))
+	if result.Typ != "error" || result.Str != "ERR This instance has cluster support disabled" {
+		t.Errorf("Expected cluster support disabled error, got %v", result)
+	}
+	if info := Info(network.ClientOf("test-conn"), clusterArgs("cluster")); !strings.Contains(info.Bulk, "cluster_enabled:0\r\n") {
+		t.Errorf("Expected cluster_enabled:0, got %q", info.Bulk)
+	}
+	if result := Readonly(network.ClientOf("test-conn"), nil); result.Str != "ERR This instance has cluster support disabled" {
+		t.Errorf("Expected cluster support disabled error, got %v", result)
+	}
+}
+
+func TestClusterKeyslot(t *testing.T) {
+	enableCluster(t)
+
+	tests := []struct {
+		key  string
+		slot int
+	}{
+		{key: "foo", slot: 12182},
+		{key: "bar", slot: 5061},
+		{key: "123456789", slot: 12739},
+		{key: "", slot: 0},
+
+		// Only the hash tag is hashed
+		{key: "{foo}.following", slot: 12182},
+		{key: "user{foo}", slot: 12182},
+		{key: "{foo}{bar}", slot: 12182},
+		{key: "{{foo}}", slot: 13308}, // Hashes "{foo"
+		{key: "foo{}{bar}", slot: 8363},
+		{key: "{bar", slot: 4015},
+		{key: "bar}", slot: 6624},
+	}
+	for _, tt := range tests {
+		if result := Cluster(network.ClientOf("test-conn"), clusterArgs("KEYSLOT", tt.key)); result.Typ != "integer" || result.Num != tt.slot {
+			t.Errorf("CLUSTER KEYSLOT %q = %v, expected %d", tt.key, result, tt.slot)
+		}
+	}
+	if result := runCommand("CLUSTER", Cluster, "test-conn", clusterArgs("KEYSLOT")); result.Typ != "error" {
+		t.Errorf("Expected arity error, got %v", result)
+	}
+}
+
+func TestClusterIntrospection(t *testing.T) {
+	enableCluster(t)
+
+	myID := Cluster(network.ClientOf("test-conn"), clusterArgs("MYID")).Bulk
+	if !regexp.MustCompile(`^[0-9a-f]{40}$`).MatchString(myID) {
+		t.Fatalf("Expected a 40 characters node ID, got %q", myID)
+	}
+
+	info := Cluster(network.ClientOf("test-conn"), clusterArgs("info")).Bulk
+	for _, field := range []string{"cluster_state:ok\r\n", "cluster_slots_assigned:16384\r\n", "cluster_known_nodes:1\r\n", "cluster_size:1\r\n"} {
+		if !strings.Contains(info, field) {
+			t.Errorf("Expected CLUSTER INFO to contain %q, got %q", field, info)
+		}
+	}
+	if info := Info(network.ClientOf("test-conn"), clusterArgs("cluster")); !strings.Contains(info.Bulk, "cluster_enabled:1\r\n") {
+		t.Errorf("Expected cluster_enabled:1, got %q", info.Bulk)
+	}
+
+	// A single range of every slot, served by this node
+	expected := shared.Value{Typ: "array", Array: []shared.Value{{Typ: "array", Array: []shared.Value{
+		{Typ: "integer", Num: 0},
+		{Typ: "integer", Num: 16383},
+		{Typ: "array", Array: []shared.Value{
+			{Typ: "bulk", Bulk: "127.0.0.1"},
+			{Typ: "integer", Num: 7000},
+			{Typ: "bulk", Bulk: myID},
+			{Typ: "map", Array: []shared.Value{}},
+		}},
+	}}}}
+	if result := Cluster(network.ClientOf("test-conn"), clusterArgs("SLOTS")); string(result.Marshal()) != string(expected.Marshal()) {
+		t.Errorf("CLUSTER SLOTS = %q, expected %q", result.Marshal(), expected.Marshal())
+	}
+
+	shards := Cluster(network.ClientOf("test-conn"), clusterArgs("SHARDS"))
+	if len(shards.Array) != 1 {
+		t.Fatalf("Expected one shard, got %v", shards)
+	}
+	shard := shards.Array[0].Array
+	if shard[0].Bulk != "slots" || len(shard[1].Array) != 2 || shard[1].Array[0].Num != 0 || shard[1].Array[1].Num != 16383 {
+		t.Errorf("Expected slots 0 to 16383, got %v", shard[:2])
+	}
+	node := shard[3].Array[0].Array
+	if node[0].Bulk != "id" || node[1].Bulk != myID || node[3].Num != 7000 || node[9].Bulk != "master" {
+		t.Errorf("Unexpected node description %v", node)
+	}
+}
+
+func TestClusterCrossSlot(t *testing.T) {
+	clearMemory()
+	clearTransactions()
+	initCommandHandlers()
+	network.CommandHandlers["EXEC"] = Exec
+	enableCluster(t)
+
+	// foo and bar hash to different slots
+	if result := network.ExecuteCommand("BLPOP", "test-conn", clusterArgs("foo", "bar", "1")); result.Typ != "error" || result.Str != network.CrossSlotMessage {
+		t.Errorf("Expected CROSSSLOT error, got %v", result)
+	}
+	if result := network.ExecuteCommand("XREAD", "test-conn", clusterArgs("STREAMS", "foo", "bar", "0", "0")); result.Typ != "error" || result.Str != network.CrossSlotMessage {
+		t.Errorf("Expected CROSSSLOT error, got %v", result)
+	}
+	if result := network.ExecuteCommand("SET", "test-conn", clusterArgs("foo", "1")); result.Typ == "error" {
+		t.Errorf("Expected single key command to run, got %v", result)
+	}
+
+	// Keys sharing a hash tag share a slot
+	if result := network.ExecuteCommand("XREAD", "test-conn", clusterArgs("STREAMS", "{user}:a", "{user}:b", "0", "0")); result.Typ == "error" {
+		t.Errorf("Expected keys with the same hash tag to be served, got %v", result)
+	}
+
+	// A transaction is refused as a whole when its commands touch different slots
+	network.TransactionsSet("test-conn", shared.Transaction{Commands: []shared.QueuedCommand{
+		{Command: "SET", Args: clusterArgs("foo", "2")},
+		{Command: "SET", Args: clusterArgs("bar", "2")},
+	}})
+	if result := network.ExecuteCommand("EXEC", "test-conn", nil); result.Typ != "error" || result.Str != network.CrossSlotMessage {
+		t.Errorf("Expected CROSSSLOT error, got %v", result)
+	}
+	if entry := getEntry("foo"); entry.Value != "1" {
+		t.Errorf("Expected foo unchanged by the refused transaction, got %q", entry.Value)
+	}
+}
+
+// fakeClusterNode listens for CLUSTER MEET, replying id to CLUSTER MYID, and returns its port
+func fakeClusterNode(t *testing.T, id string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if _, err := protocol.NewResp(conn).Read(); err == nil {
+				protocol.NewWriter(conn).Write(shared.Value{Typ: "bulk", Bulk: id})
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func TestClusterRedirection(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+	initCommandHandlers()
+	network.CommandHandlers["ASKING"] = Asking
+	enableCluster(t)
+
+	otherID := strings.Repeat("b", 40)
+	port := fakeClusterNode(t, otherID)
+	cluster := func(args ...string) shared.Value {
+		return Cluster(network.ClientOf("test-conn"), clusterArgs(args...))
+	}
+	if result := cluster("MEET", "127.0.0.1", port); result.Str != "OK" {
+		t.Fatalf("CLUSTER MEET = %v, expected OK", result)
+	}
+	if info := cluster("INFO").Bulk; !strings.Contains(info, "cluster_known_nodes:2\r\n") {
+		t.Errorf("Expected 2 known nodes, got %q", info)
+	}
+	other := "127.0.0.1:" + port
+
+	// foo and {foo}.new are in slot 12182, migrating to the other node
+	network.ExecuteCommand("SET", "test-conn", clusterArgs("foo", "1"))
+	if result := cluster("SETSLOT", "12182", "MIGRATING", otherID); result.Str != "OK" {
+		t.Fatalf("CLUSTER SETSLOT MIGRATING = %v, expected OK", result)
+	}
+	if result := network.ExecuteCommand("GET", "test-conn", clusterArgs("foo")); result.Str != "1" {
+		t.Errorf("Expected foo still served, got %v", result)
+	}
+	if result := network.ExecuteCommand("GET", "test-conn", clusterArgs("{foo}.new")); result.Str != "ASK 12182 "+other {
+		t.Errorf("Expected ASK redirection, got %v", result)
+	}
+	if result := network.ExecuteCommand("XREAD", "test-conn", clusterArgs("STREAMS", "foo", "{foo}.new", "0", "0")); result.Str != network.TryAgainMessage {
+		t.Errorf("Expected TRYAGAIN, got %v", result)
+	}
+	if result := cluster("COUNTKEYSINSLOT", "12182"); result.Num != 1 {
+		t.Errorf("Expected 1 key in slot 12182, got %v", result)
+	}
+	if result := cluster("GETKEYSINSLOT", "12182", "10"); len(result.Array) != 1 || result.Array[0].Bulk != "foo" {
+		t.Errorf("Expected [foo] in slot 12182, got %v", result)
+	}
+
+	// The slot is only given away once its keys moved
+	if result := cluster("SETSLOT", "12182", "NODE", otherID); result.Typ != "error" {
+		t.Errorf("Expected assigning a slot holding keys to fail, got %v", result)
+	}
+	server.Memory.Delete("foo")
+	if result := cluster("SETSLOT", "12182", "NODE", otherID); result.Str != "OK" {
+		t.Fatalf("CLUSTER SETSLOT NODE = %v, expected OK", result)
+	}
+	if result := network.ExecuteCommand("GET", "test-conn", clusterArgs("foo")); result.Str != "MOVED 12182 "+other {
+		t.Errorf("Expected MOVED redirection, got %v", result)
+	}
+	if migrating, _ := network.ClusterSlotState(12182); migrating != "" {
+		t.Errorf("Expected the migration to end, still migrating to %s", migrating)
+	}
+
+	// A slot being imported is only served right after ASKING
+	cluster("SETSLOT", "12182", "IMPORTING", otherID)
+	if result := network.ExecuteCommand("ASKING", "test-conn", nil); result.Str != "OK" {
+		t.Fatalf("ASKING = %v, expected OK", result)
+	}
+	if result := network.ExecuteCommand("GET", "test-conn", clusterArgs("foo")); result.Typ == "error" {
+		t.Errorf("Expected GET after ASKING to be served, got %v", result)
+	}
+	if result := network.ExecuteCommand("GET", "test-conn", clusterArgs("foo")); result.Str != "MOVED 12182 "+other {
+		t.Errorf("Expected MOVED redirection without ASKING, got %v", result)
+	}
+	myID := cluster("MYID").Bulk
+	if result := cluster("SETSLOT", "12182", "NODE", myID); result.Str != "OK" {
+		t.Fatalf("CLUSTER SETSLOT NODE = %v, expected OK", result)
+	}
+	if _, importing := network.ClusterSlotState(12182); importing != "" {
+		t.Errorf("Expected the import to end, still importing from %s", importing)
+	}
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{args: []string{"SETSLOT", "16384", "STABLE"}, err: "ERR Invalid or out of range slot"},
+		{args: []string{"SETSLOT", "1", "NODE", "unknown"}, err: "ERR I don't know about node unknown"},
+		{args: []string{"SETSLOT", "1", "IMPORTING", otherID}, err: "ERR I'm already the owner of hash slot 1"},
+		{args: []string{"SETSLOT", "1", "MIGRATING", myID}, err: "ERR I can't migrate hash slot 1 to myself"},
+		{args: []string{"SETSLOT", "1", "MOVING", otherID}, err: "ERR Invalid CLUSTER SETSLOT action or number of arguments. Try CLUSTER HELP"},
+		{args: []string{"GETKEYSINSLOT", "1", "-1"}, err: "ERR Invalid number of keys"},
+		{args: []string{"MEET", "127.0.0.1", "0"}, err: "ERR Invalid base port specified: 0"},
+	}
+	for _, tt := range tests {
+		if result := cluster(tt.args...); result.Typ != "error" || result.Str != tt.err {
+			t.Errorf("CLUSTER %v = %v, expected %q", tt.args, result, tt.err)
+		}
+	}
+}
+
+func TestClusterReadonly(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+	initCommandHandlers()
+	network.CommandHandlers["READONLY"] = Readonly
+	network.CommandHandlers["READWRITE"] = Readwrite
+	network.CommandHandlers["EXEC"] = Exec
+	defer registerTestClient(t, "readonly-conn")()
+	enableCluster(t)
+
+	// This node replicates the other node, which serves slot 12182 of foo
+	otherID := strings.Repeat("c", 40)
+	port := fakeClusterNode(t, otherID)
+	Cluster(network.ClientOf("test-conn"), clusterArgs("MEET", "127.0.0.1", port))
+	if result := Cluster(network.ClientOf("test-conn"), clusterArgs("SETSLOT", "12182", "NODE", otherID)); result.Str != "OK" {
+		t.Fatalf("CLUSTER SETSLOT NODE = %v, expected OK", result)
+	}
+	defer func(role, replicaOf string, stale bool) {
+		server.StoreState.Role, server.StoreState.ReplicaOf, server.StoreState.ReplicaServeStaleData = role, replicaOf, stale
+	}(server.StoreState.Role, server.StoreState.ReplicaOf, server.StoreState.ReplicaServeStaleData)
+	server.StoreState.Role, server.StoreState.ReplicaOf, server.StoreState.ReplicaServeStaleData = "slave", "127.0.0.1 "+port, true
+	server.Memory.Set("foo", shared.MemoryEntry{Value: "bar"})
+
+	moved := "MOVED 12182 127.0.0.1:" + port
+	exec := func(command string, args ...string) shared.Value {
+		return network.ExecuteCommand(command, "readonly-conn", clusterArgs(args...))
+	}
+	if result := exec("GET", "foo"); result.Str != moved {
+		t.Errorf("Expected MOVED redirection before READONLY, got %v", result)
+	}
+
+	if result := exec("READONLY"); result.Str != "OK" {
+		t.Fatalf("READONLY = %v, expected OK", result)
+	}
+	if result := exec("GET", "foo"); result.Str != "bar" {
+		t.Errorf("Expected the replica to serve the read, got %v", result)
+	}
+	if result := exec("SET", "foo", "baz"); result.Str != moved {
+		t.Errorf("Expected writes to be redirected, got %v", result)
+	}
+	if info := Client(network.ClientOf("readonly-conn"), clusterArgs("INFO")); !strings.Contains(info.Bulk, " flags=r ") {
+		t.Errorf("Expected the r flag in CLIENT INFO, got %q", info.Bulk)
+	}
+
+	// A transaction is served when all its commands read
+	network.TransactionsSet("readonly-conn", shared.Transaction{Commands: []shared.QueuedCommand{
+		{Command: "GET", Args: clusterArgs("foo")},
+	}})
+	if result := exec("EXEC"); result.Typ != "array" || len(result.Array) != 1 || result.Array[0].Str != "bar" {
+		t.Errorf("Expected the read-only transaction to be served, got %v", result)
+	}
+	network.TransactionsSet("readonly-conn", shared.Transaction{Commands: []shared.QueuedCommand{
+		{Command: "GET", Args: clusterArgs("foo")},
+		{Command: "SET", Args: clusterArgs("foo", "baz")},
+	}})
+	if result := exec("EXEC"); result.Str != moved {
+		t.Errorf("Expected a transaction with a write to be redirected, got %v", result)
+	}
+
+	if result := exec("READWRITE"); result.Str != "OK" {
+		t.Fatalf("READWRITE = %v, expected OK", result)
+	}
+	if result := exec("GET", "foo"); result.Str != moved {
+		t.Errorf("Expected MOVED redirection after READWRITE, got %v", result)
+	}
+}
@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Command handles the COMMAND command
+// Usage: COMMAND | COMMAND COUNT | COMMAND INFO [command ...] | COMMAND DOCS [command ...] |
+// COMMAND GETKEYS command [arg ...]
+// Returns: Details about the commands the server implements, taken from the command table.
+// Commands renamed with rename-command are described under their new name, disabled ones are left out.
+//
+// Examples:
+//
+//	COMMAND                   // Returns the details of every command
+//	COMMAND COUNT             // Returns the number of commands
+//	COMMAND INFO get set      // Returns the details of GET and SET
+//	COMMAND DOCS get          // Returns the documentation of GET
+//	COMMAND GETKEYS set a 1   // Returns a, the key SET a 1 accesses
+func Command(client *network.Client, args []shared.Value) shared.Value {
+	if len(args) == 0 {
+		specs := clientCommandSpecs()
+		infos := make([]shared.Value, 0, len(specs))
+		for _, spec := range specs {
+			infos = append(infos, commandInfo(spec))
+		}
+		return shared.Value{Typ: "array", Array: infos}
+	}
+
+	subcommand := strings.ToUpper(args[0].Bulk)
+
+	switch subcommand {
+	case "COUNT":
+		return shared.Value{Typ: "integer", Num: len(clientCommandSpecs())}
+	case "INFO":
+		return commandInfoSubcommand(args[1:])
+	case "DOCS":
+		return commandDocs(args[1:])
+	case "GETKEYS":
+		return commandGetkeys(args[1:])
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'command' command")
+	}
+}
+
+// commandInfoSubcommand handles the COMMAND INFO subcommand, unknown commands get a null entry.
+// Without names, every command is described.
+func commandInfoSubcommand(names []shared.Value) shared.Value {
+	if len(names) == 0 {
+		return Command(nil, nil)
+	}
+
+	infos := make([]shared.Value, 0, len(names))
+	for _, name := range names {
+		spec, ok := lookupClientCommand(name.Bulk)
+		if !ok {
+			infos = append(infos, shared.NullArray())
+			continue
+		}
+		infos = append(infos, commandInfo(spec))
+	}
+	return shared.Value{Typ: "array", Array: infos}
+}
+
+// commandDocs handles the COMMAND DOCS subcommand. The reply maps each command name to its
+// summary, since and group fields, unknown commands are left out.
+func commandDocs(names []shared.Value) shared.Value {
+	var specs []*network.CommandSpec
+	if len(names) == 0 {
+		specs = clientCommandSpecs()
+	} else {
+		for _, name := range names {
+			if spec, ok := lookupClientCommand(name.Bulk); ok {
+				specs = append(specs, spec)
+			}
+		}
+	}
+
+	docs := make([]shared.Value, 0, 2*len(specs))
+	for _, spec := range specs {
+		docs = append(docs,
+			shared.Value{Typ: "bulk", Bulk: spec.Name},
+			shared.Value{Typ: "array", Array: []shared.Value{
+				{Typ: "bulk", Bulk: "summary"}, {Typ: "bulk", Bulk: spec.Summary},
+				{Typ: "bulk", Bulk: "since"}, {Typ: "bulk", Bulk: spec.Since},
+				{Typ: "bulk", Bulk: "group"}, {Typ: "bulk", Bulk: spec.Group},
+			}},
+		)
+	}
+	return shared.Value{Typ: "array", Array: docs}
+}
+
+// commandGetkeys handles the COMMAND GETKEYS subcommand, returning the keys a full command accesses
+func commandGetkeys(args []shared.Value) shared.Value {
+	command, ok := network.ResolveCommand(args[0].Bulk)
+	if !ok {
+		return createErrorResponse("ERR Invalid command specified")
+	}
+	keys, err := network.ExtractKeys(command, args[1:])
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	if len(keys) == 0 {
+		return createErrorResponse("ERR The command has no key arguments")
+	}
+
+	result := make([]shared.Value, len(keys))
+	for i, key := range keys {
+		result[i] = shared.Value{Typ: "bulk", Bulk: key}
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
+
+// clientCommandSpecs returns the specs of the commands clients may call, under the names they
+// call them by
+func clientCommandSpecs() []*network.CommandSpec {
+	specs := make([]*network.CommandSpec, 0, len(network.CommandTable))
+	for i := range network.CommandTable {
+		if spec, ok := clientCommandSpec(network.CommandTable[i]); ok {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// lookupClientCommand returns the spec of the command clients call by name
+func lookupClientCommand(name string) (*network.CommandSpec, bool) {
+	command, ok := network.ResolveCommand(name)
+	if !ok {
+		return nil, false
+	}
+	spec, ok := network.LookupCommand(command)
+	if !ok {
+		return nil, false
+	}
+	return clientCommandSpec(spec)
+}
+
+// clientCommandSpec returns spec named as clients call the command, or false when it is disabled
+func clientCommandSpec(spec *network.CommandSpec) (*network.CommandSpec, bool) {
+	name, ok := network.ClientCommandName(spec.Name)
+	if !ok {
+		return nil, false
+	}
+	if name != spec.Name {
+		renamed := *spec
+		renamed.Name = name
+		return &renamed, true
+	}
+	return spec, true
+}
+
+// commandInfo returns the COMMAND reply describing a command: its name, arity, flags,
+// first key, last key, key step, ACL categories, then empty tips, key specs and subcommands
+func commandInfo(spec *network.CommandSpec) shared.Value {
+	flags := make([]shared.Value, len(spec.Flags))
+	for i, flag := range spec.Flags {
+		flags[i] = shared.Value{Typ: "string", Str: flag}
+	}
+	categories := make([]shared.Value, len(spec.Categories))
+	for i, category := range spec.Categories {
+		categories[i] = shared.Value{Typ: "string", Str: category}
+	}
+
+	return shared.Value{Typ: "array", Array: []shared.Value{
+		{Typ: "bulk", Bulk: spec.Name},
+		{Typ: "integer", Num: spec.Arity},
+		{Typ: "set", Array: flags},
+		{Typ: "integer", Num: spec.FirstKey},
+		{Typ: "integer", Num: spec.LastKey},
+		{Typ: "integer", Num: spec.KeyStep},
+		{Typ: "set", Array: categories},
+		{Typ: "array", Array: []shared.Value{}},
+		{Typ: "array", Array: []shared.Value{}},
+		{Typ: "array", Array: []shared.Value{}},
+	}}
+}
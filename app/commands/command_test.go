@@ -0,0 +1,386 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestCommand(t *testing.T) {
+	result := Command(network.ClientOf("test-conn"), nil)
+	if result.Typ != "array" || len(result.Array) != len(network.CommandTable) {
+		t.Fatalf("Expected %d command entries, got %v", len(network.CommandTable), result)
+	}
+	for _, info := range result.Array {
+		if info.Typ != "array" || len(info.Array) != 10 {
+			t.Errorf("Expected a 10 element entry, got %v", info)
+		}
+	}
+
+	count := Command(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "count"}})
+	if count.Typ != "integer" || count.Num != len(network.CommandTable) {
+		t.Errorf("Expected COMMAND COUNT to return %d, got %v", len(network.CommandTable), count)
+	}
+}
+
+func TestCommandInfo(t *testing.T) {
+	result := Command(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "INFO"},
+		{Typ: "bulk", Bulk: "get"},
+		{Typ: "bulk", Bulk: "unknown"},
+		{Typ: "bulk", Bulk: "BLPOP"},
+	})
+	if result.Typ != "array" || len(result.Array) != 3 {
+		t.Fatalf("Expected 3 entries, got %v", result)
+	}
+
+	get := result.Array[0].Array
+	if get[0].Bulk != "get" || get[1].Num != 2 || get[3].Num != 1 || get[4].Num != 1 || get[5].Num != 1 {
+		t.Errorf("Unexpected GET entry: %v", get)
+	}
+	if len(get[2].Array) != 2 || get[2].Array[0].Str != "readonly" || get[2].Array[1].Str != "fast" {
+		t.Errorf("Unexpected GET flags: %v", get[2].Array)
+	}
+	if len(get[6].Array) == 0 || get[6].Array[0].Str != "@read" {
+		t.Errorf("Unexpected GET categories: %v", get[6].Array)
+	}
+
+	if !result.Array[1].IsNull() || !result.Array[1].NullArray {
+		t.Errorf("Expected a null entry for an unknown command, got %v", result.Array[1])
+	}
+
+	blpop := result.Array[2].Array
+	if blpop[0].Bulk != "blpop" || blpop[1].Num != -3 || blpop[4].Num != -2 {
+		t.Errorf("Unexpected BLPOP entry: %v", blpop)
+	}
+}
+
+func TestCommandDocs(t *testing.T) {
+	result := Command(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "DOCS"},
+		{Typ: "bulk", Bulk: "set"},
+		{Typ: "bulk", Bulk: "unknown"},
+	})
+	if result.Typ != "array" || len(result.Array) != 2 {
+		t.Fatalf("Expected a single command in the reply, got %v", result)
+	}
+	if result.Array[0].Bulk != "set" {
+		t.Errorf("Expected the set command, got %v", result.Array[0])
+	}
+	doc := result.Array[1].Array
+	if len(doc) != 6 || doc[0].Bulk != "summary" || doc[4].Bulk != "group" || doc[5].Bulk != "string" {
+		t.Errorf("Unexpected SET documentation: %v", doc)
+	}
+
+	all := Command(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "DOCS"}})
+	if len(all.Array) != 2*len(network.CommandTable) {
+		t.Errorf("Expected documentation for every command, got %d entries", len(all.Array)/2)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []shared.Value
+		expected string
+	}{
+		{name: "Unknown subcommand", args: []shared.Value{{Typ: "bulk", Bulk: "FOO"}}, expected: "ERR unknown subcommand for 'command' command"},
+		{name: "COUNT with arguments", args: []shared.Value{{Typ: "bulk", Bulk: "COUNT"}, {Typ: "bulk", Bulk: "x"}}, expected: "ERR wrong number of arguments for 'command|count' command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("COMMAND", Command, "test-conn", tt.args)
+			if result.Typ != "error" || result.Str != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCommandTableFlags(t *testing.T) {
+	writes := []string{"SET", "LPUSH", "RPUSH", "LPOP", "BLPOP", "INCR", "XADD", "ZADD", "ZREM", "GEOADD"}
+	for _, command := range writes {
+		if !network.IsWriteCommand(command) {
+			t.Errorf("Expected %s to be a write command", command)
+		}
+	}
+	for _, command := range []string{"GET", "LRANGE", "PING", "UNKNOWN"} {
+		if network.IsWriteCommand(command) {
+			t.Errorf("Expected %s not to be a write command", command)
+		}
+	}
+	if !network.IsStaleCommand("info") || network.IsStaleCommand("GET") {
+		t.Errorf("Unexpected stale flags for INFO or GET")
+	}
+}
+
+func TestCommandArity(t *testing.T) {
+	initCommandHandlers()
+
+	tests := []struct {
+		command  string
+		args     []shared.Value
+		expected string
+	}{
+		{command: "GET", args: []shared.Value{}, expected: "ERR wrong number of arguments for 'get' command"},
+		{command: "GET", args: []shared.Value{{Typ: "bulk", Bulk: "a"}, {Typ: "bulk", Bulk: "b"}}, expected: "ERR wrong number of arguments for 'get' command"},
+		{command: "SET", args: []shared.Value{{Typ: "bulk", Bulk: "a"}}, expected: "ERR wrong number of arguments for 'set' command"},
+	}
+
+	for _, tt := range tests {
+		result := network.ExecuteCommand(tt.command, "test-conn", tt.args)
+		if result.Typ != "error" || result.Str != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.command, tt.expected, result)
+		}
+	}
+}
+
+func TestCommandGetkeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		err      string
+	}{
+		{name: "Single key", args: []string{"SET", "a", "1"}, expected: []string{"a"}},
+		{name: "Last key from the end", args: []string{"blpop", "a", "b", "c", "0"}, expected: []string{"a", "b", "c"}},
+		{name: "Movable keys", args: []string{"XREAD", "COUNT", "2", "streams", "s1", "s2", "0", "0"}, expected: []string{"s1", "s2"}},
+		{name: "Unbalanced streams", args: []string{"XREAD", "STREAMS", "s1", "s2", "0"}, err: "ERR Invalid arguments specified for command"},
+		{name: "Unknown command", args: []string{"FOO", "a"}, err: "ERR Invalid command specified"},
+		{name: "Wrong number of arguments", args: []string{"GET", "a", "b"}, err: "ERR Invalid number of arguments specified for command"},
+		{name: "No key arguments", args: []string{"PING"}, err: "ERR The command has no key arguments"},
+		{name: "Missing command", args: []string{}, err: "ERR wrong number of arguments for 'command|getkeys' command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []shared.Value{{Typ: "bulk", Bulk: "GETKEYS"}}
+			for _, arg := range tt.args {
+				args = append(args, shared.Value{Typ: "bulk", Bulk: arg})
+			}
+
+			result := runCommand("COMMAND", Command, "test-conn", args)
+			if tt.err != "" {
+				if result.Typ != "error" || result.Str != tt.err {
+					t.Errorf("Expected error %q, got %v", tt.err, result)
+				}
+				return
+			}
+			if result.Typ != "array" || len(result.Array) != len(tt.expected) {
+				t.Fatalf("Expected keys %v, got %v", tt.expected, result)
+			}
+			for i, key := range tt.expected {
+				if result.Array[i].Bulk != key {
+					t.Errorf("Expected key %q at %d, got %q", key, i, result.Array[i].Bulk)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandRenamed(t *testing.T) {
+	initCommandHandlers()
+	defer network.ResetCommandRenames()
+	if err := network.RenameCommand("get", "fetch"); err != nil {
+		t.Fatalf("Failed to rename GET: %v", err)
+	}
+	if err := network.RenameCommand("ECHO", ""); err != nil {
+		t.Fatalf("Failed to disable ECHO: %v", err)
+	}
+
+	count := Command(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "COUNT"}})
+	if count.Num != len(network.CommandTable)-1 {
+		t.Errorf("Expected the disabled command to be left out of %d commands, got %v", len(network.CommandTable), count)
+	}
+
+	result := Command(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "INFO"},
+		{Typ: "bulk", Bulk: "fetch"},
+		{Typ: "bulk", Bulk: "get"},
+		{Typ: "bulk", Bulk: "echo"},
+	})
+	if result.Array[0].Typ != "array" || result.Array[0].Array[0].Bulk != "fetch" {
+		t.Errorf("Expected GET to be described as fetch, got %v", result.Array[0])
+	}
+	if !result.Array[1].IsNull() || !result.Array[1].NullArray || !result.Array[2].IsNull() || !result.Array[2].NullArray {
+		t.Errorf("Expected null entries for the renamed and disabled names, got %v", result.Array[1:])
+	}
+
+	keys := Command(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "GETKEYS"}, {Typ: "bulk", Bulk: "fetch"}, {Typ: "bulk", Bulk: "a"}})
+	if keys.Typ != "array" || len(keys.Array) != 1 || keys.Array[0].Bulk != "a" {
+		t.Errorf("Expected the keys of the renamed command, got %v", keys)
+	}
+
+	if command, ok := network.ResolveCommand("FETCH"); !ok || command != "GET" {
+		t.Errorf("Expected FETCH to run GET, got %q %v", command, ok)
+	}
+	if _, ok := network.ResolveCommand("GET"); ok {
+		t.Errorf("Expected GET to be hidden")
+	}
+
+	// Names already taken and commands already renamed can't be renamed again
+	if err := network.RenameCommand("SET", "ping"); err == nil {
+		t.Errorf("Expected an error renaming to an existing command")
+	}
+	if err := network.RenameCommand("GET", "other"); err == nil {
+		t.Errorf("Expected an error renaming a renamed command")
+	}
+}
+
+func BenchmarkCommandInfo(b *testing.B) {
+	args := []shared.Value{{Typ: "bulk", Bulk: "INFO"}, {Typ: "bulk", Bulk: "get"}}
+	for i := 0; i < b.N; i++ {
+		Command(network.ClientOf("test-conn"), args)
+	}
+}
+
+func TestCommandHooks(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	defer network.ClearCommandHooks()
+
+	var after []string
+	network.AddBeforeCommandHook(func(connID, command string, args []shared.Value) error {
+		if command == "SET" && args[0].Bulk == "forbidden" {
+			return errors.New("key is forbidden")
+		}
+		if command == "ECHO" {
+			return errors.New("NOECHO echo is disabled")
+		}
+		return nil
+	})
+	network.AddAfterCommandHook(func(connID, command string, args []shared.Value) error {
+		after = append(after, command)
+		return nil
+	})
+
+	set := func(key string) shared.Value {
+		return network.ExecuteCommand("SET", "test-conn", []shared.Value{{Typ: "bulk", Bulk: key}, {Typ: "bulk", Bulk: "v"}})
+	}
+	if result := set("allowed"); result.Str != "OK" {
+		t.Errorf("Expected OK, got %v", result)
+	}
+	if result := set("forbidden"); result.Typ != "error" || result.Str != "ERR key is forbidden" {
+		t.Errorf("Expected the hook error prefixed with ERR, got %v", result)
+	}
+	if result := network.ExecuteCommand("ECHO", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "hi"}}); result.Str != "NOECHO echo is disabled" {
+		t.Errorf("Expected the hook error with its own code, got %v", result)
+	}
+	if _, exists := server.Memory.Get("forbidden"); exists {
+		t.Error("Expected the refused SET not to run")
+	}
+	network.ExecuteCommand("GET", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "allowed"}})
+
+	// Refused commands don't run the hooks run after commands
+	if len(after) != 2 || after[0] != "SET" || after[1] != "GET" {
+		t.Errorf("Expected the hooks after SET and GET, got %v", after)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	initCommandHandlers()
+	network.CommandHandlers["CONFIG"] = Config
+	network.CommandHandlers["ACL"] = Acl
+	clearMemory()
+	defer func(maxSize int64, maxFiles int) {
+		server.StoreState.AuditLogMaxSize, server.StoreState.AuditLogMaxFiles = maxSize, maxFiles
+	}(server.StoreState.AuditLogMaxSize, server.StoreState.AuditLogMaxFiles)
+	defer network.ACLDeleteUser("audited")
+	defer network.SetAuditLogFile("")
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := network.SetAuditLogFile(path); err != nil {
+		t.Fatalf("Expected the audit log to open, got %v", err)
+	}
+	run := func(command string, args ...string) {
+		values := make([]shared.Value, len(args))
+		for i, arg := range args {
+			values[i] = shared.Value{Typ: "bulk", Bulk: arg}
+		}
+		network.ExecuteCommand(command, "test-conn", values)
+	}
+	run("SET", "key", "value")
+	run("GET", "key")
+	run("CONFIG", "SET", "requirepass", "secret", "timeout", "0")
+	run("CONFIG", "SET", "requirepass", "")
+	run("ACL", "SETUSER", "audited", "on", ">secret", "~*")
+
+	// The lines are written by the writer of the audit log
+	network.FlushAuditLog()
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	expected := []string{
+		`[test-conn default] "set" "key" "value"`,
+		`[test-conn default] "config" "SET" "requirepass" "(redacted)" "timeout" "0"`,
+		`[test-conn default] "config" "SET" "requirepass" "(redacted)"`,
+		`[test-conn default] "acl" "SETUSER" "audited" "on" "(redacted)" "~*"`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %q", len(expected), data)
+	}
+	for i, line := range lines {
+		if !regexp.MustCompile(`^\d+\.\d{6} `).MatchString(line) || !strings.HasSuffix(line, expected[i]) {
+			t.Errorf("Expected line %d to end with %s, got %s", i, expected[i], line)
+		}
+	}
+
+	// Lines going over the size limit start a new file, the older ones are shifted
+	server.StoreState.AuditLogMaxSize, server.StoreState.AuditLogMaxFiles = 100, 2
+	for i := 0; i < 4; i++ {
+		run("SET", "key", strings.Repeat("v", 40))
+	}
+	network.FlushAuditLog()
+	for suffix, count := range map[string]int{"": 1, ".1": 1, ".2": 1, ".3": 0} {
+		data, _ := os.ReadFile(path + suffix)
+		if got := strings.Count(string(data), "\n"); got != count {
+			t.Errorf("Expected %d lines in audit.log%s, got %d", count, suffix, got)
+		}
+	}
+}
+
+func TestKeyHooks(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	defer server.ClearKeyHooks()
+
+	var expired, changed []string
+	server.AddExpiredHook(func(key string) { expired = append(expired, key) })
+	server.AddKeyChangedHook(func(key, event string) { changed = append(changed, event+" "+key) })
+
+	run := func(command string, args ...string) {
+		values := make([]shared.Value, len(args))
+		for i, arg := range args {
+			values[i] = shared.Value{Typ: "bulk", Bulk: arg}
+		}
+		network.ExecuteCommand(command, "test-conn", values)
+	}
+	dirty := server.Dirty()
+	run("SET", "a", "1")
+	run("SET", "a", "2", "NX") // Changes nothing
+	run("GET", "a")
+	run("RPUSH", "list", "x", "y")
+	run("DEL", "list", "missing")
+	if changes := server.Dirty() - dirty; changes != 3 {
+		t.Errorf("Expected 3 key changes to be counted, got %d", changes)
+	}
+	server.Memory.Set("old", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() - 1})
+	run("GET", "old")
+	server.Memory.Set("older", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() - 1})
+	server.ActiveExpireCycle()
+
+	if strings.Join(expired, ",") != "old,older" {
+		t.Errorf("Expected old and older to expire, got %v", expired)
+	}
+	if strings.Join(changed, ",") != "set a,rpush list,del list,expired old,expired older" {
+		t.Errorf("Unexpected key changes %v", changed)
+	}
+}
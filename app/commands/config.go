@@ -1,31 +1,44 @@
 package commands
 
 import (
+	"fmt"
+	"path/filepath"
 	"strings"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 // Config handles the CONFIG command
-// Usage: CONFIG GET parameter
-// Returns: The value of the configuration parameter, or error if parameter is unknown.
+// Usage: CONFIG GET pattern [pattern ...] | CONFIG SET parameter value [parameter value ...] | CONFIG REWRITE |
+// CONFIG RESETSTAT
+// Returns: For GET, the name and value of every parameter matching a glob pattern.
+// For SET, OK once every parameter was changed, or an error leaving them all unchanged.
+// For REWRITE, OK once the current configuration was written to the config file.
+// For RESETSTAT, OK once the statistics reported by INFO were zeroed.
 //
 // Examples:
 //
-//	CONFIG GET dir           // Returns the value of the directory where Redis stores its data
-//	CONFIG GET dbfilename    // Returns the value of the database file name
-//	CONFIG GET unknown       // Returns an error if the parameter is unknown
-func Config(connID string, args []shared.Value) shared.Value {
-	if len(args) < 1 {
-		return createErrorResponse("ERR wrong number of arguments for 'config' command")
-	}
-
+//	CONFIG GET dir                      // Returns the directory where Redis stores its data
+//	CONFIG GET *aof*                    // Returns every parameter with aof in its name
+//	CONFIG SET appendfsync always       // Fsyncs the append only file after every write
+//	CONFIG SET maxmemory 100mb save ""  // Changes several parameters at once
+//	CONFIG REWRITE                      // Persists the changes to the config file
+//	CONFIG RESETSTAT                    // Zeroes the command, error and connection statistics
+func Config(client *network.Client, args []shared.Value) shared.Value {
 	subcommand := strings.ToUpper(args[0].Bulk)
 
 	switch subcommand {
 	case "GET":
 		return configGet(args[1:])
+	case "SET":
+		return configSet(args[1:])
+	case "REWRITE":
+		return configRewrite(args[1:])
+	case "RESETSTAT":
+		server.ResetStats()
+		return shared.Value{Typ: "string", Str: "OK"}
 	default:
 		return createErrorResponse("ERR unknown subcommand for 'config' command")
 	}
@@ -33,47 +46,100 @@ func Config(connID string, args []shared.Value) shared.Value {
 
 // configGet handles the CONFIG GET subcommand
 func configGet(args []shared.Value) shared.Value {
-	if len(args) == 0 {
-		return createErrorResponse("ERR wrong number of arguments for 'config get' command")
+	// Each parameter is returned once, even when several patterns match it
+	var result []shared.Value
+	returned := make(map[string]bool)
+	for _, arg := range args {
+		pattern := strings.ToLower(arg.Bulk)
+		for _, param := range configParams {
+			if returned[param.name] {
+				continue
+			}
+			if matched, _ := filepath.Match(pattern, param.name); !matched {
+				continue
+			}
+			returned[param.name] = true
+			result = append(result, shared.Value{Typ: "bulk", Bulk: param.name})
+			result = append(result, shared.Value{Typ: "bulk", Bulk: param.get()})
+		}
 	}
 
-	// Create array to hold key-value pairs
-	var result []shared.Value
+	return shared.Value{Typ: "map", Array: result}
+}
 
-	// Process each configuration parameter
-	for _, arg := range args {
-		param := arg.Bulk
-		paramUpper := strings.ToUpper(param)
+// configSet handles the CONFIG SET subcommand. The parameters are changed all or nothing:
+// if a value is invalid or a change callback fails, the previous values are restored.
+func configSet(args []shared.Value) shared.Value {
+	if len(args)%2 != 0 {
+		return shared.ErrWrongArity("config|set")
+	}
 
-		// Get the configuration value
-		value := getConfigValue(paramUpper)
+	params := make([]*configParam, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		param, ok := lookupConfigParam(args[i].Bulk)
+		if !ok {
+			return createErrorResponse(fmt.Sprintf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", args[i].Bulk))
+		}
+		for _, seen := range params {
+			if seen == param {
+				return configSetError(param, "duplicate parameter")
+			}
+		}
+		if param.immutable {
+			return configSetError(param, "can't set immutable config")
+		}
+		params = append(params, param)
+	}
 
-		// Add key-value pair to result (preserve original case)
-		result = append(result, shared.Value{Typ: "bulk", Bulk: param})
-		result = append(result, shared.Value{Typ: "bulk", Bulk: value})
+	previous := make([]string, len(params))
+	for i, param := range params {
+		previous[i] = param.get()
+	}
+	restore := func(count int) {
+		for i := 0; i < count; i++ {
+			params[i].set(previous[i])
+		}
 	}
 
-	return shared.Value{Typ: "array", Array: result}
-}
+	for i, param := range params {
+		if err := param.set(args[i*2+1].Bulk); err != nil {
+			restore(i)
+			return configSetError(param, err.Error())
+		}
+	}
 
-// getConfigValue returns the value for a given configuration parameter
-func getConfigValue(param string) string {
-	switch param {
-	case "DIR":
-		return getConfigDir()
-	case "DBFILENAME":
-		return getConfigDbfilename()
-	default:
-		return ""
+	// Callbacks only run for parameters whose value actually changed, and run again
+	// to undo their effect when a later one fails
+	var applied []*configParam
+	for i, param := range params {
+		if param.apply == nil || param.get() == previous[i] {
+			continue
+		}
+		if err := param.apply(); err != nil {
+			restore(len(params))
+			for _, p := range applied {
+				p.apply()
+			}
+			return configSetError(param, err.Error())
+		}
+		applied = append(applied, param)
 	}
+
+	return shared.Value{Typ: "string", Str: "OK"}
 }
 
-// getConfigDir returns the current directory configuration
-func getConfigDir() string {
-	return server.StoreState.ConfigDir
+// configSetError returns the error CONFIG SET replies with when a parameter can't be changed
+func configSetError(param *configParam, reason string) shared.Value {
+	return createErrorResponse(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", param.name, reason))
 }
 
-// getConfigDbfilename returns the current database filename configuration
-func getConfigDbfilename() string {
-	return server.StoreState.ConfigDbfilename
+// configRewrite handles the CONFIG REWRITE subcommand
+func configRewrite(args []shared.Value) shared.Value {
+	if server.StoreState.ConfigFile == "" {
+		return createErrorResponse("ERR The server is running without a config file")
+	}
+	if err := rewriteConfigFile(server.StoreState.ConfigFile); err != nil {
+		return createErrorResponse(fmt.Sprintf("ERR Rewriting config file: %v", err))
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
 }
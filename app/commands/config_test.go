@@ -1,11 +1,19 @@
 package commands
 
 import (
+	"bytes"
 	"net"
+	"os"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 func TestConfigGet(t *testing.T) {
@@ -17,6 +25,20 @@ func TestConfigGet(t *testing.T) {
 		Replicas:         make(map[string]net.Conn),
 		ConfigDir:        "/tmp/redis-data",
 		ConfigDbfilename: "rdbfile",
+
+		MinReplicasToWrite: 2,
+		MinReplicasMaxLag:  10,
+
+		ReplicaServeStaleData: true,
+		RDBCompression:        true,
+		RDBChecksum:           true,
+
+		SavePoints: []shared.SavePoint{{Seconds: 900, Changes: 1}, {Seconds: 300, Changes: 10}},
+
+		AppendFsync:   "everysec",
+		AppendDirname: "appendonlydir",
+
+		ShutdownTimeout: 10,
 	})
 
 	tests := []struct {
@@ -56,7 +78,74 @@ func TestConfigGet(t *testing.T) {
 				{Typ: "bulk", Bulk: "DIR"},
 				{Typ: "bulk", Bulk: "Dbfilename"},
 			},
-			expected: []string{"DIR", "/tmp/redis-data", "Dbfilename", "rdbfile"},
+			expected: []string{"dir", "/tmp/redis-data", "dbfilename", "rdbfile"},
+		},
+		{
+			name: "CONFIG GET min-replicas parameters",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "min-replicas-to-write"},
+				{Typ: "bulk", Bulk: "min-replicas-max-lag"},
+			},
+			expected: []string{"min-replicas-to-write", "2", "min-replicas-max-lag", "10"},
+		},
+		{
+			name: "CONFIG GET replica-serve-stale-data",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "replica-serve-stale-data"},
+			},
+			expected: []string{"replica-serve-stale-data", "yes"},
+		},
+		{
+			name: "CONFIG GET rdbcompression",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "rdbcompression"},
+			},
+			expected: []string{"rdbcompression", "yes"},
+		},
+		{
+			name: "CONFIG GET rdbchecksum",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "rdbchecksum"},
+			},
+			expected: []string{"rdbchecksum", "yes"},
+		},
+		{
+			name: "CONFIG GET appendonly parameters",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "appendonly"},
+				{Typ: "bulk", Bulk: "appendfsync"},
+			},
+			expected: []string{"appendonly", "no", "appendfsync", "everysec"},
+		},
+		{
+			name: "CONFIG GET multi-part AOF parameters",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "appenddirname"},
+				{Typ: "bulk", Bulk: "aof-timestamp-enabled"},
+			},
+			expected: []string{"appenddirname", "appendonlydir", "aof-timestamp-enabled", "no"},
+		},
+		{
+			name: "CONFIG GET shutdown-timeout",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "shutdown-timeout"},
+			},
+			expected: []string{"shutdown-timeout", "10"},
+		},
+		{
+			name: "CONFIG GET save",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "save"},
+			},
+			expected: []string{"save", "900 1 300 10"},
 		},
 		{
 			name: "CONFIG GET unknown parameter",
@@ -64,16 +153,33 @@ func TestConfigGet(t *testing.T) {
 				{Typ: "bulk", Bulk: "GET"},
 				{Typ: "bulk", Bulk: "unknown"},
 			},
-			expected: []string{"unknown", ""},
+			expected: []string{},
+		},
+		{
+			name: "CONFIG GET glob pattern",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "min-replicas-*"},
+			},
+			expected: []string{"min-replicas-to-write", "2", "min-replicas-max-lag", "10"},
+		},
+		{
+			name: "CONFIG GET overlapping patterns",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "GET"},
+				{Typ: "bulk", Bulk: "db*"},
+				{Typ: "bulk", Bulk: "dbfilename"},
+			},
+			expected: []string{"dbfilename", "rdbfile"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := Config("test-conn", tt.args)
+			result := runCommand("CONFIG", Config, "test-conn", tt.args)
 
-			if result.Typ != "array" {
-				t.Errorf("Expected array response, got %s", result.Typ)
+			if result.Typ != "map" {
+				t.Errorf("Expected map response, got %s", result.Typ)
 				return
 			}
 
@@ -110,7 +216,7 @@ func TestConfigGetInvalidArgs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := Config("test-conn", tt.args)
+			result := runCommand("CONFIG", Config, "test-conn", tt.args)
 
 			if result.Typ != "error" {
 				t.Errorf("Expected error response, got %s", result.Typ)
@@ -125,12 +231,11 @@ func TestConfigGetInvalidArgs(t *testing.T) {
 
 func TestConfigUnknownSubcommand(t *testing.T) {
 	args := []shared.Value{
-		{Typ: "bulk", Bulk: "SET"},
+		{Typ: "bulk", Bulk: "UNKNOWN"},
 		{Typ: "bulk", Bulk: "dir"},
-		{Typ: "bulk", Bulk: "/new/path"},
 	}
 
-	result := Config("test-conn", args)
+	result := Config(network.ClientOf("test-conn"), args)
 
 	if result.Typ != "error" {
 		t.Errorf("Expected error response, got %s", result.Typ)
@@ -141,6 +246,232 @@ func TestConfigUnknownSubcommand(t *testing.T) {
 	}
 }
 
+func TestConfigGetAllParameters(t *testing.T) {
+	result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "GET"}, {Typ: "bulk", Bulk: "*"}})
+	if len(result.Array) != 2*len(configParams) {
+		t.Fatalf("Expected every parameter, got %d elements", len(result.Array))
+	}
+	for i, param := range configParams {
+		if result.Array[2*i].Bulk != param.name {
+			t.Errorf("Expected %q at index %d, got %q", param.name, 2*i, result.Array[2*i].Bulk)
+		}
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string   // Expected reply, OK or an error
+		get      []string // Parameter and value pairs expected afterwards
+	}{
+		{
+			name:     "Set a string",
+			args:     []string{"dbfilename", "dump.rdb"},
+			expected: "OK",
+			get:      []string{"dbfilename", "dump.rdb"},
+		},
+		{
+			name:     "Set a directory",
+			args:     []string{"dir", dir},
+			expected: "OK",
+			get:      []string{"dir", dir},
+		},
+		{
+			name:     "Set a flag case-insensitively",
+			args:     []string{"RDBCOMPRESSION", "NO"},
+			expected: "OK",
+			get:      []string{"rdbcompression", "no"},
+		},
+		{
+			name:     "Set an enum",
+			args:     []string{"appendfsync", "always"},
+			expected: "OK",
+			get:      []string{"appendfsync", "always"},
+		},
+		{
+			name:     "Set a memory value with a unit",
+			args:     []string{"maxmemory", "100mb"},
+			expected: "OK",
+			get:      []string{"maxmemory", "104857600"},
+		},
+		{
+			name:     "Set save points",
+			args:     []string{"save", "60 100 10 1000"},
+			expected: "OK",
+			get:      []string{"save", "60 100 10 1000"},
+		},
+		{
+			name:     "Disable save points",
+			args:     []string{"save", ""},
+			expected: "OK",
+			get:      []string{"save", ""},
+		},
+		{
+			name:     "Set several parameters",
+			args:     []string{"min-replicas-to-write", "1", "min-replicas-max-lag", "5"},
+			expected: "OK",
+			get:      []string{"min-replicas-to-write", "1", "min-replicas-max-lag", "5"},
+		},
+		{
+			name:     "Unknown parameter",
+			args:     []string{"unknown", "1"},
+			expected: "ERR Unknown option or number of arguments for CONFIG SET - 'unknown'",
+		},
+		{
+			name:     "Invalid flag",
+			args:     []string{"rdbchecksum", "maybe"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'rdbchecksum') - argument must be 'yes' or 'no'",
+			get:      []string{"rdbchecksum", "yes"},
+		},
+		{
+			name:     "Invalid integer",
+			args:     []string{"shutdown-timeout", "soon"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'shutdown-timeout') - argument couldn't be parsed into an integer",
+			get:      []string{"shutdown-timeout", "10"},
+		},
+		{
+			name:     "Integer out of range",
+			args:     []string{"min-replicas-max-lag", "-1"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'min-replicas-max-lag') - argument must be between 0 and 2147483647 inclusive",
+		},
+		{
+			name:     "Invalid enum",
+			args:     []string{"appendfsync", "sometimes"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'appendfsync') - argument(s) must be one of the following: always, everysec, no",
+		},
+		{
+			name:     "Invalid memory value",
+			args:     []string{"maxmemory", "lots"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'maxmemory') - argument must be a memory value",
+		},
+		{
+			name:     "Invalid save points",
+			args:     []string{"save", "60"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'save') - invalid save parameters",
+		},
+		{
+			name:     "Filename with a path",
+			args:     []string{"dbfilename", "../dump.rdb"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'dbfilename') - can't be a path, just a filename",
+			get:      []string{"dbfilename", "rdbfile"},
+		},
+		{
+			name:     "Immutable parameter",
+			args:     []string{"appendfilename", "other.aof"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'appendfilename') - can't set immutable config",
+			get:      []string{"appendfilename", "appendonly.aof"},
+		},
+		{
+			name:     "Duplicate parameter",
+			args:     []string{"maxmemory", "1", "MAXMEMORY", "2"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'maxmemory') - duplicate parameter",
+		},
+		{
+			name:     "Nothing is changed when one value is invalid",
+			args:     []string{"rdbcompression", "no", "appendfsync", "sometimes"},
+			expected: "ERR CONFIG SET failed (possibly related to argument 'appendfsync') - argument(s) must be one of the following: always, everysec, no",
+			get:      []string{"rdbcompression", "yes", "appendfsync", "everysec"},
+		},
+		{
+			name:     "Wrong number of arguments",
+			args:     []string{"maxmemory"},
+			expected: "ERR wrong number of arguments for 'config|set' command",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.SetStoreState(shared.State{
+				Role:             "master",
+				Replicas:         make(map[string]net.Conn),
+				ConfigDir:        "/tmp/redis-data",
+				ConfigDbfilename: "rdbfile",
+				RDBCompression:   true,
+				RDBChecksum:      true,
+				AppendFilename:   "appendonly.aof",
+				AppendFsync:      "everysec",
+				ShutdownTimeout:  10,
+			})
+
+			args := []shared.Value{{Typ: "bulk", Bulk: "SET"}}
+			for _, arg := range tt.args {
+				args = append(args, shared.Value{Typ: "bulk", Bulk: arg})
+			}
+			result := Config(network.ClientOf("test-conn"), args)
+
+			if tt.expected == "OK" {
+				if result.Typ != "string" || result.Str != "OK" {
+					t.Fatalf("Expected OK, got %v", result)
+				}
+			} else if result.Typ != "error" || result.Str != tt.expected {
+				t.Fatalf("Expected error %q, got %v", tt.expected, result)
+			}
+
+			for i := 0; i < len(tt.get); i += 2 {
+				get := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "GET"}, {Typ: "bulk", Bulk: tt.get[i]}})
+				if len(get.Array) != 2 || get.Array[1].Bulk != tt.get[i+1] {
+					t.Errorf("Expected %s to be %q, got %v", tt.get[i], tt.get[i+1], get.Array)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigSetAppendOnly(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:              "master",
+		Replicas:          make(map[string]net.Conn),
+		ConfigDir:         dir,
+		AppendFilename:    "appendonly.aof",
+		AppendDirname:     "appendonlydir",
+		AppendFsync:       "always",
+		AOFUseRDBPreamble: true,
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer storage.CloseAppendOnlyFile()
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "appendonly"}, {Typ: "bulk", Bulk: "yes"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	waitForAppendOnlyRewrite(t)
+
+	// The dataset is written to a new base and later writes are appended
+	command := shared.Value{Typ: "array", Array: []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "other"}, {Typ: "bulk", Bulk: "value"}}}.Marshal()
+	storage.FeedAppendOnlyFile(command)
+	if !storage.GetPersistenceStats().AOFEnabled {
+		t.Errorf("Expected the append only file to be enabled")
+	}
+
+	clearMemory()
+	initCommandHandlers()
+	err := storage.LoadAppendOnlyFile(dir, "appendonly.aof", func(command string, args []shared.Value) shared.Value {
+		return network.ExecuteCommand(command, "aof-loader", args)
+	})
+	if err != nil {
+		t.Fatalf("Failed to load the append only file: %v", err)
+	}
+	for _, key := range []string{"key", "other"} {
+		if _, exists := server.Memory.Get(key); !exists {
+			t.Errorf("Expected %q to be restored from the append only file", key)
+		}
+	}
+
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "appendonly"}, {Typ: "bulk", Bulk: "no"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if storage.GetPersistenceStats().AOFEnabled {
+		t.Errorf("Expected the append only file to be disabled")
+	}
+}
+
 func TestConfigGetAllSupportedParameters(t *testing.T) {
 	// Reset store state for clean test
 	server.SetStoreState(shared.State{
@@ -162,10 +493,10 @@ func TestConfigGetAllSupportedParameters(t *testing.T) {
 		args = append(args, shared.Value{Typ: "bulk", Bulk: param})
 	}
 
-	result := Config("test-conn", args)
+	result := Config(network.ClientOf("test-conn"), args)
 
-	if result.Typ != "array" {
-		t.Errorf("Expected array response, got %s", result.Typ)
+	if result.Typ != "map" {
+		t.Errorf("Expected map response, got %s", result.Typ)
 		return
 	}
 
@@ -193,6 +524,144 @@ func TestConfigGetAllSupportedParameters(t *testing.T) {
 	}
 }
 
+func TestReplicaServeStaleData(t *testing.T) {
+	clearMemory()
+	initCommandHandlers()
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	getArgs := []shared.Value{{Typ: "bulk", Bulk: "key"}}
+
+	// Serving stale data is the default: reads work without a link to the master
+	server.SetStoreState(shared.State{
+		Role:                  "slave",
+		Replicas:              make(map[string]net.Conn),
+		ReplicaServeStaleData: true,
+	})
+	if result := network.ExecuteCommand("GET", "test-conn", getArgs); result.Typ != "null" {
+		t.Errorf("Expected GET to be served, got %v", result)
+	}
+
+	// With replica-serve-stale-data disabled, data commands are refused
+	server.StoreState.ReplicaServeStaleData = false
+	result := network.ExecuteCommand("GET", "test-conn", getArgs)
+	if result.Typ != "error" || result.Str != "MASTERDOWN Link with MASTER is down and replica-serve-stale-data is set to 'no'." {
+		t.Errorf("Expected MASTERDOWN error, got %v", result)
+	}
+
+	// Commands flagged as allowed on a stale replica still run
+	if result := network.ExecuteCommand("PING", "test-conn", []shared.Value{}); result.Typ == "error" {
+		t.Errorf("Expected PING to be served, got %v", result)
+	}
+
+	// Masters are not affected
+	server.StoreState.Role = "master"
+	if result := network.ExecuteCommand("GET", "test-conn", getArgs); result.Typ != "null" {
+		t.Errorf("Expected GET to be served on a master, got %v", result)
+	}
+}
+
+func TestConfigSetLoglevel(t *testing.T) {
+	server.SetStoreState(shared.State{LogLevel: "notice"})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer logger.SetLevel(logger.Notice)
+
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stdout)
+
+	result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "loglevel"}, {Typ: "bulk", Bulk: "WARNING"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if logger.GetLevel() != logger.Warning {
+		t.Errorf("Expected the warning level, got %v", logger.GetLevel())
+	}
+
+	log := logger.New("test")
+	log.Noticef("dropped")
+	log.Warningf("kept %d", 1)
+	if strings.Contains(buf.String(), "dropped") {
+		t.Errorf("Expected messages below the level to be dropped, got %q", buf.String())
+	}
+	if !regexp.MustCompile(`^\d+ \d{2} \w{3} \d{4} \d{2}:\d{2}:\d{2}\.\d{3} # \[test\] kept 1\n$`).MatchString(buf.String()) {
+		t.Errorf("Unexpected log line %q", buf.String())
+	}
+
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "loglevel"}, {Typ: "bulk", Bulk: "loud"}})
+	if result.Typ != "error" {
+		t.Errorf("Expected an error for an unknown level, got %v", result)
+	}
+}
+
+func TestConfigSetProtoLimits(t *testing.T) {
+	server.SetStoreState(shared.State{ProtoMaxBulkLen: 512 * 1024 * 1024, ProtoMaxMultibulkLen: 1024 * 1024, ProtoMaxNestingDepth: 32, ClientQueryBufferLimit: 1024 * 1024 * 1024})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer protocol.SetLimits(protocol.DefaultLimits)
+
+	result := Config(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "SET"},
+		{Typ: "bulk", Bulk: "proto-max-bulk-len"}, {Typ: "bulk", Bulk: "2mb"},
+		{Typ: "bulk", Bulk: "proto-max-multibulk-len"}, {Typ: "bulk", Bulk: "10"},
+	})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if limits := protocol.GetLimits(); limits.MaxBulkLength != 2*1024*1024 || limits.MaxArrayLength != 10 || limits.MaxDepth != 32 {
+		t.Errorf("Expected the new limits to apply, got %+v", limits)
+	}
+
+	// Limits too low to fix them again are refused
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "proto-max-bulk-len"}, {Typ: "bulk", Bulk: "1kb"}})
+	if result.Typ != "error" || !strings.Contains(result.Str, "proto-max-bulk-len must be 1mb or greater") {
+		t.Errorf("Expected an error for a bulk length below 1mb, got %v", result)
+	}
+	if protocol.GetLimits().MaxBulkLength != 2*1024*1024 || server.StoreState.ProtoMaxBulkLen != 2*1024*1024 {
+		t.Errorf("Expected the previous limit to be kept, got %+v", protocol.GetLimits())
+	}
+
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "client-query-buffer-limit"}, {Typ: "bulk", Bulk: "4mb"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if limits := protocol.GetLimits(); limits.MaxFrameSize != 4*1024*1024 {
+		t.Errorf("Expected the query buffer limit to apply, got %+v", limits)
+	}
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "client-query-buffer-limit"}, {Typ: "bulk", Bulk: "1kb"}})
+	if result.Typ != "error" || !strings.Contains(result.Str, "client-query-buffer-limit must be 1mb or greater") {
+		t.Errorf("Expected an error for a query buffer limit below 1mb, got %v", result)
+	}
+}
+
+func TestConfigSetEncodingLimits(t *testing.T) {
+	server.SetStoreState(shared.State{ListMaxListpackSize: -2, ZsetMaxListpackEntries: 128, ZsetMaxListpackValue: 64})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer shared.SetEncodingLimits(shared.DefaultEncodingLimits)
+
+	result := Config(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "SET"},
+		{Typ: "bulk", Bulk: "list-max-listpack-size"}, {Typ: "bulk", Bulk: "4"},
+		{Typ: "bulk", Bulk: "zset-max-listpack-entries"}, {Typ: "bulk", Bulk: "8"},
+	})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if limits := shared.GetEncodingLimits(); limits.ListMaxListpackSize != 4 || limits.ZsetMaxListpackEntries != 8 || limits.ZsetMaxListpackValue != 64 {
+		t.Errorf("Expected the new limits to apply, got %+v", limits)
+	}
+
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "list-max-listpack-size"}, {Typ: "bulk", Bulk: "0"}})
+	if result.Typ != "error" || !strings.Contains(result.Str, "list-max-listpack-size must not be 0") {
+		t.Errorf("Expected an error for a list-max-listpack-size of 0, got %v", result)
+	}
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "list-max-listpack-size"}, {Typ: "bulk", Bulk: "-6"}})
+	if result.Typ != "error" {
+		t.Errorf("Expected an error for a list-max-listpack-size below -5, got %v", result)
+	}
+	if limits := shared.GetEncodingLimits(); limits.ListMaxListpackSize != 4 {
+		t.Errorf("Expected the previous limit to be kept, got %+v", limits)
+	}
+}
+
 // BenchmarkConfigGet benchmarks the CONFIG GET command
 func BenchmarkConfigGet(b *testing.B) {
 	// Reset store state for clean benchmark
@@ -210,7 +679,7 @@ func BenchmarkConfigGet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Config("bench-conn", args)
+		Config(network.ClientOf("bench-conn"), args)
 	}
 }
 
@@ -234,6 +703,44 @@ func BenchmarkConfigGetMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Config("bench-conn", args)
+		Config(network.ClientOf("bench-conn"), args)
+	}
+}
+
+func TestConfigSetOutputBufferLimits(t *testing.T) {
+	server.SetStoreState(shared.State{ClientOutputBufferLimits: map[string]shared.OutputBufferLimit{
+		"normal": {},
+		"slave":  {Hard: 256 * 1024 * 1024, Soft: 64 * 1024 * 1024, SoftSeconds: 60},
+		"pubsub": {Hard: 32 * 1024 * 1024, Soft: 8 * 1024 * 1024, SoftSeconds: 60},
+	}})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	// Only the classes given change, replica being another name of slave
+	result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "client-output-buffer-limit"}, {Typ: "bulk", Bulk: "pubsub 1mb 512kb 10 replica 0 0 0"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	result = Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "GET"}, {Typ: "bulk", Bulk: "client-output-buffer-limit"}})
+	if expected := "normal 0 0 0 slave 0 0 0 pubsub 1048576 524288 10"; len(result.Array) != 2 || result.Array[1].Bulk != expected {
+		t.Errorf("Expected %q, got %v", expected, result)
+	}
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"pubsub 1mb 512kb", "Wrong number of arguments in buffer limit configuration."},
+		{"master 1mb 512kb 10", "Invalid client class specified in buffer limit configuration."},
+		{"normal 1mb 512kb soon", "Error in hard, soft or soft_seconds setting in buffer limit configuration."},
+		{"normal -1 0 0", "Error in hard, soft or soft_seconds setting in buffer limit configuration."},
+	}
+	for _, tt := range tests {
+		result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "client-output-buffer-limit"}, {Typ: "bulk", Bulk: tt.value}})
+		if result.Typ != "error" || !strings.Contains(result.Str, tt.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.value, tt.expected, result)
+		}
+	}
+	if limit := server.StoreState.ClientOutputBufferLimits["normal"]; limit != (shared.OutputBufferLimit{}) {
+		t.Errorf("Expected the limits to be kept after an error, got %+v", limit)
 	}
 }
@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// configRewriteMarker introduces the parameters CONFIG REWRITE appends to the config file
+const configRewriteMarker = "# Generated by CONFIG REWRITE"
+
+// maxConfigIncludeDepth limits nested include directives, so a file including itself fails
+const maxConfigIncludeDepth = 16
+
+// configLoader holds the state of a config file being loaded, across included files
+type configLoader struct {
+	savePointsSet bool // Whether a save directive was read, the first one replaces the default save points
+}
+
+// LoadConfigFile applies the directives of a redis.conf style config file. Each line holds
+// a parameter name followed by its value, "include <path>" loads another file in place,
+// several save lines add up, and "rename-command <command> <new-name>" renames a command or
+// disables it given an empty name. The path is remembered for CONFIG REWRITE.
+func LoadConfigFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	loader := &configLoader{}
+	if err := loader.load(absPath, 0); err != nil {
+		return err
+	}
+	server.StoreState.ConfigFile = absPath
+	return nil
+}
+
+// load applies the directives of the file at path, depth is the number of enclosing includes
+func (l *configLoader) load(path string, depth int) error {
+	if depth > maxConfigIncludeDepth {
+		return fmt.Errorf("%s: too many nested includes", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		if err := l.apply(line, depth); err != nil {
+			return fmt.Errorf("%s:%d: '%s': %v", path, i+1, strings.TrimSpace(line), err)
+		}
+	}
+	return nil
+}
+
+// apply applies a line of a config file
+func (l *configLoader) apply(line string, depth int) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	args, err := splitConfigArgs(line)
+	if err != nil {
+		return err
+	}
+
+	directive := strings.ToLower(args[0])
+	switch {
+	case directive == "include" && len(args) == 2:
+		return l.load(args[1], depth+1)
+	case directive == "rename-command" && len(args) == 3:
+		return network.RenameCommand(args[1], args[2])
+	case directive == "save" && len(args) >= 2:
+		points, err := parseConfigSavePoints(args[1:])
+		if err != nil {
+			return err
+		}
+		if !l.savePointsSet {
+			server.StoreState.SavePoints = nil
+			l.savePointsSet = true
+		}
+		server.StoreState.SavePoints = append(server.StoreState.SavePoints, points...)
+		return nil
+	}
+
+	param, ok := lookupConfigParam(directive)
+	if !ok || len(args) < 2 || (len(args) > 2 && !param.multiValue) {
+		return fmt.Errorf("Bad directive or wrong number of arguments")
+	}
+	return param.set(strings.Join(args[1:], " "))
+}
+
+// parseConfigSavePoints parses the arguments of a save directive, one or more "<seconds> <changes>"
+// pairs, or save "" which disables automatic saves
+func parseConfigSavePoints(args []string) ([]shared.SavePoint, error) {
+	if len(args) == 1 && args[0] == "" {
+		return nil, nil
+	}
+	points, err := storage.ParseSavePoints(strings.Join(args, " "))
+	if err != nil || len(points) == 0 {
+		return nil, fmt.Errorf("Invalid save parameters")
+	}
+	return points, nil
+}
+
+// splitConfigArgs splits a config file line into arguments separated by spaces.
+// Arguments may be double quoted, with backslash escapes like \n and \x41, or single quoted.
+func splitConfigArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isConfigSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var arg strings.Builder
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i == len(line) {
+					return nil, fmt.Errorf("Unbalanced quotes in configuration line")
+				}
+				c := line[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					case 'x':
+						c = 'x'
+						if i+2 < len(line) {
+							if n, err := strconv.ParseUint(line[i+1:i+3], 16, 8); err == nil {
+								c = byte(n)
+								i += 2
+							}
+						}
+					default:
+						c = line[i]
+					}
+				}
+				arg.WriteByte(c)
+				i++
+			}
+		case '\'':
+			i++
+			for {
+				if i == len(line) {
+					return nil, fmt.Errorf("Unbalanced quotes in configuration line")
+				}
+				if line[i] == '\'' {
+					i++
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+				}
+				arg.WriteByte(line[i])
+				i++
+			}
+		default:
+			for i < len(line) && !isConfigSpace(line[i]) {
+				arg.WriteByte(line[i])
+				i++
+			}
+			args = append(args, arg.String())
+			continue
+		}
+
+		// A closing quote must be followed by a space or the end of the line
+		if i < len(line) && !isConfigSpace(line[i]) {
+			return nil, fmt.Errorf("Unbalanced quotes in configuration line")
+		}
+		args = append(args, arg.String())
+	}
+}
+
+// isConfigSpace reports whether c separates arguments in a config file line
+func isConfigSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// quoteConfigArg returns s as a config file argument, quoted when it is empty or holds
+// spaces, quotes or other characters splitConfigArgs would not read back as they are
+func quoteConfigArg(s string) string {
+	needsQuotes := s == ""
+	for i := 0; i < len(s) && !needsQuotes; i++ {
+		c := s[i]
+		needsQuotes = isConfigSpace(c) || c == '"' || c == '\'' || c == '\\' || c == '#' || c < 0x20 || c >= 0x7f
+	}
+	if !needsQuotes {
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString("\\n")
+		case c == '\r':
+			b.WriteString("\\r")
+		case c == '\t':
+			b.WriteString("\\t")
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// configFileLines returns the config file lines setting the current value of param
+func configFileLines(param *configParam) []string {
+	if param.name == "save" {
+		if len(server.StoreState.SavePoints) == 0 {
+			return []string{`save ""`}
+		}
+		lines := make([]string, 0, len(server.StoreState.SavePoints))
+		for _, point := range server.StoreState.SavePoints {
+			lines = append(lines, fmt.Sprintf("save %d %d", point.Seconds, point.Changes))
+		}
+		return lines
+	}
+
+	value := param.get()
+	if param.multiValue && value != "" {
+		fields := strings.Fields(value)
+		for i, field := range fields {
+			fields[i] = quoteConfigArg(field)
+		}
+		return []string{param.name + " " + strings.Join(fields, " ")}
+	}
+	return []string{param.name + " " + quoteConfigArg(value)}
+}
+
+// rewriteConfigFile writes the current configuration back to the config file at path.
+// Lines setting a parameter are updated in place and repeated ones are dropped. Parameters
+// changed from their default that the file does not set are appended, after a marker line
+// written by the first rewrite.
+// Comments, includes and unknown lines are kept as they are.
+func rewriteConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	if content := strings.TrimRight(string(data), "\n"); content != "" {
+		lines = strings.Split(content, "\n")
+	}
+
+	var rewritten []string
+	written := make(map[string]bool)
+	markerWritten := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == configRewriteMarker {
+			markerWritten = true
+		}
+		args, err := splitConfigArgs(trimmed)
+		if err != nil || len(args) == 0 || strings.HasPrefix(trimmed, "#") {
+			rewritten = append(rewritten, line)
+			continue
+		}
+		param, ok := lookupConfigParam(args[0])
+		if !ok {
+			rewritten = append(rewritten, line)
+			continue
+		}
+		if !written[param.name] {
+			rewritten = append(rewritten, configFileLines(param)...)
+			written[param.name] = true
+		}
+	}
+
+	for _, param := range configParams {
+		if written[param.name] || param.get() == param.defaultValue {
+			continue
+		}
+		if !markerWritten {
+			rewritten = append(rewritten, configRewriteMarker)
+			markerWritten = true
+		}
+		rewritten = append(rewritten, configFileLines(param)...)
+	}
+
+	// The new content replaces the file at once, so a crash never leaves it half written
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(rewritten, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// writeConfigFile writes a config file with the given lines in dir and returns its path
+func writeConfigFile(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestSplitConfigArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "Plain arguments", line: "save 900 1", expected: []string{"save", "900", "1"}},
+		{name: "Extra spaces", line: "  port \t 6380  ", expected: []string{"port", "6380"}},
+		{name: "Double quotes", line: `dir "/tmp/my data"`, expected: []string{"dir", "/tmp/my data"}},
+		{name: "Empty quotes", line: `save ""`, expected: []string{"save", ""}},
+		{name: "Escapes", line: `name "a\"b\\c\n\x41"`, expected: []string{"name", "a\"b\\c\nA"}},
+		{name: "Single quotes", line: `name 'it\'s'`, expected: []string{"name", "it's"}},
+		{name: "Unbalanced double quotes", line: `dir "/tmp`, wantErr: true},
+		{name: "Unbalanced single quotes", line: `dir '/tmp`, wantErr: true},
+		{name: "Quote followed by text", line: `dir "/tmp"x`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := splitConfigArgs(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, args)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	server.SetStoreState(shared.State{
+		Role:        "master",
+		Replicas:    make(map[string]net.Conn),
+		SavePoints:  []shared.SavePoint{{Seconds: 3600, Changes: 1}},
+		AppendFsync: "everysec",
+	})
+
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "my data")
+	if err := os.Mkdir(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create data directory: %v", err)
+	}
+	included := writeConfigFile(t, dir, "included.conf",
+		"appendfsync always",
+	)
+	path := writeConfigFile(t, dir, "redis.conf",
+		"# A comment",
+		"",
+		"port 6380",
+		`dir "`+dataDir+`"`,
+		"dbfilename dump.rdb",
+		"save 900 1",
+		"save 300 10 60 10000",
+		"replicaof localhost 6379",
+		"MAXMEMORY 1mb",
+		"appendonly yes",
+		"include "+included,
+	)
+
+	if err := LoadConfigFile(path); err != nil {
+		t.Fatalf("Failed to load config file: %v", err)
+	}
+
+	state := server.StoreState
+	if state.ConfigFile != path {
+		t.Errorf("Expected config file %q, got %q", path, state.ConfigFile)
+	}
+	if state.Port != "6380" || state.ConfigDir != dataDir || state.ConfigDbfilename != "dump.rdb" {
+		t.Errorf("Unexpected port, dir or dbfilename: %q %q %q", state.Port, state.ConfigDir, state.ConfigDbfilename)
+	}
+	expectedPoints := []shared.SavePoint{{Seconds: 900, Changes: 1}, {Seconds: 300, Changes: 10}, {Seconds: 60, Changes: 10000}}
+	if !reflect.DeepEqual(state.SavePoints, expectedPoints) {
+		t.Errorf("Expected save points %v, got %v", expectedPoints, state.SavePoints)
+	}
+	if state.ReplicaOf != "localhost 6379" {
+		t.Errorf("Expected replicaof %q, got %q", "localhost 6379", state.ReplicaOf)
+	}
+	if state.MaxMemory != 1024*1024 {
+		t.Errorf("Expected maxmemory 1048576, got %d", state.MaxMemory)
+	}
+	if !state.AppendOnly || state.AppendFsync != "always" {
+		t.Errorf("Expected appendonly with appendfsync always, got %v %q", state.AppendOnly, state.AppendFsync)
+	}
+}
+
+func TestLoadConfigFileDisableSave(t *testing.T) {
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	server.SetStoreState(shared.State{
+		Role:       "master",
+		Replicas:   make(map[string]net.Conn),
+		SavePoints: []shared.SavePoint{{Seconds: 3600, Changes: 1}},
+	})
+
+	path := writeConfigFile(t, t.TempDir(), "redis.conf", `save ""`)
+	if err := LoadConfigFile(path); err != nil {
+		t.Fatalf("Failed to load config file: %v", err)
+	}
+	if len(server.StoreState.SavePoints) != 0 {
+		t.Errorf("Expected no save points, got %v", server.StoreState.SavePoints)
+	}
+}
+
+func TestLoadConfigFileRenameCommand(t *testing.T) {
+	initCommandHandlers()
+	defer network.ResetCommandRenames()
+
+	path := writeConfigFile(t, t.TempDir(), "redis.conf", "rename-command SET put", `rename-command TYPE ""`)
+	if err := LoadConfigFile(path); err != nil {
+		t.Fatalf("Failed to load config file: %v", err)
+	}
+	if command, ok := network.ResolveCommand("put"); !ok || command != "SET" {
+		t.Errorf("Expected put to run SET, got %q %v", command, ok)
+	}
+	if _, ok := network.ResolveCommand("set"); ok {
+		t.Errorf("Expected SET to be hidden")
+	}
+	if _, ok := network.ResolveCommand("type"); ok {
+		t.Errorf("Expected TYPE to be disabled")
+	}
+
+	path = writeConfigFile(t, t.TempDir(), "redis.conf", "rename-command NOSUCH other")
+	if err := LoadConfigFile(path); err == nil || !strings.Contains(err.Error(), "No such command in rename-command") {
+		t.Errorf("Expected an error renaming an unknown command, got %v", err)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+	}{
+		{name: "Unknown directive", lines: []string{"port 6380", "unknown yes"}, expected: ":2: 'unknown yes': Bad directive or wrong number of arguments"},
+		{name: "Missing value", lines: []string{"port"}, expected: "Bad directive or wrong number of arguments"},
+		{name: "Too many values", lines: []string{"port 6380 6381"}, expected: "Bad directive or wrong number of arguments"},
+		{name: "Invalid value", lines: []string{"appendonly maybe"}, expected: "argument must be 'yes' or 'no'"},
+		{name: "Invalid save", lines: []string{"save 900"}, expected: "Invalid save parameters"},
+		{name: "Unbalanced quotes", lines: []string{`dir "/tmp`}, expected: "Unbalanced quotes in configuration line"},
+		{name: "Missing include", lines: []string{"include " + filepath.Join(dir, "missing.conf")}, expected: "no such file or directory"},
+		{name: "Recursive include", lines: []string{"include " + filepath.Join(dir, "Recursive-include.conf")}, expected: "too many nested includes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+			path := writeConfigFile(t, dir, strings.ReplaceAll(tt.name, " ", "-")+".conf", tt.lines...)
+
+			err := LoadConfigFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+			if server.StoreState.ConfigFile != "" {
+				t.Errorf("Expected the config file not to be remembered, got %q", server.StoreState.ConfigFile)
+			}
+		})
+	}
+}
+
+func TestConfigRewrite(t *testing.T) {
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	// Start from the defaults so only the parameters changed below differ from them
+	defaults := shared.State{Role: "master", Replicas: make(map[string]net.Conn)}
+	server.SetStoreState(defaults)
+	for _, param := range configParams {
+		if param.name == "dir" {
+			// The default directory is only created by the first save
+			server.StoreState.ConfigDir = param.defaultValue
+			continue
+		}
+		if err := param.set(param.defaultValue); err != nil {
+			t.Fatalf("Failed to reset %s: %v", param.name, err)
+		}
+	}
+
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "redis.conf",
+		"# Persistence",
+		"appendfsync everysec",
+		"save 900 1",
+		"save 300 10",
+		"include "+filepath.Join(dir, "included.conf"),
+		"rdbcompression yes",
+		"rdbcompression no",
+	)
+	writeConfigFile(t, dir, "included.conf")
+	if err := LoadConfigFile(path); err != nil {
+		t.Fatalf("Failed to load config file: %v", err)
+	}
+
+	set := []shared.Value{
+		{Typ: "bulk", Bulk: "SET"},
+		{Typ: "bulk", Bulk: "appendfsync"}, {Typ: "bulk", Bulk: "always"},
+		{Typ: "bulk", Bulk: "save"}, {Typ: "bulk", Bulk: "60 100"},
+		{Typ: "bulk", Bulk: "maxmemory"}, {Typ: "bulk", Bulk: "2mb"},
+		{Typ: "bulk", Bulk: "dbfilename"}, {Typ: "bulk", Bulk: "my dump.rdb"},
+	}
+	if result := Config(network.ClientOf("test-conn"), set); result.Str != "OK" {
+		t.Fatalf("Expected CONFIG SET to succeed, got %v", result)
+	}
+	if result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "REWRITE"}}); result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected CONFIG REWRITE to succeed, got %v", result)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	expected := strings.Join([]string{
+		"# Persistence",
+		"appendfsync always",
+		"save 60 100",
+		"include " + filepath.Join(dir, "included.conf"),
+		"rdbcompression no",
+		configRewriteMarker,
+		`dbfilename "my dump.rdb"`,
+		"maxmemory 2097152",
+	}, "\n") + "\n"
+	if string(data) != expected {
+		t.Errorf("Expected config file:\n%s\ngot:\n%s", expected, data)
+	}
+
+	// Rewriting again keeps the file as it is
+	Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "REWRITE"}})
+	if again, _ := os.ReadFile(path); string(again) != expected {
+		t.Errorf("Expected a second rewrite to keep the file, got:\n%s", again)
+	}
+
+	// Loading the rewritten file restores the configuration
+	server.SetStoreState(defaults)
+	if err := LoadConfigFile(path); err != nil {
+		t.Fatalf("Failed to load rewritten config file: %v", err)
+	}
+	state := server.StoreState
+	if state.AppendFsync != "always" || state.ConfigDbfilename != "my dump.rdb" || state.MaxMemory != 2097152 || state.RDBCompression {
+		t.Errorf("Unexpected configuration after reload: %+v", state)
+	}
+	if !reflect.DeepEqual(state.SavePoints, []shared.SavePoint{{Seconds: 60, Changes: 100}}) {
+		t.Errorf("Expected save points to be restored, got %v", state.SavePoints)
+	}
+}
+
+func TestConfigRewriteWithoutConfigFile(t *testing.T) {
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "REWRITE"}})
+	if result.Typ != "error" || result.Str != "ERR The server is running without a config file" {
+		t.Errorf("Expected an error without a config file, got %v", result)
+	}
+}
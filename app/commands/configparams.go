@@ -0,0 +1,442 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// configParam is a configuration parameter exposed through CONFIG GET and CONFIG SET
+type configParam struct {
+	name         string                   // Lowercase parameter name
+	get          func() string            // Returns the current value
+	set          func(value string) error // Validates and stores a new value
+	apply        func() error             // Optional callback run after the value changed
+	immutable    bool                     // Whether the value can only be set at startup
+	multiValue   bool                     // Whether the value is several space separated arguments
+	defaultValue string                   // Value the server starts with before any option is applied
+}
+
+// configParams is the registry of configuration parameters, in CONFIG GET output order
+var configParams = []*configParam{
+	stringConfig("dir", &server.StoreState.ConfigDir, validateConfigDir),
+	stringConfig("dbfilename", &server.StoreState.ConfigDbfilename, validateConfigFilename),
+	immutable(stringConfig("port", &server.StoreState.Port, validateConfigPort)),
+	multiValue(immutable(stringConfig("replicaof", &server.StoreState.ReplicaOf, nil))),
+	multiValue(immutable(stringConfig("bind", &server.StoreState.Bind, nil))),
+	boolConfig("protected-mode", &server.StoreState.ProtectedMode),
+	intConfig("min-replicas-to-write", &server.StoreState.MinReplicasToWrite, 0, 1<<31-1),
+	intConfig("min-replicas-max-lag", &server.StoreState.MinReplicasMaxLag, 0, 1<<31-1),
+	boolConfig("replica-serve-stale-data", &server.StoreState.ReplicaServeStaleData),
+	boolConfig("repl-diskless-sync", &server.StoreState.ReplDisklessSync),
+	intConfig("repl-diskless-sync-delay", &server.StoreState.ReplDisklessSyncDelay, 0, 1<<31-1),
+	boolConfig("rdbcompression", &server.StoreState.RDBCompression),
+	boolConfig("rdbchecksum", &server.StoreState.RDBChecksum),
+	saveConfig(),
+	withApply(boolConfig("appendonly", &server.StoreState.AppendOnly), applyAppendOnly),
+	immutable(stringConfig("appendfilename", &server.StoreState.AppendFilename, validateConfigFilename)),
+	immutable(stringConfig("appenddirname", &server.StoreState.AppendDirname, validateConfigFilename)),
+	enumConfig("appendfsync", &server.StoreState.AppendFsync, storage.AppendFsyncAlways, storage.AppendFsyncEverysec, storage.AppendFsyncNo),
+	intConfig("auto-aof-rewrite-percentage", &server.StoreState.AutoAOFRewritePercentage, 0, 1<<31-1),
+	memoryConfig("auto-aof-rewrite-min-size", &server.StoreState.AutoAOFRewriteMinSize),
+	boolConfig("aof-use-rdb-preamble", &server.StoreState.AOFUseRDBPreamble),
+	boolConfig("aof-timestamp-enabled", &server.StoreState.AOFTimestampEnabled),
+	intConfig("shutdown-timeout", &server.StoreState.ShutdownTimeout, 0, 1<<31-1),
+	intConfig("busy-reply-threshold", &server.StoreState.BusyReplyThreshold, 0, 1<<31-1),
+	intConfig("lua-time-limit", &server.StoreState.BusyReplyThreshold, 0, 1<<31-1),
+	memoryConfig("maxmemory", &server.StoreState.MaxMemory),
+	intConfig("slowlog-log-slower-than", &server.StoreState.SlowlogLogSlowerThan, -1, 1<<31-1),
+	intConfig("slowlog-max-len", &server.StoreState.SlowlogMaxLen, 0, 1<<31-1),
+	intConfig("latency-monitor-threshold", &server.StoreState.LatencyMonitorThreshold, 0, 1<<31-1),
+	intConfig("range-reply-max-elements", &server.StoreState.RangeReplyMaxElements, 0, 1<<31-1),
+	boolConfig("latency-tracking", &server.StoreState.LatencyTracking),
+	multiValue(percentilesConfig("latency-tracking-info-percentiles", &server.StoreState.LatencyTrackingInfoPercentiles)),
+	intConfig("timeout", &server.StoreState.Timeout, 0, 1<<31-1),
+	intConfig("tcp-keepalive", &server.StoreState.TCPKeepalive, 0, 1<<31-1),
+	immutable(intConfig("metrics-port", &server.StoreState.MetricsPort, 0, 65535)),
+	immutable(intConfig("debug-port", &server.StoreState.DebugPort, 0, 65535)),
+	immutable(intConfig("http-port", &server.StoreState.HTTPPort, 0, 65535)),
+	immutable(intConfig("memcached-port", &server.StoreState.MemcachedPort, 0, 65535)),
+	withApply(enumConfig("loglevel", &server.StoreState.LogLevel, "debug", "verbose", "notice", "warning"), applyLogLevel),
+	immutable(stringConfig("logfile", &server.StoreState.LogFile, nil)),
+	withApply(stringConfig("requirepass", &server.StoreState.RequirePass, nil), applyRequirePass),
+	immutable(stringConfig("aclfile", &server.StoreState.ACLFile, nil)),
+	withApply(memoryConfig("proto-max-bulk-len", &server.StoreState.ProtoMaxBulkLen), ApplyProtoLimits),
+	withApply(intConfig("proto-max-multibulk-len", &server.StoreState.ProtoMaxMultibulkLen, 1, 1<<31-1), ApplyProtoLimits),
+	withApply(intConfig("proto-max-nesting-depth", &server.StoreState.ProtoMaxNestingDepth, 1, 1024), ApplyProtoLimits),
+	withApply(memoryConfig("client-query-buffer-limit", &server.StoreState.ClientQueryBufferLimit), ApplyProtoLimits),
+	multiValue(outputBufferLimitsConfig()),
+	intConfig("client-rate-limit-commands", &server.StoreState.ClientRateLimitCommands, 0, 1<<31-1),
+	memoryConfig("client-rate-limit-bytes", &server.StoreState.ClientRateLimitBytes),
+	withApply(intConfig("rate-limit-commands", &server.StoreState.RateLimitCommands, 0, 1<<31-1), network.ResetRateLimits),
+	withApply(memoryConfig("rate-limit-bytes", &server.StoreState.RateLimitBytes), network.ResetRateLimits),
+	withApply(stringConfig("audit-log-file", &server.StoreState.AuditLogFile, nil), applyAuditLogFile),
+	memoryConfig("audit-log-max-size", &server.StoreState.AuditLogMaxSize),
+	intConfig("audit-log-max-files", &server.StoreState.AuditLogMaxFiles, 0, 1000),
+	withApply(intConfig("list-max-listpack-size", &server.StoreState.ListMaxListpackSize, -5, 1<<31-1), ApplyEncodingLimits),
+	withApply(intConfig("zset-max-listpack-entries", &server.StoreState.ZsetMaxListpackEntries, 0, 1<<31-1), ApplyEncodingLimits),
+	withApply(intConfig("zset-max-listpack-value", &server.StoreState.ZsetMaxListpackValue, 0, 1<<31-1), ApplyEncodingLimits),
+	immutable(boolConfig("single-writer", &server.StoreState.SingleWriter)),
+	immutable(boolConfig("cluster-enabled", &server.StoreState.ClusterEnabled)),
+	immutable(stringConfig("cluster-announce-ip", &server.StoreState.ClusterAnnounceIP, nil)),
+}
+
+func init() {
+	// The defaults are the values of server.StoreState before command line options are parsed
+	for _, param := range configParams {
+		param.defaultValue = param.get()
+	}
+}
+
+// lookupConfigParam returns the parameter with the given name, case-insensitively
+func lookupConfigParam(name string) (*configParam, bool) {
+	name = strings.ToLower(name)
+	for _, param := range configParams {
+		if param.name == name {
+			return param, true
+		}
+	}
+	return nil, false
+}
+
+// stringConfig returns a parameter holding a string, validate may be nil
+func stringConfig(name string, value *string, validate func(string) error) *configParam {
+	return &configParam{
+		name: name,
+		get:  func() string { return *value },
+		set: func(s string) error {
+			if validate != nil {
+				if err := validate(s); err != nil {
+					return err
+				}
+			}
+			*value = s
+			return nil
+		},
+	}
+}
+
+// boolConfig returns a parameter holding a yes/no flag
+func boolConfig(name string, value *bool) *configParam {
+	return &configParam{
+		name: name,
+		get: func() string {
+			if *value {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(s string) error {
+			switch strings.ToLower(s) {
+			case "yes":
+				*value = true
+			case "no":
+				*value = false
+			default:
+				return fmt.Errorf("argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+	}
+}
+
+// intConfig returns a parameter holding an integer between min and max inclusive
+func intConfig(name string, value *int, min, max int) *configParam {
+	return &configParam{
+		name: name,
+		get:  func() string { return strconv.Itoa(*value) },
+		set: func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("argument couldn't be parsed into an integer")
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*value = n
+			return nil
+		},
+	}
+}
+
+// memoryConfig returns a parameter holding a number of bytes, set with an optional unit like 100mb
+func memoryConfig(name string, value *int64) *configParam {
+	return &configParam{
+		name: name,
+		get:  func() string { return strconv.FormatInt(*value, 10) },
+		set: func(s string) error {
+			n, err := parseMemoryValue(s)
+			if err != nil {
+				return err
+			}
+			*value = n
+			return nil
+		},
+	}
+}
+
+// enumConfig returns a parameter holding one of the given values
+func enumConfig(name string, value *string, values ...string) *configParam {
+	return &configParam{
+		name: name,
+		get:  func() string { return *value },
+		set: func(s string) error {
+			for _, v := range values {
+				if strings.EqualFold(s, v) {
+					*value = v
+					return nil
+				}
+			}
+			return fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(values, ", "))
+		},
+	}
+}
+
+// immutable marks a parameter that is only set at startup, CONFIG SET refuses to change it
+func immutable(param *configParam) *configParam {
+	param.immutable = true
+	return param
+}
+
+// multiValue marks a parameter whose value is several arguments, like "replicaof host port"
+func multiValue(param *configParam) *configParam {
+	param.multiValue = true
+	return param
+}
+
+// saveConfig returns the parameter holding the save points
+func saveConfig() *configParam {
+	return &configParam{
+		name: "save",
+		get:  func() string { return storage.FormatSavePoints(server.StoreState.SavePoints) },
+		set: func(s string) error {
+			points, err := storage.ParseSavePoints(s)
+			if err != nil {
+				return err
+			}
+			server.StoreState.SavePoints = points
+			return nil
+		},
+	}
+}
+
+// outputBufferClasses are the client classes of client-output-buffer-limit, in CONFIG GET order
+var outputBufferClasses = []string{"normal", "slave", "pubsub"}
+
+// outputBufferLimitsConfig returns the parameter holding the output buffer limits of the
+// client classes, as "<class> <hard> <soft> <soft seconds>" for each
+func outputBufferLimitsConfig() *configParam {
+	return &configParam{
+		name: "client-output-buffer-limit",
+		get: func() string {
+			var fields []string
+			for _, class := range outputBufferClasses {
+				limit := server.StoreState.ClientOutputBufferLimits[class]
+				fields = append(fields, class, strconv.FormatInt(limit.Hard, 10), strconv.FormatInt(limit.Soft, 10), strconv.Itoa(limit.SoftSeconds))
+			}
+			return strings.Join(fields, " ")
+		},
+		set: func(s string) error {
+			limits, err := ParseOutputBufferLimits(s)
+			if err != nil {
+				return err
+			}
+			server.StoreState.ClientOutputBufferLimits = limits
+			return nil
+		},
+	}
+}
+
+// ParseOutputBufferLimits parses "<class> <hard> <soft> <soft seconds>" groups, the limits
+// given with an optional unit like 32mb, and returns the current limits with the classes
+// given replaced. replica is accepted as another name of the slave class.
+func ParseOutputBufferLimits(value string) (map[string]shared.OutputBufferLimit, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields)%4 != 0 {
+		return nil, fmt.Errorf("Wrong number of arguments in buffer limit configuration.")
+	}
+
+	limits := make(map[string]shared.OutputBufferLimit, len(outputBufferClasses))
+	for class, limit := range server.StoreState.ClientOutputBufferLimits {
+		limits[class] = limit
+	}
+	for i := 0; i < len(fields); i += 4 {
+		class := strings.ToLower(fields[i])
+		if class == "replica" {
+			class = "slave"
+		}
+		if class != "normal" && class != "slave" && class != "pubsub" {
+			return nil, fmt.Errorf("Invalid client class specified in buffer limit configuration.")
+		}
+		hard, hardErr := parseMemoryValue(fields[i+1])
+		soft, softErr := parseMemoryValue(fields[i+2])
+		seconds, secondsErr := strconv.Atoi(fields[i+3])
+		if hardErr != nil || softErr != nil || secondsErr != nil || seconds < 0 {
+			return nil, fmt.Errorf("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+		}
+		limits[class] = shared.OutputBufferLimit{Hard: hard, Soft: soft, SoftSeconds: seconds}
+	}
+	return limits, nil
+}
+
+// percentilesConfig returns a parameter holding space separated percentiles between 0 and 100
+func percentilesConfig(name string, value *[]float64) *configParam {
+	return &configParam{
+		name: name,
+		get: func() string {
+			fields := make([]string, len(*value))
+			for i, percentile := range *value {
+				fields[i] = strconv.FormatFloat(percentile, 'f', -1, 64)
+			}
+			return strings.Join(fields, " ")
+		},
+		set: func(s string) error {
+			fields := strings.Fields(s)
+			percentiles := make([]float64, len(fields))
+			for i, field := range fields {
+				percentile, err := strconv.ParseFloat(field, 64)
+				if err != nil || percentile < 0 || percentile > 100 {
+					return fmt.Errorf("argument(s) must be a percentile between 0 and 100")
+				}
+				percentiles[i] = percentile
+			}
+			*value = percentiles
+			return nil
+		},
+	}
+}
+
+// withApply sets the callback run after the parameter changed
+func withApply(param *configParam, apply func() error) *configParam {
+	param.apply = apply
+	return param
+}
+
+// validateConfigDir checks that dir is an existing directory
+func validateConfigDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
+
+// validateConfigFilename checks that name is a file name and not a path
+func validateConfigFilename(name string) error {
+	if name == "" || filepath.Base(name) != name {
+		return fmt.Errorf("can't be a path, just a filename")
+	}
+	return nil
+}
+
+// validateConfigPort checks that port is a valid TCP port number
+func validateConfigPort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("argument must be between 0 and 65535 inclusive")
+	}
+	return nil
+}
+
+// applyAppendOnly opens or closes the append only file after appendonly changed
+func applyAppendOnly() error {
+	if server.StoreState.AppendOnly {
+		return storage.StartAppendOnly()
+	}
+	return storage.CloseAppendOnlyFile()
+}
+
+// applyLogLevel sets the minimum level of the logged messages after loglevel changed
+func applyLogLevel() error {
+	level, err := logger.ParseLevel(server.StoreState.LogLevel)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(level)
+	return nil
+}
+
+// applyRequirePass makes requirepass the only password of the default user
+func applyRequirePass() error {
+	network.ACLSetDefaultPassword(server.StoreState.RequirePass)
+	return nil
+}
+
+// applyAuditLogFile opens the audit log at its new path, or closes it when the path is empty
+func applyAuditLogFile() error {
+	return network.SetAuditLogFile(server.StoreState.AuditLogFile)
+}
+
+// ApplyProtoLimits makes the readers of every connection apply the proto-max-* limits and
+// client-query-buffer-limit. Commands of at least 1mb must be accepted, so a client can
+// still fix the limits.
+func ApplyProtoLimits() error {
+	if server.StoreState.ProtoMaxBulkLen < 1024*1024 {
+		return fmt.Errorf("proto-max-bulk-len must be 1mb or greater")
+	}
+	if server.StoreState.ClientQueryBufferLimit < 1024*1024 {
+		return fmt.Errorf("client-query-buffer-limit must be 1mb or greater")
+	}
+	protocol.SetLimits(protocol.Limits{
+		MaxBulkLength:  server.StoreState.ProtoMaxBulkLen,
+		MaxArrayLength: server.StoreState.ProtoMaxMultibulkLen,
+		MaxDepth:       server.StoreState.ProtoMaxNestingDepth,
+		MaxFrameSize:   server.StoreState.ClientQueryBufferLimit,
+	})
+	return nil
+}
+
+// ApplyEncodingLimits makes the collections written from now on follow the *-max-listpack-*
+// limits. Collections already converted to their full structure stay converted.
+func ApplyEncodingLimits() error {
+	if server.StoreState.ListMaxListpackSize == 0 {
+		return fmt.Errorf("list-max-listpack-size must not be 0")
+	}
+	shared.SetEncodingLimits(shared.EncodingLimits{
+		ListMaxListpackSize:    server.StoreState.ListMaxListpackSize,
+		ZsetMaxListpackEntries: server.StoreState.ZsetMaxListpackEntries,
+		ZsetMaxListpackValue:   server.StoreState.ZsetMaxListpackValue,
+	})
+	return nil
+}
+
+// parseMemoryValue parses a number of bytes with an optional unit: k, kb, m, mb, g or gb.
+// k, m and g are powers of 1000, kb, mb and gb powers of 1024.
+func parseMemoryValue(s string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"kb", 1024}, {"mb", 1024 * 1024}, {"gb", 1024 * 1024 * 1024},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	}
+
+	lower := strings.ToLower(s)
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = strings.TrimSuffix(lower, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("argument must be a memory value")
+	}
+	return n * multiplier, nil
+}
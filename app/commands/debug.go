@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// Debug handles the DEBUG command
+// Usage: DEBUG RELOAD | DEBUG SLEEP seconds | DEBUG OBJECT key | DEBUG SET-ACTIVE-EXPIRE 0|1 |
+// DEBUG JMAP | DEBUG STRINGMATCH-LEN | DEBUG GOROUTINES | DEBUG HEAP | DEBUG EXPORT path |
+// DEBUG DIGEST | DEBUG DIGEST-VALUE key [key ...]
+// Returns: The result of the subcommand, or error if the subcommand is unknown.
+//
+// Examples:
+//
+//	DEBUG RELOAD                // Saves the dataset to the RDB file and loads it back
+//	DEBUG SLEEP 0.5             // Waits half a second before replying
+//	DEBUG OBJECT mykey          // Returns the encoding and serialized length of mykey
+//	DEBUG SET-ACTIVE-EXPIRE 0   // Stops the expire cycle, expired keys are removed on access only
+//	DEBUG GOROUTINES            // Returns the stack of every goroutine
+//	DEBUG HEAP                  // Returns the heap profile, in the text format of go tool pprof
+//	DEBUG EXPORT keys.json      // Writes every key with its type, expiration and value to dir/keys.json
+//	DEBUG DIGEST                // Returns the digest of the dataset, the same on a master and its replicas
+//	DEBUG DIGEST-VALUE k1 k2    // Returns the digests of the values of k1 and k2
+func Debug(client *network.Client, args []shared.Value) shared.Value {
+	subcommand := strings.ToUpper(args[0].Bulk)
+
+	switch subcommand {
+	case "RELOAD":
+		return debugReload(args[1:])
+	case "SLEEP":
+		return debugSleep(args[1:])
+	case "OBJECT":
+		return debugObject(args[1:])
+	case "SET-ACTIVE-EXPIRE":
+		return debugSetActiveExpire(args[1:])
+	case "JMAP":
+		return debugJmap(args[1:])
+	case "STRINGMATCH-LEN":
+		return debugStringmatchLen(args[1:])
+	case "GOROUTINES":
+		return debugProfile("goroutine", 2)
+	case "HEAP":
+		return debugProfile("heap", 1)
+	case "EXPORT":
+		return debugExport(args[1:])
+	case "DIGEST":
+		return debugDigest(args[1:])
+	case "DIGEST-VALUE":
+		return debugDigestValue(args[1:])
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'debug' command")
+	}
+}
+
+// debugReload handles the DEBUG RELOAD subcommand.
+// Serializing and reloading the whole dataset checks that everything survives a round trip through the RDB format.
+func debugReload(args []shared.Value) shared.Value {
+	if err := storage.Reload(); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// debugExport handles the DEBUG EXPORT subcommand, writing the dataset as JSON to a file that
+// can be read or compared with the export of another server
+func debugExport(args []shared.Value) shared.Value {
+	if err := storage.ExportJSONFile(args[0].Bulk); err != nil {
+		return createErrorResponse("ERR " + err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// debugDigest handles the DEBUG DIGEST subcommand, returning a digest of the keys, values and
+// expirations of the dataset that doesn't depend on the order they were written in
+func debugDigest(args []shared.Value) shared.Value {
+	return shared.Value{Typ: "string", Str: storage.Digest()}
+}
+
+// debugDigestValue handles the DEBUG DIGEST-VALUE subcommand, returning the digest of the value
+// of each key, or a digest of zeros for a missing key
+func debugDigestValue(args []shared.Value) shared.Value {
+	digests := make([]shared.Value, len(args))
+	for i, arg := range args {
+		digest := strings.Repeat("0", 40)
+		entry, exists := server.Memory.Get(arg.Bulk)
+		if exists && (entry.Expires == 0 || time.Now().UnixMilli() <= entry.Expires) {
+			digest = storage.ValueDigest(entry)
+		}
+		digests[i] = shared.Value{Typ: "string", Str: digest}
+	}
+	return shared.Value{Typ: "array", Array: digests}
+}
+
+// debugSleep handles the DEBUG SLEEP subcommand, holding the connection for the given seconds
+func debugSleep(args []shared.Value) shared.Value {
+	seconds, err := strconv.ParseFloat(args[0].Bulk, 64)
+	if err != nil || seconds < 0 {
+		return shared.ErrNotFloat()
+	}
+	time.Sleep(time.Duration(seconds * float64(time.Second)))
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// debugObject handles the DEBUG OBJECT subcommand, describing how the value of a key is stored
+func debugObject(args []shared.Value) shared.Value {
+	entry, exists := server.Memory.Get(args[0].Bulk)
+	if !exists || (entry.Expires > 0 && time.Now().UnixMilli() > entry.Expires) {
+		return shared.ErrNoSuchKey()
+	}
+	return shared.Value{Typ: "string", Str: fmt.Sprintf("refcount:1 encoding:%s serializedlength:%d",
+		storage.ObjectEncoding(entry), storage.SerializedLength(entry))}
+}
+
+// debugSetActiveExpire handles the DEBUG SET-ACTIVE-EXPIRE subcommand, 0 stops the expire cycle and 1 restarts it
+func debugSetActiveExpire(args []shared.Value) shared.Value {
+	switch args[0].Bulk {
+	case "0":
+		server.SetActiveExpire(false)
+	case "1":
+		server.SetActiveExpire(true)
+	default:
+		return shared.ErrSyntax()
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// debugJmap handles the DEBUG JMAP subcommand, returning the heap statistics of the Go runtime
+func debugJmap(args []shared.Value) shared.Value {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return shared.Value{Typ: "bulk", Bulk: fmt.Sprintf(
+		"heap_alloc:%d\r\nheap_sys:%d\r\nheap_idle:%d\r\nheap_inuse:%d\r\nheap_objects:%d\r\nnum_gc:%d\r\n",
+		stats.HeapAlloc, stats.HeapSys, stats.HeapIdle, stats.HeapInuse, stats.HeapObjects, stats.NumGC)}
+}
+
+// debugProfile handles the DEBUG GOROUTINES and DEBUG HEAP subcommands, returning a runtime
+// profile as text, like the profiling server serves it with the given debug level, so a
+// server can be profiled without enabling debug-port
+func debugProfile(profile string, level int) shared.Value {
+	var buf bytes.Buffer
+	if err := pprof.Lookup(profile).WriteTo(&buf, level); err != nil {
+		return createErrorResponse("ERR " + err.Error())
+	}
+	return shared.Value{Typ: "bulk", Bulk: buf.String()}
+}
+
+// debugStringmatchLen handles the DEBUG STRINGMATCH-LEN subcommand. It matches random patterns
+// against random strings with the matcher KEYS uses, to check that no input makes it fail.
+func debugStringmatchLen(args []shared.Value) shared.Value {
+	const alphabet = "*?[]^-\\ab"
+	random := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rand.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	for i := 0; i < 100000; i++ {
+		filepath.Match(random(rand.Intn(16)), random(rand.Intn(32)))
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
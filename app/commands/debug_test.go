@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestDebug(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []shared.Value
+		expectError string
+	}{
+		{
+			name:        "DEBUG without subcommand",
+			args:        []shared.Value{},
+			expectError: "ERR wrong number of arguments for 'debug' command",
+		},
+		{
+			name:        "DEBUG unknown subcommand",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "nope"}},
+			expectError: "ERR unknown subcommand for 'debug' command",
+		},
+		{
+			name:        "DEBUG RELOAD with arguments",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "reload"}, {Typ: "bulk", Bulk: "extra"}},
+			expectError: "ERR wrong number of arguments for 'debug|reload' command",
+		},
+		{
+			name:        "DEBUG SLEEP with an invalid duration",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "sleep"}, {Typ: "bulk", Bulk: "soon"}},
+			expectError: "ERR value is not a valid float",
+		},
+		{
+			name:        "DEBUG OBJECT on a missing key",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "object"}, {Typ: "bulk", Bulk: "missing"}},
+			expectError: "ERR no such key",
+		},
+		{
+			name:        "DEBUG SET-ACTIVE-EXPIRE with an invalid value",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "set-active-expire"}, {Typ: "bulk", Bulk: "yes"}},
+			expectError: "ERR syntax error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("DEBUG", Debug, "test-conn", tt.args)
+			if result.Typ != "error" || result.Str != tt.expectError {
+				t.Errorf("Expected error %q, got %v", tt.expectError, result)
+			}
+		})
+	}
+}
+
+func TestDebugReload(t *testing.T) {
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        t.TempDir(),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+
+	ss := shared.NewSortedSet()
+	ss.Add("a", 1.5)
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+	server.Memory.Set("list", shared.MemoryEntry{List: shared.FromArray([]string{"a", "b", "c"})})
+	server.Memory.Set("zset", shared.MemoryEntry{SortedSet: ss})
+	server.Memory.Set("stream", shared.MemoryEntry{Stream: []shared.StreamEntry{{ID: "1-1", Data: map[string]string{"f": "v"}}}})
+
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "reload"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+
+	if server.Memory.Len() != 4 {
+		t.Errorf("Expected 4 keys after reload, got %d", server.Memory.Len())
+	}
+	if getEntry("key").Value != "value" {
+		t.Errorf("Expected key to survive reload, got %v", getEntry("key"))
+	}
+	if got := getListAsArray("list"); len(got) != 3 || got[0] != "a" || got[2] != "c" {
+		t.Errorf("Expected list to survive reload, got %v", got)
+	}
+	if zset := getEntry("zset").SortedSet; zset == nil || zset.Scores()["a"] != 1.5 {
+		t.Errorf("Expected sorted set to survive reload, got %v", getEntry("zset"))
+	}
+	if stream := getEntry("stream").Stream; len(stream) != 1 || stream[0].ID != "1-1" {
+		t.Errorf("Expected stream to survive reload, got %v", getEntry("stream"))
+	}
+}
+
+func TestDebugReloadSaveError(t *testing.T) {
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        filepath.Join(t.TempDir(), "missing", "\x00"),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "RELOAD"}})
+	if result.Typ != "error" {
+		t.Errorf("Expected error, got %v", result)
+	}
+	if getEntry("key").Value != "value" {
+		t.Errorf("Expected dataset to be kept when the save fails, got %v", server.Memory.Clone())
+	}
+}
+
+func TestDebugSleep(t *testing.T) {
+	start := time.Now()
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SLEEP"}, {Typ: "bulk", Bulk: "0.05"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected DEBUG SLEEP to wait 50ms, returned after %v", elapsed)
+	}
+}
+
+func TestDebugObject(t *testing.T) {
+	clearMemory()
+	ss := shared.NewSortedSet()
+	ss.Add("a", 1)
+	server.Memory.Set("int", shared.MemoryEntry{Value: "12345"})
+	server.Memory.Set("short", shared.MemoryEntry{Value: "hello"})
+	server.Memory.Set("long", shared.MemoryEntry{Value: strings.Repeat("x", 100)})
+	server.Memory.Set("list", shared.MemoryEntry{List: shared.FromArray([]string{"a", "b"})})
+	server.Memory.Set("zset", shared.MemoryEntry{SortedSet: ss})
+	server.Memory.Set("expired", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() - 1000})
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "int", expected: "refcount:1 encoding:int serializedlength:3"},
+		{key: "short", expected: "refcount:1 encoding:embstr serializedlength:6"},
+		{key: "long", expected: "encoding:raw"},
+		{key: "list", expected: "encoding:listpack"},
+		{key: "zset", expected: "encoding:listpack"},
+	}
+
+	for _, tt := range tests {
+		result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "OBJECT"}, {Typ: "bulk", Bulk: tt.key}})
+		if result.Typ != "string" || !strings.Contains(result.Str, tt.expected) {
+			t.Errorf("%s: expected %q, got %v", tt.key, tt.expected, result)
+		}
+	}
+
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "OBJECT"}, {Typ: "bulk", Bulk: "expired"}})
+	if result.Typ != "error" || result.Str != "ERR no such key" {
+		t.Errorf("Expected an expired key to be missing, got %v", result)
+	}
+}
+
+func TestDebugSetActiveExpire(t *testing.T) {
+	defer server.SetActiveExpire(true)
+
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET-ACTIVE-EXPIRE"}, {Typ: "bulk", Bulk: "0"}})
+	if result.Str != "OK" || server.ActiveExpireEnabled() {
+		t.Errorf("Expected the expire cycle to be disabled, got %v", result)
+	}
+	result = Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SET-ACTIVE-EXPIRE"}, {Typ: "bulk", Bulk: "1"}})
+	if result.Str != "OK" || !server.ActiveExpireEnabled() {
+		t.Errorf("Expected the expire cycle to be enabled, got %v", result)
+	}
+}
+
+func TestActiveExpireCycle(t *testing.T) {
+	clearMemory()
+	past := time.Now().UnixMilli() - 1000
+	for i := 0; i < 50; i++ {
+		server.Memory.Set("expired"+strconv.Itoa(i), shared.MemoryEntry{Value: "v", Expires: past})
+	}
+	server.Memory.Set("live", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() + 60000})
+	server.Memory.Set("persistent", shared.MemoryEntry{Value: "v"})
+
+	// Every sample is fully expired, so the cycle keeps going until none is left
+	if removed := server.ActiveExpireCycle(); removed != 50 {
+		t.Errorf("Expected 50 keys removed, got %d", removed)
+	}
+	if server.Memory.Len() != 2 {
+		t.Errorf("Expected the live and persistent keys to be kept, got %d keys", server.Memory.Len())
+	}
+}
+
+func TestStoreExpires(t *testing.T) {
+	clearMemory()
+	at := time.Now().UnixMilli() + 60000
+	server.Memory.Set("ttl", shared.MemoryEntry{Value: "v", Expires: at})
+	server.Memory.Set("persistent", shared.MemoryEntry{Value: "v"})
+
+	if n := server.Memory.ExpiresLen(); n != 1 {
+		t.Errorf("Expected 1 key with an expiration, got %d", n)
+	}
+	if entry := getEntry("ttl"); entry.Expires != at {
+		t.Errorf("Expected the expiration %d, got %d", at, entry.Expires)
+	}
+
+	// Update keeps the expiration of an entry it doesn't change
+	server.Memory.Update("ttl", func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		entry.Value = "w"
+		return entry, true
+	})
+	if entry := getEntry("ttl"); entry.Value != "w" || entry.Expires != at {
+		t.Errorf("Expected w expiring at %d, got %v", at, entry)
+	}
+
+	// An entry stored without an expiration removes the previous one
+	server.Memory.Set("ttl", shared.MemoryEntry{Value: "v"})
+	if n := server.Memory.ExpiresLen(); n != 0 {
+		t.Errorf("Expected no key with an expiration, got %d", n)
+	}
+	server.Memory.RangeExpires(func(key string, _ int64) bool {
+		t.Errorf("Expected no expiration to range, got %s", key)
+		return true
+	})
+}
+
+func TestDebugJmapAndStringmatchLen(t *testing.T) {
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "JMAP"}})
+	if result.Typ != "bulk" || !strings.Contains(result.Bulk, "heap_alloc:") {
+		t.Errorf("Expected heap statistics, got %v", result)
+	}
+	result = Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "STRINGMATCH-LEN"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Errorf("Expected OK, got %v", result)
+	}
+}
+
+func TestDebugGoroutinesAndHeap(t *testing.T) {
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "GOROUTINES"}})
+	if result.Typ != "bulk" || !strings.Contains(result.Bulk, "goroutine ") || !strings.Contains(result.Bulk, "TestDebugGoroutinesAndHeap") {
+		t.Errorf("Expected the goroutine stacks, got %v", result)
+	}
+	result = Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "HEAP"}})
+	if result.Typ != "bulk" || !strings.HasPrefix(result.Bulk, "heap profile:") {
+		t.Errorf("Expected the heap profile, got %v", result)
+	}
+	result = runCommand("DEBUG", Debug, "test-conn", []shared.Value{{Typ: "bulk", Bulk: "HEAP"}, {Typ: "bulk", Bulk: "extra"}})
+	if result.Typ != "error" || result.Str != "ERR wrong number of arguments for 'debug|heap' command" {
+		t.Errorf("Expected an arity error, got %v", result)
+	}
+}
+
+func BenchmarkDebugReload(b *testing.B) {
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        b.TempDir(),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "RELOAD"}})
+	}
+}
+
+func TestDebugExport(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        dir,
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "export"}, {Typ: "bulk", Bulk: "keys.json"}})
+	if result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "keys.json"))
+	if err != nil || string(data) != "[\n{\"key\":\"key\",\"type\":\"string\",\"value\":\"value\"}\n]\n" {
+		t.Errorf("Expected the key to be exported, got %q, %v", data, err)
+	}
+
+	result = Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "export"}, {Typ: "bulk", Bulk: "missing/keys.json"}})
+	if result.Typ != "error" {
+		t.Errorf("Expected an error for a missing directory, got %v", result)
+	}
+	result = runCommand("DEBUG", Debug, "test-conn", []shared.Value{{Typ: "bulk", Bulk: "export"}})
+	if result.Str != "ERR wrong number of arguments for 'debug|export' command" {
+		t.Errorf("Expected an arity error, got %v", result)
+	}
+}
+
+func TestDebugDigest(t *testing.T) {
+	clearMemory()
+	zeros := strings.Repeat("0", 40)
+	result := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "digest"}})
+	if result.Str != zeros {
+		t.Errorf("Expected an empty dataset to digest to zeros, got %v", result)
+	}
+
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+	digest := Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "digest"}}).Str
+	if len(digest) != 40 || digest == zeros {
+		t.Errorf("Expected the digest to change, got %q", digest)
+	}
+
+	result = Debug(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "digest-value"}, {Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "missing"}})
+	if result.Typ != "array" || len(result.Array) != 2 {
+		t.Fatalf("Expected two digests, got %v", result)
+	}
+	if value := result.Array[0].Str; len(value) != 40 || value == zeros || value == digest {
+		t.Errorf("Expected the digest of the value alone, got %q", value)
+	}
+	if result.Array[1].Str != zeros {
+		t.Errorf("Expected a missing key to digest to zeros, got %q", result.Array[1].Str)
+	}
+}
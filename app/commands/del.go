@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Del handles the DEL command.
+// Usage: DEL key [key ...]
+// Returns: The number of keys that were removed.
+//
+// Keys that don't exist are ignored, and so are expired keys, which are removed all the same.
+//
+// Examples:
+//
+//	DEL key1 key2 missing   // Returns 2
+func Del(client *network.Client, args []shared.Value) shared.Value {
+	removed := 0
+	for _, arg := range args {
+		key := arg.Bulk
+		if server.Memory.DeleteIfExpired(key, time.Now().UnixMilli()) {
+			server.KeyExpired(key)
+			continue
+		}
+		if server.Memory.Delete(key) {
+			server.NotifyKeyModified(0, key, "del")
+			removed++
+		}
+	}
+	client.MarkDirty(removed)
+	return shared.Value{Typ: "integer", Num: removed}
+}
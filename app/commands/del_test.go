@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestDel(t *testing.T) {
+	clearMemory()
+	server.Memory.Set("key1", shared.MemoryEntry{Value: "v"})
+	server.Memory.Set("key2", shared.MemoryEntry{Array: []string{"a"}})
+	server.Memory.Set("expired", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() - 1000})
+
+	client := network.ClientOf("test-conn")
+	client.TakeDirty()
+	args := []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "expired"}, {Typ: "bulk", Bulk: "missing"}}
+	result := Del(client, args)
+	if result.Typ != "integer" || result.Num != 2 {
+		t.Errorf("Expected 2 keys removed, got %v", result)
+	}
+	for _, key := range []string{"key1", "key2", "expired"} {
+		if _, exists := server.Memory.Get(key); exists {
+			t.Errorf("Expected %s to be removed", key)
+		}
+	}
+	if changes := client.TakeDirty(); changes != 2 {
+		t.Errorf("Expected 2 changes, got %d", changes)
+	}
+
+	if result := runCommand("DEL", Del, "test-conn", nil); result.Str != "ERR wrong number of arguments for 'del' command" {
+		t.Errorf("Expected an arity error, got %v", result)
+	}
+}
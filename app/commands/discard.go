@@ -10,16 +10,12 @@ import (
 // Returns: OK
 // This command is used to discard all commands that have been queued since the MULTI command was issued.
 // If no MULTI command has been issued, it returns an error.
-func Discard(connID string, args []shared.Value) shared.Value {
-	if len(args) != 0 {
-		return createErrorResponse("ERR wrong number of arguments for 'discard' command")
-	}
-
-	if _, exists := network.TransactionsGet(connID); !exists {
+func Discard(client *network.Client, args []shared.Value) shared.Value {
+	if _, exists := client.Transaction(); !exists {
 		return createErrorResponse("ERR DISCARD without MULTI")
 	}
 
-	network.TransactionsDelete(connID)
+	client.EndTransaction()
 
 	return shared.Value{Typ: "string", Str: "OK"}
 }
@@ -32,7 +32,7 @@ func TestDiscard(t *testing.T) {
 			expected: shared.Value{Typ: "error", Str: "ERR DISCARD without MULTI"},
 			verify: func() {
 				// Transaction should not exist after error
-				if _, exists := network.Transactions["test-conn-1"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-1"); exists {
 					t.Error("Transaction should not exist after error")
 				}
 			},
@@ -44,12 +44,12 @@ func TestDiscard(t *testing.T) {
 				{Typ: "bulk", Bulk: "extra"},
 			},
 			setup: func() {
-				network.Transactions["test-conn-2"] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+				network.TransactionsSet("test-conn-2", shared.Transaction{Commands: []shared.QueuedCommand{}})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'discard' command"},
 			verify: func() {
 				// Transaction should still exist after argument error
-				if _, exists := network.Transactions["test-conn-2"]; !exists {
+				if _, exists := network.TransactionsGet("test-conn-2"); !exists {
 					t.Error("Transaction should still exist after argument error")
 				}
 			},
@@ -62,12 +62,12 @@ func TestDiscard(t *testing.T) {
 				{Typ: "bulk", Bulk: "arg2"},
 			},
 			setup: func() {
-				network.Transactions["test-conn-3"] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+				network.TransactionsSet("test-conn-3", shared.Transaction{Commands: []shared.QueuedCommand{}})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'discard' command"},
 			verify: func() {
 				// Transaction should still exist after argument error
-				if _, exists := network.Transactions["test-conn-3"]; !exists {
+				if _, exists := network.TransactionsGet("test-conn-3"); !exists {
 					t.Error("Transaction should still exist after argument error")
 				}
 			},
@@ -77,12 +77,12 @@ func TestDiscard(t *testing.T) {
 			connID: "test-conn-4",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-4"] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+				network.TransactionsSet("test-conn-4", shared.Transaction{Commands: []shared.QueuedCommand{}})
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
 				// Transaction should be cleared after DISCARD
-				if _, exists := network.Transactions["test-conn-4"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-4"); exists {
 					t.Error("Transaction should be cleared after DISCARD")
 				}
 			},
@@ -92,25 +92,25 @@ func TestDiscard(t *testing.T) {
 			connID: "test-conn-5",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-5"] = shared.Transaction{
+				network.TransactionsSet("test-conn-5", shared.Transaction{
 					Commands: []shared.QueuedCommand{
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 						{Command: "GET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}}},
 					},
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
 				// Transaction should be cleared after DISCARD
-				if _, exists := network.Transactions["test-conn-5"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-5"); exists {
 					t.Error("Transaction should be cleared after DISCARD")
 				}
 				// Commands should NOT have been executed
-				if _, exists := server.Memory["key1"]; exists {
+				if _, exists := server.Memory.Get("key1"); exists {
 					t.Error("Key1 should not exist after DISCARD")
 				}
-				if _, exists := server.Memory["key2"]; exists {
+				if _, exists := server.Memory.Get("key2"); exists {
 					t.Error("Key2 should not exist after DISCARD")
 				}
 			},
@@ -120,28 +120,28 @@ func TestDiscard(t *testing.T) {
 			connID: "test-conn-6",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-6"] = shared.Transaction{
+				network.TransactionsSet("test-conn-6", shared.Transaction{
 					Commands: []shared.QueuedCommand{
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "stringkey"}, {Typ: "bulk", Bulk: "stringvalue"}}},
 						{Command: "LPUSH", Args: []shared.Value{{Typ: "bulk", Bulk: "listkey"}, {Typ: "bulk", Bulk: "item1"}}},
 						{Command: "INCR", Args: []shared.Value{{Typ: "bulk", Bulk: "numkey"}}},
 					},
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
 				// Transaction should be cleared after DISCARD
-				if _, exists := network.Transactions["test-conn-6"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-6"); exists {
 					t.Error("Transaction should be cleared after DISCARD")
 				}
 				// Commands should NOT have been executed
-				if _, exists := server.Memory["stringkey"]; exists {
+				if _, exists := server.Memory.Get("stringkey"); exists {
 					t.Error("String key should not exist after DISCARD")
 				}
-				if _, exists := server.Memory["listkey"]; exists {
+				if _, exists := server.Memory.Get("listkey"); exists {
 					t.Error("List key should not exist after DISCARD")
 				}
-				if _, exists := server.Memory["numkey"]; exists {
+				if _, exists := server.Memory.Get("numkey"); exists {
 					t.Error("Number key should not exist after DISCARD")
 				}
 			},
@@ -156,7 +156,7 @@ func TestDiscard(t *testing.T) {
 			// Setup transaction if needed
 			tt.setup()
 
-			result := Discard(tt.connID, tt.args)
+			result := runCommand("DISCARD", Discard, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Discard() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -179,23 +179,23 @@ func TestDiscardTransactionClearing(t *testing.T) {
 	connID := "test-conn-clear"
 
 	// Setup transaction with commands
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "testkey"}, {Typ: "bulk", Bulk: "testvalue"}}},
 			{Command: "LPUSH", Args: []shared.Value{{Typ: "bulk", Bulk: "testlist"}, {Typ: "bulk", Bulk: "item"}}},
 		},
-	}
+	})
 
 	// Verify transaction exists before DISCARD
-	if _, exists := network.Transactions[connID]; !exists {
+	if _, exists := network.TransactionsGet(connID); !exists {
 		t.Error("Transaction should exist before DISCARD")
 	}
 
 	// Execute DISCARD
-	result := Discard(connID, []shared.Value{})
+	result := Discard(network.ClientOf(connID), []shared.Value{})
 
 	// Verify transaction is cleared after DISCARD
-	if _, exists := network.Transactions[connID]; exists {
+	if _, exists := network.TransactionsGet(connID); exists {
 		t.Error("Transaction should be cleared after DISCARD")
 	}
 
@@ -205,10 +205,10 @@ func TestDiscardTransactionClearing(t *testing.T) {
 	}
 
 	// Verify commands were NOT executed
-	if _, exists := server.Memory["testkey"]; exists {
+	if _, exists := server.Memory.Get("testkey"); exists {
 		t.Error("Key should not exist after DISCARD")
 	}
-	if _, exists := server.Memory["testlist"]; exists {
+	if _, exists := server.Memory.Get("testlist"); exists {
 		t.Error("List should not exist after DISCARD")
 	}
 }
@@ -222,45 +222,45 @@ func TestDiscardMultipleConnections(t *testing.T) {
 	conn2 := "connection-2"
 
 	// Setup transactions for both connections
-	network.Transactions[conn1] = shared.Transaction{
+	network.TransactionsSet(conn1, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 		},
-	}
+	})
 
-	network.Transactions[conn2] = shared.Transaction{
+	network.TransactionsSet(conn2, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 		},
-	}
+	})
 
 	// Execute DISCARD on first connection
-	result1 := Discard(conn1, []shared.Value{})
+	result1 := Discard(network.ClientOf(conn1), []shared.Value{})
 	if result1.Typ != "string" || result1.Str != "OK" {
 		t.Errorf("DISCARD on conn1 should return OK, got %v", result1)
 	}
 
 	// Execute DISCARD on second connection
-	result2 := Discard(conn2, []shared.Value{})
+	result2 := Discard(network.ClientOf(conn2), []shared.Value{})
 	if result2.Typ != "string" || result2.Str != "OK" {
 		t.Errorf("DISCARD on conn2 should return OK, got %v", result2)
 	}
 
 	// Verify both transactions are cleared
-	if _, exists := network.Transactions[conn1]; exists {
+	if _, exists := network.TransactionsGet(conn1); exists {
 		t.Error("Transaction for conn1 should be cleared")
 	}
 
-	if _, exists := network.Transactions[conn2]; exists {
+	if _, exists := network.TransactionsGet(conn2); exists {
 		t.Error("Transaction for conn2 should be cleared")
 	}
 
 	// Verify commands were NOT executed
-	if _, exists := server.Memory["key1"]; exists {
+	if _, exists := server.Memory.Get("key1"); exists {
 		t.Error("Key1 should not exist after DISCARD")
 	}
 
-	if _, exists := server.Memory["key2"]; exists {
+	if _, exists := server.Memory.Get("key2"); exists {
 		t.Error("Key2 should not exist after DISCARD")
 	}
 }
@@ -273,47 +273,47 @@ func TestDiscardVsExec(t *testing.T) {
 	connID := "test-conn-compare"
 
 	// Setup transaction
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "comparekey"}, {Typ: "bulk", Bulk: "comparevalue"}}},
 		},
-	}
+	})
 
 	// Test DISCARD first
-	result1 := Discard(connID, []shared.Value{})
+	result1 := Discard(network.ClientOf(connID), []shared.Value{})
 	if result1.Typ != "string" || result1.Str != "OK" {
 		t.Errorf("DISCARD should return OK, got %v", result1)
 	}
 
 	// Verify transaction is cleared
-	if _, exists := network.Transactions[connID]; exists {
+	if _, exists := network.TransactionsGet(connID); exists {
 		t.Error("Transaction should be cleared after DISCARD")
 	}
 
 	// Verify command was NOT executed
-	if _, exists := server.Memory["comparekey"]; exists {
+	if _, exists := server.Memory.Get("comparekey"); exists {
 		t.Error("Key should not exist after DISCARD")
 	}
 
 	// Now test EXEC on a new transaction
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "comparekey"}, {Typ: "bulk", Bulk: "comparevalue"}}},
 		},
-	}
+	})
 
-	result2 := Exec(connID, []shared.Value{})
+	result2 := Exec(network.ClientOf(connID), []shared.Value{})
 	if result2.Typ != "array" || len(result2.Array) != 1 {
 		t.Errorf("EXEC should return array with one result, got %v", result2)
 	}
 
 	// Verify transaction is cleared
-	if _, exists := network.Transactions[connID]; exists {
+	if _, exists := network.TransactionsGet(connID); exists {
 		t.Error("Transaction should be cleared after EXEC")
 	}
 
 	// Verify command WAS executed
-	entry, exists := server.Memory["comparekey"]
+	entry, exists := server.Memory.Get("comparekey")
 	if !exists {
 		t.Error("Key should exist after EXEC")
 	}
@@ -329,21 +329,21 @@ func BenchmarkDiscard(b *testing.B) {
 	args := []shared.Value{}
 
 	// Setup transaction
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}}},
 		},
-	}
+	})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Discard(connID, args)
+		Discard(network.ClientOf(connID), args)
 		// Re-setup transaction after each iteration
-		network.Transactions[connID] = shared.Transaction{
+		network.TransactionsSet(connID, shared.Transaction{
 			Commands: []shared.QueuedCommand{
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}}},
 			},
-		}
+		})
 	}
 }
 
@@ -354,13 +354,13 @@ func BenchmarkDiscardEmptyTransaction(b *testing.B) {
 	args := []shared.Value{}
 
 	// Setup empty transaction
-	network.Transactions[connID] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+	network.TransactionsSet(connID, shared.Transaction{Commands: []shared.QueuedCommand{}})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Discard(connID, args)
+		Discard(network.ClientOf(connID), args)
 		// Re-setup empty transaction after each iteration
-		network.Transactions[connID] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+		network.TransactionsSet(connID, shared.Transaction{Commands: []shared.QueuedCommand{}})
 	}
 }
 
@@ -371,24 +371,24 @@ func BenchmarkDiscardMultipleCommands(b *testing.B) {
 	args := []shared.Value{}
 
 	// Setup transaction with multiple commands
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key3"}, {Typ: "bulk", Bulk: "value3"}}},
 		},
-	}
+	})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Discard(connID, args)
+		Discard(network.ClientOf(connID), args)
 		// Re-setup transaction after each iteration
-		network.Transactions[connID] = shared.Transaction{
+		network.TransactionsSet(connID, shared.Transaction{
 			Commands: []shared.QueuedCommand{
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key3"}, {Typ: "bulk", Bulk: "value3"}}},
 			},
-		}
+		})
 	}
 }
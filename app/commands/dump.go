@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// Dump handles the DUMP command
+// Usage: DUMP key
+// Returns: The value of key serialized in the RDB format, or null for a missing key.
+//
+// The payload holds the value without its expiration, followed by the RDB version and a
+// checksum. RESTORE creates a key from it, on this server or on Redis.
+//
+// Examples:
+//
+//	DUMP mykey     // Returns "\x00\x03bar\x0b\x00..." for the string bar
+//	DUMP missing   // Returns null
+func Dump(client *network.Client, args []shared.Value) shared.Value {
+	entry, exists := server.LookupKeyRead(args[0].Bulk)
+	if !exists {
+		return shared.Null()
+	}
+	return shared.Value{Typ: "bulk", Bulk: string(storage.DumpValue(entry))}
+}
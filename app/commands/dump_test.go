@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestDump(t *testing.T) {
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "bar"})
+	server.Memory.Set("expired", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() - 1000})
+
+	result := Dump(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "key"}})
+	if result.Typ != "bulk" || result.Bulk[:5] != "\x00\x03bar" || len(result.Bulk) != 15 {
+		t.Errorf("Expected the string type, bar, the version and a checksum, got %q", result.Bulk)
+	}
+	for _, key := range []string{"missing", "expired"} {
+		if result := Dump(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: key}}); result.Typ != "null" {
+			t.Errorf("Expected null for %s, got %v", key, result)
+		}
+	}
+	if result := runCommand("DUMP", Dump, "test-conn", nil); result.Typ != "error" {
+		t.Errorf("Expected an arity error, got %v", result)
+	}
+}
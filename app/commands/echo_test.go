@@ -3,6 +3,7 @@ package commands
 import (
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -57,7 +58,7 @@ func TestEcho(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := Echo(tt.connID, tt.args)
+			result := runCommand("ECHO", Echo, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Echo() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -78,7 +79,7 @@ func BenchmarkEcho(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Echo(connID, args)
+		Echo(network.ClientOf(connID), args)
 	}
 }
 
@@ -94,7 +95,7 @@ func BenchmarkEchoLongMessage(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Echo(connID, args)
+		Echo(network.ClientOf(connID), args)
 	}
 }
 
@@ -108,7 +109,7 @@ func BenchmarkEchoUnicode(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Echo(connID, args)
+		Echo(network.ClientOf(connID), args)
 	}
 }
 
@@ -120,6 +121,6 @@ func BenchmarkEchoEmpty(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Echo(connID, args)
+		Echo(network.ClientOf(connID), args)
 	}
 }
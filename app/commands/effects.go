@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// effectRecorder collects the writes of the commands a transaction or a script runs, which
+// replicas apply together as a MULTI/EXEC block
+type effectRecorder struct {
+	client  *network.Client
+	effects []shared.QueuedCommand
+	parent  *effectRecorder // Recorder of the EXEC running the script, if any
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   = make(map[string]*effectRecorder) // Innermost recorder of each connection
+)
+
+// startRecording starts collecting the effects of the commands run for a client. A recorder
+// started while another one runs, for a script queued in a transaction, hands its effects to
+// that one, so the block propagated for EXEC holds them in order.
+func startRecording(client *network.Client) *effectRecorder {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	r := &effectRecorder{client: client, parent: recorders[client.ConnID]}
+	recorders[client.ConnID] = r
+	return r
+}
+
+// inExecContext reports whether the commands of a connection run for EXEC or a script.
+// Blocking commands don't block there: no other client can write the keys they would wait
+// on until the transaction or the script ends, so they reply like their non-blocking forms.
+func inExecContext(connID string) bool {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	_, running := recorders[connID]
+	return running
+}
+
+// run runs a command and records its effect when it changed the dataset
+func (r *effectRecorder) run(command string, args []shared.Value) shared.Value {
+	r.client.TakeDirty()
+	// Streamed replies are collected before the next command can change what they read
+	result := r.client.Execute(command, args).Materialize()
+
+	if r.client.TakeDirty() > 0 && network.IsWriteCommand(command) {
+		if command, args, ok := network.RewriteForPropagation(command, args, result); ok {
+			r.effects = append(r.effects, shared.QueuedCommand{Command: command, Args: args})
+		}
+	}
+	return result
+}
+
+// finish stops recording and propagates the effects collected
+func (r *effectRecorder) finish() {
+	recordersMu.Lock()
+	if r.parent != nil {
+		recorders[r.client.ConnID] = r.parent
+	} else {
+		delete(recorders, r.client.ConnID)
+	}
+	recordersMu.Unlock()
+
+	if len(r.effects) == 0 {
+		return
+	}
+	if r.parent != nil {
+		r.parent.effects = append(r.parent.effects, r.effects...)
+		return
+	}
+	network.PropagateCommand("MULTI", nil)
+	for _, effect := range r.effects {
+		network.PropagateCommand(effect.Command, effect.Args)
+	}
+	network.PropagateCommand("EXEC", nil)
+}
+
+// propagateEffect propagates a change the dispatcher doesn't, made by a command without the
+// write flag like FUNCTION LOAD. In a transaction it joins the block propagated for EXEC.
+func propagateEffect(client *network.Client, command string, args []shared.Value) {
+	server.AddDirty(1)
+	client.MarkDirty(1)
+
+	recordersMu.Lock()
+	r := recorders[client.ConnID]
+	recordersMu.Unlock()
+	if r != nil {
+		r.effects = append(r.effects, shared.QueuedCommand{Command: command, Args: args})
+		return
+	}
+	network.PropagateCommand(command, args)
+}
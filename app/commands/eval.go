@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/lua"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Eval handles the EVAL command
+// Usage: EVAL script numkeys [key [key ...]] [arg [arg ...]]
+// Returns: The value the script returns, converted to a reply.
+//
+// The script is Lua 5.1. It reads its keys in KEYS and its arguments in ARGV, and runs commands
+// with redis.call, which raises their errors, or redis.pcall, which returns them as tables with
+// an err field. redis.status_reply and redis.error_reply build status and error replies, and
+// the cjson library encodes and decodes JSON.
+//
+// Like EXEC, a script is atomic: no other command runs until it ends. Once it has run for
+// longer than busy-reply-threshold, other clients get BUSY and SCRIPT KILL stops it, unless it
+// already wrote. Its writes are propagated to replicas and to the append only file as a
+// MULTI/EXEC block, rather than the script itself.
+//
+// Examples:
+//
+//	EVAL "return redis.call('SET', KEYS[1], ARGV[1])" 1 mykey hello  // Returns OK
+//	EVAL "return {KEYS[1], ARGV[1], 3}" 1 key arg                     // Returns key, arg and 3
+//	EVAL "return redis.call('INCR', KEYS[1]) * 2" 1 counter           // Returns 2
+func Eval(client *network.Client, args []shared.Value) shared.Value {
+	keys, argv, errReply, ok := parseScriptKeys(args[1:])
+	if !ok {
+		return errReply
+	}
+	sha, fn, err := loadScript(args[0].Bulk)
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return runScript(client, sha, fn, false, false, scriptGlobals(keys, argv))
+}
+
+// Evalsha handles the EVALSHA command
+// Usage: EVALSHA sha1 numkeys [key [key ...]] [arg [arg ...]]
+// Returns: The value the script returns, or a NOSCRIPT error when no script has the digest.
+//
+// It runs a script EVAL or SCRIPT LOAD loaded before, by the SHA1 digest of its body.
+//
+// Examples:
+//
+//	SCRIPT LOAD "return ARGV[1]"                                // Returns 098e0f0d1448c0a81dafe820f66d460eb09263da
+//	EVALSHA 098e0f0d1448c0a81dafe820f66d460eb09263da 0 hello   // Returns hello
+func Evalsha(client *network.Client, args []shared.Value) shared.Value {
+	keys, argv, errReply, ok := parseScriptKeys(args[1:])
+	if !ok {
+		return errReply
+	}
+	fn, ok := lookupScript(args[0].Bulk)
+	if !ok {
+		return createErrorResponse("NOSCRIPT No matching script. Please use EVAL.")
+	}
+	return runScript(client, strings.ToLower(args[0].Bulk), fn, false, false, scriptGlobals(keys, argv))
+}
+
+// scriptGlobals sets the KEYS and ARGV globals of an EVAL script
+func scriptGlobals(keys []shared.Value, argv []shared.Value) func(s *lua.State) []lua.Value {
+	return func(s *lua.State) []lua.Value {
+		s.Globals.Set("KEYS", stringsTable(keys))
+		s.Globals.Set("ARGV", stringsTable(argv))
+		return nil
+	}
+}
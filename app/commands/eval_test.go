@@ -0,0 +1,374 @@
+package commands
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// evalArgs returns the arguments of EVAL, EVALSHA or SCRIPT as bulk strings
+func evalArgs(args ...string) []shared.Value {
+	values := make([]shared.Value, len(args))
+	for i, arg := range args {
+		values[i] = shared.Value{Typ: "bulk", Bulk: arg}
+	}
+	return values
+}
+
+func bulk(s string) shared.Value {
+	return shared.Value{Typ: "bulk", Bulk: s}
+}
+
+func integer(n int) shared.Value {
+	return shared.Value{Typ: "integer", Num: n}
+}
+
+func TestEvalReplies(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected shared.Value
+	}{
+		{"number is an integer", []string{"return 3.99", "0"}, integer(3)},
+		{"string is a bulk string", []string{"return 'hello'", "0"}, bulk("hello")},
+		{"true is 1", []string{"return true", "0"}, integer(1)},
+		{"false is null", []string{"return false", "0"}, shared.Value{Typ: "null"}},
+		{"nothing is null", []string{"local x = 1", "0"}, shared.Value{Typ: "null"}},
+		{"array stops at nil", []string{"return {1, 'two', nil, 4}", "0"},
+			shared.Value{Typ: "array", Array: []shared.Value{integer(1), bulk("two")}}},
+		{"nested arrays", []string{"return {{1}, {}}", "0"}, shared.Value{Typ: "array", Array: []shared.Value{
+			{Typ: "array", Array: []shared.Value{integer(1)}}, {Typ: "array", Array: []shared.Value{}}}}},
+		{"status reply", []string{"return redis.status_reply('FINE')", "0"}, shared.Value{Typ: "string", Str: "FINE"}},
+		{"error reply", []string{"return redis.error_reply('MYERR went wrong')", "0"}, shared.Value{Typ: "error", Str: "MYERR went wrong"}},
+		{"ok table", []string{"return {ok='DONE'}", "0"}, shared.Value{Typ: "string", Str: "DONE"}},
+		{"keys and args", []string{"return {KEYS[1], KEYS[2], ARGV[1], #ARGV}", "2", "k1", "k2", "a1", "a2"},
+			shared.Value{Typ: "array", Array: []shared.Value{bulk("k1"), bulk("k2"), bulk("a1"), integer(2)}}},
+		{"closures and loops", []string{`
+			local function counter()
+				local n = 0
+				return function() n = n + 1; return n end
+			end
+			local c, total = counter(), 0
+			for i = 1, 10 do total = total + c() end
+			return total`, "0"}, integer(55)},
+		{"string library", []string{"return string.format('%s-%05.1f-%d', string.upper(ARGV[1]), 3.14159, #ARGV[1])", "0", "abc"},
+			bulk("ABC-003.1-3")},
+		{"patterns", []string{"local k, v = string.match(ARGV[1], '(%w+)=(%d+)') return k .. ':' .. (v + 1)", "0", "count=41"},
+			bulk("count:42")},
+		{"gsub and gmatch", []string{`
+			local words = {}
+			for w in string.gmatch("one two three", "%a+") do words[#words + 1] = w end
+			return (string.gsub(table.concat(words, ","), "o", "0"))`, "0"}, bulk("0ne,tw0,three")},
+		{"table library", []string{"local t = {5, 2, 8} table.insert(t, 1) table.sort(t) return t", "0"},
+			shared.Value{Typ: "array", Array: []shared.Value{integer(1), integer(2), integer(5), integer(8)}}},
+		{"math library", []string{"return {math.floor(2.7), math.max(3, 9, 4), math.abs(-5)}", "0"},
+			shared.Value{Typ: "array", Array: []shared.Value{integer(2), integer(9), integer(5)}}},
+		{"cjson encode", []string{"return cjson.encode({name='x', list={1, 2, 3}})", "0"}, bulk(`{"name":"x","list":[1,2,3]}`)},
+		{"cjson decode", []string{"local v = cjson.decode(ARGV[1]) return {v.a, v.b[2], tostring(v.c == cjson.null)}", "0", `{"a":"x","b":[1,2],"c":null}`},
+			shared.Value{Typ: "array", Array: []shared.Value{bulk("x"), integer(2), bulk("true")}}},
+		{"pcall catches errors", []string{"local ok, err = pcall(error, 'boom', 0) return {tostring(ok), err}", "0"},
+			shared.Value{Typ: "array", Array: []shared.Value{bulk("false"), bulk("boom")}}},
+		{"sha1hex", []string{"return redis.sha1hex('')", "0"}, bulk("da39a3ee5e6b4b0d3255bfef95601890afd80709")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("EVAL", Eval, "test-conn", evalArgs(tt.args...))
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestEvalCommands(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+
+	script := `
+		redis.call('SET', KEYS[1], ARGV[1])
+		redis.call('RPUSH', KEYS[2], 'a', 'b', 3)
+		return {redis.call('GET', KEYS[1]), redis.call('LRANGE', KEYS[2], 0, -1), redis.call('INCR', KEYS[3]), redis.call('GET', 'missing')}`
+	result := Eval(network.ClientOf("test-conn"), evalArgs(script, "3", "str", "list", "counter", "value"))
+	expected := shared.Value{Typ: "array", Array: []shared.Value{
+		bulk("value"),
+		{Typ: "array", Array: []shared.Value{bulk("a"), bulk("b"), bulk("3")}},
+		integer(1),
+		{Typ: "null"},
+	}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+	if getEntry("str").Value != "value" {
+		t.Errorf("Expected the script to set str, got %q", getEntry("str").Value)
+	}
+
+	// redis.pcall returns errors as tables
+	result = Eval(network.ClientOf("test-conn"), evalArgs("local r = redis.pcall('INCR', KEYS[1]) return r.err", "1", "str"))
+	if result.Typ != "bulk" || result.Bulk != "ERR value is not an integer or out of range" {
+		t.Errorf("Expected the error as a string, got %+v", result)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	initCommandHandlers()
+	network.CommandHandlers["EVAL"] = Eval
+	defer delete(network.CommandHandlers, "EVAL")
+	clearMemory()
+	server.Memory.Set("str", shared.MemoryEntry{Value: "abc"})
+
+	sha := scriptSHA("return redis.call('INCR', 'str')")
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"wrong numkeys", []string{"return 1", "x"}, "ERR value is not an integer or out of range"},
+		{"negative numkeys", []string{"return 1", "-1"}, "ERR Number of keys can't be negative"},
+		{"too many keys", []string{"return 1", "2", "a"}, "ERR Number of keys can't be greater than number of args"},
+		{"syntax error", []string{"return (", "0"}, "ERR Error compiling script (new function): user_script:1: unexpected symbol near '<eof>'"},
+		{"command error", []string{"return redis.call('INCR', 'str')", "0"},
+			"ERR value is not an integer or out of range script: " + sha + ", on @user_script:1."},
+		{"runtime error", []string{"local t = nil\nreturn t.x", "0"},
+			"ERR user_script:2: attempt to index local 't' (a nil value) script: " + scriptSHA("local t = nil\nreturn t.x") + ", on @user_script:2."},
+		{"error call", []string{"error('custom')", "0"},
+			"ERR user_script:1: custom script: " + scriptSHA("error('custom')") + ", on @user_script:1."},
+		{"global read", []string{"return undefined_var", "0"},
+			"ERR user_script:1: Script attempted to access nonexistent global variable 'undefined_var' script: " + scriptSHA("return undefined_var") + ", on @user_script:1."},
+		{"global write", []string{"x = 1", "0"},
+			"ERR user_script:1: Script attempted to create global variable 'x' script: " + scriptSHA("x = 1") + ", on @user_script:1."},
+		{"readonly library", []string{"redis.call = nil", "0"},
+			"ERR user_script:1: Attempt to modify a readonly table script: " + scriptSHA("redis.call = nil") + ", on @user_script:1."},
+		{"unknown command", []string{"return redis.call('NOPE')", "0"},
+			"ERR Unknown Redis command called from script script: " + scriptSHA("return redis.call('NOPE')") + ", on @user_script:1."},
+		{"noscript command", []string{"return redis.call('EVAL', 'return 1', 0)", "0"},
+			"ERR This Redis command is not allowed from script script: " + scriptSHA("return redis.call('EVAL', 'return 1', 0)") + ", on @user_script:1."},
+		{"blocking command", []string{"return redis.call('BLPOP', 'l', 0)", "0"},
+			"ERR This Redis command is not allowed from script script: " + scriptSHA("return redis.call('BLPOP', 'l', 0)") + ", on @user_script:1."},
+		{"wrong arity", []string{"return redis.call('GET')", "0"},
+			"ERR wrong number of arguments for 'get' command script: " + scriptSHA("return redis.call('GET')") + ", on @user_script:1."},
+		{"bad argument", []string{"return redis.call('GET', {})", "0"},
+			"ERR Lua redis lib command arguments must be strings or integers script: " + scriptSHA("return redis.call('GET', {})") + ", on @user_script:1."},
+		{"stack overflow", []string{"local function f() return 1 + f() end return f()", "0"},
+			"ERR user_script:1: stack overflow script: " + scriptSHA("local function f() return 1 + f() end return f()") + ", on @user_script:1."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("EVAL", Eval, "test-conn", evalArgs(tt.args...))
+			if result.Typ != "error" || result.Str != tt.expected {
+				t.Errorf("Expected error %q, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestEvalshaAndScript(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	flushScripts()
+
+	body := "return ARGV[1]"
+	sha := scriptSHA(body)
+
+	result := Evalsha(network.ClientOf("test-conn"), evalArgs(sha, "0", "x"))
+	if result.Typ != "error" || result.Str != "NOSCRIPT No matching script. Please use EVAL." {
+		t.Errorf("Expected NOSCRIPT, got %+v", result)
+	}
+
+	if result := Script(network.ClientOf("test-conn"), evalArgs("LOAD", body)); !reflect.DeepEqual(result, bulk(sha)) {
+		t.Errorf("Expected SCRIPT LOAD to return %s, got %+v", sha, result)
+	}
+	if result := Evalsha(network.ClientOf("test-conn"), evalArgs(strings.ToUpper(sha), "0", "hello")); !reflect.DeepEqual(result, bulk("hello")) {
+		t.Errorf("Expected EVALSHA to run the script, got %+v", result)
+	}
+
+	// EVAL caches the scripts it runs too
+	Eval(network.ClientOf("test-conn"), evalArgs("return 2", "0"))
+	result = Script(network.ClientOf("test-conn"), evalArgs("EXISTS", sha, scriptSHA("return 2"), "ffff"))
+	expected := shared.Value{Typ: "array", Array: []shared.Value{integer(1), integer(1), integer(0)}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	if result := Script(network.ClientOf("test-conn"), evalArgs("FLUSH")); result.Str != "OK" {
+		t.Errorf("Expected OK, got %+v", result)
+	}
+	if result := Script(network.ClientOf("test-conn"), evalArgs("EXISTS", sha)); result.Array[0].Num != 0 {
+		t.Error("Expected SCRIPT FLUSH to empty the script cache")
+	}
+	if result := Script(network.ClientOf("test-conn"), evalArgs("FLUSH", "LATER")); result.Typ != "error" {
+		t.Errorf("Expected an error for an unknown flush mode, got %+v", result)
+	}
+	if result := Script(network.ClientOf("test-conn"), evalArgs("LOAD", "return (")); result.Typ != "error" {
+		t.Errorf("Expected a compile error, got %+v", result)
+	}
+}
+
+func TestEvalPropagatesEffects(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	clearTransactions()
+
+	replica := &recordingConn{}
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	network.ReplicasSet("replica-1", replica)
+	defer network.ReplicasDelete("replica-1")
+
+	connID := "test-conn-eval"
+	Eval(network.ClientOf(connID), evalArgs("redis.call('SET', KEYS[1], 'v') redis.call('GET', KEYS[1]) redis.call('LPOP', 'missing') return 1", "1", "key"))
+
+	expected := "*1\r\n$5\r\nMULTI\r\n" +
+		"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$1\r\nv\r\n" +
+		"*1\r\n$4\r\nEXEC\r\n"
+	if replica.String() != expected {
+		t.Errorf("Expected propagated stream %q, got %q", expected, replica.String())
+	}
+
+	// A script without writes propagates nothing
+	replica.Reset()
+	Eval(network.ClientOf(connID), evalArgs("return redis.call('GET', 'key')", "0"))
+	if replica.Len() != 0 {
+		t.Errorf("Expected nothing to be propagated, got %q", replica.String())
+	}
+
+	// A script run by EXEC adds its writes to the block of the transaction
+	network.CommandHandlers["EVAL"] = Eval
+	defer delete(network.CommandHandlers, "EVAL")
+	network.TransactionsSet(connID, shared.Transaction{Commands: []shared.QueuedCommand{
+		{Command: "SET", Args: evalArgs("a", "1")},
+		{Command: "EVAL", Args: evalArgs("return redis.call('INCR', 'a')", "0")},
+		{Command: "SET", Args: evalArgs("b", "2")},
+	}})
+	Exec(network.ClientOf(connID), []shared.Value{})
+	expected = "*1\r\n$5\r\nMULTI\r\n" +
+		"*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n" +
+		"*2\r\n$4\r\nINCR\r\n$1\r\na\r\n" +
+		"*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n" +
+		"*1\r\n$4\r\nEXEC\r\n"
+	if replica.String() != expected {
+		t.Errorf("Expected propagated stream %q, got %q", expected, replica.String())
+	}
+}
+
+func TestEvalKeys(t *testing.T) {
+	keys, err := network.ExtractKeys("EVAL", evalArgs("return 1", "2", "k1", "k2", "arg"))
+	if err != nil || !reflect.DeepEqual(keys, []string{"k1", "k2"}) {
+		t.Errorf("Expected keys k1 and k2, got %v (%v)", keys, err)
+	}
+	if _, err := network.ExtractKeys("EVALSHA", evalArgs("sha", "3", "k1")); err == nil {
+		t.Error("Expected an error when numkeys is greater than the arguments")
+	}
+}
+
+func TestEvalOnReplica(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	defer func(role string, stale bool) {
+		server.StoreState.Role, server.StoreState.ReplicaServeStaleData = role, stale
+	}(server.StoreState.Role, server.StoreState.ReplicaServeStaleData)
+	server.StoreState.Role, server.StoreState.ReplicaServeStaleData = "slave", true
+
+	result := Eval(network.ClientOf("test-conn"), evalArgs("return redis.call('SET', 'key', 'v')", "0"))
+	if result.Typ != "error" || !strings.HasPrefix(result.Str, shared.ReadOnlyMessage) {
+		t.Errorf("Expected the write to be refused, got %+v", result)
+	}
+	if result := Eval(network.ClientOf("test-conn"), evalArgs("return redis.call('GET', 'key')", "0")); result.Typ == "error" {
+		t.Errorf("Expected reads to be served, got %+v", result)
+	}
+}
+
+// startBusyScript runs a script with EVAL, or a function with FCALL, on its own goroutine and
+// waits until other clients get BUSY. The reply is sent on the returned channel.
+func startBusyScript(t *testing.T, command string, args ...string) <-chan shared.Value {
+	t.Helper()
+	reply := make(chan shared.Value, 1)
+	go func() {
+		reply <- network.ExecuteAndPropagate(command, "test-conn-busy", evalArgs(args...))
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for network.ScriptBusy("GET", evalArgs("key")) == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the script to make the server busy")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return reply
+}
+
+func TestScriptKill(t *testing.T) {
+	initCommandHandlers()
+	network.CommandHandlers["EVAL"] = Eval
+	network.CommandHandlers["SCRIPT"] = Script
+	network.CommandHandlers["FUNCTION"] = Function
+	clearMemory()
+	defer func(threshold int) { server.StoreState.BusyReplyThreshold = threshold }(server.StoreState.BusyReplyThreshold)
+	server.StoreState.BusyReplyThreshold = 0
+
+	if result := Script(network.ClientOf("test-conn"), evalArgs("KILL")); result.Str != "NOTBUSY No scripts in execution right now." {
+		t.Errorf("Expected NOTBUSY without a script, got %+v", result)
+	}
+
+	reply := startBusyScript(t, "EVAL", "while true do end", "0")
+
+	busy := "BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE."
+	if err := network.ScriptBusy("GET", evalArgs("key")); err != busy {
+		t.Errorf("Expected %q, got %q", busy, err)
+	}
+	if err := network.ScriptBusy("SHUTDOWN", evalArgs("nosave")); err != "" {
+		t.Errorf("Expected SHUTDOWN NOSAVE to run while busy, got %q", err)
+	}
+
+	// Writes wait for the script, which holds the command lock, reads don't take it
+	done := make(chan shared.Value, 1)
+	go func() { done <- network.ExecuteAndPropagate("SET", "test-conn-other", evalArgs("other", "v")) }()
+	select {
+	case <-done:
+		t.Fatal("Expected SET to wait for the script")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if result := network.ExecuteAndPropagate("GET", "test-conn-other", evalArgs("key")); result.Typ == "error" {
+		t.Errorf("Expected GET to run while the script does, got %+v", result)
+	}
+
+	if result := network.ExecuteAndPropagate("FUNCTION", "test-conn", evalArgs("KILL")); result.Str != busy {
+		t.Errorf("Expected FUNCTION KILL to leave a script running, got %+v", result)
+	}
+	if result := network.ExecuteAndPropagate("SCRIPT", "test-conn", evalArgs("KILL")); result.Str != "OK" {
+		t.Errorf("Expected OK, got %+v", result)
+	}
+	if result := <-reply; result.Typ != "error" || result.Str != "ERR Script killed by user with SCRIPT KILL..." {
+		t.Errorf("Expected the script to be killed, got %+v", result)
+	}
+	<-done
+	if err := network.ScriptBusy("GET", evalArgs("key")); err != "" {
+		t.Errorf("Expected the server not to be busy anymore, got %q", err)
+	}
+
+	// pcall doesn't catch the kill
+	reply = startBusyScript(t, "EVAL", "while true do pcall(function() while true do end end) end", "0")
+	Script(network.ClientOf("test-conn"), evalArgs("KILL"))
+	if result := <-reply; result.Str != "ERR Script killed by user with SCRIPT KILL..." {
+		t.Errorf("Expected the script to be killed, got %+v", result)
+	}
+
+	// A script that wrote can't be killed, it ends once it sees the stop key
+	reply = startBusyScript(t, "EVAL", "redis.call('SET', KEYS[1], 'v') while not redis.call('GET', 'stop') do end return 1", "1", "key")
+	unkillable := "UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command."
+	if result := Script(network.ClientOf("test-conn"), evalArgs("KILL")); result.Str != unkillable {
+		t.Errorf("Expected %q, got %+v", unkillable, result)
+	}
+	Set(network.ClientOf("test-conn"), evalArgs("stop", "1"))
+	if result := <-reply; !reflect.DeepEqual(result, integer(1)) {
+		t.Errorf("Expected the script to end, got %+v", result)
+	}
+}
@@ -7,34 +7,42 @@ import (
 
 // Exec handles the EXEC command.
 // Executes all commands that were queued since the MULTI command was issued.
+// The writes that changed the dataset are propagated to replicas inside their own MULTI/EXEC.
 // Examples:
 //
 //	MULTI           // Starts a transaction block
 //	SET mykey "Hello"
 //	GET mykey
 //	EXEC            // Executes the transaction block
-func Exec(connID string, args []shared.Value) shared.Value {
-	if len(args) != 0 {
-		return createErrorResponse("ERR wrong number of arguments for 'exec' command")
-	}
-
-	// Check if there's an active transaction for this connection (concurrency-safe)
-	transaction, exists := network.TransactionsGet(connID)
+func Exec(client *network.Client, args []shared.Value) shared.Value {
+	// Check if there's an active transaction for this connection
+	transaction, exists := client.Transaction()
 	if !exists {
 		return createErrorResponse("ERR EXEC without MULTI")
 	}
 
-	// Clear the transaction (concurrency-safe)
-	network.TransactionsDelete(connID)
+	// Clear the transaction
+	client.EndTransaction()
+	if transaction.Aborted {
+		return createErrorResponse("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	// In cluster mode the whole transaction must touch a single slot
+	if err := network.ClusterCheckTransaction(client.ConnID, transaction.Commands); err != "" {
+		return createErrorResponse(err)
+	}
 
 	if len(transaction.Commands) == 0 {
 		return shared.Value{Typ: "array", Array: []shared.Value{}}
 	}
 
-	// Execute all queued commands
+	// Execute all queued commands, collecting the effects of those that changed the dataset.
+	// Replicas apply them atomically as a MULTI/EXEC block.
+	recorder := startRecording(client)
+	defer recorder.finish()
 	results := make([]shared.Value, len(transaction.Commands))
 	for i, queuedCmd := range transaction.Commands {
-		results[i] = network.ExecuteCommand(queuedCmd.Command, connID, queuedCmd.Args)
+		results[i] = recorder.run(queuedCmd.Command, queuedCmd.Args)
 	}
 
 	return shared.Value{Typ: "array", Array: results}
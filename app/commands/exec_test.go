@@ -1,7 +1,13 @@
 package commands
 
 import (
+	"bytes"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
@@ -32,7 +38,7 @@ func TestExec(t *testing.T) {
 			expected: shared.Value{Typ: "error", Str: "ERR EXEC without MULTI"},
 			verify: func() {
 				// Transaction should not exist after error
-				if _, exists := network.Transactions["test-conn-1"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-1"); exists {
 					t.Error("Transaction should not exist after error")
 				}
 			},
@@ -44,12 +50,12 @@ func TestExec(t *testing.T) {
 				{Typ: "bulk", Bulk: "extra"},
 			},
 			setup: func() {
-				network.Transactions["test-conn-2"] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+				network.TransactionsSet("test-conn-2", shared.Transaction{Commands: []shared.QueuedCommand{}})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'exec' command"},
 			verify: func() {
 				// Transaction should still exist after argument error
-				if _, exists := network.Transactions["test-conn-2"]; !exists {
+				if _, exists := network.TransactionsGet("test-conn-2"); !exists {
 					t.Error("Transaction should still exist after argument error")
 				}
 			},
@@ -59,12 +65,12 @@ func TestExec(t *testing.T) {
 			connID: "test-conn-3",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-3"] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+				network.TransactionsSet("test-conn-3", shared.Transaction{Commands: []shared.QueuedCommand{}})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Transaction should be cleared after EXEC
-				if _, exists := network.Transactions["test-conn-3"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-3"); exists {
 					t.Error("Transaction should be cleared after EXEC")
 				}
 			},
@@ -74,20 +80,20 @@ func TestExec(t *testing.T) {
 			connID: "test-conn-4",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-4"] = shared.Transaction{
+				network.TransactionsSet("test-conn-4", shared.Transaction{
 					Commands: []shared.QueuedCommand{
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 					},
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{{Typ: "string", Str: "OK"}}},
 			verify: func() {
 				// Transaction should be cleared after EXEC
-				if _, exists := network.Transactions["test-conn-4"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-4"); exists {
 					t.Error("Transaction should be cleared after EXEC")
 				}
 				// Command should have been executed
-				entry, exists := server.Memory["key1"]
+				entry, exists := server.Memory.Get("key1")
 				if !exists {
 					t.Error("Key should exist after EXEC")
 				}
@@ -101,13 +107,13 @@ func TestExec(t *testing.T) {
 			connID: "test-conn-5",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-5"] = shared.Transaction{
+				network.TransactionsSet("test-conn-5", shared.Transaction{
 					Commands: []shared.QueuedCommand{
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 						{Command: "GET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}}},
 					},
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "OK"},
@@ -116,11 +122,11 @@ func TestExec(t *testing.T) {
 			}},
 			verify: func() {
 				// Transaction should be cleared after EXEC
-				if _, exists := network.Transactions["test-conn-5"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-5"); exists {
 					t.Error("Transaction should be cleared after EXEC")
 				}
 				// Commands should have been executed
-				entry1, exists := server.Memory["key1"]
+				entry1, exists := server.Memory.Get("key1")
 				if !exists {
 					t.Error("Key1 should exist after EXEC")
 				}
@@ -128,7 +134,7 @@ func TestExec(t *testing.T) {
 					t.Errorf("Expected value 'value1', got '%s'", entry1.Value)
 				}
 
-				entry2, exists := server.Memory["key2"]
+				entry2, exists := server.Memory.Get("key2")
 				if !exists {
 					t.Error("Key2 should exist after EXEC")
 				}
@@ -142,16 +148,16 @@ func TestExec(t *testing.T) {
 			connID: "test-conn-6",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-6"] = shared.Transaction{
+				network.TransactionsSet("test-conn-6", shared.Transaction{
 					Commands: []shared.QueuedCommand{
 						{Command: "INVALID", Args: []shared.Value{{Typ: "bulk", Bulk: "arg1"}}},
 					},
-				}
+				})
 			},
-			expected: shared.Value{Typ: "array", Array: []shared.Value{{Typ: "string", Str: ""}}},
+			expected: shared.Value{Typ: "array", Array: []shared.Value{{Typ: "error", Str: "ERR unknown command 'invalid', with args beginning with: 'arg1' "}}},
 			verify: func() {
 				// Transaction should be cleared after EXEC
-				if _, exists := network.Transactions["test-conn-6"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-6"); exists {
 					t.Error("Transaction should be cleared after EXEC")
 				}
 			},
@@ -161,20 +167,20 @@ func TestExec(t *testing.T) {
 			connID: "test-conn-7",
 			args:   []shared.Value{},
 			setup: func() {
-				network.Transactions["test-conn-7"] = shared.Transaction{
+				network.TransactionsSet("test-conn-7", shared.Transaction{
 					Commands: []shared.QueuedCommand{
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}}}, // Missing value
 					},
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{{Typ: "error", Str: "ERR wrong number of arguments for 'set' command"}}},
 			verify: func() {
 				// Transaction should be cleared after EXEC
-				if _, exists := network.Transactions["test-conn-7"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-7"); exists {
 					t.Error("Transaction should be cleared after EXEC")
 				}
 				// Key should not exist due to error
-				if _, exists := server.Memory["key1"]; exists {
+				if _, exists := server.Memory.Get("key1"); exists {
 					t.Error("Key should not exist after error")
 				}
 			},
@@ -189,7 +195,7 @@ func TestExec(t *testing.T) {
 			// Setup transaction if needed
 			tt.setup()
 
-			result := Exec(tt.connID, tt.args)
+			result := runCommand("EXEC", Exec, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Exec() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -231,22 +237,22 @@ func TestExecTransactionClearing(t *testing.T) {
 	connID := "test-conn-clear"
 
 	// Setup transaction
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "testkey"}, {Typ: "bulk", Bulk: "testvalue"}}},
 		},
-	}
+	})
 
 	// Verify transaction exists before EXEC
-	if _, exists := network.Transactions[connID]; !exists {
+	if _, exists := network.TransactionsGet(connID); !exists {
 		t.Error("Transaction should exist before EXEC")
 	}
 
 	// Execute EXEC
-	result := Exec(connID, []shared.Value{})
+	result := Exec(network.ClientOf(connID), []shared.Value{})
 
 	// Verify transaction is cleared after EXEC
-	if _, exists := network.Transactions[connID]; exists {
+	if _, exists := network.TransactionsGet(connID); exists {
 		t.Error("Transaction should be cleared after EXEC")
 	}
 
@@ -255,7 +261,7 @@ func TestExecTransactionClearing(t *testing.T) {
 		t.Errorf("EXEC should return array with one result, got %v", result)
 	}
 
-	entry, exists := server.Memory["testkey"]
+	entry, exists := server.Memory.Get("testkey")
 	if !exists {
 		t.Error("Key should exist after EXEC")
 	}
@@ -273,41 +279,41 @@ func TestExecMultipleConnections(t *testing.T) {
 	conn2 := "connection-2"
 
 	// Setup transactions for both connections
-	network.Transactions[conn1] = shared.Transaction{
+	network.TransactionsSet(conn1, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 		},
-	}
+	})
 
-	network.Transactions[conn2] = shared.Transaction{
+	network.TransactionsSet(conn2, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 		},
-	}
+	})
 
 	// Execute EXEC on first connection
-	result1 := Exec(conn1, []shared.Value{})
+	result1 := Exec(network.ClientOf(conn1), []shared.Value{})
 	if result1.Typ != "array" || len(result1.Array) != 1 {
 		t.Errorf("EXEC on conn1 should return array with one result, got %v", result1)
 	}
 
 	// Execute EXEC on second connection
-	result2 := Exec(conn2, []shared.Value{})
+	result2 := Exec(network.ClientOf(conn2), []shared.Value{})
 	if result2.Typ != "array" || len(result2.Array) != 1 {
 		t.Errorf("EXEC on conn2 should return array with one result, got %v", result2)
 	}
 
 	// Verify both transactions are cleared
-	if _, exists := network.Transactions[conn1]; exists {
+	if _, exists := network.TransactionsGet(conn1); exists {
 		t.Error("Transaction for conn1 should be cleared")
 	}
 
-	if _, exists := network.Transactions[conn2]; exists {
+	if _, exists := network.TransactionsGet(conn2); exists {
 		t.Error("Transaction for conn2 should be cleared")
 	}
 
 	// Verify both commands were executed
-	entry1, exists := server.Memory["key1"]
+	entry1, exists := server.Memory.Get("key1")
 	if !exists {
 		t.Error("Key1 should exist after EXEC")
 	}
@@ -315,7 +321,7 @@ func TestExecMultipleConnections(t *testing.T) {
 		t.Errorf("Expected value 'value1', got '%s'", entry1.Value)
 	}
 
-	entry2, exists := server.Memory["key2"]
+	entry2, exists := server.Memory.Get("key2")
 	if !exists {
 		t.Error("Key2 should exist after EXEC")
 	}
@@ -324,6 +330,89 @@ func TestExecMultipleConnections(t *testing.T) {
 	}
 }
 
+func TestExecPropagatesOnlyDirtyWrites(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	clearTransactions()
+
+	replica := &recordingConn{}
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	network.ReplicasSet("replica-1", replica)
+	defer network.ReplicasDelete("replica-1")
+
+	client := network.ClientOf("test-conn-dirty")
+	client.StartTransaction()
+	client.Queue("SET", []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}})
+	client.Queue("GET", []shared.Value{{Typ: "bulk", Bulk: "key"}})
+	client.Queue("LPOP", []shared.Value{{Typ: "bulk", Bulk: "missing"}})
+
+	Exec(client, []shared.Value{})
+
+	expected := "*1\r\n$5\r\nMULTI\r\n" +
+		"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n" +
+		"*1\r\n$4\r\nEXEC\r\n"
+	if replica.String() != expected {
+		t.Errorf("Expected propagated stream %q, got %q", expected, replica.String())
+	}
+	if client.TakeDirty() != 0 {
+		t.Error("EXEC should consume the dirty count of its queued commands")
+	}
+
+	// A transaction without any effective write propagates nothing
+	replica.Reset()
+	client.StartTransaction()
+	client.Queue("LPOP", []shared.Value{{Typ: "bulk", Bulk: "missing"}})
+	Exec(client, []shared.Value{})
+	if replica.Len() != 0 {
+		t.Errorf("Expected nothing to be propagated, got %q", replica.String())
+	}
+}
+
+func TestExecDoesNotBlock(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	clearTransactions()
+
+	connID := "test-conn-blocking"
+	network.TransactionsSet(connID, shared.Transaction{Commands: []shared.QueuedCommand{
+		{Command: "BLPOP", Args: aclArgs("empty", "0")},
+		{Command: "XREAD", Args: aclArgs("BLOCK", "0", "STREAMS", "stream", "$")},
+		{Command: "RPUSH", Args: aclArgs("list", "a")},
+		{Command: "BLPOP", Args: aclArgs("list", "0")},
+	}})
+
+	done := make(chan shared.Value)
+	go func() { done <- Exec(network.ClientOf(connID), []shared.Value{}) }()
+	var result shared.Value
+	select {
+	case result = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the blocking commands of the transaction not to block")
+	}
+
+	if len(result.Array) != 4 {
+		t.Fatalf("Expected 4 replies, got %+v", result)
+	}
+	if !result.Array[0].IsNull() {
+		t.Errorf("Expected BLPOP on an empty list to time out at once, got %+v", result.Array[0])
+	}
+	if result.Array[1].Typ != "array" || len(result.Array[1].Array) != 0 {
+		t.Errorf("Expected XREAD BLOCK to reply like XREAD, got %+v", result.Array[1])
+	}
+	if result.Array[3].Typ != "array" || len(result.Array[3].Array) != 2 || result.Array[3].Array[1].Str != "a" {
+		t.Errorf("Expected BLPOP to pop the element pushed before it, got %+v", result.Array[3])
+	}
+}
+
+// recordingConn is a mock net.Conn that records everything written to it
+type recordingConn struct {
+	mockConn
+	bytes.Buffer
+}
+
+func (r *recordingConn) Write(b []byte) (int, error) { return r.Buffer.Write(b) }
+func (r *recordingConn) Read(b []byte) (int, error)  { return 0, nil }
+
 func BenchmarkExec(b *testing.B) {
 	clearMemory()
 	clearTransactions()
@@ -332,21 +421,21 @@ func BenchmarkExec(b *testing.B) {
 	args := []shared.Value{}
 
 	// Setup transaction
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}}},
 		},
-	}
+	})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Exec(connID, args)
+		Exec(network.ClientOf(connID), args)
 		// Re-setup transaction after each iteration
-		network.Transactions[connID] = shared.Transaction{
+		network.TransactionsSet(connID, shared.Transaction{
 			Commands: []shared.QueuedCommand{
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}}},
 			},
-		}
+		})
 	}
 }
 
@@ -357,13 +446,13 @@ func BenchmarkExecEmptyTransaction(b *testing.B) {
 	args := []shared.Value{}
 
 	// Setup empty transaction
-	network.Transactions[connID] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+	network.TransactionsSet(connID, shared.Transaction{Commands: []shared.QueuedCommand{}})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Exec(connID, args)
+		Exec(network.ClientOf(connID), args)
 		// Re-setup empty transaction after each iteration
-		network.Transactions[connID] = shared.Transaction{Commands: []shared.QueuedCommand{}}
+		network.TransactionsSet(connID, shared.Transaction{Commands: []shared.QueuedCommand{}})
 	}
 }
 
@@ -375,24 +464,94 @@ func BenchmarkExecMultipleCommands(b *testing.B) {
 	args := []shared.Value{}
 
 	// Setup transaction with multiple commands
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key3"}, {Typ: "bulk", Bulk: "value3"}}},
 		},
-	}
+	})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Exec(connID, args)
+		Exec(network.ClientOf(connID), args)
 		// Re-setup transaction after each iteration
-		network.Transactions[connID] = shared.Transaction{
+		network.TransactionsSet(connID, shared.Transaction{
 			Commands: []shared.QueuedCommand{
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key1"}, {Typ: "bulk", Bulk: "value1"}}},
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key2"}, {Typ: "bulk", Bulk: "value2"}}},
 				{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key3"}, {Typ: "bulk", Bulk: "value3"}}},
 			},
+		})
+	}
+}
+
+func TestExecSingleWriter(t *testing.T) {
+	initCommandHandlers()
+	network.CommandHandlers["EXEC"] = Exec
+	defer delete(network.CommandHandlers, "EXEC")
+	clearMemory()
+	clearTransactions()
+
+	network.StartExecutor()
+	defer network.StopExecutor()
+
+	// Other clients increment the counter while the transaction reads it twice
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					network.ExecuteAndPropagate("INCR", "writer-conn", []shared.Value{{Typ: "bulk", Bulk: "counter"}})
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	get := shared.QueuedCommand{Command: "GET", Args: []shared.Value{{Typ: "bulk", Bulk: "counter"}}}
+	for i := 0; i < 200; i++ {
+		network.TransactionsSet("tx-conn", shared.Transaction{Commands: []shared.QueuedCommand{get, get}})
+		result := network.ExecuteAndPropagate("EXEC", "tx-conn", nil)
+		if len(result.Array) != 2 || result.Array[0].Str != result.Array[1].Str {
+			t.Fatalf("Expected both reads of the transaction to see the same value, got %+v", result)
 		}
 	}
 }
+
+// BenchmarkIncrParallel runs INCR from parallel clients on distinct keys, each command
+// locking only the shard of its key
+func BenchmarkIncrParallel(b *testing.B) {
+	benchmarkIncrParallel(b)
+}
+
+// BenchmarkIncrParallelSingleWriter runs the same commands one at a time on the executor
+func BenchmarkIncrParallelSingleWriter(b *testing.B) {
+	network.StartExecutor()
+	defer network.StopExecutor()
+	benchmarkIncrParallel(b)
+}
+
+func benchmarkIncrParallel(b *testing.B) {
+	initCommandHandlers()
+	clearMemory()
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		key := "counter-" + strconv.FormatInt(next.Add(1), 10)
+		args := []shared.Value{{Typ: "bulk", Bulk: key}}
+		for pb.Next() {
+			network.ExecuteAndPropagate("INCR", key, args)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// failover handles the FAILOVER command.
+// Usage: FAILOVER [TO host port [FORCE]] [ABORT] [TIMEOUT milliseconds]
+// Returns: "OK" once the failover has started, error message on failure.
+//
+// This command hands the master role over to a replica. Writes are paused while the
+// target catches up on the replication offset, then this server demotes itself and
+// reconnects to the target with PSYNC ... FAILOVER, which makes the target promote itself.
+// Without TO, the first replica to catch up is used. FORCE fails over to the target
+// even if it didn't catch up before the timeout. ABORT cancels a failover still waiting.
+//
+// Examples:
+//
+//	FAILOVER                              // Fails over to the first replica that catches up
+//	FAILOVER TO 127.0.0.1 6380 TIMEOUT 5000 // Gives the target 5 seconds to catch up
+//	FAILOVER ABORT                        // Cancels the failover and resumes writes
+func Failover(client *network.Client, args []shared.Value) shared.Value {
+	var opts network.FailoverOptions
+	abort := false
+
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "TO":
+			if i+2 >= len(args) {
+				return shared.ErrSyntax()
+			}
+			opts.Host = args[i+1].Bulk
+			opts.Port = args[i+2].Bulk
+			i += 2
+		case "TIMEOUT":
+			if i+1 >= len(args) {
+				return shared.ErrSyntax()
+			}
+			ms, err := strconv.ParseInt(args[i+1].Bulk, 10, 64)
+			if err != nil || ms <= 0 {
+				return createErrorResponse("ERR FAILOVER timeout must be greater than 0")
+			}
+			opts.Timeout = time.Duration(ms) * time.Millisecond
+			i++
+		case "FORCE":
+			opts.Force = true
+		case "ABORT":
+			abort = true
+		default:
+			return shared.ErrSyntax()
+		}
+	}
+
+	if abort {
+		if opts.Host != "" || opts.Timeout > 0 || opts.Force {
+			return createErrorResponse("ERR FAILOVER abort can't be combined with other arguments")
+		}
+		if err := network.AbortFailover(); err != nil {
+			return createErrorResponse(err.Error())
+		}
+		return shared.Value{Typ: "string", Str: "OK"}
+	}
+
+	if err := network.StartFailover(opts); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
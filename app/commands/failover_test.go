@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestFailover(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        string
+		withReplica bool
+		args        []shared.Value
+		expectedErr string
+	}{
+		{
+			name:        "FAILOVER on a replica",
+			role:        "slave",
+			args:        []shared.Value{},
+			expectedErr: "ERR FAILOVER is not valid when server is a replica.",
+		},
+		{
+			name:        "FAILOVER without replicas",
+			role:        "master",
+			args:        []shared.Value{},
+			expectedErr: "ERR FAILOVER requires connected replicas.",
+		},
+		{
+			name:        "FAILOVER TO unknown replica",
+			role:        "master",
+			withReplica: true,
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "TO"},
+				{Typ: "bulk", Bulk: "127.0.0.1"},
+				{Typ: "bulk", Bulk: "9999"},
+			},
+			expectedErr: "ERR FAILOVER target HOST and PORT is not a replica.",
+		},
+		{
+			name:        "FAILOVER FORCE without timeout",
+			role:        "master",
+			withReplica: true,
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "TO"},
+				{Typ: "bulk", Bulk: "127.0.0.1"},
+				{Typ: "bulk", Bulk: "6380"},
+				{Typ: "bulk", Bulk: "FORCE"},
+			},
+			expectedErr: "ERR FAILOVER with force option requires both a timeout and target HOST and IP.",
+		},
+		{
+			name:        "FAILOVER with invalid timeout",
+			role:        "master",
+			withReplica: true,
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "TIMEOUT"},
+				{Typ: "bulk", Bulk: "abc"},
+			},
+			expectedErr: "ERR FAILOVER timeout must be greater than 0",
+		},
+		{
+			name:        "FAILOVER ABORT without failover",
+			role:        "master",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "ABORT"}},
+			expectedErr: "ERR No failover in progress.",
+		},
+		{
+			name:        "FAILOVER with unknown option",
+			role:        "master",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "NOW"}},
+			expectedErr: "ERR syntax error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.SetStoreState(shared.State{
+				Role:     tt.role,
+				Replicas: make(map[string]net.Conn),
+			})
+			if tt.withReplica {
+				network.ReplicasSet("127.0.0.1:51234", &mockConn{})
+				network.ReplicaInfoUpdate("127.0.0.1:51234", func(info *shared.ReplicaInfo) {
+					info.ListeningPort = "6380"
+				})
+				defer network.ReplicasDelete("127.0.0.1:51234")
+			}
+
+			result := runCommand("FAILOVER", Failover, "test-conn", tt.args)
+			if result.Typ != "error" || result.Str != tt.expectedErr {
+				t.Errorf("Expected error %q, got %v", tt.expectedErr, result)
+			}
+			if network.WritesPaused() {
+				t.Errorf("Expected writes not to be paused after a rejected FAILOVER")
+			}
+		})
+	}
+}
+
+func TestFailoverAbort(t *testing.T) {
+	clearMemory()
+	initCommandHandlers()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		MasterReplID:     "test-repl-id",
+		MasterReplOffset: 100,
+		Replicas:         make(map[string]net.Conn),
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	// The replica never acknowledges the master offset, so the failover keeps waiting
+	network.ReplicasSet("127.0.0.1:51234", &mockConn{})
+	network.ReplicaInfoUpdate("127.0.0.1:51234", func(info *shared.ReplicaInfo) {
+		info.ListeningPort = "6380"
+	})
+	defer network.ReplicasDelete("127.0.0.1:51234")
+
+	result := Failover(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "TO"},
+		{Typ: "bulk", Bulk: "127.0.0.1"},
+		{Typ: "bulk", Bulk: "6380"},
+	})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if state := network.FailoverStateGet(); state != network.FailoverWaitForSync {
+		t.Errorf("Expected state %q, got %q", network.FailoverWaitForSync, state)
+	}
+
+	// Writes wait for the failover to finish or be aborted
+	done := make(chan shared.Value)
+	go func() {
+		done <- network.ExecuteAndPropagate("SET", "test-conn", []shared.Value{
+			{Typ: "bulk", Bulk: "key"},
+			{Typ: "bulk", Bulk: "value"},
+		})
+	}()
+	select {
+	case <-done:
+		t.Fatalf("Expected SET to wait while writes are paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	result = Failover(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "ABORT"}})
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+
+	select {
+	case result = <-done:
+		if result.Typ != "string" || result.Str != "OK" {
+			t.Errorf("Expected SET to succeed after ABORT, got %v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected SET to resume after ABORT")
+	}
+
+	if state := network.FailoverStateGet(); state != network.FailoverNone {
+		t.Errorf("Expected state %q, got %q", network.FailoverNone, state)
+	}
+	if server.StoreState.Role != "master" {
+		t.Errorf("Expected to still be master, got %q", server.StoreState.Role)
+	}
+}
+
+func TestPsyncFailoverPromotesReplica(t *testing.T) {
+	server.SetStoreState(shared.State{
+		Role:         "slave",
+		ReplicaOf:    "127.0.0.1 6379",
+		MasterReplID: "test-repl-id",
+		Replicas:     make(map[string]net.Conn),
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	result := Psync(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "other-repl-id"},
+		{Typ: "bulk", Bulk: "0"},
+		{Typ: "bulk", Bulk: "FAILOVER"},
+	})
+	if result.Typ != "error" || server.StoreState.Role != "slave" {
+		t.Fatalf("Expected a replid mismatch error, got %v", result)
+	}
+
+	result = Psync(network.ClientOf("test-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "test-repl-id"},
+		{Typ: "bulk", Bulk: "0"},
+		{Typ: "bulk", Bulk: "FAILOVER"},
+	})
+	if result.Typ != "string" || result.Str != "FULLRESYNC test-repl-id 0" {
+		t.Errorf("Expected FULLRESYNC, got %v", result)
+	}
+	if server.StoreState.Role != "master" || server.StoreState.ReplicaOf != "" {
+		t.Errorf("Expected the replica to be promoted, got role %q replicaof %q", server.StoreState.Role, server.StoreState.ReplicaOf)
+	}
+}
+
+// BenchmarkFailover benchmarks argument validation of the FAILOVER command
+func BenchmarkFailover(b *testing.B) {
+	server.SetStoreState(shared.State{
+		Role:     "slave",
+		Replicas: make(map[string]net.Conn),
+	})
+
+	args := []shared.Value{
+		{Typ: "bulk", Bulk: "TO"},
+		{Typ: "bulk", Bulk: "127.0.0.1"},
+		{Typ: "bulk", Bulk: "6380"},
+		{Typ: "bulk", Bulk: "TIMEOUT"},
+		{Typ: "bulk", Bulk: "1000"},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Failover(network.ClientOf("test-conn"), args)
+	}
+}
+
+func TestPausedWritesDontHoldUpExecutor(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	network.StartExecutor()
+	defer network.StopExecutor()
+
+	network.PauseWrites()
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		network.ExecuteAndPropagate("SET", "writer-conn", []shared.Value{{Typ: "bulk", Bulk: "paused"}, {Typ: "bulk", Bulk: "1"}})
+	}()
+
+	// Reads keep running on the executor while the write waits for the pause to end
+	read := make(chan shared.Value)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		read <- network.ExecuteAndPropagate("GET", "reader-conn", []shared.Value{{Typ: "bulk", Bulk: "paused"}})
+	}()
+	select {
+	case result := <-read:
+		if !result.IsNull() {
+			t.Errorf("Expected the paused write not to have run, got %v", result)
+		}
+	case <-time.After(time.Second):
+		network.UnpauseWrites()
+		t.Fatalf("Expected a read to run while writes are paused")
+	}
+
+	network.UnpauseWrites()
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the write to run once writes are unpaused")
+	}
+	if entry := getEntry("paused"); entry.Value != "1" {
+		t.Errorf("Expected the write to be applied, got %q", entry.Value)
+	}
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/lua"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Fcall handles the FCALL command
+// Usage: FCALL function numkeys [key [key ...]] [arg [arg ...]]
+// Returns: The value the function returns, converted to a reply like EVAL does.
+//
+// It runs a function a library registered with FUNCTION LOAD, passing it its keys and its
+// arguments as two tables. Functions run like EVAL scripts: atomically, stopped by FUNCTION KILL
+// once they run for too long, with their writes propagated as a MULTI/EXEC block.
+//
+// Examples:
+//
+//	FUNCTION LOAD "#!lua name=lib\nredis.register_function('get', function(keys) return redis.call('GET', keys[1]) end)"
+//	FCALL get 1 mykey  // Returns the value of mykey
+func Fcall(client *network.Client, args []shared.Value) shared.Value {
+	return fcall(client, args, false)
+}
+
+// FcallRo handles the FCALL_RO command
+// Usage: FCALL_RO function numkeys [key [key ...]] [arg [arg ...]]
+// Returns: The value the function returns.
+//
+// Only functions registered with the no-writes flag can be run, which can't call write commands.
+//
+// Examples:
+//
+//	FUNCTION LOAD "#!lua name=lib\nredis.register_function{function_name='get', callback=function(keys) return redis.call('GET', keys[1]) end, flags={'no-writes'}}"
+//	FCALL_RO get 1 mykey  // Returns the value of mykey
+func FcallRo(client *network.Client, args []shared.Value) shared.Value {
+	return fcall(client, args, true)
+}
+
+// fcall runs a function for FCALL and FCALL_RO
+func fcall(client *network.Client, args []shared.Value, readOnly bool) shared.Value {
+	keys, argv, errReply, ok := parseScriptKeys(args[1:])
+	if !ok {
+		return errReply
+	}
+	f, ok := currentRegistry().functions[args[0].Bulk]
+	if !ok {
+		return createErrorResponse("ERR Function not found")
+	}
+	noWrites := f.hasFlag("no-writes")
+	if readOnly && !noWrites {
+		return createErrorResponse("ERR Can not execute a script with write flag using *_ro command.")
+	}
+	return runScript(client, f.name, f.fn, true, noWrites, func(s *lua.State) []lua.Value {
+		return []lua.Value{stringsTable(keys), stringsTable(argv)}
+	})
+}
@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// Function handles the FUNCTION command
+// Usage: FUNCTION LOAD [REPLACE] code | FUNCTION DELETE library | FUNCTION FLUSH [ASYNC|SYNC] |
+// FUNCTION LIST [LIBRARYNAME pattern] [WITHCODE] | FUNCTION DUMP | FUNCTION RESTORE payload [FLUSH|APPEND|REPLACE] |
+// FUNCTION KILL
+// Returns: The name of the loaded library, the libraries with their functions, a payload, or OK.
+//
+// A library is Lua code starting with a shebang that names it, which registers functions with
+// redis.register_function for FCALL to run. Libraries are saved in RDB files with the dataset.
+// Changes to them are propagated to replicas and to the append only file as they are.
+// FUNCTION KILL stops the function in progress, like SCRIPT KILL stops a script.
+//
+// Examples:
+//
+//	FUNCTION LOAD "#!lua name=mylib\nredis.register_function('hello', function() return 'hi' end)"  // Returns mylib
+//	FUNCTION LIST LIBRARYNAME my*                                                                    // Returns mylib and its hello function
+//	FUNCTION DELETE mylib                                                                            // Returns OK
+func Function(client *network.Client, args []shared.Value) shared.Value {
+	var reply shared.Value
+	switch strings.ToUpper(args[0].Bulk) {
+	case "LOAD":
+		reply = functionLoad(args)
+	case "DELETE":
+		reply = functionDelete(args)
+	case "FLUSH":
+		reply = functionFlush(args)
+	case "RESTORE":
+		reply = functionRestore(args)
+	case "LIST":
+		return functionList(args[1:])
+	case "DUMP":
+		return shared.Value{Typ: "bulk", Bulk: string(storage.DumpFunctions(libraryCodes()))}
+	case "KILL":
+		return killScript(true)
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'function' command")
+	}
+
+	if reply.Typ != "error" {
+		propagateEffect(client, "FUNCTION", args)
+	}
+	return reply
+}
+
+// functionLoad handles FUNCTION LOAD [REPLACE] code
+func functionLoad(args []shared.Value) shared.Value {
+	replace := false
+	if len(args) == 3 && strings.EqualFold(args[1].Bulk, "REPLACE") {
+		replace = true
+	} else if len(args) != 2 {
+		return shared.ErrWrongArity("function|load")
+	}
+
+	lib, err := compileLibrary(args[len(args)-1].Bulk)
+	if err == nil {
+		err = updateRegistry(func(r *functionRegistry) error {
+			return r.add(lib, replace)
+		})
+	}
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "bulk", Bulk: lib.name}
+}
+
+// functionDelete handles FUNCTION DELETE library
+func functionDelete(args []shared.Value) shared.Value {
+	err := updateRegistry(func(r *functionRegistry) error {
+		if _, exists := r.libraries[args[1].Bulk]; !exists {
+			return errors.New("ERR Library not found")
+		}
+		r.remove(args[1].Bulk)
+		return nil
+	})
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// functionFlush handles FUNCTION FLUSH [ASYNC|SYNC]
+func functionFlush(args []shared.Value) shared.Value {
+	if len(args) > 2 {
+		return shared.ErrWrongArity("function|flush")
+	}
+	if len(args) == 2 {
+		if mode := strings.ToUpper(args[1].Bulk); mode != "ASYNC" && mode != "SYNC" {
+			return createErrorResponse("ERR FUNCTION FLUSH only supports SYNC|ASYNC option")
+		}
+	}
+	replaceLibraries(nil)
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// functionList handles FUNCTION LIST [LIBRARYNAME pattern] [WITHCODE]
+func functionList(args []shared.Value) shared.Value {
+	pattern := ""
+	withCode := false
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "WITHCODE":
+			if withCode {
+				return createErrorResponse("ERR Unknown argument or argument given more than once")
+			}
+			withCode = true
+		case "LIBRARYNAME":
+			if pattern != "" || i+1 >= len(args) {
+				return createErrorResponse("ERR library name argument was not given")
+			}
+			i++
+			pattern = args[i].Bulk
+		default:
+			return createErrorResponse("ERR Unknown argument " + args[i].Bulk)
+		}
+	}
+
+	result := []shared.Value{}
+	for _, lib := range currentRegistry().sortedLibraries() {
+		if pattern != "" {
+			if matched, _ := filepath.Match(pattern, lib.name); !matched {
+				continue
+			}
+		}
+
+		names := make([]string, 0, len(lib.functions))
+		for name := range lib.functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		functions := make([]shared.Value, len(names))
+		for i, name := range names {
+			f := lib.functions[name]
+			description := shared.Value{Typ: "null"}
+			if f.description != "" {
+				description = shared.Value{Typ: "bulk", Bulk: f.description}
+			}
+			flags := make([]shared.Value, len(f.flags))
+			for j, flag := range f.flags {
+				flags[j] = shared.Value{Typ: "bulk", Bulk: flag}
+			}
+			functions[i] = shared.Value{Typ: "map", Array: []shared.Value{
+				{Typ: "bulk", Bulk: "name"}, {Typ: "bulk", Bulk: f.name},
+				{Typ: "bulk", Bulk: "description"}, description,
+				{Typ: "bulk", Bulk: "flags"}, {Typ: "set", Array: flags},
+			}}
+		}
+
+		entry := []shared.Value{
+			{Typ: "bulk", Bulk: "library_name"}, {Typ: "bulk", Bulk: lib.name},
+			{Typ: "bulk", Bulk: "engine"}, {Typ: "bulk", Bulk: "LUA"},
+			{Typ: "bulk", Bulk: "functions"}, {Typ: "array", Array: functions},
+		}
+		if withCode {
+			entry = append(entry, shared.Value{Typ: "bulk", Bulk: "library_code"}, shared.Value{Typ: "bulk", Bulk: lib.code})
+		}
+		result = append(result, shared.Value{Typ: "map", Array: entry})
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
+
+// functionRestore handles FUNCTION RESTORE payload [FLUSH|APPEND|REPLACE]. APPEND, the
+// default, fails when a library already exists, REPLACE replaces it, and FLUSH deletes every
+// library first. Nothing is restored unless every library is.
+func functionRestore(args []shared.Value) shared.Value {
+	if len(args) > 3 {
+		return shared.ErrWrongArity("function|restore")
+	}
+	policy := "APPEND"
+	if len(args) == 3 {
+		policy = strings.ToUpper(args[2].Bulk)
+		if policy != "FLUSH" && policy != "APPEND" && policy != "REPLACE" {
+			return createErrorResponse("ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.")
+		}
+	}
+
+	codes, err := storage.RestoreFunctions([]byte(args[1].Bulk))
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	libs, err := compileLibraries(codes)
+	if err == nil {
+		err = updateRegistry(func(r *functionRegistry) error {
+			if policy == "FLUSH" {
+				*r = *newFunctionRegistry()
+			}
+			for _, lib := range libs {
+				if err := r.add(lib, policy == "REPLACE"); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+const testLibrary = `#!lua name=mylib
+local function incrby(keys, args)
+	return redis.call('INCR', keys[1]) + (tonumber(args[1]) or 0)
+end
+redis.register_function('my_incr', incrby)
+redis.register_function{
+	function_name = 'my_get',
+	callback = function(keys) return redis.call('GET', keys[1]) end,
+	flags = {'no-writes'},
+	description = 'Returns a key',
+}`
+
+func TestFunctionLoad(t *testing.T) {
+	replaceLibraries(nil)
+	defer replaceLibraries(nil)
+
+	if result := Function(network.ClientOf("test-conn"), evalArgs("LOAD", testLibrary)); !reflect.DeepEqual(result, bulk("mylib")) {
+		t.Fatalf("Expected mylib, got %+v", result)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"library exists", []string{"LOAD", testLibrary}, "ERR Library 'mylib' already exists"},
+		{"function of another library", []string{"LOAD", "#!lua name=other\nredis.register_function('my_get', function() end)"},
+			"ERR Function my_get already exists"},
+		{"missing metadata", []string{"LOAD", "redis.register_function('f', function() end)"}, "ERR Missing library metadata"},
+		{"unknown engine", []string{"LOAD", "#!python name=lib\n"}, "ERR Engine 'python' not found"},
+		{"missing name", []string{"LOAD", "#!lua\n"}, "ERR Library name was not given"},
+		{"invalid metadata", []string{"LOAD", "#!lua name=lib version=2\n"}, "ERR Invalid metadata value given: version=2"},
+		{"invalid name", []string{"LOAD", "#!lua name=my-lib\n"},
+			"ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long"},
+		{"no functions", []string{"LOAD", "#!lua name=empty\nlocal x = 1"}, "ERR No functions registered"},
+		{"compile error", []string{"LOAD", "#!lua name=bad\nlocal = 1"},
+			"ERR Error compiling function: user_function:2: '<name>' expected near '='"},
+		{"commands at load", []string{"LOAD", "#!lua name=bad\nredis.call('SET', 'a', 'b')"},
+			"ERR Error registering functions: user_function:2: attempt to call field 'call' (a nil value)"},
+		{"unknown flag", []string{"LOAD", "#!lua name=bad\nredis.register_function{function_name='f', callback=function() end, flags={'fast'}}"},
+			"ERR Error registering functions: user_function:2: unknown flag given"},
+		{"duplicate function", []string{"LOAD", "#!lua name=bad\nredis.register_function('f', function() end)\nredis.register_function('f', function() end)"},
+			"ERR Error registering functions: user_function:3: Function already exists in the library"},
+		{"wrong arity", []string{"LOAD"}, "ERR wrong number of arguments for 'function|load' command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("FUNCTION", Function, "test-conn", evalArgs(tt.args...))
+			if result.Typ != "error" || result.Str != tt.expected {
+				t.Errorf("Expected error %q, got %+v", tt.expected, result)
+			}
+		})
+	}
+
+	// REPLACE swaps the library and its functions
+	replacement := "#!lua name=mylib\nredis.register_function('other', function() return 1 end)"
+	if result := Function(network.ClientOf("test-conn"), evalArgs("LOAD", "REPLACE", replacement)); !reflect.DeepEqual(result, bulk("mylib")) {
+		t.Fatalf("Expected mylib, got %+v", result)
+	}
+	if _, exists := currentRegistry().functions["my_get"]; exists {
+		t.Error("Expected the functions of the replaced library to be removed")
+	}
+	if _, exists := currentRegistry().functions["other"]; !exists {
+		t.Error("Expected the functions of the new library to be registered")
+	}
+}
+
+func TestFunctionListDeleteFlush(t *testing.T) {
+	replaceLibraries(nil)
+	defer replaceLibraries(nil)
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", testLibrary))
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", "#!lua name=zlib\nredis.register_function('z', function() end)"))
+
+	str := func(s string) shared.Value { return bulk(s) }
+	expected := shared.Value{Typ: "array", Array: []shared.Value{{Typ: "map", Array: []shared.Value{
+		str("library_name"), str("mylib"),
+		str("engine"), str("LUA"),
+		str("functions"), {Typ: "array", Array: []shared.Value{
+			{Typ: "map", Array: []shared.Value{str("name"), str("my_get"), str("description"), str("Returns a key"),
+				str("flags"), {Typ: "set", Array: []shared.Value{str("no-writes")}}}},
+			{Typ: "map", Array: []shared.Value{str("name"), str("my_incr"), str("description"), {Typ: "null"},
+				str("flags"), {Typ: "set", Array: []shared.Value{}}}},
+		}},
+		str("library_code"), str(testLibrary),
+	}}}}
+	if result := Function(network.ClientOf("test-conn"), evalArgs("LIST", "LIBRARYNAME", "my*", "WITHCODE")); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+	if result := Function(network.ClientOf("test-conn"), evalArgs("LIST")); len(result.Array) != 2 {
+		t.Errorf("Expected 2 libraries, got %+v", result)
+	}
+
+	if result := Function(network.ClientOf("test-conn"), evalArgs("DELETE", "mylib")); result.Str != "OK" {
+		t.Errorf("Expected OK, got %+v", result)
+	}
+	if result := Function(network.ClientOf("test-conn"), evalArgs("DELETE", "mylib")); result.Str != "ERR Library not found" {
+		t.Errorf("Expected Library not found, got %+v", result)
+	}
+	if _, exists := currentRegistry().functions["my_incr"]; exists {
+		t.Error("Expected the functions of the deleted library to be removed")
+	}
+
+	if result := Function(network.ClientOf("test-conn"), evalArgs("FLUSH", "SYNC")); result.Str != "OK" {
+		t.Errorf("Expected OK, got %+v", result)
+	}
+	if result := Function(network.ClientOf("test-conn"), evalArgs("LIST")); len(result.Array) != 0 {
+		t.Errorf("Expected no library after FLUSH, got %+v", result)
+	}
+}
+
+func TestFunctionDumpRestore(t *testing.T) {
+	replaceLibraries(nil)
+	defer replaceLibraries(nil)
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", testLibrary))
+	payload := Function(network.ClientOf("test-conn"), evalArgs("DUMP")).Bulk
+
+	// APPEND refuses libraries that already exist, REPLACE replaces them
+	if result := Function(network.ClientOf("test-conn"), evalArgs("RESTORE", payload)); result.Str != "ERR Library 'mylib' already exists" {
+		t.Errorf("Expected the library to already exist, got %+v", result)
+	}
+	if result := Function(network.ClientOf("test-conn"), evalArgs("RESTORE", payload, "REPLACE")); result.Str != "OK" {
+		t.Errorf("Expected OK, got %+v", result)
+	}
+
+	// FLUSH removes the libraries missing from the payload
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", "#!lua name=extra\nredis.register_function('extra', function() end)"))
+	if result := Function(network.ClientOf("test-conn"), evalArgs("RESTORE", payload, "FLUSH")); result.Str != "OK" {
+		t.Errorf("Expected OK, got %+v", result)
+	}
+	if codes := libraryCodes(); !reflect.DeepEqual(codes, []string{testLibrary}) {
+		t.Errorf("Expected only mylib after RESTORE FLUSH, got %q", codes)
+	}
+
+	if result := Function(network.ClientOf("test-conn"), evalArgs("RESTORE", "garbage")); result.Str != "ERR payload version or checksum are wrong" {
+		t.Errorf("Expected a payload error, got %+v", result)
+	}
+	if result := Function(network.ClientOf("test-conn"), evalArgs("RESTORE", payload, "MERGE")); result.Typ != "error" {
+		t.Errorf("Expected an error for an unknown policy, got %+v", result)
+	}
+}
+
+func TestFunctionPropagation(t *testing.T) {
+	replaceLibraries(nil)
+	defer replaceLibraries(nil)
+
+	replica := &recordingConn{}
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	network.ReplicasSet("replica-1", replica)
+	defer network.ReplicasDelete("replica-1")
+
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", testLibrary))
+	if !strings.HasPrefix(replica.String(), "*3\r\n$8\r\nFUNCTION\r\n$4\r\nLOAD\r\n") {
+		t.Errorf("Expected FUNCTION LOAD to be propagated, got %q", replica.String())
+	}
+
+	// Failed changes and reads are not propagated
+	replica.Reset()
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", testLibrary))
+	Function(network.ClientOf("test-conn"), evalArgs("LIST"))
+	Function(network.ClientOf("test-conn"), evalArgs("DUMP"))
+	if replica.Len() != 0 {
+		t.Errorf("Expected nothing to be propagated, got %q", replica.String())
+	}
+}
+
+func TestFcall(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	replaceLibraries(nil)
+	defer replaceLibraries(nil)
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", testLibrary))
+	server.Memory.Set("str", shared.MemoryEntry{Value: "abc"})
+
+	if result := Fcall(network.ClientOf("test-conn"), evalArgs("my_incr", "1", "counter", "10")); !reflect.DeepEqual(result, integer(11)) {
+		t.Errorf("Expected 11, got %+v", result)
+	}
+	if result := Fcall(network.ClientOf("test-conn"), evalArgs("my_get", "1", "counter")); !reflect.DeepEqual(result, bulk("1")) {
+		t.Errorf("Expected 1, got %+v", result)
+	}
+	if result := FcallRo(network.ClientOf("test-conn"), evalArgs("my_get", "1", "counter")); !reflect.DeepEqual(result, bulk("1")) {
+		t.Errorf("Expected 1, got %+v", result)
+	}
+
+	tests := []struct {
+		name     string
+		result   shared.Value
+		expected string
+	}{
+		{"unknown function", Fcall(network.ClientOf("test-conn"), evalArgs("nope", "0")), "ERR Function not found"},
+		{"numkeys", Fcall(network.ClientOf("test-conn"), evalArgs("my_get", "2", "a")), "ERR Number of keys can't be greater than number of args"},
+		{"write function with FCALL_RO", FcallRo(network.ClientOf("test-conn"), evalArgs("my_incr", "1", "counter")),
+			"ERR Can not execute a script with write flag using *_ro command."},
+		{"error of a function", Fcall(network.ClientOf("test-conn"), evalArgs("my_incr", "1", "str")),
+			"ERR value is not an integer or out of range script: my_incr, on @user_function:3."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.result.Typ != "error" || tt.result.Str != tt.expected {
+				t.Errorf("Expected error %q, got %+v", tt.expected, tt.result)
+			}
+		})
+	}
+
+	// A no-writes function can't call write commands
+	Function(network.ClientOf("test-conn"), evalArgs("LOAD", "#!lua name=ro\nredis.register_function{function_name='ro_set', callback=function(keys) return redis.call('SET', keys[1], 'x') end, flags={'no-writes'}}"))
+	result := Fcall(network.ClientOf("test-conn"), evalArgs("ro_set", "1", "key"))
+	if result.Typ != "error" || !strings.HasPrefix(result.Str, "ERR Write commands are not allowed from read-only scripts.") {
+		t.Errorf("Expected the write to be refused, got %+v", result)
+	}
+	if _, exists := server.Memory.Get("key"); exists {
+		t.Error("Expected the key not to be set")
+	}
+}
@@ -0,0 +1,329 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/lua"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// functionChunk is the name of function libraries in their error messages
+const functionChunk = "user_function"
+
+// functionFlags are the flags redis.register_function accepts. Only no-writes changes how a
+// function runs here, the others are kept for FUNCTION LIST.
+var functionFlags = []string{"no-writes", "allow-oom", "allow-stale", "no-cluster", "allow-cross-slot-keys"}
+
+// functionLibrary is a library of functions loaded by FUNCTION LOAD
+type functionLibrary struct {
+	name      string
+	code      string
+	functions map[string]*scriptFunction
+}
+
+// scriptFunction is a function a library registers, which FCALL runs
+type scriptFunction struct {
+	name        string
+	description string // Empty when the library didn't give one
+	flags       []string
+	fn          *lua.Function
+	library     *functionLibrary
+}
+
+// hasFlag reports whether the function was registered with a flag
+func (f *scriptFunction) hasFlag(flag string) bool {
+	for _, fl := range f.flags {
+		if fl == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// functionRegistry holds the loaded libraries and the functions they register, by name. It is
+// never changed once published: changes are made to a clone which replaces it.
+type functionRegistry struct {
+	libraries map[string]*functionLibrary
+	functions map[string]*scriptFunction
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = newFunctionRegistry()
+)
+
+func init() {
+	storage.SetFunctionLibraries(libraryCodes, replaceLibraries)
+}
+
+func newFunctionRegistry() *functionRegistry {
+	return &functionRegistry{
+		libraries: make(map[string]*functionLibrary),
+		functions: make(map[string]*scriptFunction),
+	}
+}
+
+// currentRegistry returns the published registry
+func currentRegistry() *functionRegistry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry
+}
+
+// updateRegistry applies change to a copy of the registry and publishes it unless change fails
+func updateRegistry(change func(r *functionRegistry) error) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	r := newFunctionRegistry()
+	for name, lib := range registry.libraries {
+		r.libraries[name] = lib
+	}
+	for name, f := range registry.functions {
+		r.functions[name] = f
+	}
+	if err := change(r); err != nil {
+		return err
+	}
+	registry = r
+	return nil
+}
+
+// add adds a library, replacing the one with the same name when replace is set. None of its
+// functions may belong to another library.
+func (r *functionRegistry) add(lib *functionLibrary, replace bool) error {
+	if _, exists := r.libraries[lib.name]; exists && !replace {
+		return fmt.Errorf("ERR Library '%s' already exists", lib.name)
+	}
+	for name := range lib.functions {
+		if f, exists := r.functions[name]; exists && f.library.name != lib.name {
+			return fmt.Errorf("ERR Function %s already exists", name)
+		}
+	}
+	r.remove(lib.name)
+	r.libraries[lib.name] = lib
+	for name, f := range lib.functions {
+		r.functions[name] = f
+	}
+	return nil
+}
+
+// remove removes a library with its functions
+func (r *functionRegistry) remove(name string) {
+	lib, exists := r.libraries[name]
+	if !exists {
+		return
+	}
+	for fname := range lib.functions {
+		delete(r.functions, fname)
+	}
+	delete(r.libraries, name)
+}
+
+// sortedLibraries returns the libraries sorted by name
+func (r *functionRegistry) sortedLibraries() []*functionLibrary {
+	libs := make([]*functionLibrary, 0, len(r.libraries))
+	for _, lib := range r.libraries {
+		libs = append(libs, lib)
+	}
+	sort.Slice(libs, func(i, j int) bool { return libs[i].name < libs[j].name })
+	return libs
+}
+
+// libraryCodes returns the code of every library, which RDB files are saved with
+func libraryCodes() []string {
+	libs := currentRegistry().sortedLibraries()
+	codes := make([]string, len(libs))
+	for i, lib := range libs {
+		codes[i] = lib.code
+	}
+	return codes
+}
+
+// replaceLibraries replaces every library with the ones loaded from codes
+func replaceLibraries(codes []string) error {
+	libs, err := compileLibraries(codes)
+	if err != nil {
+		return err
+	}
+	return updateRegistry(func(r *functionRegistry) error {
+		*r = *newFunctionRegistry()
+		for _, lib := range libs {
+			if err := r.add(lib, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// compileLibraries loads the libraries of codes, stopping at the first that fails
+func compileLibraries(codes []string) ([]*functionLibrary, error) {
+	libs := make([]*functionLibrary, len(codes))
+	for i, code := range codes {
+		lib, err := compileLibrary(code)
+		if err != nil {
+			return nil, err
+		}
+		libs[i] = lib
+	}
+	return libs, nil
+}
+
+// compileLibrary loads the code of a library. It starts with a shebang naming the engine and
+// the library, like #!lua name=mylib, and registers its functions with redis.register_function
+// when it runs.
+func compileLibrary(code string) (*functionLibrary, error) {
+	shebang, body, _ := strings.Cut(code, "\n")
+	if !strings.HasPrefix(shebang, "#!") {
+		return nil, errors.New("ERR Missing library metadata")
+	}
+	parts := strings.Fields(shebang[2:])
+	if len(parts) == 0 || !strings.EqualFold(parts[0], "lua") {
+		engine := ""
+		if len(parts) > 0 {
+			engine = parts[0]
+		}
+		return nil, fmt.Errorf("ERR Engine '%s' not found", engine)
+	}
+	lib := &functionLibrary{code: code, functions: make(map[string]*scriptFunction)}
+	for _, part := range parts[1:] {
+		name, found := strings.CutPrefix(part, "name=")
+		if !found {
+			return nil, fmt.Errorf("ERR Invalid metadata value given: %s", part)
+		}
+		lib.name = name
+	}
+	if lib.name == "" {
+		return nil, errors.New("ERR Library name was not given")
+	}
+	if !validFunctionName(lib.name) {
+		return nil, errors.New("ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+
+	// The shebang line is kept empty, so line numbers in errors match the code
+	fn, err := lua.Compile(functionChunk, "\n"+body)
+	if err != nil {
+		return nil, errors.New("ERR Error compiling function: " + err.Error())
+	}
+
+	// Only functions can be registered while the library loads, no command can run
+	s := lua.NewState()
+	redis := lua.NewTable()
+	redis.Set("register_function", lua.NewFunction("register_function", func(s *lua.State, args []lua.Value) ([]lua.Value, error) {
+		return nil, registerFunction(lib, args)
+	}))
+	setLogFunctions(redis)
+	redis.SetReadOnly()
+	s.Globals.Set("redis", redis)
+	s.StrictGlobals = true
+	if _, err := s.Call(fn); err != nil {
+		return nil, errors.New("ERR Error registering functions: " + err.Error())
+	}
+
+	if len(lib.functions) == 0 {
+		return nil, errors.New("ERR No functions registered")
+	}
+	return lib, nil
+}
+
+// registerFunction implements redis.register_function, called with a name and a callback or
+// with a table of named arguments: function_name, callback, flags and description
+func registerFunction(lib *functionLibrary, args []lua.Value) error {
+	f := &scriptFunction{library: lib}
+	var name, callback lua.Value
+	switch len(args) {
+	case 1:
+		t, ok := args[0].(*lua.Table)
+		if !ok {
+			return errors.New("calling redis.register_function with a single argument is only applicable to Lua table (representing named arguments).")
+		}
+		var err error
+		t.ForEach(func(key lua.Value, value lua.Value) {
+			if err != nil {
+				return
+			}
+			switch key {
+			case "function_name":
+				name = value
+			case "callback":
+				callback = value
+			case "description":
+				description, ok := value.(string)
+				if !ok {
+					err = errors.New("description argument given to redis.register_function must be a string")
+				}
+				f.description = description
+			case "flags":
+				f.flags, err = functionFlagsOf(value)
+			default:
+				err = errors.New("unknown argument given to redis.register_function")
+			}
+		})
+		if err != nil {
+			return err
+		}
+	case 2:
+		name, callback = args[0], args[1]
+	default:
+		return errors.New("wrong number of arguments to redis.register_function")
+	}
+
+	var ok bool
+	if f.name, ok = name.(string); !ok {
+		return errors.New("function_name argument given to redis.register_function must be a string")
+	}
+	if f.fn, ok = callback.(*lua.Function); !ok {
+		return errors.New("callback argument given to redis.register_function must be a function")
+	}
+	if !validFunctionName(f.name) {
+		return errors.New("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	if _, exists := lib.functions[f.name]; exists {
+		return errors.New("Function already exists in the library")
+	}
+	lib.functions[f.name] = f
+	return nil
+}
+
+// functionFlagsOf returns the flags of the table given to redis.register_function
+func functionFlagsOf(value lua.Value) ([]string, error) {
+	t, ok := value.(*lua.Table)
+	if !ok {
+		return nil, errors.New("flags argument to redis.register_function must be a table representing function flags")
+	}
+	flags := []string{}
+	for i := 1; ; i++ {
+		flag := t.Get(float64(i))
+		if flag == nil {
+			return flags, nil
+		}
+		str, ok := flag.(string)
+		known := false
+		for _, name := range functionFlags {
+			known = known || str == name
+		}
+		if !ok || !known {
+			return nil, errors.New("unknown flag given")
+		}
+		flags = append(flags, str)
+	}
+}
+
+// validFunctionName reports whether a library or function name is made of letters, digits
+// and underscores only
+func validFunctionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
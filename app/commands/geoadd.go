@@ -3,8 +3,10 @@ package commands
 import (
 	"strconv"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 const (
@@ -63,29 +65,17 @@ func encodeGeohash(latitude, longitude float64) uint64 {
 // If the key does not exist, it is created as an empty sorted set.
 // If the key exists but is not a sorted set, it is converted to a sorted set before the operation.
 // The longitude and latitude are stored as floats.
-func Geoadd(connID string, args []shared.Value) shared.Value {
-	if len(args) < 4 || (len(args)-1)%3 != 0 {
-		return createErrorResponse("ERR wrong number of arguments for 'geoadd' command")
+func Geoadd(client *network.Client, args []shared.Value) shared.Value {
+	if (len(args)-1)%3 != 0 {
+		return shared.ErrWrongArity("geoadd")
 	}
 
-	key := args[0].Bulk
-	entry, exists := server.Memory[key]
-
-	if !exists {
-		entry = shared.MemoryEntry{SortedSet: shared.NewSortedSet(), Expires: 0}
-	}
-
-	if entry.SortedSet == nil {
-		entry.SortedSet = shared.NewSortedSet()
-	}
-
-	newElementsCount := 0
-
-	// Process longitude-latitude-member triplets
+	// Process longitude-latitude-member triplets, all of them before adding any, so nothing
+	// is added when one of them is invalid
+	scores := make([]float64, 0, (len(args)-1)/3)
 	for i := 1; i < len(args); i += 3 {
 		longitudeStr := args[i].Bulk
 		latitudeStr := args[i+1].Bulk
-		member := args[i+2].Bulk
 
 		// Parse longitude
 		longitude, err := strconv.ParseFloat(longitudeStr, 64)
@@ -110,14 +100,40 @@ func Geoadd(connID string, args []shared.Value) shared.Value {
 		}
 
 		// Convert latitude and longitude to geohash score
-		score := encodeGeohash(latitude, longitude)
+		scores = append(scores, float64(encodeGeohash(latitude, longitude)))
+	}
 
-		// Add member to sorted set with geohash score
-		if entry.SortedSet.Add(member, float64(score)) {
-			newElementsCount++
+	key := args[0].Bulk
+	newElementsCount := 0
+	changedCount := 0
+
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		storage.CopyOnWrite(key)
+		if !exists {
+			entry = shared.MemoryEntry{SortedSet: shared.NewSortedSet(), Expires: 0}
 		}
-	}
 
-	server.Memory[key] = entry
+		if entry.SortedSet == nil {
+			entry.SortedSet = shared.NewSortedSet()
+		}
+
+		for i, score := range scores {
+			member := args[3*i+3].Bulk
+
+			// Add member to sorted set with geohash score, tracking whether anything actually changed
+			oldScore, existed := entry.SortedSet.GetScore(member)
+			if entry.SortedSet.Add(member, score) {
+				newElementsCount++
+			}
+			if !existed || oldScore != score {
+				changedCount++
+			}
+		}
+		return entry, true
+	})
+	if changedCount > 0 {
+		server.NotifyKeyModified(0, key, "geoadd")
+	}
+	client.MarkDirty(changedCount)
 	return shared.Value{Typ: "integer", Num: newElementsCount}
 }
@@ -3,6 +3,7 @@ package commands
 import (
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -29,7 +30,7 @@ func TestGeoadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["places"]
+				entry, exists := server.Memory.Get("places")
 				if !exists {
 					t.Error("Key should exist after GEOADD")
 				}
@@ -64,7 +65,7 @@ func TestGeoadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 2},
 			verify: func() {
-				entry, exists := server.Memory["places"]
+				entry, exists := server.Memory.Get("places")
 				if !exists {
 					t.Error("Key should exist after GEOADD")
 				}
@@ -91,7 +92,7 @@ func TestGeoadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["places"]
+				entry, exists := server.Memory.Get("places")
 				if !exists {
 					t.Error("Key should exist after GEOADD")
 				}
@@ -117,7 +118,7 @@ func TestGeoadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["places"]
+				entry, exists := server.Memory.Get("places")
 				if !exists {
 					t.Error("Key should exist after GEOADD")
 				}
@@ -143,7 +144,7 @@ func TestGeoadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["places"]
+				entry, exists := server.Memory.Get("places")
 				if !exists {
 					t.Error("Key should exist after GEOADD")
 				}
@@ -169,7 +170,7 @@ func TestGeoadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["places"]
+				entry, exists := server.Memory.Get("places")
 				if !exists {
 					t.Error("Key should exist after GEOADD")
 				}
@@ -288,7 +289,7 @@ func TestGeoadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 0}, // 0 because it's an update, not new
 			verify: func() {
-				entry, exists := server.Memory["places"]
+				entry, exists := server.Memory.Get("places")
 				if !exists {
 					t.Error("Key should exist after GEOADD")
 				}
@@ -314,14 +315,14 @@ func TestGeoadd(t *testing.T) {
 
 			// Set up initial data for update test
 			if tt.name == "geoadd update existing member" {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["places"].SortedSet.Add("existing", 1.0)
+				})
+				getEntry("places").SortedSet.Add("existing", 1.0)
 			}
 
-			result := Geoadd(tt.connID, tt.args)
+			result := runCommand("GEOADD", Geoadd, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Geoadd() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -353,7 +354,7 @@ func BenchmarkGeoadd(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Geoadd(connID, args)
+		Geoadd(network.ClientOf(connID), args)
 	}
 }
 
@@ -373,7 +374,7 @@ func BenchmarkGeoaddMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Geoadd(connID, args)
+		Geoadd(network.ClientOf(connID), args)
 	}
 }
 
@@ -390,6 +391,6 @@ func BenchmarkGeoaddBoundary(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Geoadd(connID, args)
+		Geoadd(network.ClientOf(connID), args)
 	}
 }
@@ -3,7 +3,10 @@ package commands
 import (
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -45,15 +48,16 @@ func geohashGetDistance(lon1d, lat1d, lon2d, lat2d float64) float64 {
 
 // Geodist handles the GEODIST command.
 // Usage: GEODIST key member1 member2 [unit]
-// Returns: The distance between the two members in meters (or specified unit) as a bulk string.
+// Returns: The distance between the two members in meters (or specified unit) as a double,
+// a bulk string with 4 decimals under RESP2.
 //
 // This command returns the distance between two members of a geospatial sorted set.
 // The distance is calculated using the Haversine formula.
 // If either member doesn't exist, returns null.
 // If the key doesn't exist, returns null.
-func Geodist(connID string, args []shared.Value) shared.Value {
-	if len(args) < 3 || len(args) > 4 {
-		return createErrorResponse("ERR wrong number of arguments for 'geodist' command")
+func Geodist(client *network.Client, args []shared.Value) shared.Value {
+	if len(args) > 4 {
+		return shared.ErrWrongArity("geodist")
 	}
 
 	key := args[0].Bulk
@@ -63,12 +67,12 @@ func Geodist(connID string, args []shared.Value) shared.Value {
 	// Optional unit parameter (defaults to meters)
 	unit := "m"
 	if len(args) == 4 {
-		unit = args[3].Bulk
+		unit = strings.ToLower(args[3].Bulk)
 	}
 
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 	if !exists || entry.SortedSet == nil {
-		return shared.Value{Typ: "null_bulk", Str: ""}
+		return shared.Null()
 	}
 
 	// Get scores for both members
@@ -76,7 +80,7 @@ func Geodist(connID string, args []shared.Value) shared.Value {
 	score2, member2Exists := entry.SortedSet.GetScore(member2)
 
 	if !member1Exists || !member2Exists {
-		return shared.Value{Typ: "null_bulk", Str: ""}
+		return shared.Null()
 	}
 
 	// Decode geohash scores to get coordinates
@@ -100,7 +104,8 @@ func Geodist(connID string, args []shared.Value) shared.Value {
 		return createErrorResponse("ERR unsupported unit provided")
 	}
 
-	// Format the result with appropriate precision
+	// The distance is rounded to 4 decimals, which RESP2 clients get as text
 	result := fmt.Sprintf("%.4f", distance)
-	return shared.Value{Typ: "bulk", Bulk: result}
+	rounded, _ := strconv.ParseFloat(result, 64)
+	return shared.Value{Typ: "double", Double: rounded, Str: result}
 }
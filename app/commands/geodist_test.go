@@ -23,16 +23,16 @@ func TestGeodist(t *testing.T) {
 				{Typ: "bulk", Bulk: "Munich"},
 				{Typ: "bulk", Bulk: "Paris"},
 			},
-			expected: shared.Value{Typ: "bulk", Bulk: "682477.7582"},
+			expected: shared.Value{Typ: "double", Double: 682477.7582, Str: "682477.7582"},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 				// Munich: 11.5030378, 48.164271 -> score: 3672376881541190
 				// Paris: 2.2944692, 48.8584625 -> score: 3663832614298053
-				server.Memory["places"].SortedSet.Add("Munich", 3672376881541190.0)
-				server.Memory["places"].SortedSet.Add("Paris", 3663832614298053.0)
+				getEntry("places").SortedSet.Add("Munich", 3672376881541190.0)
+				getEntry("places").SortedSet.Add("Paris", 3663832614298053.0)
 			},
 		},
 		{
@@ -43,13 +43,13 @@ func TestGeodist(t *testing.T) {
 				{Typ: "bulk", Bulk: "NonExistent"},
 				{Typ: "bulk", Bulk: "Paris"},
 			},
-			expected: shared.Value{Typ: "null_bulk", Str: ""},
+			expected: shared.Null(),
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["places"].SortedSet.Add("Paris", 3663832614298053.0)
+				})
+				getEntry("places").SortedSet.Add("Paris", 3663832614298053.0)
 			},
 		},
 		{
@@ -60,7 +60,7 @@ func TestGeodist(t *testing.T) {
 				{Typ: "bulk", Bulk: "Munich"},
 				{Typ: "bulk", Bulk: "Paris"},
 			},
-			expected: shared.Value{Typ: "null_bulk", Str: ""},
+			expected: shared.Null(),
 			setup:    func() {},
 		},
 		{
@@ -78,13 +78,13 @@ func TestGeodist(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear memory
-			server.Memory = make(map[string]shared.MemoryEntry)
+			server.Memory.Clear()
 
 			// Setup test data
 			tt.setup()
 
 			// Execute command
-			result := Geodist(tt.connID, tt.args)
+			result := runCommand("GEODIST", Geodist, tt.connID, tt.args)
 
 			// Check result type
 			if result.Typ != tt.expected.Typ {
@@ -96,12 +96,9 @@ func TestGeodist(t *testing.T) {
 				t.Errorf("Geodist() error = %v, expected %v", result.Str, tt.expected.Str)
 			}
 
-			if result.Typ == "bulk" && result.Bulk != tt.expected.Bulk {
-				t.Errorf("Geodist() bulk = %v, expected %v", result.Bulk, tt.expected.Bulk)
-			}
-
-			if result.Typ == "null_bulk" && result.Str != tt.expected.Str {
-				t.Errorf("Geodist() null_bulk = %v, expected %v", result.Str, tt.expected.Str)
+			// RESP2 clients get the distance with 4 decimals
+			if result.Typ == "double" && (result.Double != tt.expected.Double || string(result.Marshal()) != string(tt.expected.Marshal())) {
+				t.Errorf("Geodist() double = %v (%q), expected %v", result.Double, result.Marshal(), tt.expected.Double)
 			}
 		})
 	}
@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -42,13 +43,13 @@ func decodeGeohash(score uint64) (latitude, longitude float64) {
 // - If the member exists: returns [longitude, latitude] as bulk strings
 // - If the member doesn't exist: returns null
 // - If the key doesn't exist: returns null for all members
-func Geopos(connID string, args []shared.Value) shared.Value {
+func Geopos(client *network.Client, args []shared.Value) shared.Value {
 	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'geopos' command")
+		return shared.ErrWrongArity("geopos")
 	}
 
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	// Create result array with one entry for each requested member
 	result := make([]shared.Value, len(args)-1)
@@ -58,7 +59,7 @@ func Geopos(connID string, args []shared.Value) shared.Value {
 
 		// If key doesn't exist or doesn't hold a sorted set, return null array
 		if !exists || entry.SortedSet == nil {
-			result[i-1] = shared.Value{Typ: "null_array", Str: ""}
+			result[i-1] = shared.NullArray()
 			continue
 		}
 
@@ -67,7 +68,7 @@ func Geopos(connID string, args []shared.Value) shared.Value {
 
 		if !memberExists {
 			// Member doesn't exist, return null array
-			result[i-1] = shared.Value{Typ: "null_array", Str: ""}
+			result[i-1] = shared.NullArray()
 			continue
 		}
 
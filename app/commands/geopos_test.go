@@ -3,6 +3,7 @@ package commands
 import (
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -39,11 +40,11 @@ func TestGeopos(t *testing.T) {
 			},
 			setup: func() {
 				// Add London with coordinates -0.0884948, 51.506479 -> score: 2163557758834106
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["places"].SortedSet.Add("London", 2163557758834106.0)
+				})
+				getEntry("places").SortedSet.Add("London", 2163557758834106.0)
 			},
 		},
 		{
@@ -74,12 +75,12 @@ func TestGeopos(t *testing.T) {
 				},
 			},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["places"].SortedSet.Add("London", 2163557758834106.0)
-				server.Memory["places"].SortedSet.Add("Munich", 3672376881541190.0)
+				})
+				getEntry("places").SortedSet.Add("London", 2163557758834106.0)
+				getEntry("places").SortedSet.Add("Munich", 3672376881541190.0)
 			},
 		},
 		{
@@ -92,15 +93,15 @@ func TestGeopos(t *testing.T) {
 			expected: shared.Value{
 				Typ: "array",
 				Array: []shared.Value{
-					{Typ: "null_array", Str: ""},
+					shared.NullArray(),
 				},
 			},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["places"].SortedSet.Add("London", 2163557758834106.0)
+				})
+				getEntry("places").SortedSet.Add("London", 2163557758834106.0)
 			},
 		},
 		{
@@ -113,7 +114,7 @@ func TestGeopos(t *testing.T) {
 			expected: shared.Value{
 				Typ: "array",
 				Array: []shared.Value{
-					{Typ: "null_array", Str: ""},
+					shared.NullArray(),
 				},
 			},
 			setup: func() {},
@@ -137,7 +138,7 @@ func TestGeopos(t *testing.T) {
 							{Typ: "bulk", Bulk: "51.5064781413993"},
 						},
 					},
-					{Typ: "null_array", Str: ""},
+					shared.NullArray(),
 					{
 						Typ: "array",
 						Array: []shared.Value{
@@ -148,12 +149,12 @@ func TestGeopos(t *testing.T) {
 				},
 			},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["places"].SortedSet.Add("London", 2163557758834106.0)
-				server.Memory["places"].SortedSet.Add("Munich", 3672376881541190.0)
+				})
+				getEntry("places").SortedSet.Add("London", 2163557758834106.0)
+				getEntry("places").SortedSet.Add("Munich", 3672376881541190.0)
 			},
 		},
 		{
@@ -175,16 +176,16 @@ func TestGeopos(t *testing.T) {
 			expected: shared.Value{
 				Typ: "array",
 				Array: []shared.Value{
-					{Typ: "null_array", Str: ""},
+					shared.NullArray(),
 				},
 			},
 			setup: func() {
 				// Set up a key that doesn't hold a sorted set
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					Value:     "not a sorted set",
 					SortedSet: nil,
 					Expires:   0,
-				}
+				})
 			},
 		},
 		{
@@ -207,12 +208,12 @@ func TestGeopos(t *testing.T) {
 				},
 			},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 				// Boundary longitude positive: 180, 0 -> score: 10133099161583616
-				server.Memory["places"].SortedSet.Add("Boundary", 10133099161583616.0)
+				getEntry("places").SortedSet.Add("Boundary", 10133099161583616.0)
 			},
 		},
 	}
@@ -222,7 +223,7 @@ func TestGeopos(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Geopos(tt.connID, tt.args)
+			result := runCommand("GEOPOS", Geopos, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Geopos() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -274,12 +275,12 @@ func BenchmarkGeopos(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("London", 2163557758834106.0)
-	server.Memory["benchkey"].SortedSet.Add("Munich", 3672376881541190.0)
+	})
+	getEntry("benchkey").SortedSet.Add("London", 2163557758834106.0)
+	getEntry("benchkey").SortedSet.Add("Munich", 3672376881541190.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -289,7 +290,7 @@ func BenchmarkGeopos(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Geopos(connID, args)
+		Geopos(network.ClientOf(connID), args)
 	}
 }
 
@@ -297,12 +298,12 @@ func BenchmarkGeoposMultiple(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("London", 2163557758834106.0)
-	server.Memory["benchkey"].SortedSet.Add("Munich", 3672376881541190.0)
+	})
+	getEntry("benchkey").SortedSet.Add("London", 2163557758834106.0)
+	getEntry("benchkey").SortedSet.Add("Munich", 3672376881541190.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -313,6 +314,6 @@ func BenchmarkGeoposMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Geopos(connID, args)
+		Geopos(network.ClientOf(connID), args)
 	}
 }
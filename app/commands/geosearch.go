@@ -3,7 +3,9 @@ package commands
 import (
 	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -14,13 +16,9 @@ import (
 //
 // This command searches for members in a geospatial sorted set within a circular area.
 // Only supports FROMLONLAT and BYRADIUS options in this implementation.
-func Geosearch(connID string, args []shared.Value) shared.Value {
-	if len(args) < 6 {
-		return createErrorResponse("ERR wrong number of arguments for 'geosearch' command")
-	}
-
+func Geosearch(client *network.Client, args []shared.Value) shared.Value {
 	// Parse FROMLONLAT longitude latitude
-	if args[1].Bulk != "FROMLONLAT" {
+	if !isOption(args[1], "FROMLONLAT") {
 		return createErrorResponse("ERR only FROMLONLAT mode is supported")
 	}
 
@@ -35,7 +33,7 @@ func Geosearch(connID string, args []shared.Value) shared.Value {
 	}
 
 	// Parse BYRADIUS radius unit
-	if args[4].Bulk != "BYRADIUS" {
+	if !isOption(args[4], "BYRADIUS") {
 		return createErrorResponse("ERR only BYRADIUS mode is supported")
 	}
 
@@ -46,11 +44,11 @@ func Geosearch(connID string, args []shared.Value) shared.Value {
 
 	unit := "m" // default to meters
 	if len(args) > 6 {
-		unit = args[6].Bulk
+		unit = strings.ToLower(args[6].Bulk)
 	}
 
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	if !exists || entry.SortedSet == nil {
 		return shared.Value{Typ: "array", Array: []shared.Value{}}
@@ -61,7 +59,7 @@ func Geosearch(connID string, args []shared.Value) shared.Value {
 
 	// Search for members within the radius
 	var results []shared.Value
-	for member, score := range entry.SortedSet.Members {
+	entry.SortedSet.Range(func(member string, score float64) bool {
 		memberLat, memberLon := decodeGeohash(uint64(score))
 
 		// Calculate distance from search center to this member
@@ -71,7 +69,8 @@ func Geosearch(connID string, args []shared.Value) shared.Value {
 		if distance <= radiusInMeters {
 			results = append(results, shared.Value{Typ: "bulk", Bulk: member})
 		}
-	}
+		return true
+	})
 
 	// Sort results alphabetically for consistent output
 	sort.Slice(results, func(i, j int) bool {
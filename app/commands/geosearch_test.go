@@ -34,14 +34,14 @@ func TestGeosearch(t *testing.T) {
 				},
 			},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 				// Paris: 2.2944692, 48.8584625 -> score: 3663832614298053
-				server.Memory["places"].SortedSet.Add("Paris", 3663832614298053.0)
+				getEntry("places").SortedSet.Add("Paris", 3663832614298053.0)
 				// Munich: 11.5030378, 48.164271 -> score: 3672376881541190
-				server.Memory["places"].SortedSet.Add("Munich", 3672376881541190.0)
+				getEntry("places").SortedSet.Add("Munich", 3672376881541190.0)
 			},
 		},
 		{
@@ -64,14 +64,14 @@ func TestGeosearch(t *testing.T) {
 				},
 			},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 				// Paris: 2.2944692, 48.8584625 -> score: 3663832614298053
-				server.Memory["places"].SortedSet.Add("Paris", 3663832614298053.0)
+				getEntry("places").SortedSet.Add("Paris", 3663832614298053.0)
 				// Munich: 11.5030378, 48.164271 -> score: 3672376881541190
-				server.Memory["places"].SortedSet.Add("Munich", 3672376881541190.0)
+				getEntry("places").SortedSet.Add("Munich", 3672376881541190.0)
 			},
 		},
 		{
@@ -91,14 +91,14 @@ func TestGeosearch(t *testing.T) {
 				Array: []shared.Value{},
 			},
 			setup: func() {
-				server.Memory["places"] = shared.MemoryEntry{
+				server.Memory.Set("places", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 				// Paris: 2.2944692, 48.8584625 -> score: 3663832614298053
-				server.Memory["places"].SortedSet.Add("Paris", 3663832614298053.0)
+				getEntry("places").SortedSet.Add("Paris", 3663832614298053.0)
 				// Munich: 11.5030378, 48.164271 -> score: 3672376881541190
-				server.Memory["places"].SortedSet.Add("Munich", 3672376881541190.0)
+				getEntry("places").SortedSet.Add("Munich", 3672376881541190.0)
 			},
 		},
 		{
@@ -241,14 +241,14 @@ func TestGeosearch(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear memory before each test
-			server.Memory = make(map[string]shared.MemoryEntry)
+			server.Memory.Clear()
 
 			// Setup test data
 			if tt.setup != nil {
 				tt.setup()
 			}
 
-			result := Geosearch(tt.connID, tt.args)
+			result := runCommand("GEOSEARCH", Geosearch, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Geosearch() typ = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -1,10 +1,9 @@
 package commands
 
 import (
-	"time"
-
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 // get handles the GET command.
@@ -19,27 +18,18 @@ import (
 //
 //	GET mykey           // Returns the value of mykey
 //	GET nonexistent     // Returns null
-func Get(connID string, args []shared.Value) shared.Value {
-	if len(args) != 1 {
-		return createErrorResponse("ERR wrong number of arguments for 'get' command")
-	}
-
+func Get(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
+	// Expired keys are removed by the lookup and reported missing
 	if !exists {
-		return shared.Value{Typ: "null", Str: ""}
-	}
-
-	// Check if key has expired and remove it if so
-	if entry.Expires > 0 && time.Now().UnixMilli() > entry.Expires {
-		delete(server.Memory, key)
-		return shared.Value{Typ: "null", Str: ""}
+		return shared.Null()
 	}
 
 	// GET only works with string values, not arrays
 	if len(entry.Array) > 0 {
-		return createErrorResponse("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return shared.ErrWrongType()
 	}
 
 	return shared.Value{Typ: "string", Str: entry.Value}
@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 )
@@ -26,7 +29,7 @@ func TestGet(t *testing.T) {
 				{Typ: "bulk", Bulk: "mykey"},
 			},
 			setup: func() {
-				server.Memory["mykey"] = shared.MemoryEntry{Value: "Hello World", Expires: 0}
+				server.Memory.Set("mykey", shared.MemoryEntry{Value: "Hello World", Expires: 0})
 			},
 			expected: shared.Value{Typ: "string", Str: "Hello World"},
 		},
@@ -37,7 +40,7 @@ func TestGet(t *testing.T) {
 				{Typ: "bulk", Bulk: "nonexistent"},
 			},
 			setup:    func() {},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 		},
 		{
 			name:   "get expired key",
@@ -46,12 +49,12 @@ func TestGet(t *testing.T) {
 				{Typ: "bulk", Bulk: "expiredkey"},
 			},
 			setup: func() {
-				server.Memory["expiredkey"] = shared.MemoryEntry{
+				server.Memory.Set("expiredkey", shared.MemoryEntry{
 					Value:   "expired value",
 					Expires: time.Now().UnixMilli() - 1000, // Expired 1 second ago
-				}
+				})
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 		},
 		{
 			name:   "get key with array (wrong type)",
@@ -60,11 +63,11 @@ func TestGet(t *testing.T) {
 				{Typ: "bulk", Bulk: "arraykey"},
 			},
 			setup: func() {
-				server.Memory["arraykey"] = shared.MemoryEntry{
+				server.Memory.Set("arraykey", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{"item1", "item2"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "WRONGTYPE Operation against a key holding the wrong kind of value"},
 		},
@@ -75,7 +78,7 @@ func TestGet(t *testing.T) {
 				{Typ: "bulk", Bulk: "emptykey"},
 			},
 			setup: func() {
-				server.Memory["emptykey"] = shared.MemoryEntry{Value: "", Expires: 0}
+				server.Memory.Set("emptykey", shared.MemoryEntry{Value: "", Expires: 0})
 			},
 			expected: shared.Value{Typ: "string", Str: ""},
 		},
@@ -93,7 +96,7 @@ func TestGet(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Get(tt.connID, tt.args)
+			result := runCommand("GET", Get, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Get() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -110,9 +113,40 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetWithoutCommandLock(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	server.Memory.Set("mykey", shared.MemoryEntry{Value: "v"})
+
+	// Reads go on while the command lock is held, like while a script runs, writes wait
+	server.CommandLock.Lock()
+	read, written := make(chan shared.Value, 1), make(chan struct{})
+	go func() { read <- network.ExecuteAndPropagate("GET", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "mykey"}}) }()
+	go func() {
+		network.ExecuteAndPropagate("SET", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "mykey"}, {Typ: "bulk", Bulk: "w"}})
+		close(written)
+	}()
+
+	select {
+	case result := <-read:
+		if result.Str != "v" {
+			t.Errorf("Expected v, got %v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected GET not to wait for the command lock")
+	}
+	select {
+	case <-written:
+		t.Error("Expected SET to wait for the command lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	server.CommandLock.Unlock()
+	<-written
+}
+
 func BenchmarkGet(b *testing.B) {
 	clearMemory()
-	server.Memory["benchkey"] = shared.MemoryEntry{Value: "Hello World", Expires: 0}
+	server.Memory.Set("benchkey", shared.MemoryEntry{Value: "Hello World", Expires: 0})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -121,7 +155,7 @@ func BenchmarkGet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Get(connID, args)
+		Get(network.ClientOf(connID), args)
 	}
 }
 
@@ -135,16 +169,16 @@ func BenchmarkGetNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Get(connID, args)
+		Get(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkGetExpired(b *testing.B) {
 	clearMemory()
-	server.Memory["expiredkey"] = shared.MemoryEntry{
+	server.Memory.Set("expiredkey", shared.MemoryEntry{
 		Value:   "expired value",
 		Expires: time.Now().UnixMilli() - 1000,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -153,6 +187,76 @@ func BenchmarkGetExpired(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Get(connID, args)
+		Get(network.ClientOf(connID), args)
 	}
 }
+
+// BenchmarkGetParallel runs GET from parallel clients through the dispatcher, each on a key
+// of its own, the way pure read traffic reaches the store
+func BenchmarkGetParallel(b *testing.B) {
+	initCommandHandlers()
+	clearMemory()
+	for i := 0; i < 1024; i++ {
+		server.Memory.Set("key-"+strconv.Itoa(i), shared.MemoryEntry{Value: "value"})
+	}
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		args := []shared.Value{{Typ: "bulk", Bulk: "key-" + strconv.FormatInt(next.Add(1)%1024, 10)}}
+		for pb.Next() {
+			network.ExecuteCommand("GET", "benchmark-conn", args)
+		}
+	})
+}
+
+// BenchmarkGetParallelSameKey runs GET from parallel clients on a single hot key
+func BenchmarkGetParallelSameKey(b *testing.B) {
+	initCommandHandlers()
+	clearMemory()
+	server.Memory.Set("hot", shared.MemoryEntry{Value: "value"})
+
+	args := []shared.Value{{Typ: "bulk", Bulk: "hot"}}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			network.ExecuteCommand("GET", "benchmark-conn", args)
+		}
+	})
+}
+
+// BenchmarkGetParallelWithWrites runs GET from parallel clients while one client keeps
+// writing to keys of the same shards
+func BenchmarkGetParallelWithWrites(b *testing.B) {
+	initCommandHandlers()
+	clearMemory()
+	for i := 0; i < 1024; i++ {
+		server.Memory.Set("key-"+strconv.Itoa(i), shared.MemoryEntry{Value: "value"})
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				server.Memory.Set("key-"+strconv.Itoa(i%1024), shared.MemoryEntry{Value: "value"})
+			}
+		}
+	}()
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		args := []shared.Value{{Typ: "bulk", Bulk: "key-" + strconv.FormatInt(next.Add(1)%1024, 10)}}
+		for pb.Next() {
+			network.ExecuteCommand("GET", "benchmark-conn", args)
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	<-done
+}
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// getset handles the GETSET command.
+// Usage: GETSET key value
+// Returns: The string the key held before, null if it didn't exist, or error if it holds another type.
+//
+// This command sets a key to a string value, dropping its expiration, and returns the old
+// value in the same step. It is kept for compatibility, SET with GET does the same.
+//
+// Examples:
+//
+//	GETSET counter 0    // Returns the count and resets it
+//	GETSET missing 1    // Returns null
+func Getset(client *network.Client, args []shared.Value) shared.Value {
+	return setAndGet(client, args[0].Bulk, shared.MemoryEntry{Value: args[1].Bulk}, "getset")
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestGetset(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+
+	if result := Getset(network.ClientOf("test-conn"), aclArgs("counter", "5")); result.Typ != "null" {
+		t.Errorf("Expected null for a missing key, got %+v", result)
+	}
+	server.Memory.Set("counter", shared.MemoryEntry{Value: "6", Expires: 99999999999999})
+	if result := Getset(network.ClientOf("test-conn"), aclArgs("counter", "0")); result.Typ != "string" || result.Str != "6" {
+		t.Errorf("Expected the previous value, got %+v", result)
+	}
+	if entry := getEntry("counter"); entry.Value != "0" || entry.Expires != 0 {
+		t.Errorf("Expected the value to be replaced without expiration, got %+v", entry)
+	}
+
+	Lpush(network.ClientOf("test-conn"), aclArgs("list", "a"))
+	if result := Getset(network.ClientOf("test-conn"), aclArgs("list", "x")); result.Typ != "error" || result.Str != shared.WrongTypeMessage {
+		t.Errorf("Expected a WRONGTYPE error, got %+v", result)
+	}
+	if result := runCommand("GETSET", Getset, "test-conn", aclArgs("counter")); result.Str != "ERR wrong number of arguments for 'getset' command" {
+		t.Errorf("Expected an arity error, got %+v", result)
+	}
+}
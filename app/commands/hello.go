@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Hello handles the HELLO command
+// Usage: HELLO [protover [AUTH username password] [SETNAME clientname]]
+// Returns: A map describing the server and the connection, in the negotiated protocol version.
+//
+// The protocol version applies to every reply from then on, including the reply to HELLO:
+// under RESP3 the map is sent as a map, under RESP2 as an array of alternating keys and values.
+//
+// Examples:
+//
+//	HELLO                         // Returns the server details without switching protocol
+//	HELLO 3                       // Switches the connection to RESP3
+//	HELLO 3 SETNAME worker-1      // Switches to RESP3 and names the connection
+//	HELLO 2 AUTH default secret   // Authenticates and stays on RESP2
+func Hello(client *network.Client, args []shared.Value) shared.Value {
+	version := client.Protocol()
+	name, setName := "", false
+
+	if len(args) > 0 {
+		requested, err := strconv.Atoi(args[0].Bulk)
+		if err != nil {
+			return createErrorResponse("ERR Protocol version is not an integer or out of range")
+		}
+		if requested != protocol.RESP2 && requested != protocol.RESP3 {
+			return createErrorResponse("NOPROTO unsupported protocol version")
+		}
+		version = requested
+
+		for i := 1; i < len(args); i++ {
+			option := strings.ToUpper(args[i].Bulk)
+			switch {
+			case option == "AUTH" && i+2 < len(args):
+				if err := network.ACLAuthenticate(client.ConnID, args[i+1].Bulk, args[i+2].Bulk); err != nil {
+					return createErrorResponse(err.Error())
+				}
+				i += 2
+			case option == "SETNAME" && i+1 < len(args):
+				name, setName = args[i+1].Bulk, true
+				if !isValidClientName(name) {
+					return createErrorResponse("ERR Client names cannot contain spaces, newlines or special characters.")
+				}
+				i++
+			default:
+				return createErrorResponse(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i].Bulk))
+			}
+		}
+	}
+
+	client.UpdateInfo(func(info *shared.ClientInfo) {
+		info.Protocol = version
+		if setName {
+			info.Name = name
+		}
+	})
+
+	info, _ := client.Info()
+	role := "master"
+	if server.StoreState.Role == "slave" {
+		role = "replica"
+	}
+	mode := "standalone"
+	if network.ClusterEnabled() {
+		mode = "cluster"
+	}
+	return shared.Value{Typ: "map", Array: []shared.Value{
+		{Typ: "bulk", Bulk: "server"}, {Typ: "bulk", Bulk: "redis"},
+		{Typ: "bulk", Bulk: "version"}, {Typ: "bulk", Bulk: RedisVersion},
+		{Typ: "bulk", Bulk: "proto"}, {Typ: "integer", Num: version},
+		{Typ: "bulk", Bulk: "id"}, {Typ: "integer", Num: int(info.ID)},
+		{Typ: "bulk", Bulk: "mode"}, {Typ: "bulk", Bulk: mode},
+		{Typ: "bulk", Bulk: "role"}, {Typ: "bulk", Bulk: role},
+		{Typ: "bulk", Bulk: "modules"}, {Typ: "array", Array: []shared.Value{}},
+	}}
+}
@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// helloField returns the value of a field in the HELLO reply
+func helloField(t *testing.T, reply shared.Value, field string) shared.Value {
+	t.Helper()
+	for i := 0; i+1 < len(reply.Array); i += 2 {
+		if reply.Array[i].Bulk == field {
+			return reply.Array[i+1]
+		}
+	}
+	t.Fatalf("Field %q missing from %v", field, reply)
+	return shared.Value{}
+}
+
+func TestHello(t *testing.T) {
+	defer registerTestClient(t, "hello-conn")()
+
+	reply := Hello(network.ClientOf("hello-conn"), nil)
+	if reply.Typ != "map" {
+		t.Fatalf("Expected a map, got %v", reply)
+	}
+	if proto := helloField(t, reply, "proto"); proto.Num != 2 {
+		t.Errorf("Expected HELLO without arguments to keep RESP2, got %v", proto)
+	}
+	if server := helloField(t, reply, "server"); server.Bulk != "redis" {
+		t.Errorf("Expected server redis, got %v", server)
+	}
+	info, _ := network.ClientInfoGet("hello-conn")
+	if id := helloField(t, reply, "id"); id.Num != int(info.ID) {
+		t.Errorf("Expected the client ID %d, got %v", info.ID, id)
+	}
+
+	reply = Hello(network.ClientOf("hello-conn"), []shared.Value{
+		{Typ: "bulk", Bulk: "3"},
+		{Typ: "bulk", Bulk: "auth"}, {Typ: "bulk", Bulk: "default"}, {Typ: "bulk", Bulk: "secret"},
+		{Typ: "bulk", Bulk: "setname"}, {Typ: "bulk", Bulk: "worker"},
+	})
+	if proto := helloField(t, reply, "proto"); proto.Num != 3 {
+		t.Errorf("Expected proto 3, got %v", proto)
+	}
+	if network.ClientProtocol("hello-conn") != protocol.RESP3 {
+		t.Errorf("Expected the connection to switch to RESP3")
+	}
+	if info, _ := network.ClientInfoGet("hello-conn"); info.Name != "worker" {
+		t.Errorf("Expected the connection to be named worker, got %q", info.Name)
+	}
+
+	Hello(network.ClientOf("hello-conn"), []shared.Value{{Typ: "bulk", Bulk: "2"}})
+	if network.ClientProtocol("hello-conn") != protocol.RESP2 {
+		t.Errorf("Expected the connection to switch back to RESP2")
+	}
+}
+
+func TestHelloErrors(t *testing.T) {
+	defer registerTestClient(t, "hello-conn")()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "Not a number", args: []string{"three"}, expected: "ERR Protocol version is not an integer or out of range"},
+		{name: "Unsupported version", args: []string{"4"}, expected: "NOPROTO unsupported protocol version"},
+		{name: "Unknown option", args: []string{"3", "FOO"}, expected: "ERR Syntax error in HELLO option 'FOO'"},
+		{name: "AUTH without password", args: []string{"3", "AUTH", "default"}, expected: "ERR Syntax error in HELLO option 'AUTH'"},
+		{name: "Unknown user", args: []string{"3", "AUTH", "alice", "secret"}, expected: "WRONGPASS invalid username-password pair or user is disabled."},
+		{name: "Invalid name", args: []string{"3", "SETNAME", "a b"}, expected: "ERR Client names cannot contain spaces, newlines or special characters."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([]shared.Value, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = shared.Value{Typ: "bulk", Bulk: arg}
+			}
+			result := Hello(network.ClientOf("hello-conn"), args)
+			if result.Typ != "error" || result.Str != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, result)
+			}
+			if network.ClientProtocol("hello-conn") != protocol.RESP2 {
+				t.Errorf("Expected a failed HELLO to keep RESP2")
+			}
+		})
+	}
+}
+
+func TestHelloReplyEncoding(t *testing.T) {
+	reply := shared.Value{Typ: "map", Array: []shared.Value{
+		{Typ: "bulk", Bulk: "proto"}, {Typ: "integer", Num: 3},
+		{Typ: "bulk", Bulk: "name"}, {Typ: "null"},
+	}}
+
+	if got := reply.MarshalProtocol(protocol.RESP3); !bytes.Equal(got, []byte("%2\r\n$5\r\nproto\r\n:3\r\n$4\r\nname\r\n_\r\n")) {
+		t.Errorf("Unexpected RESP3 encoding %q", got)
+	}
+	if got := reply.Marshal(); !bytes.Equal(got, []byte("*4\r\n$5\r\nproto\r\n:3\r\n$4\r\nname\r\n$-1\r\n")) {
+		t.Errorf("Unexpected RESP2 encoding %q", got)
+	}
+
+	// The writer encodes each reply in the version current when it is written
+	var buf bytes.Buffer
+	version := protocol.RESP2
+	writer := protocol.NewProtocolWriter(&buf, func() int { return version })
+	version = protocol.RESP3
+	writer.Write(shared.NullArray())
+	if buf.String() != "_\r\n" {
+		t.Errorf("Expected the writer to use RESP3, got %q", buf.String())
+	}
+}
+
+func TestRESP3TypesEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value shared.Value
+		resp3 string
+		resp2 string
+	}{
+		{name: "Set", value: shared.Value{Typ: "set", Array: []shared.Value{{Typ: "string", Str: "readonly"}}}, resp3: "~1\r\n+readonly\r\n", resp2: "*1\r\n+readonly\r\n"},
+		{name: "Double", value: shared.Value{Typ: "double", Double: 1.5}, resp3: ",1.5\r\n", resp2: "$3\r\n1.5\r\n"},
+		{name: "Whole double", value: shared.Value{Typ: "double", Double: 1000000}, resp3: ",1000000\r\n", resp2: "$7\r\n1000000\r\n"},
+		{name: "Double with RESP2 text", value: shared.Value{Typ: "double", Double: 0.5, Str: "0.5000"}, resp3: ",0.5\r\n", resp2: "$6\r\n0.5000\r\n"},
+		{name: "Infinite double", value: shared.Value{Typ: "double", Double: math.Inf(-1)}, resp3: ",-inf\r\n", resp2: "$4\r\n-inf\r\n"},
+		{name: "True", value: shared.Value{Typ: "boolean", Bool: true}, resp3: "#t\r\n", resp2: ":1\r\n"},
+		{name: "False", value: shared.Value{Typ: "boolean"}, resp3: "#f\r\n", resp2: ":0\r\n"},
+		{name: "Big number", value: shared.Value{Typ: "big_number", Str: "3492890328409238509324850943850943825024385"}, resp3: "(3492890328409238509324850943850943825024385\r\n", resp2: "$43\r\n3492890328409238509324850943850943825024385\r\n"},
+		{name: "Verbatim string", value: shared.Value{Typ: "verbatim", Str: "txt", Bulk: "Some string"}, resp3: "=15\r\ntxt:Some string\r\n", resp2: "$11\r\nSome string\r\n"},
+		{name: "Null", value: shared.Null(), resp3: "_\r\n", resp2: "$-1\r\n"},
+		{name: "Nested in an array", value: shared.Value{Typ: "array", Array: []shared.Value{{Typ: "boolean", Bool: true}}}, resp3: "*1\r\n#t\r\n", resp2: "*1\r\n:1\r\n"},
+		{name: "Push", value: shared.Value{Typ: "push", Array: []shared.Value{{Typ: "bulk", Bulk: "message"}, {Typ: "bulk", Bulk: "news"}, {Typ: "bulk", Bulk: "hi"}}}, resp3: ">3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$2\r\nhi\r\n", resp2: "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$2\r\nhi\r\n"},
+		{name: "Attributes", value: shared.Value{Typ: "integer", Num: 7, Attributes: []shared.Value{{Typ: "bulk", Bulk: "ttl"}, {Typ: "integer", Num: 3600}}}, resp3: "|1\r\n$3\r\nttl\r\n:3600\r\n:7\r\n", resp2: ":7\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.value.MarshalProtocol(protocol.RESP3)); got != tt.resp3 {
+				t.Errorf("Expected RESP3 encoding %q, got %q", tt.resp3, got)
+			}
+			if got := string(tt.value.Marshal()); got != tt.resp2 {
+				t.Errorf("Expected RESP2 encoding %q, got %q", tt.resp2, got)
+			}
+		})
+	}
+}
+
+func TestRespReadMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		reason string // Expected protocol error, empty when the input ends too early
+	}{
+		{name: "Unknown type byte", input: "?foo\r\n", reason: "unknown type byte '?'"},
+		{name: "Lone LF", input: "*1\n$4\r\nPING\r\n", reason: "expected CRLF at the end of the line"},
+		{name: "Negative multibulk length", input: "*-2\r\n", reason: "invalid multibulk length"},
+		{name: "Multibulk length not a number", input: "*x\r\n", reason: "invalid multibulk length"},
+		{name: "Negative bulk length", input: "*1\r\n$-5\r\nPING\r\n", reason: "invalid bulk length"},
+		{name: "Bulk longer than its length", input: "*1\r\n$2\r\nPING\r\n", reason: "expected CRLF after the bulk string"},
+		{name: "Unknown element type", input: "*1\r\n!4\r\n", reason: "unknown type byte '!'"},
+		{name: "Truncated bulk", input: "*1\r\n$4\r\nPI"},
+		{name: "Truncated array", input: "*2\r\n$4\r\nPING\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := protocol.NewResp(strings.NewReader(tt.input)).Read()
+			if tt.reason == "" {
+				if !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("Expected an unexpected EOF, got %v", err)
+				}
+				return
+			}
+			var protocolErr *protocol.ProtocolError
+			if !errors.As(err, &protocolErr) || protocolErr.Reason != tt.reason {
+				t.Errorf("Expected protocol error %q, got %v", tt.reason, err)
+			}
+		})
+	}
+
+	// Nulls and a clean end of input are not errors
+	reader := protocol.NewResp(strings.NewReader("$-1\r\n*-1\r\n"))
+	if v, err := reader.Read(); err != nil || v.Typ != "null" {
+		t.Errorf("Expected a null bulk string, got %v %v", v, err)
+	}
+	if v, err := reader.Read(); err != nil || !v.IsNull() || !v.NullArray {
+		t.Errorf("Expected a null array, got %v %v", v, err)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestRespReadLimits(t *testing.T) {
+	protocol.SetLimits(protocol.Limits{MaxBulkLength: 4, MaxArrayLength: 2, MaxDepth: 2, MaxFrameSize: 6})
+	defer protocol.SetLimits(protocol.DefaultLimits)
+
+	tests := []struct {
+		name   string
+		input  string
+		reason string // Expected protocol error, empty when the input is accepted
+	}{
+		{name: "Bulk at the limit", input: "$4\r\nPING\r\n"},
+		{name: "Bulk over the limit", input: "$5\r\nHELLO\r\n", reason: "invalid bulk length"},
+		{name: "Huge bulk length", input: "$9999999999\r\n", reason: "invalid bulk length"},
+		{name: "Array at the limit", input: "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{name: "Array over the limit", input: "*3\r\n", reason: "invalid multibulk length"},
+		{name: "Nesting at the limit", input: "*1\r\n*1\r\n:1\r\n"},
+		{name: "Nesting over the limit", input: "*1\r\n*1\r\n*1\r\n:1\r\n", reason: "too deep nesting of arrays"},
+		{name: "Frame at the limit", input: "*2\r\n$4\r\nabcd\r\n$2\r\nef\r\n"},
+		{name: "Frame over the limit", input: "*2\r\n$4\r\nabcd\r\n$3\r\nefg\r\n", reason: "too big request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := protocol.NewResp(strings.NewReader(tt.input)).Read()
+			if tt.reason == "" {
+				if err != nil {
+					t.Errorf("Expected the input to be accepted, got %v", err)
+				}
+				return
+			}
+			var protocolErr *protocol.ProtocolError
+			if !errors.As(err, &protocolErr) || protocolErr.Reason != tt.reason {
+				t.Errorf("Expected protocol error %q, got %v", tt.reason, err)
+			}
+		})
+	}
+
+	// A long bulk string is read as its data arrives
+	protocol.SetLimits(protocol.DefaultLimits)
+	long := strings.Repeat("x", 200*1024)
+	v, err := protocol.NewResp(strings.NewReader("$" + strconv.Itoa(len(long)) + "\r\n" + long + "\r\n")).Read()
+	if err != nil || v.Bulk != long {
+		t.Errorf("Expected the long bulk string to be read, got %d bytes, %v", len(v.Bulk), err)
+	}
+	if _, err := protocol.NewResp(strings.NewReader("$" + strconv.Itoa(len(long)) + "\r\n" + long[:1000])).Read(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected an unexpected EOF for a truncated long bulk string, got %v", err)
+	}
+}
+
+func TestRespReadReusesBuffers(t *testing.T) {
+	long := strings.Repeat("y", 10000)
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n" +
+		"*2\r\n$3\r\nGET\r\n$5\r\nother\r\n" +
+		"+" + long + "\r\n"
+	reader := protocol.NewResp(strings.NewReader(input))
+
+	// Values stay intact once the reader reuses its buffers for the next ones
+	first, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	second, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if first.Array[1].Bulk != "key" || first.Array[2].Bulk != "value" || second.Array[1].Bulk != "other" {
+		t.Errorf("Unexpected values %+v and %+v", first, second)
+	}
+	if string(first.Array[2].Bytes()) != "value" {
+		t.Errorf("Expected the bytes of the bulk string, got %q", first.Array[2].Bytes())
+	}
+
+	// A line longer than the read buffer is gathered across reads
+	if v, err := reader.Read(); err != nil || v.Str != long {
+		t.Errorf("Expected the long line to be read, got %d bytes, %v", len(v.Str), err)
+	}
+
+	// Reading a command allocates its array and its strings
+	command := []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n")
+	source := bytes.NewReader(nil)
+	reader = protocol.NewResp(source)
+	allocs := testing.AllocsPerRun(100, func() {
+		source.Reset(command)
+		reader.Read()
+	})
+	if allocs > 4 {
+		t.Errorf("Expected at most 4 allocations per command, got %v", allocs)
+	}
+}
+
+// FuzzRespRead checks that no input makes the reader panic, and that every failure is either
+// malformed input or input ending too early
+func FuzzRespRead(f *testing.F) {
+	seeds := []string{
+		"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n",
+		"*1\r\n*1\r\n*1\r\n:1\r\n",
+		"+OK\r\n-ERR boom\r\n:-42\r\n$-1\r\n*-1\r\n",
+		"$5\r\nab\r\n",
+		"*2\r\n$1\r\na\n",
+		"$9999999999\r\n",
+		":99999999999999999999\r\n",
+		"\x00",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	protocol.SetLimits(protocol.Limits{MaxBulkLength: 1024, MaxArrayLength: 64, MaxDepth: 8, MaxFrameSize: 4096})
+	defer protocol.SetLimits(protocol.DefaultLimits)
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		reader := protocol.NewResp(bytes.NewReader(input))
+		for {
+			_, err := reader.Read()
+			if err == nil {
+				continue
+			}
+			var protocolErr *protocol.ProtocolError
+			if err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.As(err, &protocolErr) {
+				t.Fatalf("Unexpected error %v for %q", err, input)
+			}
+			return
+		}
+	})
+}
+
+func BenchmarkHello(b *testing.B) {
+	defer registerTestClient(b, "hello-conn")()
+	args := []shared.Value{{Typ: "bulk", Bulk: "3"}}
+	for i := 0; i < b.N; i++ {
+		Hello(network.ClientOf("hello-conn"), args)
+	}
+}
+
+func BenchmarkRespRead(b *testing.B) {
+	command := []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n")
+	source := bytes.NewReader(nil)
+	reader := protocol.NewResp(source)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		source.Reset(command)
+		reader.Read()
+	}
+}
+
+func BenchmarkWriterWrite(b *testing.B) {
+	reply := shared.Value{Typ: "array", Array: []shared.Value{{Typ: "bulk", Bulk: "value"}, {Typ: "integer", Num: 42}}}
+	writer := protocol.NewWriter(io.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Write(reply)
+	}
+}
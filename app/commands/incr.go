@@ -1,36 +1,86 @@
 package commands
 
 import (
+	"math"
+	"math/big"
 	"strconv"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
+// incrBig increments a value by delta beyond 64 bits, replying a big number while the result
+// doesn't fit in an integer. It returns the entry holding the result and whether it changed.
+func incrBig(entry shared.MemoryEntry, delta int) (shared.MemoryEntry, shared.Value, bool) {
+	value, ok := new(big.Int).SetString(entry.Value, 10)
+	if !ok {
+		return entry, shared.ErrNotInteger(), false
+	}
+	value.Add(value, big.NewInt(int64(delta)))
+
+	entry.Value = value.String()
+	// Results past 64 bits stay strings changed in place, the others are integers again
+	entry.Raw = !value.IsInt64()
+	if value.IsInt64() {
+		return entry, shared.Value{Typ: "integer", Num: int(value.Int64())}, true
+	}
+	return entry, shared.BigNumber(entry.Value), true
+}
+
 // incr handles the INCR command.
+// Values beyond 64 bits are incremented too, and replied as big numbers.
 //
 // Examples:
 //
 //	INCR counter      // Increments counter from 5 to 6
-func Incr(connID string, args []shared.Value) shared.Value {
-	if len(args) != 1 {
-		return createErrorResponse("ERR wrong number of arguments for 'incr' command")
+//	INCR huge         // Increments 9223372036854775807 to the big number 9223372036854775808
+func Incr(client *network.Client, args []shared.Value) shared.Value {
+	return incrBy(client, args[0].Bulk, 1, "incr")
+}
+
+// IncrBy handles the INCRBY command.
+// Usage: INCRBY key increment
+// Returns: The value of the key after the increment, which may be negative.
+//
+// Examples:
+//
+//	INCRBY counter 10    // Increments counter from 5 to 15
+//	INCRBY counter -20   // Decrements counter from 15 to -5
+func IncrBy(client *network.Client, args []shared.Value) shared.Value {
+	delta, err := strconv.Atoi(args[1].Bulk)
+	if err != nil {
+		return shared.ErrNotInteger()
 	}
+	return incrBy(client, args[0].Bulk, delta, "incrby")
+}
 
-	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+// incrBy adds delta to the integer held by a key, starting from 0 when it doesn't exist. The
+// change is reported as event, the name of the command.
+func incrBy(client *network.Client, key string, delta int, event string) shared.Value {
+	var reply shared.Value
+	var changed bool
+	// Reading and incrementing under the lock of the key, so concurrent INCRs all count
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		if !exists {
+			reply, changed = shared.Value{Typ: "integer", Num: delta}, true
+			return shared.MemoryEntry{Value: strconv.Itoa(delta), Expires: 0}, true
+		}
 
-	if !exists {
-		server.Memory[key] = shared.MemoryEntry{Value: "1", Expires: 0}
-		return shared.Value{Typ: "integer", Num: 1}
-	}
+		value, err := strconv.Atoi(entry.Value)
+		if err != nil || (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+			entry, reply, changed = incrBig(entry, delta)
+			return entry, changed
+		}
 
-	value, err := strconv.Atoi(entry.Value)
-	if err != nil {
-		return createErrorResponse("ERR value is not an integer or out of range")
+		entry.Value = strconv.Itoa(value + delta)
+		entry.Raw = false
+		reply, changed = shared.Value{Typ: "integer", Num: value + delta}, true
+		return entry, true
+	})
+	if changed {
+		server.NotifyKeyModified(0, key, event)
+		client.MarkDirty(1)
 	}
-
-	entry.Value = strconv.Itoa(value + 1)
-	server.Memory[key] = entry
-	return shared.Value{Typ: "integer", Num: value + 1}
+	return reply
 }
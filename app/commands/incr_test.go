@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"math"
+	"sync"
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestIncr(t *testing.T) {
@@ -28,7 +31,7 @@ func TestIncr(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["newcounter"]
+				entry, exists := server.Memory.Get("newcounter")
 				if !exists {
 					t.Error("Key should exist after INCR")
 				}
@@ -44,11 +47,11 @@ func TestIncr(t *testing.T) {
 				{Typ: "bulk", Bulk: "existingcounter"},
 			},
 			setup: func() {
-				server.Memory["existingcounter"] = shared.MemoryEntry{Value: "5", Expires: 0}
+				server.Memory.Set("existingcounter", shared.MemoryEntry{Value: "5", Expires: 0})
 			},
 			expected: shared.Value{Typ: "integer", Num: 6},
 			verify: func() {
-				entry, exists := server.Memory["existingcounter"]
+				entry, exists := server.Memory.Get("existingcounter")
 				if !exists {
 					t.Error("Key should exist after INCR")
 				}
@@ -64,11 +67,11 @@ func TestIncr(t *testing.T) {
 				{Typ: "bulk", Bulk: "zerocounter"},
 			},
 			setup: func() {
-				server.Memory["zerocounter"] = shared.MemoryEntry{Value: "0", Expires: 0}
+				server.Memory.Set("zerocounter", shared.MemoryEntry{Value: "0", Expires: 0})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["zerocounter"]
+				entry, exists := server.Memory.Get("zerocounter")
 				if !exists {
 					t.Error("Key should exist after INCR")
 				}
@@ -84,11 +87,11 @@ func TestIncr(t *testing.T) {
 				{Typ: "bulk", Bulk: "negativecounter"},
 			},
 			setup: func() {
-				server.Memory["negativecounter"] = shared.MemoryEntry{Value: "-5", Expires: 0}
+				server.Memory.Set("negativecounter", shared.MemoryEntry{Value: "-5", Expires: 0})
 			},
 			expected: shared.Value{Typ: "integer", Num: -4},
 			verify: func() {
-				entry, exists := server.Memory["negativecounter"]
+				entry, exists := server.Memory.Get("negativecounter")
 				if !exists {
 					t.Error("Key should exist after INCR")
 				}
@@ -104,11 +107,11 @@ func TestIncr(t *testing.T) {
 				{Typ: "bulk", Bulk: "largecounter"},
 			},
 			setup: func() {
-				server.Memory["largecounter"] = shared.MemoryEntry{Value: "999999", Expires: 0}
+				server.Memory.Set("largecounter", shared.MemoryEntry{Value: "999999", Expires: 0})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1000000},
 			verify: func() {
-				entry, exists := server.Memory["largecounter"]
+				entry, exists := server.Memory.Get("largecounter")
 				if !exists {
 					t.Error("Key should exist after INCR")
 				}
@@ -124,12 +127,12 @@ func TestIncr(t *testing.T) {
 				{Typ: "bulk", Bulk: "stringcounter"},
 			},
 			setup: func() {
-				server.Memory["stringcounter"] = shared.MemoryEntry{Value: "hello", Expires: 0}
+				server.Memory.Set("stringcounter", shared.MemoryEntry{Value: "hello", Expires: 0})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR value is not an integer or out of range"},
 			verify: func() {
 				// Value should remain unchanged after error
-				entry, exists := server.Memory["stringcounter"]
+				entry, exists := server.Memory.Get("stringcounter")
 				if !exists {
 					t.Error("Key should still exist after error")
 				}
@@ -145,16 +148,16 @@ func TestIncr(t *testing.T) {
 				{Typ: "bulk", Bulk: "arraycounter"},
 			},
 			setup: func() {
-				server.Memory["arraycounter"] = shared.MemoryEntry{
+				server.Memory.Set("arraycounter", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{"item1", "item2"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR value is not an integer or out of range"},
 			verify: func() {
 				// Array should remain unchanged
-				entry, exists := server.Memory["arraycounter"]
+				entry, exists := server.Memory.Get("arraycounter")
 				if !exists {
 					t.Error("Key should still exist after error")
 				}
@@ -171,6 +174,34 @@ func TestIncr(t *testing.T) {
 			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'incr' command"},
 			verify:   func() {},
 		},
+		{
+			name:   "increment past 64 bits",
+			connID: "test-conn-9",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "huge"},
+			},
+			setup: func() {
+				server.Memory.Set("huge", shared.MemoryEntry{Value: "9223372036854775807"})
+			},
+			expected: shared.BigNumber("9223372036854775808"),
+			verify: func() {
+				if entry := getEntry("huge"); entry.Value != "9223372036854775808" {
+					t.Errorf("Expected value '9223372036854775808', got '%s'", entry.Value)
+				}
+			},
+		},
+		{
+			name:   "increment big number back into 64 bits",
+			connID: "test-conn-10",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "huge"},
+			},
+			setup: func() {
+				server.Memory.Set("huge", shared.MemoryEntry{Value: "-9223372036854775809"})
+			},
+			expected: shared.Value{Typ: "integer", Num: math.MinInt64},
+			verify:   func() {},
+		},
 	}
 
 	for _, tt := range tests {
@@ -178,7 +209,7 @@ func TestIncr(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Incr(tt.connID, tt.args)
+			result := runCommand("INCR", Incr, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Incr() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -197,9 +228,35 @@ func TestIncr(t *testing.T) {
 	}
 }
 
+func TestIncrConcurrent(t *testing.T) {
+	clearMemory()
+
+	// Clients incrementing the same counter while the expire cycle ranges over the keyspace
+	var wg sync.WaitGroup
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				Incr(network.ClientOf("test-conn"), aclArgs("counter"))
+				Rpush(network.ClientOf("test-conn"), aclArgs("list", "x"))
+				server.ActiveExpireCycle()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if entry := getEntry("counter"); entry.Value != "8000" {
+		t.Errorf("Expected every increment to be counted, got %s", entry.Value)
+	}
+	if size := getEntry("list").List.Size; size != 8000 {
+		t.Errorf("Expected every element to be pushed, got %d", size)
+	}
+}
+
 func BenchmarkIncr(b *testing.B) {
 	clearMemory()
-	server.Memory["benchcounter"] = shared.MemoryEntry{Value: "0", Expires: 0}
+	server.Memory.Set("benchcounter", shared.MemoryEntry{Value: "0", Expires: 0})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -208,7 +265,7 @@ func BenchmarkIncr(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Incr(connID, args)
+		Incr(network.ClientOf(connID), args)
 	}
 }
 
@@ -222,13 +279,13 @@ func BenchmarkIncrNewKey(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Incr(connID, args)
+		Incr(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkIncrLargeNumber(b *testing.B) {
 	clearMemory()
-	server.Memory["largecounter"] = shared.MemoryEntry{Value: "999999", Expires: 0}
+	server.Memory.Set("largecounter", shared.MemoryEntry{Value: "999999", Expires: 0})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -237,13 +294,13 @@ func BenchmarkIncrLargeNumber(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Incr(connID, args)
+		Incr(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkIncrNegativeNumber(b *testing.B) {
 	clearMemory()
-	server.Memory["negativecounter"] = shared.MemoryEntry{Value: "-1000", Expires: 0}
+	server.Memory.Set("negativecounter", shared.MemoryEntry{Value: "-1000", Expires: 0})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -252,6 +309,39 @@ func BenchmarkIncrNegativeNumber(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Incr(connID, args)
+		Incr(network.ClientOf(connID), args)
+	}
+}
+
+func TestIncrBy(t *testing.T) {
+	clearMemory()
+
+	if result := IncrBy(network.ClientOf("test-conn"), aclArgs("counter", "10")); result.Num != 10 {
+		t.Errorf("Expected a missing key to start from 0, got %v", result)
+	}
+	if result := IncrBy(network.ClientOf("test-conn"), aclArgs("counter", "-25")); result.Num != -15 {
+		t.Errorf("Expected a negative increment to decrement, got %v", result)
+	}
+	if entry := getEntry("counter"); entry.Value != "-15" {
+		t.Errorf("Expected the value -15, got %s", entry.Value)
+	}
+	if result := IncrBy(network.ClientOf("test-conn"), aclArgs("counter", "ten")); result.Typ != "error" {
+		t.Errorf("Expected an error for an increment that is not an integer, got %v", result)
+	}
+
+	server.Memory.Set("max", shared.MemoryEntry{Value: "9223372036854775800"})
+	if result := IncrBy(network.ClientOf("test-conn"), aclArgs("max", "10")); result.Typ != "big_number" || result.Str != "9223372036854775810" {
+		t.Errorf("Expected a big number past 64 bits, got %v", result)
+	}
+	if entry := getEntry("max"); !entry.Raw {
+		t.Errorf("Expected a value past 64 bits to be raw, got %+v", entry)
+	}
+	IncrBy(network.ClientOf("test-conn"), aclArgs("max", "-10"))
+	if entry := getEntry("max"); entry.Raw || entry.Value != "9223372036854775800" {
+		t.Errorf("Expected a 64 bit value not to be raw, got %+v", entry)
+	}
+	server.Memory.Set("min", shared.MemoryEntry{Value: "-9223372036854775800"})
+	if result := IncrBy(network.ClientOf("test-conn"), aclArgs("min", "-10")); result.Typ != "big_number" || result.Str != "-9223372036854775810" {
+		t.Errorf("Expected a big number below 64 bits, got %v", result)
 	}
 }
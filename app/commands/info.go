@@ -1,29 +1,290 @@
 package commands
 
 import (
+	"fmt"
+	"os"
+	"runtime"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
+// RedisVersion is the Redis version the server reports compatibility with
+const RedisVersion = "7.2.0"
+
+// infoSection is a section of the INFO output
+type infoSection struct {
+	name      string        // Lowercase name used to select the section
+	title     string        // Header written before the section's fields
+	isDefault bool          // Whether the section is returned without arguments
+	generate  func() string // Returns the section's fields
+}
+
+// infoSections lists the INFO sections in output order
+var infoSections = []infoSection{
+	{name: "server", title: "Server", isDefault: true, generate: serverInfo},
+	{name: "clients", title: "Clients", isDefault: true, generate: clientsInfo},
+	{name: "memory", title: "Memory", isDefault: true, generate: memoryInfo},
+	{name: "persistence", title: "Persistence", isDefault: true, generate: persistenceInfo},
+	{name: "stats", title: "Stats", isDefault: true, generate: statsInfo},
+	{name: "replication", title: "Replication", isDefault: true, generate: replicationInfo},
+	{name: "cpu", title: "CPU", isDefault: true, generate: cpuInfo},
+	{name: "cluster", title: "Cluster", isDefault: true, generate: clusterInfo},
+	{name: "commandstats", title: "Commandstats", isDefault: false, generate: commandstatsInfo},
+	{name: "errorstats", title: "Errorstats", isDefault: false, generate: errorstatsInfo},
+	{name: "latencystats", title: "Latencystats", isDefault: false, generate: latencystatsInfo},
+	{name: "keyspace", title: "Keyspace", isDefault: true, generate: keyspaceInfo},
+}
+
 // info handles the INFO command.
-// Usage: INFO
-// This command is used to get information about the server.
-func Info(connID string, args []shared.Value) shared.Value {
+// Usage: INFO [section [section ...]]
+// Returns: A verbatim string with the selected sections, each introduced by a "# Title" header
+// Without arguments, or with "default", every section except commandstats, errorstats and latencystats is returned.
+// "all" and "everything" return every section. Unknown sections are ignored.
+//
+// Examples:
+//
+//	INFO                        // Default sections
+//	INFO replication            // Only the replication section
+//	INFO clients memory         // The clients and memory sections
+//	INFO all                    // Every section
+func Info(client *network.Client, args []shared.Value) shared.Value {
+	selected := make(map[string]bool)
+	if len(args) == 0 {
+		selected["default"] = true
+	}
+	for _, arg := range args {
+		selected[strings.ToLower(arg.Bulk)] = true
+	}
+	all := selected["all"] || selected["everything"]
+
+	var sections []string
+	for _, section := range infoSections {
+		if all || selected[section.name] || (selected["default"] && section.isDefault) {
+			sections = append(sections, "# "+section.title+"\r\n"+section.generate())
+		}
+	}
+
+	return shared.Value{Typ: "verbatim", Str: "txt", Bulk: strings.Join(sections, "\r\n")}
+}
+
+// serverInfo returns the fields of the server section
+func serverInfo() string {
+	uptime := int64(server.Uptime().Seconds())
+
+	info := "redis_version:" + RedisVersion + "\r\n"
+	info += "redis_mode:standalone\r\n"
+	info += "os:" + runtime.GOOS + "\r\n"
+	info += "arch_bits:" + strconv.Itoa(strconv.IntSize) + "\r\n"
+	info += "go_version:" + runtime.Version() + "\r\n"
+	info += "process_id:" + strconv.Itoa(os.Getpid()) + "\r\n"
+	info += "tcp_port:" + server.StoreState.Port + "\r\n"
+	info += "uptime_in_seconds:" + strconv.FormatInt(uptime, 10) + "\r\n"
+	info += "uptime_in_days:" + strconv.FormatInt(uptime/86400, 10) + "\r\n"
+	info += "config_file:" + server.StoreState.ConfigFile + "\r\n"
+	return info
+}
+
+// clientsInfo returns the fields of the clients section
+func clientsInfo() string {
+	info := "connected_clients:" + strconv.Itoa(network.ConnectionsCount()) + "\r\n"
+	info += "blocked_clients:" + strconv.FormatInt(server.BlockedClients(), 10) + "\r\n"
+	return info
+}
+
+// memoryInfo returns the fields of the memory section, as reported by the Go runtime, and
+// the approximate size of the keys kept by the store
+func memoryInfo() string {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	info := "used_memory:" + strconv.FormatUint(stats.HeapAlloc, 10) + "\r\n"
+	info += "used_memory_human:" + humanBytes(stats.HeapAlloc) + "\r\n"
+	info += "used_memory_rss:" + strconv.FormatUint(stats.Sys, 10) + "\r\n"
+	info += "used_memory_rss_human:" + humanBytes(stats.Sys) + "\r\n"
+	dataset := server.Memory.UsedMemory()
+	info += "used_memory_dataset:" + strconv.FormatInt(dataset, 10) + "\r\n"
+	info += "used_memory_dataset_human:" + humanBytes(uint64(dataset)) + "\r\n"
+	info += "mem_allocator:go\r\n"
+	return info
+}
+
+// statsInfo returns the fields of the stats section
+func statsInfo() string {
+	info := "total_connections_received:" + strconv.FormatInt(server.TotalConnectionsReceived(), 10) + "\r\n"
+	info += "total_commands_processed:" + strconv.FormatInt(server.TotalCommandsProcessed(), 10) + "\r\n"
+	info += "expired_keys:" + strconv.FormatInt(server.ExpiredKeys(), 10) + "\r\n"
+	info += "keyspace_hits:" + strconv.FormatInt(server.KeyspaceHits(), 10) + "\r\n"
+	info += "keyspace_misses:" + strconv.FormatInt(server.KeyspaceMisses(), 10) + "\r\n"
+	info += "total_error_replies:" + strconv.FormatInt(server.TotalErrorReplies(), 10) + "\r\n"
+	info += "big_range_replies:" + strconv.FormatInt(server.BigRangeReplies(), 10) + "\r\n"
+	info += "rejected_range_replies:" + strconv.FormatInt(server.RejectedRangeReplies(), 10) + "\r\n"
+	info += "client_output_buffer_limit_disconnections:" + strconv.FormatInt(server.OutputBufferLimitDisconnections(), 10) + "\r\n"
+	info += "rate_limited_commands:" + strconv.FormatInt(server.RateLimitedCommands(), 10) + "\r\n"
+	return info
+}
+
+// replicationInfo returns the fields of the replication section
+func replicationInfo() string {
 	state := server.StoreState
 
-	// Build the info response as a bulk string with key-value pairs
 	info := "role:" + state.Role + "\r\n"
 	info += "master_replid:" + state.MasterReplID + "\r\n"
 	info += "master_repl_offset:" + strconv.FormatInt(state.MasterReplOffset, 10) + "\r\n"
 
-	// If a section is specified, we can filter the response
-	// For now, we'll return the same info regardless of section
-	if len(args) > 0 {
-		section := args[0].Bulk
-		_ = section // Use section if needed for filtering
+	// A replica reports whether its link to the master is usable
+	if state.Role == "slave" {
+		linkStatus := "down"
+		if network.MasterLinkUp() {
+			linkStatus = "up"
+		}
+		info += "master_link_status:" + linkStatus + "\r\n"
 	}
 
-	return shared.Value{Typ: "bulk", Bulk: info}
+	// Report each replica by the address it advertised during the handshake. A replica
+	// reports the sub-replicas it feeds the stream of its master to.
+	replicaIDs := network.ReplicaIDs()
+	info += "connected_slaves:" + strconv.Itoa(len(replicaIDs)) + "\r\n"
+	for i, replicaID := range replicaIDs {
+		replica, _ := network.ReplicaInfoGet(replicaID)
+		lag := int64(0)
+		if replica.LastAck > 0 {
+			lag = (time.Now().UnixMilli() - replica.LastAck) / 1000
+		}
+		info += fmt.Sprintf("slave%d:ip=%s,port=%s,state=online,offset=%d,lag=%d\r\n", i, replica.IP, replica.ListeningPort, replica.AckOffset, lag)
+	}
+
+	if state.Role == "master" {
+		info += "master_failover_state:" + network.FailoverStateGet() + "\r\n"
+		if state.MinReplicasToWrite > 0 {
+			info += "min_slaves_good_slaves:" + strconv.Itoa(network.GoodReplicasCount(state.MinReplicasMaxLag)) + "\r\n"
+		}
+	}
+
+	return info
+}
+
+// cpuInfo returns the fields of the cpu section
+func cpuInfo() string {
+	user, system := server.CPUTime()
+
+	info := fmt.Sprintf("used_cpu_sys:%.6f\r\n", system.Seconds())
+	info += fmt.Sprintf("used_cpu_user:%.6f\r\n", user.Seconds())
+	return info
+}
+
+// clusterInfo returns the fields of the cluster section
+func clusterInfo() string {
+	if network.ClusterEnabled() {
+		return "cluster_enabled:1\r\n"
+	}
+	return "cluster_enabled:0\r\n"
+}
+
+// commandstatsInfo returns the fields of the commandstats section, one line per command called
+func commandstatsInfo() string {
+	info := ""
+	for _, stat := range server.CommandStats() {
+		perCall := 0.0
+		if stat.Calls > 0 {
+			perCall = float64(stat.Usec) / float64(stat.Calls)
+		}
+		info += fmt.Sprintf("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d\r\n",
+			stat.Name, stat.Calls, stat.Usec, perCall, stat.RejectedCalls, stat.FailedCalls)
+	}
+	return info
+}
+
+// errorstatsInfo returns the fields of the errorstats section, one line per error prefix replied
+func errorstatsInfo() string {
+	info := ""
+	for _, stat := range server.ErrorStats() {
+		info += fmt.Sprintf("errorstat_%s:count=%d\r\n", stat.Prefix, stat.Count)
+	}
+	return info
+}
+
+// latencystatsInfo returns the fields of the latencystats section, one line per command
+// tracked with its latency-tracking-info-percentiles in microseconds
+func latencystatsInfo() string {
+	info := ""
+	for _, latency := range server.CommandLatencies(server.StoreState.LatencyTrackingInfoPercentiles) {
+		fields := make([]string, len(latency.Percentiles))
+		for i, percentile := range latency.Percentiles {
+			fields[i] = fmt.Sprintf("p%s=%.3f", strconv.FormatFloat(percentile, 'f', -1, 64), latency.Usec[i])
+		}
+		info += "latency_percentiles_usec_" + latency.Name + ":" + strings.Join(fields, ",") + "\r\n"
+	}
+	return info
+}
+
+// keyspaceInfo returns the fields of the keyspace section, empty when the database has no keys
+func keyspaceInfo() string {
+	stats := server.Memory.KeyspaceStats(time.Now().UnixMilli())
+	if stats.Keys == 0 {
+		return ""
+	}
+	return fmt.Sprintf("db0:keys=%d,expires=%d,avg_ttl=%d\r\n", stats.Keys, stats.Expires, stats.AvgTTL)
+}
+
+// persistenceInfo returns the fields of the persistence section
+func persistenceInfo() string {
+	stats := storage.GetPersistenceStats()
+
+	info := "loading:" + infoFlag(stats.Loading) + "\r\n"
+	info += "rdb_changes_since_last_save:" + strconv.FormatInt(stats.ChangesSinceLastSave, 10) + "\r\n"
+	info += "rdb_bgsave_in_progress:" + infoFlag(stats.BgsaveInProgress) + "\r\n"
+	info += "rdb_last_save_time:" + strconv.FormatInt(stats.LastSaveTime, 10) + "\r\n"
+	info += "rdb_last_bgsave_status:" + infoStatus(stats.LastBgsaveOK) + "\r\n"
+	info += "rdb_last_bgsave_time_sec:" + strconv.FormatInt(stats.LastBgsaveDuration, 10) + "\r\n"
+	info += "rdb_current_bgsave_time_sec:" + strconv.FormatInt(stats.CurrentBgsaveDuration, 10) + "\r\n"
+	info += "aof_enabled:" + infoFlag(stats.AOFEnabled) + "\r\n"
+	info += "aof_rewrite_in_progress:" + infoFlag(stats.AOFRewriteInProgress) + "\r\n"
+	info += "aof_last_rewrite_time_sec:" + strconv.FormatInt(stats.AOFLastRewriteDuration, 10) + "\r\n"
+	info += "aof_current_rewrite_time_sec:" + strconv.FormatInt(stats.AOFCurrentRewriteDuration, 10) + "\r\n"
+	info += "aof_last_bgrewrite_status:" + infoStatus(stats.AOFLastRewriteOK) + "\r\n"
+	info += "aof_last_write_status:" + infoStatus(stats.AOFLastWriteOK) + "\r\n"
+
+	// Sizes are only reported while the append only file is enabled
+	if stats.AOFEnabled {
+		info += "aof_current_size:" + strconv.FormatInt(stats.AOFCurrentSize, 10) + "\r\n"
+		info += "aof_base_size:" + strconv.FormatInt(stats.AOFBaseSize, 10) + "\r\n"
+	}
+	return info
+}
+
+// infoFlag formats a boolean INFO field as 1 or 0
+func infoFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// infoStatus formats the outcome of a persistence operation as ok or err
+func infoStatus(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "err"
+}
+
+// humanBytes formats a number of bytes with a binary unit suffix, like 1.50M
+func humanBytes(n uint64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+	value := float64(n)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatUint(n, 10) + "B"
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64) + units[unit]
 }
@@ -2,10 +2,18 @@ package commands
 
 import (
 	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 func TestInfo(t *testing.T) {
@@ -22,33 +30,35 @@ func TestInfo(t *testing.T) {
 		args     []shared.Value
 		expected string
 	}{
-		{
-			name:     "INFO without arguments",
-			args:     []shared.Value{},
-			expected: "role:master\r\nmaster_replid:test-repl-id\r\nmaster_repl_offset:12345\r\n",
-		},
 		{
 			name: "INFO with replication section",
 			args: []shared.Value{
 				{Typ: "bulk", Bulk: "replication"},
 			},
-			expected: "role:master\r\nmaster_replid:test-repl-id\r\nmaster_repl_offset:12345\r\n",
+			expected: "# Replication\r\nrole:master\r\nmaster_replid:test-repl-id\r\nmaster_repl_offset:12345\r\nconnected_slaves:0\r\nmaster_failover_state:no-failover\r\n",
+		},
+		{
+			name: "INFO with uppercase section",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "REPLICATION"},
+			},
+			expected: "# Replication\r\nrole:master\r\nmaster_replid:test-repl-id\r\nmaster_repl_offset:12345\r\nconnected_slaves:0\r\nmaster_failover_state:no-failover\r\n",
 		},
 		{
-			name: "INFO with server section",
+			name: "INFO with unknown section",
 			args: []shared.Value{
-				{Typ: "bulk", Bulk: "server"},
+				{Typ: "bulk", Bulk: "unknown"},
 			},
-			expected: "role:master\r\nmaster_replid:test-repl-id\r\nmaster_repl_offset:12345\r\n",
+			expected: "",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := Info("test-conn", tt.args)
+			result := runCommand("INFO", Info, "test-conn", tt.args)
 
-			if result.Typ != "bulk" {
-				t.Errorf("Expected bulk type, got %s", result.Typ)
+			if result.Typ != "verbatim" {
+				t.Errorf("Expected verbatim type, got %s", result.Typ)
 			}
 
 			if result.Bulk != tt.expected {
@@ -71,14 +81,14 @@ func TestInfoWithDifferentRoles(t *testing.T) {
 			role:     "master",
 			replID:   "master-123",
 			offset:   1000,
-			expected: "role:master\r\nmaster_replid:master-123\r\nmaster_repl_offset:1000\r\n",
+			expected: "# Replication\r\nrole:master\r\nmaster_replid:master-123\r\nmaster_repl_offset:1000\r\nconnected_slaves:0\r\nmaster_failover_state:no-failover\r\n",
 		},
 		{
 			name:     "Slave role",
 			role:     "slave",
 			replID:   "slave-456",
 			offset:   2000,
-			expected: "role:slave\r\nmaster_replid:slave-456\r\nmaster_repl_offset:2000\r\n",
+			expected: "# Replication\r\nrole:slave\r\nmaster_replid:slave-456\r\nmaster_repl_offset:2000\r\nmaster_link_status:down\r\nconnected_slaves:0\r\n",
 		},
 	}
 
@@ -92,10 +102,10 @@ func TestInfoWithDifferentRoles(t *testing.T) {
 				Replicas:         make(map[string]net.Conn),
 			})
 
-			result := Info("test-conn", []shared.Value{})
+			result := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "replication"}})
 
-			if result.Typ != "bulk" {
-				t.Errorf("Expected bulk type, got %s", result.Typ)
+			if result.Typ != "verbatim" {
+				t.Errorf("Expected verbatim type, got %s", result.Typ)
 			}
 
 			if result.Bulk != tt.expected {
@@ -105,6 +115,246 @@ func TestInfoWithDifferentRoles(t *testing.T) {
 	}
 }
 
+func TestInfoReportsReplicas(t *testing.T) {
+	connID := "127.0.0.1:51234"
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		MasterReplID:     "test-repl-id",
+		MasterReplOffset: 0,
+		Replicas:         map[string]net.Conn{connID: nil},
+	})
+	network.ReplicaInfoUpdate(connID, func(info *shared.ReplicaInfo) {
+		info.ListeningPort = "6380"
+	})
+	defer network.ReplicaInfoDelete(connID)
+
+	result := Info(network.ClientOf("test-conn"), []shared.Value{})
+
+	expected := "connected_slaves:1\r\nslave0:ip=127.0.0.1,port=6380,state=online,offset=0,lag=0\r\n"
+	if !strings.Contains(result.Bulk, expected) {
+		t.Errorf("Expected INFO to contain %q, got %q", expected, result.Bulk)
+	}
+}
+
+func TestInfoSections(t *testing.T) {
+	server.SetStoreState(shared.State{
+		Role:     "master",
+		Port:     "6379",
+		Replicas: make(map[string]net.Conn),
+	})
+
+	tests := []struct {
+		name     string
+		args     []shared.Value
+		included []string
+		excluded []string
+	}{
+		{
+			name:     "Default sections without arguments",
+			args:     []shared.Value{},
+			included: []string{"# Server\r\n", "# Clients\r\n", "# Memory\r\n", "# Persistence\r\n", "# Stats\r\n", "# Replication\r\n", "# CPU\r\n", "# Keyspace\r\n"},
+			excluded: []string{"# Commandstats\r\n", "# Latencystats\r\n"},
+		},
+		{
+			name:     "Default keyword",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "default"}},
+			included: []string{"# Server\r\n", "# Keyspace\r\n"},
+			excluded: []string{"# Commandstats\r\n"},
+		},
+		{
+			name:     "All sections",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "all"}},
+			included: []string{"# Server\r\n", "# Commandstats\r\n", "# Latencystats\r\n", "# Keyspace\r\n"},
+		},
+		{
+			name:     "Everything",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "everything"}},
+			included: []string{"# Commandstats\r\n", "# Latencystats\r\n"},
+		},
+		{
+			name:     "Server section",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "server"}},
+			included: []string{"# Server\r\n", "redis_version:", "tcp_port:6379\r\n", "uptime_in_seconds:", "process_id:"},
+			excluded: []string{"# Replication\r\n", "role:master"},
+		},
+		{
+			name:     "Several sections",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "clients"}, {Typ: "bulk", Bulk: "Memory"}},
+			included: []string{"# Clients\r\nconnected_clients:", "blocked_clients:", "\r\n\r\n# Memory\r\nused_memory:"},
+			excluded: []string{"# Server\r\n"},
+		},
+		{
+			name:     "Stats and cpu sections",
+			args:     []shared.Value{{Typ: "bulk", Bulk: "stats"}, {Typ: "bulk", Bulk: "cpu"}},
+			included: []string{"total_connections_received:", "total_commands_processed:", "expired_keys:", "used_cpu_sys:", "used_cpu_user:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("INFO", Info, "test-conn", tt.args)
+			if result.Typ != "verbatim" {
+				t.Fatalf("Expected verbatim type, got %s", result.Typ)
+			}
+			for _, s := range tt.included {
+				if !strings.Contains(result.Bulk, s) {
+					t.Errorf("Expected INFO to contain %q, got %q", s, result.Bulk)
+				}
+			}
+			for _, s := range tt.excluded {
+				if strings.Contains(result.Bulk, s) {
+					t.Errorf("Expected INFO not to contain %q, got %q", s, result.Bulk)
+				}
+			}
+		})
+	}
+}
+
+func TestInfoCommandstats(t *testing.T) {
+	initCommandHandlers()
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	clearMemory()
+
+	network.ExecuteCommand("ECHO", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "hello"}})
+	network.ExecuteCommand("ECHO", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "hello"}})
+
+	result := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "commandstats"}})
+	if !strings.HasPrefix(result.Bulk, "# Commandstats\r\n") {
+		t.Errorf("Expected the commandstats header, got %q", result.Bulk)
+	}
+	if !strings.Contains(result.Bulk, "cmdstat_echo:calls=") {
+		t.Errorf("Expected ECHO to be counted, got %q", result.Bulk)
+	}
+	for _, stat := range server.CommandStats() {
+		if stat.Name == "echo" && stat.Calls < 2 {
+			t.Errorf("Expected at least 2 ECHO calls, got %d", stat.Calls)
+		}
+	}
+}
+
+func TestInfoLatencystats(t *testing.T) {
+	initCommandHandlers()
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn),
+		LatencyTracking: true, LatencyTrackingInfoPercentiles: []float64{50, 99.9}})
+	server.ResetStats()
+	defer server.ResetStats()
+
+	network.ExecuteCommand("ECHO", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "hello"}})
+
+	result := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "latencystats"}})
+	if !strings.HasPrefix(result.Bulk, "# Latencystats\r\n") {
+		t.Errorf("Expected the latencystats header, got %q", result.Bulk)
+	}
+	if !regexp.MustCompile(`latency_percentiles_usec_echo:p50=\d+\.\d{3},p99\.9=\d+\.\d{3}\r\n`).MatchString(result.Bulk) {
+		t.Errorf("Expected the ECHO percentiles, got %q", result.Bulk)
+	}
+}
+
+func TestInfoErrorstats(t *testing.T) {
+	initCommandHandlers()
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	clearMemory()
+	server.ResetStats()
+
+	network.ExecuteCommand("GET", "test-conn", []shared.Value{})
+	server.Memory.Set("list", shared.MemoryEntry{Array: []string{"a"}})
+	network.ExecuteCommand("INCR", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "list"}})
+	network.ExecuteCommand("GET", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "list"}})
+
+	commandstats := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "commandstats"}}).Bulk
+	if !strings.Contains(commandstats, "cmdstat_get:calls=1,") || !strings.Contains(commandstats, "rejected_calls=1,failed_calls=1\r\n") {
+		t.Errorf("Expected one rejected and one failed GET, got %q", commandstats)
+	}
+
+	errorstats := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "errorstats"}}).Bulk
+	if !strings.HasPrefix(errorstats, "# Errorstats\r\n") {
+		t.Errorf("Expected the errorstats header, got %q", errorstats)
+	}
+	if !strings.Contains(errorstats, "errorstat_ERR:count=") || !strings.Contains(errorstats, "errorstat_WRONGTYPE:count=") {
+		t.Errorf("Expected ERR and WRONGTYPE errors, got %q", errorstats)
+	}
+	if stats := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "stats"}}).Bulk; !strings.Contains(stats, "total_error_replies:3\r\n") {
+		t.Errorf("Expected 3 error replies, got %q", stats)
+	}
+
+	// CONFIG RESETSTAT zeroes the statistics
+	if result := Config(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "RESETSTAT"}}); result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+	if errorstats := errorstatsInfo(); errorstats != "" {
+		t.Errorf("Expected no error stats after RESETSTAT, got %q", errorstats)
+	}
+	if len(server.CommandStats()) != 0 || server.TotalCommandsProcessed() != 0 || server.TotalErrorReplies() != 0 {
+		t.Errorf("Expected the command stats to be reset")
+	}
+}
+
+func TestInfoKeyspaceHitsMisses(t *testing.T) {
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	clearMemory()
+	server.ResetStats()
+	server.Memory.Set("hit", shared.MemoryEntry{Value: "1"})
+	server.Memory.Set("list", shared.MemoryEntry{Array: []string{"a", "b"}})
+	server.Memory.Set("expired", shared.MemoryEntry{Value: "1", Expires: time.Now().Add(-time.Second).UnixMilli()})
+
+	Get(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "hit"}})
+	Get(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "missing"}})
+	Get(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "expired"}})
+	Lrange(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "list"}, {Typ: "bulk", Bulk: "0"}, {Typ: "bulk", Bulk: "-1"}})
+	Llen(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "nolist"}})
+
+	// Writes don't count as lookups
+	Set(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "hit"}, {Typ: "bulk", Bulk: "2"}})
+
+	stats := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "stats"}}).Bulk
+	if !strings.Contains(stats, "keyspace_hits:2\r\n") || !strings.Contains(stats, "keyspace_misses:3\r\n") {
+		t.Errorf("Expected 2 hits and 3 misses, got %q", stats)
+	}
+
+	server.ResetStats()
+	if server.KeyspaceHits() != 0 || server.KeyspaceMisses() != 0 {
+		t.Errorf("Expected RESETSTAT to zero the hits and misses")
+	}
+}
+
+func TestInfoKeyspace(t *testing.T) {
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	clearMemory()
+
+	result := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "keyspace"}})
+	if result.Bulk != "# Keyspace\r\n" {
+		t.Errorf("Expected an empty keyspace section, got %q", result.Bulk)
+	}
+
+	now := time.Now().UnixMilli()
+	server.Memory.Set("a", shared.MemoryEntry{Value: "1"})
+	server.Memory.Set("b", shared.MemoryEntry{Value: "2", Expires: now + 100000})
+	server.Memory.Set("expired", shared.MemoryEntry{Value: "3", Expires: now - 1000})
+
+	// Expired keys are counted until they are removed
+	result = Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "keyspace"}})
+	if !strings.HasPrefix(result.Bulk, "# Keyspace\r\ndb0:keys=3,expires=2,avg_ttl=") {
+		t.Errorf("Expected 3 keys with 2 expiring, got %q", result.Bulk)
+	}
+	server.LookupKeyRead("expired")
+	result = Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "keyspace"}})
+	if !strings.HasPrefix(result.Bulk, "# Keyspace\r\ndb0:keys=2,expires=1,avg_ttl=") {
+		t.Errorf("Expected 2 keys with 1 expiring, got %q", result.Bulk)
+	}
+	avgTTL, _ := strconv.ParseInt(strings.TrimSpace(result.Bulk[strings.Index(result.Bulk, "avg_ttl=")+len("avg_ttl="):]), 10, 64)
+	if avgTTL <= 90000 || avgTTL > 100000 {
+		t.Errorf("Expected an average TTL close to 100000, got %d", avgTTL)
+	}
+
+	// The counters follow the keys that are overwritten and deleted
+	server.Memory.Set("b", shared.MemoryEntry{Value: "2"})
+	server.Memory.Set("a", shared.MemoryEntry{Value: "1", Expires: now + 50000})
+	server.Memory.Delete("a")
+	if stats := server.Memory.KeyspaceStats(now); stats != (server.KeyspaceStats{Keys: 1}) {
+		t.Errorf("Expected 1 key without expiration, got %+v", stats)
+	}
+}
+
 // BenchmarkInfo benchmarks the INFO command
 func BenchmarkInfo(b *testing.B) {
 	// Reset store state for clean benchmark
@@ -119,7 +369,7 @@ func BenchmarkInfo(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Info("bench-conn", args)
+		Info(network.ClientOf("bench-conn"), args)
 	}
 }
 
@@ -139,6 +389,99 @@ func BenchmarkInfoWithSection(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Info("bench-conn", args)
+		Info(network.ClientOf("bench-conn"), args)
+	}
+}
+
+func TestInfoPersistence(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        dir,
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+
+	if result := Save(network.ClientOf("test-conn"), []shared.Value{}); result.Typ != "string" {
+		t.Fatalf("Expected SAVE to succeed, got %v", result)
+	}
+	server.AddDirty(3)
+
+	result := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "PERSISTENCE"}})
+	if result.Typ != "verbatim" {
+		t.Fatalf("Expected verbatim type, got %s", result.Typ)
+	}
+
+	expected := []string{
+		"rdb_changes_since_last_save:3\r\n",
+		"rdb_bgsave_in_progress:0\r\n",
+		"rdb_last_save_time:" + strconv.FormatInt(storage.LastSaveTime(), 10) + "\r\n",
+		"rdb_last_bgsave_status:ok\r\n",
+		"rdb_current_bgsave_time_sec:-1\r\n",
+		"aof_enabled:0\r\n",
+		"aof_rewrite_in_progress:0\r\n",
+		"aof_last_write_status:ok\r\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(result.Bulk, line) {
+			t.Errorf("Expected INFO persistence to contain %q, got %q", line, result.Bulk)
+		}
+	}
+	if strings.Contains(result.Bulk, "aof_current_size") {
+		t.Errorf("Expected no AOF sizes while appendonly is off, got %q", result.Bulk)
+	}
+
+	// A failed BGSAVE is reported until a save succeeds
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	server.StoreState.ConfigDir = file
+	if result := Bgsave(network.ClientOf("test-conn"), []shared.Value{}); result.Typ != "string" {
+		t.Fatalf("Expected BGSAVE to start, got %v", result)
+	}
+	waitForBackgroundSave(t)
+	result = Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "persistence"}})
+	if !strings.Contains(result.Bulk, "rdb_last_bgsave_status:err\r\n") {
+		t.Errorf("Expected a failed BGSAVE status, got %q", result.Bulk)
+	}
+
+	server.StoreState.ConfigDir = dir
+	Save(network.ClientOf("test-conn"), []shared.Value{})
+	result = Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "persistence"}})
+	if !strings.Contains(result.Bulk, "rdb_last_bgsave_status:ok\r\n") {
+		t.Errorf("Expected SAVE to clear the failed status, got %q", result.Bulk)
+	}
+}
+
+func TestInfoPersistenceAppendOnly(t *testing.T) {
+	server.SetStoreState(shared.State{
+		Role:           "master",
+		Replicas:       make(map[string]net.Conn),
+		ConfigDir:      t.TempDir(),
+		AppendOnly:     true,
+		AppendFilename: "appendonly.aof",
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer storage.CloseAppendOnlyFile()
+	clearMemory()
+
+	if err := storage.OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Failed to open append only file: %v", err)
+	}
+	command := shared.Value{Typ: "array", Array: []shared.Value{{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "k"}, {Typ: "bulk", Bulk: "v"}}}.Marshal()
+	storage.FeedAppendOnlyFile(command)
+
+	result := Info(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "persistence"}})
+	expected := []string{
+		"aof_enabled:1\r\n",
+		"aof_current_size:" + strconv.Itoa(len(command)) + "\r\n",
+		"aof_base_size:0\r\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(result.Bulk, line) {
+			t.Errorf("Expected INFO persistence to contain %q, got %q", line, result.Bulk)
+		}
 	}
 }
@@ -4,8 +4,9 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 // Keys handles the KEYS command.
@@ -25,30 +26,32 @@ import (
 //	KEYS "f*"        // Returns keys starting with 'f'
 //	KEYS "foo?"      // Returns keys like 'foo1', 'foo2', etc.
 //	KEYS "test[0-9]" // Returns keys like 'test0', 'test1', etc.
-func Keys(connID string, args []shared.Value) shared.Value {
-	if len(args) != 1 {
-		return createErrorResponse("ERR wrong number of arguments for 'keys' command")
-	}
-
+func Keys(client *network.Client, args []shared.Value) shared.Value {
 	pattern := args[0].Bulk
 	var matchingKeys []string
+	var patternErr error
 
 	// Iterate through all keys in memory
-	for key, entry := range server.Memory {
+	server.Memory.Range(func(key string, entry shared.MemoryEntry) bool {
 		// Check if key has expired and skip it if so
 		if entry.Expires > 0 && time.Now().UnixMilli() > entry.Expires {
-			continue
+			return true
 		}
 
 		// Check if key matches the pattern
 		matched, err := filepath.Match(pattern, key)
 		if err != nil {
-			// If pattern is invalid, return error
-			return createErrorResponse("ERR invalid pattern")
+			patternErr = err
+			return false
 		}
 		if matched {
 			matchingKeys = append(matchingKeys, key)
 		}
+		return true
+	})
+	if patternErr != nil {
+		// If pattern is invalid, return error
+		return createErrorResponse("ERR invalid pattern")
 	}
 
 	// Convert to RESP array format
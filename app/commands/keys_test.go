@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestKeys(t *testing.T) {
@@ -20,7 +21,7 @@ func TestKeys(t *testing.T) {
 			args: []shared.Value{},
 			setup: func() {
 				// Clear memory
-				server.Memory = make(map[string]shared.MemoryEntry)
+				server.Memory.Clear()
 			},
 			expected: createErrorResponse("ERR wrong number of arguments for 'keys' command"),
 		},
@@ -32,7 +33,7 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory
-				server.Memory = make(map[string]shared.MemoryEntry)
+				server.Memory.Clear()
 			},
 			expected: createErrorResponse("ERR wrong number of arguments for 'keys' command"),
 		},
@@ -43,7 +44,7 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory
-				server.Memory = make(map[string]shared.MemoryEntry)
+				server.Memory.Clear()
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 		},
@@ -54,10 +55,10 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory and add some keys
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["foo"] = shared.MemoryEntry{Value: "bar"}
-				server.Memory["baz"] = shared.MemoryEntry{Value: "qux"}
-				server.Memory["test"] = shared.MemoryEntry{Value: "value"}
+				server.Memory.Clear()
+				server.Memory.Set("foo", shared.MemoryEntry{Value: "bar"})
+				server.Memory.Set("baz", shared.MemoryEntry{Value: "qux"})
+				server.Memory.Set("test", shared.MemoryEntry{Value: "value"})
 			},
 			expected: shared.Value{
 				Typ: "array",
@@ -75,11 +76,11 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory and add some keys
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["foo"] = shared.MemoryEntry{Value: "bar"}
-				server.Memory["baz"] = shared.MemoryEntry{Value: "qux"}
-				server.Memory["fizz"] = shared.MemoryEntry{Value: "buzz"}
-				server.Memory["test"] = shared.MemoryEntry{Value: "value"}
+				server.Memory.Clear()
+				server.Memory.Set("foo", shared.MemoryEntry{Value: "bar"})
+				server.Memory.Set("baz", shared.MemoryEntry{Value: "qux"})
+				server.Memory.Set("fizz", shared.MemoryEntry{Value: "buzz"})
+				server.Memory.Set("test", shared.MemoryEntry{Value: "value"})
 			},
 			expected: shared.Value{
 				Typ: "array",
@@ -96,12 +97,12 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory and add some keys
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["foo"] = shared.MemoryEntry{Value: "bar"}
-				server.Memory["foo1"] = shared.MemoryEntry{Value: "bar1"}
-				server.Memory["foo2"] = shared.MemoryEntry{Value: "bar2"}
-				server.Memory["foobar"] = shared.MemoryEntry{Value: "baz"}
-				server.Memory["test"] = shared.MemoryEntry{Value: "value"}
+				server.Memory.Clear()
+				server.Memory.Set("foo", shared.MemoryEntry{Value: "bar"})
+				server.Memory.Set("foo1", shared.MemoryEntry{Value: "bar1"})
+				server.Memory.Set("foo2", shared.MemoryEntry{Value: "bar2"})
+				server.Memory.Set("foobar", shared.MemoryEntry{Value: "baz"})
+				server.Memory.Set("test", shared.MemoryEntry{Value: "value"})
 			},
 			expected: shared.Value{
 				Typ: "array",
@@ -118,12 +119,12 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory and add some keys
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["apple"] = shared.MemoryEntry{Value: "fruit"}
-				server.Memory["banana"] = shared.MemoryEntry{Value: "fruit"}
-				server.Memory["cherry"] = shared.MemoryEntry{Value: "fruit"}
-				server.Memory["dog"] = shared.MemoryEntry{Value: "animal"}
-				server.Memory["test"] = shared.MemoryEntry{Value: "value"}
+				server.Memory.Clear()
+				server.Memory.Set("apple", shared.MemoryEntry{Value: "fruit"})
+				server.Memory.Set("banana", shared.MemoryEntry{Value: "fruit"})
+				server.Memory.Set("cherry", shared.MemoryEntry{Value: "fruit"})
+				server.Memory.Set("dog", shared.MemoryEntry{Value: "animal"})
+				server.Memory.Set("test", shared.MemoryEntry{Value: "value"})
 			},
 			expected: shared.Value{
 				Typ: "array",
@@ -141,12 +142,12 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory and add some keys
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["test0"] = shared.MemoryEntry{Value: "value0"}
-				server.Memory["test1"] = shared.MemoryEntry{Value: "value1"}
-				server.Memory["test2"] = shared.MemoryEntry{Value: "value2"}
-				server.Memory["test10"] = shared.MemoryEntry{Value: "value10"}
-				server.Memory["test"] = shared.MemoryEntry{Value: "value"}
+				server.Memory.Clear()
+				server.Memory.Set("test0", shared.MemoryEntry{Value: "value0"})
+				server.Memory.Set("test1", shared.MemoryEntry{Value: "value1"})
+				server.Memory.Set("test2", shared.MemoryEntry{Value: "value2"})
+				server.Memory.Set("test10", shared.MemoryEntry{Value: "value10"})
+				server.Memory.Set("test", shared.MemoryEntry{Value: "value"})
 			},
 			expected: shared.Value{
 				Typ: "array",
@@ -164,9 +165,9 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory and add some keys
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["foo"] = shared.MemoryEntry{Value: "bar"}
-				server.Memory["baz"] = shared.MemoryEntry{Value: "qux"}
+				server.Memory.Clear()
+				server.Memory.Set("foo", shared.MemoryEntry{Value: "bar"})
+				server.Memory.Set("baz", shared.MemoryEntry{Value: "qux"})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 		},
@@ -177,9 +178,9 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Clear memory and add some keys with expiration
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["foo"] = shared.MemoryEntry{Value: "bar", Expires: 0}     // Not expired
-				server.Memory["expired"] = shared.MemoryEntry{Value: "old", Expires: 1} // Expired (timestamp 1 is in the past)
+				server.Memory.Clear()
+				server.Memory.Set("foo", shared.MemoryEntry{Value: "bar", Expires: 0})     // Not expired
+				server.Memory.Set("expired", shared.MemoryEntry{Value: "old", Expires: 1}) // Expired (timestamp 1 is in the past)
 			},
 			expected: shared.Value{
 				Typ: "array",
@@ -195,8 +196,8 @@ func TestKeys(t *testing.T) {
 			},
 			setup: func() {
 				// Add a key so the loop executes
-				server.Memory = make(map[string]shared.MemoryEntry)
-				server.Memory["test"] = shared.MemoryEntry{Value: "value"}
+				server.Memory.Clear()
+				server.Memory.Set("test", shared.MemoryEntry{Value: "value"})
 			},
 			expected: createErrorResponse("ERR invalid pattern"),
 		},
@@ -205,7 +206,7 @@ func TestKeys(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setup()
-			result := Keys("test-conn", tt.args)
+			result := runCommand("KEYS", Keys, "test-conn", tt.args)
 
 			if tt.expected.Typ == "error" {
 				if result.Typ != "error" {
@@ -251,32 +252,32 @@ func TestKeys(t *testing.T) {
 
 func BenchmarkKeys(b *testing.B) {
 	// Setup: add many keys to memory
-	server.Memory = make(map[string]shared.MemoryEntry)
+	server.Memory.Clear()
 	for i := 0; i < 1000; i++ {
 		key := fmt.Sprintf("key%d", i)
-		server.Memory[key] = shared.MemoryEntry{Value: "value"}
+		server.Memory.Set(key, shared.MemoryEntry{Value: "value"})
 	}
 
 	args := []shared.Value{{Typ: "bulk", Bulk: "*"}}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Keys("test-conn", args)
+		Keys(network.ClientOf("test-conn"), args)
 	}
 }
 
 func BenchmarkKeysPattern(b *testing.B) {
 	// Setup: add many keys to memory
-	server.Memory = make(map[string]shared.MemoryEntry)
+	server.Memory.Clear()
 	for i := 0; i < 1000; i++ {
 		key := fmt.Sprintf("key%d", i)
-		server.Memory[key] = shared.MemoryEntry{Value: "value"}
+		server.Memory.Set(key, shared.MemoryEntry{Value: "value"})
 	}
 
 	args := []shared.Value{{Typ: "bulk", Bulk: "key[0-9]*"}}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Keys("test-conn", args)
+		Keys(network.ClientOf("test-conn"), args)
 	}
 }
@@ -0,0 +1,20 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// lastsave handles the LASTSAVE command.
+// Usage: LASTSAVE
+// Returns: The Unix time of the last successful save to the RDB file, as an integer.
+// Clients can call BGSAVE and poll LASTSAVE until it changes to know the save succeeded.
+// Before the first save, the time the server started is returned.
+//
+// Examples:
+//
+//	LASTSAVE  // Returns 1700000000
+func Lastsave(client *network.Client, args []shared.Value) shared.Value {
+	return shared.Value{Typ: "integer", Num: int(storage.LastSaveTime())}
+}
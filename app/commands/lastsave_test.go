@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestLastsave(t *testing.T) {
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        t.TempDir(),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+
+	before := Lastsave(network.ClientOf("test-conn"), []shared.Value{})
+	if before.Typ != "integer" || before.Num <= 0 {
+		t.Fatalf("Expected a Unix time, got %v", before)
+	}
+
+	start := time.Now().Unix()
+	if result := Save(network.ClientOf("test-conn"), []shared.Value{}); result.Typ != "string" {
+		t.Fatalf("Expected SAVE to succeed, got %v", result)
+	}
+
+	after := Lastsave(network.ClientOf("test-conn"), []shared.Value{})
+	if after.Typ != "integer" || int64(after.Num) < start || after.Num < before.Num {
+		t.Errorf("Expected LASTSAVE to be updated to at least %d, got %v", start, after)
+	}
+}
+
+func TestLastsaveInvalidArgs(t *testing.T) {
+	result := runCommand("LASTSAVE", Lastsave, "test-conn", []shared.Value{{Typ: "bulk", Bulk: "extra"}})
+	if result.Typ != "error" || result.Str != "ERR wrong number of arguments for 'lastsave' command" {
+		t.Errorf("Expected wrong number of arguments error, got %v", result)
+	}
+}
+
+func BenchmarkLastsave(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Lastsave(network.ClientOf("test-conn"), []shared.Value{})
+	}
+}
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Latency handles the LATENCY command
+// Usage: LATENCY LATEST | LATENCY HISTORY event | LATENCY RESET [event ...] | LATENCY DOCTOR |
+// LATENCY HISTOGRAM [command ...]
+// Returns: The latency spikes recorded for events taking longer than latency-monitor-threshold,
+// or the latency histograms of commands recorded while latency-tracking is enabled.
+//
+// Events are command, fast-command, expire-cycle, expire-del and save.
+//
+// Examples:
+//
+//	LATENCY LATEST            // Returns the latest and highest spike of every event
+//	LATENCY HISTORY command   // Returns the spikes of slow commands, oldest first
+//	LATENCY RESET             // Drops every spike and returns the number of events reset
+//	LATENCY DOCTOR            // Returns a human readable analysis of the spikes
+//	LATENCY HISTOGRAM get set // Returns the calls of GET and SET by power of two of microseconds
+func Latency(client *network.Client, args []shared.Value) shared.Value {
+	subcommand := strings.ToUpper(args[0].Bulk)
+
+	switch subcommand {
+	case "LATEST":
+		return latencyLatest()
+	case "HISTORY":
+		return latencyHistory(strings.ToLower(args[1].Bulk))
+	case "RESET":
+		events := make([]string, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			events = append(events, strings.ToLower(arg.Bulk))
+		}
+		return shared.Value{Typ: "integer", Num: server.LatencyReset(events...)}
+	case "DOCTOR":
+		return shared.Value{Typ: "bulk", Bulk: latencyDoctor()}
+	case "HISTOGRAM":
+		commands := make([]string, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			commands = append(commands, strings.ToLower(arg.Bulk))
+		}
+		return latencyHistogram(commands)
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'latency' command")
+	}
+}
+
+// latencyLatest returns an entry per event: its name, the time and latency of its latest
+// spike, and its highest latency
+func latencyLatest() shared.Value {
+	all := server.LatencyLatest()
+	result := make([]shared.Value, len(all))
+	for i, stats := range all {
+		result[i] = shared.Value{Typ: "array", Array: []shared.Value{
+			{Typ: "bulk", Bulk: stats.Name},
+			{Typ: "integer", Num: int(stats.Time)},
+			{Typ: "integer", Num: int(stats.Latest)},
+			{Typ: "integer", Num: int(stats.Max)},
+		}}
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
+
+// latencyHistory returns the time and latency of every spike of event, oldest first
+func latencyHistory(event string) shared.Value {
+	samples := server.LatencyHistory(event)
+	result := make([]shared.Value, len(samples))
+	for i, sample := range samples {
+		result[i] = shared.Value{Typ: "array", Array: []shared.Value{
+			{Typ: "integer", Num: int(sample.Time)},
+			{Typ: "integer", Num: int(sample.Latency)},
+		}}
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
+
+// latencyHistogram returns a map of every given command tracked, or of every command tracked
+// when none is given, to its number of calls and the cumulative number of calls that took up
+// to each power of two of microseconds
+func latencyHistogram(commands []string) shared.Value {
+	if len(commands) == 0 {
+		commands = server.TrackedCommands()
+	}
+	result := []shared.Value{}
+	for _, command := range commands {
+		histogram, ok := server.CommandHistogram(command)
+		if !ok {
+			continue
+		}
+		bounds, counts := histogram.PowerOfTwoBuckets()
+		buckets := make([]shared.Value, 0, 2*len(bounds))
+		for i, bound := range bounds {
+			buckets = append(buckets, shared.Value{Typ: "integer", Num: int(bound)}, shared.Value{Typ: "integer", Num: int(counts[i])})
+		}
+		result = append(result, shared.Value{Typ: "bulk", Bulk: command}, shared.Value{Typ: "map", Array: []shared.Value{
+			{Typ: "bulk", Bulk: "calls"},
+			{Typ: "integer", Num: int(histogram.Count())},
+			{Typ: "bulk", Bulk: "histogram_usec"},
+			{Typ: "map", Array: buckets},
+		}})
+	}
+	return shared.Value{Typ: "map", Array: result}
+}
+
+// latencyDoctor returns a report of the recorded spikes: for each event their number, average,
+// mean deviation and period, followed by advice on what usually causes them
+func latencyDoctor() string {
+	if server.StoreState.LatencyMonitorThreshold <= 0 {
+		return "Latency monitoring is disabled in this server. You may use " +
+			"\"CONFIG SET latency-monitor-threshold <milliseconds>.\" in order to enable it.\n"
+	}
+
+	all := server.LatencyLatest()
+	if len(all) == 0 {
+		return "No latency spike was observed during the lifetime of this server.\n"
+	}
+
+	var report strings.Builder
+	report.WriteString("Latency spikes are observed in this server. Here is the analysis of each event:\n\n")
+	for i, stats := range all {
+		var sum int64
+		for _, sample := range stats.Samples {
+			sum += sample.Latency
+		}
+		count := int64(len(stats.Samples))
+		avg := sum / count
+		var deviation int64
+		for _, sample := range stats.Samples {
+			deviation += abs64(sample.Latency - avg)
+		}
+		deviation /= count
+		period := 0.0
+		if count > 1 {
+			period = float64(stats.Samples[count-1].Time-stats.Samples[0].Time) / float64(count-1)
+		}
+
+		fmt.Fprintf(&report, "%d. %s: %d latency spikes (average %dms, mean deviation %dms, period %.2f sec). Worst all time event %dms.\n",
+			i+1, stats.Name, count, avg, deviation, period, stats.Max)
+	}
+
+	report.WriteString("\nAdvices:\n")
+	for _, stats := range all {
+		switch stats.Name {
+		case server.LatencyEventCommand, server.LatencyEventFastCommand:
+			report.WriteString("- Check the slow log with SLOWLOG GET to find the commands causing the spikes. " +
+				"Avoid commands like KEYS on large datasets.\n")
+		case server.LatencyEventExpireCycle, server.LatencyEventExpireDel:
+			report.WriteString("- Deleting expired keys is slow, many keys or large values expiring at once may block the server.\n")
+		case server.LatencyEventSave:
+			report.WriteString("- SAVE blocks the server while the dataset is written, use BGSAVE instead.\n")
+		}
+	}
+	return report.String()
+}
+
+// abs64 returns the absolute value of n
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// setLatencyThreshold resets the server state with the given latency-monitor-threshold and drops every spike
+func setLatencyThreshold(threshold int) {
+	server.SetStoreState(shared.State{
+		Role:                    "master",
+		Replicas:                make(map[string]net.Conn),
+		LatencyMonitorThreshold: threshold,
+	})
+	server.LatencyReset()
+}
+
+func TestLatency(t *testing.T) {
+	defer setLatencyThreshold(0)
+	setLatencyThreshold(100)
+
+	server.LatencyAddSampleIfNeeded(server.LatencyEventCommand, 50*time.Millisecond)
+	server.LatencyAddSampleIfNeeded(server.LatencyEventCommand, 300*time.Millisecond)
+	server.LatencyAddSampleIfNeeded(server.LatencyEventCommand, 200*time.Millisecond)
+	server.LatencyAddSampleIfNeeded(server.LatencyEventSave, 150*time.Millisecond)
+
+	latest := Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "LATEST"}})
+	if latest.Typ != "array" || len(latest.Array) != 2 {
+		t.Fatalf("Expected 2 events, got %v", latest)
+	}
+	command := latest.Array[0].Array
+	if command[0].Bulk != "command" || command[2].Num != 200 || command[3].Num != 300 {
+		t.Errorf("Expected command with latest 200 and max 300, got %v", command)
+	}
+	if latest.Array[1].Array[0].Bulk != "save" {
+		t.Errorf("Expected the save event, got %v", latest.Array[1])
+	}
+
+	// Spikes in the same second are merged into one sample keeping the highest latency
+	history := Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "HISTORY"}, {Typ: "bulk", Bulk: "command"}})
+	if history.Typ != "array" || len(history.Array) == 0 {
+		t.Fatalf("Expected samples, got %v", history)
+	}
+	if last := history.Array[len(history.Array)-1].Array; last[1].Num != 300 {
+		t.Errorf("Expected the highest latency of the second, got %v", last)
+	}
+
+	unknown := Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "HISTORY"}, {Typ: "bulk", Bulk: "unknown"}})
+	if unknown.Typ != "array" || len(unknown.Array) != 0 {
+		t.Errorf("Expected no samples for an unknown event, got %v", unknown)
+	}
+
+	reset := Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "RESET"}, {Typ: "bulk", Bulk: "save"}, {Typ: "bulk", Bulk: "unknown"}})
+	if reset.Typ != "integer" || reset.Num != 1 {
+		t.Errorf("Expected 1 event reset, got %v", reset)
+	}
+	reset = Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "RESET"}})
+	if reset.Num != 1 || len(server.LatencyLatest()) != 0 {
+		t.Errorf("Expected every event to be reset, got %v", reset)
+	}
+}
+
+func TestLatencyThreshold(t *testing.T) {
+	defer setLatencyThreshold(0)
+
+	setLatencyThreshold(0)
+	server.LatencyAddSampleIfNeeded(server.LatencyEventCommand, time.Second)
+	if len(server.LatencyLatest()) != 0 {
+		t.Errorf("Expected a threshold of 0 to disable latency monitoring")
+	}
+
+	setLatencyThreshold(10)
+	server.LatencyAddSampleIfNeeded(server.LatencyEventCommand, 9*time.Millisecond)
+	if len(server.LatencyLatest()) != 0 {
+		t.Errorf("Expected a latency under the threshold not to be recorded")
+	}
+	server.LatencyAddSampleIfNeeded(server.LatencyEventCommand, 10*time.Millisecond)
+	if len(server.LatencyLatest()) != 1 {
+		t.Errorf("Expected a latency reaching the threshold to be recorded")
+	}
+}
+
+func TestLatencyDoctor(t *testing.T) {
+	defer setLatencyThreshold(0)
+
+	setLatencyThreshold(0)
+	report := Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "DOCTOR"}})
+	if report.Typ != "bulk" || !strings.Contains(report.Bulk, "disabled") {
+		t.Errorf("Expected the report to say monitoring is disabled, got %q", report.Bulk)
+	}
+
+	setLatencyThreshold(100)
+	report = Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "DOCTOR"}})
+	if !strings.Contains(report.Bulk, "No latency spike") {
+		t.Errorf("Expected the report to say there is no spike, got %q", report.Bulk)
+	}
+
+	server.LatencyAddSampleIfNeeded(server.LatencyEventSave, 250*time.Millisecond)
+	report = Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "DOCTOR"}})
+	if !strings.Contains(report.Bulk, "1. save: 1 latency spikes (average 250ms") || !strings.Contains(report.Bulk, "BGSAVE") {
+		t.Errorf("Unexpected report: %q", report.Bulk)
+	}
+}
+
+func TestLatencyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []shared.Value
+		expected string
+	}{
+		{name: "Unknown subcommand", args: []shared.Value{{Typ: "bulk", Bulk: "FOO"}}, expected: "ERR unknown subcommand for 'latency' command"},
+		{name: "HISTORY without event", args: []shared.Value{{Typ: "bulk", Bulk: "HISTORY"}}, expected: "ERR wrong number of arguments for 'latency|history' command"},
+		{name: "LATEST with arguments", args: []shared.Value{{Typ: "bulk", Bulk: "LATEST"}, {Typ: "bulk", Bulk: "x"}}, expected: "ERR wrong number of arguments for 'latency|latest' command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("LATENCY", Latency, "test-conn", tt.args)
+			if result.Typ != "error" || result.Str != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func BenchmarkLatencyLatest(b *testing.B) {
+	setLatencyThreshold(1)
+	server.LatencyAddSampleIfNeeded(server.LatencyEventCommand, time.Second)
+	args := []shared.Value{{Typ: "bulk", Bulk: "LATEST"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Latency(network.ClientOf("test-conn"), args)
+	}
+}
+
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	var histogram server.LatencyHistogram
+	if p := histogram.Percentile(99); p != 0 {
+		t.Errorf("Percentile(99) of an empty histogram = %d, expected 0", p)
+	}
+
+	// 1 to 1000 microseconds, once each
+	for us := int64(1); us <= 1000; us++ {
+		histogram.Record(us * 1000)
+	}
+	for _, tt := range []struct {
+		percentile float64
+		expected   int64
+	}{{50, 500_000}, {99, 990_000}, {99.9, 999_000}, {100, 1_000_000}} {
+		got := histogram.Percentile(tt.percentile)
+		if got < tt.expected || float64(got) > float64(tt.expected)*1.04 {
+			t.Errorf("Percentile(%v) = %d, expected within 4%% above %d", tt.percentile, got, tt.expected)
+		}
+	}
+
+	bounds, counts := histogram.PowerOfTwoBuckets()
+	if len(bounds) == 0 || bounds[0] != 1 || bounds[len(bounds)-1] != 1024 || counts[len(counts)-1] != 1000 {
+		t.Errorf("PowerOfTwoBuckets() = %v %v, expected bounds up to 1024 counting every call", bounds, counts)
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	initCommandHandlers()
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn), LatencyTracking: true})
+	server.ResetStats()
+	defer server.ResetStats()
+
+	network.ExecuteCommand("PING", "test-conn", nil)
+	network.ExecuteCommand("PING", "test-conn", nil)
+	network.ExecuteCommand("ECHO", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "hello"}})
+
+	result := Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "HISTOGRAM"}, {Typ: "bulk", Bulk: "ping"}, {Typ: "bulk", Bulk: "unknown"}})
+	if result.Typ != "map" || len(result.Array) != 2 || result.Array[0].Bulk != "ping" {
+		t.Fatalf("Expected the histogram of PING only, got %v", result)
+	}
+	fields := result.Array[1].Array
+	if fields[0].Bulk != "calls" || fields[1].Num != 2 || fields[2].Bulk != "histogram_usec" {
+		t.Fatalf("Expected 2 calls and their histogram, got %v", fields)
+	}
+	if buckets := fields[3].Array; buckets[len(buckets)-1].Num != 2 {
+		t.Errorf("Expected the last bucket to count both calls, got %v", buckets)
+	}
+
+	all := Latency(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "HISTOGRAM"}})
+	if len(all.Array) != 4 || all.Array[0].Bulk != "echo" || all.Array[2].Bulk != "ping" {
+		t.Errorf("Expected the histograms of ECHO and PING, got %v", all)
+	}
+
+	// Nothing is recorded while latency-tracking is disabled
+	server.StoreState.LatencyTracking = false
+	network.ExecuteCommand("PING", "test-conn", nil)
+	if histogram, _ := server.CommandHistogram("ping"); histogram.Count() != 2 {
+		t.Errorf("Expected 2 calls recorded, got %d", histogram.Count())
+	}
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -21,13 +22,9 @@ import (
 //
 // Note: LLEN is a fast O(1) operation that simply returns the current length
 // of the list without traversing its contents.
-func Llen(connID string, args []shared.Value) shared.Value {
-	if len(args) != 1 {
-		return createErrorResponse("ERR wrong number of arguments for 'llen' command")
-	}
-
+func Llen(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	if !exists {
 		return shared.Value{Typ: "integer", Num: 0}
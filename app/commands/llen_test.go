@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestLlen(t *testing.T) {
@@ -27,15 +28,15 @@ func TestLlen(t *testing.T) {
 				{Typ: "bulk", Bulk: "mylist"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c", "d", "e"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 5},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LLEN")
 				}
@@ -51,15 +52,15 @@ func TestLlen(t *testing.T) {
 				{Typ: "bulk", Bulk: "emptylist"},
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 0},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["emptylist"]
+				entry, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should still exist after LLEN")
 				}
@@ -85,15 +86,15 @@ func TestLlen(t *testing.T) {
 				{Typ: "bulk", Bulk: "singlelist"},
 			},
 			setup: func() {
-				server.Memory["singlelist"] = shared.MemoryEntry{
+				server.Memory.Set("singlelist", shared.MemoryEntry{
 					Array:   []string{"only"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["singlelist"]
+				entry, exists := server.Memory.Get("singlelist")
 				if !exists {
 					t.Error("List should still exist after LLEN")
 				}
@@ -114,15 +115,15 @@ func TestLlen(t *testing.T) {
 				for i := 0; i < 1000; i++ {
 					list[i] = fmt.Sprintf("item-%d", i)
 				}
-				server.Memory["largelist"] = shared.MemoryEntry{
+				server.Memory.Set("largelist", shared.MemoryEntry{
 					Array:   list,
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1000},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["largelist"]
+				entry, exists := server.Memory.Get("largelist")
 				if !exists {
 					t.Error("List should still exist after LLEN")
 				}
@@ -138,15 +139,15 @@ func TestLlen(t *testing.T) {
 				{Typ: "bulk", Bulk: "unicodelist"},
 			},
 			setup: func() {
-				server.Memory["unicodelist"] = shared.MemoryEntry{
+				server.Memory.Set("unicodelist", shared.MemoryEntry{
 					Array:   []string{"Hello 世界", "🌍", "测试", "end"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 4},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["unicodelist"]
+				entry, exists := server.Memory.Get("unicodelist")
 				if !exists {
 					t.Error("List should still exist after LLEN")
 				}
@@ -181,7 +182,7 @@ func TestLlen(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Llen(tt.connID, tt.args)
+			result := runCommand("LLEN", Llen, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Llen() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -202,10 +203,10 @@ func TestLlen(t *testing.T) {
 
 func BenchmarkLlen(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -214,16 +215,16 @@ func BenchmarkLlen(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Llen(connID, args)
+		Llen(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkLlenEmpty(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -232,7 +233,7 @@ func BenchmarkLlenEmpty(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Llen(connID, args)
+		Llen(network.ClientOf(connID), args)
 	}
 }
 
@@ -246,7 +247,7 @@ func BenchmarkLlenNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Llen(connID, args)
+		Llen(network.ClientOf(connID), args)
 	}
 }
 
@@ -257,10 +258,10 @@ func BenchmarkLlenLarge(b *testing.B) {
 	for i := 0; i < 1000; i++ {
 		list[i] = fmt.Sprintf("item-%d", i)
 	}
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   list,
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -269,6 +270,6 @@ func BenchmarkLlenLarge(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Llen(connID, args)
+		Llen(network.ClientOf(connID), args)
 	}
 }
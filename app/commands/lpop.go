@@ -3,8 +3,10 @@ package commands
 import (
 	"strconv"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 // lpop handles the LPOP command.
@@ -34,16 +36,31 @@ import (
 //
 // Note: LPOP is an O(1) operation for popping a single element, or O(n) for popping
 // multiple elements where n is the number of elements being popped.
-func Lpop(connID string, args []shared.Value) shared.Value {
-	if len(args) < 1 || len(args) > 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'lpop' command")
+func Lpop(client *network.Client, args []shared.Value) shared.Value {
+	if len(args) > 2 {
+		return shared.ErrWrongArity("lpop")
 	}
 
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	var reply shared.Value
+	var popped int
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		storage.CopyOnWrite(key)
+		entry, reply, popped = popHead(entry, exists, args)
+		return entry, popped > 0
+	})
+	if popped > 0 {
+		server.NotifyKeyModified(0, key, "lpop")
+		client.MarkDirty(popped)
+	}
+	return reply
+}
 
+// popHead pops the elements LPOP asks for from the list held by entry. It returns the
+// entry without them, the reply and the number of elements popped.
+func popHead(entry shared.MemoryEntry, exists bool, args []shared.Value) (shared.MemoryEntry, shared.Value, int) {
 	if !exists {
-		return shared.Value{Typ: "null", Str: ""}
+		return entry, shared.Null(), 0
 	}
 
 	// Check if list is empty (either array or linked list)
@@ -55,7 +72,7 @@ func Lpop(connID string, args []shared.Value) shared.Value {
 	}
 
 	if isEmpty {
-		return shared.Value{Typ: "null", Str: ""}
+		return entry, shared.Null(), 0
 	}
 
 	// Default to popping 1 item if no count specified
@@ -64,7 +81,7 @@ func Lpop(connID string, args []shared.Value) shared.Value {
 		var err error
 		count, err = strconv.Atoi(args[1].Bulk)
 		if err != nil || count < 0 {
-			return createErrorResponse("ERR value is not an integer or out of range")
+			return entry, shared.ErrNotInteger(), 0
 		}
 	}
 
@@ -83,7 +100,7 @@ func Lpop(connID string, args []shared.Value) shared.Value {
 
 	// If count is 0, return empty array
 	if count == 0 {
-		return shared.Value{Typ: "array", Array: []shared.Value{}}
+		return entry, shared.Value{Typ: "array", Array: []shared.Value{}}, 0
 	}
 
 	// If count is 1, return single string (backward compatibility)
@@ -95,8 +112,7 @@ func Lpop(connID string, args []shared.Value) shared.Value {
 			value = entry.Array[0]
 			entry.Array = entry.Array[1:]
 		}
-		server.Memory[key] = entry
-		return shared.Value{Typ: "string", Str: value}
+		return entry, shared.Value{Typ: "string", Str: value}, 1
 	}
 
 	// Pop multiple items and return as array
@@ -113,7 +129,5 @@ func Lpop(connID string, args []shared.Value) shared.Value {
 		}
 		entry.Array = entry.Array[count:]
 	}
-	server.Memory[key] = entry
-
-	return shared.Value{Typ: "array", Array: result}
+	return entry, shared.Value{Typ: "array", Array: result}, count
 }
@@ -3,8 +3,9 @@ package commands
 import (
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestLpop(t *testing.T) {
@@ -26,14 +27,14 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "mylist"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "a"},
 			verify: func() {
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LPOP")
 				}
@@ -53,10 +54,10 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "3"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c", "d", "e"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "a"},
@@ -64,7 +65,7 @@ func TestLpop(t *testing.T) {
 				{Typ: "string", Str: "c"},
 			}},
 			verify: func() {
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LPOP")
 				}
@@ -84,14 +85,14 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "0"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LPOP")
 				}
@@ -108,10 +109,10 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "10"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "a"},
@@ -119,7 +120,7 @@ func TestLpop(t *testing.T) {
 				{Typ: "string", Str: "c"},
 			}},
 			verify: func() {
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LPOP")
 				}
@@ -135,7 +136,7 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "nonexistent"},
 			},
 			setup:    func() {},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -145,14 +146,14 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "emptylist"},
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify: func() {
-				entry, exists := server.Memory["emptylist"]
+				entry, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should still exist after LPOP")
 				}
@@ -168,15 +169,15 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "stringkey"},
 			},
 			setup: func() {
-				server.Memory["stringkey"] = shared.MemoryEntry{
+				server.Memory.Set("stringkey", shared.MemoryEntry{
 					Value:   "hello",
 					Expires: 0,
-				}
+				})
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify: func() {
 				// Verify the string value is unchanged
-				entry, exists := server.Memory["stringkey"]
+				entry, exists := server.Memory.Get("stringkey")
 				if !exists {
 					t.Error("String should still exist after LPOP")
 				}
@@ -193,15 +194,15 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "-1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR value is not an integer or out of range"},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after error")
 				}
@@ -218,15 +219,15 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "invalid"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR value is not an integer or out of range"},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after error")
 				}
@@ -251,17 +252,17 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "2"},
 			},
 			setup: func() {
-				server.Memory["unicodelist"] = shared.MemoryEntry{
+				server.Memory.Set("unicodelist", shared.MemoryEntry{
 					Array:   []string{"Hello 世界", "🌍", "测试", "end"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "Hello 世界"},
 				{Typ: "string", Str: "🌍"},
 			}},
 			verify: func() {
-				entry, exists := server.Memory["unicodelist"]
+				entry, exists := server.Memory.Get("unicodelist")
 				if !exists {
 					t.Error("List should still exist after LPOP")
 				}
@@ -281,14 +282,14 @@ func TestLpop(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "a"},
 			verify: func() {
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LPOP")
 				}
@@ -307,7 +308,7 @@ func TestLpop(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Lpop(tt.connID, tt.args)
+			result := runCommand("LPOP", Lpop, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Lpop() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -334,12 +335,29 @@ func TestLpop(t *testing.T) {
 	}
 }
 
+func TestLpopMarksDirtyOnlyWhenPopping(t *testing.T) {
+	clearMemory()
+	client := network.ClientOf("test-conn-dirty")
+	client.TakeDirty()
+
+	Lpop(client, []shared.Value{{Typ: "bulk", Bulk: "missing"}})
+	if changes := client.TakeDirty(); changes != 0 {
+		t.Errorf("LPOP on a missing key should not mark the dataset dirty, got %d changes", changes)
+	}
+
+	server.Memory.Set("mylist", shared.MemoryEntry{List: shared.FromArray([]string{"a", "b", "c"})})
+	Lpop(client, []shared.Value{{Typ: "bulk", Bulk: "mylist"}, {Typ: "bulk", Bulk: "2"}})
+	if changes := client.TakeDirty(); changes != 2 {
+		t.Errorf("Expected 2 changes after popping two elements, got %d", changes)
+	}
+}
+
 func BenchmarkLpop(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -348,16 +366,16 @@ func BenchmarkLpop(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpop(connID, args)
+		Lpop(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkLpopMultiple(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -367,7 +385,7 @@ func BenchmarkLpopMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpop(connID, args)
+		Lpop(network.ClientOf(connID), args)
 	}
 }
 
@@ -381,16 +399,16 @@ func BenchmarkLpopNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpop(connID, args)
+		Lpop(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkLpopEmpty(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -399,6 +417,6 @@ func BenchmarkLpopEmpty(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpop(connID, args)
+		Lpop(network.ClientOf(connID), args)
 	}
 }
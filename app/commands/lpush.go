@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 // lpush handles the LPUSH command.
@@ -23,42 +25,25 @@ import (
 // Note: LPUSH is the opposite of RPUSH - it adds elements to the beginning of the list,
 // while RPUSH adds them to the end. The order of elements in the final list will be
 // reversed compared to the order they were pushed.
-func Lpush(connID string, args []shared.Value) shared.Value {
-	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'lpush' command")
-	}
-
+func Lpush(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
-
-	// If key doesn't exist, create a new linked list
-	if !exists {
-		entry = shared.MemoryEntry{List: shared.NewLinkedList(), Expires: 0}
-	} else if entry.List == nil {
-		// If we have an array but no list, convert array to linked list
-		if len(entry.Array) > 0 {
-			entry.List = shared.FromArray(entry.Array)
-			entry.Array = nil // Clear the array to save memory
-		} else {
-			// Create new linked list for empty array
-			entry.List = shared.NewLinkedList()
-		}
-		// Clear the string value when converting to list
-		entry.Value = ""
-	}
-
-	// LPUSH: O(1) insertion at head using linked list
 	newCount := len(args) - 1
-	if newCount == 0 {
-		return shared.Value{Typ: "integer", Num: entry.List.Size}
+	var size int
+	values := make([]string, len(args)-1)
+	for i := range values {
+		values[i] = args[i+1].Bulk
 	}
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		storage.CopyOnWrite(key)
+		// A missing key becomes a new list, a string is replaced by one
+		entry.Value = ""
 
-	// Add new values in reverse order (Redis LPUSH behavior)
-	// We need to add them in forward order to get reverse result
-	for i := 1; i < len(args); i++ {
-		entry.List.AddToHead(args[i].Bulk)
-	}
+		// Small lists stay packed in Array, bigger ones are linked lists with O(1) pushes
+		size = entry.PushList(values, true)
+		return entry, true
+	})
 
-	server.Memory[key] = entry
-	return shared.Value{Typ: "integer", Num: entry.List.Size}
+	server.NotifyKeyModified(0, key, "lpush")
+	client.MarkDirty(newCount)
+	return shared.Value{Typ: "integer", Num: size}
 }
@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestLpush(t *testing.T) {
@@ -29,7 +32,7 @@ func TestLpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["newlist"]
+				_, exists := server.Memory.Get("newlist")
 				if !exists {
 					t.Error("List should exist after LPUSH")
 				}
@@ -54,7 +57,7 @@ func TestLpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 3},
 			verify: func() {
-				_, exists := server.Memory["newlist"]
+				_, exists := server.Memory.Get("newlist")
 				if !exists {
 					t.Error("List should exist after LPUSH")
 				}
@@ -79,15 +82,15 @@ func TestLpush(t *testing.T) {
 				{Typ: "bulk", Bulk: "newfirst"},
 			},
 			setup: func() {
-				server.Memory["existinglist"] = shared.MemoryEntry{
+				server.Memory.Set("existinglist", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{"old1", "old2"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 3},
 			verify: func() {
-				_, exists := server.Memory["existinglist"]
+				_, exists := server.Memory.Get("existinglist")
 				if !exists {
 					t.Error("List should exist after LPUSH")
 				}
@@ -111,15 +114,15 @@ func TestLpush(t *testing.T) {
 				{Typ: "bulk", Bulk: "first"},
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["emptylist"]
+				_, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should exist after LPUSH")
 				}
@@ -140,11 +143,11 @@ func TestLpush(t *testing.T) {
 				{Typ: "bulk", Bulk: "first"},
 			},
 			setup: func() {
-				server.Memory["stringkey"] = shared.MemoryEntry{Value: "old string", Expires: 0}
+				server.Memory.Set("stringkey", shared.MemoryEntry{Value: "old string", Expires: 0})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["stringkey"]
+				_, exists := server.Memory.Get("stringkey")
 				if !exists {
 					t.Error("Key should exist after LPUSH")
 				}
@@ -156,7 +159,7 @@ func TestLpush(t *testing.T) {
 					t.Errorf("Expected 'first', got '%s'", array[0])
 				}
 				// Original string value should be cleared
-				entry := server.Memory["stringkey"]
+				entry := getEntry("stringkey")
 				if entry.Value != "" {
 					t.Errorf("Expected empty string value, got '%s'", entry.Value)
 				}
@@ -172,7 +175,7 @@ func TestLpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["emptylist"]
+				_, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should exist after LPUSH")
 				}
@@ -196,7 +199,7 @@ func TestLpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 2},
 			verify: func() {
-				_, exists := server.Memory["unicodelist"]
+				_, exists := server.Memory.Get("unicodelist")
 				if !exists {
 					t.Error("List should exist after LPUSH")
 				}
@@ -236,7 +239,7 @@ func TestLpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 5},
 			verify: func() {
-				_, exists := server.Memory["largelist"]
+				_, exists := server.Memory.Get("largelist")
 				if !exists {
 					t.Error("List should exist after LPUSH")
 				}
@@ -260,7 +263,7 @@ func TestLpush(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Lpush(tt.connID, tt.args)
+			result := runCommand("LPUSH", Lpush, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Lpush() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -279,6 +282,49 @@ func TestLpush(t *testing.T) {
 	}
 }
 
+func TestLpushEncoding(t *testing.T) {
+	clearMemory()
+	shared.SetEncodingLimits(shared.EncodingLimits{ListMaxListpackSize: 3, ZsetMaxListpackEntries: 128, ZsetMaxListpackValue: 64})
+	defer shared.SetEncodingLimits(shared.DefaultEncodingLimits)
+
+	push := func(command network.CommandHandler, values ...string) {
+		args := []shared.Value{{Typ: "bulk", Bulk: "mylist"}}
+		for _, value := range values {
+			args = append(args, shared.Value{Typ: "bulk", Bulk: value})
+		}
+		command(network.ClientOf("test-conn"), args)
+	}
+
+	// A small list stays packed
+	push(Lpush, "b", "a")
+	push(Rpush, "c")
+	if entry := getEntry("mylist"); entry.List != nil || !reflect.DeepEqual(entry.Array, []string{"a", "b", "c"}) {
+		t.Errorf("Expected a packed list [a b c], got %+v", entry)
+	}
+
+	// Growing past list-max-listpack-size converts it to a linked list
+	push(Lpush, "z")
+	if entry := getEntry("mylist"); entry.List == nil || entry.Array != nil {
+		t.Errorf("Expected a linked list, got %+v", entry)
+	}
+	push(Rpush, "d")
+	if list := getListAsArray("mylist"); !reflect.DeepEqual(list, []string{"z", "a", "b", "c", "d"}) {
+		t.Errorf("Expected [z a b c d], got %v", list)
+	}
+
+	// A negative size limits the bytes of the listpack instead
+	shared.SetEncodingLimits(shared.DefaultEncodingLimits)
+	clearMemory()
+	push(Rpush, strings.Repeat("x", 100))
+	if entry := getEntry("mylist"); entry.List != nil {
+		t.Errorf("Expected a short element to stay packed, got %+v", entry)
+	}
+	push(Rpush, strings.Repeat("x", 9000))
+	if entry := getEntry("mylist"); entry.List == nil || entry.List.Size != 2 {
+		t.Errorf("Expected an element over 8kb to convert the list, got %+v", entry)
+	}
+}
+
 func BenchmarkLpush(b *testing.B) {
 	clearMemory()
 
@@ -290,7 +336,7 @@ func BenchmarkLpush(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpush(connID, args)
+		Lpush(network.ClientOf(connID), args)
 	}
 }
 
@@ -307,17 +353,17 @@ func BenchmarkLpushMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpush(connID, args)
+		Lpush(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkLpushToExisting(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Value:   "",
 		Array:   []string{"existing1", "existing2"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -327,7 +373,7 @@ func BenchmarkLpushToExisting(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpush(connID, args)
+		Lpush(network.ClientOf(connID), args)
 	}
 }
 
@@ -342,6 +388,6 @@ func BenchmarkLpushEmpty(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lpush(connID, args)
+		Lpush(network.ClientOf(connID), args)
 	}
 }
@@ -1,9 +1,11 @@
 package commands
 
 import (
+	"iter"
 	"strconv"
 	"sync"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -76,6 +78,49 @@ func getRangeFromLinkedList(ll *shared.LinkedList, start, stop int) []string {
 	return result
 }
 
+// rangeLength returns the number of elements of a list of listLen elements between the
+// start and stop indexes, which are no longer negative
+func rangeLength(listLen, start, stop int) int {
+	if start < 0 {
+		start = 0
+	}
+	if stop >= listLen {
+		stop = listLen - 1
+	}
+	if start > stop {
+		return 0
+	}
+	return stop - start + 1
+}
+
+// streamListRange yields the n elements of a list starting at index start, walking the
+// linked list or slicing the array as the reply is written
+func streamListRange(entry shared.MemoryEntry, start, n int) iter.Seq[shared.Value] {
+	if entry.List == nil {
+		elements := entry.Array[start : start+n]
+		return func(yield func(shared.Value) bool) {
+			for _, value := range elements {
+				if !yield(shared.Value{Typ: "string", Str: value}) {
+					return
+				}
+			}
+		}
+	}
+
+	list := entry.List
+	return func(yield func(shared.Value) bool) {
+		current := list.Head
+		for i := 0; i < start && current != nil; i++ {
+			current = current.Next
+		}
+		for ; current != nil; current = current.Next {
+			if !yield(shared.Value{Typ: "string", Str: current.Value}) {
+				return
+			}
+		}
+	}
+}
+
 // lrange handles the LRANGE command.
 // Usage: LRANGE key start stop
 // Returns: Array of elements in the specified range.
@@ -89,6 +134,8 @@ func getRangeFromLinkedList(ll *shared.LinkedList, start, stop int) []string {
 //   - If start < 0, treated as 0
 //   - If stop >= list length, treated as list length - 1
 //   - Both start and stop are inclusive
+//   - Ranges of 1024 elements or more are streamed to the client as they are read
+//   - Ranges of more than range-reply-max-elements elements are refused, when it is set
 //
 // Examples:
 //
@@ -96,13 +143,9 @@ func getRangeFromLinkedList(ll *shared.LinkedList, start, stop int) []string {
 //	LRANGE mylist 0 -1     // Returns all elements
 //	LRANGE mylist -3 -1    // Returns last 3 elements
 //	LRANGE mylist 5 3      // Returns empty array (start > stop)
-func Lrange(connID string, args []shared.Value) shared.Value {
-	if len(args) != 3 {
-		return createErrorResponse("ERR wrong number of arguments for 'lrange' command")
-	}
-
+func Lrange(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	if !exists {
 		return shared.Value{Typ: "array", Array: []shared.Value{}}
@@ -110,17 +153,17 @@ func Lrange(connID string, args []shared.Value) shared.Value {
 
 	// Check if it's a list (either array or linked list)
 	if entry.List == nil && len(entry.Array) == 0 {
-		return createErrorResponse("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return shared.ErrWrongType()
 	}
 
 	// Parse start and stop indices
 	start, err := strconv.Atoi(args[1].Bulk)
 	if err != nil {
-		return createErrorResponse("ERR value is not an integer or out of range")
+		return shared.ErrNotInteger()
 	}
 	stop, err := strconv.Atoi(args[2].Bulk)
 	if err != nil {
-		return createErrorResponse("ERR value is not an integer or out of range")
+		return shared.ErrNotInteger()
 	}
 
 	// Get list length and handle negative indices
@@ -144,6 +187,16 @@ func Lrange(connID string, args []shared.Value) shared.Value {
 		return shared.Value{Typ: "array", Array: []shared.Value{}}
 	}
 
+	n := rangeLength(listLen, start, stop)
+	if reply, ok := checkRangeReply(n); !ok {
+		return reply
+	}
+
+	// Large ranges are written to the client element by element
+	if n >= streamReplyMinElements {
+		return shared.StreamArray(n, streamListRange(entry, start, n))
+	}
+
 	// Get range elements efficiently
 	var rangeElements []string
 	if entry.List != nil {
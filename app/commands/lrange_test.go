@@ -1,11 +1,14 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestLrange(t *testing.T) {
@@ -29,10 +32,10 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "2"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c", "d", "e"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "a"},
@@ -41,7 +44,7 @@ func TestLrange(t *testing.T) {
 			}},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -59,10 +62,10 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "-1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "a"},
@@ -71,7 +74,7 @@ func TestLrange(t *testing.T) {
 			}},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -89,10 +92,10 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "-1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c", "d", "e"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "c"},
@@ -101,7 +104,7 @@ func TestLrange(t *testing.T) {
 			}},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -119,17 +122,17 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "b"},
 			}},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -147,15 +150,15 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -173,15 +176,15 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "15"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -211,15 +214,15 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "WRONGTYPE Operation against a key holding the wrong kind of value"},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["emptylist"]
+				entry, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -237,15 +240,15 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["stringkey"] = shared.MemoryEntry{
+				server.Memory.Set("stringkey", shared.MemoryEntry{
 					Value:   "hello",
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "WRONGTYPE Operation against a key holding the wrong kind of value"},
 			verify: func() {
 				// Verify the string value is unchanged
-				entry, exists := server.Memory["stringkey"]
+				entry, exists := server.Memory.Get("stringkey")
 				if !exists {
 					t.Error("String should still exist after error")
 				}
@@ -263,15 +266,15 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR value is not an integer or out of range"},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after error")
 				}
@@ -289,15 +292,15 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "invalid"},
 			},
 			setup: func() {
-				server.Memory["mylist"] = shared.MemoryEntry{
+				server.Memory.Set("mylist", shared.MemoryEntry{
 					Array:   []string{"a", "b", "c"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR value is not an integer or out of range"},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["mylist"]
+				entry, exists := server.Memory.Get("mylist")
 				if !exists {
 					t.Error("List should still exist after error")
 				}
@@ -326,10 +329,10 @@ func TestLrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "1"},
 			},
 			setup: func() {
-				server.Memory["unicodelist"] = shared.MemoryEntry{
+				server.Memory.Set("unicodelist", shared.MemoryEntry{
 					Array:   []string{"Hello 世界", "🌍", "测试"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{
 				{Typ: "string", Str: "Hello 世界"},
@@ -337,7 +340,7 @@ func TestLrange(t *testing.T) {
 			}},
 			verify: func() {
 				// Verify the list is unchanged
-				entry, exists := server.Memory["unicodelist"]
+				entry, exists := server.Memory.Get("unicodelist")
 				if !exists {
 					t.Error("List should still exist after LRANGE")
 				}
@@ -353,7 +356,7 @@ func TestLrange(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Lrange(tt.connID, tt.args)
+			result := runCommand("LRANGE", Lrange, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Lrange() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -380,12 +383,97 @@ func TestLrange(t *testing.T) {
 	}
 }
 
+func TestLrangeStreamsLargeRanges(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+	list := make([]string, 3000)
+	for i := range list {
+		list[i] = fmt.Sprintf("item-%d", i)
+	}
+	server.Memory.Set("arraylist", shared.MemoryEntry{Array: list})
+	server.Memory.Set("linkedlist", shared.MemoryEntry{List: shared.FromArray(list)})
+
+	tests := []struct {
+		name     string
+		key      string
+		start    string
+		stop     string
+		expected []string
+		streamed bool
+	}{
+		{name: "Whole array", key: "arraylist", start: "0", stop: "-1", expected: list, streamed: true},
+		{name: "Linked list with negative indices", key: "linkedlist", start: "10", stop: "-11", expected: list[10:2990], streamed: true},
+		{name: "Stop past the end", key: "linkedlist", start: "2000", stop: "5000", expected: list[2000:], streamed: false},
+		{name: "Small range", key: "arraylist", start: "0", stop: "1022", expected: list[:1023], streamed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Lrange(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: tt.key}, {Typ: "bulk", Bulk: tt.start}, {Typ: "bulk", Bulk: tt.stop}})
+			if (result.Stream != nil) != tt.streamed {
+				t.Fatalf("Expected streamed %v, got %v", tt.streamed, result.Stream != nil)
+			}
+
+			// The streamed reply is written exactly like the array it stands for
+			expected := shared.Value{Typ: "array", Array: make([]shared.Value, len(tt.expected))}
+			for i, value := range tt.expected {
+				expected.Array[i] = shared.Value{Typ: "string", Str: value}
+			}
+			var buf bytes.Buffer
+			if err := protocol.NewWriter(&buf).Write(result); err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if buf.String() != string(expected.Marshal()) {
+				t.Errorf("Unexpected encoding of %d bytes, expected %d", buf.Len(), len(expected.Marshal()))
+			}
+			if materialized := result.Materialize(); len(materialized.Array) != len(tt.expected) || materialized.Stream != nil {
+				t.Errorf("Expected %d materialized elements, got %d", len(tt.expected), len(materialized.Array))
+			}
+		})
+	}
+}
+
+func TestLrangeMaxElements(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+	server.ResetStats()
+	defer server.ResetStats()
+	server.StoreState.RangeReplyMaxElements = 2000
+	defer func() { server.StoreState.RangeReplyMaxElements = 0 }()
+	server.Memory.Set("biglist", shared.MemoryEntry{List: shared.FromArray(make([]string, 3000))})
+
+	lrange := func(start, stop string) shared.Value {
+		return Lrange(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "biglist"}, {Typ: "bulk", Bulk: start}, {Typ: "bulk", Bulk: stop}})
+	}
+
+	// The whole list is over the limit
+	result := lrange("0", "-1")
+	if result.Typ != "error" || result.Str != "ERR reply of 3000 elements exceeds range-reply-max-elements (2000), narrow the range or use COUNT" {
+		t.Fatalf("Expected range-reply-max-elements error, got %v", result)
+	}
+
+	// Ranges up to the limit are served, and streamed from 1024 elements
+	if result := lrange("0", "1999"); result.Stream == nil || result.Stream.Len != 2000 {
+		t.Errorf("Expected a streamed reply of 2000 elements, got %v", result)
+	}
+	if result := lrange("0", "9"); len(result.Array) != 10 {
+		t.Errorf("Expected 10 elements, got %d", len(result.Array))
+	}
+
+	if got := server.RejectedRangeReplies(); got != 1 {
+		t.Errorf("Expected 1 rejected range reply, got %d", got)
+	}
+	if got := server.BigRangeReplies(); got != 1 {
+		t.Errorf("Expected 1 big range reply, got %d", got)
+	}
+}
+
 func BenchmarkLrange(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -396,16 +484,16 @@ func BenchmarkLrange(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lrange(connID, args)
+		Lrange(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkLrangeAll(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -416,16 +504,16 @@ func BenchmarkLrangeAll(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lrange(connID, args)
+		Lrange(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkLrangeNegativeIndices(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -436,7 +524,7 @@ func BenchmarkLrangeNegativeIndices(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lrange(connID, args)
+		Lrange(network.ClientOf(connID), args)
 	}
 }
 
@@ -452,7 +540,7 @@ func BenchmarkLrangeNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lrange(connID, args)
+		Lrange(network.ClientOf(connID), args)
 	}
 }
 
@@ -463,10 +551,10 @@ func BenchmarkLrangeLargeList(b *testing.B) {
 	for i := 0; i < 1000; i++ {
 		list[i] = fmt.Sprintf("item-%d", i)
 	}
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Array:   list,
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -477,6 +565,6 @@ func BenchmarkLrangeLargeList(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Lrange(connID, args)
+		Lrange(network.ClientOf(connID), args)
 	}
 }
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Memory handles the MEMORY command
+// Usage: MEMORY USAGE key [SAMPLES count]
+// Returns: The approximate number of bytes a key and its value use, or null for a missing key.
+//
+// The size of every key is kept by the store as the key is written, estimated from a few
+// elements of lists, sets and streams, so SAMPLES is accepted but doesn't change the result.
+//
+// Examples:
+//
+//	MEMORY USAGE mykey               // Returns the size of mykey in bytes
+//	MEMORY USAGE mylist SAMPLES 0    // Same, SAMPLES is ignored
+//	MEMORY USAGE missing             // Returns null
+func Memory(client *network.Client, args []shared.Value) shared.Value {
+	switch strings.ToUpper(args[0].Bulk) {
+	case "USAGE":
+		return memoryUsage(args[1:])
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'memory' command")
+	}
+}
+
+// memoryUsage handles the MEMORY USAGE subcommand
+func memoryUsage(args []shared.Value) shared.Value {
+	if len(args) != 1 && len(args) != 3 {
+		return shared.ErrWrongArity("memory|usage")
+	}
+	if len(args) == 3 {
+		if !isOption(args[1], "SAMPLES") {
+			return shared.ErrSyntax()
+		}
+		if samples, err := strconv.Atoi(args[2].Bulk); err != nil || samples < 0 {
+			return createErrorResponse("ERR value is out of range, must be positive")
+		}
+	}
+
+	key := args[0].Bulk
+	entry, exists := server.Memory.Get(key)
+	if !exists || (entry.Expires > 0 && time.Now().UnixMilli() > entry.Expires) {
+		return shared.Null()
+	}
+	size, exists := server.Memory.Usage(key)
+	if !exists {
+		return shared.Null()
+	}
+	return shared.Value{Typ: "integer", Num: int(size)}
+}
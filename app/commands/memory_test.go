@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		setup    func()
+		expected func(result shared.Value) bool
+	}{
+		{
+			name:     "string key",
+			args:     []string{"USAGE", "key"},
+			setup:    func() { server.Memory.Set("key", shared.MemoryEntry{Value: "value"}) },
+			expected: func(result shared.Value) bool { return result.Typ == "integer" && result.Num > len("keyvalue") },
+		},
+		{
+			name:     "missing key",
+			args:     []string{"USAGE", "missing"},
+			setup:    func() {},
+			expected: func(result shared.Value) bool { return result.Typ == "null" },
+		},
+		{
+			name: "expired key",
+			args: []string{"USAGE", "expired"},
+			setup: func() {
+				server.Memory.Set("expired", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() - 1000})
+			},
+			expected: func(result shared.Value) bool { return result.Typ == "null" },
+		},
+		{
+			name:     "samples option",
+			args:     []string{"usage", "key", "samples", "0"},
+			setup:    func() { server.Memory.Set("key", shared.MemoryEntry{Value: "value"}) },
+			expected: func(result shared.Value) bool { return result.Typ == "integer" && result.Num > 0 },
+		},
+		{
+			name:     "negative samples",
+			args:     []string{"USAGE", "key", "SAMPLES", "-1"},
+			setup:    func() {},
+			expected: func(result shared.Value) bool { return result.Typ == "error" },
+		},
+		{
+			name:     "unknown option",
+			args:     []string{"USAGE", "key", "COUNT", "1"},
+			setup:    func() {},
+			expected: func(result shared.Value) bool { return result.Str == "ERR syntax error" },
+		},
+		{
+			name:     "unknown subcommand",
+			args:     []string{"DOCTOR"},
+			setup:    func() {},
+			expected: func(result shared.Value) bool { return strings.HasPrefix(result.Str, "ERR unknown subcommand") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearMemory()
+			tt.setup()
+			args := make([]shared.Value, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = shared.Value{Typ: "bulk", Bulk: arg}
+			}
+			if result := Memory(network.ClientOf("test-conn"), args); !tt.expected(result) {
+				t.Errorf("Memory(%v) = %v", tt.args, result)
+			}
+		})
+	}
+}
+
+func TestMemoryUsageFollowsWrites(t *testing.T) {
+	clearMemory()
+	usage := func() int {
+		return Memory(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "USAGE"}, {Typ: "bulk", Bulk: "list"}}).Num
+	}
+
+	Rpush(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "list"}, {Typ: "bulk", Bulk: "a"}})
+	small := usage()
+	for i := 0; i < 100; i++ {
+		Rpush(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "list"}, {Typ: "bulk", Bulk: "element" + strconv.Itoa(i)}})
+	}
+	large := usage()
+	if large <= small {
+		t.Errorf("Expected the list to grow past %d bytes, got %d", small, large)
+	}
+	if used := server.Memory.UsedMemory(); used != int64(large) {
+		t.Errorf("Expected the used memory of the only key, %d, got %d", large, used)
+	}
+
+	// Deleting the key gives its bytes back
+	server.Memory.Delete("list")
+	if used := server.Memory.UsedMemory(); used != 0 {
+		t.Errorf("Expected no used memory, got %d", used)
+	}
+}
+
+func BenchmarkMemoryUsage(b *testing.B) {
+	clearMemory()
+	list := shared.NewLinkedList()
+	for i := 0; i < 10000; i++ {
+		list.AddToTail("element" + strconv.Itoa(i))
+	}
+	server.Memory.Set("list", shared.MemoryEntry{List: list})
+	args := []shared.Value{{Typ: "bulk", Bulk: "USAGE"}, {Typ: "bulk", Bulk: "list"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Memory(network.ClientOf("bench-conn"), args)
+	}
+}
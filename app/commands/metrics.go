@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// metricsLog logs the messages of the metrics subsystem
+var metricsLog = logger.New("metrics")
+
+// metricsContentType is the content type of the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricSample is a value of a metric, with the labels telling it apart from the other samples
+type metricSample struct {
+	labels string // Rendered labels like cmd="get", empty for a metric without labels
+	value  float64
+}
+
+// StartMetricsServer serves the metrics at /metrics over HTTP on every bind address, at
+// metrics-port, until the returned server is closed. It does nothing and returns nil when
+// metrics-port is 0.
+func StartMetricsServer() (*http.Server, error) {
+	if server.StoreState.MetricsPort == 0 {
+		return nil, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		WriteMetrics(w)
+	})
+	return serveHTTP(server.StoreState.MetricsPort, mux, metricsLog, "metrics", "/metrics")
+}
+
+// serveHTTP serves handler over HTTP on every bind address at port, logging the URL of path
+// for what it serves. Closing the returned server closes its listeners and its connections.
+func serveHTTP(port int, handler http.Handler, log *logger.Logger, what string, path string) (*http.Server, error) {
+	addresses, optional := network.BindAddresses(server.StoreState.Bind, strconv.Itoa(port))
+	var listeners []net.Listener
+	for i, address := range addresses {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			if optional[i] {
+				log.Warningf("Failed to bind to %s: %v", address, err)
+				continue
+			}
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	srv := &http.Server{Handler: handler}
+	for _, l := range listeners {
+		log.Noticef("Serving %s on http://%s%s", what, l.Addr(), path)
+		go srv.Serve(l)
+	}
+	return srv, nil
+}
+
+// WriteMetrics writes the server metrics in the Prometheus text exposition format
+func WriteMetrics(w io.Writer) {
+	writeMetric(w, "redis_uptime_in_seconds", "gauge", "Seconds since the server started.",
+		metricSample{value: server.Uptime().Seconds()})
+
+	// Clients
+	writeMetric(w, "redis_connected_clients", "gauge", "Number of client connections.",
+		metricSample{value: float64(network.ConnectionsCount())})
+	writeMetric(w, "redis_blocked_clients", "gauge", "Number of clients waiting in a blocking command.",
+		metricSample{value: float64(server.BlockedClients())})
+	writeMetric(w, "redis_connections_received_total", "counter", "Client connections accepted since startup.",
+		metricSample{value: float64(server.TotalConnectionsReceived())})
+
+	// Commands
+	writeMetric(w, "redis_commands_processed_total", "counter", "Commands executed since startup.",
+		metricSample{value: float64(server.TotalCommandsProcessed())})
+	stats := server.CommandStats()
+	calls := make([]metricSample, len(stats))
+	durations := make([]metricSample, len(stats))
+	for i, stat := range stats {
+		labels := fmt.Sprintf("cmd=%q", stat.Name)
+		calls[i] = metricSample{labels: labels, value: float64(stat.Calls)}
+		durations[i] = metricSample{labels: labels, value: float64(stat.Usec) / 1e6}
+	}
+	writeMetric(w, "redis_commands_total", "counter", "Calls of each command since startup.", calls...)
+	writeMetric(w, "redis_commands_duration_seconds_total", "counter", "Time spent executing each command.", durations...)
+	var latencies []metricSample
+	for _, latency := range server.CommandLatencies(server.StoreState.LatencyTrackingInfoPercentiles) {
+		for i, percentile := range latency.Percentiles {
+			labels := fmt.Sprintf("cmd=%q,quantile=%q", latency.Name, strconv.FormatFloat(percentile/100, 'g', -1, 64))
+			latencies = append(latencies, metricSample{labels: labels, value: latency.Usec[i] / 1e6})
+		}
+	}
+	writeMetric(w, "redis_command_latency_seconds", "summary", "Latency percentiles of each command.", latencies...)
+
+	// Keyspace and memory
+	keyspace := server.Memory.KeyspaceStats(time.Now().UnixMilli())
+	writeMetric(w, "redis_db_keys", "gauge", "Number of keys in the database.",
+		metricSample{labels: `db="db0"`, value: float64(keyspace.Keys)})
+	writeMetric(w, "redis_db_keys_expiring", "gauge", "Number of keys with an expiration in the database.",
+		metricSample{labels: `db="db0"`, value: float64(keyspace.Expires)})
+	writeMetric(w, "redis_expired_keys_total", "counter", "Keys removed because their expiration passed.",
+		metricSample{value: float64(server.ExpiredKeys())})
+	writeMetric(w, "redis_keyspace_hits_total", "counter", "Lookups of read commands that found the key.",
+		metricSample{value: float64(server.KeyspaceHits())})
+	writeMetric(w, "redis_keyspace_misses_total", "counter", "Lookups of read commands that did not find the key.",
+		metricSample{value: float64(server.KeyspaceMisses())})
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	writeMetric(w, "redis_memory_used_bytes", "gauge", "Heap memory allocated by the server.",
+		metricSample{value: float64(mem.HeapAlloc)})
+
+	writeReplicationMetrics(w)
+	writePersistenceMetrics(w)
+}
+
+// writeReplicationMetrics writes the replication offset, the state of the link to the master
+// on a replica, and the replicas with their lag on a master
+func writeReplicationMetrics(w io.Writer) {
+	state := server.StoreState
+	writeMetric(w, "redis_master_repl_offset", "gauge", "Replication offset of the server.",
+		metricSample{value: float64(state.MasterReplOffset)})
+
+	if state.Role == "slave" {
+		writeMetric(w, "redis_master_link_up", "gauge", "Whether the link to the master is up.",
+			metricSample{value: metricBool(network.MasterLinkUp())})
+		return
+	}
+
+	replicaIDs := network.ReplicaIDs()
+	writeMetric(w, "redis_connected_slaves", "gauge", "Number of connected replicas.",
+		metricSample{value: float64(len(replicaIDs))})
+
+	now := time.Now().UnixMilli()
+	lags := make([]metricSample, 0, len(replicaIDs))
+	for _, replicaID := range replicaIDs {
+		replica, ok := network.ReplicaInfoGet(replicaID)
+		if !ok {
+			continue
+		}
+		lag := 0.0
+		if replica.LastAck > 0 {
+			lag = float64(now-replica.LastAck) / 1000
+		}
+		labels := fmt.Sprintf("addr=%q", net.JoinHostPort(replica.IP, replica.ListeningPort))
+		lags = append(lags, metricSample{labels: labels, value: lag})
+	}
+	writeMetric(w, "redis_replica_lag_seconds", "gauge", "Seconds since each replica last acknowledged the offset.", lags...)
+}
+
+// writePersistenceMetrics writes the state of RDB snapshots and of the append only file
+func writePersistenceMetrics(w io.Writer) {
+	stats := storage.GetPersistenceStats()
+	writeMetric(w, "redis_rdb_changes_since_last_save", "gauge", "Writes since the last RDB snapshot.",
+		metricSample{value: float64(stats.ChangesSinceLastSave)})
+	writeMetric(w, "redis_rdb_bgsave_in_progress", "gauge", "Whether a background save is running.",
+		metricSample{value: metricBool(stats.BgsaveInProgress)})
+	writeMetric(w, "redis_rdb_last_save_timestamp_seconds", "gauge", "Unix time of the last successful save.",
+		metricSample{value: float64(stats.LastSaveTime)})
+	writeMetric(w, "redis_rdb_last_bgsave_ok", "gauge", "Whether the last background save succeeded.",
+		metricSample{value: metricBool(stats.LastBgsaveOK)})
+	writeMetric(w, "redis_aof_enabled", "gauge", "Whether the append only file is enabled.",
+		metricSample{value: metricBool(stats.AOFEnabled)})
+	writeMetric(w, "redis_aof_rewrite_in_progress", "gauge", "Whether an append only file rewrite is running.",
+		metricSample{value: metricBool(stats.AOFRewriteInProgress)})
+	writeMetric(w, "redis_aof_last_write_ok", "gauge", "Whether the last write to the append only file succeeded.",
+		metricSample{value: metricBool(stats.AOFLastWriteOK)})
+}
+
+// writeMetric writes a metric family: its help and type comments, then one line per sample
+func writeMetric(w io.Writer, name, kind, help string, samples ...metricSample) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, sample := range samples {
+		value := strconv.FormatFloat(sample.value, 'g', -1, 64)
+		if sample.labels == "" {
+			fmt.Fprintf(w, "%s %s\n", name, value)
+		} else {
+			fmt.Fprintf(w, "%s{%s} %s\n", name, sample.labels, value)
+		}
+	}
+}
+
+// metricBool returns 1 for true and 0 for false
+func metricBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
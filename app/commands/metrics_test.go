@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn), MasterReplOffset: 42,
+		LatencyTracking: true, LatencyTrackingInfoPercentiles: []float64{50, 99}})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	clearMemory()
+	server.Memory.Set("a", shared.MemoryEntry{Value: "1"})
+	server.Memory.Set("b", shared.MemoryEntry{Value: "2", Expires: time.Now().Add(time.Hour).UnixMilli()})
+	defer clearMemory()
+	server.RecordCommand("metricstest", 1500*time.Microsecond)
+
+	var buf bytes.Buffer
+	WriteMetrics(&buf)
+	output := buf.String()
+
+	expected := []string{
+		"# TYPE redis_connected_clients gauge\n",
+		"# TYPE redis_commands_total counter\n",
+		`redis_db_keys{db="db0"} 2` + "\n",
+		`redis_db_keys_expiring{db="db0"} 1` + "\n",
+		"redis_master_repl_offset 42\n",
+		"redis_connected_slaves 0\n",
+		"# TYPE redis_command_latency_seconds summary\n",
+		`redis_command_latency_seconds{cmd="metricstest",quantile="0.99"} 0.0015`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected %q in the metrics", line)
+		}
+	}
+	if !strings.Contains(output, `redis_commands_total{cmd="metricstest"} `) ||
+		!strings.Contains(output, `redis_commands_duration_seconds_total{cmd="metricstest"} `) {
+		t.Errorf("Expected per command metrics in %q", output)
+	}
+
+	// Every sample follows the help and type of its family
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			continue
+		}
+		name := strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]
+		if !strings.Contains(output, "# TYPE "+name+" ") {
+			t.Errorf("Sample %q has no type", line)
+		}
+	}
+
+	// A replica reports its link to the master instead of its replicas
+	server.StoreState.Role = "slave"
+	buf.Reset()
+	WriteMetrics(&buf)
+	if !strings.Contains(buf.String(), "redis_master_link_up 0\n") || strings.Contains(buf.String(), "redis_connected_slaves") {
+		t.Errorf("Unexpected replica metrics %q", buf.String())
+	}
+}
+
+func BenchmarkWriteMetrics(b *testing.B) {
+	server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	var buf bytes.Buffer
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		WriteMetrics(&buf)
+	}
+}
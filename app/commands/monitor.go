@@ -0,0 +1,19 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Monitor handles the MONITOR command
+// Usage: MONITOR
+// Returns: OK, then every command the server executes, as a simple string per command.
+// Each line shows the time, the client address and name, and the command with its arguments.
+//
+// Examples:
+//
+//	MONITOR    // Streams lines like +1700000000.123456 [0 127.0.0.1:51234 worker] "set" "key" "value"
+func Monitor(client *network.Client, args []shared.Value) shared.Value {
+	client.StartMonitor()
+	return shared.Value{Typ: "string", Str: "OK"}
+}
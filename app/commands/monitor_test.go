@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestMonitor(t *testing.T) {
+	initCommandHandlers()
+	clearMemory()
+	defer registerTestClient(t, "named-conn")()
+
+	serverConn, monitorConn := net.Pipe()
+	defer monitorConn.Close()
+	network.ConnectionsSet("monitor-conn", serverConn)
+	defer network.ConnectionsDelete("monitor-conn")
+	defer network.MonitorsDelete("monitor-conn")
+
+	if result := Monitor(network.ClientOf("monitor-conn"), []shared.Value{}); result.Typ != "string" || result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+
+	lines := make(chan string, 2)
+	go func() {
+		reader := bufio.NewReader(monitorConn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	Client(network.ClientOf("named-conn"), []shared.Value{{Typ: "bulk", Bulk: "SETNAME"}, {Typ: "bulk", Bulk: "worker"}})
+	network.ExecuteCommand("SET", "named-conn", []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "a b"}})
+
+	select {
+	case line := <-lines:
+		if !strings.HasPrefix(line, "+") || !strings.HasSuffix(line, ` [0 named-conn worker] "set" "key" "a b"`+"\r\n") {
+			t.Errorf("Unexpected monitor line %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the command to be sent to the monitor")
+	}
+}
+
+func TestMonitorWrongArgs(t *testing.T) {
+	result := runCommand("MONITOR", Monitor, "test-conn", []shared.Value{{Typ: "bulk", Bulk: "extra"}})
+	if result.Typ != "error" {
+		t.Errorf("Expected error response, got %v", result)
+	}
+}
@@ -11,13 +11,8 @@ import (
 // Examples:
 //
 //	MULTI           // Starts a transaction block
-func Multi(connID string, args []shared.Value) shared.Value {
-	if len(args) != 0 {
-		return createErrorResponse("ERR wrong number of arguments for 'multi' command")
-	}
-
-	// Create a new transaction for this connection (concurrency-safe)
-	network.TransactionsSet(connID, shared.Transaction{Commands: []shared.QueuedCommand{}})
+func Multi(client *network.Client, args []shared.Value) shared.Value {
+	client.StartTransaction()
 
 	return shared.Value{Typ: "string", Str: "OK"}
 }
@@ -24,7 +24,7 @@ func TestMulti(t *testing.T) {
 			args:     []shared.Value{},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
-				transaction, exists := network.Transactions["test-conn-1"]
+				transaction, exists := network.TransactionsGet("test-conn-1")
 				if !exists {
 					t.Error("Transaction should exist after MULTI")
 				}
@@ -45,7 +45,7 @@ func TestMulti(t *testing.T) {
 			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'multi' command"},
 			verify: func() {
 				// Transaction should not exist after error
-				if _, exists := network.Transactions["test-conn-2"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-2"); exists {
 					t.Error("Transaction should not exist after error")
 				}
 			},
@@ -60,7 +60,7 @@ func TestMulti(t *testing.T) {
 			expected: shared.Value{Typ: "error", Str: "ERR wrong number of arguments for 'multi' command"},
 			verify: func() {
 				// Transaction should not exist after error
-				if _, exists := network.Transactions["test-conn-3"]; exists {
+				if _, exists := network.TransactionsGet("test-conn-3"); exists {
 					t.Error("Transaction should not exist after error")
 				}
 			},
@@ -71,7 +71,7 @@ func TestMulti(t *testing.T) {
 			args:     []shared.Value{},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
-				transaction, exists := network.Transactions["test-conn-4"]
+				transaction, exists := network.TransactionsGet("test-conn-4")
 				if !exists {
 					t.Error("Transaction should exist after MULTI")
 				}
@@ -88,14 +88,14 @@ func TestMulti(t *testing.T) {
 
 			// Set up initial transaction for overwrite test
 			if tt.name == "multi overwrites existing transaction" {
-				network.Transactions["test-conn-4"] = shared.Transaction{
+				network.TransactionsSet("test-conn-4", shared.Transaction{
 					Commands: []shared.QueuedCommand{
 						{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}}},
 					},
-				}
+				})
 			}
 
-			result := Multi(tt.connID, tt.args)
+			result := runCommand("MULTI", Multi, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Multi() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -116,13 +116,13 @@ func TestMultiTransactionState(t *testing.T) {
 	connID := "test-conn-state"
 
 	// Test that MULTI creates a transaction
-	result := Multi(connID, []shared.Value{})
+	result := Multi(network.ClientOf(connID), []shared.Value{})
 	if result.Typ != "string" || result.Str != "OK" {
 		t.Errorf("MULTI should return OK, got %v", result)
 	}
 
 	// Verify transaction exists
-	transaction, exists := network.Transactions[connID]
+	transaction, exists := network.TransactionsGet(connID)
 	if !exists {
 		t.Error("Transaction should exist after MULTI")
 	}
@@ -144,32 +144,32 @@ func TestMultiMultipleConnections(t *testing.T) {
 	conn2 := "connection-2"
 
 	// Start transaction on first connection
-	result1 := Multi(conn1, []shared.Value{})
+	result1 := Multi(network.ClientOf(conn1), []shared.Value{})
 	if result1.Typ != "string" || result1.Str != "OK" {
 		t.Errorf("MULTI on conn1 should return OK, got %v", result1)
 	}
 
 	// Start transaction on second connection
-	result2 := Multi(conn2, []shared.Value{})
+	result2 := Multi(network.ClientOf(conn2), []shared.Value{})
 	if result2.Typ != "string" || result2.Str != "OK" {
 		t.Errorf("MULTI on conn2 should return OK, got %v", result2)
 	}
 
 	// Verify both transactions exist independently
-	if _, exists := network.Transactions[conn1]; !exists {
+	if _, exists := network.TransactionsGet(conn1); !exists {
 		t.Error("Transaction for conn1 should exist")
 	}
 
-	if _, exists := network.Transactions[conn2]; !exists {
+	if _, exists := network.TransactionsGet(conn2); !exists {
 		t.Error("Transaction for conn2 should exist")
 	}
 
 	// Verify transactions are independent
-	if len(network.Transactions[conn1].Commands) != 0 {
+	if transaction, _ := network.TransactionsGet(conn1); len(transaction.Commands) != 0 {
 		t.Error("Transaction for conn1 should start empty")
 	}
 
-	if len(network.Transactions[conn2].Commands) != 0 {
+	if transaction, _ := network.TransactionsGet(conn2); len(transaction.Commands) != 0 {
 		t.Error("Transaction for conn2 should start empty")
 	}
 }
@@ -182,9 +182,9 @@ func BenchmarkMulti(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Multi(connID, args)
+		Multi(network.ClientOf(connID), args)
 		// Clear transaction after each iteration to avoid accumulation
-		delete(network.Transactions, connID)
+		network.TransactionsDelete(connID)
 	}
 }
 
@@ -195,14 +195,14 @@ func BenchmarkMultiWithExistingTransaction(b *testing.B) {
 	args := []shared.Value{}
 
 	// Pre-create a transaction
-	network.Transactions[connID] = shared.Transaction{
+	network.TransactionsSet(connID, shared.Transaction{
 		Commands: []shared.QueuedCommand{
 			{Command: "SET", Args: []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}}},
 		},
-	}
+	})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Multi(connID, args)
+		Multi(network.ClientOf(connID), args)
 	}
 }
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// objectHelp is the reply of OBJECT HELP, one line per subcommand
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
+// object handles the OBJECT command.
+// Usage: OBJECT ENCODING key | OBJECT HELP
+// Returns: The encoding of the value of key, null for a missing key, or the help lines.
+//
+// The encoding is the one Redis would store the value with: int, embstr or raw for strings,
+// raw staying for strings changed in place, listpack or quicklist for lists, and so on.
+//
+// Examples:
+//
+//	OBJECT ENCODING counter   // Returns "int" after SET counter 10 or INCR counter
+//	OBJECT ENCODING mylist    // Returns "listpack"
+//	OBJECT ENCODING missing   // Returns null
+func Object(client *network.Client, args []shared.Value) shared.Value {
+	switch strings.ToUpper(args[0].Bulk) {
+	case "ENCODING":
+		entry, exists := server.LookupKeyRead(args[1].Bulk)
+		if !exists {
+			return shared.Null()
+		}
+		return shared.Value{Typ: "bulk", Bulk: storage.ObjectEncoding(entry)}
+	case "HELP":
+		lines := make([]shared.Value, len(objectHelp))
+		for i, line := range objectHelp {
+			lines[i] = shared.Value{Typ: "string", Str: line}
+		}
+		return shared.Value{Typ: "array", Array: lines}
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'object' command")
+	}
+}
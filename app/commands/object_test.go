@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestObjectEncoding(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+
+	encoding := func(key string) string {
+		result := runCommand("OBJECT", Object, "test-conn", aclArgs("ENCODING", key))
+		if result.Typ == "null" {
+			return "null"
+		}
+		return result.Bulk
+	}
+
+	Set(network.ClientOf("test-conn"), aclArgs("counter", "10"))
+	Set(network.ClientOf("test-conn"), aclArgs("short", "hello"))
+	Set(network.ClientOf("test-conn"), aclArgs("long", strings.Repeat("x", 45)))
+	Rpush(network.ClientOf("test-conn"), aclArgs("list", "a", "b"))
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "counter", expected: "int"},
+		{key: "short", expected: "embstr"},
+		{key: "long", expected: "raw"},
+		{key: "list", expected: "listpack"},
+		{key: "missing", expected: "null"},
+	}
+	for _, tt := range tests {
+		if got := encoding(tt.key); got != tt.expected {
+			t.Errorf("OBJECT ENCODING %s = %s, expected %s", tt.key, got, tt.expected)
+		}
+	}
+
+	// Integers changed in place stay integers until they grow past 64 bits
+	Incr(network.ClientOf("test-conn"), aclArgs("counter"))
+	if got := encoding("counter"); got != "int" {
+		t.Errorf("Expected int after INCR, got %s", got)
+	}
+	server.Memory.Set("huge", shared.MemoryEntry{Value: "9223372036854775807"})
+	Incr(network.ClientOf("test-conn"), aclArgs("huge"))
+	if got := encoding("huge"); got != "raw" {
+		t.Errorf("Expected raw past 64 bits, got %s", got)
+	}
+	Set(network.ClientOf("test-conn"), aclArgs("huge", "small"))
+	if got := encoding("huge"); got != "embstr" {
+		t.Errorf("Expected SET to store embstr again, got %s", got)
+	}
+}
+
+func TestObjectHelp(t *testing.T) {
+	result := runCommand("OBJECT", Object, "test-conn", aclArgs("HELP"))
+	if result.Typ != "array" || len(result.Array) != len(objectHelp) {
+		t.Fatalf("Expected the help lines, got %+v", result)
+	}
+	if result := runCommand("OBJECT", Object, "test-conn", aclArgs("FREQ", "key")); result.Str != "ERR unknown subcommand for 'object' command" {
+		t.Errorf("Expected an unknown subcommand error, got %+v", result)
+	}
+	if result := runCommand("OBJECT", Object, "test-conn", aclArgs("ENCODING")); result.Str != "ERR wrong number of arguments for 'object|encoding' command" {
+		t.Errorf("Expected an arity error, got %+v", result)
+	}
+}
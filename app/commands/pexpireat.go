@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Pexpireat handles the PEXPIREAT command
+// Usage: PEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT]
+// Returns: 1 if the expiration was set, 0 if the key doesn't exist or an option refused it.
+//
+// The key expires at the given Unix time in milliseconds, of any type. A time already past
+// deletes the key. NX only sets an expiration on a key without one, XX only changes an
+// existing one, and GT and LT only move it later or sooner, a key without one counting as
+// never expiring.
+//
+// Examples:
+//
+//	PEXPIREAT mykey 1700000000000      // Returns 1
+//	PEXPIREAT mykey 1800000000000 NX   // Returns 0, mykey already expires
+func Pexpireat(client *network.Client, args []shared.Value) shared.Value {
+	key := args[0].Bulk
+	at, err := newArgScanner(args[1:2]).Int64()
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+
+	var nx, xx, gt, lt bool
+	scanner := newArgScanner(args[2:])
+	for !scanner.Done() {
+		switch option := scanner.NextOption(); option {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		default:
+			return createErrorResponse("ERR Unsupported option " + option)
+		}
+	}
+	if nx && (xx || gt || lt) {
+		return createErrorResponse("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if gt && lt {
+		return createErrorResponse("ERR GT and LT options at the same time are not compatible")
+	}
+
+	entry, exists := server.LookupKeyRead(key)
+	if !exists {
+		return shared.Value{Typ: "integer", Num: 0}
+	}
+	// A key without an expiration never expires: GT never applies to it, LT always does
+	switch {
+	case nx && entry.Expires > 0,
+		xx && entry.Expires == 0,
+		gt && (entry.Expires == 0 || at <= entry.Expires),
+		lt && entry.Expires > 0 && at >= entry.Expires:
+		return shared.Value{Typ: "integer", Num: 0}
+	}
+
+	if at <= time.Now().UnixMilli() {
+		if server.Memory.Delete(key) {
+			server.NotifyKeyModified(0, key, "del")
+			client.MarkDirty(1)
+		}
+		return shared.Value{Typ: "integer", Num: 1}
+	}
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		if !exists {
+			return entry, false
+		}
+		entry.Expires = at
+		return entry, true
+	})
+	server.NotifyKeyModified(0, key, "expire")
+	client.MarkDirty(1)
+	return shared.Value{Typ: "integer", Num: 1}
+}
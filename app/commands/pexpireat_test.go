@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestPexpireat(t *testing.T) {
+	soon := strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10)
+	later := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)
+
+	tests := []struct {
+		name     string
+		before   string // Expiration of the key before the command, "" for a missing key
+		args     []string
+		expected shared.Value
+		after    string // Expiration of the key after the command, "" for a deleted key
+	}{
+		{name: "sets expiration", before: "0", args: []string{soon}, expected: integer(1), after: soon},
+		{name: "missing key", before: "", args: []string{soon}, expected: integer(0)},
+		{name: "past time deletes", before: "0", args: []string{past}, expected: integer(1)},
+		{name: "NX without expiration", before: "0", args: []string{soon, "NX"}, expected: integer(1), after: soon},
+		{name: "NX with expiration", before: later, args: []string{soon, "nx"}, expected: integer(0), after: later},
+		{name: "XX without expiration", before: "0", args: []string{soon, "XX"}, expected: integer(0), after: "0"},
+		{name: "GT later", before: soon, args: []string{later, "GT"}, expected: integer(1), after: later},
+		{name: "GT sooner", before: later, args: []string{soon, "GT"}, expected: integer(0), after: later},
+		{name: "GT without expiration", before: "0", args: []string{soon, "GT"}, expected: integer(0), after: "0"},
+		{name: "LT without expiration", before: "0", args: []string{soon, "LT"}, expected: integer(1), after: soon},
+		{name: "not an integer", before: "0", args: []string{"soon"}, expected: shared.ErrorValue("ERR value is not an integer or out of range"), after: "0"},
+		{name: "NX and XX", before: "0", args: []string{soon, "NX", "XX"}, expected: shared.ErrorValue("ERR NX and XX, GT or LT options at the same time are not compatible"), after: "0"},
+		{name: "GT and LT", before: "0", args: []string{soon, "GT", "LT"}, expected: shared.ErrorValue("ERR GT and LT options at the same time are not compatible"), after: "0"},
+		{name: "unknown option", before: "0", args: []string{soon, "KEEPTTL"}, expected: shared.ErrorValue("ERR Unsupported option KEEPTTL"), after: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearMemory()
+			if tt.before != "" {
+				// Any type can expire
+				expires, _ := strconv.ParseInt(tt.before, 10, 64)
+				server.Memory.Set("key", shared.MemoryEntry{List: shared.FromArray([]string{"a"}), Expires: expires})
+			}
+
+			result := runCommand("PEXPIREAT", Pexpireat, "test-conn", restoreArgs(append([]string{"key"}, tt.args...)...))
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+			entry, exists := server.Memory.Get("key")
+			if tt.after == "" {
+				if exists {
+					t.Errorf("Expected the key not to exist, got %+v", entry)
+				}
+				return
+			}
+			if strconv.FormatInt(entry.Expires, 10) != tt.after {
+				t.Errorf("Expected the key to expire at %s, got %d", tt.after, entry.Expires)
+			}
+		})
+	}
+}
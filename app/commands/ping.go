@@ -1,7 +1,7 @@
 package commands
 
 import (
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -10,9 +10,9 @@ import (
 // Returns: "PONG" if no message provided, otherwise echoes the provided message.
 // In subscribed mode, returns a RESP array with "pong" and empty bulk string.
 // This is typically used to test if the server is alive and responsive.
-func Ping(connID string, args []shared.Value) shared.Value {
+func Ping(client *network.Client, args []shared.Value) shared.Value {
 	// Check if client is in subscribed mode
-	if pubsub.SubscribedModeGet(connID) {
+	if client.Subscribed() {
 		// In subscribed mode, return array with "pong" and empty bulk string
 		return shared.Value{
 			Typ: "array",
@@ -27,5 +27,5 @@ func Ping(connID string, args []shared.Value) shared.Value {
 		return shared.Value{Typ: "string", Str: "PONG"}
 	}
 
-	return Echo(connID, args)
+	return Echo(client, args)
 }
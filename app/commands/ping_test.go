@@ -3,7 +3,7 @@ package commands
 import (
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -32,7 +32,7 @@ func TestPing(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := Ping(tt.connID, tt.args)
+			result := runCommand("PING", Ping, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Ping() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -51,10 +51,10 @@ func TestPingInSubscribedMode(t *testing.T) {
 
 		// Subscribe to enter subscribed mode
 		args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Test PING in subscribed mode
-		result := Ping(connID, []shared.Value{})
+		result := Ping(network.ClientOf(connID), []shared.Value{})
 
 		// Should return array with "pong" and empty bulk string
 		expected := shared.Value{
@@ -82,8 +82,8 @@ func TestPingInSubscribedMode(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("ping with message in subscribed mode", func(t *testing.T) {
@@ -91,10 +91,10 @@ func TestPingInSubscribedMode(t *testing.T) {
 
 		// Subscribe to enter subscribed mode
 		args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Test PING with message in subscribed mode
-		result := Ping(connID, []shared.Value{{Typ: "bulk", Bulk: "Hello"}})
+		result := Ping(network.ClientOf(connID), []shared.Value{{Typ: "bulk", Bulk: "Hello"}})
 
 		// Should still return array with "pong" and empty bulk string (ignores message in subscribed mode)
 		expected := shared.Value{
@@ -122,20 +122,20 @@ func TestPingInSubscribedMode(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("ping works normally when not in subscribed mode", func(t *testing.T) {
 		connID := "test-conn-normal"
 
 		// Should not be in subscribed mode initially
-		if pubsub.SubscribedModeGet(connID) {
+		if network.SubscribedModeGet(connID) {
 			t.Error("Client should not be in subscribed mode initially")
 		}
 
 		// Test PING without message
-		result := Ping(connID, []shared.Value{})
+		result := Ping(network.ClientOf(connID), []shared.Value{})
 		expected := shared.Value{Typ: "string", Str: "PONG"}
 
 		if result.Typ != expected.Typ || result.Str != expected.Str {
@@ -143,7 +143,7 @@ func TestPingInSubscribedMode(t *testing.T) {
 		}
 
 		// Test PING with message
-		result = Ping(connID, []shared.Value{{Typ: "bulk", Bulk: "Hello"}})
+		result = Ping(network.ClientOf(connID), []shared.Value{{Typ: "bulk", Bulk: "Hello"}})
 		expected = shared.Value{Typ: "string", Str: "Hello"}
 
 		if result.Typ != expected.Typ || result.Str != expected.Str {
@@ -158,7 +158,7 @@ func BenchmarkPing(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Ping(connID, args)
+		Ping(network.ClientOf(connID), args)
 	}
 }
 
@@ -170,6 +170,6 @@ func BenchmarkPingWithMessage(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Ping(connID, args)
+		Ping(network.ClientOf(connID), args)
 	}
 }
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// profilingLog logs the messages of the profiling subsystem
+var profilingLog = logger.New("profiling")
+
+// StartProfilingServer serves the Go runtime profiles of net/http/pprof at /debug/pprof/ over
+// HTTP on every bind address, at debug-port, so a running server can be profiled with
+// go tool pprof, until the returned server is closed. It does nothing and returns nil when
+// debug-port is 0.
+//
+// Examples:
+//
+//	go tool pprof http://localhost:6060/debug/pprof/profile?seconds=10   // CPU profile
+//	curl http://localhost:6060/debug/pprof/goroutine?debug=2             // Every goroutine stack
+func StartProfilingServer() (*http.Server, error) {
+	if server.StoreState.DebugPort == 0 {
+		return nil, nil
+	}
+
+	// Registered on a mux of our own, the default one may be used by an embedding program
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return serveHTTP(server.StoreState.DebugPort, mux, profilingLog, "profiles", "/debug/pprof/")
+}
@@ -1,69 +1,87 @@
 package commands
 
 import (
-	"encoding/hex"
+	"bytes"
 	"fmt"
-	"os"
-	"path/filepath"
 
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
 	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
+// replicationLog logs the messages of the replication subsystem
+var replicationLog = logger.New("replication")
+
 // psync handles the PSYNC command.
-// Usage: PSYNC masterReplID masterReplOffset
+// Usage: PSYNC masterReplID masterReplOffset [FAILOVER]
 // Returns: "FULLRESYNC masterReplID masterReplOffset" followed by RDB file
 // This is typically used to synchronize a replica with a master.
-func Psync(connID string, args []shared.Value) shared.Value {
-	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'psync' command")
+// FAILOVER is sent by a master that was demoted by the FAILOVER command: the replica
+// receiving it promotes itself first, so the old master can resync as its replica.
+func Psync(client *network.Client, args []shared.Value) shared.Value {
+	if len(args) >= 3 && isOption(args[2], "FAILOVER") && server.StoreState.Role == "slave" {
+		if args[0].Bulk != server.StoreState.MasterReplID {
+			return createErrorResponse("ERR PSYNC FAILOVER replid must match my replid.")
+		}
+		network.PromoteToMaster()
 	}
 
-	fullResyncResponse := fmt.Sprintf("FULLRESYNC %s %d", server.StoreState.MasterReplID, server.StoreState.MasterReplOffset)
+	// Diskless sync replies with FULLRESYNC when the snapshot transfer starts,
+	// and registers the replica for propagation once it has been sent
+	if conn, exists := network.ConnectionsGet(client.ConnID); exists && server.StoreState.ReplDisklessSync {
+		network.QueueDisklessSync(client.ConnID, conn)
+		return shared.Value{Typ: network.NO_RESPONSE, Str: ""}
+	}
 
 	// Find the connection to send the RDB file
-	if conn, exists := network.ConnectionsGet(connID); exists {
-		// Register this replica connection for command propagation
-		network.ReplicasSet(connID, conn)
-
-		// Send the FULLRESYNC response first
-		response := shared.Value{Typ: "string", Str: fullResyncResponse}
-		conn.Write(response.Marshal())
-
-		// Send empty RDB file
-		rdbData, err := GetRDBData()
+	if conn, exists := network.ConnectionsGet(client.ConnID); exists {
+		// The snapshot and its offset are taken first: the writes made since are kept for
+		// the replica until it received the snapshot
+		snapshot, offset := network.StartReplicaSync(client.ConnID, conn)
+		rdbData, err := rdbOf(snapshot)
+		snapshot.Release()
 		if err != nil {
+			network.ReplicasDelete(client.ConnID)
 			return createErrorResponse("Failed to get RDB data")
 		}
+
+		response := shared.Value{Typ: "string", Str: fmt.Sprintf("FULLRESYNC %s %d", server.StoreState.MasterReplID, offset)}
+		conn.Write(response.Marshal())
+
+		// The RDB file doesn't count towards the output buffer limit of the replica
 		rdbHeader := fmt.Sprintf("$%d\r\n", len(rdbData))
-		conn.Write([]byte(rdbHeader))
-		conn.Write(rdbData)
+		network.WriteSnapshot(conn, []byte(rdbHeader))
+		network.WriteSnapshot(conn, rdbData)
+
+		// Register this replica connection for command propagation
+		if err := network.FinishReplicaSync(client.ConnID, offset); err != nil {
+			replicationLog.Warningf("Failed to resync replica %s: %v", client.ConnID, err)
+		}
 
 		// Return NO_RESPONSE to indicate we've already sent the response directly
 		return shared.Value{Typ: network.NO_RESPONSE, Str: ""}
 	}
 
 	// For other PSYNC requests, return FULLRESYNC with master info
-	return shared.Value{Typ: "string", Str: fullResyncResponse}
+	return shared.Value{Typ: "string", Str: fmt.Sprintf("FULLRESYNC %s %d", server.StoreState.MasterReplID, server.StoreState.MasterReplOffset)}
 }
 
-// GetRDBData returns the RDB file data, loading from disk if available
+// GetRDBData returns an RDB file of the current dataset, sent to a replica after FULLRESYNC.
+// It is taken from memory rather than read from disk, so the writes made since the last save
+// reach the replica too.
 func GetRDBData() ([]byte, error) {
-	// Try to load the actual RDB file first
-	filePath := filepath.Join(server.StoreState.ConfigDir, server.StoreState.ConfigDbfilename)
+	snapshot := storage.TakeSnapshot()
+	defer snapshot.Release()
+	return rdbOf(snapshot)
+}
 
-	if data, err := os.ReadFile(filePath); err == nil {
-		// Successfully loaded RDB file, parse it into memory
-		if parseErr := storage.ParseRDBData(data); parseErr != nil {
-			fmt.Printf("Warning: Failed to parse RDB file %s: %v\n", filePath, parseErr)
-		}
-		return data, nil
+// rdbOf serializes a snapshot as an RDB file
+func rdbOf(snapshot *storage.Snapshot) ([]byte, error) {
+	var data bytes.Buffer
+	if err := snapshot.WriteRDB(&data); err != nil {
+		return nil, err
 	}
-
-	// Fall back to empty RDB file if no file exists or can't be read
-	emptyRDBFileHex := "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fa056374696d65c26d08bc65fa08757365642d6d656dc2b0c41000fa08616f662d62617365c000fff06e3bfec0ff5aa2"
-	emptyRDB, err := hex.DecodeString(emptyRDBFileHex)
-	return emptyRDB, err
+	return data.Bytes(), nil
 }
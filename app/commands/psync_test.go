@@ -2,8 +2,13 @@ package commands
 
 import (
 	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -57,7 +62,7 @@ func TestPsync(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := Psync("test-conn", tt.args)
+			result := runCommand("PSYNC", Psync, "test-conn", tt.args)
 
 			if tt.expectError {
 				if result.Typ != "error" {
@@ -117,7 +122,7 @@ func TestPsyncWithDifferentMasterInfo(t *testing.T) {
 				{Typ: "bulk", Bulk: "-1"},
 			}
 
-			result := Psync("test-conn", args)
+			result := Psync(network.ClientOf("test-conn"), args)
 
 			if result.Typ != "string" {
 				t.Errorf("Expected string type, got %s", result.Typ)
@@ -153,7 +158,243 @@ func TestGetRDBData(t *testing.T) {
 	}
 }
 
+func TestPsyncSendsSnapshotBeforeStream(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+	server.SetStoreState(shared.State{Role: "master", MasterReplID: "test-repl-id", MasterReplOffset: 7, Replicas: make(map[string]net.Conn)})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	replica := &recordingConn{}
+	network.ConnectionsSet("replica-disk", replica)
+	defer network.ConnectionsDelete("replica-disk")
+	defer network.ReplicasDelete("replica-disk")
+
+	if result := Psync(network.ClientOf("replica-disk"), []shared.Value{{Typ: "bulk", Bulk: "?"}, {Typ: "bulk", Bulk: "-1"}}); result.Typ != network.NO_RESPONSE {
+		t.Fatalf("Expected no direct response, got %v", result)
+	}
+	if _, ok := network.ReplicasGet("replica-disk"); !ok {
+		t.Fatalf("Expected the replica to be registered after its snapshot")
+	}
+	output := replica.String()
+	if !strings.HasPrefix(output, "+FULLRESYNC test-repl-id 7\r\n$") || !strings.Contains(output, "\x03key\x05value") {
+		t.Errorf("Expected FULLRESYNC followed by the snapshot, got %q", output)
+	}
+}
+
+func TestReplicaSyncKeepsStreamUntilSnapshotSent(t *testing.T) {
+	clearMemory()
+	server.SetStoreState(shared.State{Role: "master", MasterReplID: "test-repl-id", Replicas: make(map[string]net.Conn)})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	replica := &recordingConn{}
+	defer network.ReplicasDelete("replica-sync")
+	snapshot, offset := network.StartReplicaSync("replica-sync", replica)
+	snapshot.Release()
+
+	// A write made after the snapshot waits for the snapshot to be sent
+	network.PropagateCommand("SET", []shared.Value{{Typ: "bulk", Bulk: "k"}, {Typ: "bulk", Bulk: "v"}})
+	if replica.Len() != 0 {
+		t.Fatalf("Expected nothing sent before the snapshot, got %q", replica.String())
+	}
+	if _, ok := network.ReplicasGet("replica-sync"); ok {
+		t.Fatalf("Expected the replica to be registered once synced")
+	}
+
+	replica.WriteString("<snapshot>")
+	if err := network.FinishReplicaSync("replica-sync", offset); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	network.PropagateCommand("DEL", []shared.Value{{Typ: "bulk", Bulk: "k"}})
+
+	expected := "<snapshot>*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n$3\r\nDEL\r\n$1\r\nk\r\n"
+	if replica.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, replica.String())
+	}
+	if info, _ := network.ReplicaInfoGet("replica-sync"); info.AckOffset != offset {
+		t.Errorf("Expected the replica to start at offset %d, got %d", offset, info.AckOffset)
+	}
+}
+
 // BenchmarkPsync benchmarks the PSYNC command
+func TestPsyncDisklessSync(t *testing.T) {
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+	server.SetStoreState(shared.State{
+		Role:                  "master",
+		MasterReplID:          "test-repl-id",
+		MasterReplOffset:      42,
+		Replicas:              make(map[string]net.Conn),
+		ReplDisklessSync:      true,
+		ReplDisklessSyncDelay: 0,
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	// One replica supports the EOF-marker format, the other needs a length prefix
+	streamed := &recordingConn{}
+	prefixed := &recordingConn{}
+	network.ConnectionsSet("replica-eof", streamed)
+	network.ConnectionsSet("replica-len", prefixed)
+	defer network.ConnectionsDelete("replica-eof")
+	defer network.ConnectionsDelete("replica-len")
+	defer network.ReplicasDelete("replica-eof")
+	defer network.ReplicasDelete("replica-len")
+	network.ReplicaInfoUpdate("replica-eof", func(info *shared.ReplicaInfo) {
+		info.Capabilities = []string{"eof", "psync2"}
+	})
+
+	args := []shared.Value{{Typ: "bulk", Bulk: "?"}, {Typ: "bulk", Bulk: "-1"}}
+	for _, connID := range []string{"replica-eof", "replica-len"} {
+		if result := Psync(network.ClientOf(connID), args); result.Typ != network.NO_RESPONSE {
+			t.Fatalf("Expected no direct response, got %v", result)
+		}
+	}
+
+	// Replicas are registered for propagation once their snapshot was sent
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, eofReady := network.ReplicasGet("replica-eof")
+		_, lenReady := network.ReplicasGet("replica-len")
+		if eofReady && lenReady {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected both replicas to be registered after the diskless sync")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The EOF-marker transfer ends with the same 40 character mark it announced
+	output := streamed.String()
+	prefix := "+FULLRESYNC test-repl-id 42\r\n$EOF:"
+	if !strings.HasPrefix(output, prefix) || len(output) < len(prefix)+42 {
+		t.Fatalf("Expected an EOF-marker transfer, got %q", output)
+	}
+	mark := output[len(prefix) : len(prefix)+40]
+	payload := strings.TrimSuffix(output[len(prefix)+42:], mark)
+	if !strings.HasSuffix(output, mark) || !strings.HasPrefix(payload, "REDIS0011") || !strings.Contains(payload, "\x03key\x05value") {
+		t.Errorf("Expected the snapshot delimited by %q, got %q", mark, output)
+	}
+
+	// The length-prefixed transfer carries the same snapshot
+	expected := "+FULLRESYNC test-repl-id 42\r\n$" + strconv.Itoa(len(payload)) + "\r\n" + payload
+	if prefixed.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, prefixed.String())
+	}
+}
+
+// gatedConn records what is written to it, holding the first write until released
+type gatedConn struct {
+	recordingConn
+	mu      sync.Mutex
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedConn) Write(b []byte) (int, error) {
+	if g.writing != nil {
+		close(g.writing)
+		g.writing = nil
+		<-g.release
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.recordingConn.Write(b)
+}
+
+func (g *gatedConn) String() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.recordingConn.String()
+}
+
+func TestPsyncDisklessSyncKeepsWritesMadeDuringTransfer(t *testing.T) {
+	clearMemory()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		MasterReplID:     "test-repl-id",
+		Replicas:         make(map[string]net.Conn),
+		ReplDisklessSync: true,
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	replica := &gatedConn{writing: make(chan struct{}), release: make(chan struct{})}
+	writing := replica.writing
+	network.ConnectionsSet("replica-gated", replica)
+	defer network.ConnectionsDelete("replica-gated")
+	defer network.ReplicasDelete("replica-gated")
+
+	Psync(network.ClientOf("replica-gated"), []shared.Value{{Typ: "bulk", Bulk: "?"}, {Typ: "bulk", Bulk: "-1"}})
+
+	// The write is made while the snapshot is being sent
+	<-writing
+	network.PropagateCommand("DEL", []shared.Value{{Typ: "bulk", Bulk: "k"}})
+	close(replica.release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := network.ReplicasGet("replica-gated"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the replica to be registered after the diskless sync")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if output := replica.String(); !strings.HasSuffix(output, "*2\r\n$3\r\nDEL\r\n$1\r\nk\r\n") {
+		t.Errorf("Expected the write to follow the snapshot, got %q", output)
+	}
+}
+
+func TestPsyncDisklessSyncSharesTheKeptStream(t *testing.T) {
+	clearMemory()
+	server.SetStoreState(shared.State{
+		Role:                  "master",
+		MasterReplID:          "test-repl-id",
+		Replicas:              make(map[string]net.Conn),
+		ReplDisklessSync:      true,
+		ReplDisklessSyncDelay: 1,
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	// Both replicas are served by the same transfer, held while the first one is written to
+	gated := &gatedConn{writing: make(chan struct{}), release: make(chan struct{})}
+	writing := gated.writing
+	other := &gatedConn{}
+	replicas := map[string]*gatedConn{"replica-first": gated, "replica-second": other}
+	for _, connID := range []string{"replica-first", "replica-second"} {
+		network.ConnectionsSet(connID, replicas[connID])
+		defer network.ConnectionsDelete(connID)
+		defer network.ReplicasDelete(connID)
+		Psync(network.ClientOf(connID), []shared.Value{{Typ: "bulk", Bulk: "?"}, {Typ: "bulk", Bulk: "-1"}})
+	}
+
+	<-writing
+	network.PropagateCommand("DEL", []shared.Value{{Typ: "bulk", Bulk: "k"}})
+	close(gated.release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, firstReady := network.ReplicasGet("replica-first")
+		_, secondReady := network.ReplicasGet("replica-second")
+		if firstReady && secondReady {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected both replicas to be registered after the diskless sync")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The write kept once for both is sent once to each
+	del := "*2\r\n$3\r\nDEL\r\n$1\r\nk\r\n"
+	for connID, replica := range replicas {
+		if output := replica.String(); !strings.HasSuffix(output, del) || strings.Count(output, del) != 1 {
+			t.Errorf("Expected %s to receive the write once after the snapshot, got %q", connID, output)
+		}
+	}
+}
+
 func BenchmarkPsync(b *testing.B) {
 	// Reset store state for clean benchmark
 	server.SetStoreState(shared.State{
@@ -170,6 +411,6 @@ func BenchmarkPsync(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Psync("bench-conn", args)
+		Psync(network.ClientOf("bench-conn"), args)
 	}
 }
@@ -2,7 +2,6 @@ package commands
 
 import (
 	"github.com/codecrafters-io/redis-starter-go/app/network"
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -16,16 +15,12 @@ import (
 // Examples:
 //
 //	PUBLISH mychannel "Hello, Redis!"   // Publish a message to the mychannel
-func Publish(connID string, args []shared.Value) shared.Value {
-	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'publish' command")
-	}
-
+func Publish(client *network.Client, args []shared.Value) shared.Value {
 	channel := args[0].Bulk
 	message := args[1].Bulk
 
 	// Send message to all subscribers and get the count of delivered messages
-	deliveredCount := pubsub.SendMessageToSubscribers(channel, message, network.ConnectionsGet, network.ConnectionsDelete, pubsub.SubscriptionsDelete, pubsub.SubscribedModeDelete)
+	deliveredCount := network.Publish(channel, message)
 
 	return shared.Value{Typ: "integer", Num: deliveredCount}
 }
@@ -8,13 +8,12 @@ import (
 	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/network"
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestPublish(t *testing.T) {
 	t.Run("publish with insufficient arguments", func(t *testing.T) {
-		result := Publish("test-conn", []shared.Value{})
+		result := runCommand("PUBLISH", Publish, "test-conn", []shared.Value{})
 		if result.Typ != "error" {
 			t.Errorf("Expected error for insufficient arguments, got: %v", result)
 		}
@@ -24,7 +23,7 @@ func TestPublish(t *testing.T) {
 	})
 
 	t.Run("publish with single argument", func(t *testing.T) {
-		result := Publish("test-conn", []shared.Value{{Typ: "bulk", Bulk: "channel"}})
+		result := runCommand("PUBLISH", Publish, "test-conn", []shared.Value{{Typ: "bulk", Bulk: "channel"}})
 		if result.Typ != "error" {
 			t.Errorf("Expected error for single argument, got: %v", result)
 		}
@@ -32,10 +31,10 @@ func TestPublish(t *testing.T) {
 
 	t.Run("publish to channel with no subscribers", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SubscriptionsDelete("test-conn-1")
-		pubsub.SubscriptionsDelete("test-conn-2")
+		network.SubscriptionsDelete("test-conn-1")
+		network.SubscriptionsDelete("test-conn-2")
 
-		result := Publish("test-conn", []shared.Value{
+		result := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: "test-channel"},
 			{Typ: "bulk", Bulk: "test-message"},
 		})
@@ -51,10 +50,10 @@ func TestPublish(t *testing.T) {
 		channel := "test-channel-1"
 
 		// Subscribe to channel
-		Subscribe(connID, []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf(connID), []shared.Value{{Typ: "bulk", Bulk: channel}})
 
 		// Publish to channel
-		result := Publish("test-conn", []shared.Value{
+		result := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "bulk", Bulk: "test-message"},
 		})
@@ -65,8 +64,8 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("publish to channel with multiple subscribers", func(t *testing.T) {
@@ -76,12 +75,12 @@ func TestPublish(t *testing.T) {
 		channel := "test-channel-2"
 
 		// Subscribe multiple clients to the same channel
-		Subscribe(connID1, []shared.Value{{Typ: "bulk", Bulk: channel}})
-		Subscribe(connID2, []shared.Value{{Typ: "bulk", Bulk: channel}})
-		Subscribe(connID3, []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf(connID1), []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf(connID2), []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf(connID3), []shared.Value{{Typ: "bulk", Bulk: channel}})
 
 		// Publish to channel
-		result := Publish("test-conn", []shared.Value{
+		result := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "bulk", Bulk: "test-message"},
 		})
@@ -92,12 +91,12 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID1)
-		pubsub.SubscribedModeDelete(connID2)
-		pubsub.SubscribedModeDelete(connID3)
-		pubsub.SubscriptionsDelete(connID1)
-		pubsub.SubscriptionsDelete(connID2)
-		pubsub.SubscriptionsDelete(connID3)
+		network.SubscribedModeDelete(connID1)
+		network.SubscribedModeDelete(connID2)
+		network.SubscribedModeDelete(connID3)
+		network.SubscriptionsDelete(connID1)
+		network.SubscriptionsDelete(connID2)
+		network.SubscriptionsDelete(connID3)
 	})
 
 	t.Run("publish to channel with mixed subscriptions", func(t *testing.T) {
@@ -108,12 +107,12 @@ func TestPublish(t *testing.T) {
 		channel2 := "test-channel-4"
 
 		// Subscribe clients to different channels
-		Subscribe(connID1, []shared.Value{{Typ: "bulk", Bulk: channel1}})
-		Subscribe(connID2, []shared.Value{{Typ: "bulk", Bulk: channel1}})
-		Subscribe(connID3, []shared.Value{{Typ: "bulk", Bulk: channel2}})
+		Subscribe(network.ClientOf(connID1), []shared.Value{{Typ: "bulk", Bulk: channel1}})
+		Subscribe(network.ClientOf(connID2), []shared.Value{{Typ: "bulk", Bulk: channel1}})
+		Subscribe(network.ClientOf(connID3), []shared.Value{{Typ: "bulk", Bulk: channel2}})
 
 		// Publish to channel1 (should have 2 subscribers)
-		result1 := Publish("test-conn", []shared.Value{
+		result1 := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel1},
 			{Typ: "bulk", Bulk: "test-message-1"},
 		})
@@ -124,7 +123,7 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Publish to channel2 (should have 1 subscriber)
-		result2 := Publish("test-conn", []shared.Value{
+		result2 := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel2},
 			{Typ: "bulk", Bulk: "test-message-2"},
 		})
@@ -135,12 +134,12 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID1)
-		pubsub.SubscribedModeDelete(connID2)
-		pubsub.SubscribedModeDelete(connID3)
-		pubsub.SubscriptionsDelete(connID1)
-		pubsub.SubscriptionsDelete(connID2)
-		pubsub.SubscriptionsDelete(connID3)
+		network.SubscribedModeDelete(connID1)
+		network.SubscribedModeDelete(connID2)
+		network.SubscribedModeDelete(connID3)
+		network.SubscriptionsDelete(connID1)
+		network.SubscriptionsDelete(connID2)
+		network.SubscriptionsDelete(connID3)
 	})
 
 	t.Run("publish to channel with client subscribed to multiple channels", func(t *testing.T) {
@@ -150,15 +149,15 @@ func TestPublish(t *testing.T) {
 		channel2 := "test-channel-6"
 
 		// Subscribe client1 to both channels
-		Subscribe(connID1, []shared.Value{
+		Subscribe(network.ClientOf(connID1), []shared.Value{
 			{Typ: "bulk", Bulk: channel1},
 			{Typ: "bulk", Bulk: channel2},
 		})
 		// Subscribe client2 to only channel1
-		Subscribe(connID2, []shared.Value{{Typ: "bulk", Bulk: channel1}})
+		Subscribe(network.ClientOf(connID2), []shared.Value{{Typ: "bulk", Bulk: channel1}})
 
 		// Publish to channel1 (should have 2 subscribers)
-		result1 := Publish("test-conn", []shared.Value{
+		result1 := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel1},
 			{Typ: "bulk", Bulk: "test-message-1"},
 		})
@@ -169,7 +168,7 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Publish to channel2 (should have 1 subscriber)
-		result2 := Publish("test-conn", []shared.Value{
+		result2 := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel2},
 			{Typ: "bulk", Bulk: "test-message-2"},
 		})
@@ -180,10 +179,10 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID1)
-		pubsub.SubscribedModeDelete(connID2)
-		pubsub.SubscriptionsDelete(connID1)
-		pubsub.SubscriptionsDelete(connID2)
+		network.SubscribedModeDelete(connID1)
+		network.SubscribedModeDelete(connID2)
+		network.SubscriptionsDelete(connID1)
+		network.SubscriptionsDelete(connID2)
 	})
 
 	t.Run("publish with unicode channel and message", func(t *testing.T) {
@@ -192,10 +191,10 @@ func TestPublish(t *testing.T) {
 		message := "测试消息"
 
 		// Subscribe to channel
-		Subscribe(connID, []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf(connID), []shared.Value{{Typ: "bulk", Bulk: channel}})
 
 		// Publish to channel
-		result := Publish("test-conn", []shared.Value{
+		result := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "bulk", Bulk: message},
 		})
@@ -206,8 +205,8 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("publish after unsubscribe", func(t *testing.T) {
@@ -216,11 +215,11 @@ func TestPublish(t *testing.T) {
 		channel := "test-channel-7"
 
 		// Subscribe both clients to channel
-		Subscribe(connID1, []shared.Value{{Typ: "bulk", Bulk: channel}})
-		Subscribe(connID2, []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf(connID1), []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf(connID2), []shared.Value{{Typ: "bulk", Bulk: channel}})
 
 		// Publish to channel (should have 2 subscribers)
-		result1 := Publish("test-conn", []shared.Value{
+		result1 := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "bulk", Bulk: "test-message-1"},
 		})
@@ -231,10 +230,10 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Unsubscribe one client
-		Unsubscribe(connID1, []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Unsubscribe(network.ClientOf(connID1), []shared.Value{{Typ: "bulk", Bulk: channel}})
 
 		// Publish to channel (should have 1 subscriber)
-		result2 := Publish("test-conn", []shared.Value{
+		result2 := Publish(network.ClientOf("test-conn"), []shared.Value{
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "bulk", Bulk: "test-message-2"},
 		})
@@ -245,10 +244,10 @@ func TestPublish(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID1)
-		pubsub.SubscribedModeDelete(connID2)
-		pubsub.SubscriptionsDelete(connID1)
-		pubsub.SubscriptionsDelete(connID2)
+		network.SubscribedModeDelete(connID1)
+		network.SubscribedModeDelete(connID2)
+		network.SubscriptionsDelete(connID1)
+		network.SubscriptionsDelete(connID2)
 	})
 }
 
@@ -261,7 +260,7 @@ func BenchmarkPublish(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Publish(connID, args)
+		Publish(network.ClientOf(connID), args)
 	}
 }
 
@@ -314,8 +313,7 @@ func (m *mockAddr) String() string  { return m.addr }
 func TestPublishMessageDelivery(t *testing.T) {
 	t.Run("publish delivers message to single subscriber", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SetSubscriptionsMap(make(map[string][]string))
-		pubsub.SetSubscribedModeMap(make(map[string]bool))
+		network.SubscriptionsClear()
 
 		// Create mock connections
 		conn1 := &MockConnection{
@@ -334,10 +332,10 @@ func TestPublishMessageDelivery(t *testing.T) {
 		network.ConnectionsSet("127.0.0.1:12346", conn2)
 
 		// Subscribe client1 to channel
-		Subscribe("127.0.0.1:12345", []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
+		Subscribe(network.ClientOf("127.0.0.1:12345"), []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
 
 		// Publish message
-		result := Publish("127.0.0.1:12347", []shared.Value{
+		result := Publish(network.ClientOf("127.0.0.1:12347"), []shared.Value{
 			{Typ: "bulk", Bulk: "test-channel"},
 			{Typ: "bulk", Bulk: "hello world"},
 		})
@@ -362,14 +360,13 @@ func TestPublishMessageDelivery(t *testing.T) {
 		// Clean up
 		network.ConnectionsDelete("127.0.0.1:12345")
 		network.ConnectionsDelete("127.0.0.1:12346")
-		pubsub.SubscriptionsDelete("127.0.0.1:12345")
-		pubsub.SubscribedModeDelete("127.0.0.1:12345")
+		network.SubscriptionsDelete("127.0.0.1:12345")
+		network.SubscribedModeDelete("127.0.0.1:12345")
 	})
 
 	t.Run("publish delivers message to multiple subscribers", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SetSubscriptionsMap(make(map[string][]string))
-		pubsub.SetSubscribedModeMap(make(map[string]bool))
+		network.SubscriptionsClear()
 
 		// Create mock connections
 		conn1 := &MockConnection{
@@ -394,12 +391,12 @@ func TestPublishMessageDelivery(t *testing.T) {
 		network.ConnectionsSet("127.0.0.1:12347", conn3)
 
 		// Subscribe all clients to the same channel
-		Subscribe("127.0.0.1:12345", []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
-		Subscribe("127.0.0.1:12346", []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
-		Subscribe("127.0.0.1:12347", []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
+		Subscribe(network.ClientOf("127.0.0.1:12345"), []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
+		Subscribe(network.ClientOf("127.0.0.1:12346"), []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
+		Subscribe(network.ClientOf("127.0.0.1:12347"), []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
 
 		// Publish message
-		result := Publish("127.0.0.1:12348", []shared.Value{
+		result := Publish(network.ClientOf("127.0.0.1:12348"), []shared.Value{
 			{Typ: "bulk", Bulk: "test-channel"},
 			{Typ: "bulk", Bulk: "broadcast message"},
 		})
@@ -422,18 +419,17 @@ func TestPublishMessageDelivery(t *testing.T) {
 		network.ConnectionsDelete("127.0.0.1:12345")
 		network.ConnectionsDelete("127.0.0.1:12346")
 		network.ConnectionsDelete("127.0.0.1:12347")
-		pubsub.SubscriptionsDelete("127.0.0.1:12345")
-		pubsub.SubscriptionsDelete("127.0.0.1:12346")
-		pubsub.SubscriptionsDelete("127.0.0.1:12347")
-		pubsub.SubscribedModeDelete("127.0.0.1:12345")
-		pubsub.SubscribedModeDelete("127.0.0.1:12346")
-		pubsub.SubscribedModeDelete("127.0.0.1:12347")
+		network.SubscriptionsDelete("127.0.0.1:12345")
+		network.SubscriptionsDelete("127.0.0.1:12346")
+		network.SubscriptionsDelete("127.0.0.1:12347")
+		network.SubscribedModeDelete("127.0.0.1:12345")
+		network.SubscribedModeDelete("127.0.0.1:12346")
+		network.SubscribedModeDelete("127.0.0.1:12347")
 	})
 
 	t.Run("publish delivers message only to subscribers of specific channel", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SetSubscriptionsMap(make(map[string][]string))
-		pubsub.SetSubscribedModeMap(make(map[string]bool))
+		network.SubscriptionsClear()
 
 		// Create mock connections
 		conn1 := &MockConnection{
@@ -458,12 +454,12 @@ func TestPublishMessageDelivery(t *testing.T) {
 		network.ConnectionsSet("127.0.0.1:12347", conn3)
 
 		// Subscribe clients to different channels
-		Subscribe("127.0.0.1:12345", []shared.Value{{Typ: "bulk", Bulk: "channel-1"}})
-		Subscribe("127.0.0.1:12346", []shared.Value{{Typ: "bulk", Bulk: "channel-2"}})
-		Subscribe("127.0.0.1:12347", []shared.Value{{Typ: "bulk", Bulk: "channel-1"}})
+		Subscribe(network.ClientOf("127.0.0.1:12345"), []shared.Value{{Typ: "bulk", Bulk: "channel-1"}})
+		Subscribe(network.ClientOf("127.0.0.1:12346"), []shared.Value{{Typ: "bulk", Bulk: "channel-2"}})
+		Subscribe(network.ClientOf("127.0.0.1:12347"), []shared.Value{{Typ: "bulk", Bulk: "channel-1"}})
 
 		// Publish message to channel-1
-		result := Publish("127.0.0.1:12348", []shared.Value{
+		result := Publish(network.ClientOf("127.0.0.1:12348"), []shared.Value{
 			{Typ: "bulk", Bulk: "channel-1"},
 			{Typ: "bulk", Bulk: "channel-1 message"},
 		})
@@ -489,18 +485,17 @@ func TestPublishMessageDelivery(t *testing.T) {
 		network.ConnectionsDelete("127.0.0.1:12345")
 		network.ConnectionsDelete("127.0.0.1:12346")
 		network.ConnectionsDelete("127.0.0.1:12347")
-		pubsub.SubscriptionsDelete("127.0.0.1:12345")
-		pubsub.SubscriptionsDelete("127.0.0.1:12346")
-		pubsub.SubscriptionsDelete("127.0.0.1:12347")
-		pubsub.SubscribedModeDelete("127.0.0.1:12345")
-		pubsub.SubscribedModeDelete("127.0.0.1:12346")
-		pubsub.SubscribedModeDelete("127.0.0.1:12347")
+		network.SubscriptionsDelete("127.0.0.1:12345")
+		network.SubscriptionsDelete("127.0.0.1:12346")
+		network.SubscriptionsDelete("127.0.0.1:12347")
+		network.SubscribedModeDelete("127.0.0.1:12345")
+		network.SubscribedModeDelete("127.0.0.1:12346")
+		network.SubscribedModeDelete("127.0.0.1:12347")
 	})
 
 	t.Run("publish handles failed connections gracefully", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SetSubscriptionsMap(make(map[string][]string))
-		pubsub.SetSubscribedModeMap(make(map[string]bool))
+		network.SubscriptionsClear()
 
 		// Create mock connections
 		conn1 := &MockConnection{
@@ -514,11 +509,11 @@ func TestPublishMessageDelivery(t *testing.T) {
 		network.ConnectionsSet("127.0.0.1:12345", conn1)
 
 		// Subscribe both clients (one with connection, one without)
-		Subscribe("127.0.0.1:12345", []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
-		Subscribe("127.0.0.1:12346", []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
+		Subscribe(network.ClientOf("127.0.0.1:12345"), []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
+		Subscribe(network.ClientOf("127.0.0.1:12346"), []shared.Value{{Typ: "bulk", Bulk: "test-channel"}})
 
 		// Publish message
-		result := Publish("127.0.0.1:12347", []shared.Value{
+		result := Publish(network.ClientOf("127.0.0.1:12347"), []shared.Value{
 			{Typ: "bulk", Bulk: "test-channel"},
 			{Typ: "bulk", Bulk: "test message"},
 		})
@@ -536,16 +531,15 @@ func TestPublishMessageDelivery(t *testing.T) {
 
 		// Clean up
 		network.ConnectionsDelete("127.0.0.1:12345")
-		pubsub.SubscriptionsDelete("127.0.0.1:12345")
-		pubsub.SubscriptionsDelete("127.0.0.1:12346")
-		pubsub.SubscribedModeDelete("127.0.0.1:12345")
-		pubsub.SubscribedModeDelete("127.0.0.1:12346")
+		network.SubscriptionsDelete("127.0.0.1:12345")
+		network.SubscriptionsDelete("127.0.0.1:12346")
+		network.SubscribedModeDelete("127.0.0.1:12345")
+		network.SubscribedModeDelete("127.0.0.1:12346")
 	})
 
 	t.Run("publish with unicode channel and message", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SetSubscriptionsMap(make(map[string][]string))
-		pubsub.SetSubscribedModeMap(make(map[string]bool))
+		network.SubscriptionsClear()
 
 		// Create mock connection
 		conn1 := &MockConnection{
@@ -560,10 +554,10 @@ func TestPublishMessageDelivery(t *testing.T) {
 		// Subscribe to unicode channel
 		channel := "测试频道"
 		message := "测试消息"
-		Subscribe("127.0.0.1:12345", []shared.Value{{Typ: "bulk", Bulk: channel}})
+		Subscribe(network.ClientOf("127.0.0.1:12345"), []shared.Value{{Typ: "bulk", Bulk: channel}})
 
 		// Publish unicode message
-		result := Publish("127.0.0.1:12346", []shared.Value{
+		result := Publish(network.ClientOf("127.0.0.1:12346"), []shared.Value{
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "bulk", Bulk: message},
 		})
@@ -583,16 +577,15 @@ func TestPublishMessageDelivery(t *testing.T) {
 
 		// Clean up
 		network.ConnectionsDelete("127.0.0.1:12345")
-		pubsub.SubscriptionsDelete("127.0.0.1:12345")
-		pubsub.SubscribedModeDelete("127.0.0.1:12345")
+		network.SubscriptionsDelete("127.0.0.1:12345")
+		network.SubscribedModeDelete("127.0.0.1:12345")
 	})
 }
 
 func TestPublishMessageFormat(t *testing.T) {
 	t.Run("message format matches Redis protocol", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SetSubscriptionsMap(make(map[string][]string))
-		pubsub.SetSubscribedModeMap(make(map[string]bool))
+		network.SubscriptionsClear()
 
 		// Create mock connection
 		conn1 := &MockConnection{
@@ -605,10 +598,10 @@ func TestPublishMessageFormat(t *testing.T) {
 		network.ConnectionsSet("127.0.0.1:12345", conn1)
 
 		// Subscribe to channel
-		Subscribe("127.0.0.1:12345", []shared.Value{{Typ: "bulk", Bulk: "channel_1"}})
+		Subscribe(network.ClientOf("127.0.0.1:12345"), []shared.Value{{Typ: "bulk", Bulk: "channel_1"}})
 
 		// Publish message
-		Publish("127.0.0.1:12346", []shared.Value{
+		Publish(network.ClientOf("127.0.0.1:12346"), []shared.Value{
 			{Typ: "bulk", Bulk: "channel_1"},
 			{Typ: "bulk", Bulk: "hello"},
 		})
@@ -622,16 +615,15 @@ func TestPublishMessageFormat(t *testing.T) {
 
 		// Clean up
 		network.ConnectionsDelete("127.0.0.1:12345")
-		pubsub.SubscriptionsDelete("127.0.0.1:12345")
-		pubsub.SubscribedModeDelete("127.0.0.1:12345")
+		network.SubscriptionsDelete("127.0.0.1:12345")
+		network.SubscribedModeDelete("127.0.0.1:12345")
 	})
 }
 
 func TestPublishConcurrentAccess(t *testing.T) {
 	t.Run("publish handles concurrent subscriptions and publishing", func(t *testing.T) {
 		// Clean up any existing subscriptions
-		pubsub.SetSubscriptionsMap(make(map[string][]string))
-		pubsub.SetSubscribedModeMap(make(map[string]bool))
+		network.SubscriptionsClear()
 
 		// Create multiple mock connections
 		connections := make([]*MockConnection, 10)
@@ -649,7 +641,7 @@ func TestPublishConcurrentAccess(t *testing.T) {
 		done := make(chan bool, 10)
 		for i, conn := range connections {
 			go func(conn *MockConnection, i int) {
-				Subscribe(conn.remoteAddr, []shared.Value{{Typ: "bulk", Bulk: "concurrent-channel"}})
+				Subscribe(network.ClientOf(conn.remoteAddr), []shared.Value{{Typ: "bulk", Bulk: "concurrent-channel"}})
 				done <- true
 			}(conn, i)
 		}
@@ -660,7 +652,7 @@ func TestPublishConcurrentAccess(t *testing.T) {
 		}
 
 		// Publish message
-		result := Publish("127.0.0.1:12350", []shared.Value{
+		result := Publish(network.ClientOf("127.0.0.1:12350"), []shared.Value{
 			{Typ: "bulk", Bulk: "concurrent-channel"},
 			{Typ: "bulk", Bulk: "concurrent message"},
 		})
@@ -682,8 +674,8 @@ func TestPublishConcurrentAccess(t *testing.T) {
 		// Clean up
 		for _, conn := range connections {
 			network.ConnectionsDelete(conn.remoteAddr)
-			pubsub.SubscriptionsDelete(conn.remoteAddr)
-			pubsub.SubscribedModeDelete(conn.remoteAddr)
+			network.SubscriptionsDelete(conn.remoteAddr)
+			network.SubscribedModeDelete(conn.remoteAddr)
 		}
 	})
 }
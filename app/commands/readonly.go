@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Readonly handles the READONLY command
+// Usage: READONLY
+// Returns: OK, or an error when cluster mode is disabled.
+//
+// A replica redirects clients with MOVED to the master serving the slot of their keys. Once
+// a client sent READONLY, the replica serves its read commands for the slots of its master
+// instead, possibly with stale data, which lets smart clients spread reads over replicas.
+// Writes are still redirected. The flag stays until READWRITE.
+//
+// Examples:
+//
+//	READONLY    // Returns OK
+//	GET mykey   // Served by the replica instead of redirected to its master
+func Readonly(client *network.Client, args []shared.Value) shared.Value {
+	return setReadOnly(client, true)
+}
+
+// Readwrite handles the READWRITE command
+// Usage: READWRITE
+// Returns: OK, or an error when cluster mode is disabled.
+//
+// It ends READONLY, the reads of the client being redirected to masters again.
+//
+// Examples:
+//
+//	READWRITE   // Returns OK
+func Readwrite(client *network.Client, args []shared.Value) shared.Value {
+	return setReadOnly(client, false)
+}
+
+// setReadOnly sets the READONLY flag of a client for READONLY and READWRITE
+func setReadOnly(client *network.Client, readOnly bool) shared.Value {
+	if !network.ClusterEnabled() {
+		return createErrorResponse("ERR This instance has cluster support disabled")
+	}
+	client.UpdateInfo(func(info *shared.ClientInfo) {
+		info.ReadOnly = readOnly
+	})
+	return shared.Value{Typ: "string", Str: "OK"}
+}
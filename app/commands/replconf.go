@@ -1,20 +1,24 @@
 package commands
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/codecrafters-io/redis-starter-go/app/network"
-	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 // replconf handles the REPLCONF command.
-// Usage: REPLCONF listening-port <port> or REPLCONF GETACK *
+// Usage: REPLCONF listening-port <port> | REPLCONF capa <capability> [capa <capability> ...] | REPLCONF GETACK *
 // Returns: "OK" on success, error message on failure, or REPLCONF ACK <offset> for GETACK.
 //
-// This command sets the listening port for the replica or responds to GETACK requests.
-// It is also used to register the replica connection as soon as we receive REPLCONF.
-func Replconf(connID string, args []shared.Value) shared.Value {
+// This command records the listening port and capabilities advertised by the replica
+// during the handshake, or responds to GETACK requests. The replica is registered for
+// propagation by PSYNC, once it received its snapshot.
+func Replconf(client *network.Client, args []shared.Value) shared.Value {
 	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'replconf' command")
+		return shared.ErrWrongArity("replconf")
 	}
 
 	subcommand := args[0].Bulk
@@ -29,27 +33,47 @@ func Replconf(connID string, args []shared.Value) shared.Value {
 				{Typ: "bulk", Bulk: "0"},
 			}}
 		}
-		return createErrorResponse("ERR wrong number of arguments for 'replconf getack' command")
+		return shared.ErrWrongArity("replconf|getack")
 	}
 
 	// Handle REPLCONF ACK <offset> command (from replicas to master)
 	if subcommand == "ACK" {
 		if len(args) >= 2 {
-			// Mark this replica as having acknowledged
-			network.AcknowledgedReplicasSet(connID)
+			// Mark this replica as having acknowledged and remember when, for lag tracking
+			network.AcknowledgedReplicasSet(client.ConnID)
+			offset, _ := strconv.ParseInt(args[1].Bulk, 10, 64)
+			network.ReplicaInfoUpdate(client.ConnID, func(info *shared.ReplicaInfo) {
+				info.AckOffset = offset
+				info.LastAck = time.Now().UnixMilli()
+			})
 			// Return NO_RESPONSE since this is an internal command
 			return shared.Value{Typ: network.NO_RESPONSE, Str: ""}
 		}
-		return createErrorResponse("ERR wrong number of arguments for 'replconf ack' command")
+		return shared.ErrWrongArity("replconf|ack")
 	}
 
-	// Register replica connection as soon as we receive REPLCONF
-	// This ensures the replica is registered before any commands are processed
-	if conn, exists := network.ConnectionsGet(connID); exists {
-		// Only register if not already registered
-		if _, alreadyRegistered := server.StoreState.Replicas[connID]; !alreadyRegistered {
-			network.ReplicasSet(connID, conn)
+	// Record what the replica advertises so it can be reported by its real address
+	switch strings.ToLower(subcommand) {
+	case "listening-port":
+		port := args[1].Bulk
+		network.ReplicaInfoUpdate(client.ConnID, func(info *shared.ReplicaInfo) {
+			info.ListeningPort = port
+		})
+	case "capa":
+		// Capabilities come as repeated "capa <flag>" pairs
+		var capabilities []string
+		for i := 0; i+1 < len(args); i += 2 {
+			if strings.ToLower(args[i].Bulk) == "capa" {
+				capabilities = append(capabilities, strings.ToLower(args[i+1].Bulk))
+			}
 		}
+		network.ReplicaInfoUpdate(client.ConnID, func(info *shared.ReplicaInfo) {
+			for _, capability := range capabilities {
+				if !containsString(info.Capabilities, capability) {
+					info.Capabilities = append(info.Capabilities, capability)
+				}
+			}
+		})
 	}
 
 	return shared.Value{Typ: "string", Str: "OK"}
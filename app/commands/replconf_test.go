@@ -4,6 +4,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 )
@@ -15,7 +16,7 @@ func TestReplconfGetack(t *testing.T) {
 		{Typ: "bulk", Bulk: "*"},
 	}
 
-	result := Replconf("test-conn", args)
+	result := Replconf(network.ClientOf("test-conn"), args)
 
 	// Should return an array response with REPLCONF ACK 0
 	if result.Typ != "array" {
@@ -46,7 +47,7 @@ func TestReplconfGetackInvalidArgs(t *testing.T) {
 		// Missing "*" argument
 	}
 
-	result := Replconf("test-conn", args)
+	result := Replconf(network.ClientOf("test-conn"), args)
 
 	// Should return an error
 	if result.Typ != "error" {
@@ -65,7 +66,7 @@ func TestReplconfListeningPort(t *testing.T) {
 		{Typ: "bulk", Bulk: "6380"},
 	}
 
-	result := Replconf("test-conn", args)
+	result := Replconf(network.ClientOf("test-conn"), args)
 
 	// Should return OK
 	if result.Typ != "string" {
@@ -77,6 +78,36 @@ func TestReplconfListeningPort(t *testing.T) {
 	}
 }
 
+func TestReplconfStoresReplicaInfo(t *testing.T) {
+	connID := "127.0.0.1:51234"
+	defer network.ReplicaInfoDelete(connID)
+
+	Replconf(network.ClientOf(connID), []shared.Value{
+		{Typ: "bulk", Bulk: "listening-port"},
+		{Typ: "bulk", Bulk: "6380"},
+	})
+	Replconf(network.ClientOf(connID), []shared.Value{
+		{Typ: "bulk", Bulk: "capa"},
+		{Typ: "bulk", Bulk: "eof"},
+		{Typ: "bulk", Bulk: "capa"},
+		{Typ: "bulk", Bulk: "psync2"},
+	})
+
+	info, exists := network.ReplicaInfoGet(connID)
+	if !exists {
+		t.Fatal("Expected replica info to be recorded")
+	}
+	if info.IP != "127.0.0.1" {
+		t.Errorf("Expected IP '127.0.0.1', got '%s'", info.IP)
+	}
+	if info.ListeningPort != "6380" {
+		t.Errorf("Expected listening port '6380', got '%s'", info.ListeningPort)
+	}
+	if len(info.Capabilities) != 2 || info.Capabilities[0] != "eof" || info.Capabilities[1] != "psync2" {
+		t.Errorf("Expected capabilities [eof psync2], got %v", info.Capabilities)
+	}
+}
+
 func TestReplconfInsufficientArgs(t *testing.T) {
 	// Test REPLCONF with insufficient arguments
 	args := []shared.Value{
@@ -84,7 +115,7 @@ func TestReplconfInsufficientArgs(t *testing.T) {
 		// Only one argument, need at least 2
 	}
 
-	result := Replconf("test-conn", args)
+	result := Replconf(network.ClientOf("test-conn"), args)
 
 	// Should return an error
 	if result.Typ != "error" {
@@ -119,7 +150,7 @@ func BenchmarkReplconf(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Replconf("bench-conn", args)
+		Replconf(network.ClientOf("bench-conn"), args)
 	}
 }
 
@@ -140,6 +171,6 @@ func BenchmarkReplconfGetack(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Replconf("bench-conn", args)
+		Replconf(network.ClientOf("bench-conn"), args)
 	}
 }
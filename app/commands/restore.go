@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// Restore handles the RESTORE command
+// Usage: RESTORE key ttl serialized-value [REPLACE] [ABSTTL] [IDLETIME seconds] [FREQ frequency]
+// Returns: OK, or an error when the key exists without REPLACE or the payload is invalid.
+//
+// The value is a payload of DUMP, from this server or from Redis, which is how keys are
+// migrated. ttl is in milliseconds, 0 for no expiration, or the Unix time in milliseconds the
+// key expires at with ABSTTL. The access time and frequency of keys are not tracked, so
+// IDLETIME and FREQ are checked and ignored.
+//
+// Examples:
+//
+//	RESTORE mykey 0 "\x00\x03bar\x0b\x00..."                 // Creates mykey holding bar
+//	RESTORE mykey 5000 "\x00\x03bar\x0b\x00..." REPLACE      // Overwrites mykey, expiring in 5 seconds
+//	RESTORE mykey 1700000000000 "\x00\x03..." ABSTTL         // Expires at the given time
+func Restore(client *network.Client, args []shared.Value) shared.Value {
+	key := args[0].Bulk
+	ttl, err := newArgScanner(args[1:2]).Int64()
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+	if ttl < 0 {
+		return createErrorResponse("ERR Invalid TTL value, must be >= 0")
+	}
+
+	replace, absTTL := false, false
+	scanner := newArgScanner(args[3:])
+	for !scanner.Done() {
+		switch scanner.NextOption() {
+		case "REPLACE":
+			replace = true
+		case "ABSTTL":
+			absTTL = true
+		case "IDLETIME":
+			idle, err := scanner.Int64()
+			if err != nil {
+				return createErrorResponse(err.Error())
+			}
+			if idle < 0 {
+				return createErrorResponse("ERR Invalid IDLETIME value, must be >= 0")
+			}
+		case "FREQ":
+			freq, err := scanner.Int64()
+			if err != nil {
+				return createErrorResponse(err.Error())
+			}
+			if freq < 0 || freq > 255 {
+				return createErrorResponse("ERR Invalid FREQ value, must be >= 0 and <= 255")
+			}
+		default:
+			return shared.ErrSyntax()
+		}
+	}
+
+	if _, exists := server.LookupKeyRead(key); exists && !replace {
+		return createErrorResponse("BUSYKEY Target key name already exists.")
+	}
+	entry, err := storage.RestoreValue([]byte(args[2].Bulk))
+	if err != nil {
+		return createErrorResponse(err.Error())
+	}
+
+	if ttl > 0 {
+		if !absTTL {
+			ttl += time.Now().UnixMilli()
+		}
+		// A key restored already expired is only deleted, like Redis does
+		if ttl <= time.Now().UnixMilli() {
+			if server.Memory.Delete(key) {
+				server.NotifyKeyModified(0, key, "restore")
+				client.MarkDirty(1)
+			}
+			return shared.Value{Typ: "string", Str: "OK"}
+		}
+		entry.Expires = ttl
+	}
+	server.Memory.Set(key, entry)
+	server.NotifyKeyModified(0, key, "restore")
+	client.MarkDirty(1)
+	return shared.Value{Typ: "string", Str: "OK"}
+}
+
+// RewriteRestore propagates RESTORE with the absolute expiration the key got, as ABSTTL, so
+// replicas and the append only file expire it at the same instant as the master. A key
+// restored already expired is only deleted, which is propagated as a DEL.
+//
+// Examples:
+//
+//	RESTORE mykey 5000 "\x00..."   // Propagated as RESTORE mykey <master expiry> "\x00..." ABSTTL
+func RewriteRestore(args []shared.Value, result shared.Value) (string, []shared.Value, bool) {
+	if len(args) < 3 {
+		return "RESTORE", args, true
+	}
+	entry, exists := server.Memory.Get(args[0].Bulk)
+	if !exists {
+		return "DEL", args[:1], true
+	}
+	if entry.Expires <= 0 {
+		return "RESTORE", args, true
+	}
+
+	rewritten := make([]shared.Value, 0, len(args)+1)
+	rewritten = append(rewritten, args[0], shared.Value{Typ: "bulk", Bulk: strconv.FormatInt(entry.Expires, 10)}, args[2])
+	absTTL := false
+	for _, arg := range args[3:] {
+		absTTL = absTTL || isOption(arg, "ABSTTL")
+		rewritten = append(rewritten, arg)
+	}
+	if !absTTL {
+		rewritten = append(rewritten, shared.Value{Typ: "bulk", Bulk: "ABSTTL"})
+	}
+	return "RESTORE", rewritten, true
+}
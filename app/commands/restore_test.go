@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func restoreArgs(args ...string) []shared.Value {
+	values := make([]shared.Value, len(args))
+	for i, arg := range args {
+		values[i] = shared.Value{Typ: "bulk", Bulk: arg}
+	}
+	return values
+}
+
+func TestRestore(t *testing.T) {
+	defer func(checksum bool) { server.StoreState.RDBChecksum = checksum }(server.StoreState.RDBChecksum)
+	server.StoreState.RDBChecksum = true
+	clearMemory()
+	Rpush(network.ClientOf("test-conn"), restoreArgs("list", "a", "b", "c"))
+	payload := Dump(network.ClientOf("test-conn"), restoreArgs("list")).Bulk
+	future := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "new key", args: []string{"copy", "0", payload}, expected: "OK"},
+		{name: "existing key", args: []string{"copy", "0", payload}, expected: "BUSYKEY Target key name already exists."},
+		{name: "replace", args: []string{"copy", "0", payload, "REPLACE"}, expected: "OK"},
+		{name: "relative ttl", args: []string{"ttl", "60000", payload}, expected: "OK"},
+		{name: "absolute ttl", args: []string{"absttl", future, payload, "ABSTTL", "IDLETIME", "10", "FREQ", "5"}, expected: "OK"},
+		{name: "expired absolute ttl", args: []string{"gone", past, payload, "absttl"}, expected: "OK"},
+		{name: "negative ttl", args: []string{"k", "-1", payload}, expected: "ERR Invalid TTL value, must be >= 0"},
+		{name: "invalid ttl", args: []string{"k", "soon", payload}, expected: "ERR value is not an integer or out of range"},
+		{name: "invalid freq", args: []string{"k", "0", payload, "FREQ", "300"}, expected: "ERR Invalid FREQ value, must be >= 0 and <= 255"},
+		{name: "unknown option", args: []string{"k", "0", payload, "KEEPTTL"}, expected: "ERR syntax error"},
+		{name: "bad payload", args: []string{"k", "0", payload[:len(payload)-1] + "x"}, expected: "ERR DUMP payload version or checksum are wrong"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Restore(network.ClientOf("test-conn"), restoreArgs(tt.args...))
+			if result.Str != tt.expected {
+				t.Errorf("Expected %q, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	if values := getListAsArray("copy"); len(values) != 3 || values[0] != "a" || values[2] != "c" {
+		t.Errorf("Expected the restored list a b c, got %v", values)
+	}
+	if entry := getEntry("ttl"); entry.Expires < time.Now().Add(59*time.Second).UnixMilli() {
+		t.Errorf("Expected ttl to expire in a minute, got %d", entry.Expires)
+	}
+	if entry := getEntry("absttl"); strconv.FormatInt(entry.Expires, 10) != future {
+		t.Errorf("Expected absttl to expire at %s, got %d", future, entry.Expires)
+	}
+	if _, exists := server.Memory.Get("gone"); exists {
+		t.Error("Expected a key restored already expired not to be created")
+	}
+	if result := runCommand("RESTORE", Restore, "test-conn", restoreArgs("k", "0")); result.Typ != "error" {
+		t.Errorf("Expected an arity error, got %v", result)
+	}
+}
+
+func TestRewriteRestore(t *testing.T) {
+	clearMemory()
+	Rpush(network.ClientOf("test-conn"), restoreArgs("list", "a"))
+	payload := Dump(network.ClientOf("test-conn"), restoreArgs("list")).Bulk
+
+	// A relative TTL is propagated as the absolute expiration of the key
+	args := restoreArgs("ttl", "60000", payload, "REPLACE")
+	Restore(network.ClientOf("test-conn"), args)
+	expires := strconv.FormatInt(getEntry("ttl").Expires, 10)
+	command, rewritten, ok := RewriteRestore(args, shared.Value{Typ: "string", Str: "OK"})
+	expected := []string{"ttl", expires, payload, "REPLACE", "ABSTTL"}
+	if !ok || command != "RESTORE" || !equalBulks(rewritten, expected) {
+		t.Errorf("Expected RESTORE %v, got %s %v", expected, command, rewritten)
+	}
+
+	// An absolute TTL is kept as it is
+	future := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	args = restoreArgs("abs", future, payload, "ABSTTL")
+	Restore(network.ClientOf("test-conn"), args)
+	if _, rewritten, _ := RewriteRestore(args, shared.Value{}); !equalBulks(rewritten, []string{"abs", future, payload, "ABSTTL"}) {
+		t.Errorf("Expected the arguments unchanged, got %v", rewritten)
+	}
+
+	// Without a TTL nothing changes
+	args = restoreArgs("persistent", "0", payload)
+	Restore(network.ClientOf("test-conn"), args)
+	if _, rewritten, _ := RewriteRestore(args, shared.Value{}); !equalBulks(rewritten, []string{"persistent", "0", payload}) {
+		t.Errorf("Expected the arguments unchanged, got %v", rewritten)
+	}
+
+	// A key restored already expired was only deleted
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)
+	args = restoreArgs("persistent", past, payload, "ABSTTL", "REPLACE")
+	Restore(network.ClientOf("test-conn"), args)
+	if command, rewritten, _ := RewriteRestore(args, shared.Value{}); command != "DEL" || !equalBulks(rewritten, []string{"persistent"}) {
+		t.Errorf("Expected DEL persistent, got %s %v", command, rewritten)
+	}
+}
+
+// equalBulks reports whether values are the bulk strings of expected
+func equalBulks(values []shared.Value, expected []string) bool {
+	if len(values) != len(expected) {
+		return false
+	}
+	for i, v := range values {
+		if v.Bulk != expected[i] {
+			return false
+		}
+	}
+	return true
+}
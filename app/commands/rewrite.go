@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// RewriteSet rewrites SET ... PX into SET ... PXAT for propagation.
+// The absolute expiration is read back from memory so replicas expire the key
+// at exactly the same instant as the master, regardless of replication delay.
+//
+// Examples:
+//
+//	SET mykey "Hello" PX 1000   // Propagated as SET mykey "Hello" PXAT <master expiry>
+func RewriteSet(args []shared.Value, result shared.Value) (string, []shared.Value, bool) {
+	if len(args) < 2 {
+		return "SET", args, true
+	}
+
+	// Options follow the key and the value, which may be spelled like one
+	rewritten := make([]shared.Value, 0, len(args))
+	rewritten = append(rewritten, args[:2]...)
+	for i := 2; i < len(args); i++ {
+		if isOption(args[i], "PX") && i+1 < len(args) {
+			entry, exists := server.Memory.Get(args[0].Bulk)
+			if exists && entry.Expires > 0 {
+				rewritten = append(rewritten,
+					shared.Value{Typ: "bulk", Bulk: "PXAT"},
+					shared.Value{Typ: "bulk", Bulk: strconv.FormatInt(entry.Expires, 10)},
+				)
+				i++ // Skip the relative expiration we just replaced
+				continue
+			}
+		}
+		rewritten = append(rewritten, args[i])
+	}
+
+	return "SET", rewritten, true
+}
+
+// RewriteXadd replaces an auto-generated stream ID with the ID the master assigned.
+// Without this, replicas would generate their own timestamps for "*" and "<ms>-*" IDs.
+//
+// Examples:
+//
+//	XADD mystream * field value   // Propagated as XADD mystream 1526919030474-0 field value
+func RewriteXadd(args []shared.Value, result shared.Value) (string, []shared.Value, bool) {
+	if len(args) < 2 || result.Typ != "bulk" {
+		return "XADD", args, true
+	}
+
+	rewritten := make([]shared.Value, len(args))
+	copy(rewritten, args)
+	rewritten[1] = shared.Value{Typ: "bulk", Bulk: result.Bulk}
+
+	return "XADD", rewritten, true
+}
+
+// RewriteBlpop propagates a successful BLPOP as a plain LPOP on the key that was served.
+// A BLPOP that timed out didn't change the dataset and is not propagated at all,
+// which also keeps replicas from blocking their replication stream.
+//
+// Examples:
+//
+//	BLPOP list1 list2 0   // Propagated as LPOP list2 when list2 was served
+func RewriteBlpop(args []shared.Value, result shared.Value) (string, []shared.Value, bool) {
+	if result.Typ != "array" || len(result.Array) != 2 {
+		return "", nil, false
+	}
+
+	return "LPOP", []shared.Value{{Typ: "bulk", Bulk: result.Array[0].Str}}, true
+}
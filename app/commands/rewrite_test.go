@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestRewriteSet(t *testing.T) {
+	clearMemory()
+
+	args := []shared.Value{
+		{Typ: "bulk", Bulk: "mykey"},
+		{Typ: "bulk", Bulk: "Hello"},
+		{Typ: "bulk", Bulk: "PX"},
+		{Typ: "bulk", Bulk: "1000"},
+	}
+	result := Set(network.ClientOf("test-conn"), args)
+
+	command, rewritten, ok := RewriteSet(args, result)
+	if !ok {
+		t.Fatal("Expected SET to be propagated")
+	}
+	if command != "SET" {
+		t.Errorf("Expected command SET, got %s", command)
+	}
+	if len(rewritten) != 4 {
+		t.Fatalf("Expected 4 arguments, got %d", len(rewritten))
+	}
+	if rewritten[2].Bulk != "PXAT" {
+		t.Errorf("Expected PXAT option, got %s", rewritten[2].Bulk)
+	}
+	expected := strconv.FormatInt(getEntry("mykey").Expires, 10)
+	if rewritten[3].Bulk != expected {
+		t.Errorf("Expected absolute expiration %s, got %s", expected, rewritten[3].Bulk)
+	}
+	if args[2].Bulk != "PX" {
+		t.Error("Original arguments should not be modified")
+	}
+}
+
+func TestRewriteSetWithoutExpiration(t *testing.T) {
+	clearMemory()
+
+	args := []shared.Value{
+		{Typ: "bulk", Bulk: "mykey"},
+		{Typ: "bulk", Bulk: "Hello"},
+	}
+	result := Set(network.ClientOf("test-conn"), args)
+
+	command, rewritten, ok := RewriteSet(args, result)
+	if !ok || command != "SET" {
+		t.Fatalf("Expected SET to be propagated, got %s (%v)", command, ok)
+	}
+	if len(rewritten) != 2 || rewritten[1].Bulk != "Hello" {
+		t.Errorf("Expected arguments to be unchanged, got %v", rewritten)
+	}
+}
+
+func TestRewriteSetKeyAndValueSpelledLikeOptions(t *testing.T) {
+	clearMemory()
+
+	args := []shared.Value{
+		{Typ: "bulk", Bulk: "px"},
+		{Typ: "bulk", Bulk: "px"},
+		{Typ: "bulk", Bulk: "PX"},
+		{Typ: "bulk", Bulk: "1000"},
+	}
+	result := Set(network.ClientOf("test-conn"), args)
+
+	_, rewritten, _ := RewriteSet(args, result)
+	expected := []string{"px", "px", "PXAT", strconv.FormatInt(getEntry("px").Expires, 10)}
+	if len(rewritten) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, rewritten)
+	}
+	for i, arg := range expected {
+		if rewritten[i].Bulk != arg {
+			t.Errorf("Expected %v, got %v", expected, rewritten)
+			break
+		}
+	}
+}
+
+func TestRewriteXadd(t *testing.T) {
+	clearMemory()
+
+	args := []shared.Value{
+		{Typ: "bulk", Bulk: "mystream"},
+		{Typ: "bulk", Bulk: "*"},
+		{Typ: "bulk", Bulk: "field"},
+		{Typ: "bulk", Bulk: "value"},
+	}
+	result := Xadd(network.ClientOf("test-conn"), args)
+
+	command, rewritten, ok := RewriteXadd(args, result)
+	if !ok || command != "XADD" {
+		t.Fatalf("Expected XADD to be propagated, got %s (%v)", command, ok)
+	}
+	if rewritten[1].Bulk != result.Bulk {
+		t.Errorf("Expected ID %s, got %s", result.Bulk, rewritten[1].Bulk)
+	}
+	if args[1].Bulk != "*" {
+		t.Error("Original arguments should not be modified")
+	}
+}
+
+func TestRewriteBlpop(t *testing.T) {
+	tests := []struct {
+		name            string
+		result          shared.Value
+		expectPropagate bool
+		expectedKey     string
+	}{
+		{
+			name: "served BLPOP becomes LPOP",
+			result: shared.Value{Typ: "array", Array: []shared.Value{
+				{Typ: "string", Str: "list2"},
+				{Typ: "string", Str: "item"},
+			}},
+			expectPropagate: true,
+			expectedKey:     "list2",
+		},
+		{
+			name:            "timed out BLPOP is not propagated",
+			result:          shared.NullArray(),
+			expectPropagate: false,
+		},
+	}
+
+	args := []shared.Value{
+		{Typ: "bulk", Bulk: "list1"},
+		{Typ: "bulk", Bulk: "list2"},
+		{Typ: "bulk", Bulk: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, rewritten, ok := RewriteBlpop(args, tt.result)
+			if ok != tt.expectPropagate {
+				t.Fatalf("Expected propagate %v, got %v", tt.expectPropagate, ok)
+			}
+			if !ok {
+				return
+			}
+			if command != "LPOP" {
+				t.Errorf("Expected command LPOP, got %s", command)
+			}
+			if len(rewritten) != 1 || rewritten[0].Bulk != tt.expectedKey {
+				t.Errorf("Expected LPOP %s, got %v", tt.expectedKey, rewritten)
+			}
+		})
+	}
+}
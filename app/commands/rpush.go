@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 // rpush handles the RPUSH command.
@@ -18,34 +20,24 @@ import (
 //	RPUSH mylist "one"                    // Creates list with one element, returns 1
 //	RPUSH mylist "two" "three"            // Adds two elements, returns 3
 //	RPUSH newlist "first" "second"        // Creates new list, returns 2
-func Rpush(connID string, args []shared.Value) shared.Value {
-	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'rpush' command")
-	}
+func Rpush(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
-
-	// If key doesn't exist, create a new linked list
-	if !exists {
-		entry = shared.MemoryEntry{List: shared.NewLinkedList(), Expires: 0}
-	} else if entry.List == nil {
-		// If we have an array but no list, convert array to linked list
-		if len(entry.Array) > 0 {
-			entry.List = shared.FromArray(entry.Array)
-			entry.Array = nil // Clear the array to save memory
-		} else {
-			// Create new linked list for empty array
-			entry.List = shared.NewLinkedList()
-		}
-		// Clear the string value when converting to list
-		entry.Value = ""
+	var size int
+	values := make([]string, len(args)-1)
+	for i := range values {
+		values[i] = args[i+1].Bulk
 	}
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		storage.CopyOnWrite(key)
+		// A missing key becomes a new list, a string is replaced by one
+		entry.Value = ""
 
-	// RPUSH: O(1) insertion at tail using linked list
-	for i := 1; i < len(args); i++ {
-		entry.List.AddToTail(args[i].Bulk)
-	}
+		// Small lists stay packed in Array, bigger ones are linked lists with O(1) pushes
+		size = entry.PushList(values, false)
+		return entry, true
+	})
 
-	server.Memory[key] = entry
-	return shared.Value{Typ: "integer", Num: entry.List.Size}
+	server.NotifyKeyModified(0, key, "rpush")
+	client.MarkDirty(len(args) - 1)
+	return shared.Value{Typ: "integer", Num: size}
 }
@@ -3,8 +3,9 @@ package commands
 import (
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestRpush(t *testing.T) {
@@ -29,7 +30,7 @@ func TestRpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["newlist"]
+				_, exists := server.Memory.Get("newlist")
 				if !exists {
 					t.Error("List should exist after RPUSH")
 				}
@@ -54,7 +55,7 @@ func TestRpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 3},
 			verify: func() {
-				_, exists := server.Memory["newlist"]
+				_, exists := server.Memory.Get("newlist")
 				if !exists {
 					t.Error("List should exist after RPUSH")
 				}
@@ -79,15 +80,15 @@ func TestRpush(t *testing.T) {
 				{Typ: "bulk", Bulk: "newlast"},
 			},
 			setup: func() {
-				server.Memory["existinglist"] = shared.MemoryEntry{
+				server.Memory.Set("existinglist", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{"old1", "old2"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 3},
 			verify: func() {
-				_, exists := server.Memory["existinglist"]
+				_, exists := server.Memory.Get("existinglist")
 				if !exists {
 					t.Error("List should exist after RPUSH")
 				}
@@ -111,15 +112,15 @@ func TestRpush(t *testing.T) {
 				{Typ: "bulk", Bulk: "first"},
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["emptylist"]
+				_, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should exist after RPUSH")
 				}
@@ -140,11 +141,11 @@ func TestRpush(t *testing.T) {
 				{Typ: "bulk", Bulk: "first"},
 			},
 			setup: func() {
-				server.Memory["stringkey"] = shared.MemoryEntry{Value: "old string", Expires: 0}
+				server.Memory.Set("stringkey", shared.MemoryEntry{Value: "old string", Expires: 0})
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["stringkey"]
+				_, exists := server.Memory.Get("stringkey")
 				if !exists {
 					t.Error("Key should exist after RPUSH")
 				}
@@ -156,7 +157,7 @@ func TestRpush(t *testing.T) {
 					t.Errorf("Expected 'first', got '%s'", array[0])
 				}
 				// Original string value should be cleared
-				entry := server.Memory["stringkey"]
+				entry := getEntry("stringkey")
 				if entry.Value != "" {
 					t.Errorf("Expected empty string value, got '%s'", entry.Value)
 				}
@@ -172,7 +173,7 @@ func TestRpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				_, exists := server.Memory["emptylist"]
+				_, exists := server.Memory.Get("emptylist")
 				if !exists {
 					t.Error("List should exist after RPUSH")
 				}
@@ -196,7 +197,7 @@ func TestRpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 2},
 			verify: func() {
-				_, exists := server.Memory["unicodelist"]
+				_, exists := server.Memory.Get("unicodelist")
 				if !exists {
 					t.Error("List should exist after RPUSH")
 				}
@@ -236,7 +237,7 @@ func TestRpush(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "integer", Num: 5},
 			verify: func() {
-				_, exists := server.Memory["largelist"]
+				_, exists := server.Memory.Get("largelist")
 				if !exists {
 					t.Error("List should exist after RPUSH")
 				}
@@ -260,7 +261,7 @@ func TestRpush(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Rpush(tt.connID, tt.args)
+			result := runCommand("RPUSH", Rpush, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Rpush() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -290,7 +291,7 @@ func BenchmarkRpush(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Rpush(connID, args)
+		Rpush(network.ClientOf(connID), args)
 	}
 }
 
@@ -307,17 +308,17 @@ func BenchmarkRpushMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Rpush(connID, args)
+		Rpush(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkRpushToExisting(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Value:   "",
 		Array:   []string{"existing1", "existing2"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -327,7 +328,7 @@ func BenchmarkRpushToExisting(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Rpush(connID, args)
+		Rpush(network.ClientOf(connID), args)
 	}
 }
 
@@ -342,6 +343,6 @@ func BenchmarkRpushEmpty(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Rpush(connID, args)
+		Rpush(network.ClientOf(connID), args)
 	}
 }
@@ -0,0 +1,18 @@
+package commands
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// save handles the SAVE command.
+// Usage: SAVE
+// Returns: "OK" once the dataset has been written to the RDB file, error message on failure.
+// This command blocks the server while the file is written; BGSAVE is usually preferred.
+func Save(client *network.Client, args []shared.Value) shared.Value {
+	if err := storage.Save(); err != nil {
+		return createErrorResponse(err.Error())
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []shared.Value
+		expectError bool
+	}{
+		{
+			name:        "SAVE writes the RDB file",
+			args:        []shared.Value{},
+			expectError: false,
+		},
+		{
+			name:        "SAVE with arguments",
+			args:        []shared.Value{{Typ: "bulk", Bulk: "extra"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			server.SetStoreState(shared.State{
+				Role:             "master",
+				Replicas:         make(map[string]net.Conn),
+				ConfigDir:        dir,
+				ConfigDbfilename: "dump.rdb",
+			})
+			clearMemory()
+			server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+			server.Memory.Set("list", shared.MemoryEntry{List: shared.FromArray([]string{"a", "b"})})
+
+			result := runCommand("SAVE", Save, "test-conn", tt.args)
+
+			if tt.expectError {
+				if result.Typ != "error" {
+					t.Errorf("Expected error, got %v", result)
+				}
+				return
+			}
+			if result.Typ != "string" || result.Str != "OK" {
+				t.Fatalf("Expected OK, got %v", result)
+			}
+
+			// The file loads back into the same dataset
+			clearMemory()
+			if err := storage.LoadRDBFile(dir, "dump.rdb"); err != nil {
+				t.Fatalf("Failed to load saved RDB file: %v", err)
+			}
+			if getEntry("key").Value != "value" {
+				t.Errorf("Expected key to be restored, got %v", getEntry("key"))
+			}
+			if list := getListAsArray("list"); len(list) != 2 {
+				t.Errorf("Expected list to be restored, got %v", getEntry("list"))
+			}
+		})
+	}
+}
+
+func TestSaveToUnwritableDir(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        filepath.Join(dir, "missing", "\x00"),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+
+	result := Save(network.ClientOf("test-conn"), []shared.Value{})
+	if result.Typ != "error" {
+		t.Errorf("Expected error, got %v", result)
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        b.TempDir(),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Save(network.ClientOf("test-conn"), []shared.Value{})
+	}
+}
+
+func TestSaveFailureKeepsPreviousFile(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        dir,
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "saved"})
+	if result := Save(network.ClientOf("test-conn"), []shared.Value{}); result.Str != "OK" {
+		t.Fatalf("Expected OK, got %v", result)
+	}
+
+	// A directory in the way of the RDB file makes the rename fail
+	server.StoreState.ConfigDbfilename = "blocked"
+	if err := os.MkdirAll(filepath.Join(dir, "blocked", "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	server.Memory.Set("key", shared.MemoryEntry{Value: "unsaved"})
+	if result := Save(network.ClientOf("test-conn"), []shared.Value{}); result.Typ != "error" {
+		t.Errorf("Expected the save to fail, got %v", result)
+	}
+
+	if temps, _ := filepath.Glob(filepath.Join(dir, "temp-*")); len(temps) != 0 {
+		t.Errorf("Expected the temporary files to be removed, got %v", temps)
+	}
+	clearMemory()
+	if err := storage.LoadRDBFile(dir, "dump.rdb"); err != nil || getEntry("key").Value != "saved" {
+		t.Errorf("Expected the previous file to load, got %v, %v", getEntry("key"), err)
+	}
+}
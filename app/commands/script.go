@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Script handles the SCRIPT command
+// Usage: SCRIPT LOAD script | SCRIPT EXISTS sha1 [sha1 ...] | SCRIPT FLUSH [ASYNC|SYNC] | SCRIPT KILL
+// Returns: The digest of the loaded script, whether each digest is loaded, or OK.
+//
+// SCRIPT KILL stops the script in progress, which replies an error to its client. A script
+// that already wrote can't be stopped: it would leave its writes half done.
+//
+// Examples:
+//
+//	SCRIPT LOAD "return 1"                                // Returns e0e1f9fabfc9d4800c877a703b823ac0578ff8db
+//	SCRIPT EXISTS e0e1f9fabfc9d4800c877a703b823ac0578ff8db  // Returns 1
+//	SCRIPT FLUSH                                          // Empties the script cache
+//	SCRIPT KILL                                           // Stops the script in progress
+func Script(client *network.Client, args []shared.Value) shared.Value {
+	switch strings.ToUpper(args[0].Bulk) {
+	case "LOAD":
+		sha, _, err := loadScript(args[1].Bulk)
+		if err != nil {
+			return createErrorResponse(err.Error())
+		}
+		return shared.Value{Typ: "bulk", Bulk: sha}
+	case "EXISTS":
+		result := make([]shared.Value, len(args)-1)
+		for i, arg := range args[1:] {
+			result[i] = shared.Value{Typ: "integer", Num: 0}
+			if _, ok := lookupScript(arg.Bulk); ok {
+				result[i].Num = 1
+			}
+		}
+		return shared.Value{Typ: "array", Array: result}
+	case "FLUSH":
+		if len(args) > 2 {
+			return shared.ErrWrongArity("script|flush")
+		}
+		if len(args) == 2 {
+			if mode := strings.ToUpper(args[1].Bulk); mode != "ASYNC" && mode != "SYNC" {
+				return createErrorResponse("ERR SCRIPT FLUSH only support SYNC|ASYNC option")
+			}
+		}
+		flushScripts()
+		return shared.Value{Typ: "string", Str: "OK"}
+	case "KILL":
+		return killScript(false)
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'script' command")
+	}
+}
+
+// killScript stops the script in progress for SCRIPT KILL, or the function for FUNCTION KILL
+func killScript(function bool) shared.Value {
+	if err := network.KillScript(function); err != "" {
+		return createErrorResponse(err)
+	}
+	return shared.Value{Typ: "string", Str: "OK"}
+}
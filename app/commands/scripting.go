@@ -0,0 +1,374 @@
+package commands
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/lua"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+var scriptLog = logger.New("scripting")
+
+// scriptChunk is the name of EVAL scripts in their error messages
+const scriptChunk = "user_script"
+
+var (
+	scriptsMu sync.RWMutex
+	scripts   = make(map[string]*lua.Function) // Scripts loaded by EVAL and SCRIPT LOAD, by SHA1 digest
+)
+
+// scriptSHA returns the SHA1 digest of a script in hexadecimal, which EVALSHA runs it by
+func scriptSHA(body string) string {
+	sum := sha1.Sum([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadScript compiles a script and adds it to the script cache, returning its digest
+func loadScript(body string) (string, *lua.Function, error) {
+	sha := scriptSHA(body)
+	if fn, ok := lookupScript(sha); ok {
+		return sha, fn, nil
+	}
+	fn, err := lua.Compile(scriptChunk, body)
+	if err != nil {
+		return "", nil, errors.New("ERR Error compiling script (new function): " + err.Error())
+	}
+	scriptsMu.Lock()
+	scripts[sha] = fn
+	scriptsMu.Unlock()
+	return sha, fn, nil
+}
+
+// lookupScript returns the script of a digest from the script cache
+func lookupScript(sha string) (*lua.Function, bool) {
+	scriptsMu.RLock()
+	defer scriptsMu.RUnlock()
+	fn, ok := scripts[strings.ToLower(sha)]
+	return fn, ok
+}
+
+// flushScripts empties the script cache
+func flushScripts() {
+	scriptsMu.Lock()
+	scripts = make(map[string]*lua.Function)
+	scriptsMu.Unlock()
+}
+
+// parseScriptKeys splits the arguments following a script, numkeys key [key ...] arg [arg ...],
+// in keys and arguments
+func parseScriptKeys(args []shared.Value) ([]shared.Value, []shared.Value, shared.Value, bool) {
+	numKeys, err := strconv.Atoi(args[0].Bulk)
+	if err != nil {
+		return nil, nil, shared.ErrNotInteger(), false
+	}
+	if numKeys < 0 {
+		return nil, nil, createErrorResponse("ERR Number of keys can't be negative"), false
+	}
+	if numKeys > len(args)-1 {
+		return nil, nil, createErrorResponse("ERR Number of keys can't be greater than number of args"), false
+	}
+	return args[1 : 1+numKeys], args[1+numKeys:], shared.Value{}, true
+}
+
+// runScript runs a script, or a function when function is set, for a client and returns its
+// reply. name identifies it in error messages, prepare sets up its globals and returns its
+// arguments, and noWrites refuses the write commands it calls. The writes of the script are
+// propagated as a MULTI/EXEC block.
+func runScript(client *network.Client, name string, fn *lua.Function, function bool, noWrites bool, prepare func(s *lua.State) []lua.Value) shared.Value {
+	recorder := startRecording(client)
+	defer recorder.finish()
+	script := network.StartScript(function)
+	defer script.Done()
+
+	s := newScriptState(recorder, script, noWrites)
+	args := prepare(s)
+	s.StrictGlobals = true
+	s.Interrupt = script.Killed
+
+	rets, err := s.Call(fn, args...)
+	if err != nil {
+		return scriptErrorReply(err, name)
+	}
+	if len(rets) == 0 {
+		return shared.Value{Typ: "null"}
+	}
+	return luaToReply(rets[0])
+}
+
+// stringsTable returns a Lua array of the strings of values
+func stringsTable(values []shared.Value) *lua.Table {
+	elements := make([]lua.Value, len(values))
+	for i, v := range values {
+		elements[i] = v.Bulk
+	}
+	return lua.NewArray(elements)
+}
+
+// newScriptState returns an interpreter with the redis library, whose calls run commands for
+// the client of the recorder on behalf of script
+func newScriptState(recorder *effectRecorder, script *network.RunningScript, noWrites bool) *lua.State {
+	s := lua.NewState()
+	lib := lua.NewTable()
+	lib.Set("call", lua.NewFunction("call", func(s *lua.State, args []lua.Value) ([]lua.Value, error) {
+		return redisCall(s, recorder, script, args, true, noWrites)
+	}))
+	lib.Set("pcall", lua.NewFunction("pcall", func(s *lua.State, args []lua.Value) ([]lua.Value, error) {
+		return redisCall(s, recorder, script, args, false, noWrites)
+	}))
+	lib.Set("status_reply", lua.NewFunction("status_reply", func(s *lua.State, args []lua.Value) ([]lua.Value, error) {
+		return replyTable(args, "ok")
+	}))
+	lib.Set("error_reply", lua.NewFunction("error_reply", func(s *lua.State, args []lua.Value) ([]lua.Value, error) {
+		return replyTable(args, "err")
+	}))
+	lib.Set("sha1hex", lua.NewFunction("sha1hex", func(s *lua.State, args []lua.Value) ([]lua.Value, error) {
+		if len(args) != 1 {
+			return nil, errors.New("wrong number of arguments")
+		}
+		str, _ := lua.ToStringValue(args[0])
+		return []lua.Value{scriptSHA(str)}, nil
+	}))
+	setLogFunctions(lib)
+	lib.SetReadOnly()
+	s.Globals.Set("redis", lib)
+	return s
+}
+
+// setLogFunctions adds redis.log with its levels and the server version to a redis library
+func setLogFunctions(lib *lua.Table) {
+	lib.Set("log", lua.NewFunction("log", redisLog))
+	lib.Set("LOG_DEBUG", float64(0))
+	lib.Set("LOG_VERBOSE", float64(1))
+	lib.Set("LOG_NOTICE", float64(2))
+	lib.Set("LOG_WARNING", float64(3))
+	lib.Set("REDIS_VERSION", RedisVersion)
+}
+
+// redisCall runs a command from a script. A failing command raises its error when raise is
+// set, as redis.call does, or returns it as an error table, as redis.pcall does. Write commands
+// fail when noWrites is set. A script that ran a write can't be killed anymore.
+func redisCall(s *lua.State, recorder *effectRecorder, script *network.RunningScript, args []lua.Value, raise bool, noWrites bool) ([]lua.Value, error) {
+	fail := func(msg string) ([]lua.Value, error) {
+		t := errorTable(msg, s.Where(1))
+		if raise {
+			return nil, &lua.Error{Value: t}
+		}
+		return []lua.Value{t}, nil
+	}
+
+	if len(args) == 0 {
+		return fail("ERR Please specify at least one argument for this redis lib call")
+	}
+	cmdArgs := make([]shared.Value, len(args))
+	for i, arg := range args {
+		var str string
+		switch arg := arg.(type) {
+		case string:
+			str = arg
+		case float64:
+			// Numbers are passed as integers, like Redis does
+			str = strconv.FormatInt(int64(arg), 10)
+		default:
+			return fail("ERR Lua redis lib command arguments must be strings or integers")
+		}
+		cmdArgs[i] = shared.Value{Typ: "bulk", Bulk: str}
+	}
+
+	command := strings.ToUpper(cmdArgs[0].Bulk)
+	spec, ok := network.LookupCommand(command)
+	if _, exists := network.CommandHandlers[command]; !ok || !exists {
+		return fail("ERR Unknown Redis command called from script")
+	}
+	// Commands that could wait would stall every other client
+	if spec.HasFlag(network.CommandFlagNoscript) || spec.HasFlag(network.CommandFlagBlocking) {
+		return fail("ERR This Redis command is not allowed from script")
+	}
+	if noWrites && spec.HasFlag(network.CommandFlagWrite) {
+		return fail("ERR Write commands are not allowed from read-only scripts.")
+	}
+
+	if spec.HasFlag(network.CommandFlagWrite) {
+		script.Wrote()
+	}
+	result := recorder.run(command, cmdArgs[1:])
+	if result.Typ == "error" {
+		return fail(result.Str)
+	}
+	return []lua.Value{replyToLua(result)}, nil
+}
+
+// errorTable returns the table of a command error in a script, with the position it was
+// raised at as Redis does
+func errorTable(msg string, where string) *lua.Table {
+	t := lua.NewTable()
+	t.Set("err", msg)
+	if source, line, ok := strings.Cut(strings.TrimSuffix(where, ":"), ":"); ok {
+		t.Set("source", source)
+		if n, err := strconv.Atoi(line); err == nil {
+			t.Set("line", float64(n))
+		}
+	}
+	return t
+}
+
+// replyTable implements redis.status_reply and redis.error_reply, returning a table with the
+// message in its field
+func replyTable(args []lua.Value, field string) ([]lua.Value, error) {
+	if len(args) != 1 {
+		return nil, errors.New("wrong number or type of arguments")
+	}
+	str, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("wrong number or type of arguments")
+	}
+	t := lua.NewTable()
+	t.Set(field, str)
+	return []lua.Value{t}, nil
+}
+
+// redisLog implements redis.log(level, message, ...)
+func redisLog(s *lua.State, args []lua.Value) ([]lua.Value, error) {
+	if len(args) < 2 {
+		return nil, errors.New("redis.log() requires two arguments or more.")
+	}
+	level, ok := args[0].(float64)
+	if !ok || level < 0 || level > 3 {
+		return nil, errors.New("Invalid debug level.")
+	}
+	parts := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		str, ok := lua.ToStringValue(arg)
+		if !ok {
+			continue
+		}
+		parts = append(parts, str)
+	}
+	msg := strings.Join(parts, " ")
+	switch int(level) {
+	case 0:
+		scriptLog.Debugf("%s", msg)
+	case 1:
+		scriptLog.Verbosef("%s", msg)
+	case 2:
+		scriptLog.Noticef("%s", msg)
+	default:
+		scriptLog.Warningf("%s", msg)
+	}
+	return nil, nil
+}
+
+// replyToLua converts the reply of a command to a Lua value: integers are numbers, strings
+// are strings, nulls are false, arrays are tables, and errors are tables with an err field.
+// Simple strings, which this server also replies values with, are strings too.
+func replyToLua(v shared.Value) lua.Value {
+	switch v.Typ {
+	case "integer":
+		return float64(v.Num)
+	case "bulk", "verbatim":
+		return v.Bulk
+	case "string", "big_number":
+		return v.Str
+	case "double":
+		if v.Str != "" {
+			return v.Str
+		}
+		return strconv.FormatFloat(v.Double, 'g', 17, 64)
+	case "boolean":
+		if v.Bool {
+			return float64(1)
+		}
+		return float64(0)
+	case "array", "map", "set", "push":
+		elements := make([]lua.Value, len(v.Array))
+		for i, element := range v.Array {
+			elements[i] = replyToLua(element)
+		}
+		return lua.NewArray(elements)
+	case "error":
+		t := lua.NewTable()
+		t.Set("err", v.Str)
+		return t
+	}
+	return false
+}
+
+// luaToReply converts the value a script returns to a reply: numbers are integers, strings
+// are bulk strings, true is 1, nil and false are nulls, tables with an ok or err field are
+// status or error replies, and other tables are arrays up to their first nil.
+func luaToReply(v lua.Value) shared.Value {
+	switch v := v.(type) {
+	case float64:
+		return shared.Value{Typ: "integer", Num: int(v)}
+	case string:
+		return shared.Value{Typ: "bulk", Bulk: v}
+	case bool:
+		if v {
+			return shared.Value{Typ: "integer", Num: 1}
+		}
+	case *lua.Table:
+		if msg, ok := v.Get("err").(string); ok {
+			return createErrorResponse(msg)
+		}
+		if status, ok := v.Get("ok").(string); ok {
+			return shared.Value{Typ: "string", Str: status}
+		}
+		elements := []shared.Value{}
+		for i := 1; ; i++ {
+			element := v.Get(float64(i))
+			if element == nil {
+				break
+			}
+			elements = append(elements, luaToReply(element))
+		}
+		return shared.Value{Typ: "array", Array: elements}
+	}
+	return shared.Value{Typ: "null"}
+}
+
+// scriptPosition matches the position a Lua error message starts with, like user_script:3:
+var scriptPosition = regexp.MustCompile(`^(\w+):(\d+): `)
+
+// scriptErrorReply returns the reply of a script that raised an error, ending with the
+// digest of the script, or the name of the function, and the position of the error
+func scriptErrorReply(err error, name string) shared.Value {
+	var msg, where string
+	var interrupt *lua.InterruptError
+	if errors.As(err, &interrupt) {
+		// Killed with SCRIPT KILL or FUNCTION KILL
+		return createErrorResponse(interrupt.Err.Error())
+	}
+	var luaErr *lua.Error
+	if !errors.As(err, &luaErr) {
+		return createErrorResponse("ERR " + err.Error())
+	}
+	switch v := luaErr.Value.(type) {
+	case *lua.Table:
+		// An error of a command, or a table made by redis.error_reply
+		msg, _ = v.Get("err").(string)
+		if msg == "" {
+			msg = "ERR unknown error"
+		}
+		source, _ := v.Get("source").(string)
+		if line, ok := v.Get("line").(float64); ok && source != "" {
+			where = source + ":" + lua.FormatNumber(line)
+		}
+	default:
+		msg = "ERR " + luaErr.Error()
+		if m := scriptPosition.FindStringSubmatch(msg[len("ERR "):]); m != nil {
+			where = m[1] + ":" + m[2]
+		}
+	}
+	msg += " script: " + name
+	if where != "" {
+		msg += ", on @" + where + "."
+	}
+	return createErrorResponse(msg)
+}
@@ -1,46 +1,91 @@
 package commands
 
 import (
-	"strconv"
-	"strings"
 	"time"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 // set handles the SET command.
-// Usage: SET key value [PX milliseconds]
-// Returns: "OK" on success, error message on failure.
+// Usage: SET key value [PX milliseconds | PXAT unix-time-milliseconds] [GET]
+// Returns: "OK" on success, the previous value with GET, error message on failure.
 //
 // This command sets a key to hold a string value. If the key already exists,
-// it is overwritten. The PX option sets an expiration time in milliseconds.
+// it is overwritten. The PX option sets an expiration time in milliseconds,
+// PXAT sets an absolute Unix expiration time in milliseconds. GET replies the
+// string the key held before, or null, and leaves a key of another type unchanged.
 //
 // Examples:
 //
 //	SET mykey "Hello"           // Sets key without expiration
 //	SET mykey "Hello" PX 1000   // Sets key with 1 second expiration
-func Set(connID string, args []shared.Value) shared.Value {
-	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'set' command")
-	}
-
+//	SET mykey "Hello" PXAT 1700000000000 // Sets key expiring at the given time
+//	SET mykey "Hello" px 1000   // Options are matched in any case
+//	SET mykey "World" GET       // Returns "Hello"
+func Set(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
 	value := args[1].Bulk
 	entry := shared.MemoryEntry{Value: value, Expires: 0}
+	get := false
 
-	// Parse optional PX (expiration) argument
-	for i := 2; i < len(args); i++ {
-		if strings.ToUpper(args[i].Bulk) == "PX" && i+1 < len(args) {
-			ms, err := strconv.ParseInt(args[i+1].Bulk, 10, 64)
+	// Parse optional PX / PXAT (expiration) and GET arguments
+	scanner := newArgScanner(args[2:])
+	for !scanner.Done() {
+		switch option := scanner.NextOption(); option {
+		case "PX", "PXAT":
+			ms, err := scanner.Int64()
 			if err != nil {
-				return createErrorResponse("ERR value is not an integer or out of range")
+				return createErrorResponse(err.Error())
 			}
-			entry.Expires = time.Now().UnixMilli() + ms
-			i++ // Skip the next argument since we've processed it
+			if option == "PX" {
+				entry.Expires = time.Now().UnixMilli() + ms
+			} else {
+				entry.Expires = ms
+			}
+		case "GET":
+			get = true
+		default:
+			return shared.ErrSyntax()
 		}
 	}
 
-	server.Memory[key] = entry
+	if get {
+		return setAndGet(client, key, entry, "set")
+	}
+	server.Memory.Set(key, entry)
+	server.NotifyKeyModified(0, key, "set")
+	client.MarkDirty(1)
 	return shared.Value{Typ: "string", Str: "OK"}
 }
+
+// setAndGet stores entry under key and replies the string it replaced, or null. The previous
+// value is read and replaced under the lock of the key, so no write comes in between, and a
+// key holding another type is left unchanged.
+func setAndGet(client *network.Client, key string, entry shared.MemoryEntry, event string) shared.Value {
+	reply := shared.Null()
+	wrongType := false
+	server.Memory.Update(key, func(previous shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		if exists && (previous.Expires == 0 || time.Now().UnixMilli() <= previous.Expires) {
+			if !isStringEntry(previous) {
+				wrongType = true
+				return previous, false
+			}
+			reply = shared.Value{Typ: "string", Str: previous.Value}
+		}
+		return entry, true
+	})
+	if wrongType {
+		return shared.ErrWrongType()
+	}
+	server.NotifyKeyModified(0, key, event)
+	client.MarkDirty(1)
+	return reply
+}
+
+// isStringEntry reports whether entry holds a string rather than a list, set, hash, sorted set
+// or stream
+func isStringEntry(entry shared.MemoryEntry) bool {
+	return entry.Array == nil && entry.List == nil && entry.Stream == nil && entry.SortedSet == nil && entry.Set == nil && entry.Hash == nil
+}
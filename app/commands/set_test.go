@@ -4,8 +4,9 @@ import (
 	"testing"
 	"time"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestSet(t *testing.T) {
@@ -28,7 +29,7 @@ func TestSet(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
-				entry, exists := server.Memory["mykey"]
+				entry, exists := server.Memory.Get("mykey")
 				if !exists {
 					t.Error("Key should exist after SET")
 				}
@@ -51,7 +52,7 @@ func TestSet(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
-				entry, exists := server.Memory["expiringkey"]
+				entry, exists := server.Memory.Get("expiringkey")
 				if !exists {
 					t.Error("Key should exist after SET")
 				}
@@ -63,6 +64,26 @@ func TestSet(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:   "set key with absolute expiration",
+			connID: "test-conn-2b",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "absolutekey"},
+				{Typ: "bulk", Bulk: "expires later"},
+				{Typ: "bulk", Bulk: "PXAT"},
+				{Typ: "bulk", Bulk: "4102444800000"},
+			},
+			expected: shared.Value{Typ: "string", Str: "OK"},
+			verify: func() {
+				entry, exists := server.Memory.Get("absolutekey")
+				if !exists {
+					t.Error("Key should exist after SET")
+				}
+				if entry.Expires != 4102444800000 {
+					t.Errorf("Expected expiration 4102444800000, got %d", entry.Expires)
+				}
+			},
+		},
 		{
 			name:   "set empty value",
 			connID: "test-conn-3",
@@ -72,7 +93,7 @@ func TestSet(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
-				entry, exists := server.Memory["emptykey"]
+				entry, exists := server.Memory.Get("emptykey")
 				if !exists {
 					t.Error("Key should exist after SET")
 				}
@@ -90,7 +111,7 @@ func TestSet(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
-				entry, exists := server.Memory["overwritekey"]
+				entry, exists := server.Memory.Get("overwritekey")
 				if !exists {
 					t.Error("Key should exist after SET")
 				}
@@ -111,7 +132,7 @@ func TestSet(t *testing.T) {
 			expected: shared.Value{Typ: "error", Str: "ERR value is not an integer or out of range"},
 			verify: func() {
 				// Key should not exist after error
-				if _, exists := server.Memory["invalidkey"]; exists {
+				if _, exists := server.Memory.Get("invalidkey"); exists {
 					t.Error("Key should not exist after error")
 				}
 			},
@@ -134,7 +155,7 @@ func TestSet(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "string", Str: "OK"},
 			verify: func() {
-				entry, exists := server.Memory["unicodekey"]
+				entry, exists := server.Memory.Get("unicodekey")
 				if !exists {
 					t.Error("Key should exist after SET")
 				}
@@ -151,10 +172,10 @@ func TestSet(t *testing.T) {
 
 			// Set up initial data for overwrite test
 			if tt.name == "overwrite existing key" {
-				server.Memory["overwritekey"] = shared.MemoryEntry{Value: "old value", Expires: 0}
+				server.Memory.Set("overwritekey", shared.MemoryEntry{Value: "old value", Expires: 0})
 			}
 
-			result := Set(tt.connID, tt.args)
+			result := runCommand("SET", Set, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Set() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -169,6 +190,71 @@ func TestSet(t *testing.T) {
 	}
 }
 
+func TestSetOptions(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+
+	tests := []struct {
+		name    string
+		args    []shared.Value
+		err     string // Expected error, empty on success
+		expires bool   // Whether the key expires
+	}{
+		{name: "Lowercase px", args: aclArgs("key", "value", "px", "1000"), expires: true},
+		{name: "Mixed case PxAt", args: aclArgs("key", "value", "PxAt", "99999999999999"), expires: true},
+		{name: "PX without value", args: aclArgs("key", "value", "PX"), err: "ERR syntax error"},
+		{name: "PX not an integer", args: aclArgs("key", "value", "px", "soon"), err: "ERR value is not an integer or out of range"},
+		{name: "Unknown option", args: aclArgs("key", "value", "BOGUS"), err: "ERR syntax error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.Memory.Delete("key")
+			result := runCommand("SET", Set, "test-conn", tt.args)
+			if tt.err != "" {
+				if result.Typ != "error" || result.Str != tt.err {
+					t.Errorf("Expected error %q, got %+v", tt.err, result)
+				}
+				if _, exists := server.Memory.Get("key"); exists {
+					t.Errorf("Expected the key not to be set")
+				}
+				return
+			}
+			if result.Str != "OK" || (getEntry("key").Expires > 0) != tt.expires {
+				t.Errorf("Unexpected result %+v, entry %+v", result, getEntry("key"))
+			}
+		})
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+
+	if result := Set(network.ClientOf("test-conn"), aclArgs("key", "old", "GET")); result.Typ != "null" {
+		t.Errorf("Expected null for a missing key, got %+v", result)
+	}
+	if result := Set(network.ClientOf("test-conn"), aclArgs("key", "new", "PX", "100000", "get")); result.Str != "old" {
+		t.Errorf("Expected the previous value, got %+v", result)
+	}
+	if entry := getEntry("key"); entry.Value != "new" || entry.Expires == 0 {
+		t.Errorf("Expected the key to be set with its expiration, got %+v", entry)
+	}
+
+	Rpush(network.ClientOf("test-conn"), aclArgs("list", "a"))
+	if result := Set(network.ClientOf("test-conn"), aclArgs("list", "value", "GET")); result.Str != shared.WrongTypeMessage {
+		t.Errorf("Expected a WRONGTYPE error, got %+v", result)
+	}
+	if entry := getEntry("list"); entry.Value != "" {
+		t.Errorf("Expected the list to be left unchanged, got %+v", entry)
+	}
+
+	server.Memory.Set("expired", shared.MemoryEntry{Value: "v", Expires: time.Now().UnixMilli() - 1})
+	if result := Set(network.ClientOf("test-conn"), aclArgs("expired", "v2", "GET")); result.Typ != "null" {
+		t.Errorf("Expected null for an expired key, got %+v", result)
+	}
+}
+
 func BenchmarkSet(b *testing.B) {
 	clearMemory()
 
@@ -180,7 +266,7 @@ func BenchmarkSet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Set(connID, args)
+		Set(network.ClientOf(connID), args)
 	}
 }
 
@@ -197,7 +283,7 @@ func BenchmarkSetWithExpiration(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Set(connID, args)
+		Set(network.ClientOf(connID), args)
 	}
 }
 
@@ -212,7 +298,7 @@ func BenchmarkSetEmptyValue(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Set(connID, args)
+		Set(network.ClientOf(connID), args)
 	}
 }
 
@@ -227,6 +313,6 @@ func BenchmarkSetUnicode(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Set(connID, args)
+		Set(network.ClientOf(connID), args)
 	}
 }
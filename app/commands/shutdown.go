@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// shutdownLog logs the messages of the shutdown subsystem
+var shutdownLog = logger.New("shutdown")
+
+// shutdownExit ends the process once SHUTDOWN is done, tests replace it
+var shutdownExit = os.Exit
+
+// Shutdown handles the SHUTDOWN command.
+// Usage: SHUTDOWN [NOSAVE | SAVE] [NOW] [FORCE]
+// Returns: Nothing on success, the server exits and the connection is closed; error message on failure.
+//
+// This command waits up to shutdown-timeout seconds for replicas to catch up, performs
+// a final synchronous save when save points are configured (or SAVE is given, unless NOSAVE is),
+// flushes the append only file, closes the listener and replication links and exits.
+// NOW skips waiting for replicas. FORCE exits even if the final save fails, with status 1.
+//
+// Examples:
+//
+//	SHUTDOWN          // Saves if save points are configured, then exits
+//	SHUTDOWN NOSAVE   // Exits without saving
+//	SHUTDOWN SAVE NOW // Saves without waiting for replicas, then exits
+func Shutdown(client *network.Client, args []shared.Value) shared.Value {
+	save, noSave, now, force := false, false, false, false
+	for _, arg := range args {
+		switch strings.ToUpper(arg.Bulk) {
+		case "SAVE":
+			save = true
+		case "NOSAVE":
+			noSave = true
+		case "NOW":
+			now = true
+		case "FORCE":
+			force = true
+		default:
+			return shared.ErrSyntax()
+		}
+	}
+	if save && noSave {
+		return shared.ErrSyntax()
+	}
+
+	shutdownLog.Warningf("User requested shutdown...")
+	if !now {
+		timeout := time.Duration(server.StoreState.ShutdownTimeout) * time.Second
+		if lagging := network.WaitReplicasForShutdown(timeout); lagging > 0 {
+			shutdownLog.Warningf("%d replicas didn't catch up before shutdown", lagging)
+		}
+	}
+
+	status := 0
+	if save || (!noSave && len(server.StoreState.SavePoints) > 0) {
+		shutdownLog.Noticef("Saving the final RDB snapshot before exiting.")
+		if err := storage.Save(); err != nil {
+			shutdownLog.Warningf("Error trying to save the DB: %v", err)
+			if !force {
+				network.CancelShutdown()
+				return createErrorResponse("ERR Errors trying to SHUTDOWN. Check logs.")
+			}
+			status = 1
+		}
+	}
+	if err := storage.CloseAppendOnlyFile(); err != nil {
+		shutdownLog.Warningf("Error flushing the append only file: %v", err)
+		status = 1
+	}
+
+	network.CloseForShutdown()
+	shutdownLog.Warningf("Redis is now ready to exit, bye bye...")
+	shutdownExit(status)
+	return shared.Value{Typ: network.NO_RESPONSE}
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// stubShutdownExit records the exit status instead of ending the test binary
+func stubShutdownExit(t *testing.T) *int {
+	status := -1
+	shutdownExit = func(code int) { status = code }
+	t.Cleanup(func() { shutdownExit = os.Exit })
+	return &status
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []shared.Value
+		savePoints []shared.SavePoint
+		expectSave bool
+	}{
+		{
+			name:       "SHUTDOWN saves when save points are configured",
+			args:       []shared.Value{},
+			savePoints: []shared.SavePoint{{Seconds: 60, Changes: 1}},
+			expectSave: true,
+		},
+		{
+			name:       "SHUTDOWN without save points",
+			args:       []shared.Value{},
+			expectSave: false,
+		},
+		{
+			name:       "SHUTDOWN SAVE without save points",
+			args:       []shared.Value{{Typ: "bulk", Bulk: "save"}},
+			expectSave: true,
+		},
+		{
+			name:       "SHUTDOWN NOSAVE with save points",
+			args:       []shared.Value{{Typ: "bulk", Bulk: "NOSAVE"}, {Typ: "bulk", Bulk: "NOW"}},
+			savePoints: []shared.SavePoint{{Seconds: 60, Changes: 1}},
+			expectSave: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			server.SetStoreState(shared.State{
+				Role:             "master",
+				Replicas:         make(map[string]net.Conn),
+				ConfigDir:        dir,
+				ConfigDbfilename: "dump.rdb",
+				SavePoints:       tt.savePoints,
+			})
+			clearMemory()
+			server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+			status := stubShutdownExit(t)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			defer l.Close()
+			network.ListenerSet(l)
+
+			result := runCommand("SHUTDOWN", Shutdown, "test-conn", tt.args)
+			defer network.CancelShutdown()
+
+			if result.Typ != network.NO_RESPONSE {
+				t.Fatalf("Expected no response, got %v", result)
+			}
+			if *status != 0 {
+				t.Errorf("Expected exit status 0, got %d", *status)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); (err == nil) != tt.expectSave {
+				t.Errorf("Expected RDB file written %v, got error %v", tt.expectSave, err)
+			}
+			if _, err := l.Accept(); err == nil {
+				t.Errorf("Expected the listener to be closed")
+			}
+		})
+	}
+}
+
+func TestShutdownInvalidArgs(t *testing.T) {
+	status := stubShutdownExit(t)
+
+	for _, args := range [][]shared.Value{
+		{{Typ: "bulk", Bulk: "SAVE"}, {Typ: "bulk", Bulk: "NOSAVE"}},
+		{{Typ: "bulk", Bulk: "LATER"}},
+	} {
+		result := Shutdown(network.ClientOf("test-conn"), args)
+		if result.Typ != "error" || result.Str != "ERR syntax error" {
+			t.Errorf("Expected syntax error for %v, got %v", args, result)
+		}
+	}
+	if *status != -1 {
+		t.Errorf("Expected no exit, got status %d", *status)
+	}
+}
+
+func TestShutdownSaveError(t *testing.T) {
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        filepath.Join(t.TempDir(), "missing", "\x00"),
+		ConfigDbfilename: "dump.rdb",
+	})
+	clearMemory()
+	status := stubShutdownExit(t)
+
+	// The server keeps running when the final save fails
+	result := Shutdown(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SAVE"}})
+	if result.Typ != "error" || result.Str != "ERR Errors trying to SHUTDOWN. Check logs." {
+		t.Errorf("Expected shutdown error, got %v", result)
+	}
+	if *status != -1 {
+		t.Errorf("Expected no exit, got status %d", *status)
+	}
+	if network.WritesPaused() {
+		t.Errorf("Expected writes to resume after a failed shutdown")
+	}
+
+	// FORCE exits anyway, reporting the failure in the status
+	result = Shutdown(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "SAVE"}, {Typ: "bulk", Bulk: "FORCE"}})
+	network.CancelShutdown()
+	if result.Typ != network.NO_RESPONSE || *status != 1 {
+		t.Errorf("Expected exit status 1, got %d (%v)", *status, result)
+	}
+}
+
+func BenchmarkShutdownNoSave(b *testing.B) {
+	server.SetStoreState(shared.State{
+		Role:     "master",
+		Replicas: make(map[string]net.Conn),
+	})
+	shutdownExit = func(int) {}
+	defer func() { shutdownExit = os.Exit }()
+
+	for i := 0; i < b.N; i++ {
+		Shutdown(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "NOSAVE"}, {Typ: "bulk", Bulk: "NOW"}})
+	}
+	network.CancelShutdown()
+}
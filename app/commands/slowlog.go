@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// defaultSlowlogCount is the number of entries SLOWLOG GET returns without a count
+const defaultSlowlogCount = 10
+
+// Slowlog handles the SLOWLOG command
+// Usage: SLOWLOG GET [count] | SLOWLOG LEN | SLOWLOG RESET
+// Returns: The slow log entries, their number, or OK once the log is emptied.
+//
+// Each entry holds its ID, the Unix timestamp the command ran, its duration in microseconds,
+// the command with its arguments, and the address and name of the client.
+//
+// Examples:
+//
+//	SLOWLOG GET        // Returns the 10 most recent entries
+//	SLOWLOG GET -1     // Returns every entry
+//	SLOWLOG LEN        // Returns the number of entries
+//	SLOWLOG RESET      // Empties the slow log
+func Slowlog(client *network.Client, args []shared.Value) shared.Value {
+	subcommand := strings.ToUpper(args[0].Bulk)
+
+	switch subcommand {
+	case "GET":
+		return slowlogGet(args[1:])
+	case "LEN":
+		return shared.Value{Typ: "integer", Num: server.SlowlogLen()}
+	case "RESET":
+		server.SlowlogReset()
+		return shared.Value{Typ: "string", Str: "OK"}
+	default:
+		return createErrorResponse("ERR unknown subcommand for 'slowlog' command")
+	}
+}
+
+// slowlogGet handles the SLOWLOG GET subcommand
+func slowlogGet(args []shared.Value) shared.Value {
+	if len(args) > 1 {
+		return shared.ErrWrongArity("slowlog|get")
+	}
+
+	count := defaultSlowlogCount
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0].Bulk)
+		if err != nil || n < -1 {
+			return createErrorResponse("ERR count should be greater than or equal to -1")
+		}
+		count = n
+	}
+
+	entries := server.SlowlogGet(count)
+	result := make([]shared.Value, len(entries))
+	for i, entry := range entries {
+		argv := make([]shared.Value, len(entry.Args))
+		for j, arg := range entry.Args {
+			argv[j] = shared.Value{Typ: "bulk", Bulk: arg}
+		}
+		result[i] = shared.Value{Typ: "array", Array: []shared.Value{
+			{Typ: "integer", Num: int(entry.ID)},
+			{Typ: "integer", Num: int(entry.Timestamp)},
+			{Typ: "integer", Num: int(entry.Duration)},
+			{Typ: "array", Array: argv},
+			{Typ: "bulk", Bulk: entry.ClientAddr},
+			{Typ: "bulk", Bulk: entry.ClientName},
+		}}
+	}
+	return shared.Value{Typ: "array", Array: result}
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// setSlowlogState resets the server state with the given slow log settings and empties the log
+func setSlowlogState(slowerThan, maxLen int) {
+	server.SetStoreState(shared.State{
+		Role:                 "master",
+		Replicas:             make(map[string]net.Conn),
+		SlowlogLogSlowerThan: slowerThan,
+		SlowlogMaxLen:        maxLen,
+	})
+	server.SlowlogReset()
+}
+
+func TestSlowlog(t *testing.T) {
+	defer setSlowlogState(10000, 128)
+	setSlowlogState(0, 128)
+	clearMemory()
+	initCommandHandlers()
+
+	network.ExecuteCommand("SET", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}})
+	network.ExecuteCommand("GET", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "key"}})
+
+	length := Slowlog(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "LEN"}})
+	if length.Typ != "integer" || length.Num != 2 {
+		t.Fatalf("Expected 2 entries, got %v", length)
+	}
+
+	result := Slowlog(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "GET"}})
+	if result.Typ != "array" || len(result.Array) != 2 {
+		t.Fatalf("Expected 2 entries, got %v", result)
+	}
+	newest := result.Array[0].Array
+	if len(newest) != 6 {
+		t.Fatalf("Expected a 6 element entry, got %v", newest)
+	}
+	if newest[0].Num <= result.Array[1].Array[0].Num {
+		t.Errorf("Expected the newest entry first, got IDs %d and %d", newest[0].Num, result.Array[1].Array[0].Num)
+	}
+	if args := newest[3].Array; len(args) != 2 || args[0].Bulk != "get" || args[1].Bulk != "key" {
+		t.Errorf("Expected get key, got %v", args)
+	}
+
+	limited := Slowlog(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "GET"}, {Typ: "bulk", Bulk: "1"}})
+	if len(limited.Array) != 1 {
+		t.Errorf("Expected a single entry, got %v", limited)
+	}
+
+	reset := Slowlog(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "RESET"}})
+	if reset.Str != "OK" || server.SlowlogLen() != 0 {
+		t.Errorf("Expected the slow log to be empty after RESET, got %v and %d entries", reset, server.SlowlogLen())
+	}
+}
+
+func TestSlowlogThreshold(t *testing.T) {
+	defer setSlowlogState(10000, 128)
+
+	setSlowlogState(-1, 128)
+	server.SlowlogRecord([]string{"get", "key"}, time.Second, "", "")
+	if server.SlowlogLen() != 0 {
+		t.Errorf("Expected a negative threshold to disable the slow log")
+	}
+
+	setSlowlogState(1000, 128)
+	server.SlowlogRecord([]string{"get", "key"}, 999*time.Microsecond, "", "")
+	server.SlowlogRecord([]string{"get", "key"}, time.Millisecond, "", "")
+	if server.SlowlogLen() != 1 {
+		t.Errorf("Expected only the command reaching the threshold to be logged, got %d entries", server.SlowlogLen())
+	}
+}
+
+func TestSlowlogMaxLen(t *testing.T) {
+	defer setSlowlogState(10000, 128)
+	setSlowlogState(0, 3)
+
+	for i := 0; i < 5; i++ {
+		server.SlowlogRecord([]string{"ping"}, time.Millisecond, "", "")
+	}
+	entries := server.SlowlogGet(-1)
+	if len(entries) != 3 {
+		t.Fatalf("Expected the slow log to keep 3 entries, got %d", len(entries))
+	}
+	if entries[0].ID != entries[2].ID+2 {
+		t.Errorf("Expected the newest entries to be kept, got IDs %d and %d", entries[0].ID, entries[2].ID)
+	}
+}
+
+func TestSlowlogShortensArguments(t *testing.T) {
+	defer setSlowlogState(10000, 128)
+	setSlowlogState(0, 128)
+
+	args := []string{"rpush", "list", strings.Repeat("x", 200)}
+	for i := 0; i < 40; i++ {
+		args = append(args, "v")
+	}
+	server.SlowlogRecord(args, time.Millisecond, "127.0.0.1:5000", "worker")
+
+	entry := server.SlowlogGet(1)[0]
+	if len(entry.Args) != 32 {
+		t.Fatalf("Expected 32 arguments, got %d", len(entry.Args))
+	}
+	if entry.Args[2] != strings.Repeat("x", 128)+"... (72 more bytes)" {
+		t.Errorf("Expected the long argument to be shortened, got %q", entry.Args[2])
+	}
+	if entry.Args[31] != "... (12 more arguments)" {
+		t.Errorf("Expected the last argument to count the missing ones, got %q", entry.Args[31])
+	}
+	if entry.ClientAddr != "127.0.0.1:5000" || entry.ClientName != "worker" {
+		t.Errorf("Unexpected client address or name: %q %q", entry.ClientAddr, entry.ClientName)
+	}
+}
+
+func TestSlowlogErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []shared.Value
+		expected string
+	}{
+		{name: "Unknown subcommand", args: []shared.Value{{Typ: "bulk", Bulk: "FOO"}}, expected: "ERR unknown subcommand for 'slowlog' command"},
+		{name: "Invalid count", args: []shared.Value{{Typ: "bulk", Bulk: "GET"}, {Typ: "bulk", Bulk: "-2"}}, expected: "ERR count should be greater than or equal to -1"},
+		{name: "LEN with arguments", args: []shared.Value{{Typ: "bulk", Bulk: "LEN"}, {Typ: "bulk", Bulk: "x"}}, expected: "ERR wrong number of arguments for 'slowlog|len' command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("SLOWLOG", Slowlog, "test-conn", tt.args)
+			if result.Typ != "error" || result.Str != tt.expected {
+				t.Errorf("Expected error %q, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func BenchmarkSlowlogGet(b *testing.B) {
+	setSlowlogState(0, 128)
+	for i := 0; i < 128; i++ {
+		server.SlowlogRecord([]string{"get", "key"}, time.Millisecond, "", "")
+	}
+	args := []shared.Value{{Typ: "bulk", Bulk: "GET"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Slowlog(network.ClientOf("test-conn"), args)
+	}
+}
@@ -3,7 +3,7 @@ package commands
 import (
 	"sync"
 
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -36,25 +36,13 @@ func putSubscribeResponse(s []shared.Value) {
 // Examples:
 //
 //	SUBSCRIBE mychannel1 mychannel2   // Subscribe to two channels
-func Subscribe(connID string, args []shared.Value) shared.Value {
-	if len(args) == 0 {
-		return createErrorResponse("ERR wrong number of arguments for 'subscribe' command")
-	}
-
-	// Register subscriptions for all channels efficiently
+func Subscribe(client *network.Client, args []shared.Value) shared.Value {
+	// Register subscriptions for all channels and set client in subscribed mode
 	newChannels := make([]string, 0, len(args))
 	for _, arg := range args {
-		channel := arg.Bulk
-		pubsub.SubscriptionsSet(connID, channel)
-		newChannels = append(newChannels, channel)
+		newChannels = append(newChannels, arg.Bulk)
 	}
-
-	// Set client in subscribed mode
-	pubsub.SubscribedModeSet(connID)
-
-	// Get current subscription count
-	channels, _ := pubsub.SubscriptionsGet(connID)
-	subscriptionCount := len(channels)
+	subscriptionCount := client.Subscribe(newChannels...)
 
 	// Use object pool for response slice
 	responses := getSubscribeResponse()
@@ -73,7 +61,7 @@ func Subscribe(connID string, args []shared.Value) shared.Value {
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "integer", Num: subscriptionCount},
 		}
-		responses = append(responses, shared.Value{Typ: "array", Array: responseArray})
+		responses = append(responses, shared.Value{Typ: "push", Array: responseArray})
 	}
 
 	// For single channel subscription, return the response directly
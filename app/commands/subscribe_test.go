@@ -2,10 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/codecrafters-io/redis-starter-go/app/network"
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -23,7 +23,7 @@ func TestSubscribe(t *testing.T) {
 			name:         "subscribe to single channel",
 			connID:       "conn1",
 			args:         []shared.Value{{Typ: "bulk", Bulk: "strawberry"}},
-			expectedType: "array",
+			expectedType: "push",
 			expectedArray: []shared.Value{
 				{Typ: "bulk", Bulk: "subscribe"},
 				{Typ: "bulk", Bulk: "strawberry"},
@@ -40,7 +40,7 @@ func TestSubscribe(t *testing.T) {
 				{Typ: "bulk", Bulk: "channel2"},
 				{Typ: "bulk", Bulk: "channel3"},
 			},
-			expectedType: "array",
+			expectedType: "push",
 			expectedArray: []shared.Value{
 				{Typ: "bulk", Bulk: "subscribe"},
 				{Typ: "bulk", Bulk: "channel1"},
@@ -56,7 +56,7 @@ func TestSubscribe(t *testing.T) {
 				{Typ: "bulk", Bulk: "duplicate"},
 				{Typ: "bulk", Bulk: "duplicate"},
 			},
-			expectedType: "array",
+			expectedType: "push",
 			expectedArray: []shared.Value{
 				{Typ: "bulk", Bulk: "subscribe"},
 				{Typ: "bulk", Bulk: "duplicate"},
@@ -84,7 +84,7 @@ func TestSubscribe(t *testing.T) {
 				{Typ: "bulk", Bulk: "café"},
 				{Typ: "bulk", Bulk: "тест"},
 			},
-			expectedType: "array",
+			expectedType: "push",
 			expectedArray: []shared.Value{
 				{Typ: "bulk", Bulk: "subscribe"},
 				{Typ: "bulk", Bulk: "频道1"},
@@ -98,9 +98,9 @@ func TestSubscribe(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear subscriptions before each test
-			pubsub.SetSubscriptionsMap(make(map[string][]string))
+			network.SubscriptionsClear()
 
-			result := Subscribe(tt.connID, tt.args)
+			result := runCommand("SUBSCRIBE", Subscribe, tt.connID, tt.args)
 
 			// Check response type
 			if result.Typ != tt.expectedType {
@@ -108,7 +108,7 @@ func TestSubscribe(t *testing.T) {
 			}
 
 			// Check array content
-			if result.Typ == "array" {
+			if result.Typ == "push" {
 				if len(result.Array) != len(tt.expectedArray) {
 					t.Errorf("Expected array length %d, got %d", len(tt.expectedArray), len(result.Array))
 				}
@@ -130,7 +130,7 @@ func TestSubscribe(t *testing.T) {
 			}
 
 			// Check subscription count
-			channels, exists := pubsub.SubscriptionsGet(tt.connID)
+			channels, exists := network.SubscriptionsGet(tt.connID)
 			if tt.expectedCount > 0 {
 				if !exists {
 					t.Errorf("Expected subscription to exist for connection %s", tt.connID)
@@ -147,21 +147,21 @@ func TestSubscribe(t *testing.T) {
 // BenchmarkSubscribeSingleChannel benchmarks subscribing to a single channel
 func BenchmarkSubscribeSingleChannel(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i)
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 }
 
 // BenchmarkSubscribeMultipleChannels benchmarks subscribing to multiple channels
 func BenchmarkSubscribeMultipleChannels(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	args := []shared.Value{
 		{Typ: "bulk", Bulk: "channel1"},
@@ -174,14 +174,14 @@ func BenchmarkSubscribeMultipleChannels(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i)
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 }
 
 // BenchmarkSubscribeDuplicateChannels benchmarks subscribing to duplicate channels
 func BenchmarkSubscribeDuplicateChannels(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	args := []shared.Value{
 		{Typ: "bulk", Bulk: "duplicate"},
@@ -192,14 +192,14 @@ func BenchmarkSubscribeDuplicateChannels(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i)
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 }
 
 // BenchmarkSubscribeConcurrent benchmarks concurrent subscriptions
 func BenchmarkSubscribeConcurrent(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	args := []shared.Value{{Typ: "bulk", Bulk: "concurrent-channel"}}
 
@@ -208,7 +208,7 @@ func BenchmarkSubscribeConcurrent(b *testing.B) {
 		i := 0
 		for pb.Next() {
 			connID := fmt.Sprintf("conn%d", i)
-			Subscribe(connID, args)
+			Subscribe(network.ClientOf(connID), args)
 			i++
 		}
 	})
@@ -217,7 +217,7 @@ func BenchmarkSubscribeConcurrent(b *testing.B) {
 // BenchmarkSubscribeLargeChannelList benchmarks subscribing to many channels at once
 func BenchmarkSubscribeLargeChannelList(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	// Create a large list of channels
 	args := make([]shared.Value, 100)
@@ -228,14 +228,14 @@ func BenchmarkSubscribeLargeChannelList(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i)
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 }
 
 // BenchmarkSubscribeUnicodeChannels benchmarks subscribing to unicode channel names
 func BenchmarkSubscribeUnicodeChannels(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	args := []shared.Value{
 		{Typ: "bulk", Bulk: "频道1"},
@@ -248,28 +248,28 @@ func BenchmarkSubscribeUnicodeChannels(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i)
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 }
 
 // BenchmarkSubscribeMemoryUsage benchmarks memory usage with many subscriptions
 func BenchmarkSubscribeMemoryUsage(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	args := []shared.Value{{Typ: "bulk", Bulk: "memory-test"}}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i)
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 }
 
 // BenchmarkSubscribeGetSubscriptions benchmarks getting subscription data
 func BenchmarkSubscribeGetSubscriptions(b *testing.B) {
 	// Setup: create many subscriptions
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	// Create 1000 connections with subscriptions
 	for i := 0; i < 1000; i++ {
@@ -278,20 +278,20 @@ func BenchmarkSubscribeGetSubscriptions(b *testing.B) {
 			{Typ: "bulk", Bulk: fmt.Sprintf("channel%d", i)},
 			{Typ: "bulk", Bulk: fmt.Sprintf("channel%d-2", i)},
 		}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i%1000)
-		pubsub.SubscriptionsGet(connID)
+		network.SubscriptionsGet(connID)
 	}
 }
 
 // BenchmarkSubscribeMixedWorkload benchmarks a mixed workload of different subscription patterns
 func BenchmarkSubscribeMixedWorkload(b *testing.B) {
 	// Clear subscriptions before benchmark
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	// Different subscription patterns
 	patterns := [][]shared.Value{
@@ -315,13 +315,13 @@ func BenchmarkSubscribeMixedWorkload(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		connID := fmt.Sprintf("conn%d", i)
 		pattern := patterns[i%len(patterns)]
-		Subscribe(connID, pattern)
+		Subscribe(network.ClientOf(connID), pattern)
 	}
 }
 
 func TestSubscribeMultipleConnections(t *testing.T) {
 	// Clear subscriptions
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	// Test multiple connections subscribing to different channels
 	conn1 := "connection1"
@@ -329,33 +329,33 @@ func TestSubscribeMultipleConnections(t *testing.T) {
 	conn3 := "connection3"
 
 	// Subscribe conn1 to channel1
-	result1 := Subscribe(conn1, []shared.Value{{Typ: "bulk", Bulk: "channel1"}})
-	if result1.Typ != "array" || len(result1.Array) != 3 {
+	result1 := Subscribe(network.ClientOf(conn1), []shared.Value{{Typ: "bulk", Bulk: "channel1"}})
+	if result1.Typ != "push" || len(result1.Array) != 3 {
 		t.Errorf("Expected valid array response for conn1")
 	}
 
 	// Subscribe conn2 to channel1 and channel2
-	result2 := Subscribe(conn2, []shared.Value{
+	result2 := Subscribe(network.ClientOf(conn2), []shared.Value{
 		{Typ: "bulk", Bulk: "channel1"},
 		{Typ: "bulk", Bulk: "channel2"},
 	})
-	if result2.Typ != "array" || len(result2.Array) != 3 {
+	if result2.Typ != "push" || len(result2.Array) != 3 {
 		t.Errorf("Expected valid array response for conn2")
 	}
 
 	// Subscribe conn3 to channel2 and channel3
-	result3 := Subscribe(conn3, []shared.Value{
+	result3 := Subscribe(network.ClientOf(conn3), []shared.Value{
 		{Typ: "bulk", Bulk: "channel2"},
 		{Typ: "bulk", Bulk: "channel3"},
 	})
-	if result3.Typ != "array" || len(result3.Array) != 3 {
+	if result3.Typ != "push" || len(result3.Array) != 3 {
 		t.Errorf("Expected valid array response for conn3")
 	}
 
 	// Verify subscription counts
-	channels1, _ := pubsub.SubscriptionsGet(conn1)
-	channels2, _ := pubsub.SubscriptionsGet(conn2)
-	channels3, _ := pubsub.SubscriptionsGet(conn3)
+	channels1, _ := network.SubscriptionsGet(conn1)
+	channels2, _ := network.SubscriptionsGet(conn2)
+	channels3, _ := network.SubscriptionsGet(conn3)
 
 	if len(channels1) != 1 {
 		t.Errorf("Expected conn1 to have 1 subscription, got %d", len(channels1))
@@ -381,7 +381,7 @@ func TestSubscribeMultipleConnections(t *testing.T) {
 
 func TestSubscribeConcurrent(t *testing.T) {
 	// Clear subscriptions
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	// Test concurrent subscriptions
 	done := make(chan bool, 10)
@@ -397,9 +397,9 @@ func TestSubscribeConcurrent(t *testing.T) {
 				args[j] = shared.Value{Typ: "bulk", Bulk: channel}
 			}
 
-			result := Subscribe(connID, args)
-			if result.Typ != "array" {
-				t.Errorf("Expected array response for concurrent subscription")
+			result := Subscribe(network.ClientOf(connID), args)
+			if result.Typ != "push" {
+				t.Errorf("Expected push response for concurrent subscription")
 			}
 		}(fmt.Sprintf("conn%d", i))
 	}
@@ -412,7 +412,7 @@ func TestSubscribeConcurrent(t *testing.T) {
 	// Verify all connections have correct subscriptions
 	for i := 0; i < 10; i++ {
 		connID := fmt.Sprintf("conn%d", i)
-		channels, exists := pubsub.SubscriptionsGet(connID)
+		channels, exists := network.SubscriptionsGet(connID)
 		if !exists {
 			t.Errorf("Expected subscription to exist for %s", connID)
 		} else if len(channels) != 3 {
@@ -423,15 +423,15 @@ func TestSubscribeConcurrent(t *testing.T) {
 
 func TestSubscribeEdgeCases(t *testing.T) {
 	// Clear subscriptions
-	pubsub.SetSubscriptionsMap(make(map[string][]string))
+	network.SubscriptionsClear()
 
 	t.Run("empty channel name", func(t *testing.T) {
-		result := Subscribe("conn1", []shared.Value{{Typ: "bulk", Bulk: ""}})
-		if result.Typ != "array" {
-			t.Errorf("Expected array response for empty channel name")
+		result := Subscribe(network.ClientOf("conn1"), []shared.Value{{Typ: "bulk", Bulk: ""}})
+		if result.Typ != "push" {
+			t.Errorf("Expected push response for empty channel name")
 		}
 
-		channels, _ := pubsub.SubscriptionsGet("conn1")
+		channels, _ := network.SubscriptionsGet("conn1")
 		if len(channels) != 1 || channels[0] != "" {
 			t.Errorf("Expected empty channel name to be stored")
 		}
@@ -443,12 +443,12 @@ func TestSubscribeEdgeCases(t *testing.T) {
 			longChannel = longChannel[:i] + "a" + longChannel[i+1:]
 		}
 
-		result := Subscribe("conn2", []shared.Value{{Typ: "bulk", Bulk: longChannel}})
-		if result.Typ != "array" {
-			t.Errorf("Expected array response for long channel name")
+		result := Subscribe(network.ClientOf("conn2"), []shared.Value{{Typ: "bulk", Bulk: longChannel}})
+		if result.Typ != "push" {
+			t.Errorf("Expected push response for long channel name")
 		}
 
-		channels, _ := pubsub.SubscriptionsGet("conn2")
+		channels, _ := network.SubscriptionsGet("conn2")
 		if len(channels) != 1 || channels[0] != longChannel {
 			t.Errorf("Expected long channel name to be stored correctly")
 		}
@@ -456,12 +456,12 @@ func TestSubscribeEdgeCases(t *testing.T) {
 
 	t.Run("special characters in channel name", func(t *testing.T) {
 		specialChannel := "channel!@#$%^&*()_+-=[]{}|;':\",./<>?"
-		result := Subscribe("conn3", []shared.Value{{Typ: "bulk", Bulk: specialChannel}})
-		if result.Typ != "array" {
-			t.Errorf("Expected array response for special characters")
+		result := Subscribe(network.ClientOf("conn3"), []shared.Value{{Typ: "bulk", Bulk: specialChannel}})
+		if result.Typ != "push" {
+			t.Errorf("Expected push response for special characters")
 		}
 
-		channels, _ := pubsub.SubscriptionsGet("conn3")
+		channels, _ := network.SubscriptionsGet("conn3")
 		if len(channels) != 1 || channels[0] != specialChannel {
 			t.Errorf("Expected special characters to be stored correctly")
 		}
@@ -473,22 +473,22 @@ func TestSubscribeSubscribedMode(t *testing.T) {
 		connID := "test-conn-1"
 
 		// Initially not in subscribed mode
-		if pubsub.SubscribedModeGet(connID) {
+		if network.SubscribedModeGet(connID) {
 			t.Error("Client should not be in subscribed mode initially")
 		}
 
 		// Subscribe to a channel
 		args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Should now be in subscribed mode
-		if !pubsub.SubscribedModeGet(connID) {
+		if !network.SubscribedModeGet(connID) {
 			t.Error("Client should be in subscribed mode after SUBSCRIBE")
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 }
 
@@ -498,22 +498,22 @@ func TestSubscribedModeCommandRestrictions(t *testing.T) {
 
 		// Subscribe to enter subscribed mode
 		args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Test allowed commands
 		allowedCommands := []string{"SUBSCRIBE", "UNSUBSCRIBE", "PING", "QUIT", "RESET"}
 
 		for _, cmd := range allowedCommands {
-			// These should not return error responses
+			// These should not be refused for the subscribed mode
 			result := network.ExecuteCommand(cmd, connID, []shared.Value{})
-			if result.Typ == "error" {
+			if strings.HasPrefix(result.Str, "ERR Can't execute") {
 				t.Errorf("Command %s should be allowed in subscribed mode, got error: %s", cmd, result.Str)
 			}
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("disallowed commands return error in subscribed mode", func(t *testing.T) {
@@ -521,7 +521,7 @@ func TestSubscribedModeCommandRestrictions(t *testing.T) {
 
 		// Subscribe to enter subscribed mode
 		args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Test disallowed commands
 		disallowedCommands := []string{"GET", "SET", "ECHO", "INCR", "LPUSH", "RPUSH", "XADD", "MULTI", "EXEC"}
@@ -537,15 +537,15 @@ func TestSubscribedModeCommandRestrictions(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("commands work normally when not in subscribed mode", func(t *testing.T) {
 		connID := "test-conn-7"
 
 		// Should not be in subscribed mode initially
-		if pubsub.SubscribedModeGet(connID) {
+		if network.SubscribedModeGet(connID) {
 			t.Error("Client should not be in subscribed mode initially")
 		}
 
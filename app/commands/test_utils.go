@@ -8,21 +8,32 @@ import (
 
 // clearMemory clears all entries from the shared memory for testing
 func clearMemory() {
-	for k := range server.Memory {
-		delete(server.Memory, k)
+	server.Memory.Clear()
+}
+
+// getEntry returns the entry of key, or an empty entry when it doesn't exist
+func getEntry(key string) shared.MemoryEntry {
+	entry, _ := server.Memory.Get(key)
+	return entry
+}
+
+// runCommand runs a handler after checking the arity of the command and of its subcommand,
+// like the dispatcher does before calling it
+func runCommand(command string, handler network.CommandHandler, connID string, args []shared.Value) shared.Value {
+	if err := network.ArityError(command, args); err != "" {
+		return shared.ErrorValue(err)
 	}
+	return handler(network.ClientOf(connID), args)
 }
 
 // clearTransactions clears all transactions for testing
 func clearTransactions() {
-	for k := range network.Transactions {
-		delete(network.Transactions, k)
-	}
+	network.TransactionsClear()
 }
 
 // getListAsArray gets list as array for testing (works with both linked list and array)
 func getListAsArray(key string) []string {
-	entry, exists := server.Memory[key]
+	entry, exists := server.Memory.Get(key)
 	if !exists {
 		return nil
 	}
@@ -34,27 +45,30 @@ func getListAsArray(key string) []string {
 
 // initCommandHandlers initializes the shared command handlers for testing
 func initCommandHandlers() {
-	network.CommandHandlers = map[string]shared.CommandHandler{
-		"SET":    Set,
-		"GET":    Get,
-		"LPUSH":  Lpush,
-		"RPUSH":  Rpush,
-		"LPOP":   Lpop,
-		"LLEN":   Llen,
-		"LRANGE": Lrange,
-		"INCR":   Incr,
-		"PING":   Ping,
-		"ECHO":   Echo,
-		"TYPE":   Type,
-		"XADD":   Xadd,
-		"XRANGE": Xrange,
-		"XREAD":  Xread,
-		"BLPOP":  Blpop,
-		"ZADD":   Zadd,
-		"ZRANK":  Zrank,
-		"ZRANGE": Zrange,
-		"ZSCORE": Zscore,
-		"ZREM":   Zrem,
-		"ZCARD":  Zcard,
+	network.CommandHandlers = map[string]network.CommandHandler{
+		"SET":       Set,
+		"GET":       Get,
+		"DEL":       Del,
+		"LPUSH":     Lpush,
+		"RPUSH":     Rpush,
+		"LPOP":      Lpop,
+		"LLEN":      Llen,
+		"LRANGE":    Lrange,
+		"INCR":      Incr,
+		"PING":      Ping,
+		"ECHO":      Echo,
+		"TYPE":      Type,
+		"XADD":      Xadd,
+		"XRANGE":    Xrange,
+		"XREAD":     Xread,
+		"BLPOP":     Blpop,
+		"ZADD":      Zadd,
+		"ZRANK":     Zrank,
+		"ZRANGE":    Zrange,
+		"ZSCORE":    Zscore,
+		"ZREM":      Zrem,
+		"ZCARD":     Zcard,
+		"PEXPIREAT": Pexpireat,
+		"RESTORE":   Restore,
 	}
 }
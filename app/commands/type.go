@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -19,13 +20,9 @@ import (
 //	TYPE nonexistent              // Returns "none" (key doesn't exist)
 //	TYPE mystring                 // Returns "string"
 //	TYPE mylist                   // Returns "list"
-func Type(connID string, args []shared.Value) shared.Value {
-	if len(args) != 1 {
-		return createErrorResponse("ERR wrong number of arguments for 'type' command")
-	}
-
+func Type(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.Memory.Get(key)
 
 	if !exists {
 		return shared.Value{Typ: "string", Str: "none"}
@@ -35,8 +32,16 @@ func Type(connID string, args []shared.Value) shared.Value {
 	switch {
 	case len(entry.Array) > 0:
 		return shared.Value{Typ: "string", Str: "list"}
+	case entry.List != nil && entry.List.Size > 0:
+		return shared.Value{Typ: "string", Str: "list"}
 	case len(entry.Stream) > 0:
 		return shared.Value{Typ: "string", Str: "stream"}
+	case entry.SortedSet != nil:
+		return shared.Value{Typ: "string", Str: "zset"}
+	case len(entry.Set) > 0:
+		return shared.Value{Typ: "string", Str: "set"}
+	case len(entry.Hash) > 0:
+		return shared.Value{Typ: "string", Str: "hash"}
 	case entry.Value != "":
 		return shared.Value{Typ: "string", Str: "string"}
 	default:
@@ -4,8 +4,9 @@ import (
 	"testing"
 	"time"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestType(t *testing.T) {
@@ -26,7 +27,7 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "stringkey"},
 			},
 			setup: func() {
-				server.Memory["stringkey"] = shared.MemoryEntry{Value: "hello world", Expires: 0}
+				server.Memory.Set("stringkey", shared.MemoryEntry{Value: "hello world", Expires: 0})
 			},
 			expected: shared.Value{Typ: "string", Str: "string"},
 		},
@@ -37,11 +38,11 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "listkey"},
 			},
 			setup: func() {
-				server.Memory["listkey"] = shared.MemoryEntry{
+				server.Memory.Set("listkey", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{"item1", "item2", "item3"},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "list"},
 		},
@@ -52,13 +53,13 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "streamkey"},
 			},
 			setup: func() {
-				server.Memory["streamkey"] = shared.MemoryEntry{
+				server.Memory.Set("streamkey", shared.MemoryEntry{
 					Value: "",
 					Stream: []shared.StreamEntry{
 						{ID: "1234567890-0", Data: map[string]string{"field1": "value1", "field2": "value2"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "stream"},
 		},
@@ -78,7 +79,7 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "emptykey"},
 			},
 			setup: func() {
-				server.Memory["emptykey"] = shared.MemoryEntry{Value: "", Expires: 0}
+				server.Memory.Set("emptykey", shared.MemoryEntry{Value: "", Expires: 0})
 			},
 			expected: shared.Value{Typ: "string", Str: "none"},
 		},
@@ -89,11 +90,11 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "emptylist"},
 			},
 			setup: func() {
-				server.Memory["emptylist"] = shared.MemoryEntry{
+				server.Memory.Set("emptylist", shared.MemoryEntry{
 					Value:   "",
 					Array:   []string{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "none"},
 		},
@@ -104,11 +105,11 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "emptystream"},
 			},
 			setup: func() {
-				server.Memory["emptystream"] = shared.MemoryEntry{
+				server.Memory.Set("emptystream", shared.MemoryEntry{
 					Value:   "",
 					Stream:  []shared.StreamEntry{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "none"},
 		},
@@ -119,10 +120,10 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "expiredkey"},
 			},
 			setup: func() {
-				server.Memory["expiredkey"] = shared.MemoryEntry{
+				server.Memory.Set("expiredkey", shared.MemoryEntry{
 					Value:   "expired value",
 					Expires: time.Now().UnixMilli() - 1000, // Expired 1 second ago
-				}
+				})
 			},
 			expected: shared.Value{Typ: "string", Str: "string"},
 		},
@@ -140,10 +141,45 @@ func TestType(t *testing.T) {
 				{Typ: "bulk", Bulk: "unicodekey"},
 			},
 			setup: func() {
-				server.Memory["unicodekey"] = shared.MemoryEntry{Value: "Hello 世界 🌍", Expires: 0}
+				server.Memory.Set("unicodekey", shared.MemoryEntry{Value: "Hello 世界 🌍", Expires: 0})
 			},
 			expected: shared.Value{Typ: "string", Str: "string"},
 		},
+		{
+			name:   "type of sorted set key",
+			connID: "test-conn-11",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "zsetkey"},
+			},
+			setup: func() {
+				zset := shared.NewSortedSet()
+				zset.Add("member", 1)
+				server.Memory.Set("zsetkey", shared.MemoryEntry{SortedSet: zset})
+			},
+			expected: shared.Value{Typ: "string", Str: "zset"},
+		},
+		{
+			name:   "type of set key",
+			connID: "test-conn-12",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "setkey"},
+			},
+			setup: func() {
+				server.Memory.Set("setkey", shared.MemoryEntry{Set: map[string]struct{}{"a": {}}})
+			},
+			expected: shared.Value{Typ: "string", Str: "set"},
+		},
+		{
+			name:   "type of hash key",
+			connID: "test-conn-13",
+			args: []shared.Value{
+				{Typ: "bulk", Bulk: "hashkey"},
+			},
+			setup: func() {
+				server.Memory.Set("hashkey", shared.MemoryEntry{Hash: map[string]string{"field": "value"}})
+			},
+			expected: shared.Value{Typ: "string", Str: "hash"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -151,7 +187,7 @@ func TestType(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Type(tt.connID, tt.args)
+			result := runCommand("TYPE", Type, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Type() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -170,7 +206,7 @@ func TestType(t *testing.T) {
 
 func BenchmarkType(b *testing.B) {
 	clearMemory()
-	server.Memory["benchkey"] = shared.MemoryEntry{Value: "Hello World", Expires: 0}
+	server.Memory.Set("benchkey", shared.MemoryEntry{Value: "Hello World", Expires: 0})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -179,17 +215,17 @@ func BenchmarkType(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Type(connID, args)
+		Type(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkTypeList(b *testing.B) {
 	clearMemory()
-	server.Memory["benchlist"] = shared.MemoryEntry{
+	server.Memory.Set("benchlist", shared.MemoryEntry{
 		Value:   "",
 		Array:   []string{"item1", "item2", "item3"},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -198,19 +234,19 @@ func BenchmarkTypeList(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Type(connID, args)
+		Type(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkTypeStream(b *testing.B) {
 	clearMemory()
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Value: "",
 		Stream: []shared.StreamEntry{
 			{ID: "1234567890-0", Data: map[string]string{"field1": "value1", "field2": "value2"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -219,7 +255,7 @@ func BenchmarkTypeStream(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Type(connID, args)
+		Type(network.ClientOf(connID), args)
 	}
 }
 
@@ -233,6 +269,6 @@ func BenchmarkTypeNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Type(connID, args)
+		Type(network.ClientOf(connID), args)
 	}
 }
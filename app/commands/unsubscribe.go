@@ -3,7 +3,7 @@ package commands
 import (
 	"sync"
 
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -52,12 +52,12 @@ func putUnsubscribeChannelSlice(s []string) {
 //
 //	UNSUBSCRIBE mychannel1 mychannel2   // Unsubscribe from two channels
 //	UNSUBSCRIBE                         // Unsubscribe from all channels
-func Unsubscribe(connID string, args []shared.Value) shared.Value {
+func Unsubscribe(client *network.Client, args []shared.Value) shared.Value {
 	// Get current subscriptions once
-	channels, hasSubscriptions := pubsub.SubscriptionsGet(connID)
-	if !hasSubscriptions {
+	channels := client.Channels()
+	if len(channels) == 0 {
 		// Client has no subscriptions, return empty response
-		return shared.Value{Typ: "array", Array: []shared.Value{
+		return shared.Value{Typ: "push", Array: []shared.Value{
 			{Typ: "bulk", Bulk: "unsubscribe"},
 			{Typ: "bulk", Bulk: ""},
 			{Typ: "integer", Num: 0},
@@ -66,9 +66,8 @@ func Unsubscribe(connID string, args []shared.Value) shared.Value {
 
 	// If no channels specified, unsubscribe from all
 	if len(args) == 0 {
-		// Unsubscribe from all channels
-		pubsub.SubscriptionsDelete(connID)
-		pubsub.SubscribedModeDelete(connID)
+		// Unsubscribe from all channels, leaving subscribed mode
+		client.SetChannels(nil)
 
 		// Use object pool for responses
 		responses := getUnsubscribeResponse()
@@ -87,7 +86,7 @@ func Unsubscribe(connID string, args []shared.Value) shared.Value {
 				{Typ: "bulk", Bulk: channel},
 				{Typ: "integer", Num: 0},
 			}
-			responses = append(responses, shared.Value{Typ: "array", Array: responseArray})
+			responses = append(responses, shared.Value{Typ: "push", Array: responseArray})
 		}
 
 		// Return the first response (Redis behavior)
@@ -95,7 +94,7 @@ func Unsubscribe(connID string, args []shared.Value) shared.Value {
 			return responses[0]
 		}
 
-		return shared.Value{Typ: "array", Array: []shared.Value{
+		return shared.Value{Typ: "push", Array: []shared.Value{
 			{Typ: "bulk", Bulk: "unsubscribe"},
 			{Typ: "bulk", Bulk: ""},
 			{Typ: "integer", Num: 0},
@@ -128,15 +127,8 @@ func Unsubscribe(connID string, args []shared.Value) shared.Value {
 		}
 	}
 
-	// Update subscriptions
-	if len(newChannels) == 0 {
-		// No more subscriptions, remove from subscribed mode
-		pubsub.SubscriptionsDelete(connID)
-		pubsub.SubscribedModeDelete(connID)
-	} else {
-		// Update subscriptions with remaining channels
-		pubsub.SubscriptionsSetChannels(connID, newChannels)
-	}
+	// Update subscriptions, with no more of them the client leaves subscribed mode
+	client.SetChannels(newChannels)
 
 	remainingCount := len(newChannels)
 
@@ -157,7 +149,7 @@ func Unsubscribe(connID string, args []shared.Value) shared.Value {
 			{Typ: "bulk", Bulk: channel},
 			{Typ: "integer", Num: remainingCount},
 		}
-		responses = append(responses, shared.Value{Typ: "array", Array: responseArray})
+		responses = append(responses, shared.Value{Typ: "push", Array: responseArray})
 	}
 
 	// For single channel unsubscription, return the response directly
@@ -171,7 +163,7 @@ func Unsubscribe(connID string, args []shared.Value) shared.Value {
 	}
 
 	// No channels were unsubscribed (they weren't subscribed)
-	return shared.Value{Typ: "array", Array: []shared.Value{
+	return shared.Value{Typ: "push", Array: []shared.Value{
 		{Typ: "bulk", Bulk: "unsubscribe"},
 		{Typ: "bulk", Bulk: ""},
 		{Typ: "integer", Num: remainingCount},
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
@@ -14,15 +14,15 @@ func TestUnsubscribeCommand(t *testing.T) {
 
 		// Subscribe to multiple channels
 		args := []shared.Value{{Typ: "bulk", Bulk: "channel1"}, {Typ: "bulk", Bulk: "channel2"}, {Typ: "bulk", Bulk: "channel3"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Unsubscribe from two channels
 		unsubArgs := []shared.Value{{Typ: "bulk", Bulk: "channel1"}, {Typ: "bulk", Bulk: "channel3"}}
-		result := Unsubscribe(connID, unsubArgs)
+		result := Unsubscribe(network.ClientOf(connID), unsubArgs)
 
 		// Should return unsubscribe response
-		if result.Typ != "array" || len(result.Array) != 3 {
-			t.Errorf("Expected array response with 3 elements, got: %v", result)
+		if result.Typ != "push" || len(result.Array) != 3 {
+			t.Errorf("Expected push response with 3 elements, got: %v", result)
 		}
 
 		// Check response format
@@ -31,19 +31,19 @@ func TestUnsubscribeCommand(t *testing.T) {
 		}
 
 		// Should have one remaining subscription
-		channels, _ := pubsub.SubscriptionsGet(connID)
+		channels, _ := network.SubscriptionsGet(connID)
 		if len(channels) != 1 || channels[0] != "channel2" {
 			t.Errorf("Expected 1 remaining subscription 'channel2', got %v", channels)
 		}
 
 		// Should still be in subscribed mode
-		if !pubsub.SubscribedModeGet(connID) {
+		if !network.SubscribedModeGet(connID) {
 			t.Error("Client should still be in subscribed mode")
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("unsubscribe from all channels", func(t *testing.T) {
@@ -51,30 +51,30 @@ func TestUnsubscribeCommand(t *testing.T) {
 
 		// Subscribe to channels
 		args := []shared.Value{{Typ: "bulk", Bulk: "channel1"}, {Typ: "bulk", Bulk: "channel2"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Unsubscribe from all (no args)
-		result := Unsubscribe(connID, []shared.Value{})
+		result := Unsubscribe(network.ClientOf(connID), []shared.Value{})
 
 		// Should return unsubscribe response
-		if result.Typ != "array" || len(result.Array) != 3 {
-			t.Errorf("Expected array response with 3 elements, got: %v", result)
+		if result.Typ != "push" || len(result.Array) != 3 {
+			t.Errorf("Expected push response with 3 elements, got: %v", result)
 		}
 
 		// Should have no remaining subscriptions
-		channels, _ := pubsub.SubscriptionsGet(connID)
+		channels, _ := network.SubscriptionsGet(connID)
 		if len(channels) != 0 {
 			t.Errorf("Expected no remaining subscriptions, got %v", channels)
 		}
 
 		// Should not be in subscribed mode
-		if pubsub.SubscribedModeGet(connID) {
+		if network.SubscribedModeGet(connID) {
 			t.Error("Client should not be in subscribed mode after unsubscribing from all")
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("unsubscribe from non-existent channel", func(t *testing.T) {
@@ -82,37 +82,37 @@ func TestUnsubscribeCommand(t *testing.T) {
 
 		// Subscribe to a channel
 		args := []shared.Value{{Typ: "bulk", Bulk: "channel1"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Try to unsubscribe from non-existent channel
 		unsubArgs := []shared.Value{{Typ: "bulk", Bulk: "nonexistent"}}
-		result := Unsubscribe(connID, unsubArgs)
+		result := Unsubscribe(network.ClientOf(connID), unsubArgs)
 
 		// Should return response with 0 remaining subscriptions
-		if result.Typ != "array" || len(result.Array) != 3 {
-			t.Errorf("Expected array response with 3 elements, got: %v", result)
+		if result.Typ != "push" || len(result.Array) != 3 {
+			t.Errorf("Expected push response with 3 elements, got: %v", result)
 		}
 
 		// Should still have original subscription
-		channels, _ := pubsub.SubscriptionsGet(connID)
+		channels, _ := network.SubscriptionsGet(connID)
 		if len(channels) != 1 || channels[0] != "channel1" {
 			t.Errorf("Expected 1 remaining subscription 'channel1', got %v", channels)
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("unsubscribe when not subscribed", func(t *testing.T) {
 		connID := "test-conn-11"
 
 		// Try to unsubscribe without being subscribed
-		result := Unsubscribe(connID, []shared.Value{})
+		result := Unsubscribe(network.ClientOf(connID), []shared.Value{})
 
 		// Should return empty response
-		if result.Typ != "array" || len(result.Array) != 3 {
-			t.Errorf("Expected array response with 3 elements, got: %v", result)
+		if result.Typ != "push" || len(result.Array) != 3 {
+			t.Errorf("Expected push response with 3 elements, got: %v", result)
 		}
 
 		if result.Array[0].Bulk != "unsubscribe" || result.Array[1].Bulk != "" || result.Array[2].Num != 0 {
@@ -120,8 +120,8 @@ func TestUnsubscribeCommand(t *testing.T) {
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 }
 
@@ -131,24 +131,24 @@ func TestUnsubscribeSubscribedMode(t *testing.T) {
 
 		// Subscribe to channels
 		args := []shared.Value{{Typ: "bulk", Bulk: "channel1"}, {Typ: "bulk", Bulk: "channel2"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Should be in subscribed mode
-		if !pubsub.SubscribedModeGet(connID) {
+		if !network.SubscribedModeGet(connID) {
 			t.Error("Client should be in subscribed mode after SUBSCRIBE")
 		}
 
 		// Unsubscribe from all channels
-		Unsubscribe(connID, []shared.Value{})
+		Unsubscribe(network.ClientOf(connID), []shared.Value{})
 
 		// Should no longer be in subscribed mode
-		if pubsub.SubscribedModeGet(connID) {
+		if network.SubscribedModeGet(connID) {
 			t.Error("Client should not be in subscribed mode after UNSUBSCRIBE all")
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("client exits subscribed mode after UNSUBSCRIBE last channel", func(t *testing.T) {
@@ -156,25 +156,25 @@ func TestUnsubscribeSubscribedMode(t *testing.T) {
 
 		// Subscribe to a channel
 		args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Should be in subscribed mode
-		if !pubsub.SubscribedModeGet(connID) {
+		if !network.SubscribedModeGet(connID) {
 			t.Error("Client should be in subscribed mode after SUBSCRIBE")
 		}
 
 		// Unsubscribe from the channel
 		unsubArgs := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Unsubscribe(connID, unsubArgs)
+		Unsubscribe(network.ClientOf(connID), unsubArgs)
 
 		// Should no longer be in subscribed mode
-		if pubsub.SubscribedModeGet(connID) {
+		if network.SubscribedModeGet(connID) {
 			t.Error("Client should not be in subscribed mode after UNSUBSCRIBE last channel")
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 
 	t.Run("client stays in subscribed mode after UNSUBSCRIBE some channels", func(t *testing.T) {
@@ -182,31 +182,31 @@ func TestUnsubscribeSubscribedMode(t *testing.T) {
 
 		// Subscribe to multiple channels
 		args := []shared.Value{{Typ: "bulk", Bulk: "channel1"}, {Typ: "bulk", Bulk: "channel2"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		// Should be in subscribed mode
-		if !pubsub.SubscribedModeGet(connID) {
+		if !network.SubscribedModeGet(connID) {
 			t.Error("Client should be in subscribed mode after SUBSCRIBE")
 		}
 
 		// Unsubscribe from one channel
 		unsubArgs := []shared.Value{{Typ: "bulk", Bulk: "channel1"}}
-		Unsubscribe(connID, unsubArgs)
+		Unsubscribe(network.ClientOf(connID), unsubArgs)
 
 		// Should still be in subscribed mode
-		if !pubsub.SubscribedModeGet(connID) {
+		if !network.SubscribedModeGet(connID) {
 			t.Error("Client should still be in subscribed mode after UNSUBSCRIBE some channels")
 		}
 
 		// Should have one remaining subscription
-		channels, _ := pubsub.SubscriptionsGet(connID)
+		channels, _ := network.SubscriptionsGet(connID)
 		if len(channels) != 1 || channels[0] != "channel2" {
 			t.Errorf("Expected 1 remaining subscription 'channel2', got %v", channels)
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 }
 
@@ -216,19 +216,19 @@ func BenchmarkUnsubscribeSingleChannel(b *testing.B) {
 
 	// Pre-subscribe to a channel
 	args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-	Subscribe(connID, args)
+	Subscribe(network.ClientOf(connID), args)
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		// Unsubscribe and re-subscribe for next iteration
-		Unsubscribe(connID, args)
-		Subscribe(connID, args)
+		Unsubscribe(network.ClientOf(connID), args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 
 	// Clean up
-	pubsub.SubscribedModeDelete(connID)
-	pubsub.SubscriptionsDelete(connID)
+	network.SubscribedModeDelete(connID)
+	network.SubscriptionsDelete(connID)
 }
 
 func BenchmarkUnsubscribeMultipleChannels(b *testing.B) {
@@ -236,19 +236,19 @@ func BenchmarkUnsubscribeMultipleChannels(b *testing.B) {
 
 	// Pre-subscribe to multiple channels
 	args := []shared.Value{{Typ: "bulk", Bulk: "channel1"}, {Typ: "bulk", Bulk: "channel2"}, {Typ: "bulk", Bulk: "channel3"}}
-	Subscribe(connID, args)
+	Subscribe(network.ClientOf(connID), args)
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		// Unsubscribe from all and re-subscribe for next iteration
-		Unsubscribe(connID, []shared.Value{})
-		Subscribe(connID, args)
+		Unsubscribe(network.ClientOf(connID), []shared.Value{})
+		Subscribe(network.ClientOf(connID), args)
 	}
 
 	// Clean up
-	pubsub.SubscribedModeDelete(connID)
-	pubsub.SubscriptionsDelete(connID)
+	network.SubscribedModeDelete(connID)
+	network.SubscriptionsDelete(connID)
 }
 
 func BenchmarkUnsubscribeConcurrent(b *testing.B) {
@@ -257,17 +257,17 @@ func BenchmarkUnsubscribeConcurrent(b *testing.B) {
 
 		// Pre-subscribe to a channel
 		args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-		Subscribe(connID, args)
+		Subscribe(network.ClientOf(connID), args)
 
 		for pb.Next() {
 			// Unsubscribe and re-subscribe
-			Unsubscribe(connID, args)
-			Subscribe(connID, args)
+			Unsubscribe(network.ClientOf(connID), args)
+			Subscribe(network.ClientOf(connID), args)
 		}
 
 		// Clean up
-		pubsub.SubscribedModeDelete(connID)
-		pubsub.SubscriptionsDelete(connID)
+		network.SubscribedModeDelete(connID)
+		network.SubscriptionsDelete(connID)
 	})
 }
 
@@ -281,19 +281,19 @@ func BenchmarkUnsubscribeLargeChannelList(b *testing.B) {
 	}
 
 	// Pre-subscribe to all channels
-	Subscribe(connID, channels)
+	Subscribe(network.ClientOf(connID), channels)
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		// Unsubscribe from all and re-subscribe for next iteration
-		Unsubscribe(connID, []shared.Value{})
-		Subscribe(connID, channels)
+		Unsubscribe(network.ClientOf(connID), []shared.Value{})
+		Subscribe(network.ClientOf(connID), channels)
 	}
 
 	// Clean up
-	pubsub.SubscribedModeDelete(connID)
-	pubsub.SubscriptionsDelete(connID)
+	network.SubscribedModeDelete(connID)
+	network.SubscriptionsDelete(connID)
 }
 
 func BenchmarkUnsubscribeMemoryUsage(b *testing.B) {
@@ -301,19 +301,19 @@ func BenchmarkUnsubscribeMemoryUsage(b *testing.B) {
 
 	// Pre-subscribe to a channel
 	args := []shared.Value{{Typ: "bulk", Bulk: "test-channel"}}
-	Subscribe(connID, args)
+	Subscribe(network.ClientOf(connID), args)
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		// Unsubscribe and re-subscribe
-		Unsubscribe(connID, args)
-		Subscribe(connID, args)
+		Unsubscribe(network.ClientOf(connID), args)
+		Subscribe(network.ClientOf(connID), args)
 	}
 
 	// Clean up
-	pubsub.SubscribedModeDelete(connID)
-	pubsub.SubscriptionsDelete(connID)
+	network.SubscribedModeDelete(connID)
+	network.SubscriptionsDelete(connID)
 }
 
 func BenchmarkUnsubscribeMixedWorkload(b *testing.B) {
@@ -321,18 +321,18 @@ func BenchmarkUnsubscribeMixedWorkload(b *testing.B) {
 
 	// Pre-subscribe to multiple channels
 	args := []shared.Value{{Typ: "bulk", Bulk: "channel1"}, {Typ: "bulk", Bulk: "channel2"}, {Typ: "bulk", Bulk: "channel3"}}
-	Subscribe(connID, args)
+	Subscribe(network.ClientOf(connID), args)
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		// Mixed workload: unsubscribe from some, then all, then re-subscribe
-		Unsubscribe(connID, []shared.Value{{Typ: "bulk", Bulk: "channel1"}})
-		Unsubscribe(connID, []shared.Value{})
-		Subscribe(connID, args)
+		Unsubscribe(network.ClientOf(connID), []shared.Value{{Typ: "bulk", Bulk: "channel1"}})
+		Unsubscribe(network.ClientOf(connID), []shared.Value{})
+		Subscribe(network.ClientOf(connID), args)
 	}
 
 	// Clean up
-	pubsub.SubscribedModeDelete(connID)
-	pubsub.SubscriptionsDelete(connID)
+	network.SubscribedModeDelete(connID)
+	network.SubscriptionsDelete(connID)
 }
@@ -1,8 +1,42 @@
 package commands
 
-import "github.com/codecrafters-io/redis-starter-go/app/shared"
+import (
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// streamReplyMinElements is the number of elements from which range commands stream their
+// reply to the client instead of building it in memory
+const streamReplyMinElements = 1024
+
+// checkRangeReply checks a range reply of n elements against range-reply-max-elements and
+// counts the big ones for INFO. It returns the error to reply and false when the range is
+// over the limit.
+func checkRangeReply(n int) (shared.Value, bool) {
+	if limit := server.StoreState.RangeReplyMaxElements; limit > 0 && n > limit {
+		server.RangeReplyRejected()
+		return shared.ErrorValue("ERR reply of " + strconv.Itoa(n) + " elements exceeds range-reply-max-elements (" +
+			strconv.Itoa(limit) + "), narrow the range or use COUNT"), false
+	}
+	if n >= streamReplyMinElements {
+		server.BigRangeReply()
+	}
+	return shared.Value{}, true
+}
 
 // createErrorResponse creates a standardized error response.
 func createErrorResponse(message string) shared.Value {
-	return shared.Value{Typ: "error", Str: message}
+	return shared.ErrorValue(message)
+}
+
+// containsString reports whether the slice contains the given string.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
@@ -13,12 +13,9 @@ import (
 // WAIT numreplicas timeout(ms)
 // Returns: "integer" with the number of replicas that have acknowledged
 // This command waits for a specified number of replicas to acknowledge commands.
-// It uses REPLCONF GETACK to prompt replicas to acknowledge commands.
-func Wait(connID string, args []shared.Value) shared.Value {
-	if len(args) != 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'wait' command")
-	}
-
+// Replicas report their offset every second on their own, so WAIT first checks the
+// offsets they already acknowledged and only uses REPLCONF GETACK to prompt the rest.
+func Wait(client *network.Client, args []shared.Value) shared.Value {
 	numReplicas, err := strconv.Atoi(args[0].Bulk)
 	if err != nil {
 		return createErrorResponse("ERR numreplicas is not an integer or out of range")
@@ -28,6 +25,12 @@ func Wait(connID string, args []shared.Value) shared.Value {
 		return createErrorResponse("ERR timeout is not an integer or out of range")
 	}
 
+	// Every write propagated so far must be acknowledged
+	targetOffset := server.StoreState.MasterReplOffset
+	if ackedCount := network.ReplicasAckedCount(targetOffset); ackedCount >= numReplicas {
+		return shared.Value{Typ: "integer", Num: ackedCount}
+	}
+
 	// Send GETACK to all replicas to prompt ACK responses
 	network.SendReplconfGetack()
 
@@ -36,7 +39,7 @@ func Wait(connID string, args []shared.Value) shared.Value {
 
 	for time.Now().Before(deadline) {
 		// Count how many replicas have acknowledged
-		ackCount := network.AcknowledgedReplicasCount()
+		ackCount := network.ReplicasAckedCount(targetOffset)
 
 		// If we have enough acknowledgments, return immediately
 		if ackCount >= numReplicas {
@@ -49,7 +52,7 @@ func Wait(connID string, args []shared.Value) shared.Value {
 
 	// Timeout reached, return current acknowledgment count
 	// But if no acknowledgments were received, return total replicas (fallback behavior)
-	finalAckCount := network.AcknowledgedReplicasCount()
+	finalAckCount := network.ReplicasAckedCount(targetOffset)
 	if finalAckCount == 0 {
 		return shared.Value{Typ: "integer", Num: len(server.StoreState.Replicas)}
 	}
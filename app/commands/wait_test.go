@@ -7,8 +7,8 @@ import (
 	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/network"
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestWait(t *testing.T) {
@@ -79,7 +79,7 @@ func TestWait(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := Wait("test-conn", tt.args)
+			result := runCommand("WAIT", Wait, "test-conn", tt.args)
 
 			if tt.expectError {
 				if result.Typ != "error" {
@@ -156,7 +156,7 @@ func TestWaitWithConnectedReplicas(t *testing.T) {
 			}
 
 			start := time.Now()
-			result := Wait("test-conn", args)
+			result := Wait(network.ClientOf("test-conn"), args)
 			duration := time.Since(start)
 
 			if result.Typ != "integer" {
@@ -204,7 +204,7 @@ func TestWaitWithAcknowledgedReplicas(t *testing.T) {
 		{Typ: "bulk", Bulk: "100"},
 	}
 
-	result := Wait("test-conn", args)
+	result := Wait(network.ClientOf("test-conn"), args)
 
 	if result.Typ != "integer" {
 		t.Errorf("Expected integer type, got %s", result.Typ)
@@ -215,6 +215,56 @@ func TestWaitWithAcknowledgedReplicas(t *testing.T) {
 	}
 }
 
+func TestMinReplicasToWrite(t *testing.T) {
+	clearMemory()
+	initCommandHandlers()
+	server.SetStoreState(shared.State{
+		Role:               "master",
+		MasterReplID:       "test-repl-id",
+		Replicas:           make(map[string]net.Conn),
+		MinReplicasToWrite: 1,
+		MinReplicasMaxLag:  10,
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+
+	setArgs := []shared.Value{
+		{Typ: "bulk", Bulk: "key"},
+		{Typ: "bulk", Bulk: "value"},
+	}
+
+	// No replicas connected: writes are refused, reads still work
+	result := network.ExecuteCommand("SET", "test-conn", setArgs)
+	if result.Typ != "error" || result.Str != "NOREPLICAS Not enough good replicas to write." {
+		t.Errorf("Expected NOREPLICAS error, got %v", result)
+	}
+	result = network.ExecuteCommand("GET", "test-conn", setArgs[:1])
+	if result.Typ != "null" {
+		t.Errorf("Expected GET to be served, got %v", result)
+	}
+
+	// A replica that acknowledged recently counts as good
+	network.ReplicasSet("replica-1", &mockConn{})
+	defer network.ReplicasDelete("replica-1")
+	Replconf(network.ClientOf("replica-1"), []shared.Value{
+		{Typ: "bulk", Bulk: "ACK"},
+		{Typ: "bulk", Bulk: "0"},
+	})
+
+	result = network.ExecuteCommand("SET", "test-conn", setArgs)
+	if result.Typ != "string" || result.Str != "OK" {
+		t.Errorf("Expected SET to succeed with a good replica, got %v", result)
+	}
+
+	// A replica whose last ACK is older than the max lag no longer counts
+	network.ReplicaInfoUpdate("replica-1", func(info *shared.ReplicaInfo) {
+		info.LastAck = time.Now().Add(-11 * time.Second).UnixMilli()
+	})
+	result = network.ExecuteCommand("SET", "test-conn", setArgs)
+	if result.Typ != "error" {
+		t.Errorf("Expected NOREPLICAS error with a lagging replica, got %v", result)
+	}
+}
+
 // mockConn is a simple mock implementation of net.Conn for testing
 type mockConn struct{}
 
@@ -247,6 +297,6 @@ func BenchmarkWait(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Wait("bench-conn", args)
+		Wait(network.ClientOf("bench-conn"), args)
 	}
 }
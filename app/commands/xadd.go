@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -187,14 +188,9 @@ func validateStreamKeyOptimized(id string, stream []string) (bool, error) {
 //	XADD mystream * message "Hello"             // Auto-generate both timestamp and sequence
 //	XADD mystream 0-* message "Hello"           // Auto-generate sequence only
 //	XADD mystream *-0 message "Hello"           // Auto-generate timestamp only
-func Xadd(connID string, args []shared.Value) shared.Value {
-	if len(args) < 3 {
-		return createErrorResponse("ERR wrong number of arguments for 'xadd' command")
-	}
-
+func Xadd(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
 	id := args[1].Bulk
-	entry, exists := server.Memory[key]
 
 	// Parse field-value pairs efficiently
 	streamData := make(map[string]string, (len(args)-2)/2)
@@ -205,33 +201,43 @@ func Xadd(connID string, args []shared.Value) shared.Value {
 	}
 
 	var actualID string
-	var streamIDs []string
+	var err error
 
-	if !exists {
-		streamIDs = []string{}
-	} else {
-		// Optimize: Pre-allocate slice with known capacity
-		streamIDs = make([]string, 0, len(entry.Stream))
-		for _, streamEntry := range entry.Stream {
-			streamIDs = append(streamIDs, streamEntry.ID)
+	// The ID is generated and the entry added under the lock of the key, so entries added
+	// at once by two clients get different IDs
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		var streamIDs []string
+		if !exists {
+			streamIDs = []string{}
+		} else {
+			// Optimize: Pre-allocate slice with known capacity
+			streamIDs = make([]string, 0, len(entry.Stream))
+			for _, streamEntry := range entry.Stream {
+				streamIDs = append(streamIDs, streamEntry.ID)
+			}
 		}
-	}
 
-	actualID = generateActualIDOptimized(id, streamIDs)
-	valid, err := validateStreamKeyOptimized(actualID, streamIDs)
-	if !valid {
-		return createErrorResponse(err.Error())
-	}
+		actualID = generateActualIDOptimized(id, streamIDs)
+		var valid bool
+		if valid, err = validateStreamKeyOptimized(actualID, streamIDs); !valid {
+			return entry, false
+		}
 
-	// Create stream entry efficiently
-	streamEntry := shared.StreamEntry{ID: actualID, Data: streamData}
+		// Create stream entry efficiently
+		streamEntry := shared.StreamEntry{ID: actualID, Data: streamData}
 
-	if !exists {
-		// Optimize: Pre-allocate with capacity
-		entry = shared.MemoryEntry{Stream: make([]shared.StreamEntry, 0, 1)}
+		if !exists {
+			// Optimize: Pre-allocate with capacity
+			entry = shared.MemoryEntry{Stream: make([]shared.StreamEntry, 0, 1)}
+		}
+		entry.Stream = append(entry.Stream, streamEntry)
+		return entry, true
+	})
+	if err != nil {
+		return createErrorResponse(err.Error())
 	}
-	entry.Stream = append(entry.Stream, streamEntry)
-	server.Memory[key] = entry
+	server.NotifyKeyModified(0, key, "xadd")
+	client.MarkDirty(1)
 
 	return shared.Value{Typ: "bulk", Bulk: actualID}
 }
@@ -3,8 +3,9 @@ package commands
 import (
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestXadd(t *testing.T) {
@@ -31,7 +32,7 @@ func TestXadd(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "bulk", Bulk: "1-0"},
 			verify: func() {
-				entry, exists := server.Memory["mystream"]
+				entry, exists := server.Memory.Get("mystream")
 				if !exists {
 					t.Error("Stream should exist after XADD")
 				}
@@ -58,7 +59,7 @@ func TestXadd(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "bulk", Bulk: "*"}, // Will be replaced with actual ID
 			verify: func() {
-				entry, exists := server.Memory["mystream"]
+				entry, exists := server.Memory.Get("mystream")
 				if !exists {
 					t.Error("Stream should exist after XADD")
 				}
@@ -91,7 +92,7 @@ func TestXadd(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "bulk", Bulk: "2-0"},
 			verify: func() {
-				entry, exists := server.Memory["mystream"]
+				entry, exists := server.Memory.Get("mystream")
 				if !exists {
 					t.Error("Stream should exist after XADD")
 				}
@@ -120,16 +121,16 @@ func TestXadd(t *testing.T) {
 				{Typ: "bulk", Bulk: "World"},
 			},
 			setup: func() {
-				server.Memory["existingstream"] = shared.MemoryEntry{
+				server.Memory.Set("existingstream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "bulk", Bulk: "3-0"},
 			verify: func() {
-				entry, exists := server.Memory["existingstream"]
+				entry, exists := server.Memory.Get("existingstream")
 				if !exists {
 					t.Error("Stream should exist after XADD")
 				}
@@ -157,7 +158,7 @@ func TestXadd(t *testing.T) {
 			expected: shared.Value{Typ: "error", Str: "ERR The ID specified in XADD must be greater than 0-0"},
 			verify: func() {
 				// Stream should not exist after error
-				if _, exists := server.Memory["mystream"]; exists {
+				if _, exists := server.Memory.Get("mystream"); exists {
 					t.Error("Stream should not exist after error")
 				}
 			},
@@ -172,17 +173,17 @@ func TestXadd(t *testing.T) {
 				{Typ: "bulk", Bulk: "Old"},
 			},
 			setup: func() {
-				server.Memory["existingstream"] = shared.MemoryEntry{
+				server.Memory.Set("existingstream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "2-0", Data: map[string]string{"message": "New"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "error", Str: "ERR The ID specified in XADD is equal or smaller than the target stream top item"},
 			verify: func() {
 				// Stream should remain unchanged
-				entry, exists := server.Memory["existingstream"]
+				entry, exists := server.Memory.Get("existingstream")
 				if !exists {
 					t.Error("Stream should still exist after error")
 				}
@@ -203,7 +204,7 @@ func TestXadd(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "bulk", Bulk: "1000-0"},
 			verify: func() {
-				entry, exists := server.Memory["mystream"]
+				entry, exists := server.Memory.Get("mystream")
 				if !exists {
 					t.Error("Stream should exist after XADD")
 				}
@@ -225,16 +226,16 @@ func TestXadd(t *testing.T) {
 				{Typ: "bulk", Bulk: "Second"},
 			},
 			setup: func() {
-				server.Memory["existingstream"] = shared.MemoryEntry{
+				server.Memory.Set("existingstream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1000-0", Data: map[string]string{"message": "First"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "bulk", Bulk: "1000-1"},
 			verify: func() {
-				entry, exists := server.Memory["existingstream"]
+				entry, exists := server.Memory.Get("existingstream")
 				if !exists {
 					t.Error("Stream should exist after XADD")
 				}
@@ -269,7 +270,7 @@ func TestXadd(t *testing.T) {
 			setup:    func() {},
 			expected: shared.Value{Typ: "bulk", Bulk: "4-0"},
 			verify: func() {
-				entry, exists := server.Memory["mystream"]
+				entry, exists := server.Memory.Get("mystream")
 				if !exists {
 					t.Error("Stream should exist after XADD")
 				}
@@ -288,7 +289,7 @@ func TestXadd(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Xadd(tt.connID, tt.args)
+			result := runCommand("XADD", Xadd, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Xadd() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -326,7 +327,7 @@ func BenchmarkXadd(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xadd(connID, args)
+		Xadd(network.ClientOf(connID), args)
 	}
 }
 
@@ -343,7 +344,7 @@ func BenchmarkXaddExplicitID(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xadd(connID, args)
+		Xadd(network.ClientOf(connID), args)
 	}
 }
 
@@ -364,18 +365,18 @@ func BenchmarkXaddMultipleFields(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xadd(connID, args)
+		Xadd(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkXaddToExistingStream(b *testing.B) {
 	clearMemory()
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "existing"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -387,6 +388,6 @@ func BenchmarkXaddToExistingStream(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xadd(connID, args)
+		Xadd(network.ClientOf(connID), args)
 	}
 }
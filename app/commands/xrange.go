@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -111,33 +112,83 @@ func createStreamEntryValue(entry shared.StreamEntry) shared.Value {
 }
 
 // xrange handles the XRANGE command.
+// Usage: XRANGE key start end [COUNT count]
+//
+// COUNT returns at most count entries, the first ones of the range. Ranges of more than
+// range-reply-max-elements entries are refused, when it is set, unless COUNT keeps them
+// under it.
 //
 // Examples:
 //
 //	XRANGE mystream 1526985054069 1526985054079    // Range between specific IDs
 //	XRANGE mystream - +                            // All entries
 //	XRANGE mystream 1526985054069 +                // From specific ID to end
-func Xrange(connID string, args []shared.Value) shared.Value {
-	if len(args) < 3 {
-		return createErrorResponse("ERR wrong number of arguments for 'xrange' command")
-	}
-
+//	XRANGE mystream - + COUNT 10                   // First 10 entries
+func Xrange(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
 	start := args[1].Bulk
 	end := args[2].Bulk
 
-	entry, exists := server.Memory[key]
+	count := -1 // No limit
+	scanner := newArgScanner(args[3:])
+	for !scanner.Done() {
+		switch scanner.NextOption() {
+		case "COUNT":
+			n, err := scanner.Int64()
+			if err != nil {
+				return createErrorResponse(err.Error())
+			}
+			count = int(max(n, 0))
+		default:
+			return shared.ErrSyntax()
+		}
+	}
+
+	entry, exists := server.LookupKeyRead(key)
 	if !exists {
 		// Empty stream - return empty array
 		return shared.Value{Typ: "array", Array: []shared.Value{}}
 	}
 
-	var result []shared.Value
+	matches := 0
+	for _, streamEntry := range entry.Stream {
+		if matches == count {
+			break
+		}
+		if isInRange(streamEntry.ID, start, end) {
+			matches++
+		}
+	}
+	if reply, ok := checkRangeReply(matches); !ok {
+		return reply
+	}
 
+	// Large ranges are written to the client entry by entry
+	if matches >= streamReplyMinElements {
+		entries := entry.Stream
+		return shared.StreamArray(matches, func(yield func(shared.Value) bool) {
+			sent := 0
+			for _, streamEntry := range entries {
+				if sent == matches {
+					return
+				}
+				if isInRange(streamEntry.ID, start, end) {
+					if !yield(createStreamEntryValue(streamEntry)) {
+						return
+					}
+					sent++
+				}
+			}
+		})
+	}
+
+	result := make([]shared.Value, 0, matches)
 	for _, streamEntry := range entry.Stream {
+		if len(result) == matches {
+			break
+		}
 		if isInRange(streamEntry.ID, start, end) {
-			entryValue := createStreamEntryValue(streamEntry)
-			result = append(result, entryValue)
+			result = append(result, createStreamEntryValue(streamEntry))
 		}
 	}
 
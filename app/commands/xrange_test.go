@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestXrange(t *testing.T) {
@@ -29,19 +30,19 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "+"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
 						{ID: "3-0", Data: map[string]string{"message": "Test"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get 3 entries
-				result := Xrange("test-conn-1", []shared.Value{
+				result := Xrange(network.ClientOf("test-conn-1"), []shared.Value{
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "-"},
 					{Typ: "bulk", Bulk: "+"},
@@ -60,7 +61,7 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "3-0"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
@@ -68,12 +69,12 @@ func TestXrange(t *testing.T) {
 						{ID: "4-0", Data: map[string]string{"message": "Extra"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get entries 2-0 and 3-0
-				result := Xrange("test-conn-2", []shared.Value{
+				result := Xrange(network.ClientOf("test-conn-2"), []shared.Value{
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "2-0"},
 					{Typ: "bulk", Bulk: "3-0"},
@@ -98,19 +99,19 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "+"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
 						{ID: "3-0", Data: map[string]string{"message": "Test"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get entries from 2-0 onwards
-				result := Xrange("test-conn-3", []shared.Value{
+				result := Xrange(network.ClientOf("test-conn-3"), []shared.Value{
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "2-0"},
 					{Typ: "bulk", Bulk: "+"},
@@ -129,19 +130,19 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "2-0"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
 						{ID: "3-0", Data: map[string]string{"message": "Test"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get entries up to 2-0
-				result := Xrange("test-conn-4", []shared.Value{
+				result := Xrange(network.ClientOf("test-conn-4"), []shared.Value{
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "-"},
 					{Typ: "bulk", Bulk: "2-0"},
@@ -172,10 +173,10 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "+"},
 			},
 			setup: func() {
-				server.Memory["emptystream"] = shared.MemoryEntry{
+				server.Memory.Set("emptystream", shared.MemoryEntry{
 					Stream:  []shared.StreamEntry{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify:   func() {},
@@ -189,17 +190,17 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "1-0"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get exactly one entry
-				result := Xrange("test-conn-7", []shared.Value{
+				result := Xrange(network.ClientOf("test-conn-7"), []shared.Value{
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "1-0"},
 					{Typ: "bulk", Bulk: "1-0"},
@@ -218,7 +219,7 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "+"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{
 							"temperature": "25",
@@ -227,12 +228,12 @@ func TestXrange(t *testing.T) {
 						}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get the entry with multiple fields
-				result := Xrange("test-conn-8", []shared.Value{
+				result := Xrange(network.ClientOf("test-conn-8"), []shared.Value{
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "-"},
 					{Typ: "bulk", Bulk: "+"},
@@ -266,7 +267,7 @@ func TestXrange(t *testing.T) {
 				{Typ: "bulk", Bulk: "+"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{
 							"消息": "你好世界 🌍",
@@ -274,12 +275,12 @@ func TestXrange(t *testing.T) {
 						}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get the unicode entry
-				result := Xrange("test-conn-10", []shared.Value{
+				result := Xrange(network.ClientOf("test-conn-10"), []shared.Value{
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "-"},
 					{Typ: "bulk", Bulk: "+"},
@@ -296,7 +297,7 @@ func TestXrange(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Xrange(tt.connID, tt.args)
+			result := runCommand("XRANGE", Xrange, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Xrange() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -313,7 +314,7 @@ func TestXrange(t *testing.T) {
 
 func BenchmarkXrange(b *testing.B) {
 	clearMemory()
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 			{ID: "2-0", Data: map[string]string{"message": "World"}},
@@ -322,7 +323,7 @@ func BenchmarkXrange(b *testing.B) {
 			{ID: "5-0", Data: map[string]string{"message": "More"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -333,13 +334,13 @@ func BenchmarkXrange(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xrange(connID, args)
+		Xrange(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkXrangeSpecificRange(b *testing.B) {
 	clearMemory()
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 			{ID: "2-0", Data: map[string]string{"message": "World"}},
@@ -348,7 +349,7 @@ func BenchmarkXrangeSpecificRange(b *testing.B) {
 			{ID: "5-0", Data: map[string]string{"message": "More"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -359,7 +360,7 @@ func BenchmarkXrangeSpecificRange(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xrange(connID, args)
+		Xrange(network.ClientOf(connID), args)
 	}
 }
 
@@ -375,7 +376,70 @@ func BenchmarkXrangeNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xrange(connID, args)
+		Xrange(network.ClientOf(connID), args)
+	}
+}
+
+func TestXrangeCount(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+	stream := make([]shared.StreamEntry, 3000)
+	for i := range stream {
+		stream[i] = shared.StreamEntry{ID: fmt.Sprintf("%d-0", i+1), Data: map[string]string{"n": fmt.Sprintf("%d", i+1)}}
+	}
+	server.Memory.Set("mystream", shared.MemoryEntry{Stream: stream})
+
+	xrange := func(args ...string) shared.Value {
+		values := []shared.Value{{Typ: "bulk", Bulk: "mystream"}}
+		for _, arg := range args {
+			values = append(values, shared.Value{Typ: "bulk", Bulk: arg})
+		}
+		return Xrange(network.ClientOf("test-conn"), values).Materialize()
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		entries int
+		firstID string
+		err     string
+	}{
+		{name: "Count under the range", args: []string{"-", "+", "COUNT", "2"}, entries: 2, firstID: "1-0"},
+		{name: "Count from a start ID", args: []string{"10-0", "+", "count", "3"}, entries: 3, firstID: "10-0"},
+		{name: "Count over the range", args: []string{"2999-0", "+", "COUNT", "10"}, entries: 2, firstID: "2999-0"},
+		{name: "Streamed count", args: []string{"-", "+", "COUNT", "1500"}, entries: 1500, firstID: "1-0"},
+		{name: "Zero count", args: []string{"-", "+", "COUNT", "0"}, entries: 0},
+		{name: "Not an integer", args: []string{"-", "+", "COUNT", "x"}, err: shared.NotIntegerMessage},
+		{name: "Missing count", args: []string{"-", "+", "COUNT"}, err: shared.SyntaxMessage},
+		{name: "Unknown option", args: []string{"-", "+", "LIMIT", "2"}, err: shared.SyntaxMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := xrange(tt.args...)
+			if tt.err != "" {
+				if result.Typ != "error" || result.Str != tt.err {
+					t.Fatalf("Expected error %q, got %v", tt.err, result)
+				}
+				return
+			}
+			if len(result.Array) != tt.entries {
+				t.Fatalf("Expected %d entries, got %d", tt.entries, len(result.Array))
+			}
+			if tt.entries > 0 && result.Array[0].Array[0].Bulk != tt.firstID {
+				t.Errorf("Expected first entry %s, got %s", tt.firstID, result.Array[0].Array[0].Bulk)
+			}
+		})
+	}
+
+	// COUNT keeps a range under range-reply-max-elements
+	server.StoreState.RangeReplyMaxElements = 100
+	defer func() { server.StoreState.RangeReplyMaxElements = 0 }()
+	if result := xrange("-", "+"); result.Typ != "error" {
+		t.Errorf("Expected range-reply-max-elements error, got %d entries", len(result.Array))
+	}
+	if result := xrange("-", "+", "COUNT", "100"); len(result.Array) != 100 {
+		t.Errorf("Expected 100 entries, got %d", len(result.Array))
 	}
 }
 
@@ -392,10 +456,10 @@ func BenchmarkXrangeLargeStream(b *testing.B) {
 			},
 		}
 	}
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Stream:  stream,
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -406,6 +470,6 @@ func BenchmarkXrangeLargeStream(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xrange(connID, args)
+		Xrange(network.ClientOf(connID), args)
 	}
 }
@@ -2,46 +2,59 @@ package commands
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 )
 
-// parseBlockParameter parses the BLOCK parameter and returns timeout and streams index.
-func parseBlockParameter(args []shared.Value) (int, int) {
-	if len(args) >= 2 && strings.ToUpper(args[0].Bulk) == "BLOCK" {
-		if len(args) < 4 {
-			return 0, -1 // Error case
-		}
-
-		timeoutStr := args[1].Bulk
-		if timeoutStr == "0" {
-			return -1, 2 // Block indefinitely
-		}
-
-		if parsedTimeout, err := strconv.Atoi(timeoutStr); err == nil {
-			return parsedTimeout, 2
-		}
-		return 1000, 2 // Default timeout
-	}
-	return 0, 0 // No blocking
+// xreadOptions are the options of XREAD given before its streams
+type xreadOptions struct {
+	count        int // Most entries returned per stream, 0 for all
+	blockTimeout int // Milliseconds to wait, -1 to wait indefinitely, 0 not to block
 }
 
-// parseStreamArguments validates streams keyword and parses stream arguments.
-func parseStreamArguments(args []shared.Value, streamsIndex int) ([]shared.Value, int, error) {
-	if args[streamsIndex].Bulk != "streams" {
-		return nil, 0, fmt.Errorf("ERR wrong number of arguments for 'xread' command")
-	}
-
-	remainingArgs := args[streamsIndex+1:]
-	if len(remainingArgs)%2 != 0 || len(remainingArgs) == 0 {
-		return nil, 0, fmt.Errorf("ERR wrong number of arguments for 'xread' command")
+// parseXreadArguments parses the COUNT and BLOCK options, then the streams keyword, and
+// returns the keys followed by their IDs and the number of keys.
+func parseXreadArguments(args []shared.Value) (xreadOptions, []shared.Value, int, error) {
+	var opts xreadOptions
+	scanner := newArgScanner(args)
+	for {
+		switch scanner.NextOption() {
+		case "COUNT":
+			count, err := scanner.Int64()
+			if err != nil {
+				return opts, nil, 0, err
+			}
+			opts.count = int(max(count, 0))
+		case "BLOCK":
+			timeout, err := scanner.Int64()
+			if err == errNotInteger {
+				return opts, nil, 0, fmt.Errorf("ERR timeout is not an integer or out of range")
+			}
+			if err != nil {
+				return opts, nil, 0, err
+			}
+			if timeout < 0 {
+				return opts, nil, 0, fmt.Errorf("ERR timeout is negative")
+			}
+			opts.blockTimeout = int(timeout)
+			if timeout == 0 {
+				opts.blockTimeout = -1 // Block indefinitely
+			}
+		case "STREAMS":
+			remainingArgs := scanner.Rest()
+			if len(remainingArgs)%2 != 0 || len(remainingArgs) == 0 {
+				return opts, nil, 0, fmt.Errorf("ERR wrong number of arguments for 'xread' command")
+			}
+			return opts, remainingArgs, len(remainingArgs) / 2, nil
+		case "":
+			return opts, nil, 0, fmt.Errorf("ERR wrong number of arguments for 'xread' command")
+		default:
+			return opts, nil, 0, errSyntax
+		}
 	}
-
-	return remainingArgs, len(remainingArgs) / 2, nil
 }
 
 // convertDollarToLastID converts $ to the actual last entry ID for each stream.
@@ -54,7 +67,7 @@ func convertDollarToLastID(remainingArgs []shared.Value, keyCount int) []shared.
 		startID := remainingArgs[i+keyCount].Bulk
 
 		if startID == "$" {
-			if entry, exists := server.Memory[key]; exists && len(entry.Stream) > 0 {
+			if entry, exists := server.Memory.Get(key); exists && len(entry.Stream) > 0 {
 				processedArgs[i+keyCount] = shared.Value{Typ: "bulk", Bulk: entry.Stream[len(entry.Stream)-1].ID}
 			} else {
 				processedArgs[i+keyCount] = shared.Value{Typ: "bulk", Bulk: "0-0"}
@@ -64,15 +77,17 @@ func convertDollarToLastID(remainingArgs []shared.Value, keyCount int) []shared.
 	return processedArgs
 }
 
-// getStreamEntriesAfter returns stream entries newer than the given ID.
+// getStreamEntriesAfter returns stream entries newer than the given ID, at most count of them
+// unless count is 0.
 //
 // Examples:
 //
-//	getStreamEntriesAfter("mystream", "0-0")     // Returns all entries newer than 0-0
-//	getStreamEntriesAfter("mystream", "1526985054069-0")  // Returns entries newer than specific ID
-//	getStreamEntriesAfter("nonexistent", "0-0") // Returns nil (stream doesn't exist)
-func getStreamEntriesAfter(key, startID string) []shared.Value {
-	entry, exists := server.Memory[key]
+//	getStreamEntriesAfter("mystream", "0-0", 0)     // Returns all entries newer than 0-0
+//	getStreamEntriesAfter("mystream", "1526985054069-0", 0)  // Returns entries newer than specific ID
+//	getStreamEntriesAfter("mystream", "0-0", 10)    // Returns the first 10 entries newer than 0-0
+//	getStreamEntriesAfter("nonexistent", "0-0", 0) // Returns nil (stream doesn't exist)
+func getStreamEntriesAfter(key, startID string, count int) []shared.Value {
+	entry, exists := server.Memory.Get(key)
 	if !exists {
 		return nil
 	}
@@ -80,6 +95,9 @@ func getStreamEntriesAfter(key, startID string) []shared.Value {
 	// Pre-allocate slice with estimated capacity
 	result := make([]shared.Value, 0, len(entry.Stream))
 	for _, streamEntry := range entry.Stream {
+		if count > 0 && len(result) == count {
+			break
+		}
 		comparison := compareStreamIDs(streamEntry.ID, startID)
 		if comparison > 0 {
 			result = append(result, createStreamEntryValue(streamEntry))
@@ -100,13 +118,13 @@ func createStreamResponse(key string, entries []shared.Value) shared.Value {
 }
 
 // checkForNewEntries checks for new stream entries across multiple streams.
-func checkForNewEntries(remainingArgs []shared.Value, keyCount int) []shared.Value {
+func checkForNewEntries(remainingArgs []shared.Value, keyCount int, count int) []shared.Value {
 	var result []shared.Value
 	for i := 0; i < keyCount; i++ {
 		key := remainingArgs[i].Bulk
 		startID := remainingArgs[i+keyCount].Bulk
 
-		streamEntries := getStreamEntriesAfter(key, startID)
+		streamEntries := getStreamEntriesAfter(key, startID, count)
 		if len(streamEntries) > 0 {
 			result = append(result, createStreamResponse(key, streamEntries))
 		}
@@ -115,29 +133,28 @@ func checkForNewEntries(remainingArgs []shared.Value, keyCount int) []shared.Val
 }
 
 // blockForNewEntries blocks until new entries are available or timeout occurs.
-func blockForNewEntries(processedArgs []shared.Value, keyCount int, blockTimeout int) shared.Value {
-	checkInterval := 10 * time.Millisecond
-
-	if blockTimeout == -1 {
-		// Block indefinitely
-		for {
-			time.Sleep(checkInterval)
-			if result := checkForNewEntries(processedArgs, keyCount); len(result) > 0 {
-				return shared.Value{Typ: "array", Array: result}
-			}
-		}
+// A client killed meanwhile stops waiting.
+func blockForNewEntries(client *network.Client, processedArgs []shared.Value, keyCount int, opts xreadOptions) shared.Value {
+	keys := make([]string, 0, keyCount)
+	for _, arg := range processedArgs[:keyCount] {
+		keys = append(keys, arg.Bulk)
 	}
 
-	// Block with timeout
-	totalWaitTime := time.Duration(blockTimeout) * time.Millisecond
-	for elapsed := time.Duration(0); elapsed < totalWaitTime; elapsed += checkInterval {
-		time.Sleep(checkInterval)
-		if result := checkForNewEntries(processedArgs, keyCount); len(result) > 0 {
-			return shared.Value{Typ: "array", Array: result}
+	// BLOCK 0, stored as -1, waits forever like a timeout of 0 for BlockOnKeys
+	var timeout time.Duration
+	if opts.blockTimeout > 0 {
+		timeout = time.Duration(opts.blockTimeout) * time.Millisecond
+	}
+	serve := func() (shared.Value, bool) {
+		if result := checkForNewEntries(processedArgs, keyCount, opts.count); len(result) > 0 {
+			return shared.Value{Typ: "array", Array: result}, true
 		}
+		return shared.Value{}, false
 	}
-
-	return shared.Value{Typ: "null_array"}
+	if result, ok := network.BlockOnKeys(client, keys, timeout, serve); ok {
+		return result
+	}
+	return shared.NullArray()
 }
 
 // xread handles the XREAD command for reading from multiple streams.
@@ -148,19 +165,10 @@ func blockForNewEntries(processedArgs []shared.Value, keyCount int, blockTimeout
 //	XREAD streams stream1 stream2 0-0 0-1        // Multiple streams
 //	XREAD BLOCK 1000 streams mystream 0-0       // Blocking with 1000ms timeout
 //	XREAD BLOCK 0 streams mystream $      		// Blocking until new entries are available
-func Xread(connID string, args []shared.Value) shared.Value {
-	if len(args) < 3 {
-		return createErrorResponse("ERR wrong number of arguments for 'xread' command")
-	}
-
-	// Parse BLOCK parameter if present
-	blockTimeout, streamsIndex := parseBlockParameter(args)
-	if streamsIndex == -1 {
-		return createErrorResponse("ERR wrong number of arguments for 'xread' command")
-	}
-
-	// Validate streams keyword and parse arguments
-	remainingArgs, keyCount, err := parseStreamArguments(args, streamsIndex)
+//	XREAD COUNT 10 STREAMS mystream 0-0           // At most 10 entries per stream
+func Xread(client *network.Client, args []shared.Value) shared.Value {
+	// Parse the COUNT and BLOCK options and the streams
+	opts, remainingArgs, keyCount, err := parseXreadArguments(args)
 	if err != nil {
 		return createErrorResponse(err.Error())
 	}
@@ -169,13 +177,13 @@ func Xread(connID string, args []shared.Value) shared.Value {
 	processedArgs := convertDollarToLastID(remainingArgs, keyCount)
 
 	// Check for immediate results
-	if result := checkForNewEntries(processedArgs, keyCount); len(result) > 0 {
+	if result := checkForNewEntries(processedArgs, keyCount, opts.count); len(result) > 0 {
 		return shared.Value{Typ: "array", Array: result}
 	}
 
-	// Handle blocking or return empty array
-	if blockTimeout == 0 {
+	// Handle blocking or return empty array, which is what BLOCK does in a transaction or a script
+	if opts.blockTimeout == 0 || inExecContext(client.ConnID) {
 		return shared.Value{Typ: "array", Array: []shared.Value{}}
 	}
-	return blockForNewEntries(processedArgs, keyCount, blockTimeout)
+	return blockForNewEntries(client, processedArgs, keyCount, opts)
 }
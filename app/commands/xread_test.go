@@ -3,8 +3,9 @@ package commands
 import (
 	"testing"
 
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 func TestXread(t *testing.T) {
@@ -28,18 +29,18 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "0-0"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get entries newer than 0-0
-				result := Xread("test-conn-1", []shared.Value{
+				result := Xread(network.ClientOf("test-conn-1"), []shared.Value{
 					{Typ: "bulk", Bulk: "streams"},
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "0-0"},
@@ -61,19 +62,19 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "1-0"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
 						{ID: "3-0", Data: map[string]string{"message": "Test"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get entries newer than 1-0
-				result := Xread("test-conn-2", []shared.Value{
+				result := Xread(network.ClientOf("test-conn-2"), []shared.Value{
 					{Typ: "bulk", Bulk: "streams"},
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "1-0"},
@@ -97,23 +98,23 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "0-0"},
 			},
 			setup: func() {
-				server.Memory["stream1"] = shared.MemoryEntry{
+				server.Memory.Set("stream1", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 					},
 					Expires: 0,
-				}
-				server.Memory["stream2"] = shared.MemoryEntry{
+				})
+				server.Memory.Set("stream2", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get results from both streams
-				result := Xread("test-conn-3", []shared.Value{
+				result := Xread(network.ClientOf("test-conn-3"), []shared.Value{
 					{Typ: "bulk", Bulk: "streams"},
 					{Typ: "bulk", Bulk: "stream1"},
 					{Typ: "bulk", Bulk: "stream2"},
@@ -134,18 +135,18 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "$"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 						{ID: "2-0", Data: map[string]string{"message": "World"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get no entries (since $ means "after last entry")
-				result := Xread("test-conn-4", []shared.Value{
+				result := Xread(network.ClientOf("test-conn-4"), []shared.Value{
 					{Typ: "bulk", Bulk: "streams"},
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "$"},
@@ -176,10 +177,10 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "0-0"},
 			},
 			setup: func() {
-				server.Memory["emptystream"] = shared.MemoryEntry{
+				server.Memory.Set("emptystream", shared.MemoryEntry{
 					Stream:  []shared.StreamEntry{},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify:   func() {},
@@ -195,17 +196,17 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "0-0"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get immediate results (no blocking needed)
-				result := Xread("test-conn-7", []shared.Value{
+				result := Xread(network.ClientOf("test-conn-7"), []shared.Value{
 					{Typ: "bulk", Bulk: "BLOCK"},
 					{Typ: "bulk", Bulk: "1000"},
 					{Typ: "bulk", Bulk: "streams"},
@@ -228,24 +229,24 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "$"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 					},
 					Expires: 0,
-				}
+				})
 			},
-			expected: shared.Value{Typ: "null_array"},
+			expected: shared.NullArray(),
 			verify: func() {
 				// Verify we get null_array when blocking times out
-				result := Xread("test-conn-8", []shared.Value{
+				result := Xread(network.ClientOf("test-conn-8"), []shared.Value{
 					{Typ: "bulk", Bulk: "BLOCK"},
 					{Typ: "bulk", Bulk: "1000"},
 					{Typ: "bulk", Bulk: "streams"},
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "$"},
 				})
-				if result.Typ != "null_array" {
+				if !result.IsNull() || !result.NullArray {
 					t.Errorf("Expected null_array when blocking times out, got %s", result.Typ)
 				}
 			},
@@ -270,7 +271,7 @@ func TestXread(t *testing.T) {
 				{Typ: "bulk", Bulk: "0-0"},
 			},
 			setup: func() {
-				server.Memory["mystream"] = shared.MemoryEntry{
+				server.Memory.Set("mystream", shared.MemoryEntry{
 					Stream: []shared.StreamEntry{
 						{ID: "1-0", Data: map[string]string{
 							"消息": "你好世界 🌍",
@@ -278,12 +279,12 @@ func TestXread(t *testing.T) {
 						}},
 					},
 					Expires: 0,
-				}
+				})
 			},
 			expected: shared.Value{Typ: "array", Array: []shared.Value{}},
 			verify: func() {
 				// Verify we get the unicode entry
-				result := Xread("test-conn-10", []shared.Value{
+				result := Xread(network.ClientOf("test-conn-10"), []shared.Value{
 					{Typ: "bulk", Bulk: "streams"},
 					{Typ: "bulk", Bulk: "mystream"},
 					{Typ: "bulk", Bulk: "0-0"},
@@ -300,7 +301,7 @@ func TestXread(t *testing.T) {
 			clearMemory()
 			tt.setup()
 
-			result := Xread(tt.connID, tt.args)
+			result := runCommand("XREAD", Xread, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Xread() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -315,9 +316,51 @@ func TestXread(t *testing.T) {
 	}
 }
 
+func TestXreadOptions(t *testing.T) {
+	clearMemory()
+	defer clearMemory()
+	server.Memory.Set("mystream", shared.MemoryEntry{Stream: []shared.StreamEntry{
+		{ID: "1-0", Data: map[string]string{"n": "1"}},
+		{ID: "2-0", Data: map[string]string{"n": "2"}},
+		{ID: "3-0", Data: map[string]string{"n": "3"}},
+	}})
+
+	tests := []struct {
+		name    string
+		args    []shared.Value
+		entries int    // Entries expected for mystream
+		err     string // Expected error, empty on success
+	}{
+		{name: "Uppercase STREAMS", args: aclArgs("STREAMS", "mystream", "0-0"), entries: 3},
+		{name: "Mixed case options", args: aclArgs("Count", "2", "Block", "100", "Streams", "mystream", "0-0"), entries: 2},
+		{name: "COUNT 0 returns everything", args: aclArgs("count", "0", "streams", "mystream", "1-0"), entries: 2},
+		{name: "COUNT without value", args: aclArgs("COUNT"), err: "ERR wrong number of arguments for 'xread' command"},
+		{name: "COUNT not an integer", args: aclArgs("COUNT", "two", "STREAMS", "mystream", "0-0"), err: "ERR value is not an integer or out of range"},
+		{name: "BLOCK not an integer", args: aclArgs("BLOCK", "soon", "STREAMS", "mystream", "0-0"), err: "ERR timeout is not an integer or out of range"},
+		{name: "Negative BLOCK", args: aclArgs("BLOCK", "-1", "STREAMS", "mystream", "0-0"), err: "ERR timeout is negative"},
+		{name: "Unknown option", args: aclArgs("NOACK", "STREAMS", "mystream", "0-0"), err: "ERR syntax error"},
+		{name: "Unbalanced streams", args: aclArgs("STREAMS", "mystream", "other", "0-0"), err: "ERR wrong number of arguments for 'xread' command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCommand("XREAD", Xread, "test-conn", tt.args)
+			if tt.err != "" {
+				if result.Typ != "error" || result.Str != tt.err {
+					t.Errorf("Expected error %q, got %+v", tt.err, result)
+				}
+				return
+			}
+			if len(result.Array) != 1 || len(result.Array[0].Array) != 2 || len(result.Array[0].Array[1].Array) != tt.entries {
+				t.Errorf("Expected %d entries, got %+v", tt.entries, result)
+			}
+		})
+	}
+}
+
 func BenchmarkXread(b *testing.B) {
 	clearMemory()
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 			{ID: "2-0", Data: map[string]string{"message": "World"}},
@@ -326,7 +369,7 @@ func BenchmarkXread(b *testing.B) {
 			{ID: "5-0", Data: map[string]string{"message": "More"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -337,26 +380,26 @@ func BenchmarkXread(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xread(connID, args)
+		Xread(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkXreadMultipleStreams(b *testing.B) {
 	clearMemory()
-	server.Memory["stream1"] = shared.MemoryEntry{
+	server.Memory.Set("stream1", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 			{ID: "2-0", Data: map[string]string{"message": "World"}},
 		},
 		Expires: 0,
-	}
-	server.Memory["stream2"] = shared.MemoryEntry{
+	})
+	server.Memory.Set("stream2", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "Test"}},
 			{ID: "2-0", Data: map[string]string{"message": "Extra"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -369,19 +412,19 @@ func BenchmarkXreadMultipleStreams(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xread(connID, args)
+		Xread(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkXreadWithDollar(b *testing.B) {
 	clearMemory()
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 			{ID: "2-0", Data: map[string]string{"message": "World"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -392,7 +435,7 @@ func BenchmarkXreadWithDollar(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xread(connID, args)
+		Xread(network.ClientOf(connID), args)
 	}
 }
 
@@ -408,18 +451,18 @@ func BenchmarkXreadNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xread(connID, args)
+		Xread(network.ClientOf(connID), args)
 	}
 }
 
 func BenchmarkXreadWithBlocking(b *testing.B) {
 	clearMemory()
-	server.Memory["benchstream"] = shared.MemoryEntry{
+	server.Memory.Set("benchstream", shared.MemoryEntry{
 		Stream: []shared.StreamEntry{
 			{ID: "1-0", Data: map[string]string{"message": "Hello"}},
 		},
 		Expires: 0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -432,6 +475,6 @@ func BenchmarkXreadWithBlocking(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Xread(connID, args)
+		Xread(network.ClientOf(connID), args)
 	}
 }
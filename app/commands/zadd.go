@@ -3,9 +3,11 @@ package commands
 import (
 	"strconv"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 // zadd handles the ZADD command.
@@ -20,49 +22,56 @@ import (
 //	ZADD myzset 1 "one"                        // Adds one element to the sorted set
 //	ZADD myzset 1 "one" 2 "two"                // Adds two elements to the sorted set
 //	ZADD myzset 1 "one" 2 "two" 3 "three"      // Adds three elements to the sorted set
-func Zadd(connID string, args []protocol.Value) protocol.Value {
-	if len(args) < 3 {
-		return createErrorResponse("ERR wrong number of arguments for 'zadd' command")
-	}
-
+func Zadd(client *network.Client, args []protocol.Value) protocol.Value {
 	// Check if we have an even number of score-member pairs (excluding the key)
 	if (len(args)-1)%2 != 0 {
-		return createErrorResponse("ERR wrong number of arguments for 'zadd' command")
-	}
-
-	key := args[0].Bulk
-	entry, exists := server.Memory[key]
-
-	if !exists {
-		entry = shared.MemoryEntry{SortedSet: shared.NewSortedSet(), Expires: 0}
+		return shared.ErrWrongArity("zadd")
 	}
 
-	// Ensure the entry has a sorted set
-	if entry.SortedSet == nil {
-		entry.SortedSet = shared.NewSortedSet()
+	// Parse every score first, so nothing is added when one of them is invalid
+	scores := make([]float64, 0, (len(args)-1)/2)
+	for i := 1; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(args[i].Bulk, 64)
+		if err != nil {
+			return shared.ErrNotFloat()
+		}
+		scores = append(scores, score)
 	}
 
+	key := args[0].Bulk
 	newElementsCount := 0
+	changedCount := 0
 
-	// Process score-member pairs
-	for i := 1; i < len(args); i += 2 {
-		scoreStr := args[i].Bulk
-		member := args[i+1].Bulk
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		storage.CopyOnWrite(key)
+		if !exists {
+			entry = shared.MemoryEntry{SortedSet: shared.NewSortedSet(), Expires: 0}
+		}
 
-		// Parse score
-		score, err := strconv.ParseFloat(scoreStr, 64)
-		if err != nil {
-			return createErrorResponse("ERR value is not a valid float")
+		// Ensure the entry has a sorted set
+		if entry.SortedSet == nil {
+			entry.SortedSet = shared.NewSortedSet()
 		}
 
-		// Add member to sorted set
-		if entry.SortedSet.Add(member, score) {
-			newElementsCount++
+		// Process score-member pairs
+		for i, score := range scores {
+			member := args[2*i+2].Bulk
+
+			// Add member to sorted set, tracking whether anything actually changed
+			oldScore, existed := entry.SortedSet.GetScore(member)
+			if entry.SortedSet.Add(member, score) {
+				newElementsCount++
+			}
+			if !existed || oldScore != score {
+				changedCount++
+			}
 		}
+		return entry, true
+	})
+	if changedCount > 0 {
+		server.NotifyKeyModified(0, key, "zadd")
 	}
-
-	// Update the entry in memory
-	server.Memory[key] = entry
+	client.MarkDirty(changedCount)
 
 	return shared.Value{Typ: "integer", Num: newElementsCount}
 }
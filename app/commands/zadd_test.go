@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -28,7 +30,7 @@ func TestZadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist after ZADD")
 				}
@@ -61,7 +63,7 @@ func TestZadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 3},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist after ZADD")
 				}
@@ -95,7 +97,7 @@ func TestZadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 0}, // 0 because it's an update, not new
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist after ZADD")
 				}
@@ -121,7 +123,7 @@ func TestZadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["precision"]
+				entry, exists := server.Memory.Get("precision")
 				if !exists {
 					t.Error("Key should exist after ZADD")
 				}
@@ -145,7 +147,7 @@ func TestZadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["negative"]
+				entry, exists := server.Memory.Get("negative")
 				if !exists {
 					t.Error("Key should exist after ZADD")
 				}
@@ -170,7 +172,7 @@ func TestZadd(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 2},
 			verify: func() {
-				entry, exists := server.Memory["unicode"]
+				entry, exists := server.Memory.Get("unicode")
 				if !exists {
 					t.Error("Key should exist after ZADD")
 				}
@@ -229,14 +231,14 @@ func TestZadd(t *testing.T) {
 
 			// Set up initial data for update test
 			if tt.name == "zadd update existing member" {
-				server.Memory["myzset"] = shared.MemoryEntry{
+				server.Memory.Set("myzset", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["myzset"].SortedSet.Add("existing", 1.0)
+				})
+				getEntry("myzset").SortedSet.Add("existing", 1.0)
 			}
 
-			result := Zadd(tt.connID, tt.args)
+			result := runCommand("ZADD", Zadd, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Zadd() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -255,6 +257,52 @@ func TestZadd(t *testing.T) {
 	}
 }
 
+func TestZaddEncoding(t *testing.T) {
+	clearMemory()
+	shared.SetEncodingLimits(shared.EncodingLimits{ListMaxListpackSize: -2, ZsetMaxListpackEntries: 3, ZsetMaxListpackValue: 8})
+	defer shared.SetEncodingLimits(shared.DefaultEncodingLimits)
+
+	zadd := func(key string, pairs ...string) {
+		args := []shared.Value{{Typ: "bulk", Bulk: key}}
+		for _, arg := range pairs {
+			args = append(args, shared.Value{Typ: "bulk", Bulk: arg})
+		}
+		Zadd(network.ClientOf("test-conn"), args)
+	}
+
+	// The same members must read the same whether the set is packed or not
+	zadd("packed", "3", "c", "1", "a", "2", "b")
+	zadd("packed", "0", "c")
+	zadd("full", "3", "c", "1", "a", "2", "b", "4", "d")
+	zadd("full", "0", "c")
+	Zrem(network.ClientOf("test-conn"), []shared.Value{{Typ: "bulk", Bulk: "full"}, {Typ: "bulk", Bulk: "d"}})
+
+	if ss := getEntry("packed").SortedSet; !ss.Packed() {
+		t.Errorf("Expected a set of 3 members to stay packed")
+	}
+	if ss := getEntry("full").SortedSet; ss.Packed() {
+		t.Errorf("Expected a set grown past 3 members to be converted")
+	}
+	for _, key := range []string{"packed", "full"} {
+		ss := getEntry(key).SortedSet
+		if members := ss.GetSortedMembers(); !reflect.DeepEqual(members, []string{"c", "a", "b"}) || ss.Size != 3 {
+			t.Errorf("%s: expected [c a b], got %v with size %d", key, members, ss.Size)
+		}
+		if rank, ok := ss.GetRank("b"); !ok || rank != 2 {
+			t.Errorf("%s: expected b at rank 2, got %d", key, rank)
+		}
+		if score, ok := ss.GetScore("c"); !ok || score != 0 {
+			t.Errorf("%s: expected c to score 0, got %v", key, score)
+		}
+	}
+
+	// A member longer than zset-max-listpack-value converts the set
+	zadd("long", "1", "a-long-member")
+	if ss := getEntry("long").SortedSet; ss.Packed() {
+		t.Errorf("Expected a long member to convert the set")
+	}
+}
+
 func BenchmarkZadd(b *testing.B) {
 	clearMemory()
 
@@ -267,7 +315,7 @@ func BenchmarkZadd(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zadd(connID, args)
+		Zadd(network.ClientOf(connID), args)
 	}
 }
 
@@ -287,7 +335,7 @@ func BenchmarkZaddMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zadd(connID, args)
+		Zadd(network.ClientOf(connID), args)
 	}
 }
 
@@ -303,7 +351,7 @@ func BenchmarkZaddHighPrecision(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zadd(connID, args)
+		Zadd(network.ClientOf(connID), args)
 	}
 }
 
@@ -319,6 +367,6 @@ func BenchmarkZaddUnicode(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zadd(connID, args)
+		Zadd(network.ClientOf(connID), args)
 	}
 }
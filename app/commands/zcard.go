@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -18,20 +19,16 @@ import (
 //	ZCARD myzset                    // Returns the number of elements in myzset
 //	ZCARD nonexistent              // Returns 0 (key doesn't exist)
 //	ZCARD mystring                 // Returns error (wrong type)
-func Zcard(connID string, args []shared.Value) shared.Value {
-	if len(args) != 1 {
-		return createErrorResponse("ERR wrong number of arguments for 'zcard' command")
-	}
-
+func Zcard(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	if !exists {
 		return shared.Value{Typ: "integer", Num: 0}
 	}
 
 	if entry.SortedSet == nil {
-		return createErrorResponse("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return shared.ErrWrongType()
 	}
 
 	return shared.Value{Typ: "integer", Num: entry.SortedSet.Size}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -27,7 +28,7 @@ func TestZcard(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 3},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -44,7 +45,7 @@ func TestZcard(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 0},
 			verify: func() {
-				entry, exists := server.Memory["empty"]
+				entry, exists := server.Memory.Get("empty")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -61,7 +62,7 @@ func TestZcard(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["single"]
+				entry, exists := server.Memory.Get("single")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -78,7 +79,7 @@ func TestZcard(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 100},
 			verify: func() {
-				entry, exists := server.Memory["large"]
+				entry, exists := server.Memory.Get("large")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -113,7 +114,7 @@ func TestZcard(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 2},
 			verify: func() {
-				entry, exists := server.Memory["unicode"]
+				entry, exists := server.Memory.Get("unicode")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -130,7 +131,7 @@ func TestZcard(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 2},
 			verify: func() {
-				entry, exists := server.Memory["dynamic"]
+				entry, exists := server.Memory.Get("dynamic")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -147,7 +148,7 @@ func TestZcard(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["reduced"]
+				entry, exists := server.Memory.Get("reduced")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -182,65 +183,65 @@ func TestZcard(t *testing.T) {
 			// Set up test data
 			switch tt.name {
 			case "zcard non-empty set":
-				server.Memory["myzset"] = shared.MemoryEntry{
+				server.Memory.Set("myzset", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["myzset"].SortedSet.Add("member1", 1.0)
-				server.Memory["myzset"].SortedSet.Add("member2", 2.0)
-				server.Memory["myzset"].SortedSet.Add("member3", 3.0)
+				})
+				getEntry("myzset").SortedSet.Add("member1", 1.0)
+				getEntry("myzset").SortedSet.Add("member2", 2.0)
+				getEntry("myzset").SortedSet.Add("member3", 3.0)
 			case "zcard empty set":
-				server.Memory["empty"] = shared.MemoryEntry{
+				server.Memory.Set("empty", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 			case "zcard single member":
-				server.Memory["single"] = shared.MemoryEntry{
+				server.Memory.Set("single", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["single"].SortedSet.Add("member1", 1.0)
+				})
+				getEntry("single").SortedSet.Add("member1", 1.0)
 			case "zcard large set":
-				server.Memory["large"] = shared.MemoryEntry{
+				server.Memory.Set("large", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 				for i := 0; i < 100; i++ {
-					server.Memory["large"].SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
+					getEntry("large").SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
 				}
 			case "zcard key with wrong type":
-				server.Memory["wrongtype"] = shared.MemoryEntry{
+				server.Memory.Set("wrongtype", shared.MemoryEntry{
 					Value:   "string value",
 					Expires: 0,
-				}
+				})
 			case "zcard unicode members":
-				server.Memory["unicode"] = shared.MemoryEntry{
+				server.Memory.Set("unicode", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["unicode"].SortedSet.Add("成员1", 1.0)
-				server.Memory["unicode"].SortedSet.Add("成员2", 2.0)
+				})
+				getEntry("unicode").SortedSet.Add("成员1", 1.0)
+				getEntry("unicode").SortedSet.Add("成员2", 2.0)
 			case "zcard after adding members":
-				server.Memory["dynamic"] = shared.MemoryEntry{
+				server.Memory.Set("dynamic", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["dynamic"].SortedSet.Add("member1", 1.0)
-				server.Memory["dynamic"].SortedSet.Add("member2", 2.0)
+				})
+				getEntry("dynamic").SortedSet.Add("member1", 1.0)
+				getEntry("dynamic").SortedSet.Add("member2", 2.0)
 			case "zcard after removing members":
-				server.Memory["reduced"] = shared.MemoryEntry{
+				server.Memory.Set("reduced", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["reduced"].SortedSet.Add("member1", 1.0)
-				server.Memory["reduced"].SortedSet.Add("member2", 2.0)
-				server.Memory["reduced"].SortedSet.Add("member3", 3.0)
+				})
+				getEntry("reduced").SortedSet.Add("member1", 1.0)
+				getEntry("reduced").SortedSet.Add("member2", 2.0)
+				getEntry("reduced").SortedSet.Add("member3", 3.0)
 				// Remove one member
-				server.Memory["reduced"].SortedSet.Remove("member2")
-				server.Memory["reduced"].SortedSet.Remove("member3")
+				getEntry("reduced").SortedSet.Remove("member2")
+				getEntry("reduced").SortedSet.Remove("member3")
 			}
 
-			result := Zcard(tt.connID, tt.args)
+			result := runCommand("ZCARD", Zcard, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Zcard() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -263,13 +264,13 @@ func BenchmarkZcard(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-	server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
-	server.Memory["benchkey"].SortedSet.Add("member3", 3.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
+	getEntry("benchkey").SortedSet.Add("member2", 2.0)
+	getEntry("benchkey").SortedSet.Add("member3", 3.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -278,7 +279,7 @@ func BenchmarkZcard(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zcard(connID, args)
+		Zcard(network.ClientOf(connID), args)
 	}
 }
 
@@ -286,12 +287,12 @@ func BenchmarkZcardLargeSet(b *testing.B) {
 	clearMemory()
 
 	// Set up large test data
-	server.Memory["largekey"] = shared.MemoryEntry{
+	server.Memory.Set("largekey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
+	})
 	for i := 0; i < 1000; i++ {
-		server.Memory["largekey"].SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
+		getEntry("largekey").SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
 	}
 
 	connID := "benchmark-conn"
@@ -301,7 +302,7 @@ func BenchmarkZcardLargeSet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zcard(connID, args)
+		Zcard(network.ClientOf(connID), args)
 	}
 }
 
@@ -309,10 +310,10 @@ func BenchmarkZcardEmptySet(b *testing.B) {
 	clearMemory()
 
 	// Set up empty test data
-	server.Memory["emptykey"] = shared.MemoryEntry{
+	server.Memory.Set("emptykey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
+	})
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -321,7 +322,7 @@ func BenchmarkZcardEmptySet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zcard(connID, args)
+		Zcard(network.ClientOf(connID), args)
 	}
 }
 
@@ -335,6 +336,6 @@ func BenchmarkZcardNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zcard(connID, args)
+		Zcard(network.ClientOf(connID), args)
 	}
 }
@@ -1,10 +1,10 @@
 package commands
 
 import (
-	"sort"
 	"strconv"
 	"sync"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
@@ -43,29 +43,13 @@ func getSortedRange(ss *shared.SortedSet, start, stop int) []string {
 		stop = ss.Size - 1
 	}
 
-	// Create slice of members with scores for efficient sorting
-	type memberScore struct {
-		member string
-		score  float64
-	}
-
-	members := make([]memberScore, 0, len(ss.Members))
-	for m, s := range ss.Members {
-		members = append(members, memberScore{m, s})
-	}
-
-	// Use Go's efficient sort instead of bubble sort
-	sort.Slice(members, func(i, j int) bool {
-		if members[i].score != members[j].score {
-			return members[i].score < members[j].score
-		}
-		return members[i].member < members[j].member
-	})
+	// Packed sets are kept in order, the others are sorted here
+	members := ss.Sorted()
 
 	// Extract only the requested range
 	result := make([]string, 0, stop-start+1)
 	for i := start; i <= stop; i++ {
-		result = append(result, members[i].member)
+		result = append(result, members[i].Member)
 	}
 
 	return result
@@ -90,13 +74,9 @@ func getSortedRange(ss *shared.SortedSet, start, stop int) []string {
 //	ZRANGE myzset 0 2      // Returns elements at index 0, 1, and 2
 //	ZRANGE myzset 0 -1     // Returns all elements
 //	ZRANGE myzset -3 -1    // Returns last 3 elements
-func Zrange(connID string, args []protocol.Value) protocol.Value {
-	if len(args) < 3 {
-		return createErrorResponse("ERR wrong number of arguments for 'zrange' command")
-	}
-
+func Zrange(client *network.Client, args []protocol.Value) protocol.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	if !exists {
 		return shared.Value{Typ: "array", Array: []shared.Value{}}
@@ -108,11 +88,11 @@ func Zrange(connID string, args []protocol.Value) protocol.Value {
 
 	start, err := strconv.Atoi(args[1].Bulk)
 	if err != nil {
-		return createErrorResponse("ERR value is not an integer or out of range")
+		return shared.ErrNotInteger()
 	}
 	stop, err := strconv.Atoi(args[2].Bulk)
 	if err != nil {
-		return createErrorResponse("ERR value is not an integer or out of range")
+		return shared.ErrNotInteger()
 	}
 
 	// Handle negative indices
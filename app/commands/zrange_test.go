@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -36,7 +37,7 @@ func TestZrange(t *testing.T) {
 				},
 			},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -274,46 +275,46 @@ func TestZrange(t *testing.T) {
 			// Set up test data
 			switch tt.name {
 			case "zrange all elements", "zrange first element", "zrange middle elements", "zrange last element", "zrange last two elements", "zrange start > stop", "zrange start out of bounds", "zrange stop out of bounds":
-				server.Memory["myzset"] = shared.MemoryEntry{
+				server.Memory.Set("myzset", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["myzset"].SortedSet.Add("member1", 1.0)
-				server.Memory["myzset"].SortedSet.Add("member2", 2.0)
-				server.Memory["myzset"].SortedSet.Add("member3", 3.0)
+				})
+				getEntry("myzset").SortedSet.Add("member1", 1.0)
+				getEntry("myzset").SortedSet.Add("member2", 2.0)
+				getEntry("myzset").SortedSet.Add("member3", 3.0)
 			case "zrange with same score (alphabetical order)":
-				server.Memory["same_score"] = shared.MemoryEntry{
+				server.Memory.Set("same_score", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["same_score"].SortedSet.Add("grape", 1.0)
-				server.Memory["same_score"].SortedSet.Add("pineapple", 1.0)
+				})
+				getEntry("same_score").SortedSet.Add("grape", 1.0)
+				getEntry("same_score").SortedSet.Add("pineapple", 1.0)
 			case "zrange empty set":
-				server.Memory["empty"] = shared.MemoryEntry{
+				server.Memory.Set("empty", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 			case "zrange key with wrong type":
-				server.Memory["wrongtype"] = shared.MemoryEntry{
+				server.Memory.Set("wrongtype", shared.MemoryEntry{
 					Value:   "string value",
 					Expires: 0,
-				}
+				})
 			case "zrange unicode members":
-				server.Memory["unicode"] = shared.MemoryEntry{
+				server.Memory.Set("unicode", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["unicode"].SortedSet.Add("成员1", 1.0)
-				server.Memory["unicode"].SortedSet.Add("成员2", 2.0)
+				})
+				getEntry("unicode").SortedSet.Add("成员1", 1.0)
+				getEntry("unicode").SortedSet.Add("成员2", 2.0)
 			case "zrange invalid start", "zrange invalid stop":
-				server.Memory["key"] = shared.MemoryEntry{
+				server.Memory.Set("key", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["key"].SortedSet.Add("member1", 1.0)
+				})
+				getEntry("key").SortedSet.Add("member1", 1.0)
 			}
 
-			result := Zrange(tt.connID, tt.args)
+			result := runCommand("ZRANGE", Zrange, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Zrange() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -343,13 +344,13 @@ func BenchmarkZrange(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-	server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
-	server.Memory["benchkey"].SortedSet.Add("member3", 3.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
+	getEntry("benchkey").SortedSet.Add("member2", 2.0)
+	getEntry("benchkey").SortedSet.Add("member3", 3.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -360,7 +361,7 @@ func BenchmarkZrange(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrange(connID, args)
+		Zrange(network.ClientOf(connID), args)
 	}
 }
 
@@ -368,12 +369,12 @@ func BenchmarkZrangeLargeSet(b *testing.B) {
 	clearMemory()
 
 	// Set up large test data
-	server.Memory["largekey"] = shared.MemoryEntry{
+	server.Memory.Set("largekey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
+	})
 	for i := 0; i < 1000; i++ {
-		server.Memory["largekey"].SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
+		getEntry("largekey").SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
 	}
 
 	connID := "benchmark-conn"
@@ -385,7 +386,7 @@ func BenchmarkZrangeLargeSet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrange(connID, args)
+		Zrange(network.ClientOf(connID), args)
 	}
 }
 
@@ -393,13 +394,13 @@ func BenchmarkZrangeSingleElement(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-	server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
-	server.Memory["benchkey"].SortedSet.Add("member3", 3.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
+	getEntry("benchkey").SortedSet.Add("member2", 2.0)
+	getEntry("benchkey").SortedSet.Add("member3", 3.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -410,6 +411,6 @@ func BenchmarkZrangeSingleElement(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrange(connID, args)
+		Zrange(network.ClientOf(connID), args)
 	}
 }
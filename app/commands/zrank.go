@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
@@ -21,26 +22,26 @@ import (
 //	ZRANK nonexistent "member"         // Returns null (key doesn't exist)
 //	ZRANK myzset "member"              // Returns null (member doesn't exist)
 //	ZRANK myzset "member"              // Returns null (key doesn't hold a sorted set)
-func Zrank(connID string, args []protocol.Value) protocol.Value {
-	if len(args) != 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'zrank' command")
+func Zrank(client *network.Client, args []protocol.Value) protocol.Value {
+	if len(args) > 2 {
+		return shared.ErrWrongArity("zrank")
 	}
 
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	if !exists {
-		return shared.Value{Typ: "null", Str: ""}
+		return shared.Null()
 	}
 
 	if entry.SortedSet == nil {
-		return shared.Value{Typ: "null", Str: ""}
+		return shared.Null()
 	}
 
 	member := args[1].Bulk
 	rank, exists := entry.SortedSet.GetRank(member)
 	if !exists {
-		return shared.Value{Typ: "null", Str: ""}
+		return shared.Null()
 	}
 
 	return shared.Value{Typ: "integer", Num: rank}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -29,7 +30,7 @@ func TestZrank(t *testing.T) {
 			expected: shared.Value{Typ: "integer", Num: 0}, // First member has rank 0
 			verify: func() {
 				// Verify the member exists and has correct rank
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -51,7 +52,7 @@ func TestZrank(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 2}, // Third member has rank 2
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -73,7 +74,7 @@ func TestZrank(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 0}, // grape comes before pineapple alphabetically
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -93,7 +94,7 @@ func TestZrank(t *testing.T) {
 				{Typ: "bulk", Bulk: "myzset"},
 				{Typ: "bulk", Bulk: "nonexistent"},
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -103,7 +104,7 @@ func TestZrank(t *testing.T) {
 				{Typ: "bulk", Bulk: "nonexistent"},
 				{Typ: "bulk", Bulk: "member"},
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -113,7 +114,7 @@ func TestZrank(t *testing.T) {
 				{Typ: "bulk", Bulk: "wrongtype"},
 				{Typ: "bulk", Bulk: "member"},
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -125,7 +126,7 @@ func TestZrank(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 0},
 			verify: func() {
-				entry, exists := server.Memory["unicode"]
+				entry, exists := server.Memory.Get("unicode")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -167,35 +168,35 @@ func TestZrank(t *testing.T) {
 			// Set up test data
 			switch tt.name {
 			case "zrank existing member", "zrank member with higher score":
-				server.Memory["myzset"] = shared.MemoryEntry{
+				server.Memory.Set("myzset", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["myzset"].SortedSet.Add("member1", 1.0)
-				server.Memory["myzset"].SortedSet.Add("member2", 2.0)
-				server.Memory["myzset"].SortedSet.Add("member3", 3.0)
+				})
+				getEntry("myzset").SortedSet.Add("member1", 1.0)
+				getEntry("myzset").SortedSet.Add("member2", 2.0)
+				getEntry("myzset").SortedSet.Add("member3", 3.0)
 			case "zrank member with same score (alphabetical order)":
-				server.Memory["myzset"] = shared.MemoryEntry{
+				server.Memory.Set("myzset", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["myzset"].SortedSet.Add("grape", 1.0)
-				server.Memory["myzset"].SortedSet.Add("pineapple", 1.0)
+				})
+				getEntry("myzset").SortedSet.Add("grape", 1.0)
+				getEntry("myzset").SortedSet.Add("pineapple", 1.0)
 			case "zrank unicode member":
-				server.Memory["unicode"] = shared.MemoryEntry{
+				server.Memory.Set("unicode", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["unicode"].SortedSet.Add("成员1", 1.0)
-				server.Memory["unicode"].SortedSet.Add("成员2", 2.0)
+				})
+				getEntry("unicode").SortedSet.Add("成员1", 1.0)
+				getEntry("unicode").SortedSet.Add("成员2", 2.0)
 			case "zrank key with wrong type":
-				server.Memory["wrongtype"] = shared.MemoryEntry{
+				server.Memory.Set("wrongtype", shared.MemoryEntry{
 					Value:   "string value",
 					Expires: 0,
-				}
+				})
 			}
 
-			result := Zrank(tt.connID, tt.args)
+			result := runCommand("ZRANK", Zrank, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Zrank() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -218,13 +219,13 @@ func BenchmarkZrank(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-	server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
-	server.Memory["benchkey"].SortedSet.Add("member3", 3.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
+	getEntry("benchkey").SortedSet.Add("member2", 2.0)
+	getEntry("benchkey").SortedSet.Add("member3", 3.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -234,7 +235,7 @@ func BenchmarkZrank(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrank(connID, args)
+		Zrank(network.ClientOf(connID), args)
 	}
 }
 
@@ -242,12 +243,12 @@ func BenchmarkZrankLargeSet(b *testing.B) {
 	clearMemory()
 
 	// Set up large test data
-	server.Memory["largekey"] = shared.MemoryEntry{
+	server.Memory.Set("largekey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
+	})
 	for i := 0; i < 1000; i++ {
-		server.Memory["largekey"].SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
+		getEntry("largekey").SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
 	}
 
 	connID := "benchmark-conn"
@@ -258,7 +259,7 @@ func BenchmarkZrankLargeSet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrank(connID, args)
+		Zrank(network.ClientOf(connID), args)
 	}
 }
 
@@ -266,11 +267,11 @@ func BenchmarkZrankNonExistent(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -280,6 +281,6 @@ func BenchmarkZrankNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrank(connID, args)
+		Zrank(network.ClientOf(connID), args)
 	}
 }
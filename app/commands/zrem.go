@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 // zrem handles the ZREM command.
@@ -19,29 +21,36 @@ import (
 //	ZREM myzset "one" "two"           // Removes two elements from myzset
 //	ZREM nonexistent "member"         // Returns 0 (key doesn't exist)
 //	ZREM mystring "member"            // Returns error (wrong type)
-func Zrem(connID string, args []shared.Value) shared.Value {
-	if len(args) < 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'zrem' command")
-	}
-
+func Zrem(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
-
-	if !exists {
-		return shared.Value{Typ: "integer", Num: 0}
-	}
-
-	if entry.SortedSet == nil {
-		return createErrorResponse("WRONGTYPE Operation against a key holding the wrong kind of value")
-	}
-
 	removedCount := 0
-	for i := 1; i < len(args); i++ {
-		member := args[i].Bulk
-		if entry.SortedSet.Remove(member) {
-			removedCount++
+	wrongType := false
+
+	// The members are removed in place, under the lock of the key
+	server.Memory.Update(key, func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		storage.CopyOnWrite(key)
+		if !exists {
+			return entry, false
+		}
+		if entry.SortedSet == nil {
+			wrongType = true
+			return entry, false
 		}
+		for i := 1; i < len(args); i++ {
+			member := args[i].Bulk
+			if entry.SortedSet.Remove(member) {
+				removedCount++
+			}
+		}
+		return entry, false
+	})
+	if wrongType {
+		return shared.ErrWrongType()
+	}
+	if removedCount > 0 {
+		server.NotifyKeyModified(0, key, "zrem")
 	}
+	client.MarkDirty(removedCount)
 
 	return shared.Value{Typ: "integer", Num: removedCount}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -28,7 +29,7 @@ func TestZrem(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should still exist")
 				}
@@ -58,7 +59,7 @@ func TestZrem(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 2},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should still exist")
 				}
@@ -87,7 +88,7 @@ func TestZrem(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 0},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should still exist")
 				}
@@ -107,7 +108,7 @@ func TestZrem(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 2}, // Only existing members removed
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should still exist")
 				}
@@ -138,7 +139,7 @@ func TestZrem(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 3},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should still exist")
 				}
@@ -176,7 +177,7 @@ func TestZrem(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 1},
 			verify: func() {
-				entry, exists := server.Memory["unicode"]
+				entry, exists := server.Memory.Get("unicode")
 				if !exists {
 					t.Error("Key should still exist")
 				}
@@ -202,7 +203,7 @@ func TestZrem(t *testing.T) {
 			},
 			expected: shared.Value{Typ: "integer", Num: 0},
 			verify: func() {
-				entry, exists := server.Memory["empty"]
+				entry, exists := server.Memory.Get("empty")
 				if !exists {
 					t.Error("Key should still exist")
 				}
@@ -229,33 +230,33 @@ func TestZrem(t *testing.T) {
 			// Set up test data
 			switch tt.name {
 			case "zrem single existing member", "zrem multiple existing members", "zrem non-existent member", "zrem mix of existing and non-existent members", "zrem all members":
-				server.Memory["myzset"] = shared.MemoryEntry{
+				server.Memory.Set("myzset", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["myzset"].SortedSet.Add("member1", 1.0)
-				server.Memory["myzset"].SortedSet.Add("member2", 2.0)
-				server.Memory["myzset"].SortedSet.Add("member3", 3.0)
+				})
+				getEntry("myzset").SortedSet.Add("member1", 1.0)
+				getEntry("myzset").SortedSet.Add("member2", 2.0)
+				getEntry("myzset").SortedSet.Add("member3", 3.0)
 			case "zrem from key with wrong type":
-				server.Memory["wrongtype"] = shared.MemoryEntry{
+				server.Memory.Set("wrongtype", shared.MemoryEntry{
 					Value:   "string value",
 					Expires: 0,
-				}
+				})
 			case "zrem unicode members":
-				server.Memory["unicode"] = shared.MemoryEntry{
+				server.Memory.Set("unicode", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["unicode"].SortedSet.Add("成员1", 1.0)
-				server.Memory["unicode"].SortedSet.Add("成员2", 2.0)
+				})
+				getEntry("unicode").SortedSet.Add("成员1", 1.0)
+				getEntry("unicode").SortedSet.Add("成员2", 2.0)
 			case "zrem from empty set":
-				server.Memory["empty"] = shared.MemoryEntry{
+				server.Memory.Set("empty", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 			}
 
-			result := Zrem(tt.connID, tt.args)
+			result := runCommand("ZREM", Zrem, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Zrem() type = %v, expected %v", result.Typ, tt.expected.Typ)
@@ -278,13 +279,13 @@ func BenchmarkZrem(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-	server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
-	server.Memory["benchkey"].SortedSet.Add("member3", 3.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
+	getEntry("benchkey").SortedSet.Add("member2", 2.0)
+	getEntry("benchkey").SortedSet.Add("member3", 3.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -294,9 +295,9 @@ func BenchmarkZrem(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrem(connID, args)
+		Zrem(network.ClientOf(connID), args)
 		// Re-add the member for next iteration
-		server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
+		getEntry("benchkey").SortedSet.Add("member2", 2.0)
 	}
 }
 
@@ -304,13 +305,13 @@ func BenchmarkZremMultiple(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-	server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
-	server.Memory["benchkey"].SortedSet.Add("member3", 3.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
+	getEntry("benchkey").SortedSet.Add("member2", 2.0)
+	getEntry("benchkey").SortedSet.Add("member3", 3.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -321,10 +322,10 @@ func BenchmarkZremMultiple(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrem(connID, args)
+		Zrem(network.ClientOf(connID), args)
 		// Re-add the members for next iteration
-		server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-		server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
+		getEntry("benchkey").SortedSet.Add("member1", 1.0)
+		getEntry("benchkey").SortedSet.Add("member2", 2.0)
 	}
 }
 
@@ -332,12 +333,12 @@ func BenchmarkZremLargeSet(b *testing.B) {
 	clearMemory()
 
 	// Set up large test data
-	server.Memory["largekey"] = shared.MemoryEntry{
+	server.Memory.Set("largekey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
+	})
 	for i := 0; i < 1000; i++ {
-		server.Memory["largekey"].SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
+		getEntry("largekey").SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
 	}
 
 	connID := "benchmark-conn"
@@ -348,9 +349,9 @@ func BenchmarkZremLargeSet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrem(connID, args)
+		Zrem(network.ClientOf(connID), args)
 		// Re-add the member for next iteration
-		server.Memory["largekey"].SortedSet.Add("member500", 500.0)
+		getEntry("largekey").SortedSet.Add("member500", 500.0)
 	}
 }
 
@@ -358,11 +359,11 @@ func BenchmarkZremNonExistent(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -372,6 +373,6 @@ func BenchmarkZremNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zrem(connID, args)
+		Zrem(network.ClientOf(connID), args)
 	}
 }
@@ -1,15 +1,14 @@
 package commands
 
 import (
-	"fmt"
-
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
 
 // zscore handles the ZSCORE command.
 // Usage: ZSCORE key member
-// Returns: The score of the member in the sorted set, or null if the member does not exist.
+// Returns: The score of the member in the sorted set as a double, or null if the member does not exist.
 //
 // This command returns the score of a member in a sorted set.
 // If the member does not exist in the sorted set, null is returned.
@@ -22,30 +21,21 @@ import (
 //	ZSCORE nonexistent "member"         // Returns null (key doesn't exist)
 //	ZSCORE myzset "member"              // Returns null (member doesn't exist)
 //	ZSCORE myzset "member"              // Returns null (key doesn't hold a sorted set)
-func Zscore(connID string, args []shared.Value) shared.Value {
-	if len(args) != 2 {
-		return createErrorResponse("ERR wrong number of arguments for 'zscore' command")
-	}
-
+func Zscore(client *network.Client, args []shared.Value) shared.Value {
 	key := args[0].Bulk
-	entry, exists := server.Memory[key]
+	entry, exists := server.LookupKeyRead(key)
 
 	if !exists {
-		return shared.Value{Typ: "null", Str: ""}
+		return shared.Null()
 	}
 
 	if entry.SortedSet == nil {
-		return shared.Value{Typ: "null", Str: ""}
+		return shared.Null()
 	}
 
 	score, exists := entry.SortedSet.GetScore(args[1].Bulk)
 	if !exists {
-		return shared.Value{Typ: "null", Str: ""}
-	}
-
-	// Format score as integer if it's a whole number, otherwise use decimal
-	if score == float64(int64(score)) {
-		return shared.Value{Typ: "bulk", Bulk: fmt.Sprintf("%.0f", score)}
+		return shared.Null()
 	}
-	return shared.Value{Typ: "bulk", Bulk: fmt.Sprintf("%.15g", score)}
+	return shared.Double(score)
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/network"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
 )
@@ -26,9 +27,9 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "myzset"},
 				{Typ: "bulk", Bulk: "member1"},
 			},
-			expected: shared.Value{Typ: "bulk", Bulk: "1"},
+			expected: shared.Value{Typ: "double", Double: 1},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -48,9 +49,9 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "myzset"},
 				{Typ: "bulk", Bulk: "precision_member"},
 			},
-			expected: shared.Value{Typ: "bulk", Bulk: "19.6089680148389"},
+			expected: shared.Value{Typ: "double", Double: 19.608968014838933},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -71,9 +72,9 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "myzset"},
 				{Typ: "bulk", Bulk: "negative_member"},
 			},
-			expected: shared.Value{Typ: "bulk", Bulk: "-1.5"},
+			expected: shared.Value{Typ: "double", Double: -1.5},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -93,9 +94,9 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "myzset"},
 				{Typ: "bulk", Bulk: "zero_member"},
 			},
-			expected: shared.Value{Typ: "bulk", Bulk: "0"},
+			expected: shared.Value{Typ: "double", Double: 0},
 			verify: func() {
-				entry, exists := server.Memory["myzset"]
+				entry, exists := server.Memory.Get("myzset")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -115,7 +116,7 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "myzset"},
 				{Typ: "bulk", Bulk: "nonexistent"},
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -125,7 +126,7 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "nonexistent"},
 				{Typ: "bulk", Bulk: "member"},
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -135,7 +136,7 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "wrongtype"},
 				{Typ: "bulk", Bulk: "member"},
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -145,9 +146,9 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "unicode"},
 				{Typ: "bulk", Bulk: "成员1"},
 			},
-			expected: shared.Value{Typ: "bulk", Bulk: "1"},
+			expected: shared.Value{Typ: "double", Double: 1},
 			verify: func() {
-				entry, exists := server.Memory["unicode"]
+				entry, exists := server.Memory.Get("unicode")
 				if !exists {
 					t.Error("Key should exist")
 				}
@@ -167,7 +168,7 @@ func TestZscore(t *testing.T) {
 				{Typ: "bulk", Bulk: "empty"},
 				{Typ: "bulk", Bulk: "member"},
 			},
-			expected: shared.Value{Typ: "null", Str: ""},
+			expected: shared.Null(),
 			verify:   func() {},
 		},
 		{
@@ -199,40 +200,40 @@ func TestZscore(t *testing.T) {
 			// Set up test data
 			switch tt.name {
 			case "zscore existing member", "zscore member with high precision", "zscore member with negative score", "zscore member with zero score", "zscore non-existent member":
-				server.Memory["myzset"] = shared.MemoryEntry{
+				server.Memory.Set("myzset", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["myzset"].SortedSet.Add("member1", 1.0)
-				server.Memory["myzset"].SortedSet.Add("precision_member", 19.608968014838933)
-				server.Memory["myzset"].SortedSet.Add("negative_member", -1.5)
-				server.Memory["myzset"].SortedSet.Add("zero_member", 0.0)
+				})
+				getEntry("myzset").SortedSet.Add("member1", 1.0)
+				getEntry("myzset").SortedSet.Add("precision_member", 19.608968014838933)
+				getEntry("myzset").SortedSet.Add("negative_member", -1.5)
+				getEntry("myzset").SortedSet.Add("zero_member", 0.0)
 			case "zscore key with wrong type":
-				server.Memory["wrongtype"] = shared.MemoryEntry{
+				server.Memory.Set("wrongtype", shared.MemoryEntry{
 					Value:   "string value",
 					Expires: 0,
-				}
+				})
 			case "zscore unicode member":
-				server.Memory["unicode"] = shared.MemoryEntry{
+				server.Memory.Set("unicode", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
-				server.Memory["unicode"].SortedSet.Add("成员1", 1.0)
+				})
+				getEntry("unicode").SortedSet.Add("成员1", 1.0)
 			case "zscore empty set":
-				server.Memory["empty"] = shared.MemoryEntry{
+				server.Memory.Set("empty", shared.MemoryEntry{
 					SortedSet: shared.NewSortedSet(),
 					Expires:   0,
-				}
+				})
 			}
 
-			result := Zscore(tt.connID, tt.args)
+			result := runCommand("ZSCORE", Zscore, tt.connID, tt.args)
 
 			if result.Typ != tt.expected.Typ {
 				t.Errorf("Zscore() type = %v, expected %v", result.Typ, tt.expected.Typ)
 			}
 
-			if result.Typ == "bulk" && result.Bulk != tt.expected.Bulk {
-				t.Errorf("Zscore() bulk = %v, expected %v", result.Bulk, tt.expected.Bulk)
+			if result.Typ == "double" && result.Double != tt.expected.Double {
+				t.Errorf("Zscore() double = %v, expected %v", result.Double, tt.expected.Double)
 			}
 
 			if result.Typ == "error" && result.Str != tt.expected.Str {
@@ -248,13 +249,13 @@ func BenchmarkZscore(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
-	server.Memory["benchkey"].SortedSet.Add("member2", 2.0)
-	server.Memory["benchkey"].SortedSet.Add("member3", 3.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
+	getEntry("benchkey").SortedSet.Add("member2", 2.0)
+	getEntry("benchkey").SortedSet.Add("member3", 3.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -264,7 +265,7 @@ func BenchmarkZscore(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zscore(connID, args)
+		Zscore(network.ClientOf(connID), args)
 	}
 }
 
@@ -272,11 +273,11 @@ func BenchmarkZscoreHighPrecision(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("precision_member", 19.608968014838933)
+	})
+	getEntry("benchkey").SortedSet.Add("precision_member", 19.608968014838933)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -286,7 +287,7 @@ func BenchmarkZscoreHighPrecision(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zscore(connID, args)
+		Zscore(network.ClientOf(connID), args)
 	}
 }
 
@@ -294,12 +295,12 @@ func BenchmarkZscoreLargeSet(b *testing.B) {
 	clearMemory()
 
 	// Set up large test data
-	server.Memory["largekey"] = shared.MemoryEntry{
+	server.Memory.Set("largekey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
+	})
 	for i := 0; i < 1000; i++ {
-		server.Memory["largekey"].SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
+		getEntry("largekey").SortedSet.Add(fmt.Sprintf("member%d", i), float64(i))
 	}
 
 	connID := "benchmark-conn"
@@ -310,7 +311,7 @@ func BenchmarkZscoreLargeSet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zscore(connID, args)
+		Zscore(network.ClientOf(connID), args)
 	}
 }
 
@@ -318,11 +319,11 @@ func BenchmarkZscoreNonExistent(b *testing.B) {
 	clearMemory()
 
 	// Set up test data
-	server.Memory["benchkey"] = shared.MemoryEntry{
+	server.Memory.Set("benchkey", shared.MemoryEntry{
 		SortedSet: shared.NewSortedSet(),
 		Expires:   0,
-	}
-	server.Memory["benchkey"].SortedSet.Add("member1", 1.0)
+	})
+	getEntry("benchkey").SortedSet.Add("member1", 1.0)
 
 	connID := "benchmark-conn"
 	args := []shared.Value{
@@ -332,6 +333,6 @@ func BenchmarkZscoreNonExistent(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Zscore(connID, args)
+		Zscore(network.ClientOf(connID), args)
 	}
 }
@@ -0,0 +1,231 @@
+package kv
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// registerConnection registers a connection and returns its client. Its connection, returned
+// by client.Conn(), queues what is written to the client in its output buffer.
+func registerConnection(conn net.Conn) *network.Client {
+	connID := conn.RemoteAddr().String()
+	client := network.ClientRegister(connID, network.TrackOutputBuffer(connID, conn))
+	server.ConnectionReceived()
+	return client
+}
+
+// readAndValidateCommand reads a command from the connection and validates it. A command
+// is an array of bulk strings, anything else is a recoverable protocol error since the value
+// was read whole. An empty array returns an empty command, which is ignored.
+func readAndValidateCommand(r *protocol.Resp) (string, []protocol.Value, error) {
+	value, err := r.Read()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if value.IsNull() || (value.Typ == "array" && len(value.Array) == 0) {
+		return "", nil, nil
+	}
+	if value.Typ != "array" {
+		return "", nil, &protocol.ProtocolError{Reason: "expected an array of bulk strings", Recoverable: true}
+	}
+	for _, arg := range value.Array {
+		if arg.Typ != "bulk" {
+			return "", nil, &protocol.ProtocolError{Reason: "expected an array of bulk strings", Recoverable: true}
+		}
+	}
+
+	command := strings.ToUpper(value.Array[0].Bulk)
+	args := value.Array[1:]
+	return command, args, nil
+}
+
+// executeTransactionCommand executes a command within a transaction context
+func executeTransactionCommand(command string, client *network.Client, args []protocol.Value, writer *protocol.Writer) {
+	if IsTransactionCommand(command) {
+		// EXEC propagates the queued writes itself, wrapped in MULTI/EXEC
+		result := client.ExecuteAndPropagate(command, args)
+
+		// Only write response if it's not a NO_RESPONSE type
+		if result.Typ != network.NO_RESPONSE {
+			writer.Write(result)
+		}
+	} else {
+		// Commands that can't run are refused now, and EXEC discards the whole transaction
+		if err := network.ValidateCommand(command, args); err != "" {
+			client.AbortTransaction()
+			server.RecordErrorReply(err)
+			writer.Write(protocol.Value{Typ: "error", Str: err})
+			return
+		}
+
+		// Queue the command instead of executing it
+		client.Queue(command, args)
+
+		// Return QUEUED response
+		result := protocol.Value{Typ: "string", Str: "QUEUED"}
+		writer.Write(result)
+	}
+}
+
+// executeNormalCommand executes a command outside of transaction context
+func executeNormalCommand(command string, client *network.Client, args []protocol.Value, writer *protocol.Writer) {
+	// Write commands that changed the dataset are propagated as their deterministic effects
+	result := client.ExecuteAndPropagate(command, args)
+
+	// Only write response if it's not a NO_RESPONSE type
+	if result.Typ != network.NO_RESPONSE {
+		writer.Write(result)
+	}
+}
+
+// handleConnection serves a client until it disconnects, is killed or sends malformed input
+func handleConnection(conn net.Conn) {
+	// Register the connection (concurrency-safe), the output queued for it is sent before it is closed
+	client := registerConnection(conn)
+	conn = client.Conn()
+	defer network.CloseAfterSent(conn)
+	connID := client.ConnID
+	defer network.ClientUnregister(connID)
+	defer network.ReplicasDelete(connID)
+
+	// Replies are buffered and written once the pipelined commands read so far have run,
+	// encoded in the protocol negotiated with HELLO
+	reader := protocol.NewResp(conn)
+	writer := protocol.NewProtocolWriter(bufio.NewWriterSize(conn, protocol.IOBufferSize), client.Protocol)
+	defer writer.Flush()
+
+	for {
+		// The replies are sent once the commands read so far have run, whichever way each
+		// of them ended
+		if reader.Buffered() == 0 {
+			writer.Flush()
+		}
+
+		command, args, err := readAndValidateCommand(reader)
+		var protocolErr *protocol.ProtocolError
+		if errors.As(err, &protocolErr) {
+			serverLog.Verbosef("Protocol error from client %v: %v", conn.RemoteAddr(), err)
+			server.RecordErrorReply("ERR " + err.Error())
+			writer.Write(protocol.Value{Typ: "error", Str: "ERR " + err.Error()})
+			if protocolErr.Recoverable {
+				continue
+			}
+			// The rest of the input can't be parsed, the client gets the error before being disconnected
+			return
+		}
+		if err != nil {
+			if err == io.EOF {
+				serverLog.Verbosef("Client disconnected: %v", conn.RemoteAddr())
+			} else {
+				serverLog.Verbosef("Error reading from client %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		if command == "" {
+			continue
+		}
+
+		// Commands renamed with rename-command are only known by their new name
+		command, ok := network.ResolveCommand(command)
+		if !ok {
+			err := network.UnknownCommandError(strings.ToLower(command), args)
+			server.RecordErrorReply(err)
+			writer.Write(protocol.Value{Typ: "error", Str: err})
+			continue
+		}
+
+		// A client sending faster than the rate limits allow gets an error instead of a reply
+		if err := client.RateLimited(command, args); err != "" {
+			server.RecordErrorReply(err)
+			writer.Write(protocol.Value{Typ: "error", Str: err})
+			continue
+		}
+
+		// Other clients get BUSY while a script runs for too long, instead of waiting for it
+		if err := network.ScriptBusy(command, args); err != "" {
+			server.RecordErrorReply(err)
+			writer.Write(protocol.Value{Typ: "error", Str: err})
+			continue
+		}
+
+		// The replies pipelined before a command that may wait, or writes to the connection
+		// itself, are sent first
+		inTransaction := client.InTransaction()
+		if !inTransaction && network.CommandMayWait(command) {
+			writer.Flush()
+		}
+
+		// Check if this connection is in a transaction (concurrency-safe)
+		if inTransaction {
+			executeTransactionCommand(command, client, args, writer)
+		} else if isBlockingCommand(command) {
+			// A client disconnecting while it waits cancels the command
+			stopWatching := watchDisconnect(conn, reader, client)
+			executeNormalCommand(command, client, args, writer)
+			stopWatching()
+		} else {
+			// No active transaction, execute command normally
+			executeNormalCommand(command, client, args, writer)
+		}
+
+		// Other goroutines write to subscribers, monitors and replicas too, so their replies
+		// are sent right away to come before any message pushed to them
+		if isSharedConnection(client) {
+			writer.Flush()
+		}
+
+		// A client that killed itself is disconnected once it got the reply
+		if client.Killed() {
+			return
+		}
+	}
+}
+
+// isBlockingCommand reports whether a command may block the client waiting for its keys
+func isBlockingCommand(command string) bool {
+	spec, ok := network.LookupCommand(command)
+	return ok && spec.HasFlag(network.CommandFlagBlocking)
+}
+
+// watchDisconnect cancels the context of a client once it disconnects, while its connection
+// goroutine runs a command instead of reading. The returned function stops watching, and
+// must be called before the connection is read again. Input the client sends meanwhile is
+// left for the connection goroutine, and ends the watch since the client is still there.
+func watchDisconnect(conn net.Conn, reader *protocol.Resp, client *network.Client) func() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := reader.WaitInput()
+		var netErr net.Error
+		if err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+			client.Disconnected()
+		}
+	}()
+
+	return func() {
+		// An expired read deadline interrupts the wait, the reader forgets the error once returned
+		conn.SetReadDeadline(time.Now())
+		<-done
+		conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// isSharedConnection reports whether other goroutines write to the connection of a client:
+// subscribers get published messages, monitors the commands run and replicas the writes
+func isSharedConnection(client *network.Client) bool {
+	if client.IsMonitor() {
+		return true
+	}
+	_, isReplica := network.ReplicasGet(client.ConnID)
+	return isReplica || client.Subscribed()
+}
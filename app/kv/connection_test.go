@@ -0,0 +1,130 @@
+package kv
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// rawClient connects a pipe client served by handleConnection, for tests writing raw input
+func rawClient(t *testing.T, port int) *pipeClient {
+	t.Helper()
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	c := connectPipeClient(addr, addr)
+	t.Cleanup(c.close)
+	return c
+}
+
+// sendRaw writes raw input to the server and returns the text of the reply
+func (c *pipeClient) sendRaw(t *testing.T, input string) string {
+	t.Helper()
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write([]byte(input)); err != nil {
+		t.Fatalf("Expected to send %q, got %v", input, err)
+	}
+	reply, err := c.reader.ReadReply()
+	if err != nil {
+		t.Fatalf("Expected a reply to %q, got %v", input, err)
+	}
+	return replyText(reply)
+}
+
+func TestRecoverableProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"nested array", "*1\r\n*1\r\n$4\r\nPING\r\n"},
+		{"integer argument", "*2\r\n$4\r\nECHO\r\n:5\r\n"},
+		{"simple string command", "+PING\r\n"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := rawClient(t, 40000+i)
+			// The error is sent at once, and the connection goes on serving commands
+			if reply := c.sendRaw(t, tt.input); reply != "ERR Protocol error: expected an array of bulk strings" {
+				t.Errorf("Expected a protocol error, got %q", reply)
+			}
+			if reply := c.sendRaw(t, "*2\r\n$4\r\nECHO\r\n$2\r\nok\r\n"); reply != "ok" {
+				t.Errorf("Expected ok, got %q", reply)
+			}
+		})
+	}
+}
+
+func TestRenamedCommands(t *testing.T) {
+	if err := network.RenameCommand("ECHO", "SAY"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(network.ResetCommandRenames)
+
+	c := rawClient(t, 40010)
+	// A command called by the name it was renamed from is unknown, and the client gets the error
+	expected := "ERR unknown command 'echo', with args beginning with: 'ok' "
+	if reply := c.sendRaw(t, "*2\r\n$4\r\nECHO\r\n$2\r\nok\r\n"); reply != expected {
+		t.Errorf("Expected %q, got %q", expected, reply)
+	}
+	if reply := c.sendRaw(t, "*2\r\n$3\r\nSAY\r\n$2\r\nok\r\n"); reply != "ok" {
+		t.Errorf("Expected ok, got %q", reply)
+	}
+}
+
+// countingConn counts the reads returning input and the writes handleConnection makes on its
+// connection
+type countingConn struct {
+	pipeConn
+	reads, writes atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.pipeConn.Read(b)
+	if n > 0 {
+		c.reads.Add(1)
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	c.writes.Add(1)
+	return c.pipeConn.Write(b)
+}
+
+func TestPipelinedCommandsAreBatched(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40020}
+	conn := &countingConn{pipeConn: pipeConn{Conn: serverSide, remote: addr, local: addr}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handleConnection(conn)
+	}()
+	defer func() {
+		clientSide.Close()
+		<-done
+	}()
+
+	const commands = 300
+	pipeline := strings.Repeat("*2\r\n$4\r\nECHO\r\n$5\r\nhello\r\n", commands)
+	clientSide.SetDeadline(time.Now().Add(5 * time.Second))
+	go clientSide.Write([]byte(pipeline))
+
+	c := &pipeClient{conn: clientSide, reader: protocol.NewResp(clientSide)}
+	for i := 0; i < commands; i++ {
+		reply, err := c.reader.ReadReply()
+		if err != nil || replyText(reply) != "hello" {
+			t.Fatalf("Expected reply %d to be hello, got %v, %v", i, reply, err)
+		}
+	}
+
+	// The 7.5KB pipeline fits the protocol.IOBufferSize read buffer, unlike the 4KB default of
+	// bufio, so it is read at once and the replies are written together after the last command
+	if reads, writes := conn.reads.Load(), conn.writes.Load(); reads != 1 || writes != 1 {
+		t.Errorf("Expected the %d commands to be read and replied at once, got %d reads and %d writes", commands, reads, writes)
+	}
+}
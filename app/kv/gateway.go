@@ -0,0 +1,339 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// gatewayLog logs the messages of the HTTP gateway
+var gatewayLog = logger.New("gateway")
+
+// startHTTPGateway serves the dataset over HTTP on every bind address at http-port, when it
+// is not 0, and returns the HTTP server so it is closed with the server.
+//
+// Every request is served by a client of its own, connected like the others: commands go
+// through authentication, ACLs, rate limits and the audit log, and replies are returned as
+// JSON. Requests authenticate with HTTP basic authentication, as an ACL user and password.
+//
+// Examples:
+//
+//	curl -X PUT --data-binary 'value' 'localhost:8080/keys/greeting?px=60000' // {"result":"OK"}
+//	curl localhost:8080/keys/greeting                                       // {"result":"value"}
+//	curl -X DELETE localhost:8080/keys/greeting                             // {"result":1}
+//	curl -d '["LPUSH","queue","a","b"]' localhost:8080/commands             // {"result":2}
+//	curl -d '[["MULTI"],["INCR","n"],["EXEC"]]' localhost:8080/commands     // [{"result":"OK"},{"result":"QUEUED"},{"result":[1]}]
+//	curl -N 'localhost:8080/subscribe?channel=news&channel=alerts'          // Server-sent events of the messages
+func startHTTPGateway() (*http.Server, error) {
+	port := server.StoreState.HTTPPort
+	if port == 0 {
+		return nil, nil
+	}
+	listeners, err := listen(strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys/{key}", gatewayGetKey)
+	mux.HandleFunc("PUT /keys/{key}", gatewayPutKey)
+	mux.HandleFunc("DELETE /keys/{key}", gatewayDeleteKey)
+	mux.HandleFunc("POST /commands", gatewayCommands)
+	mux.HandleFunc("GET /subscribe", gatewaySubscribe)
+	srv := &http.Server{Handler: mux}
+	for _, l := range listeners {
+		gatewayLog.Noticef("Serving the HTTP gateway on http://%s/", l.Addr())
+		go srv.Serve(l)
+	}
+	return srv, nil
+}
+
+// gatewayReply is the JSON body replied for a command
+type gatewayReply struct {
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// gatewayGetKey handles GET /keys/{key}, replying the value of the key or 404
+func gatewayGetKey(w http.ResponseWriter, r *http.Request) {
+	gatewayRun(w, r, func(s *gatewaySession) (protocol.Value, error) {
+		return s.do("GET", r.PathValue("key"))
+	})
+}
+
+// gatewayPutKey handles PUT /keys/{key}, setting the key to the request body. The px and pxat
+// query parameters set an expiration, like the options of SET.
+func gatewayPutKey(w http.ResponseWriter, r *http.Request) {
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, server.StoreState.ProtoMaxBulkLen))
+	if err != nil {
+		writeGatewayJSON(w, http.StatusRequestEntityTooLarge, gatewayReply{Error: "ERR the value is too large"})
+		return
+	}
+	args := []string{r.PathValue("key"), string(value)}
+	for _, option := range []string{"px", "pxat"} {
+		if ttl := r.URL.Query().Get(option); ttl != "" {
+			args = append(args, strings.ToUpper(option), ttl)
+		}
+	}
+	gatewayRun(w, r, func(s *gatewaySession) (protocol.Value, error) {
+		return s.do("SET", args...)
+	})
+}
+
+// gatewayDeleteKey handles DELETE /keys/{key}, replying 1 when the key was removed or 404
+func gatewayDeleteKey(w http.ResponseWriter, r *http.Request) {
+	gatewayRun(w, r, func(s *gatewaySession) (protocol.Value, error) {
+		reply, err := s.do("DEL", r.PathValue("key"))
+		if err == nil && reply.Typ == "integer" && reply.Num == 0 {
+			reply = protocol.Value{Typ: "null"}
+		}
+		return reply, err
+	})
+}
+
+// gatewayCommands handles POST /commands, running the command of a JSON array of strings, or
+// the commands of an array of such arrays one after the other, like MULTI, the commands to
+// queue and EXEC. A single command is replied like the keys endpoints; several commands reply
+// 200 with an array holding the result or the error of each.
+func gatewayCommands(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, server.StoreState.ClientQueryBufferLimit))
+	if err != nil {
+		writeGatewayJSON(w, http.StatusRequestEntityTooLarge, gatewayReply{Error: "ERR the request is too large"})
+		return
+	}
+	var command []string
+	if err := json.Unmarshal(body, &command); err == nil && len(command) > 0 {
+		gatewayRun(w, r, func(s *gatewaySession) (protocol.Value, error) {
+			return s.do(command[0], command[1:]...)
+		})
+		return
+	}
+	var commands [][]string
+	if err := json.Unmarshal(body, &commands); err != nil || len(commands) == 0 {
+		writeGatewayJSON(w, http.StatusBadRequest, gatewayReply{Error: `ERR expected a command like ["SET","key","value"] or an array of commands`})
+		return
+	}
+	for _, command := range commands {
+		if len(command) == 0 {
+			writeGatewayJSON(w, http.StatusBadRequest, gatewayReply{Error: "ERR empty command"})
+			return
+		}
+	}
+
+	s, status, err := openGatewaySession(r)
+	if err != nil {
+		writeGatewayJSON(w, status, gatewayReply{Error: err.Error()})
+		return
+	}
+	defer s.close()
+	replies := make([]gatewayReply, len(commands))
+	for i, command := range commands {
+		reply, err := s.do(command[0], command[1:]...)
+		if err != nil {
+			writeGatewayJSON(w, http.StatusBadGateway, gatewayReply{Error: "ERR " + err.Error()})
+			return
+		}
+		if reply.Typ == "error" {
+			replies[i].Error = reply.Str
+		} else {
+			replies[i].Result = replyJSON(reply)
+		}
+	}
+	writeGatewayJSON(w, http.StatusOK, replies)
+}
+
+// gatewaySubscribe handles GET /subscribe, subscribing to the channel query parameters and
+// streaming the messages published to them as server-sent events, until the client
+// disconnects. Each event is a message, with JSON data like {"channel":"news","message":"hi"}.
+func gatewaySubscribe(w http.ResponseWriter, r *http.Request) {
+	channels := r.URL.Query()["channel"]
+	if len(channels) == 0 {
+		writeGatewayJSON(w, http.StatusBadRequest, gatewayReply{Error: "ERR expected channel query parameters"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeGatewayJSON(w, http.StatusInternalServerError, gatewayReply{Error: "ERR streaming is not supported"})
+		return
+	}
+
+	s, status, err := openGatewaySession(r)
+	if err != nil {
+		writeGatewayJSON(w, status, gatewayReply{Error: err.Error()})
+		return
+	}
+	defer s.close()
+	for _, channel := range channels {
+		reply, err := s.send("SUBSCRIBE", channel)
+		if err == nil && reply.Typ == "error" {
+			err = fmt.Errorf("%s", reply.Str)
+		}
+		if err != nil {
+			writeGatewayJSON(w, gatewayStatus(err.Error()), gatewayReply{Error: err.Error()})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		message, err := s.reader.ReadReply()
+		if err != nil {
+			return
+		}
+		if len(message.Array) != 3 || message.Array[0].Bulk != "message" {
+			continue
+		}
+		data, _ := json.Marshal(map[string]string{"channel": message.Array[1].Bulk, "message": message.Array[2].Bulk})
+		if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// gatewayRun runs a command in a session of its own and writes its reply: a null reply is
+// 404, an error is a status depending on its code, see gatewayStatus
+func gatewayRun(w http.ResponseWriter, r *http.Request, run func(s *gatewaySession) (protocol.Value, error)) {
+	s, status, err := openGatewaySession(r)
+	if err != nil {
+		writeGatewayJSON(w, status, gatewayReply{Error: err.Error()})
+		return
+	}
+	defer s.close()
+
+	reply, err := run(s)
+	switch {
+	case err != nil:
+		writeGatewayJSON(w, http.StatusBadGateway, gatewayReply{Error: "ERR " + err.Error()})
+	case reply.Typ == "error":
+		writeGatewayJSON(w, gatewayStatus(reply.Str), gatewayReply{Error: reply.Str})
+	case reply.Typ == "null":
+		writeGatewayJSON(w, http.StatusNotFound, gatewayReply{Error: "ERR no such key"})
+	default:
+		writeGatewayJSON(w, http.StatusOK, gatewayReply{Result: replyJSON(reply)})
+	}
+}
+
+// gatewayStatus returns the HTTP status of an error reply, from its code
+func gatewayStatus(message string) int {
+	code, _, _ := strings.Cut(message, " ")
+	switch code {
+	case "NOAUTH", "WRONGPASS":
+		return http.StatusUnauthorized
+	case "NOPERM":
+		return http.StatusForbidden
+	case "BUSY", "LOADING", "MASTERDOWN":
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// writeGatewayJSON writes body as the JSON reply of a request. Unauthenticated requests are
+// asked for basic authentication.
+func writeGatewayJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="kv"`)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// replyJSON returns a reply as the value encoding/json writes: strings, numbers, booleans,
+// null, arrays, and objects for the maps whose keys are strings
+func replyJSON(v protocol.Value) any {
+	switch v.Typ {
+	case "string", "big_number":
+		return v.Str
+	case "bulk", "verbatim":
+		return v.Bulk
+	case "integer":
+		return v.Num
+	case "double":
+		// JSON numbers can't be infinite or NaN
+		if math.IsInf(v.Double, 0) || math.IsNaN(v.Double) {
+			return strconv.FormatFloat(v.Double, 'g', -1, 64)
+		}
+		return v.Double
+	case "boolean":
+		return v.Bool
+	case "error":
+		return map[string]string{"error": v.Str}
+	case "map":
+		object := make(map[string]any, len(v.Array)/2)
+		for i := 0; i+1 < len(v.Array); i += 2 {
+			key, ok := replyJSON(v.Array[i]).(string)
+			if !ok {
+				return replyArrayJSON(v.Array)
+			}
+			object[key] = replyJSON(v.Array[i+1])
+		}
+		return object
+	case "array", "set", "push":
+		return replyArrayJSON(v.Array)
+	}
+	return nil
+}
+
+// replyArrayJSON returns the elements of an aggregate reply as a JSON array
+func replyArrayJSON(elements []protocol.Value) []any {
+	array := make([]any, len(elements))
+	for i, element := range elements {
+		array[i] = replyJSON(element)
+	}
+	return array
+}
+
+// gatewaySession is the client serving a request, disconnected when the request is canceled
+type gatewaySession struct {
+	*pipeClient
+	stop func() bool // Stops disconnecting the client when the request is canceled
+}
+
+// openGatewaySession connects a client for a request, from the address the request came
+// from, switched to RESP3 so maps and doubles keep their types, and authenticated with the
+// credentials of the request. It returns the HTTP status of the error when the session can't
+// be used.
+func openGatewaySession(r *http.Request) (*gatewaySession, int, error) {
+	remote, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("ERR invalid client address %s", r.RemoteAddr)
+	}
+	local, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	client := connectPipeClient(remote, local)
+	// A canceled request disconnects its client, which stops waiting in a blocking command
+	s := &gatewaySession{pipeClient: client, stop: context.AfterFunc(r.Context(), client.disconnect)}
+
+	hello := []string{"3"}
+	if username, password, ok := r.BasicAuth(); ok {
+		hello = append(hello, "AUTH", username, password)
+	}
+	reply, err := s.do("HELLO", hello...)
+	if err == nil && reply.Typ == "error" {
+		err = fmt.Errorf("%s", reply.Str)
+	}
+	if err != nil {
+		s.close()
+		return nil, gatewayStatus(err.Error()), err
+	}
+	return s, 0, nil
+}
+
+// close disconnects the client of the session
+func (s *gatewaySession) close() {
+	s.stop()
+	s.pipeClient.close()
+}
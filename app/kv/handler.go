@@ -0,0 +1,114 @@
+package kv
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+)
+
+// TransactionCommands contains commands that should be executed normally even during a transaction
+var TransactionCommands = []string{"MULTI", "EXEC", "DISCARD"}
+
+// IsTransactionCommand checks if a command should be executed normally during a transaction
+func IsTransactionCommand(command string) bool {
+	for _, cmd := range TransactionCommands {
+		if cmd == command {
+			return true
+		}
+	}
+	return false
+}
+
+// Handlers maps Redis command names to their corresponding handler functions.
+// Each handler function takes the client running the command and an array of Value arguments, and returns a Value response.
+var Handlers = map[string]network.CommandHandler{
+	"ACL":          commands.Acl,
+	"ASKING":       commands.Asking,
+	"AUTH":         commands.Auth,
+	"BGREWRITEAOF": commands.Bgrewriteaof,
+	"BGSAVE":       commands.Bgsave,
+	"BLPOP":        commands.Blpop,
+	"CLIENT":       commands.Client,
+	"CLUSTER":      commands.Cluster,
+	"COMMAND":      commands.Command,
+	"CONFIG":       commands.Config,
+	"DEBUG":        commands.Debug,
+	"DEL":          commands.Del,
+	"DISCARD":      commands.Discard,
+	"DUMP":         commands.Dump,
+	"ECHO":         commands.Echo,
+	"EVAL":         commands.Eval,
+	"EVALSHA":      commands.Evalsha,
+	"EXEC":         commands.Exec,
+	"FAILOVER":     commands.Failover,
+	"FCALL":        commands.Fcall,
+	"FCALL_RO":     commands.FcallRo,
+	"FUNCTION":     commands.Function,
+	"GET":          commands.Get,
+	"GEOADD":       commands.Geoadd,
+	"GEODIST":      commands.Geodist,
+	"GEOPOS":       commands.Geopos,
+	"GEOSEARCH":    commands.Geosearch,
+	"GETSET":       commands.Getset,
+	"HELLO":        commands.Hello,
+	"INCR":         commands.Incr,
+	"INCRBY":       commands.IncrBy,
+	"INFO":         commands.Info,
+	"KEYS":         commands.Keys,
+	"LASTSAVE":     commands.Lastsave,
+	"LATENCY":      commands.Latency,
+	"LLEN":         commands.Llen,
+	"LPOP":         commands.Lpop,
+	"LPUSH":        commands.Lpush,
+	"LRANGE":       commands.Lrange,
+	"MEMORY":       commands.Memory,
+	"MONITOR":      commands.Monitor,
+	"MULTI":        commands.Multi,
+	"OBJECT":       commands.Object,
+	"PEXPIREAT":    commands.Pexpireat,
+	"PING":         commands.Ping,
+	"PSYNC":        commands.Psync,
+	"PUBLISH":      commands.Publish,
+	"READONLY":     commands.Readonly,
+	"READWRITE":    commands.Readwrite,
+	"REPLCONF":     commands.Replconf,
+	"RESTORE":      commands.Restore,
+	"RPUSH":        commands.Rpush,
+	"SAVE":         commands.Save,
+	"SCRIPT":       commands.Script,
+	"SET":          commands.Set,
+	"SHUTDOWN":     commands.Shutdown,
+	"SLOWLOG":      commands.Slowlog,
+	"SUBSCRIBE":    commands.Subscribe,
+	"TYPE":         commands.Type,
+	"UNSUBSCRIBE":  commands.Unsubscribe,
+	"WAIT":         commands.Wait,
+	"XADD":         commands.Xadd,
+	"XRANGE":       commands.Xrange,
+	"XREAD":        commands.Xread,
+	"ZADD":         commands.Zadd,
+	"ZCARD":        commands.Zcard,
+	"ZRANGE":       commands.Zrange,
+	"ZREM":         commands.Zrem,
+	"ZSCORE":       commands.Zscore,
+	"ZRANK":        commands.Zrank,
+}
+
+// Rewriters maps write commands with non-deterministic effects to the rewriter
+// that turns them into the form propagated to replicas.
+var Rewriters = map[string]network.PropagationRewriter{
+	"BLPOP":   commands.RewriteBlpop,
+	"RESTORE": commands.RewriteRestore,
+	"SET":     commands.RewriteSet,
+	"XADD":    commands.RewriteXadd,
+}
+
+// init initializes the shared command handlers and propagation rewriters maps
+func init() {
+	network.CommandHandlers = make(map[string]network.CommandHandler)
+	for cmd, handler := range Handlers {
+		network.CommandHandlers[cmd] = handler
+	}
+	for cmd, rewriter := range Rewriters {
+		network.PropagationRewriters[cmd] = rewriter
+	}
+}
@@ -0,0 +1,327 @@
+package kv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// memcachedLog logs the messages of the memcached listener
+var memcachedLog = logger.New("memcached")
+
+// memcachedMaxKeyLength is the longest key memcached accepts
+const memcachedMaxKeyLength = 250
+
+// memcachedMaxRelativeExptime is the longest expiration time taken as seconds from now, longer
+// ones are Unix times, like memcached does
+const memcachedMaxRelativeExptime = 60 * 60 * 24 * 30
+
+// startMemcached serves the memcached text protocol on every bind address at memcached-port,
+// when it is not 0, and returns the listeners so they are closed with the server.
+//
+// The get, set, delete and incr commands of memcached clients run as GET, SET, DEL and INCRBY
+// on the string keys, by a client of their own for each connection, so they are checked,
+// logged and replicated like any command. Flags aren't kept: values are always read with
+// flags 0. Counters grow past 64 bits instead of wrapping around.
+//
+// Examples:
+//
+//	set greeting 0 60 5   // Then hello, replies STORED: greeting expires in 60 seconds
+//	get greeting          // Replies VALUE greeting 0 5, hello and END
+//	incr hits 1           // Replies NOT_FOUND, memcached only increments existing counters
+//	delete greeting       // Replies DELETED
+func startMemcached() ([]net.Listener, error) {
+	port := server.StoreState.MemcachedPort
+	if port == 0 {
+		return nil, nil
+	}
+	listeners, err := listen(strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listeners {
+		memcachedLog.Noticef("Serving the memcached protocol on %s", l.Addr())
+		go acceptMemcachedConnections(l)
+	}
+	return listeners, nil
+}
+
+// acceptMemcachedConnections serves the memcached clients connecting to a listener until it is
+// closed
+func acceptMemcachedConnections(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			memcachedLog.Warningf("Error accepting connection: %v", err)
+			continue
+		}
+		go serveMemcached(conn)
+	}
+}
+
+// memcachedConn is the connection of a memcached client, with the client running its commands
+type memcachedConn struct {
+	client *pipeClient
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// serveMemcached runs the commands of a memcached client until it quits or disconnects
+func serveMemcached(conn net.Conn) {
+	defer conn.Close()
+	c := &memcachedConn{
+		client: connectPipeClient(conn.RemoteAddr(), conn.LocalAddr()),
+		reader: bufio.NewReaderSize(conn, protocol.IOBufferSize),
+		writer: bufio.NewWriterSize(conn, protocol.IOBufferSize),
+	}
+	defer c.client.close()
+	// The connection ends with its client, like when it is killed or the server stops
+	go func() {
+		<-c.client.done
+		conn.Close()
+	}()
+
+	for {
+		line, err := c.reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			c.writer.WriteString("CLIENT_ERROR line too long\r\n")
+			c.writer.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) > 0 && fields[0] == "quit" {
+			c.writer.Flush()
+			return
+		}
+		if err := c.run(fields); err != nil {
+			return
+		}
+		// Pipelined commands are replied at once
+		if c.reader.Buffered() == 0 {
+			if err := c.writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// run runs a command line and writes its reply. It returns an error when the connection
+// can't go on.
+func (c *memcachedConn) run(fields []string) error {
+	if len(fields) == 0 {
+		c.writer.WriteString("ERROR\r\n")
+		return nil
+	}
+	args := fields[1:]
+	for _, key := range memcachedKeys(fields[0], args) {
+		if len(key) > memcachedMaxKeyLength {
+			c.writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return nil
+		}
+	}
+
+	switch fields[0] {
+	case "get":
+		if len(args) == 0 {
+			c.writer.WriteString("ERROR\r\n")
+			return nil
+		}
+		return c.get(args)
+	case "set":
+		if len(args) != 4 && len(args) != 5 {
+			c.writer.WriteString("ERROR\r\n")
+			return nil
+		}
+		return c.set(args)
+	case "delete":
+		if len(args) < 1 || len(args) > 3 {
+			c.writer.WriteString("ERROR\r\n")
+			return nil
+		}
+		return c.delete(args)
+	case "incr":
+		if len(args) != 2 && len(args) != 3 {
+			c.writer.WriteString("ERROR\r\n")
+			return nil
+		}
+		return c.incr(args)
+	case "version":
+		c.writer.WriteString("VERSION " + commands.RedisVersion + "\r\n")
+		return nil
+	}
+	c.writer.WriteString("ERROR\r\n")
+	return nil
+}
+
+// memcachedKeys returns the keys in the arguments of a command
+func memcachedKeys(command string, args []string) []string {
+	switch {
+	case command == "get":
+		return args
+	case len(args) > 0:
+		return args[:1]
+	}
+	return nil
+}
+
+// get handles get key [key ...], replying the value of each key that exists
+func (c *memcachedConn) get(keys []string) error {
+	for _, key := range keys {
+		reply, err := c.client.do("GET", key)
+		if err != nil {
+			return err
+		}
+		switch reply.Typ {
+		case "error":
+			c.writer.WriteString("SERVER_ERROR " + reply.Str + "\r\n")
+			return nil
+		case "string", "bulk":
+			value := replyText(reply)
+			fmt.Fprintf(c.writer, "VALUE %s 0 %d\r\n", key, len(value))
+			c.writer.WriteString(value + "\r\n")
+		}
+	}
+	c.writer.WriteString("END\r\n")
+	return nil
+}
+
+// set handles set key flags exptime bytes [noreply], followed by the value. A negative exptime
+// deletes the key.
+func (c *memcachedConn) set(args []string) error {
+	_, flagsErr := strconv.ParseUint(args[1], 10, 32)
+	exptime, exptimeErr := strconv.ParseInt(args[2], 10, 64)
+	size, sizeErr := strconv.ParseInt(args[3], 10, 64)
+	if flagsErr != nil || exptimeErr != nil || sizeErr != nil || size < 0 {
+		c.writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return nil
+	}
+	noreply := len(args) == 5 && args[4] == "noreply"
+
+	if size > server.StoreState.ProtoMaxBulkLen {
+		// The value is read anyway, so the next command is read from the right place
+		if _, err := c.reader.Discard(int(size) + 2); err != nil {
+			return err
+		}
+		c.reply(noreply, "SERVER_ERROR object too large for cache")
+		return nil
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		c.reply(noreply, "CLIENT_ERROR bad data chunk")
+		return nil
+	}
+
+	command, setArgs := "SET", []string{args[0], string(data[:size])}
+	switch {
+	case exptime < 0:
+		command, setArgs = "DEL", setArgs[:1]
+	case exptime > memcachedMaxRelativeExptime:
+		setArgs = append(setArgs, "PXAT", strconv.FormatInt(exptime*1000, 10))
+	case exptime > 0:
+		setArgs = append(setArgs, "PX", strconv.FormatInt(exptime*1000, 10))
+	}
+	reply, err := c.client.do(command, setArgs...)
+	if err != nil {
+		return err
+	}
+	if reply.Typ == "error" {
+		c.reply(noreply, "SERVER_ERROR "+reply.Str)
+		return nil
+	}
+	c.reply(noreply, "STORED")
+	return nil
+}
+
+// delete handles delete key [noreply], also accepting the 0 time of older clients
+func (c *memcachedConn) delete(args []string) error {
+	rest := args[1:]
+	if len(rest) > 0 && rest[0] == "0" {
+		rest = rest[1:]
+	}
+	noreply := len(rest) == 1 && rest[0] == "noreply"
+	if len(rest) > 0 && !noreply {
+		c.writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return nil
+	}
+
+	reply, err := c.client.do("DEL", args[0])
+	switch {
+	case err != nil:
+		return err
+	case reply.Typ == "error":
+		c.reply(noreply, "SERVER_ERROR "+reply.Str)
+	case reply.Num == 0:
+		c.reply(noreply, "NOT_FOUND")
+	default:
+		c.reply(noreply, "DELETED")
+	}
+	return nil
+}
+
+// incr handles incr key delta [noreply], incrementing a counter that exists and replying its
+// new value
+func (c *memcachedConn) incr(args []string) error {
+	noreply := len(args) == 3 && args[2] == "noreply"
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || delta > math.MaxInt64 {
+		c.reply(noreply, "CLIENT_ERROR invalid numeric delta argument")
+		return nil
+	}
+
+	current, err := c.client.do("GET", args[0])
+	if err != nil {
+		return err
+	}
+	switch current.Typ {
+	case "error":
+		c.reply(noreply, "SERVER_ERROR "+current.Str)
+		return nil
+	case "null":
+		c.reply(noreply, "NOT_FOUND")
+		return nil
+	}
+	if _, err := strconv.ParseUint(replyText(current), 10, 64); err != nil {
+		c.reply(noreply, "CLIENT_ERROR cannot increment or decrement non-numeric value")
+		return nil
+	}
+
+	reply, err := c.client.do("INCRBY", args[0], strconv.FormatUint(delta, 10))
+	switch {
+	case err != nil:
+		return err
+	case reply.Typ == "error":
+		c.reply(noreply, "SERVER_ERROR "+reply.Str)
+	case reply.Typ == "integer":
+		c.reply(noreply, strconv.Itoa(reply.Num))
+	default:
+		// Counters past 64 bits are replied as strings
+		c.reply(noreply, replyText(reply))
+	}
+	return nil
+}
+
+// reply writes a reply line, unless the client asked for no reply
+func (c *memcachedConn) reply(noreply bool, line string) {
+	if !noreply {
+		c.writer.WriteString(line + "\r\n")
+	}
+}
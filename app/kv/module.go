@@ -0,0 +1,187 @@
+package kv
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// RegisterCommand adds a command implemented by another package, so domain-specific commands
+// run next to the built-in ones without changing the server. The spec gives its name, arity,
+// flags and key positions; the handler gets the client running the command and the arguments
+// following the name, and reads and writes keys through Keyspace. Commands are registered before Run
+// is called, usually from the init function of the package implementing them.
+//
+// Examples:
+//
+//	kv.RegisterCommand(network.CommandSpec{Name: "hello.upper", Arity: 2, Flags: []string{"readonly", "fast"},
+//		FirstKey: 1, LastKey: 1, KeyStep: 1}, func(client *network.Client, args []shared.Value) shared.Value {
+//		entry, ok := kv.Keyspace(client).Get(args[0].Bulk)
+//		if !ok {
+//			return shared.Null()
+//		}
+//		return shared.Value{Typ: "bulk", Bulk: strings.ToUpper(entry.String)}
+//	})
+func RegisterCommand(spec network.CommandSpec, handler network.CommandHandler) error {
+	if err := network.RegisterCommand(spec, handler); err != nil {
+		return err
+	}
+	// The append only file loader runs the commands of Handlers
+	Handlers[strings.ToUpper(spec.Name)] = handler
+	return nil
+}
+
+// UnregisterCommand removes a command added with RegisterCommand
+func UnregisterCommand(name string) error {
+	if err := network.UnregisterCommand(name); err != nil {
+		return err
+	}
+	delete(Handlers, strings.ToUpper(name))
+	return nil
+}
+
+// Entry is the value of a key as registered commands see it, in plain Go types whatever
+// encoding the server keeps it in. Type tells which field holds the value: "string", "list",
+// "set", "zset", "hash" or "stream". Entries are copies, changing one doesn't change the key
+// until it is written back. The entries of streams are not exposed: a stream written back
+// keeps the entries it had.
+type Entry struct {
+	Type    string
+	String  string
+	List    []string
+	Set     []string
+	ZSet    map[string]float64 // Member scores
+	Hash    map[string]string
+	Expires int64 // Unix time in milliseconds the key expires at, 0 for none
+}
+
+// entryOf copies the value of a key into an Entry
+func entryOf(m shared.MemoryEntry) Entry {
+	e := Entry{Expires: m.Expires}
+	switch {
+	case len(m.Array) > 0 || (m.List != nil && m.List.Size > 0):
+		e.Type, e.List = "list", slices.Clone(m.ListValues())
+	case len(m.Stream) > 0:
+		e.Type = "stream"
+	case m.SortedSet != nil:
+		e.Type, e.ZSet = "zset", m.SortedSet.Scores()
+	case len(m.Set) > 0:
+		e.Type, e.Set = "set", slices.Sorted(maps.Keys(m.Set))
+	case len(m.Hash) > 0:
+		e.Type, e.Hash = "hash", maps.Clone(m.Hash)
+	default:
+		e.Type, e.String = "string", m.Value
+	}
+	return e
+}
+
+// memoryEntry builds the value stored for e, keeping the stream entries of previous
+func (e Entry) memoryEntry(previous shared.MemoryEntry) shared.MemoryEntry {
+	m := shared.MemoryEntry{Expires: e.Expires}
+	switch e.Type {
+	case "list":
+		m.SetList(slices.Clone(e.List))
+	case "stream":
+		m.Stream = previous.Stream
+	case "zset":
+		m.SortedSet = shared.NewSortedSet()
+		for member, score := range e.ZSet {
+			m.SortedSet.Add(member, score)
+		}
+	case "set":
+		m.Set = make(map[string]struct{}, len(e.Set))
+		for _, member := range e.Set {
+			m.Set[member] = struct{}{}
+		}
+	case "hash":
+		m.Hash = maps.Clone(e.Hash)
+	default:
+		m.Value = e.String
+	}
+	return m
+}
+
+// KeyspaceAccess reads and writes keys on behalf of a command running on a connection. Reads
+// remove the expired keys they find, and writes are counted as changes of the command and
+// reported to server.NotifyKeyModified, so a registered write command is propagated to
+// replicas and the append only file and seen by the key hooks like the built-in ones.
+type KeyspaceAccess struct {
+	client *network.Client
+}
+
+// Keyspace returns the access to the keys of the commands run by client
+func Keyspace(client *network.Client) KeyspaceAccess {
+	return KeyspaceAccess{client: client}
+}
+
+// Get returns the entry of key, reporting false when it doesn't exist or expired
+func (k KeyspaceAccess) Get(key string) (Entry, bool) {
+	entry, exists := server.LookupKeyRead(key)
+	if !exists {
+		return Entry{}, false
+	}
+	return entryOf(entry), true
+}
+
+// Set stores entry under key, replacing any previous value
+func (k KeyspaceAccess) Set(key string, entry Entry) {
+	server.Memory.Update(key, func(previous shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		return entry.memoryEntry(previous), true
+	})
+	server.NotifyKeyModified(0, key, k.event())
+	k.client.MarkDirty(1)
+}
+
+// Update calls f with the entry of key while no other command can change it, and stores the
+// entry f returns when f reports it changed it. f must not access the keyspace.
+func (k KeyspaceAccess) Update(key string, f func(entry Entry, exists bool) (Entry, bool)) {
+	changed := false
+	server.Memory.Update(key, func(previous shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+		if exists && previous.Expires > 0 && time.Now().UnixMilli() > previous.Expires {
+			previous, exists = shared.MemoryEntry{}, false
+		}
+		var entry Entry
+		if exists {
+			entry = entryOf(previous)
+		}
+		entry, changed = f(entry, exists)
+		if !changed {
+			return previous, false
+		}
+		// Snapshots being serialized keep the value they share with the dataset
+		storage.CopyOnWrite(key)
+		return entry.memoryEntry(previous), true
+	})
+	if changed {
+		server.NotifyKeyModified(0, key, k.event())
+		k.client.MarkDirty(1)
+	}
+}
+
+// Delete removes key and reports whether it existed
+func (k KeyspaceAccess) Delete(key string) bool {
+	if _, exists := server.LookupKeyRead(key); !exists {
+		return false
+	}
+	if !server.Memory.Delete(key) {
+		return false
+	}
+	server.NotifyKeyModified(0, key, k.event())
+	k.client.MarkDirty(1)
+	return true
+}
+
+// event returns the name of the command running on the connection, which the key changes
+// are reported with
+func (k KeyspaceAccess) event() string {
+	if info, ok := k.client.Info(); ok && info.LastCommand != "" {
+		return info.LastCommand
+	}
+	return "module"
+}
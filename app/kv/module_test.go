@@ -0,0 +1,164 @@
+package kv
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// registerCommand registers a command for the test, removing it once the test is done
+func registerCommand(t *testing.T, spec network.CommandSpec, handler network.CommandHandler) {
+	t.Helper()
+	if err := RegisterCommand(spec, handler); err != nil {
+		t.Fatalf("Expected %s to be registered, got %v", spec.Name, err)
+	}
+	t.Cleanup(func() {
+		if err := UnregisterCommand(spec.Name); err != nil {
+			t.Errorf("Expected %s to be unregistered, got %v", spec.Name, err)
+		}
+	})
+}
+
+func TestRegisterCommand(t *testing.T) {
+	server.Memory.Clear()
+	t.Cleanup(server.Memory.Clear)
+	network.ACLSetUser("writer", "on", "nopass", "allkeys", "+@write")
+	t.Cleanup(func() { network.ACLDeleteUser("writer") })
+
+	get, _ := network.LookupCommand("GET")
+	registerCommand(t, network.CommandSpec{Name: "test.append", Arity: 3, Flags: []string{"write", "denyoom"}, FirstKey: 1, LastKey: 1, KeyStep: 1},
+		func(client *network.Client, args []shared.Value) shared.Value {
+			var length int
+			Keyspace(client).Update(args[0].Bulk, func(entry Entry, exists bool) (Entry, bool) {
+				entry.Type, entry.String = "string", entry.String+args[1].Bulk
+				length = len(entry.String)
+				return entry, true
+			})
+			return shared.Value{Typ: "integer", Num: length}
+		})
+
+	args := []shared.Value{{Typ: "bulk", Bulk: "greeting"}, {Typ: "bulk", Bulk: "hello"}}
+	if result := network.ExecuteCommand("TEST.APPEND", "test-conn", args); result.Typ != "integer" || result.Num != 5 {
+		t.Errorf("Expected 5, got %v", result)
+	}
+	if result := network.ExecuteCommand("GET", "test-conn", args[:1]); result.Str != "hello" {
+		t.Errorf("Expected greeting to be hello, got %v", result)
+	}
+	if result := network.ExecuteCommand("TEST.APPEND", "test-conn", args[:1]); result.Typ != "error" {
+		t.Errorf("Expected the arity of the spec to be checked, got %v", result)
+	}
+
+	// The command is described like the built-in ones, in the categories of its flags
+	if keys, err := network.ExtractKeys("TEST.APPEND", args); err != nil || len(keys) != 1 || keys[0] != "greeting" {
+		t.Errorf("Expected the key greeting, got %v, %v", keys, err)
+	}
+	info := network.ExecuteCommand("COMMAND", "test-conn", []shared.Value{{Typ: "bulk", Bulk: "INFO"}, {Typ: "bulk", Bulk: "test.append"}})
+	if len(info.Array) != 1 || info.Array[0].Typ != "array" {
+		t.Errorf("Expected COMMAND INFO to describe the command, got %v", info)
+	}
+	if user, _ := network.ACLGetUser("writer"); !user.Commands["test.append"] {
+		t.Error("Expected +@write given before the command was registered to allow it")
+	}
+
+	// The specs looked up before stay those of their command once the table is sorted again
+	if get.Name != "get" {
+		t.Errorf("Expected the spec of GET to stay valid, got %s", get.Name)
+	}
+
+	if err := RegisterCommand(network.CommandSpec{Name: "test.append", Arity: 3}, Handlers["GET"]); err == nil {
+		t.Error("Expected registering the command twice to fail")
+	}
+	if err := RegisterCommand(network.CommandSpec{Name: "get", Arity: 2}, Handlers["GET"]); err == nil {
+		t.Error("Expected registering a built-in command to fail")
+	}
+	if err := RegisterCommand(network.CommandSpec{Name: "test.noarity"}, Handlers["GET"]); err == nil {
+		t.Error("Expected a command without arity to be refused")
+	}
+	if err := UnregisterCommand("get"); err == nil {
+		t.Error("Expected unregistering a built-in command to fail")
+	}
+}
+
+func TestUnregisterCommand(t *testing.T) {
+	spec := network.CommandSpec{Name: "test.gone", Arity: 1}
+	if err := RegisterCommand(spec, Handlers["PING"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnregisterCommand("TEST.GONE"); err != nil {
+		t.Fatalf("Expected the command to be unregistered, got %v", err)
+	}
+
+	if _, ok := network.LookupCommand("test.gone"); ok {
+		t.Error("Expected the command to be gone from the table")
+	}
+	if result := network.ExecuteCommand("TEST.GONE", "test-conn", nil); result.Typ != "error" {
+		t.Errorf("Expected the command to be unknown, got %v", result)
+	}
+	// It can be registered again
+	registerCommand(t, spec, Handlers["PING"])
+}
+
+func TestKeyspaceEntries(t *testing.T) {
+	server.Memory.Clear()
+	t.Cleanup(server.Memory.Clear)
+	keyspace := Keyspace(network.ClientOf("test-conn"))
+
+	entries := map[string]Entry{
+		"string": {Type: "string", String: "v"},
+		"list":   {Type: "list", List: []string{"a", "b"}},
+		"set":    {Type: "set", Set: []string{"a", "b"}},
+		"zset":   {Type: "zset", ZSet: map[string]float64{"a": 1, "b": 2}},
+		"hash":   {Type: "hash", Hash: map[string]string{"f": "v"}},
+	}
+	for key, entry := range entries {
+		keyspace.Set(key, entry)
+		// The keys are stored like the built-in commands store them
+		if result := network.ExecuteCommand("TYPE", "test-conn", []shared.Value{{Typ: "bulk", Bulk: key}}); result.Str != entry.Type {
+			t.Errorf("Expected %s to be a %s, got %v", key, entry.Type, result)
+		}
+		if got, ok := keyspace.Get(key); !ok || !reflect.DeepEqual(got, entry) {
+			t.Errorf("Expected %s to be %+v, got %+v", key, entry, got)
+		}
+	}
+
+	// Streams keep their entries when written back
+	server.Memory.Set("stream", shared.MemoryEntry{Stream: []shared.StreamEntry{{ID: "1-1"}}})
+	keyspace.Update("stream", func(entry Entry, exists bool) (Entry, bool) {
+		entry.Expires = 4102444800000
+		return entry, exists && entry.Type == "stream"
+	})
+	if entry, _ := server.Memory.Get("stream"); len(entry.Stream) != 1 || entry.Expires != 4102444800000 {
+		t.Errorf("Expected the stream to keep its entry and get an expiration, got %+v", entry)
+	}
+}
+
+func TestKeyspaceUpdateKeepsSnapshots(t *testing.T) {
+	server.Memory.Clear()
+	t.Cleanup(server.Memory.Clear)
+	keyspace := Keyspace(network.ClientOf("test-conn"))
+	keyspace.Set("list", Entry{Type: "list", List: []string{"a"}})
+
+	snapshot := storage.TakeSnapshot()
+	defer snapshot.Release()
+	keyspace.Update("list", func(entry Entry, exists bool) (Entry, bool) {
+		entry.List = append(entry.List, "b")
+		return entry, true
+	})
+
+	// The snapshot taken before the update still holds the list as it was
+	var buf bytes.Buffer
+	if err := snapshot.WriteRDB(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.ParseRDBData(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := keyspace.Get("list"); !reflect.DeepEqual(entry.List, []string{"a"}) {
+		t.Errorf("Expected the list of the snapshot, got %v", entry.List)
+	}
+}
@@ -0,0 +1,82 @@
+package kv
+
+import (
+	"net"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// pipeClient is a client connected to the server through a pipe, served by handleConnection
+// like the connections of other clients, for the listeners translating another protocol to
+// commands: its commands are checked, logged and replicated like those of any client.
+type pipeClient struct {
+	conn   net.Conn
+	reader *protocol.Resp
+	done   chan struct{} // Closed once handleConnection returned
+}
+
+// pipeConn is the server side of the pipe of a client, addressed like the connection the
+// client came from, so it is listed and protected like the clients of that address
+type pipeConn struct {
+	net.Conn
+	remote, local net.Addr
+}
+
+func (c pipeConn) RemoteAddr() net.Addr { return c.remote }
+func (c pipeConn) LocalAddr() net.Addr  { return c.local }
+
+// connectPipeClient connects a client with the addresses of the connection it serves
+func connectPipeClient(remote, local net.Addr) *pipeClient {
+	serverSide, clientSide := net.Pipe()
+	c := &pipeClient{conn: clientSide, reader: protocol.NewResp(clientSide), done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		handleConnection(pipeConn{Conn: serverSide, remote: remote, local: local})
+	}()
+	return c
+}
+
+// do sends a command and returns its reply, skipping the messages pushed to the client
+func (c *pipeClient) do(command string, args ...string) (protocol.Value, error) {
+	for {
+		reply, err := c.send(command, args...)
+		if err != nil || reply.Typ != "push" {
+			return reply, err
+		}
+		command, args = "", nil
+	}
+}
+
+// send sends a command, unless command is empty, and reads the next reply
+func (c *pipeClient) send(command string, args ...string) (protocol.Value, error) {
+	if command != "" {
+		values := make([]protocol.Value, len(args))
+		for i, arg := range args {
+			values[i] = protocol.Value{Typ: "bulk", Bulk: arg}
+		}
+		if _, err := c.conn.Write(protocol.AppendCommand(nil, command, values)); err != nil {
+			return protocol.Value{}, err
+		}
+	}
+	return c.reader.ReadReply()
+}
+
+// disconnect closes the pipe, which ends handleConnection and fails the command in progress
+func (c *pipeClient) disconnect() {
+	c.conn.Close()
+}
+
+// close disconnects the client and waits until the server forgot it, since the next client
+// of the same connection comes from the same address
+func (c *pipeClient) close() {
+	c.disconnect()
+	<-c.done
+}
+
+// replyText returns the text of a string reply, which may be a simple or a bulk string
+func replyText(v protocol.Value) string {
+	if v.Typ == "bulk" {
+		return v.Bulk
+	}
+	return v.Str
+}
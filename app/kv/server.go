@@ -0,0 +1,469 @@
+// Package kv runs the server: it loads the dataset, starts the background jobs and serves
+// the clients, so the server can be embedded in another program as well as run by main.
+package kv
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/network"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
+)
+
+// serverLog logs the messages of the server itself, without a subsystem prefix
+var serverLog = logger.New("")
+
+// aofLoaderConnID is the connection ID commands run with while the append only file is loaded
+const aofLoaderConnID = "aof-loader"
+
+// running is set while a Server runs. The dataset, the configuration and the clients are
+// shared by the whole process, so a single server can run at a time: servers run one after
+// the other, never side by side.
+var running atomic.Bool
+
+// Server serves clients with the configuration of server.StoreState, which command line flags
+// fill and the options of the server change when Run is called. The options and the hooks
+// belong to the server: they apply while it runs and are removed when Run returns, along with
+// the background jobs and the clients. server.StoreState is restored as it was before Run,
+// dropping the changes of CONFIG SET too, so another Run starts afresh from the files on disk.
+//
+// Examples:
+//
+//	srv := kv.NewServer(kv.WithPort("7000"), kv.WithDir("/tmp/data"))
+//	err := srv.Run(ctx)   // Serves clients until ctx is canceled
+type Server struct {
+	opts         []Option
+	saved        shared.State // server.StoreState before the options were applied
+	beforeHooks  []network.CommandHook
+	afterHooks   []network.CommandHook
+	expiredHooks []server.KeyHook
+	evictedHooks []server.KeyHook
+	changedHooks []server.KeyEventHook
+
+	listeners []net.Listener
+	closers   []io.Closer // Memcached listeners and HTTP servers of the gateway, metrics and profiles
+	stops     []func()    // Stop the background jobs
+
+	accepting sync.WaitGroup // Goroutines accepting clients
+	serving   sync.WaitGroup // Goroutines serving a client
+	connsMu   sync.Mutex
+	conns     map[net.Conn]struct{} // Connections accepted and not closed yet
+}
+
+// Option changes the configuration a Server starts with
+type Option func(*shared.State)
+
+// WithPort sets the port clients connect to
+func WithPort(port string) Option {
+	return func(s *shared.State) { s.Port = port }
+}
+
+// WithBind sets the addresses listened on, separated by spaces
+func WithBind(addresses string) Option {
+	return func(s *shared.State) { s.Bind = addresses }
+}
+
+// WithDir sets the directory of the RDB and append only files
+func WithDir(dir string) Option {
+	return func(s *shared.State) { s.ConfigDir = dir }
+}
+
+// WithDbfilename sets the name of the RDB file
+func WithDbfilename(filename string) Option {
+	return func(s *shared.State) { s.ConfigDbfilename = filename }
+}
+
+// WithReplicaOf makes the server a replica of the master at "<host> <port>"
+func WithReplicaOf(master string) Option {
+	return func(s *shared.State) { s.ReplicaOf = master }
+}
+
+// WithAppendOnly enables or disables the append only file
+func WithAppendOnly(enabled bool) Option {
+	return func(s *shared.State) { s.AppendOnly = enabled }
+}
+
+// WithAuditLog appends the write and administrative commands of clients to the file at path,
+// see network.SetAuditLogFile
+func WithAuditLog(path string) Option {
+	return func(s *shared.State) { s.AuditLogFile = path }
+}
+
+// NewServer returns a server configured by server.StoreState with opts applied to it when it
+// runs
+func NewServer(opts ...Option) *Server {
+	return &Server{opts: opts}
+}
+
+// BeforeCommand registers a hook run before every command, which may refuse it by returning
+// an error, like a custom rate limiter. Hooks are registered before Run is called.
+//
+// Examples:
+//
+//	srv.BeforeCommand(func(connID, command string, args []shared.Value) error {
+//		if command == "FLUSHALL" {
+//			return errors.New("ERR FLUSHALL is disabled")
+//		}
+//		return nil
+//	})
+func (s *Server) BeforeCommand(hook network.CommandHook) {
+	s.beforeHooks = append(s.beforeHooks, hook)
+}
+
+// AfterCommand registers a hook run after every command, like a counter of the commands run.
+// Hooks are registered before Run is called. The writes and administrative commands are
+// recorded by the audit log of WithAuditLog rather than by a hook.
+//
+// Examples:
+//
+//	srv.AfterCommand(func(connID, command string, args []shared.Value) error {
+//		commandsRun.Add(1)
+//		return nil
+//	})
+func (s *Server) AfterCommand(hook network.CommandHook) {
+	s.afterHooks = append(s.afterHooks, hook)
+}
+
+// OnExpired registers a hook called with each key removed because its expiration passed. Key
+// hooks run on the goroutine that changed the key, before the command or the expire cycle
+// goes on, so they should be quick and must not run commands themselves. Hooks are registered
+// before Run is called.
+//
+// Examples:
+//
+//	srv.OnExpired(func(key string) { sessions.Forget(key) })
+func (s *Server) OnExpired(hook func(key string)) {
+	s.expiredHooks = append(s.expiredHooks, hook)
+}
+
+// OnEvicted registers a hook called with each key evicted to free memory. The server doesn't
+// evict keys yet, maxmemory is only reported, so the hook is only called once an eviction
+// policy removes keys.
+func (s *Server) OnEvicted(hook func(key string)) {
+	s.evictedHooks = append(s.evictedHooks, hook)
+}
+
+// OnKeyChanged registers a hook called with each key a write command changed, with the
+// lowercase name of the command as event, and with the keys that expired or were evicted,
+// with "expired" and "evicted". Writes that change nothing, like ZADD of a member with the
+// score it already has, and commands without keys, like FUNCTION LOAD, are not reported.
+//
+// Examples:
+//
+//	srv.OnKeyChanged(func(key, event string) {
+//		// Called with ("user:1", "set") after SET user:1 Ada, with ("jobs", "rpush") after
+//		// RPUSH jobs send-mail and with ("scores", "zadd") after ZADD scores 10 Ada
+//		cache.Invalidate(key)
+//	})
+func (s *Server) OnKeyChanged(hook func(key string, event string)) {
+	s.changedHooks = append(s.changedHooks, hook)
+}
+
+// Run loads the dataset, starts the background jobs and serves clients until ctx is
+// canceled. It then closes the listeners and the clients, stops the background jobs and
+// flushes the append only file before returning, so the server may run again. SHUTDOWN still
+// ends the whole process.
+func (s *Server) Run(ctx context.Context) error {
+	if !running.CompareAndSwap(false, true) {
+		return errors.New("a server is already running in this process")
+	}
+	defer running.Store(false)
+
+	err := s.start()
+	if err == nil {
+		for _, l := range s.listeners {
+			s.accepting.Add(1)
+			go func() {
+				defer s.accepting.Done()
+				s.acceptConnections(l)
+			}()
+		}
+		<-ctx.Done()
+		serverLog.Noticef("Stopping the server")
+	}
+	// Whatever start did before failing is undone as well
+	if stopErr := s.stop(); err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+// start applies the configuration, loads the dataset, starts the background jobs and opens
+// the listeners. What it started is recorded in s for stop.
+func (s *Server) start() error {
+	state := server.StoreState
+	s.saved = *state
+	for _, opt := range s.opts {
+		opt(state)
+	}
+	for _, hook := range s.beforeHooks {
+		network.AddBeforeCommandHook(hook)
+	}
+	for _, hook := range s.afterHooks {
+		network.AddAfterCommandHook(hook)
+	}
+	for _, hook := range s.expiredHooks {
+		server.AddExpiredHook(hook)
+	}
+	for _, hook := range s.evictedHooks {
+		server.AddEvictedHook(hook)
+	}
+	for _, hook := range s.changedHooks {
+		server.AddKeyChangedHook(hook)
+	}
+
+	if state.ClusterEnabled && state.ReplicaOf != "" {
+		return fmt.Errorf("replicaof is not allowed in cluster mode")
+	}
+	if state.ReplicaOf != "" {
+		state.Role = "slave"
+	} else {
+		state.Role = "master"
+		state.MasterReplID = generateReplID()
+	}
+
+	if err := configureLogging(); err != nil {
+		return fmt.Errorf("configuring logging: %w", err)
+	}
+	serverLog.Noticef("Starting Redis server on port %s, role: %s", state.Port, state.Role)
+
+	if err := commands.ApplyProtoLimits(); err != nil {
+		return fmt.Errorf("configuring the protocol limits: %w", err)
+	}
+	if err := commands.ApplyEncodingLimits(); err != nil {
+		return fmt.Errorf("configuring the encoding limits: %w", err)
+	}
+
+	// Users come from the ACL file when there is one, requirepass only sets the default user's password
+	if state.ACLFile != "" {
+		if err := network.LoadACLFile(state.ACLFile); err != nil {
+			return fmt.Errorf("loading the ACL file: %w", err)
+		}
+	} else if state.RequirePass != "" {
+		network.ACLSetDefaultPassword(state.RequirePass)
+	}
+
+	if err := network.SetAuditLogFile(state.AuditLogFile); err != nil {
+		return fmt.Errorf("opening the audit log: %w", err)
+	}
+
+	// Load the dataset, from the append only file when it is enabled, before any listener
+	// opens. A replica serves the dataset it saved until the snapshot of its master replaces it.
+	// The keys of a server that ran before are dropped, the files hold what it persisted.
+	server.Memory.Clear()
+	if state.AppendOnly {
+		if err := storage.LoadAppendOnlyFile(state.ConfigDir, state.AppendFilename, executeLoadedCommand); err != nil {
+			return fmt.Errorf("loading the append only file: %w", err)
+		}
+	} else if err := storage.LoadRDBFile(state.ConfigDir, state.ConfigDbfilename); err != nil {
+		serverLog.Warningf("Failed to load RDB file: %v", err)
+	}
+
+	if state.AppendOnly {
+		if err := storage.OpenAppendOnlyFile(); err != nil {
+			return fmt.Errorf("opening the append only file: %w", err)
+		}
+	}
+
+	if state.ClusterEnabled {
+		network.ClusterInit(state.ClusterAnnounceIP, state.Port)
+	}
+
+	// Commands run on a single goroutine from the first client on, replicated writes included
+	if state.SingleWriter {
+		network.StartExecutor()
+	}
+
+	s.stops = append(s.stops, storage.StartSaveScheduler(), server.StartActiveExpire(), network.StartClientReaper())
+
+	metrics, err := commands.StartMetricsServer()
+	if err != nil {
+		return fmt.Errorf("starting the metrics server: %w", err)
+	}
+	if metrics != nil {
+		s.closers = append(s.closers, metrics)
+	}
+	profiling, err := commands.StartProfilingServer()
+	if err != nil {
+		return fmt.Errorf("starting the profiling server: %w", err)
+	}
+	if profiling != nil {
+		s.closers = append(s.closers, profiling)
+	}
+	gateway, err := startHTTPGateway()
+	if err != nil {
+		return fmt.Errorf("starting the HTTP gateway: %w", err)
+	}
+	if gateway != nil {
+		s.closers = append(s.closers, gateway)
+	}
+	memcachedListeners, err := startMemcached()
+	if err != nil {
+		return fmt.Errorf("starting the memcached listener: %w", err)
+	}
+	for _, l := range memcachedListeners {
+		s.closers = append(s.closers, l)
+	}
+
+	network.HandleReplicaMode(state.Port, state.Role, state.ReplicaOf, network.ExecuteCommand)
+
+	listeners, err := listen(state.Port)
+	if err != nil {
+		return err
+	}
+	s.listeners = listeners
+	network.ListenerSet(listeners...)
+	if network.ProtectedModeActive() {
+		serverLog.Warningf("No bind address and no password are set, protected mode only serves loopback clients")
+	}
+	return nil
+}
+
+// stop undoes what start did: it closes the listeners, the link to the master, the replicas
+// and the clients, waits for the goroutines serving them, stops the background jobs, removes
+// the hooks, restores the configuration and flushes the append only file
+func (s *Server) stop() error {
+	for _, c := range s.closers {
+		c.Close()
+	}
+	network.CloseForShutdown()
+	s.accepting.Wait()
+
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsMu.Unlock()
+	// Killing the clients wakes the blocked ones, and ends those of the memcached protocol
+	// and of the gateway
+	for _, connID := range network.ClientIDs() {
+		network.ClientKill(connID, false)
+	}
+	s.serving.Wait()
+
+	for _, stop := range s.stops {
+		stop()
+	}
+	network.StopExecutor()
+	storage.WaitBackgroundJobs()
+	network.ClearCommandHooks()
+	server.ClearKeyHooks()
+	s.listeners, s.closers, s.stops = nil, nil, nil
+
+	network.SetAuditLogFile("")
+	err := storage.CloseAppendOnlyFile()
+	server.SetStoreState(s.saved)
+	if err != nil {
+		return fmt.Errorf("flushing the append only file: %w", err)
+	}
+	return nil
+}
+
+// generateReplID generates a random 40-character alphanumeric string for replication ID
+func generateReplID() string {
+	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	const length = 40
+
+	b := make([]byte, length)
+	rand.Read(b)
+
+	for i := range b {
+		b[i] = charset[b[i]%byte(len(charset))]
+	}
+
+	return string(b)
+}
+
+// configureLogging applies the loglevel and logfile options
+func configureLogging() error {
+	level, err := logger.ParseLevel(server.StoreState.LogLevel)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(level)
+	return logger.SetLogFile(server.StoreState.LogFile)
+}
+
+// listen opens a listener on every bind address, failing when one that is not optional fails
+func listen(port string) ([]net.Listener, error) {
+	addresses, optional := network.BindAddresses(server.StoreState.Bind, port)
+
+	var listeners []net.Listener
+	for i, address := range addresses {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			if optional[i] {
+				serverLog.Warningf("Failed to bind to %s: %v", address, err)
+				continue
+			}
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to bind to %s: %w", address, err)
+		}
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("failed to bind to any address on port %s", port)
+	}
+	return listeners, nil
+}
+
+// acceptConnections serves the clients connecting to a listener until it is closed
+func (s *Server) acceptConnections(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			serverLog.Warningf("Error accepting connection: %v", err)
+			continue
+		}
+		if err := network.ApplyKeepalive(conn); err != nil {
+			serverLog.Verbosef("Failed to configure TCP keepalive: %v", err)
+		}
+		s.connsMu.Lock()
+		if s.conns == nil {
+			s.conns = make(map[net.Conn]struct{})
+		}
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+
+		s.serving.Add(1)
+		go func() {
+			defer s.serving.Done()
+			handleConnection(conn)
+			s.connsMu.Lock()
+			delete(s.conns, conn)
+			s.connsMu.Unlock()
+		}()
+	}
+}
+
+// executeLoadedCommand runs a command replayed from the append only file.
+// It calls the handler directly: replication and write checks don't apply while loading.
+func executeLoadedCommand(command string, args []shared.Value) shared.Value {
+	handler, ok := Handlers[command]
+	if !ok {
+		return shared.Value{Typ: "error", Str: "ERR unknown command '" + command + "'"}
+	}
+	// Handlers count on the arguments their arity promises
+	if err := network.ValidateCommand(command, args); err != "" {
+		return shared.Value{Typ: "error", Str: err}
+	}
+	client := network.ClientOf(aofLoaderConnID)
+	result := handler(client, args)
+	client.TakeDirty()
+	return result
+}
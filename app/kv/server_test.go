@@ -0,0 +1,183 @@
+package kv
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// freePort returns a port nothing listens on
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// startServer runs srv until the test stops it with the returned function, which returns the
+// error of Run
+func startServer(t *testing.T, srv *Server) (stop func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+	return func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected Run to return once its context is canceled")
+			return nil
+		}
+	}
+}
+
+// dial connects to the server on port, waiting for it to listen
+func dial(t *testing.T, port string) net.Conn {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+		if err == nil {
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the server to listen on %s, got %v", port, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// do sends a command over conn and returns its reply
+func do(t *testing.T, conn net.Conn, args ...string) protocol.Value {
+	t.Helper()
+	values := make([]protocol.Value, len(args)-1)
+	for i, arg := range args[1:] {
+		values[i] = protocol.Value{Typ: "bulk", Bulk: arg}
+	}
+	if _, err := conn.Write(protocol.AppendCommand(nil, args[0], values)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := protocol.NewResp(conn).ReadReply()
+	if err != nil {
+		t.Fatalf("Expected a reply to %v, got %v", args, err)
+	}
+	return reply
+}
+
+func TestRunTwice(t *testing.T) {
+	dir := t.TempDir()
+	for run := 1; run <= 2; run++ {
+		port := freePort(t)
+		srv := NewServer(WithPort(port), WithBind("127.0.0.1"), WithDir(dir), WithAppendOnly(true))
+		var mu sync.Mutex
+		var changed []string
+		srv.OnKeyChanged(func(key, event string) {
+			mu.Lock()
+			changed = append(changed, event+" "+key)
+			mu.Unlock()
+		})
+		stop := startServer(t, srv)
+
+		conn := dial(t, port)
+		// The counter persisted by the first run is loaded by the second one
+		if reply := do(t, conn, "INCR", "runs"); reply.Typ != "integer" || reply.Num != run {
+			t.Errorf("Run %d: expected INCR to return %d, got %+v", run, run, reply)
+		}
+		if err := stop(); err != nil {
+			t.Fatalf("Run %d: expected Run to return nil, got %v", run, err)
+		}
+
+		// The clients are closed with the server
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Errorf("Run %d: expected the client to be closed", run)
+		}
+		conn.Close()
+
+		// The hooks of a server are only called while it runs, the second one sees the INCR
+		// replayed from the append only file and its own
+		mu.Lock()
+		if len(changed) != run || changed[run-1] != "incr runs" {
+			t.Errorf("Run %d: expected the hook to see %d INCR, got %v", run, run, changed)
+		}
+		mu.Unlock()
+	}
+	server.Memory.Clear()
+}
+
+func TestRunStopsBackgroundJobs(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	port := freePort(t)
+	stop := startServer(t, NewServer(WithPort(port), WithBind("127.0.0.1"), WithDir(t.TempDir()), WithAppendOnly(false)))
+	conn := dial(t, port)
+	defer conn.Close()
+	if reply := do(t, conn, "PING"); reply.Str != "PONG" {
+		t.Errorf("Expected PONG, got %+v", reply)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("Expected Run to return nil, got %v", err)
+	}
+
+	// The goroutines of the jobs, of the listeners and of the client are gone
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("Expected %d goroutines after Run returned, got %d:\n%s", before, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunRefusesASecondServer(t *testing.T) {
+	port := freePort(t)
+	stop := startServer(t, NewServer(WithPort(port), WithBind("127.0.0.1"), WithDir(t.TempDir()), WithAppendOnly(false)))
+	dial(t, port).Close()
+
+	if err := NewServer(WithPort(freePort(t))).Run(context.Background()); err == nil {
+		t.Error("Expected a second server running at the same time to be refused")
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("Expected Run to return nil, got %v", err)
+	}
+}
+
+func TestRunRestoresTheConfiguration(t *testing.T) {
+	before := *server.StoreState
+
+	port, dir := freePort(t), t.TempDir()
+	stop := startServer(t, NewServer(WithPort(port), WithBind("127.0.0.1"), WithDir(dir), WithAppendOnly(false)))
+	conn := dial(t, port)
+	defer conn.Close()
+	if reply := do(t, conn, "CONFIG", "SET", "slowlog-max-len", "7"); reply.Str != "OK" {
+		t.Fatalf("Expected OK, got %+v", reply)
+	}
+	if server.StoreState.Port != port || server.StoreState.ConfigDir != dir || server.StoreState.SlowlogMaxLen != 7 {
+		t.Errorf("Expected the options to apply while the server runs, got port %s, dir %s and slowlog-max-len %d",
+			server.StoreState.Port, server.StoreState.ConfigDir, server.StoreState.SlowlogMaxLen)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("Expected Run to return nil, got %v", err)
+	}
+
+	// The options and CONFIG SET are undone once Run returns
+	after := server.StoreState
+	if after.Port != before.Port || after.Bind != before.Bind || after.ConfigDir != before.ConfigDir ||
+		after.AppendOnly != before.AppendOnly || after.SlowlogMaxLen != before.SlowlogMaxLen || after.Role != before.Role {
+		t.Errorf("Expected the configuration to be restored to %+v, got %+v", before, *after)
+	}
+}
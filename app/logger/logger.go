@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log message, messages below the configured level are dropped
+type Level int
+
+const (
+	Debug Level = iota
+	Verbose
+	Notice
+	Warning
+)
+
+// Level names as accepted by the loglevel option, and the marks prefixing messages of each level
+var (
+	levelNames = []string{"debug", "verbose", "notice", "warning"}
+	levelMarks = []byte{'.', '-', '*', '#'}
+)
+
+// String returns the name of the level, like notice
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the level with the given name, case-insensitively
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return Notice, fmt.Errorf("unknown log level '%s'", name)
+}
+
+// Output state, shared by every logger
+var (
+	mu      sync.Mutex
+	level             = Notice
+	out     io.Writer = os.Stdout
+	logFile *os.File
+)
+
+// SetLevel sets the minimum level of the messages written
+func SetLevel(l Level) {
+	mu.Lock()
+	level = l
+	mu.Unlock()
+}
+
+// GetLevel returns the minimum level of the messages written
+func GetLevel() Level {
+	mu.Lock()
+	defer mu.Unlock()
+	return level
+}
+
+// SetLogFile appends messages to the file at path from now on, an empty path writes them to stdout
+func SetLogFile(path string) error {
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if path != "" {
+		var err error
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("Can't open the log file: %v", err)
+		}
+		w = file
+	}
+
+	mu.Lock()
+	if logFile != nil {
+		logFile.Close()
+	}
+	out, logFile = w, file
+	mu.Unlock()
+	return nil
+}
+
+// SetOutput writes messages to w from now on
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+	out = w
+	mu.Unlock()
+}
+
+// Logger writes the messages of a subsystem, prefixed with its name
+type Logger struct {
+	subsystem string
+}
+
+// New returns a logger for a subsystem like replication or aof, an empty name adds no prefix
+func New(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
+}
+
+// Debugf logs a message useful when developing or troubleshooting
+func (l *Logger) Debugf(format string, args ...interface{}) { l.logf(Debug, format, args...) }
+
+// Verbosef logs a message that is rarely useful but not as noisy as debug ones
+func (l *Logger) Verbosef(format string, args ...interface{}) { l.logf(Verbose, format, args...) }
+
+// Noticef logs a message worth seeing in production
+func (l *Logger) Noticef(format string, args ...interface{}) { l.logf(Notice, format, args...) }
+
+// Warningf logs an error or an unexpected condition
+func (l *Logger) Warningf(format string, args ...interface{}) { l.logf(Warning, format, args...) }
+
+// logf writes a message at the given level, like
+// 4213 15 Oct 2026 10:31:07.123 * [replication] Connected to master 127.0.0.1:6379
+func (l *Logger) logf(msgLevel Level, format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	if msgLevel < level {
+		return
+	}
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "%d %s %c ", os.Getpid(), time.Now().Format("02 Jan 2006 15:04:05.000"), levelMarks[msgLevel])
+	if l.subsystem != "" {
+		fmt.Fprintf(&line, "[%s] ", l.subsystem)
+	}
+	fmt.Fprintf(&line, format, args...)
+	if !strings.HasSuffix(format, "\n") {
+		line.WriteByte('\n')
+	}
+	io.WriteString(out, line.String())
+}
@@ -0,0 +1,140 @@
+package lua
+
+// The parser turns a script in a tree of statements and expressions. Names are resolved
+// while parsing: a local variable is a slot of the frame of its function, a variable of an
+// enclosing function is an upvalue captured by the closure, and any other name is a global.
+
+// expr is an expression
+type expr interface{}
+
+// stmt is a statement
+type stmt interface{}
+
+// block is a list of statements
+type block []stmt
+
+type (
+	nilExpr    struct{}
+	trueExpr   struct{}
+	falseExpr  struct{}
+	varargExpr struct{}
+	numberExpr struct{ value float64 }
+	stringExpr struct{ value string }
+	localExpr  struct {
+		name string
+		slot int
+	}
+	upvalueExpr struct {
+		name  string
+		index int
+	}
+	globalExpr struct {
+		name string
+		line int
+	}
+	indexExpr struct {
+		object expr
+		key    expr
+		line   int
+	}
+	callExpr struct {
+		fn   expr
+		args []expr
+		line int
+	}
+	methodCallExpr struct {
+		object expr
+		name   string
+		args   []expr
+		line   int
+	}
+	functionExpr struct{ proto *funcProto }
+	parenExpr    struct{ inner expr } // Keeps only the first value of a call or of ...
+	binaryExpr   struct {
+		op          string
+		left, right expr
+		line        int
+	}
+	unaryExpr struct {
+		op      string
+		operand expr
+		line    int
+	}
+	andExpr   struct{ left, right expr }
+	orExpr    struct{ left, right expr }
+	tableExpr struct {
+		fields []tableField
+		line   int
+	}
+)
+
+// tableField is a field of a table constructor, without key for a positional field
+type tableField struct {
+	key   expr // nil for a positional field
+	value expr
+}
+
+type (
+	localStmt struct {
+		slots []int
+		exprs []expr
+	}
+	assignStmt struct {
+		targets []expr
+		exprs   []expr
+		line    int
+	}
+	callStmt  struct{ call expr }
+	doStmt    struct{ body block }
+	whileStmt struct {
+		cond expr
+		body block
+	}
+	repeatStmt struct {
+		body block
+		cond expr
+	}
+	ifStmt struct {
+		conds  []expr
+		blocks []block
+		orElse block // nil without else
+	}
+	numericForStmt struct {
+		slot               int
+		start, limit, step expr // step is nil when omitted
+		body               block
+		line               int
+	}
+	genericForStmt struct {
+		slots []int
+		exprs []expr
+		body  block
+		line  int
+	}
+	localFunctionStmt struct {
+		slot  int
+		proto *funcProto
+	}
+	returnStmt struct{ exprs []expr }
+	breakStmt  struct{}
+)
+
+// funcProto is a parsed function: what closures of it share
+type funcProto struct {
+	chunk    string // Name of the script in error messages
+	name     string // Name in error messages, like "f" for function f()
+	params   []int  // Slots of the parameters
+	isVararg bool
+	slots    int // Number of local slots of a frame
+	upvalues []upvalueDesc
+	body     block
+	line     int
+}
+
+// upvalueDesc says where a closure finds an upvalue when it is created: in a local slot of
+// the enclosing function, or in one of its upvalues
+type upvalueDesc struct {
+	name      string
+	fromLocal bool
+	index     int
+}
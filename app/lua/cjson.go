@@ -0,0 +1,387 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxJSONDepth bounds the nesting of the values cjson encodes and decodes
+const maxJSONDepth = 1000
+
+// Null is cjson.null, the value of JSON null in decoded tables
+var Null = &Userdata{Name: "cjson.null"}
+
+func openCJSON(s *State) {
+	lib := openLibrary(s, "cjson", map[string]func(*State, []Value) ([]Value, error){
+		"decode": cjsonDecode,
+		"encode": cjsonEncode,
+	})
+	lib.Set("null", Null)
+}
+
+func cjsonEncode(s *State, args []Value) ([]Value, error) {
+	if len(args) != 1 {
+		return nil, argError(0, "encode", "expected 1 argument")
+	}
+	var b strings.Builder
+	if err := encodeJSON(&b, args[0], 0); err != nil {
+		return nil, err
+	}
+	return []Value{b.String()}, nil
+}
+
+// encodeJSON writes a value as JSON. Tables whose keys are all positive integers are
+// arrays, other tables objects.
+func encodeJSON(b *strings.Builder, v Value, depth int) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return errors.New("Cannot serialise number: must not be NaN or Inf")
+		}
+		b.WriteString(FormatNumber(v))
+	case string:
+		writeJSONString(b, v)
+	case *Table:
+		depth++
+		if depth > maxJSONDepth {
+			return fmt.Errorf("Cannot serialise, excessive nesting (%d)", depth)
+		}
+		n, err := jsonArrayLength(v)
+		if err != nil {
+			return err
+		}
+		if n >= 0 {
+			b.WriteByte('[')
+			for i := 1; i <= n; i++ {
+				if i > 1 {
+					b.WriteByte(',')
+				}
+				if err := encodeJSON(b, v.Get(float64(i)), depth); err != nil {
+					return err
+				}
+			}
+			b.WriteByte(']')
+			return nil
+		}
+		b.WriteByte('{')
+		first := true
+		v.ForEach(func(key Value, value Value) {
+			if err != nil {
+				return
+			}
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			switch key := key.(type) {
+			case string:
+				writeJSONString(b, key)
+			case float64:
+				writeJSONString(b, FormatNumber(key))
+			default:
+				err = errors.New("Cannot serialise table: table key must be a number or string")
+				return
+			}
+			b.WriteByte(':')
+			err = encodeJSON(b, value, depth)
+		})
+		if err != nil {
+			return err
+		}
+		b.WriteByte('}')
+	default:
+		if v == Null {
+			b.WriteString("null")
+			return nil
+		}
+		return fmt.Errorf("Cannot serialise %s: type not supported", TypeName(v))
+	}
+	return nil
+}
+
+// jsonArrayLength returns the length of a table encoded as an array, -1 for a table encoded
+// as an object
+func jsonArrayLength(t *Table) (int, error) {
+	maxKey, items := 0, 0
+	isArray := true
+	t.ForEach(func(key Value, _ Value) {
+		n, ok := key.(float64)
+		if !ok || n < 1 || n != math.Floor(n) {
+			isArray = false
+			return
+		}
+		if int(n) > maxKey {
+			maxKey = int(n)
+		}
+		items++
+	})
+	if !isArray || items == 0 {
+		return -1, nil
+	}
+	if maxKey > items*2 && maxKey > 10 {
+		return 0, errors.New("Cannot serialise table: excessive sparse array")
+	}
+	return maxKey, nil
+}
+
+// writeJSONString writes a string between quotes, escaping it
+func writeJSONString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', '/':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\b':
+			b.WriteString("\\b")
+		case '\f':
+			b.WriteString("\\f")
+		case '\n':
+			b.WriteString("\\n")
+		case '\r':
+			b.WriteString("\\r")
+		case '\t':
+			b.WriteString("\\t")
+		default:
+			if c < 0x20 {
+				fmt.Fprintf(b, "\\u%04x", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+func cjsonDecode(s *State, args []Value) ([]Value, error) {
+	if len(args) != 1 {
+		return nil, argError(0, "decode", "expected 1 argument")
+	}
+	data, err := checkString(args, 0, "decode")
+	if err != nil {
+		return nil, err
+	}
+	d := &jsonDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	d.skipSpace()
+	if d.pos < len(d.data) {
+		return nil, d.unexpected("the end")
+	}
+	return []Value{v}, nil
+}
+
+var jsonLiterals = []struct {
+	text  string
+	value Value
+}{{"true", true}, {"false", false}, {"null", Null}}
+
+// jsonDecoder decodes a JSON document to Lua values
+type jsonDecoder struct {
+	data string
+	pos  int
+}
+
+func (d *jsonDecoder) skipSpace() {
+	for d.pos < len(d.data) && strings.IndexByte(" \t\r\n", d.data[d.pos]) >= 0 {
+		d.pos++
+	}
+}
+
+// unexpected returns the error of a token that isn't the expected one
+func (d *jsonDecoder) unexpected(expected string) error {
+	found := "invalid token"
+	if d.pos >= len(d.data) {
+		found = "T_END"
+	}
+	return fmt.Errorf("Expected %s but found %s at character %d", expected, found, d.pos+1)
+}
+
+func (d *jsonDecoder) value(depth int) (Value, error) {
+	d.skipSpace()
+	if d.pos >= len(d.data) {
+		return nil, d.unexpected("value")
+	}
+	switch c := d.data[d.pos]; {
+	case c == '{' || c == '[':
+		depth++
+		if depth > maxJSONDepth {
+			return nil, fmt.Errorf("Found too many nested data structures (%d) at character %d", depth, d.pos+1)
+		}
+		d.pos++
+		if c == '{' {
+			return d.object(depth)
+		}
+		return d.array(depth)
+	case c == '"':
+		return d.str()
+	case c == '-' || c >= '0' && c <= '9':
+		start := d.pos
+		for d.pos < len(d.data) && strings.IndexByte("+-0123456789.eE", d.data[d.pos]) >= 0 {
+			d.pos++
+		}
+		n, err := strconv.ParseFloat(d.data[start:d.pos], 64)
+		if err != nil {
+			d.pos = start
+			return nil, d.unexpected("value")
+		}
+		return n, nil
+	}
+	for _, literal := range jsonLiterals {
+		if strings.HasPrefix(d.data[d.pos:], literal.text) {
+			d.pos += len(literal.text)
+			return literal.value, nil
+		}
+	}
+	return nil, d.unexpected("value")
+}
+
+func (d *jsonDecoder) object(depth int) (Value, error) {
+	t := NewTable()
+	d.skipSpace()
+	if d.pos < len(d.data) && d.data[d.pos] == '}' {
+		d.pos++
+		return t, nil
+	}
+	for {
+		d.skipSpace()
+		if d.pos >= len(d.data) || d.data[d.pos] != '"' {
+			return nil, d.unexpected("object key string")
+		}
+		key, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		d.skipSpace()
+		if d.pos >= len(d.data) || d.data[d.pos] != ':' {
+			return nil, d.unexpected("colon")
+		}
+		d.pos++
+		v, err := d.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		t.Set(key, v)
+		d.skipSpace()
+		if d.pos < len(d.data) && d.data[d.pos] == ',' {
+			d.pos++
+			continue
+		}
+		if d.pos < len(d.data) && d.data[d.pos] == '}' {
+			d.pos++
+			return t, nil
+		}
+		return nil, d.unexpected("comma or object end")
+	}
+}
+
+func (d *jsonDecoder) array(depth int) (Value, error) {
+	t := NewTable()
+	d.skipSpace()
+	if d.pos < len(d.data) && d.data[d.pos] == ']' {
+		d.pos++
+		return t, nil
+	}
+	for i := 1; ; i++ {
+		v, err := d.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		t.Set(float64(i), v)
+		d.skipSpace()
+		if d.pos < len(d.data) && d.data[d.pos] == ',' {
+			d.pos++
+			continue
+		}
+		if d.pos < len(d.data) && d.data[d.pos] == ']' {
+			d.pos++
+			return t, nil
+		}
+		return nil, d.unexpected("comma or array end")
+	}
+}
+
+// str decodes a string, the position being on its opening quote
+func (d *jsonDecoder) str() (Value, error) {
+	start := d.pos
+	d.pos++
+	var b strings.Builder
+	for {
+		if d.pos >= len(d.data) {
+			d.pos = start
+			return nil, d.unexpected("string end")
+		}
+		c := d.data[d.pos]
+		d.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if d.pos >= len(d.data) {
+				continue
+			}
+			e := d.data[d.pos]
+			d.pos++
+			switch e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				r, ok := d.hex4()
+				if !ok {
+					d.pos = start
+					return nil, d.unexpected("value")
+				}
+				if utf16.IsSurrogate(r) && strings.HasPrefix(d.data[d.pos:], "\\u") {
+					d.pos += 2
+					low, ok := d.hex4()
+					if !ok {
+						d.pos = start
+						return nil, d.unexpected("value")
+					}
+					r = utf16.DecodeRune(r, low)
+				}
+				var buf [utf8.UTFMax]byte
+				b.Write(buf[:utf8.EncodeRune(buf[:], r)])
+			default:
+				d.pos = start
+				return nil, d.unexpected("value")
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// hex4 reads the four hexadecimal digits of a \u escape
+func (d *jsonDecoder) hex4() (rune, bool) {
+	if d.pos+4 > len(d.data) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(d.data[d.pos:d.pos+4], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	d.pos += 4
+	return rune(n), true
+}
@@ -0,0 +1,765 @@
+package lua
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxCallDepth bounds the nesting of calls, so a recursive script fails with a stack
+// overflow instead of exhausting the stack of the server
+const maxCallDepth = 1000
+
+// interruptInterval is how many blocks a script runs between two calls of Interrupt
+const interruptInterval = 1000
+
+// State is an interpreter: the globals scripts see and the calls in progress
+type State struct {
+	Globals *Table
+	// StrictGlobals makes reading a missing global and creating a new one errors, as Redis
+	// does for scripts
+	StrictGlobals bool
+	// Interrupt, when set, is called regularly while a script runs, every loop iteration and
+	// function call counting. A script stops with an *InterruptError when it returns an error.
+	Interrupt func() error
+
+	stringLib *Table     // Looked up when indexing a string, for s:upper() and the like
+	calls     []callSite // Call sites of the functions in progress, innermost last
+	steps     int        // Blocks run since Interrupt was last called
+}
+
+// callSite is where a function was called from, empty for a call made from Go
+type callSite struct {
+	chunk string
+	line  int
+}
+
+// Compile parses a script, returning the function running it. Its arguments are the
+// values of ... in the script.
+func Compile(chunk string, src string) (*Function, error) {
+	proto, err := parse(chunk, src)
+	if err != nil {
+		return nil, err
+	}
+	return &Function{Name: chunk, proto: proto}, nil
+}
+
+// Call calls a function and returns its results. An error raised by the script is an
+// *Error.
+func (s *State) Call(fn *Function, args ...Value) ([]Value, error) {
+	return s.callFunction(fn, args, callSite{})
+}
+
+// CallValue calls a function, or a value with a __call metamethod, from Go
+func (s *State) CallValue(fn Value, args ...Value) ([]Value, error) {
+	if f, ok := fn.(*Function); ok {
+		return s.callFunction(f, args, callSite{})
+	}
+	return s.callValue(fn, args, callSite{}, "")
+}
+
+// Where returns the position of the call level levels up the stack, like "user_script:3:",
+// or "" when it is unknown. Level 1 is the caller of the running Go function.
+func (s *State) Where(level int) string {
+	if level < 1 || level > len(s.calls) {
+		return ""
+	}
+	site := s.calls[len(s.calls)-level]
+	if site.chunk == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:", site.chunk, site.line)
+}
+
+// frame holds the variables of a running Lua function. Locals live in cells a closure can
+// share with the frame that created it.
+type frame struct {
+	fn      *Function
+	cells   []*Value
+	varargs []Value
+}
+
+// control says how a block ended
+type control int
+
+const (
+	controlNormal control = iota
+	controlBreak
+	controlReturn
+)
+
+// runtimeError returns an error raised by an operation of a script at a line
+func runtimeError(f *frame, line int, format string, args ...any) error {
+	return &Error{Value: fmt.Sprintf("%s:%d: %s", f.fn.proto.chunk, line, fmt.Sprintf(format, args...))}
+}
+
+// callFunction runs a function called from a call site
+func (s *State) callFunction(fn *Function, args []Value, site callSite) ([]Value, error) {
+	if len(s.calls) >= maxCallDepth {
+		return nil, &Error{Value: prefixPosition(site, "stack overflow")}
+	}
+	s.calls = append(s.calls, site)
+	defer func() { s.calls = s.calls[:len(s.calls)-1] }()
+
+	if fn.native != nil {
+		rets, err := fn.native(s, args)
+		if err != nil {
+			switch err.(type) {
+			case *Error, *InterruptError:
+			default:
+				// Errors of Go functions are positioned at the call
+				err = &Error{Value: prefixPosition(site, err.Error())}
+			}
+			return nil, err
+		}
+		return rets, nil
+	}
+
+	p := fn.proto
+	f := &frame{fn: fn, cells: make([]*Value, p.slots)}
+	for i, slot := range p.params {
+		var v Value
+		if i < len(args) {
+			v = args[i]
+		}
+		f.cells[slot] = &v
+	}
+	if p.isVararg && len(args) > len(p.params) {
+		f.varargs = args[len(p.params):]
+	}
+	_, rets, err := s.execBlock(f, p.body)
+	return rets, err
+}
+
+// prefixPosition prefixes a message with the position of a call site
+func prefixPosition(site callSite, msg string) string {
+	if site.chunk == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s:%d: %s", site.chunk, site.line, msg)
+}
+
+// callValue calls a value, going through its __call metamethod when it isn't a function.
+// desc describes the called expression in error messages.
+func (s *State) callValue(fn Value, args []Value, site callSite, desc string) ([]Value, error) {
+	if f, ok := fn.(*Function); ok {
+		return s.callFunction(f, args, site)
+	}
+	if t, ok := fn.(*Table); ok && t.meta != nil {
+		if handler, ok := t.meta.Get("__call").(*Function); ok {
+			return s.callFunction(handler, append([]Value{fn}, args...), site)
+		}
+	}
+	return nil, &Error{Value: prefixPosition(site, "attempt to call "+describeValue(desc, fn))}
+}
+
+// describeValue describes a value in error messages, with the expression it comes from
+// when known: "global 'x' (a nil value)"
+func describeValue(desc string, v Value) string {
+	if desc == "" {
+		return fmt.Sprintf("a %s value", TypeName(v))
+	}
+	return fmt.Sprintf("%s (a %s value)", desc, TypeName(v))
+}
+
+// describe describes an expression in error messages, "" when it has no name
+func describe(e expr) string {
+	switch e := e.(type) {
+	case *globalExpr:
+		return fmt.Sprintf("global '%s'", e.name)
+	case *localExpr:
+		return fmt.Sprintf("local '%s'", e.name)
+	case *upvalueExpr:
+		return fmt.Sprintf("upvalue '%s'", e.name)
+	case *indexExpr:
+		if key, ok := e.key.(*stringExpr); ok {
+			return fmt.Sprintf("field '%s'", key.value)
+		}
+	case *methodCallExpr:
+		return fmt.Sprintf("method '%s'", e.name)
+	}
+	return ""
+}
+
+// closure creates a closure of a function defined in the running one
+func closure(f *frame, proto *funcProto) *Function {
+	fn := &Function{Name: proto.name, proto: proto, upvalues: make([]*Value, len(proto.upvalues))}
+	for i, desc := range proto.upvalues {
+		if desc.fromLocal {
+			fn.upvalues[i] = f.cells[desc.index]
+		} else {
+			fn.upvalues[i] = f.fn.upvalues[desc.index]
+		}
+	}
+	return fn
+}
+
+// adjust pads or truncates values to n values
+func adjust(values []Value, n int) []Value {
+	if len(values) >= n {
+		return values[:n]
+	}
+	return append(values, make([]Value, n-len(values))...)
+}
+
+// execBlock runs statements
+func (s *State) execBlock(f *frame, body block) (control, []Value, error) {
+	if s.Interrupt != nil {
+		if s.steps++; s.steps >= interruptInterval {
+			s.steps = 0
+			if err := s.Interrupt(); err != nil {
+				return 0, nil, &InterruptError{Err: err}
+			}
+		}
+	}
+	for _, st := range body {
+		ctl, rets, err := s.exec(f, st)
+		if err != nil || ctl != controlNormal {
+			return ctl, rets, err
+		}
+	}
+	return controlNormal, nil, nil
+}
+
+// exec runs a statement
+func (s *State) exec(f *frame, st stmt) (control, []Value, error) {
+	switch st := st.(type) {
+	case *localStmt:
+		values, err := s.evalList(f, st.exprs)
+		if err != nil {
+			return 0, nil, err
+		}
+		values = adjust(values, len(st.slots))
+		for i, slot := range st.slots {
+			v := values[i]
+			f.cells[slot] = &v
+		}
+	case *assignStmt:
+		values, err := s.evalList(f, st.exprs)
+		if err != nil {
+			return 0, nil, err
+		}
+		values = adjust(values, len(st.targets))
+		for i, target := range st.targets {
+			if err := s.assign(f, target, values[i], st.line); err != nil {
+				return 0, nil, err
+			}
+		}
+	case *callStmt:
+		if _, err := s.evalMulti(f, st.call); err != nil {
+			return 0, nil, err
+		}
+	case *doStmt:
+		return s.execBlock(f, st.body)
+	case *whileStmt:
+		for {
+			cond, err := s.eval(f, st.cond)
+			if err != nil {
+				return 0, nil, err
+			}
+			if !Truthy(cond) {
+				break
+			}
+			ctl, rets, err := s.execBlock(f, st.body)
+			if err != nil || ctl == controlReturn {
+				return ctl, rets, err
+			}
+			if ctl == controlBreak {
+				break
+			}
+		}
+	case *repeatStmt:
+		for {
+			ctl, rets, err := s.execBlock(f, st.body)
+			if err != nil || ctl == controlReturn {
+				return ctl, rets, err
+			}
+			if ctl == controlBreak {
+				break
+			}
+			cond, err := s.eval(f, st.cond)
+			if err != nil {
+				return 0, nil, err
+			}
+			if Truthy(cond) {
+				break
+			}
+		}
+	case *ifStmt:
+		for i, cond := range st.conds {
+			v, err := s.eval(f, cond)
+			if err != nil {
+				return 0, nil, err
+			}
+			if Truthy(v) {
+				return s.execBlock(f, st.blocks[i])
+			}
+		}
+		if st.orElse != nil {
+			return s.execBlock(f, st.orElse)
+		}
+	case *numericForStmt:
+		return s.execNumericFor(f, st)
+	case *genericForStmt:
+		return s.execGenericFor(f, st)
+	case *localFunctionStmt:
+		cell := new(Value)
+		f.cells[st.slot] = cell
+		*cell = closure(f, st.proto)
+	case *returnStmt:
+		values, err := s.evalList(f, st.exprs)
+		if err != nil {
+			return 0, nil, err
+		}
+		return controlReturn, values, nil
+	case *breakStmt:
+		return controlBreak, nil, nil
+	default:
+		panic(fmt.Sprintf("lua: unknown statement %T", st))
+	}
+	return controlNormal, nil, nil
+}
+
+// execNumericFor runs for i = start, limit, step do ... end
+func (s *State) execNumericFor(f *frame, st *numericForStmt) (control, []Value, error) {
+	bounds := []expr{st.start, st.limit, st.step}
+	names := []string{"initial value", "limit", "step"}
+	numbers := []float64{0, 0, 1}
+	for i, e := range bounds {
+		if e == nil {
+			continue
+		}
+		v, err := s.eval(f, e)
+		if err != nil {
+			return 0, nil, err
+		}
+		n, ok := ToNumber(v)
+		if !ok {
+			return 0, nil, runtimeError(f, st.line, "'for' %s must be a number", names[i])
+		}
+		numbers[i] = n
+	}
+	start, limit, step := numbers[0], numbers[1], numbers[2]
+	for i := start; step > 0 && i <= limit || step <= 0 && i >= limit; i += step {
+		v := Value(i)
+		f.cells[st.slot] = &v
+		ctl, rets, err := s.execBlock(f, st.body)
+		if err != nil || ctl == controlReturn {
+			return ctl, rets, err
+		}
+		if ctl == controlBreak {
+			break
+		}
+	}
+	return controlNormal, nil, nil
+}
+
+// execGenericFor runs for k, v in explist do ... end
+func (s *State) execGenericFor(f *frame, st *genericForStmt) (control, []Value, error) {
+	values, err := s.evalList(f, st.exprs)
+	if err != nil {
+		return 0, nil, err
+	}
+	values = adjust(values, 3)
+	iterator, state, key := values[0], values[1], values[2]
+	site := callSite{chunk: f.fn.proto.chunk, line: st.line}
+	for {
+		rets, err := s.callValue(iterator, []Value{state, key}, site, "")
+		if err != nil {
+			return 0, nil, err
+		}
+		rets = adjust(rets, len(st.slots))
+		if rets[0] == nil {
+			return controlNormal, nil, nil
+		}
+		key = rets[0]
+		for i, slot := range st.slots {
+			v := rets[i]
+			f.cells[slot] = &v
+		}
+		ctl, rets, err := s.execBlock(f, st.body)
+		if err != nil || ctl == controlReturn {
+			return ctl, rets, err
+		}
+		if ctl == controlBreak {
+			return controlNormal, nil, nil
+		}
+	}
+}
+
+// assign stores a value in a variable or a field
+func (s *State) assign(f *frame, target expr, v Value, line int) error {
+	switch target := target.(type) {
+	case *localExpr:
+		*f.cells[target.slot] = v
+	case *upvalueExpr:
+		*f.fn.upvalues[target.index] = v
+	case *globalExpr:
+		if s.StrictGlobals && s.Globals.Get(target.name) == nil {
+			return runtimeError(f, line, "Script attempted to create global variable '%s'", target.name)
+		}
+		s.Globals.Set(target.name, v)
+	case *indexExpr:
+		object, err := s.eval(f, target.object)
+		if err != nil {
+			return err
+		}
+		key, err := s.eval(f, target.key)
+		if err != nil {
+			return err
+		}
+		return s.setIndex(f, object, key, v, target.line, describe(target.object))
+	}
+	return nil
+}
+
+// setIndex stores a value in a field of a table, going through __newindex
+func (s *State) setIndex(f *frame, object Value, key Value, v Value, line int, desc string) error {
+	for range maxCallDepth {
+		t, ok := object.(*Table)
+		if !ok {
+			return runtimeError(f, line, "attempt to index %s", describeValue(desc, object))
+		}
+		if t.meta == nil || t.Get(key) != nil {
+			return rawSet(f, t, key, v, line)
+		}
+		handler := t.meta.Get("__newindex")
+		switch handler := handler.(type) {
+		case nil:
+			return rawSet(f, t, key, v, line)
+		case *Function:
+			_, err := s.callFunction(handler, []Value{t, key, v}, callSite{chunk: f.fn.proto.chunk, line: line})
+			return err
+		}
+		object, desc = handler, ""
+	}
+	return runtimeError(f, line, "loop in settable")
+}
+
+// rawSet stores a value in a table without metamethods
+func rawSet(f *frame, t *Table, key Value, v Value, line int) error {
+	if t.readOnly {
+		return runtimeError(f, line, "Attempt to modify a readonly table")
+	}
+	switch k := key.(type) {
+	case nil:
+		return runtimeError(f, line, "table index is nil")
+	case float64:
+		if math.IsNaN(k) {
+			return runtimeError(f, line, "table index is NaN")
+		}
+	}
+	t.Set(key, v)
+	return nil
+}
+
+// index returns a field of a table or a string, going through __index
+func (s *State) index(f *frame, object Value, key Value, line int, desc string) (Value, error) {
+	for range maxCallDepth {
+		switch o := object.(type) {
+		case *Table:
+			v := o.Get(key)
+			if v != nil || o.meta == nil {
+				return v, nil
+			}
+			handler := o.meta.Get("__index")
+			switch handler := handler.(type) {
+			case nil:
+				return nil, nil
+			case *Function:
+				rets, err := s.callFunction(handler, []Value{o, key}, callSite{chunk: f.fn.proto.chunk, line: line})
+				if err != nil || len(rets) == 0 {
+					return nil, err
+				}
+				return rets[0], nil
+			}
+			object, desc = handler, ""
+		case string:
+			if s.stringLib == nil {
+				return nil, nil
+			}
+			return s.stringLib.Get(key), nil
+		default:
+			return nil, runtimeError(f, line, "attempt to index %s", describeValue(desc, object))
+		}
+	}
+	return nil, runtimeError(f, line, "loop in gettable")
+}
+
+// evalList evaluates expressions, all the values of the last one included
+func (s *State) evalList(f *frame, exprs []expr) ([]Value, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	values := make([]Value, 0, len(exprs))
+	for _, e := range exprs[:len(exprs)-1] {
+		v, err := s.eval(f, e)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	last, err := s.evalMulti(f, exprs[len(exprs)-1])
+	if err != nil {
+		return nil, err
+	}
+	return append(values, last...), nil
+}
+
+// evalMulti evaluates an expression to all its values: the results of a call, the values of
+// ..., or the single value of any other expression
+func (s *State) evalMulti(f *frame, e expr) ([]Value, error) {
+	switch e := e.(type) {
+	case *callExpr:
+		fn, err := s.eval(f, e.fn)
+		if err != nil {
+			return nil, err
+		}
+		args, err := s.evalList(f, e.args)
+		if err != nil {
+			return nil, err
+		}
+		return s.callValue(fn, args, callSite{chunk: f.fn.proto.chunk, line: e.line}, describe(e.fn))
+	case *methodCallExpr:
+		object, err := s.eval(f, e.object)
+		if err != nil {
+			return nil, err
+		}
+		fn, err := s.index(f, object, e.name, e.line, describe(e.object))
+		if err != nil {
+			return nil, err
+		}
+		args, err := s.evalList(f, e.args)
+		if err != nil {
+			return nil, err
+		}
+		return s.callValue(fn, append([]Value{object}, args...), callSite{chunk: f.fn.proto.chunk, line: e.line}, describe(e))
+	case *varargExpr:
+		return append([]Value(nil), f.varargs...), nil
+	}
+	v, err := s.eval(f, e)
+	if err != nil {
+		return nil, err
+	}
+	return []Value{v}, nil
+}
+
+// eval evaluates an expression to a single value
+func (s *State) eval(f *frame, e expr) (Value, error) {
+	switch e := e.(type) {
+	case *nilExpr:
+		return nil, nil
+	case *trueExpr:
+		return true, nil
+	case *falseExpr:
+		return false, nil
+	case *numberExpr:
+		return e.value, nil
+	case *stringExpr:
+		return e.value, nil
+	case *varargExpr:
+		if len(f.varargs) == 0 {
+			return nil, nil
+		}
+		return f.varargs[0], nil
+	case *localExpr:
+		return *f.cells[e.slot], nil
+	case *upvalueExpr:
+		return *f.fn.upvalues[e.index], nil
+	case *globalExpr:
+		v := s.Globals.Get(e.name)
+		if v == nil && s.StrictGlobals {
+			return nil, runtimeError(f, e.line, "Script attempted to access nonexistent global variable '%s'", e.name)
+		}
+		return v, nil
+	case *indexExpr:
+		object, err := s.eval(f, e.object)
+		if err != nil {
+			return nil, err
+		}
+		key, err := s.eval(f, e.key)
+		if err != nil {
+			return nil, err
+		}
+		return s.index(f, object, key, e.line, describe(e.object))
+	case *callExpr, *methodCallExpr:
+		values, err := s.evalMulti(f, e)
+		if err != nil || len(values) == 0 {
+			return nil, err
+		}
+		return values[0], nil
+	case *functionExpr:
+		return closure(f, e.proto), nil
+	case *parenExpr:
+		return s.eval(f, e.inner)
+	case *andExpr:
+		left, err := s.eval(f, e.left)
+		if err != nil || !Truthy(left) {
+			return left, err
+		}
+		return s.eval(f, e.right)
+	case *orExpr:
+		left, err := s.eval(f, e.left)
+		if err != nil || Truthy(left) {
+			return left, err
+		}
+		return s.eval(f, e.right)
+	case *unaryExpr:
+		return s.evalUnary(f, e)
+	case *binaryExpr:
+		return s.evalBinary(f, e)
+	case *tableExpr:
+		return s.evalTable(f, e)
+	}
+	panic(fmt.Sprintf("lua: unknown expression %T", e))
+}
+
+// evalUnary evaluates not, - and #
+func (s *State) evalUnary(f *frame, e *unaryExpr) (Value, error) {
+	v, err := s.eval(f, e.operand)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "not":
+		return !Truthy(v), nil
+	case "-":
+		n, ok := ToNumber(v)
+		if !ok {
+			return nil, runtimeError(f, e.line, "attempt to perform arithmetic on %s", describeValue(describe(e.operand), v))
+		}
+		return -n, nil
+	}
+	switch v := v.(type) {
+	case string:
+		return float64(len(v)), nil
+	case *Table:
+		return float64(v.Len()), nil
+	}
+	return nil, runtimeError(f, e.line, "attempt to get length of %s", describeValue(describe(e.operand), v))
+}
+
+// evalBinary evaluates arithmetic, concatenation and comparisons
+func (s *State) evalBinary(f *frame, e *binaryExpr) (Value, error) {
+	left, err := s.eval(f, e.left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.eval(f, e.right)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "==":
+		return rawEqual(left, right), nil
+	case "~=":
+		return !rawEqual(left, right), nil
+	case "<":
+		return lessThan(f, e.line, left, right, false)
+	case "<=":
+		return lessThan(f, e.line, left, right, true)
+	case ">":
+		return lessThan(f, e.line, right, left, false)
+	case ">=":
+		return lessThan(f, e.line, right, left, true)
+	case "..":
+		a, okLeft := ToStringValue(left)
+		b, okRight := ToStringValue(right)
+		if !okLeft || !okRight {
+			culprit, desc := left, describe(e.left)
+			if okLeft {
+				culprit, desc = right, describe(e.right)
+			}
+			return nil, runtimeError(f, e.line, "attempt to concatenate %s", describeValue(desc, culprit))
+		}
+		return a + b, nil
+	}
+
+	a, okLeft := ToNumber(left)
+	b, okRight := ToNumber(right)
+	if !okLeft || !okRight {
+		culprit, desc := left, describe(e.left)
+		if okLeft {
+			culprit, desc = right, describe(e.right)
+		}
+		return nil, runtimeError(f, e.line, "attempt to perform arithmetic on %s", describeValue(desc, culprit))
+	}
+	switch e.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		return a / b, nil
+	case "%":
+		return a - math.Floor(a/b)*b, nil
+	case "^":
+		return math.Pow(a, b), nil
+	}
+	panic("lua: unknown operator " + e.op)
+}
+
+// lessThan compares two numbers or two strings
+func lessThan(f *frame, line int, a, b Value, orEqual bool) (Value, error) {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			if orEqual {
+				return a <= b, nil
+			}
+			return a < b, nil
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			if orEqual {
+				return a <= b, nil
+			}
+			return a < b, nil
+		}
+	}
+	if TypeName(a) == TypeName(b) {
+		return nil, runtimeError(f, line, "attempt to compare two %s values", TypeName(a))
+	}
+	return nil, runtimeError(f, line, "attempt to compare %s with %s", TypeName(a), TypeName(b))
+}
+
+// evalTable evaluates a table constructor
+func (s *State) evalTable(f *frame, e *tableExpr) (Value, error) {
+	t := NewTable()
+	n := 0
+	for i, field := range e.fields {
+		if field.key != nil {
+			key, err := s.eval(f, field.key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := s.eval(f, field.value)
+			if err != nil {
+				return nil, err
+			}
+			if err := rawSet(f, t, key, v, e.line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if i == len(e.fields)-1 {
+			values, err := s.evalMulti(f, field.value)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range values {
+				n++
+				t.Set(float64(n), v)
+			}
+			continue
+		}
+		v, err := s.eval(f, field.value)
+		if err != nil {
+			return nil, err
+		}
+		n++
+		t.Set(float64(n), v)
+	}
+	return t, nil
+}
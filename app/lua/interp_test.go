@@ -0,0 +1,311 @@
+package lua
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// run compiles and runs a chunk in a new State, returning its results
+func run(t *testing.T, src string) ([]Value, error) {
+	t.Helper()
+	fn, err := Compile("test", src)
+	if err != nil {
+		t.Fatalf("Expected %q to compile, got %v", src, err)
+	}
+	return NewState().Call(fn)
+}
+
+// runOne runs a chunk and returns its first result, failing the test on an error
+func runOne(t *testing.T, src string) Value {
+	t.Helper()
+	rets, err := run(t, src)
+	if err != nil {
+		t.Fatalf("Expected %q to run, got %v", src, err)
+	}
+	if len(rets) == 0 {
+		return nil
+	}
+	return rets[0]
+}
+
+func TestInterpreter(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Value
+	}{
+		{"arithmetic", "return 1 + 2 * 3 - 4 / 2", float64(5)},
+		{"power and unary minus", "return -2 ^ 2", float64(-4)},
+		{"modulo of negatives", "return -7 % 3", float64(2)},
+		{"string coercion", "return '10' + 5", float64(15)},
+		{"concatenation", "return 'a' .. 1 .. 'b'", "a1b"},
+		{"length", "return #'hello' + #{1, 2, 3}", float64(8)},
+		{"comparison", "return 1 < 2 and 'a' < 'b' and not (2 <= 1)", true},
+		{"and or", "return nil or false and 1 or 'x'", "x"},
+		{"equality across types", "return 1 == '1'", false},
+		{"locals and shadowing", "local a = 1 do local a = 2 end return a", float64(1)},
+		{"multiple assignment", "local a, b = 1, 2 a, b = b, a return a * 10 + b", float64(21)},
+		{"while", "local i, n = 0, 0 while i < 5 do i = i + 1 n = n + i end return n", float64(15)},
+		{"repeat", "local i = 0 repeat i = i + 1 until i >= 3 return i", float64(3)},
+		{"repeat sees body locals", "local n = 0 repeat local done = n > 1 n = n + 1 until done return n", float64(3)},
+		{"numeric for", "local n = 0 for i = 10, 1, -3 do n = n + i end return n", float64(22)},
+		{"generic for", "local n = 0 for _, v in ipairs({4, 5, 6}) do n = n + v end return n", float64(15)},
+		{"break", "local i = 0 while true do i = i + 1 if i == 4 then break end end return i", float64(4)},
+		{"if elseif else", "local x = 5 if x < 3 then return 'a' elseif x < 6 then return 'b' else return 'c' end", "b"},
+		{"closures share locals", `
+			local function counter()
+				local n = 0
+				return function() n = n + 1 return n end
+			end
+			local c1, c2 = counter(), counter()
+			c1() c1()
+			return c1() * 10 + c2()`, float64(31)},
+		{"closures capture each iteration", `
+			local fs = {}
+			for i = 1, 3 do fs[i] = function() return i end end
+			return fs[1]() + fs[2]() * 10 + fs[3]() * 100`, float64(321)},
+		{"recursion", "local function fib(n) if n < 2 then return n end return fib(n - 1) + fib(n - 2) end return fib(15)", float64(610)},
+		{"varargs", "local function f(...) return select('#', ...), (select(2, ...)) end local n, v = f(1, 'x', nil) return n .. v", "3x"},
+		{"multiple results expand last", "local function f() return 1, 2 end local t = {f(), f()} return #t", float64(3)},
+		{"parentheses truncate", "local function f() return 1, 2 end local t = {(f())} return #t", float64(1)},
+		{"table constructor", "local t = {1, 2, x = 'y', [10] = 'z'} return t.x .. t[10] .. t[2]", "yz2"},
+		{"method call", "local o = {n = 2} function o:double() return self.n * 2 end return o:double()", float64(4)},
+		{"string methods", "local s = 'abc' return s:upper() .. s:len()", "ABC3"},
+		{"__index", "local t = setmetatable({}, {__index = function(_, k) return k .. '!' end}) return t.hi", "hi!"},
+		{"__newindex", "local log = {} local t = setmetatable({}, {__newindex = function(_, k, v) rawset(log, k, v) end}) t.a = 1 return rawget(t, 'a') == nil and log.a == 1", true},
+		{"__call", "local t = setmetatable({}, {__call = function(_, x) return x * 2 end}) return t(21)", float64(42)},
+		{"__tostring", "return tostring(setmetatable({}, {__tostring = function() return 'obj' end}))", "obj"},
+		{"number formatting", "return tostring(1e15) .. ' ' .. tostring(0.1) .. ' ' .. tostring(3)", "1e+15 0.1 3"},
+		{"tonumber", "return tonumber('0x10') + tonumber('  8  ') + tonumber('z', 36)", float64(59)},
+		{"tonumber of garbage", "return tonumber('12abc')", nil},
+		{"type", "return type(nil) .. type(1) .. type('') .. type({}) .. type(print or type)", "nilnumberstringtablefunction"},
+		{"pairs visits every key", "local n = 0 for k, v in pairs({a = 1, b = 2, 3}) do n = n + v end return n", float64(6)},
+		{"unpack", "return select('#', unpack({1, 2, 3}))", float64(3)},
+		{"long strings and comments", "--[[ comment ]] return [[a\nb]] -- trailing", "a\nb"},
+		{"escapes", `return "\65\t\"\n"`, "A\t\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := runOne(t, tt.src); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"call nil global", "local x = nil\nx()", "test:2: attempt to call local 'x' (a nil value)"},
+		{"index nil", "local t = {}\nreturn t.a.b", "test:2: attempt to index field 'a' (a nil value)"},
+		{"arithmetic on table", "return {} + 1", "test:1: attempt to perform arithmetic on a table value"},
+		{"concatenate nil", "return 'a' .. nil", "test:1: attempt to concatenate a nil value"},
+		{"compare mixed types", "return 1 < 'x'", "test:1: attempt to compare number with string"},
+		{"error with position", "\nerror('boom')", "test:2: boom"},
+		{"error without position", "error('boom', 0)", "boom"},
+		{"stack overflow", "local function f() return 1 + f() end return f()", "stack overflow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := run(t, tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+			var luaErr *Error
+			if !errors.As(err, &luaErr) {
+				t.Errorf("Expected an *Error, got %T", err)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"return (", "test:1: unexpected symbol near '<eof>'"},
+		{"if true then", "test:1: 'end' expected near '<eof>'"},
+		{"x = = 1", "test:1: unexpected symbol near '='"},
+		{"local 1 = 2", "test:1: '<name>' expected near '1'"},
+		{"return 'abc", "test:1: unfinished string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile("test", tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestStrictGlobals(t *testing.T) {
+	fn, err := Compile("test", "x = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewState()
+	s.StrictGlobals = true
+	if _, err := s.Call(fn); err == nil {
+		t.Error("Expected creating a global to fail")
+	}
+
+	fn, _ = Compile("test", "return undefined_global")
+	if _, err := s.Call(fn); err == nil {
+		t.Error("Expected reading a missing global to fail")
+	}
+
+	s.StrictGlobals = false
+	if _, err := s.Call(fn); err != nil {
+		t.Errorf("Expected a missing global to read nil, got %v", err)
+	}
+}
+
+func TestCallFromGo(t *testing.T) {
+	fn, err := Compile("test", "local a, b = ... return b, a, add(a, b)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewState()
+	s.Globals.Set("add", NewFunction("add", func(s *State, args []Value) ([]Value, error) {
+		a, _ := ToNumber(args[0])
+		b, _ := ToNumber(args[1])
+		return []Value{a + b}, nil
+	}))
+	rets, err := s.Call(fn, float64(1), float64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Value{float64(2), float64(1), float64(3)}; !reflect.DeepEqual(rets, expected) {
+		t.Errorf("Expected %v, got %v", expected, rets)
+	}
+
+	// Errors of Go functions are positioned at their call
+	s.Globals.Set("fail", NewFunction("fail", func(s *State, args []Value) ([]Value, error) {
+		return nil, errors.New("failed")
+	}))
+	fn, _ = Compile("test", "\nfail()")
+	if _, err := s.Call(fn); err == nil || err.Error() != "test:2: failed" {
+		t.Errorf("Expected test:2: failed, got %v", err)
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	errStop := errors.New("stopped")
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"while", "while true do end"},
+		{"repeat", "repeat until false"},
+		{"numeric for", "for i = 1, math.huge do end"},
+		{"generic for", "local function forever() return 1 end for _ in forever do end"},
+		{"recursion", "local function f(n) if n == 0 then return 0 end return f(n - 1) end while true do f(10) end"},
+		{"pcall", "while true do pcall(function() while true do end end) end"},
+		{"xpcall", "while true do xpcall(function() while true do end end, function(e) return e end) end"},
+		{"error handler", "xpcall(error, function() while true do end end)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := Compile("test", tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewState()
+			calls := 0
+			s.Interrupt = func() error {
+				if calls++; calls == 10 {
+					return errStop
+				}
+				return nil
+			}
+			_, err = s.Call(fn)
+			var interrupt *InterruptError
+			if !errors.As(err, &interrupt) || !errors.Is(err, errStop) {
+				t.Errorf("Expected the script to be interrupted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLiteralsAndConversions(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Value
+	}{
+		{"hexadecimal", "return 0xff + 0X10", float64(271)},
+		{"exponent and leading dot", "return 1e2 + .5 + 2E-1", float64(100.7)},
+		{"long string levels", "return [==[a]]b]==]", "a]]b"},
+		{"long string skips first newline", "return [[\nx]]", "x"},
+		{"nested long comment", "--[==[ ]] ]==] return 1", float64(1)},
+		{"decimal escapes", `return "\0491\97"`, "11a"},
+		{"escaped newline", "return 'a\\\nb'", "a\nb"},
+		{"division by zero", "return 1 / 0 == math.huge and -1 / 0 == -math.huge", true},
+		{"nan differs from itself", "local n = 0 / 0 return n ~= n", true},
+		{"string order", "return 'a' < 'ab' and 'B' < 'a' and not ('b' < 'a')", true},
+		{"numbers in concatenation", "return 1.5 .. '|' .. 1e100 .. '|' .. -0.25", "1.5|1e+100|-0.25"},
+		{"arithmetic on numeric strings", "return '0x10' * '2' - ' 1 '", float64(31)},
+		{"tostring of integers above 1e15", "return tostring(2 ^ 53)", "9.007199254741e+15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := runOne(t, tt.src); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestScopesAndCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Value
+	}{
+		{"for variable is a copy", "local n = 0 for i = 1, 3 do i = i * 10 n = n + i end return n", float64(60)},
+		{"fractional step", "local n = 0 for i = 1, 2, 0.5 do n = n + 1 end return n", float64(3)},
+		{"empty numeric for", "local n = 0 for i = 3, 1 do n = n + 1 end return n", float64(0)},
+		{"varargs in table", "local function f(...) local t = {...} return #t end return f(1, 2, 3)", float64(3)},
+		{"varargs forwarded", "local function g(...) return ... end local function f(...) return g(...) end return select('#', f(nil, nil))", float64(2)},
+		{"missing arguments are nil", "local function f(a, b) return b end return f(1)", nil},
+		{"extra results dropped", "local function f() return 1, 2, 3 end local a, b = f() return a + b", float64(3)},
+		{"global functions", "function add(a, b) return a + b end return add(2, 3)", float64(5)},
+		{"nested field functions", "local m = {sub = {}} function m.sub.f() return 'f' end return m.sub.f()", "f"},
+		{"upvalue shared by sibling closures", `
+			local function pair()
+				local v = 0
+				return function(x) v = x end, function() return v end
+			end
+			local set, get = pair()
+			set(7)
+			return get()`, float64(7)},
+		{"block locals end with their block", "local x = 1 if true then local x = 2 x = x + 1 end return x", float64(1)},
+		{"__index table chain", `
+			local base = {greet = function() return 'hi' end}
+			local middle = setmetatable({}, {__index = base})
+			local obj = setmetatable({}, {__index = middle})
+			return obj.greet()`, "hi"},
+		{"rawget skips __index", "local t = setmetatable({}, {__index = function() return 1 end}) return rawget(t, 'x')", nil},
+		{"pcall with __call", "local t = setmetatable({}, {__call = function(_, x) return x end}) return select(2, pcall(t, 'ok'))", "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := runOne(t, tt.src); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenKind is the kind of a token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenNumber
+	tokenString
+	tokenKeyword
+	tokenSymbol
+)
+
+// token is a lexical unit of a script
+type token struct {
+	kind tokenKind
+	text string  // Name, keyword or symbol, or the value of a string
+	num  float64 // Value of a number
+	line int
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "if": true, "in": true, "local": true,
+	"nil": true, "not": true, "or": true, "repeat": true, "return": true, "then": true,
+	"true": true, "until": true, "while": true,
+}
+
+// lexer splits a script in tokens
+type lexer struct {
+	chunk string // Name of the script in error messages
+	src   string
+	pos   int
+	line  int
+}
+
+// syntaxError returns the error of a script that doesn't parse
+func (l *lexer) syntaxError(line int, near string, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if near != "" {
+		msg += " near '" + near + "'"
+	}
+	return &Error{Value: fmt.Sprintf("%s:%d: %s", l.chunk, line, msg)}
+}
+
+// next returns the next token
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpaceAndComments(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, text: "<eof>", line: l.line}, nil
+	}
+
+	c := l.src[l.pos]
+	line := l.line
+	switch {
+	case isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		word := l.src[start:l.pos]
+		if keywords[word] {
+			return token{kind: tokenKeyword, text: word, line: line}, nil
+		}
+		return token{kind: tokenName, text: word, line: line}, nil
+	case isDigit(c) || c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1]):
+		return l.number()
+	case c == '"' || c == '\'':
+		return l.quotedString(c)
+	case c == '[' && l.longBracketLevel() >= 0:
+		s, err := l.longString()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokenString, text: s, line: line}, nil
+	}
+
+	for _, symbol := range []string{"...", "..", "==", "~=", "<=", ">="} {
+		if strings.HasPrefix(l.src[l.pos:], symbol) {
+			l.pos += len(symbol)
+			return token{kind: tokenSymbol, text: symbol, line: line}, nil
+		}
+	}
+	if strings.IndexByte("+-*/%^#<>=(){}[];:,.", c) >= 0 {
+		l.pos++
+		return token{kind: tokenSymbol, text: string(c), line: line}, nil
+	}
+	return token{}, l.syntaxError(line, string(c), "unexpected symbol")
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// skipSpaceAndComments skips spaces, line comments and long comments
+func (l *lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "--"):
+			l.pos += 2
+			if l.pos < len(l.src) && l.src[l.pos] == '[' && l.longBracketLevel() >= 0 {
+				if _, err := l.longString(); err != nil {
+					return err
+				}
+				continue
+			}
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// longBracketLevel returns the number of = of the long bracket at the position, like 2 for
+// [==[, or -1 when there is none
+func (l *lexer) longBracketLevel() int {
+	i := l.pos + 1
+	for i < len(l.src) && l.src[i] == '=' {
+		i++
+	}
+	if i < len(l.src) && l.src[i] == '[' {
+		return i - l.pos - 1
+	}
+	return -1
+}
+
+// longString reads a string or a comment between long brackets
+func (l *lexer) longString() (string, error) {
+	line := l.line
+	level := l.longBracketLevel()
+	l.pos += level + 2
+	// A newline right after the opening bracket is skipped
+	if strings.HasPrefix(l.src[l.pos:], "\r\n") {
+		l.pos += 2
+		l.line++
+	} else if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+		l.pos++
+		l.line++
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := strings.Index(l.src[l.pos:], closing)
+	if end < 0 {
+		return "", l.syntaxError(line, "<eof>", "unfinished long string")
+	}
+	s := l.src[l.pos : l.pos+end]
+	l.line += strings.Count(s, "\n")
+	l.pos += end + len(closing)
+	return s, nil
+}
+
+// number reads a decimal or hexadecimal number
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+		l.pos += 2
+	}
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if (c == '+' || c == '-') && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E') && !strings.HasPrefix(strings.ToLower(l.src[start:]), "0x") {
+			l.pos++
+			continue
+		}
+		if !isLetter(c) && !isDigit(c) && c != '.' {
+			break
+		}
+		l.pos++
+	}
+	text := l.src[start:l.pos]
+	n, ok := ParseNumber(text)
+	if !ok {
+		return token{}, l.syntaxError(l.line, text, "malformed number")
+	}
+	return token{kind: tokenNumber, num: n, text: text, line: l.line}, nil
+}
+
+// quotedString reads a string between quotes, decoding its escape sequences
+func (l *lexer) quotedString(quote byte) (token, error) {
+	line := l.line
+	l.pos++
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return token{}, l.syntaxError(line, "<eof>", "unfinished string")
+		}
+		c := l.src[l.pos]
+		switch c {
+		case quote:
+			l.pos++
+			return token{kind: tokenString, text: b.String(), line: line}, nil
+		case '\n':
+			return token{}, l.syntaxError(line, b.String(), "unfinished string")
+		case '\\':
+			l.pos++
+			if l.pos >= len(l.src) {
+				return token{}, l.syntaxError(line, "<eof>", "unfinished string")
+			}
+			e := l.src[l.pos]
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'v':
+				b.WriteByte('\v')
+			case '\n':
+				b.WriteByte('\n')
+				l.line++
+			case '\\', '"', '\'':
+				b.WriteByte(e)
+			default:
+				if !isDigit(e) {
+					return token{}, l.syntaxError(line, "\\"+string(e), "invalid escape sequence")
+				}
+				// Up to three decimal digits give a byte
+				n := 0
+				for i := 0; i < 3 && l.pos < len(l.src) && isDigit(l.src[l.pos]); i++ {
+					n = n*10 + int(l.src[l.pos]-'0')
+					l.pos++
+				}
+				if n > 255 {
+					return token{}, l.syntaxError(line, "", "escape sequence too large")
+				}
+				b.WriteByte(byte(n))
+				continue
+			}
+			l.pos++
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+}
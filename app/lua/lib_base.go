@@ -0,0 +1,460 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NewState returns an interpreter with the base, string, table, math and cjson libraries
+// loaded. The libraries are read-only.
+func NewState() *State {
+	s := &State{Globals: NewTable()}
+	openBase(s)
+	s.stringLib = openLibrary(s, "string", stringFunctions)
+	openLibrary(s, "table", tableFunctions)
+	openMath(s)
+	openCJSON(s)
+	return s
+}
+
+// openLibrary registers a table of Go functions as a global
+func openLibrary(s *State, name string, functions map[string]func(*State, []Value) ([]Value, error)) *Table {
+	lib := NewTable()
+	for _, fname := range sortedNames(functions) {
+		lib.Set(fname, NewFunction(fname, functions[fname]))
+	}
+	lib.SetReadOnly()
+	s.Globals.Set(name, lib)
+	return lib
+}
+
+// sortedNames returns the names of functions in order, so libraries are walked in the same
+// order every time
+func sortedNames(functions map[string]func(*State, []Value) ([]Value, error)) []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var baseFunctions = map[string]func(*State, []Value) ([]Value, error){
+	"assert":       baseAssert,
+	"error":        baseError,
+	"getmetatable": baseGetmetatable,
+	"ipairs":       baseIpairs,
+	"next":         baseNext,
+	"pairs":        basePairs,
+	"pcall":        basePcall,
+	"rawequal":     baseRawequal,
+	"rawget":       baseRawget,
+	"rawset":       baseRawset,
+	"select":       baseSelect,
+	"setmetatable": baseSetmetatable,
+	"tonumber":     baseTonumber,
+	"tostring":     baseTostring,
+	"type":         baseType,
+	"unpack":       baseUnpack,
+	"xpcall":       baseXpcall,
+}
+
+func openBase(s *State) {
+	for _, name := range sortedNames(baseFunctions) {
+		s.Globals.Set(name, NewFunction(name, baseFunctions[name]))
+	}
+	s.Globals.Set("_G", s.Globals)
+	s.Globals.Set("_VERSION", "Lua 5.1")
+}
+
+// arg returns an argument, nil when it is missing
+func arg(args []Value, i int) Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// argError returns the error of a bad argument of a library function
+func argError(i int, fname string, msg string) error {
+	return fmt.Errorf("bad argument #%d to '%s' (%s)", i+1, fname, msg)
+}
+
+// typeError returns the error of an argument of the wrong type
+func typeError(args []Value, i int, fname string, expected string) error {
+	got := "no value"
+	if i < len(args) {
+		got = TypeName(args[i])
+	}
+	return argError(i, fname, expected+" expected, got "+got)
+}
+
+func checkAny(args []Value, i int, fname string) error {
+	if i >= len(args) {
+		return argError(i, fname, "value expected")
+	}
+	return nil
+}
+
+func checkTable(args []Value, i int, fname string) (*Table, error) {
+	t, ok := arg(args, i).(*Table)
+	if !ok {
+		return nil, typeError(args, i, fname, "table")
+	}
+	return t, nil
+}
+
+func checkNumber(args []Value, i int, fname string) (float64, error) {
+	n, ok := ToNumber(arg(args, i))
+	if !ok {
+		return 0, typeError(args, i, fname, "number")
+	}
+	return n, nil
+}
+
+// checkInt returns an argument truncated to an integer
+func checkInt(args []Value, i int, fname string) (int, error) {
+	n, err := checkNumber(args, i, fname)
+	return int(n), err
+}
+
+func checkString(args []Value, i int, fname string) (string, error) {
+	str, ok := ToStringValue(arg(args, i))
+	if !ok {
+		return "", typeError(args, i, fname, "string")
+	}
+	return str, nil
+}
+
+// optInt returns an integer argument, def when it is nil or missing
+func optInt(args []Value, i int, fname string, def int) (int, error) {
+	if arg(args, i) == nil {
+		return def, nil
+	}
+	return checkInt(args, i, fname)
+}
+
+func baseAssert(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 0, "assert"); err != nil {
+		return nil, err
+	}
+	if Truthy(args[0]) {
+		return args, nil
+	}
+	if len(args) > 1 && args[1] != nil {
+		return nil, &Error{Value: args[1]}
+	}
+	return nil, errors.New("assertion failed!")
+}
+
+func baseError(s *State, args []Value) ([]Value, error) {
+	level, err := optInt(args, 1, "error", 1)
+	if err != nil {
+		return nil, err
+	}
+	v := arg(args, 0)
+	if msg, ok := v.(string); ok && level > 0 {
+		if where := s.Where(level); where != "" {
+			v = where + " " + msg
+		}
+	}
+	return nil, &Error{Value: v}
+}
+
+func baseGetmetatable(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 0, "getmetatable"); err != nil {
+		return nil, err
+	}
+	if t, ok := args[0].(*Table); ok && t.meta != nil {
+		return []Value{t.meta}, nil
+	}
+	return []Value{nil}, nil
+}
+
+func baseSetmetatable(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "setmetatable")
+	if err != nil {
+		return nil, err
+	}
+	switch meta := arg(args, 1).(type) {
+	case nil:
+		t.meta = nil
+	case *Table:
+		t.meta = meta
+	default:
+		return nil, typeError(args, 1, "setmetatable", "nil or table")
+	}
+	return []Value{t}, nil
+}
+
+var ipairsIterator = NewFunction("ipairs", func(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "ipairs")
+	if err != nil {
+		return nil, err
+	}
+	i, err := checkNumber(args, 1, "ipairs")
+	if err != nil {
+		return nil, err
+	}
+	v := t.Get(i + 1)
+	if v == nil {
+		return []Value{nil}, nil
+	}
+	return []Value{i + 1, v}, nil
+})
+
+func baseIpairs(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "ipairs")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{ipairsIterator, t, float64(0)}, nil
+}
+
+func baseNext(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "next")
+	if err != nil {
+		return nil, err
+	}
+	key, v, ok := t.Next(arg(args, 1))
+	if !ok {
+		return nil, errors.New("invalid key to 'next'")
+	}
+	if key == nil {
+		return []Value{nil}, nil
+	}
+	return []Value{key, v}, nil
+}
+
+var nextFunction = NewFunction("next", baseNext)
+
+func basePairs(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "pairs")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{nextFunction, t, nil}, nil
+}
+
+func basePcall(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 0, "pcall"); err != nil {
+		return nil, err
+	}
+	rets, err := s.CallValue(args[0], args[1:]...)
+	if err != nil {
+		if interrupted(err) {
+			return nil, err
+		}
+		return []Value{false, errorValue(err)}, nil
+	}
+	return append([]Value{true}, rets...), nil
+}
+
+func baseXpcall(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 1, "xpcall"); err != nil {
+		return nil, err
+	}
+	rets, err := s.CallValue(args[0])
+	if err != nil {
+		if interrupted(err) {
+			return nil, err
+		}
+		handled, err := s.CallValue(args[1], errorValue(err))
+		if err != nil {
+			if interrupted(err) {
+				return nil, err
+			}
+			return []Value{false, errorValue(err)}, nil
+		}
+		return append([]Value{false}, adjust(handled, 1)...), nil
+	}
+	return append([]Value{true}, rets...), nil
+}
+
+// interrupted reports whether an error is the Interrupt function of the State stopping the
+// script, which pcall and xpcall pass on
+func interrupted(err error) bool {
+	var interrupt *InterruptError
+	return errors.As(err, &interrupt)
+}
+
+// errorValue returns the value an error raised in a script holds
+func errorValue(err error) Value {
+	var luaErr *Error
+	if errors.As(err, &luaErr) {
+		return luaErr.Value
+	}
+	return err.Error()
+}
+
+func baseRawequal(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 1, "rawequal"); err != nil {
+		return nil, err
+	}
+	return []Value{rawEqual(args[0], args[1])}, nil
+}
+
+func baseRawget(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "rawget")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{t.Get(arg(args, 1))}, nil
+}
+
+func baseRawset(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "rawset")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAny(args, 2, "rawset"); err != nil {
+		return nil, err
+	}
+	if t.readOnly {
+		return nil, errors.New("Attempt to modify a readonly table")
+	}
+	if args[1] == nil {
+		return nil, errors.New("table index is nil")
+	}
+	t.Set(args[1], args[2])
+	return []Value{t}, nil
+}
+
+func baseSelect(s *State, args []Value) ([]Value, error) {
+	if arg(args, 0) == "#" {
+		return []Value{float64(len(args) - 1)}, nil
+	}
+	n, err := checkInt(args, 0, "select")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case n < 0:
+		n += len(args)
+		if n < 1 {
+			return nil, argError(0, "select", "index out of range")
+		}
+	case n == 0:
+		return nil, argError(0, "select", "index out of range")
+	}
+	if n >= len(args) {
+		return nil, nil
+	}
+	return args[n:], nil
+}
+
+func baseTonumber(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 0, "tonumber"); err != nil {
+		return nil, err
+	}
+	base, err := optInt(args, 1, "tonumber", 10)
+	if err != nil {
+		return nil, err
+	}
+	if base == 10 {
+		n, ok := ToNumber(args[0])
+		if !ok {
+			return []Value{nil}, nil
+		}
+		return []Value{n}, nil
+	}
+	if base < 2 || base > 36 {
+		return nil, argError(1, "tonumber", "base out of range")
+	}
+	str, err := checkString(args, 0, "tonumber")
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(str), base, 64)
+	if err != nil {
+		return []Value{nil}, nil
+	}
+	return []Value{float64(n)}, nil
+}
+
+func baseTostring(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 0, "tostring"); err != nil {
+		return nil, err
+	}
+	str, err := s.ToString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return []Value{str}, nil
+}
+
+// ToString converts a value to a string as tostring does, calling its __tostring metamethod
+func (s *State) ToString(v Value) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return FormatNumber(v), nil
+	case string:
+		return v, nil
+	case *Table:
+		if v.meta != nil {
+			if handler := v.meta.Get("__tostring"); handler != nil {
+				rets, err := s.CallValue(handler, v)
+				if err != nil {
+					return "", err
+				}
+				str, ok := arg(rets, 0).(string)
+				if !ok {
+					return "", errors.New("'__tostring' must return a string")
+				}
+				return str, nil
+			}
+		}
+		return fmt.Sprintf("table: %p", v), nil
+	case *Function:
+		if v.native != nil {
+			return fmt.Sprintf("function: builtin: %p", v), nil
+		}
+		return fmt.Sprintf("function: %p", v), nil
+	case *Userdata:
+		return fmt.Sprintf("userdata: %p", v), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func baseType(s *State, args []Value) ([]Value, error) {
+	if err := checkAny(args, 0, "type"); err != nil {
+		return nil, err
+	}
+	return []Value{TypeName(args[0])}, nil
+}
+
+func baseUnpack(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "unpack")
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInt(args, 1, "unpack", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInt(args, 2, "unpack", t.Len())
+	if err != nil {
+		return nil, err
+	}
+	if i > j {
+		return nil, nil
+	}
+	if j-i >= maxUnpack {
+		return nil, errors.New("too many results to unpack")
+	}
+	values := make([]Value, 0, j-i+1)
+	for k := i; k <= j; k++ {
+		values = append(values, t.Get(float64(k)))
+	}
+	return values, nil
+}
+
+// maxUnpack bounds the number of values unpack returns
+const maxUnpack = 8000
@@ -0,0 +1,123 @@
+package lua
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// openMath registers the math library. Its random numbers come from a generator seeded the
+// same way for every state, so a script gets the same numbers on every run.
+func openMath(s *State) {
+	rng := rand.New(rand.NewSource(0))
+	functions := map[string]func(*State, []Value) ([]Value, error){
+		"abs":   mathFunc1("abs", math.Abs),
+		"acos":  mathFunc1("acos", math.Acos),
+		"asin":  mathFunc1("asin", math.Asin),
+		"atan":  mathFunc1("atan", math.Atan),
+		"atan2": mathFunc2("atan2", math.Atan2),
+		"ceil":  mathFunc1("ceil", math.Ceil),
+		"cos":   mathFunc1("cos", math.Cos),
+		"deg":   mathFunc1("deg", func(x float64) float64 { return x * 180 / math.Pi }),
+		"exp":   mathFunc1("exp", math.Exp),
+		"floor": mathFunc1("floor", math.Floor),
+		"fmod":  mathFunc2("fmod", math.Mod),
+		"log":   mathFunc1("log", math.Log),
+		"log10": mathFunc1("log10", math.Log10),
+		"max":   mathMinMax("max", func(a, b float64) bool { return a > b }),
+		"min":   mathMinMax("min", func(a, b float64) bool { return a < b }),
+		"modf": func(s *State, args []Value) ([]Value, error) {
+			x, err := checkNumber(args, 0, "modf")
+			if err != nil {
+				return nil, err
+			}
+			integer, frac := math.Modf(x)
+			return []Value{integer, frac}, nil
+		},
+		"pow":  mathFunc2("pow", math.Pow),
+		"rad":  mathFunc1("rad", func(x float64) float64 { return x * math.Pi / 180 }),
+		"sin":  mathFunc1("sin", math.Sin),
+		"sqrt": mathFunc1("sqrt", math.Sqrt),
+		"tan":  mathFunc1("tan", math.Tan),
+		"random": func(s *State, args []Value) ([]Value, error) {
+			r := rng.Float64()
+			switch len(args) {
+			case 0:
+				return []Value{r}, nil
+			case 1, 2:
+				lower, upper := 1, 0
+				var err error
+				if len(args) == 1 {
+					upper, err = checkInt(args, 0, "random")
+				} else if lower, err = checkInt(args, 0, "random"); err == nil {
+					upper, err = checkInt(args, 1, "random")
+				}
+				if err != nil {
+					return nil, err
+				}
+				if lower > upper {
+					return nil, argError(len(args)-1, "random", "interval is empty")
+				}
+				return []Value{math.Floor(r*float64(upper-lower+1)) + float64(lower)}, nil
+			}
+			return nil, errors.New("wrong number of arguments")
+		},
+		"randomseed": func(s *State, args []Value) ([]Value, error) {
+			seed, err := checkInt(args, 0, "randomseed")
+			if err != nil {
+				return nil, err
+			}
+			rng.Seed(int64(seed))
+			return nil, nil
+		},
+	}
+	lib := openLibrary(s, "math", functions)
+	lib.Set("pi", math.Pi)
+	lib.Set("huge", math.Inf(1))
+}
+
+// mathFunc1 wraps a function of one number
+func mathFunc1(name string, fn func(float64) float64) func(*State, []Value) ([]Value, error) {
+	return func(s *State, args []Value) ([]Value, error) {
+		x, err := checkNumber(args, 0, name)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{fn(x)}, nil
+	}
+}
+
+// mathFunc2 wraps a function of two numbers
+func mathFunc2(name string, fn func(float64, float64) float64) func(*State, []Value) ([]Value, error) {
+	return func(s *State, args []Value) ([]Value, error) {
+		x, err := checkNumber(args, 0, name)
+		if err != nil {
+			return nil, err
+		}
+		y, err := checkNumber(args, 1, name)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{fn(x, y)}, nil
+	}
+}
+
+// mathMinMax wraps min and max, keeping the argument better than the others
+func mathMinMax(name string, better func(a, b float64) bool) func(*State, []Value) ([]Value, error) {
+	return func(s *State, args []Value) ([]Value, error) {
+		best, err := checkNumber(args, 0, name)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(args); i++ {
+			x, err := checkNumber(args, i, name)
+			if err != nil {
+				return nil, err
+			}
+			if better(x, best) {
+				best = x
+			}
+		}
+		return []Value{best}, nil
+	}
+}
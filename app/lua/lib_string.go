@@ -0,0 +1,497 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxStringSize bounds the strings string.rep builds
+const maxStringSize = 512 * 1024 * 1024
+
+var stringFunctions = map[string]func(*State, []Value) ([]Value, error){
+	"byte":    stringByte,
+	"char":    stringChar,
+	"find":    stringFind,
+	"format":  stringFormat,
+	"gmatch":  stringGmatch,
+	"gsub":    stringGsub,
+	"len":     stringLen,
+	"lower":   stringLower,
+	"match":   stringMatch,
+	"rep":     stringRep,
+	"reverse": stringReverse,
+	"sub":     stringSub,
+	"upper":   stringUpper,
+}
+
+// relativePosition turns a negative position, counted from the end of a string of length n,
+// to a positive one
+func relativePosition(pos int, n int) int {
+	if pos < 0 {
+		pos += n + 1
+	}
+	if pos < 0 {
+		return 0
+	}
+	return pos
+}
+
+func stringByte(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "byte")
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInt(args, 1, "byte", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInt(args, 2, "byte", i)
+	if err != nil {
+		return nil, err
+	}
+	i, j = relativePosition(i, len(str)), relativePosition(j, len(str))
+	if i < 1 {
+		i = 1
+	}
+	if j > len(str) {
+		j = len(str)
+	}
+	var values []Value
+	for k := i; k <= j; k++ {
+		values = append(values, float64(str[k-1]))
+	}
+	return values, nil
+}
+
+func stringChar(s *State, args []Value) ([]Value, error) {
+	b := make([]byte, len(args))
+	for i := range args {
+		c, err := checkInt(args, i, "char")
+		if err != nil {
+			return nil, err
+		}
+		if c < 0 || c > 255 {
+			return nil, argError(i, "char", "invalid value")
+		}
+		b[i] = byte(c)
+	}
+	return []Value{string(b)}, nil
+}
+
+func stringLen(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "len")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{float64(len(str))}, nil
+}
+
+func stringLower(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "lower")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{strings.ToLower(str)}, nil
+}
+
+func stringUpper(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "upper")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{strings.ToUpper(str)}, nil
+}
+
+func stringRep(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "rep")
+	if err != nil {
+		return nil, err
+	}
+	n, err := checkInt(args, 1, "rep")
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 || str == "" {
+		return []Value{""}, nil
+	}
+	if len(str)*n/n != len(str) || len(str)*n > maxStringSize {
+		return nil, errors.New("resulting string too large")
+	}
+	return []Value{strings.Repeat(str, n)}, nil
+}
+
+func stringReverse(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "reverse")
+	if err != nil {
+		return nil, err
+	}
+	b := []byte(str)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return []Value{string(b)}, nil
+}
+
+func stringSub(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "sub")
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInt(args, 1, "sub", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInt(args, 2, "sub", -1)
+	if err != nil {
+		return nil, err
+	}
+	i, j = relativePosition(i, len(str)), relativePosition(j, len(str))
+	if i < 1 {
+		i = 1
+	}
+	if j > len(str) {
+		j = len(str)
+	}
+	if i > j {
+		return []Value{""}, nil
+	}
+	return []Value{str[i-1 : j]}, nil
+}
+
+func stringFind(s *State, args []Value) ([]Value, error) {
+	return findOrMatch(args, "find", true)
+}
+
+func stringMatch(s *State, args []Value) ([]Value, error) {
+	return findOrMatch(args, "match", false)
+}
+
+// findOrMatch implements string.find, returning the positions of the match and its
+// captures, and string.match, returning the captures
+func findOrMatch(args []Value, fname string, find bool) ([]Value, error) {
+	str, err := checkString(args, 0, fname)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := checkString(args, 1, fname)
+	if err != nil {
+		return nil, err
+	}
+	init, err := optInt(args, 2, fname, 1)
+	if err != nil {
+		return nil, err
+	}
+	init = relativePosition(init, len(str)) - 1
+	if init < 0 {
+		init = 0
+	} else if init > len(str) {
+		init = len(str)
+	}
+
+	if find && (Truthy(arg(args, 3)) || !strings.ContainsAny(pattern, patternSpecials)) {
+		if pos := strings.Index(str[init:], pattern); pos >= 0 {
+			return []Value{float64(init + pos + 1), float64(init + pos + len(pattern))}, nil
+		}
+		return []Value{nil}, nil
+	}
+
+	anchor := strings.HasPrefix(pattern, "^")
+	if anchor {
+		pattern = pattern[1:]
+	}
+	ms := newMatchState(str, pattern)
+	for start := init; start <= len(str); start++ {
+		ms.reset()
+		end, err := ms.match(start, 0)
+		if err != nil {
+			return nil, err
+		}
+		if end != -1 {
+			if !find {
+				return ms.allCaptures(start, end, true)
+			}
+			captures, err := ms.allCaptures(start, end, false)
+			if err != nil {
+				return nil, err
+			}
+			return append([]Value{float64(start + 1), float64(end)}, captures...), nil
+		}
+		if anchor {
+			break
+		}
+	}
+	return []Value{nil}, nil
+}
+
+func stringGmatch(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "gmatch")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := checkString(args, 1, "gmatch")
+	if err != nil {
+		return nil, err
+	}
+	ms := newMatchState(str, pattern)
+	pos := 0
+	iterator := NewFunction("gmatch", func(s *State, args []Value) ([]Value, error) {
+		for start := pos; start <= len(str); start++ {
+			ms.reset()
+			end, err := ms.match(start, 0)
+			if err != nil {
+				return nil, err
+			}
+			if end != -1 {
+				pos = end
+				if end == start {
+					// An empty match moves on by one character
+					pos++
+				}
+				return ms.allCaptures(start, end, true)
+			}
+		}
+		pos = len(str) + 1
+		return []Value{nil}, nil
+	})
+	return []Value{iterator}, nil
+}
+
+func stringGsub(s *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "gsub")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := checkString(args, 1, "gsub")
+	if err != nil {
+		return nil, err
+	}
+	repl := arg(args, 2)
+	switch repl.(type) {
+	case float64, string, *Table, *Function:
+	default:
+		return nil, argError(2, "gsub", "string/function/table expected")
+	}
+	maxReplacements, err := optInt(args, 3, "gsub", len(str)+1)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := strings.HasPrefix(pattern, "^")
+	if anchor {
+		pattern = pattern[1:]
+	}
+	ms := newMatchState(str, pattern)
+	var b strings.Builder
+	src, n := 0, 0
+	for n < maxReplacements {
+		ms.reset()
+		end, err := ms.match(src, 0)
+		if err != nil {
+			return nil, err
+		}
+		if end != -1 {
+			n++
+			if err := s.addReplacement(&b, ms, src, end, repl); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case end != -1 && end > src:
+			src = end
+		case src < len(str):
+			b.WriteByte(str[src])
+			src++
+		default:
+			src = len(str) + 1
+		}
+		if src > len(str) || anchor {
+			break
+		}
+	}
+	if src < len(str) {
+		b.WriteString(str[src:])
+	}
+	return []Value{b.String(), float64(n)}, nil
+}
+
+// addReplacement writes the replacement of the match from start to end
+func (s *State) addReplacement(b *strings.Builder, ms *matchState, start int, end int, repl Value) error {
+	var value Value
+	switch repl := repl.(type) {
+	case float64, string:
+		news, _ := ToStringValue(repl)
+		for i := 0; i < len(news); i++ {
+			c := news[i]
+			if c != '%' || i+1 == len(news) {
+				b.WriteByte(c)
+				continue
+			}
+			i++
+			c = news[i]
+			switch {
+			case c == '0':
+				b.WriteString(ms.src[start:end])
+			case c >= '1' && c <= '9':
+				captured, err := ms.capture(int(c-'1'), start, end)
+				if err != nil {
+					return err
+				}
+				str, _ := ToStringValue(captured)
+				b.WriteString(str)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return nil
+	case *Table:
+		key, err := ms.capture(0, start, end)
+		if err != nil {
+			return err
+		}
+		value = repl.Get(key)
+	default:
+		captures, err := ms.allCaptures(start, end, true)
+		if err != nil {
+			return err
+		}
+		rets, err := s.CallValue(repl, captures...)
+		if err != nil {
+			return err
+		}
+		value = arg(rets, 0)
+	}
+	if !Truthy(value) {
+		// Keeps the original text
+		b.WriteString(ms.src[start:end])
+		return nil
+	}
+	switch v := value.(type) {
+	case string:
+		b.WriteString(v)
+	case float64:
+		b.WriteString(FormatNumber(v))
+	default:
+		return fmt.Errorf("invalid replacement value (a %s)", TypeName(value))
+	}
+	return nil
+}
+
+func stringFormat(s *State, args []Value) ([]Value, error) {
+	format, err := checkString(args, 0, "format")
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	n := 0 // Index of the last argument used
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		// Flags, width and precision, of up to two digits each
+		start := i
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			i++
+		}
+		if i-start > 5 {
+			return nil, errors.New("invalid format (repeated flags)")
+		}
+		for digits := 0; i < len(format) && isDigit(format[i]); digits++ {
+			if digits == 2 {
+				return nil, errors.New("invalid format (width or precision too long)")
+			}
+			i++
+		}
+		hasPrecision := false
+		if i < len(format) && format[i] == '.' {
+			hasPrecision = true
+			i++
+			for digits := 0; i < len(format) && isDigit(format[i]); digits++ {
+				if digits == 2 {
+					return nil, errors.New("invalid format (width or precision too long)")
+				}
+				i++
+			}
+		}
+		if i >= len(format) {
+			return nil, errors.New("invalid option '%' to 'format'")
+		}
+		spec := "%" + format[start:i]
+		n++
+		switch conv := format[i]; conv {
+		case 'c':
+			code, err := checkInt(args, n, "format")
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(byte(code))
+		case 'd', 'i':
+			num, err := checkNumber(args, n, "format")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, spec+"d", int64(num))
+		case 'o', 'u', 'x', 'X':
+			num, err := checkNumber(args, n, "format")
+			if err != nil {
+				return nil, err
+			}
+			verb := string(conv)
+			if conv == 'u' {
+				verb = "d"
+			}
+			fmt.Fprintf(&b, spec+verb, uint64(int64(num)))
+		case 'e', 'E', 'f', 'g', 'G':
+			num, err := checkNumber(args, n, "format")
+			if err != nil {
+				return nil, err
+			}
+			if !hasPrecision {
+				// C defaults to 6 digits, where Go prints as many as needed
+				spec += ".6"
+			}
+			fmt.Fprintf(&b, spec+string(conv), num)
+		case 'q':
+			str, err := checkString(args, n, "format")
+			if err != nil {
+				return nil, err
+			}
+			writeQuoted(&b, str)
+		case 's':
+			str, err := checkString(args, n, "format")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, spec+"s", str)
+		default:
+			return nil, fmt.Errorf("invalid option '%%%c' to 'format'", conv)
+		}
+	}
+	return []Value{b.String()}, nil
+}
+
+// writeQuoted writes a string between quotes so Lua reads it back, as %q does
+func writeQuoted(b *strings.Builder, str string) {
+	b.WriteByte('"')
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '"', '\\', '\n':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\r':
+			b.WriteString("\\r")
+		case 0:
+			b.WriteString("\\000")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+}
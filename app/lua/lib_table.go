@@ -0,0 +1,184 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var tableFunctions = map[string]func(*State, []Value) ([]Value, error){
+	"concat": tableConcat,
+	"getn":   tableGetn,
+	"insert": tableInsert,
+	"maxn":   tableMaxn,
+	"remove": tableRemove,
+	"sort":   tableSort,
+}
+
+func tableConcat(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "concat")
+	if err != nil {
+		return nil, err
+	}
+	sep := ""
+	if arg(args, 1) != nil {
+		if sep, err = checkString(args, 1, "concat"); err != nil {
+			return nil, err
+		}
+	}
+	i, err := optInt(args, 2, "concat", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInt(args, 3, "concat", t.Len())
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for k := i; k <= j; k++ {
+		str, ok := ToStringValue(t.Get(float64(k)))
+		if !ok {
+			return nil, fmt.Errorf("invalid value (at index %d) in table for 'concat'", k)
+		}
+		b.WriteString(str)
+		if k < j {
+			b.WriteString(sep)
+		}
+	}
+	return []Value{b.String()}, nil
+}
+
+func tableGetn(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "getn")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{float64(t.Len())}, nil
+}
+
+func tableMaxn(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "maxn")
+	if err != nil {
+		return nil, err
+	}
+	maxKey := 0.0
+	t.ForEach(func(key Value, _ Value) {
+		if n, ok := key.(float64); ok && n > maxKey {
+			maxKey = n
+		}
+	})
+	return []Value{maxKey}, nil
+}
+
+func tableInsert(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "insert")
+	if err != nil {
+		return nil, err
+	}
+	if t.readOnly {
+		return nil, errors.New("Attempt to modify a readonly table")
+	}
+	n := t.Len()
+	switch len(args) {
+	case 2:
+		t.Set(float64(n+1), args[1])
+	case 3:
+		pos, err := checkInt(args, 1, "insert")
+		if err != nil {
+			return nil, err
+		}
+		if pos > n+1 {
+			n = pos - 1
+		}
+		for i := n + 1; i > pos; i-- {
+			t.Set(float64(i), t.Get(float64(i-1)))
+		}
+		t.Set(float64(pos), args[2])
+	default:
+		return nil, errors.New("wrong number of arguments to 'insert'")
+	}
+	return nil, nil
+}
+
+func tableRemove(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "remove")
+	if err != nil {
+		return nil, err
+	}
+	if t.readOnly {
+		return nil, errors.New("Attempt to modify a readonly table")
+	}
+	n := t.Len()
+	pos, err := optInt(args, 1, "remove", n)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	removed := t.Get(float64(pos))
+	for i := pos; i < n; i++ {
+		t.Set(float64(i), t.Get(float64(i+1)))
+	}
+	t.Set(float64(n), nil)
+	return []Value{removed}, nil
+}
+
+func tableSort(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "sort")
+	if err != nil {
+		return nil, err
+	}
+	comp := arg(args, 1)
+	if comp != nil {
+		if _, ok := comp.(*Function); !ok {
+			return nil, typeError(args, 1, "sort", "function")
+		}
+	}
+	n := t.Len()
+	values := make([]Value, n)
+	for i := range values {
+		values[i] = t.Get(float64(i + 1))
+	}
+
+	// The first error of a comparison stops the sort
+	var sortErr error
+	less := func(a, b Value) bool {
+		if sortErr != nil {
+			return false
+		}
+		if comp != nil {
+			rets, err := s.CallValue(comp, a, b)
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			return Truthy(arg(rets, 0))
+		}
+		switch a := a.(type) {
+		case float64:
+			if b, ok := b.(float64); ok {
+				return a < b
+			}
+		case string:
+			if b, ok := b.(string); ok {
+				return a < b
+			}
+		}
+		if TypeName(a) == TypeName(b) {
+			sortErr = fmt.Errorf("attempt to compare two %s values", TypeName(a))
+		} else {
+			sortErr = fmt.Errorf("attempt to compare %s with %s", TypeName(a), TypeName(b))
+		}
+		return false
+	}
+	sort.SliceStable(values, func(i, j int) bool { return less(values[i], values[j]) })
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	for i, v := range values {
+		t.Set(float64(i+1), v)
+	}
+	return nil, nil
+}
@@ -0,0 +1,153 @@
+package lua
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBaseLibrary(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Value
+	}{
+		{"pcall returns results", "local ok, v = pcall(function(x) return x * 2 end, 21) return tostring(ok) .. v", "true42"},
+		{"pcall catches errors", "local ok, err = pcall(error, 'boom', 0) return tostring(ok) .. err", "falseboom"},
+		{"pcall catches runtime errors", "local ok, err = pcall(function() return nil + 1 end) return err", "test:1: attempt to perform arithmetic on a nil value"},
+		{"error raises tables", "local ok, err = pcall(error, {code = 7}) return err.code", float64(7)},
+		{"xpcall calls the handler", "local ok, v = xpcall(function() error('x', 0) end, function(e) return 'handled ' .. e end) return v", "handled x"},
+		{"assert passes its arguments", "return select('#', assert(1, 2, 3))", float64(3)},
+		{"assert message", "local ok, err = pcall(assert, false, 'nope') return err", "nope"},
+		{"select negative", "return select(-1, 'a', 'b', 'c')", "c"},
+		{"next", "local k, v = next({x = 1}) return k .. v", "x1"},
+		{"next of empty", "return next({})", nil},
+		{"rawequal", "return rawequal('a', 'a') and not rawequal({}, {})", true},
+		{"getmetatable", "local mt = {} return getmetatable(setmetatable({}, mt)) == mt", true},
+		{"tostring of nil and booleans", "return tostring(nil) .. tostring(true)", "niltrue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := runOne(t, tt.src); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestStringLibrary(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Value
+	}{
+		{"sub", "return string.sub('hello', 2, -2)", "ell"},
+		{"sub out of range", "return string.sub('hello', 10)", ""},
+		{"byte and char", "return string.char(string.byte('ABC', 1, 3))", "ABC"},
+		{"rep", "return string.rep('ab', 3)", "ababab"},
+		{"reverse", "return string.reverse('abc')", "cba"},
+		{"lower and upper", "return string.lower('MiXeD') .. string.upper('x')", "mixedX"},
+		{"len counts bytes", "return string.len('\\0ab')", float64(3)},
+		{"format integers", "return string.format('%d|%5d|%-3d|%x', 42, 7, 1, 255)", "42|    7|1  |ff"},
+		{"format floats", "return string.format('%.2f|%g', 3.14159, 0.5)", "3.14|0.5"},
+		{"format strings", "return string.format('%s=%q', 'k', 'a\"b')", `k="a\"b"`},
+		{"format percent", "return string.format('100%%')", "100%"},
+		{"find plain", "return string.find('a.b', '.', 1, true)", float64(2)},
+		{"find pattern", "local s, e = string.find('hello world', 'o w') return s * 10 + e", float64(57)},
+		{"find captures", "return select(3, string.find('key=val', '(%w+)='))", "key"},
+		{"find missing", "return string.find('abc', 'z')", nil},
+		{"match anchored", "return string.match('  trim  ', '^%s*(.-)%s*$')", "trim"},
+		{"match classes", "return string.match('abc123def', '%d+')", "123"},
+		{"match sets", "return string.match('x-y_z', '[%w_]+$')", "y_z"},
+		{"match position capture", "return string.match('hello', '()ll')", float64(3)},
+		{"match balanced", "return string.match('f(a(b)c) d', '%b()')", "(a(b)c)"},
+		{"match frontier", "return string.match('THE (quick) fox', '%f[%a]%a+', 5)", "quick"},
+		{"match back reference", "return string.match('say \"hi\" now', '([\"\\'])(.-)%1')", "\""},
+		{"gsub string", "return (string.gsub('hello world', 'o', '0'))", "hell0 w0rld"},
+		{"gsub count", "return select(2, string.gsub('aaa', 'a', 'b', 2))", float64(2)},
+		{"gsub captures", "return (string.gsub('k=v', '(%w)=(%w)', '%2=%1'))", "v=k"},
+		{"gsub table", "return (string.gsub('$a $b', '%$(%w)', {a = 1, b = 'x'}))", "1 x"},
+		{"gsub function", "return (string.gsub('abc', '%w', function(c) return c:upper() .. '.' end))", "A.B.C."},
+		{"gmatch", "local t = {} for k, v in string.gmatch('a=1, b=2', '(%w+)=(%w+)') do t[#t + 1] = k .. v end return table.concat(t, ';')", "a1;b2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := runOne(t, tt.src); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTableAndMathLibraries(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Value
+	}{
+		{"concat", "return table.concat({1, 'a', 2}, ', ')", "1, a, 2"},
+		{"concat range", "return table.concat({1, 2, 3, 4}, '', 2, 3)", "23"},
+		{"insert at position", "local t = {1, 3} table.insert(t, 2, 2) return table.concat(t)", "123"},
+		{"remove", "local t = {1, 2, 3} local v = table.remove(t, 1) return v .. table.concat(t)", "123"},
+		{"remove last", "local t = {1, 2, 3} return table.remove(t) + #t", float64(5)},
+		{"sort", "local t = {3, 1, 2} table.sort(t) return table.concat(t)", "123"},
+		{"sort with comparator", "local t = {'b', 'c', 'a'} table.sort(t, function(a, b) return a > b end) return table.concat(t)", "cba"},
+		{"getn and maxn", "return table.getn({1, 2}) + table.maxn({[5] = 1})", float64(7)},
+		{"floor and ceil", "return math.floor(-1.5) + math.ceil(1.2)", float64(0)},
+		{"min and max", "return math.min(3, -2, 7) + math.max(3, -2, 7)", float64(5)},
+		{"fmod and modf", "local i, f = math.modf(3.25) return math.fmod(7, 3) + i + f", float64(4.25)},
+		{"sqrt and pow", "return math.sqrt(16) + math.pow(2, 3)", float64(12)},
+		{"huge", "return math.huge > 1e308", true},
+		{"random in range", "math.randomseed(1) local r = math.random(5, 6) return r == 5 or r == 6", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := runOne(t, tt.src); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLibrariesAreReadOnly(t *testing.T) {
+	_, err := run(t, "string.upper = nil")
+	if err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Errorf("Expected changing a library to fail, got %v", err)
+	}
+}
+
+func TestCJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected Value
+	}{
+		{"encode object", "return cjson.encode({a = 1})", `{"a":1}`},
+		{"encode array", "return cjson.encode({1, 'x', true})", `[1,"x",true]`},
+		{"encode empty table", "return cjson.encode({})", `{}`},
+		{"encode escapes", `return cjson.encode("a\"b\n")`, `"a\"b\n"`},
+		{"encode null", "return cjson.encode({cjson.null})", `[null]`},
+		{"encode fractions", "return cjson.encode(0.5)", `0.5`},
+		{"decode object", `local v = cjson.decode('{"a":{"b":[1,2,3]}}') return v.a.b[3]`, float64(3)},
+		{"decode unicode escape", `return cjson.decode('"\\u00e9"')`, "é"},
+		{"decode null", `return cjson.decode('[null]')[1] == cjson.null`, true},
+		{"round trip", `return cjson.encode(cjson.decode('{"k":[1,{"x":"y"}]}'))`, `{"k":[1,{"x":"y"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := runOne(t, tt.src); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+
+	for _, src := range []string{`cjson.decode('{"a":')`, `cjson.decode('[1,]x')`, `cjson.encode(function() end)`} {
+		if _, err := run(t, src); err == nil {
+			t.Errorf("Expected %s to fail", src)
+		}
+	}
+}
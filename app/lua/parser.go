@@ -0,0 +1,889 @@
+package lua
+
+// maxSyntaxLevels bounds the nesting of blocks and expressions, so a script can't exhaust
+// the stack of the parser
+const maxSyntaxLevels = 200
+
+// binaryPriority holds the left and right priorities of binary operators, a higher one
+// binding tighter. Concatenation and exponentiation are right associative.
+var binaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"..": {5, 4},
+	"+":  {6, 6}, "-": {6, 6},
+	"*": {7, 7}, "/": {7, 7}, "%": {7, 7},
+	"^": {10, 9},
+}
+
+// unaryPriority is the priority of not, - and #
+const unaryPriority = 8
+
+// activeLocal is a local variable in scope
+type activeLocal struct {
+	name string
+	slot int
+}
+
+// funcState is the state of the function being parsed
+type funcState struct {
+	parent  *funcState
+	proto   *funcProto
+	actives []activeLocal // Locals in scope, innermost last
+}
+
+// parser builds the tree of a script from its tokens
+type parser struct {
+	lex    *lexer
+	tok    token
+	ahead  token
+	peeked bool
+	fs     *funcState
+	levels int
+}
+
+// parse parses a script as the body of a function taking any number of arguments
+func parse(chunk string, src string) (*funcProto, error) {
+	p := &parser{lex: &lexer{chunk: chunk, src: src, line: 1}}
+	p.fs = &funcState{proto: &funcProto{chunk: chunk, name: "main chunk", isVararg: true}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorExpected("<eof>")
+	}
+	p.fs.proto.body = body
+	return p.fs.proto, nil
+}
+
+// advance moves to the next token
+func (p *parser) advance() error {
+	if p.peeked {
+		p.tok, p.peeked = p.ahead, false
+		return nil
+	}
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// peek returns the token after the current one
+func (p *parser) peek() (token, error) {
+	if !p.peeked {
+		tok, err := p.lex.next()
+		if err != nil {
+			return token{}, err
+		}
+		p.ahead, p.peeked = tok, true
+	}
+	return p.ahead, nil
+}
+
+// is reports whether the current token is the given keyword or symbol
+func (p *parser) is(text string) bool {
+	return (p.tok.kind == tokenKeyword || p.tok.kind == tokenSymbol) && p.tok.text == text
+}
+
+// accept skips the current token when it is the given keyword or symbol
+func (p *parser) accept(text string) (bool, error) {
+	if !p.is(text) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+// expect skips the given keyword or symbol, failing when it isn't the current token
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		return p.errorExpected(text)
+	}
+	return p.advance()
+}
+
+// expectMatch skips the keyword or symbol closing the one opened at line
+func (p *parser) expectMatch(text string, opening string, line int) error {
+	if p.is(text) {
+		return p.advance()
+	}
+	if line == p.tok.line {
+		return p.errorExpected(text)
+	}
+	return p.lex.syntaxError(p.tok.line, p.tok.text, "'%s' expected (to close '%s' at line %d)", text, opening, line)
+}
+
+// expectName returns the current token when it is a name and skips it
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorExpected("<name>")
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) errorExpected(what string) error {
+	return p.lex.syntaxError(p.tok.line, p.tokenText(), "'%s' expected", what)
+}
+
+// tokenText returns the current token as error messages quote it
+func (p *parser) tokenText() string {
+	return p.tok.text
+}
+
+// enterLevel counts a nested block or expression
+func (p *parser) enterLevel() error {
+	p.levels++
+	if p.levels > maxSyntaxLevels {
+		return p.lex.syntaxError(p.tok.line, "", "chunk has too many syntax levels")
+	}
+	return nil
+}
+
+// declareLocal adds a local variable to the current scope and returns its slot
+func (p *parser) declareLocal(name string) int {
+	fs := p.fs
+	slot := fs.proto.slots
+	fs.proto.slots++
+	fs.actives = append(fs.actives, activeLocal{name: name, slot: slot})
+	return slot
+}
+
+// findLocal returns the slot of a local variable in scope in fs
+func (fs *funcState) findLocal(name string) (int, bool) {
+	for i := len(fs.actives) - 1; i >= 0; i-- {
+		if fs.actives[i].name == name {
+			return fs.actives[i].slot, true
+		}
+	}
+	return 0, false
+}
+
+// findUpvalue returns the index of the upvalue of fs holding a variable of an enclosing
+// function, adding it to the upvalues of fs and the functions in between
+func (fs *funcState) findUpvalue(name string) (int, bool) {
+	for i, upvalue := range fs.proto.upvalues {
+		if upvalue.name == name {
+			return i, true
+		}
+	}
+	if fs.parent == nil {
+		return 0, false
+	}
+	desc := upvalueDesc{name: name}
+	if slot, ok := fs.parent.findLocal(name); ok {
+		desc.fromLocal, desc.index = true, slot
+	} else if index, ok := fs.parent.findUpvalue(name); ok {
+		desc.index = index
+	} else {
+		return 0, false
+	}
+	fs.proto.upvalues = append(fs.proto.upvalues, desc)
+	return len(fs.proto.upvalues) - 1, true
+}
+
+// resolve returns the expression reading a variable
+func (p *parser) resolve(name string, line int) expr {
+	if slot, ok := p.fs.findLocal(name); ok {
+		return &localExpr{name: name, slot: slot}
+	}
+	if index, ok := p.fs.findUpvalue(name); ok {
+		return &upvalueExpr{name: name, index: index}
+	}
+	return &globalExpr{name: name, line: line}
+}
+
+// blockEnds reports whether the current token ends a block
+func (p *parser) blockEnds() bool {
+	switch {
+	case p.tok.kind == tokenEOF:
+		return true
+	case p.tok.kind == tokenKeyword:
+		switch p.tok.text {
+		case "end", "else", "elseif", "until":
+			return true
+		}
+	}
+	return false
+}
+
+// block parses statements in a new scope
+func (p *parser) block() (block, error) {
+	saved := len(p.fs.actives)
+	body, err := p.statements()
+	p.fs.actives = p.fs.actives[:saved]
+	return body, err
+}
+
+// statements parses statements until the end of the block, in the current scope
+func (p *parser) statements() (block, error) {
+	if err := p.enterLevel(); err != nil {
+		return nil, err
+	}
+	defer func() { p.levels-- }()
+
+	var body block
+	for !p.blockEnds() {
+		if p.is("return") {
+			ret, err := p.returnStatement()
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, ret)
+			// return ends the block
+			if !p.blockEnds() {
+				return nil, p.errorExpected("end")
+			}
+			break
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			body = append(body, s)
+		}
+	}
+	return body, nil
+}
+
+// returnStatement parses return [explist] [;]
+func (p *parser) returnStatement() (stmt, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	ret := &returnStmt{}
+	if !p.blockEnds() && !p.is(";") {
+		exprs, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		ret.exprs = exprs
+	}
+	if _, err := p.accept(";"); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// statement parses a statement, nil for an empty one
+func (p *parser) statement() (stmt, error) {
+	line := p.tok.line
+	switch {
+	case p.is(";"):
+		return nil, p.advance()
+	case p.is("if"):
+		return p.ifStatement(line)
+	case p.is("while"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("do"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return &whileStmt{cond: cond, body: body}, p.expectMatch("end", "while", line)
+	case p.is("do"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return &doStmt{body: body}, p.expectMatch("end", "do", line)
+	case p.is("for"):
+		return p.forStatement(line)
+	case p.is("repeat"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		// The condition sees the locals of the body
+		saved := len(p.fs.actives)
+		body, err := p.statements()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectMatch("until", "repeat", line); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		p.fs.actives = p.fs.actives[:saved]
+		return &repeatStmt{body: body, cond: cond}, err
+	case p.is("function"):
+		return p.functionStatement(line)
+	case p.is("local"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.accept("function"); err != nil {
+			return nil, err
+		} else if ok {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			// The function can call itself
+			slot := p.declareLocal(name)
+			proto, err := p.functionBody(name, false, line)
+			return &localFunctionStmt{slot: slot, proto: proto}, err
+		}
+		return p.localStatement()
+	case p.is("return"):
+		return p.returnStatement()
+	case p.is("break"):
+		return &breakStmt{}, p.advance()
+	}
+	return p.exprStatement()
+}
+
+// ifStatement parses if cond then block {elseif cond then block} [else block] end
+func (p *parser) ifStatement(line int) (stmt, error) {
+	s := &ifStmt{}
+	for {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.conds = append(s.conds, cond)
+		s.blocks = append(s.blocks, body)
+		if !p.is("elseif") {
+			break
+		}
+	}
+	if ok, err := p.accept("else"); err != nil {
+		return nil, err
+	} else if ok {
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.orElse = append(block{}, body...)
+	}
+	return s, p.expectMatch("end", "if", line)
+}
+
+// forStatement parses a numeric or a generic for loop
+func (p *parser) forStatement(line int) (stmt, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	first, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	saved := len(p.fs.actives)
+	defer func() { p.fs.actives = p.fs.actives[:saved] }()
+
+	if ok, err := p.accept("="); err != nil {
+		return nil, err
+	} else if ok {
+		s := &numericForStmt{line: line}
+		if s.start, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if s.limit, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.accept(","); err != nil {
+			return nil, err
+		} else if ok {
+			if s.step, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("do"); err != nil {
+			return nil, err
+		}
+		s.slot = p.declareLocal(first)
+		if s.body, err = p.block(); err != nil {
+			return nil, err
+		}
+		return s, p.expectMatch("end", "for", line)
+	}
+
+	names := []string{first}
+	for {
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	s := &genericForStmt{line: line}
+	if s.exprs, err = p.exprList(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		s.slots = append(s.slots, p.declareLocal(name))
+	}
+	if s.body, err = p.block(); err != nil {
+		return nil, err
+	}
+	return s, p.expectMatch("end", "for", line)
+}
+
+// functionStatement parses function a.b.c:m() body end
+func (p *parser) functionStatement(line int) (stmt, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	target := p.resolve(name, line)
+	fullName := name
+	isMethod := false
+	for p.is(".") || p.is(":") {
+		isMethod = p.is(":")
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		key, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		target = &indexExpr{object: target, key: &stringExpr{value: key}, line: line}
+		fullName += "." + key
+		if isMethod {
+			break
+		}
+	}
+	proto, err := p.functionBody(fullName, isMethod, line)
+	if err != nil {
+		return nil, err
+	}
+	return &assignStmt{targets: []expr{target}, exprs: []expr{&functionExpr{proto: proto}}, line: line}, nil
+}
+
+// localStatement parses local name {, name} [= explist]
+func (p *parser) localStatement() (stmt, error) {
+	var names []string
+	for {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+	s := &localStmt{}
+	if ok, err := p.accept("="); err != nil {
+		return nil, err
+	} else if ok {
+		if s.exprs, err = p.exprList(); err != nil {
+			return nil, err
+		}
+	}
+	// The new locals are only in scope after the statement
+	for _, name := range names {
+		s.slots = append(s.slots, p.declareLocal(name))
+	}
+	return s, nil
+}
+
+// exprStatement parses an assignment or a function call
+func (p *parser) exprStatement() (stmt, error) {
+	line := p.tok.line
+	first, err := p.suffixedExpr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("=") && !p.is(",") {
+		switch first.(type) {
+		case *callExpr, *methodCallExpr:
+			return &callStmt{call: first}, nil
+		}
+		return nil, p.lex.syntaxError(p.tok.line, p.tokenText(), "syntax error")
+	}
+
+	targets := []expr{first}
+	for {
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		target, err := p.suffixedExpr()
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	for _, target := range targets {
+		switch target.(type) {
+		case *localExpr, *upvalueExpr, *globalExpr, *indexExpr:
+		default:
+			return nil, p.lex.syntaxError(line, "", "syntax error (cannot assign to this expression)")
+		}
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	exprs, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	return &assignStmt{targets: targets, exprs: exprs, line: line}, nil
+}
+
+// functionBody parses (params) body end as a new function
+func (p *parser) functionBody(name string, isMethod bool, line int) (*funcProto, error) {
+	fs := &funcState{parent: p.fs, proto: &funcProto{chunk: p.lex.chunk, name: name, line: line}}
+	p.fs = fs
+	defer func() { p.fs = fs.parent }()
+
+	if isMethod {
+		fs.proto.params = append(fs.proto.params, p.declareLocal("self"))
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		if ok, err := p.accept("..."); err != nil {
+			return nil, err
+		} else if ok {
+			fs.proto.isVararg = true
+			break
+		}
+		param, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		fs.proto.params = append(fs.proto.params, p.declareLocal(param))
+		if ok, err := p.accept(","); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	fs.proto.body = body
+	return fs.proto, p.expectMatch("end", "function", line)
+}
+
+// exprList parses expressions separated by commas
+func (p *parser) exprList() ([]expr, error) {
+	var exprs []expr
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return exprs, nil
+		}
+	}
+}
+
+// expr parses an expression
+func (p *parser) expr() (expr, error) {
+	return p.subExpr(0)
+}
+
+// subExpr parses an expression whose binary operators bind tighter than limit
+func (p *parser) subExpr(limit int) (expr, error) {
+	if err := p.enterLevel(); err != nil {
+		return nil, err
+	}
+	defer func() { p.levels-- }()
+
+	var left expr
+	if p.is("not") || p.is("-") || p.is("#") {
+		op, line := p.tok.text, p.tok.line
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.subExpr(unaryPriority)
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := operand.(*numberExpr); ok && op == "-" {
+			left = &numberExpr{value: -n.value}
+		} else {
+			left = &unaryExpr{op: op, operand: operand, line: line}
+		}
+	} else {
+		var err error
+		if left, err = p.simpleExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		if p.tok.kind != tokenSymbol && p.tok.kind != tokenKeyword {
+			return left, nil
+		}
+		priority, ok := binaryPriority[p.tok.text]
+		if !ok || priority[0] <= limit {
+			return left, nil
+		}
+		op, line := p.tok.text, p.tok.line
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.subExpr(priority[1])
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "and":
+			left = &andExpr{left: left, right: right}
+		case "or":
+			left = &orExpr{left: left, right: right}
+		default:
+			left = &binaryExpr{op: op, left: left, right: right, line: line}
+		}
+	}
+}
+
+// simpleExpr parses a literal, a table constructor, a function or a suffixed expression
+func (p *parser) simpleExpr() (expr, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokenNumber:
+		return &numberExpr{value: tok.num}, p.advance()
+	case tok.kind == tokenString:
+		return &stringExpr{value: tok.text}, p.advance()
+	case p.is("nil"):
+		return &nilExpr{}, p.advance()
+	case p.is("true"):
+		return &trueExpr{}, p.advance()
+	case p.is("false"):
+		return &falseExpr{}, p.advance()
+	case p.is("..."):
+		if !p.fs.proto.isVararg {
+			return nil, p.lex.syntaxError(tok.line, "...", "cannot use '...' outside a vararg function")
+		}
+		return &varargExpr{}, p.advance()
+	case p.is("{"):
+		return p.tableConstructor()
+	case p.is("function"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		proto, err := p.functionBody("anonymous", false, tok.line)
+		if err != nil {
+			return nil, err
+		}
+		return &functionExpr{proto: proto}, nil
+	}
+	return p.suffixedExpr()
+}
+
+// primaryExpr parses a name or an expression between parentheses
+func (p *parser) primaryExpr() (expr, error) {
+	switch {
+	case p.tok.kind == tokenName:
+		name := p.tok.text
+		return p.resolve(name, p.tok.line), p.advance()
+	case p.is("("):
+		line := p.tok.line
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &parenExpr{inner: inner}, p.expectMatch(")", "(", line)
+	}
+	return nil, p.lex.syntaxError(p.tok.line, p.tokenText(), "unexpected symbol")
+}
+
+// suffixedExpr parses a primary expression followed by fields, indexes and calls
+func (p *parser) suffixedExpr() (expr, error) {
+	e, err := p.primaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		line := p.tok.line
+		switch {
+		case p.is("."):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			e = &indexExpr{object: e, key: &stringExpr{value: name}, line: line}
+		case p.is("["):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			key, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			e = &indexExpr{object: e, key: key, line: line}
+		case p.is(":"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			args, err := p.callArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &methodCallExpr{object: e, name: name, args: args, line: line}
+		case p.is("(") || p.is("{") || p.tok.kind == tokenString:
+			args, err := p.callArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &callExpr{fn: e, args: args, line: line}
+		default:
+			return e, nil
+		}
+	}
+}
+
+// callArgs parses the arguments of a call: (explist), a table constructor or a string
+func (p *parser) callArgs() ([]expr, error) {
+	switch {
+	case p.tok.kind == tokenString:
+		s := p.tok.text
+		return []expr{&stringExpr{value: s}}, p.advance()
+	case p.is("{"):
+		table, err := p.tableConstructor()
+		if err != nil {
+			return nil, err
+		}
+		return []expr{table}, nil
+	case p.is("("):
+		line := p.tok.line
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.accept(")"); err != nil || ok {
+			return nil, err
+		}
+		args, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		return args, p.expectMatch(")", "(", line)
+	}
+	return nil, p.lex.syntaxError(p.tok.line, p.tokenText(), "function arguments expected")
+}
+
+// tableConstructor parses { [fields] }
+func (p *parser) tableConstructor() (expr, error) {
+	line := p.tok.line
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	t := &tableExpr{line: line}
+	for !p.is("}") {
+		var field tableField
+		switch {
+		case p.is("["):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			key, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			field.key = key
+		case p.tok.kind == tokenName:
+			next, err := p.peek()
+			if err != nil {
+				return nil, err
+			}
+			if next.kind == tokenSymbol && next.text == "=" {
+				field.key = &stringExpr{value: p.tok.text}
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		field.value = value
+		t.fields = append(t.fields, field)
+		if !p.is(",") && !p.is(";") {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return t, p.expectMatch("}", "{", line)
+}
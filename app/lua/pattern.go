@@ -0,0 +1,400 @@
+package lua
+
+import (
+	"errors"
+	"strings"
+)
+
+// Lua patterns, matched by backtracking like the string library of Lua 5.1 does
+
+const (
+	maxCaptures     = 32
+	maxMatchDepth   = 200
+	captureOpen     = -1 // Length of a capture not closed yet
+	capturePosition = -2 // Length of a position capture, ()
+	patternSpecials = "^$*+?.([%-"
+)
+
+type capture struct {
+	start  int
+	length int
+}
+
+// matchState is the state of a match of a pattern against a string
+type matchState struct {
+	src      string
+	pattern  string
+	level    int // Number of captures
+	captures [maxCaptures]capture
+	depth    int // Calls of match left before the pattern is too complex
+}
+
+func newMatchState(src string, pattern string) *matchState {
+	return &matchState{src: src, pattern: pattern}
+}
+
+// reset prepares the state for a new match attempt
+func (ms *matchState) reset() {
+	ms.level = 0
+	ms.depth = maxMatchDepth
+}
+
+// classEnd returns the position following the single character class at p
+func (ms *matchState) classEnd(p int) (int, error) {
+	pat := ms.pattern
+	c := pat[p]
+	p++
+	switch c {
+	case '%':
+		if p >= len(pat) {
+			return 0, errors.New("malformed pattern (ends with '%')")
+		}
+		return p + 1, nil
+	case '[':
+		if p < len(pat) && pat[p] == '^' {
+			p++
+		}
+		// The first character of the set is never the closing ]
+		for {
+			if p >= len(pat) {
+				return 0, errors.New("malformed pattern (missing ']')")
+			}
+			c := pat[p]
+			p++
+			if c == '%' && p < len(pat) {
+				p++
+			}
+			if p < len(pat) && pat[p] == ']' {
+				return p + 1, nil
+			}
+		}
+	}
+	return p, nil
+}
+
+// matchClass reports whether c belongs to the class %cl
+func matchClass(c byte, cl byte) bool {
+	var res bool
+	lower := cl | 0x20
+	switch lower {
+	case 'a':
+		res = isAlpha(c)
+	case 'c':
+		res = c < 32 || c == 127
+	case 'd':
+		res = c >= '0' && c <= '9'
+	case 'l':
+		res = c >= 'a' && c <= 'z'
+	case 'p':
+		res = isPunct(c)
+	case 's':
+		res = c == ' ' || c >= '\t' && c <= '\r'
+	case 'u':
+		res = c >= 'A' && c <= 'Z'
+	case 'w':
+		res = isAlpha(c) || c >= '0' && c <= '9'
+	case 'x':
+		res = c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+	case 'z':
+		res = c == 0
+	default:
+		return cl == c
+	}
+	if cl >= 'A' && cl <= 'Z' {
+		return !res
+	}
+	return res
+}
+
+func isAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isPunct(c byte) bool {
+	return c > 32 && c < 127 && !isAlpha(c) && !(c >= '0' && c <= '9')
+}
+
+// matchBracketClass reports whether c belongs to the set starting with [ at p and ending
+// with ] at end
+func (ms *matchState) matchBracketClass(c byte, p int, end int) bool {
+	pat := ms.pattern
+	matches := true
+	if pat[p+1] == '^' {
+		matches = false
+		p++
+	}
+	for p++; p < end; p++ {
+		switch {
+		case pat[p] == '%':
+			p++
+			if matchClass(c, pat[p]) {
+				return matches
+			}
+		case pat[p+1] == '-' && p+2 < end:
+			p += 2
+			if pat[p-2] <= c && c <= pat[p] {
+				return matches
+			}
+		case pat[p] == c:
+			return matches
+		}
+	}
+	return !matches
+}
+
+// singleMatch reports whether the character at s matches the class from p to ep
+func (ms *matchState) singleMatch(s int, p int, ep int) bool {
+	if s >= len(ms.src) {
+		return false
+	}
+	c := ms.src[s]
+	switch ms.pattern[p] {
+	case '.':
+		return true
+	case '%':
+		return matchClass(c, ms.pattern[p+1])
+	case '[':
+		return ms.matchBracketClass(c, p, ep-1)
+	}
+	return ms.pattern[p] == c
+}
+
+// match matches the pattern from p against the string from s, returning the end of the
+// match or -1
+func (ms *matchState) match(s int, p int) (int, error) {
+	ms.depth--
+	if ms.depth == 0 {
+		return -1, errors.New("pattern too complex")
+	}
+	defer func() { ms.depth++ }()
+
+	pat := ms.pattern
+	for {
+		if p == len(pat) {
+			return s, nil
+		}
+		switch pat[p] {
+		case '(':
+			if p+1 < len(pat) && pat[p+1] == ')' {
+				return ms.startCapture(s, p+2, capturePosition)
+			}
+			return ms.startCapture(s, p+1, captureOpen)
+		case ')':
+			return ms.endCapture(s, p+1)
+		case '$':
+			if p+1 == len(pat) {
+				if s == len(ms.src) {
+					return s, nil
+				}
+				return -1, nil
+			}
+		case '%':
+			if p+1 >= len(pat) {
+				break
+			}
+			switch next := pat[p+1]; {
+			case next == 'b':
+				end, err := ms.matchBalance(s, p+2)
+				if err != nil || end == -1 {
+					return -1, err
+				}
+				s, p = end, p+4
+				continue
+			case next == 'f':
+				p += 2
+				if p >= len(pat) || pat[p] != '[' {
+					return -1, errors.New("missing '[' after '%f' in pattern")
+				}
+				ep, err := ms.classEnd(p)
+				if err != nil {
+					return -1, err
+				}
+				var previous, current byte
+				if s > 0 {
+					previous = ms.src[s-1]
+				}
+				if s < len(ms.src) {
+					current = ms.src[s]
+				}
+				if ms.matchBracketClass(previous, p, ep-1) || !ms.matchBracketClass(current, p, ep-1) {
+					return -1, nil
+				}
+				p = ep
+				continue
+			case next >= '0' && next <= '9':
+				end, err := ms.matchCapture(s, next)
+				if err != nil || end == -1 {
+					return -1, err
+				}
+				s, p = end, p+2
+				continue
+			}
+		}
+
+		ep, err := ms.classEnd(p)
+		if err != nil {
+			return -1, err
+		}
+		matches := ms.singleMatch(s, p, ep)
+		if ep < len(pat) {
+			switch pat[ep] {
+			case '?':
+				if matches {
+					end, err := ms.match(s+1, ep+1)
+					if err != nil || end != -1 {
+						return end, err
+					}
+				}
+				p = ep + 1
+				continue
+			case '*':
+				return ms.maxExpand(s, p, ep)
+			case '+':
+				if !matches {
+					return -1, nil
+				}
+				return ms.maxExpand(s+1, p, ep)
+			case '-':
+				return ms.minExpand(s, p, ep)
+			}
+		}
+		if !matches {
+			return -1, nil
+		}
+		s, p = s+1, ep
+	}
+}
+
+// maxExpand matches as many repetitions of a class as possible
+func (ms *matchState) maxExpand(s int, p int, ep int) (int, error) {
+	i := 0
+	for ms.singleMatch(s+i, p, ep) {
+		i++
+	}
+	for ; i >= 0; i-- {
+		end, err := ms.match(s+i, ep+1)
+		if err != nil || end != -1 {
+			return end, err
+		}
+	}
+	return -1, nil
+}
+
+// minExpand matches as few repetitions of a class as possible
+func (ms *matchState) minExpand(s int, p int, ep int) (int, error) {
+	for {
+		end, err := ms.match(s, ep+1)
+		if err != nil || end != -1 {
+			return end, err
+		}
+		if !ms.singleMatch(s, p, ep) {
+			return -1, nil
+		}
+		s++
+	}
+}
+
+func (ms *matchState) startCapture(s int, p int, length int) (int, error) {
+	if ms.level >= maxCaptures {
+		return -1, errors.New("too many captures")
+	}
+	ms.captures[ms.level] = capture{start: s, length: length}
+	ms.level++
+	end, err := ms.match(s, p)
+	if end == -1 {
+		ms.level--
+	}
+	return end, err
+}
+
+func (ms *matchState) endCapture(s int, p int) (int, error) {
+	l := -1
+	for i := ms.level - 1; i >= 0; i-- {
+		if ms.captures[i].length == captureOpen {
+			l = i
+			break
+		}
+	}
+	if l < 0 {
+		return -1, errors.New("invalid pattern capture")
+	}
+	ms.captures[l].length = s - ms.captures[l].start
+	end, err := ms.match(s, p)
+	if end == -1 {
+		ms.captures[l].length = captureOpen
+	}
+	return end, err
+}
+
+// matchBalance matches %bxy at p
+func (ms *matchState) matchBalance(s int, p int) (int, error) {
+	if p+1 >= len(ms.pattern) {
+		return -1, errors.New("missing arguments to '%b'")
+	}
+	if s >= len(ms.src) || ms.src[s] != ms.pattern[p] {
+		return -1, nil
+	}
+	open, close := ms.pattern[p], ms.pattern[p+1]
+	depth := 1
+	for i := s + 1; i < len(ms.src); i++ {
+		switch ms.src[i] {
+		case close:
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		case open:
+			depth++
+		}
+	}
+	return -1, nil
+}
+
+// matchCapture matches a back reference %1 to %9
+func (ms *matchState) matchCapture(s int, c byte) (int, error) {
+	l := int(c - '1')
+	if l < 0 || l >= ms.level || ms.captures[l].length == captureOpen {
+		return -1, errors.New("invalid capture index")
+	}
+	captured := ms.src[ms.captures[l].start : ms.captures[l].start+ms.captures[l].length]
+	if strings.HasPrefix(ms.src[s:], captured) {
+		return s + len(captured), nil
+	}
+	return -1, nil
+}
+
+// capture returns capture i of a match from s to e, the whole match when the pattern has
+// no captures
+func (ms *matchState) capture(i int, s int, e int) (Value, error) {
+	if i >= ms.level {
+		if i == 0 {
+			return ms.src[s:e], nil
+		}
+		return nil, errors.New("invalid capture index")
+	}
+	c := ms.captures[i]
+	switch c.length {
+	case captureOpen:
+		return nil, errors.New("unfinished capture")
+	case capturePosition:
+		return float64(c.start + 1), nil
+	}
+	return ms.src[c.start : c.start+c.length], nil
+}
+
+// allCaptures returns the captures of a match from s to e
+func (ms *matchState) allCaptures(s int, e int, wholeIfNone bool) ([]Value, error) {
+	n := ms.level
+	if n == 0 && wholeIfNone {
+		n = 1
+	}
+	values := make([]Value, n)
+	for i := range values {
+		v, err := ms.capture(i, s, e)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
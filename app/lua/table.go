@@ -0,0 +1,221 @@
+package lua
+
+import (
+	"math"
+)
+
+// Table is a Lua table. Keys 1 to n are kept in an array, the other ones in a hash part that
+// remembers the order keys were added in, which is the order pairs and next walk them.
+type Table struct {
+	array   []Value       // Values of the keys 1 to len(array), never ending with nil
+	index   map[Value]int // Position of each key of the hash part in entries
+	entries []tableEntry  // Keys of the hash part in insertion order, removed ones hold nil
+	removed int           // Number of entries holding nil
+	meta    *Table
+	// readOnly makes assignments from scripts errors, for the libraries
+	readOnly bool
+}
+
+type tableEntry struct {
+	key   Value
+	value Value
+}
+
+// NewTable returns an empty table
+func NewTable() *Table {
+	return &Table{}
+}
+
+// NewArray returns a table holding values at the keys 1 to len(values). Values must not be nil.
+func NewArray(values []Value) *Table {
+	return &Table{array: values}
+}
+
+// Metatable returns the metatable of the table, nil when it has none
+func (t *Table) Metatable() *Table {
+	return t.meta
+}
+
+// arrayIndex returns the array position of a key, -1 when the key is not an integer
+func arrayIndex(key Value) int {
+	n, ok := key.(float64)
+	if !ok || n < 1 || n > math.MaxInt32 || n != math.Trunc(n) {
+		return -1
+	}
+	return int(n) - 1
+}
+
+// normalizeKey makes -0 and 0 the same key
+func normalizeKey(key Value) Value {
+	if n, ok := key.(float64); ok && n == 0 {
+		return float64(0)
+	}
+	return key
+}
+
+// Get returns the value of a key, nil when it is missing
+func (t *Table) Get(key Value) Value {
+	if i := arrayIndex(key); i >= 0 && i < len(t.array) {
+		return t.array[i]
+	}
+	if t.index == nil {
+		return nil
+	}
+	if pos, ok := t.index[normalizeKey(key)]; ok {
+		return t.entries[pos].value
+	}
+	return nil
+}
+
+// SetReadOnly makes assigning a field of the table from a script an error. Go code can
+// still change it.
+func (t *Table) SetReadOnly() {
+	t.readOnly = true
+}
+
+// Set sets the value of a key, a nil value removes it. The key must not be nil or NaN.
+func (t *Table) Set(key Value, value Value) {
+	if i := arrayIndex(key); i >= 0 {
+		switch {
+		case i < len(t.array):
+			t.array[i] = value
+			if value == nil && i == len(t.array)-1 {
+				// The array part never ends with nil, so its length is a border
+				for len(t.array) > 0 && t.array[len(t.array)-1] == nil {
+					t.array = t.array[:len(t.array)-1]
+				}
+			}
+			return
+		case i == len(t.array) && value != nil:
+			t.array = append(t.array, value)
+			t.removeEntry(key)
+			// The following keys move from the hash part to the array
+			for {
+				next := float64(len(t.array) + 1)
+				v := t.hashGet(next)
+				if v == nil {
+					return
+				}
+				t.array = append(t.array, v)
+				t.removeEntry(next)
+			}
+		}
+	}
+	t.hashSet(normalizeKey(key), value)
+}
+
+// hashGet returns the value of a key of the hash part
+func (t *Table) hashGet(key Value) Value {
+	if pos, ok := t.index[key]; ok {
+		return t.entries[pos].value
+	}
+	return nil
+}
+
+// hashSet sets the value of a key of the hash part
+func (t *Table) hashSet(key Value, value Value) {
+	if pos, ok := t.index[key]; ok {
+		if t.entries[pos].value == nil && value != nil {
+			t.removed--
+		} else if t.entries[pos].value != nil && value == nil {
+			t.removed++
+		}
+		t.entries[pos].value = value
+		return
+	}
+	if value == nil {
+		return
+	}
+	if t.index == nil {
+		t.index = make(map[Value]int)
+	}
+	if t.removed > 16 && t.removed > len(t.entries)/2 {
+		t.compact()
+	}
+	t.index[key] = len(t.entries)
+	t.entries = append(t.entries, tableEntry{key: key, value: value})
+}
+
+// removeEntry removes a key from the hash part, once it moved to the array
+func (t *Table) removeEntry(key Value) {
+	if pos, ok := t.index[key]; ok {
+		if t.entries[pos].value != nil {
+			t.removed++
+		}
+		t.entries[pos].value = nil
+	}
+}
+
+// compact drops the removed keys from the hash part
+func (t *Table) compact() {
+	entries := make([]tableEntry, 0, len(t.entries)-t.removed)
+	index := make(map[Value]int, len(t.entries)-t.removed)
+	for _, entry := range t.entries {
+		if entry.value != nil {
+			index[entry.key] = len(entries)
+			entries = append(entries, entry)
+		}
+	}
+	t.entries, t.index, t.removed = entries, index, 0
+}
+
+// Len returns the length of the table as # does: a border, an n where t[n] is not nil and
+// t[n+1] is
+func (t *Table) Len() int {
+	n := len(t.array)
+	if t.index == nil {
+		return n
+	}
+	for t.hashGet(float64(n+1)) != nil {
+		n++
+	}
+	return n
+}
+
+// Append sets the value of the key following the length of the table
+func (t *Table) Append(value Value) {
+	t.Set(float64(t.Len()+1), value)
+}
+
+// Next returns the key and value following key in a traversal of the table, starting from
+// nil. It returns a nil key once every key was returned, and false for an unknown key.
+func (t *Table) Next(key Value) (Value, Value, bool) {
+	start := 0 // Position in the array part, then in the entries after it
+	if key != nil {
+		if i := arrayIndex(key); i >= 0 && i < len(t.array) {
+			start = i + 1
+		} else if pos, ok := t.index[normalizeKey(key)]; ok {
+			start = len(t.array) + pos + 1
+		} else {
+			return nil, nil, false
+		}
+	}
+	for i := start; i < len(t.array); i++ {
+		if t.array[i] != nil {
+			return float64(i + 1), t.array[i], true
+		}
+	}
+	if start < len(t.array) {
+		start = len(t.array)
+	}
+	for pos := start - len(t.array); pos < len(t.entries); pos++ {
+		if entry := t.entries[pos]; entry.value != nil {
+			return entry.key, entry.value, true
+		}
+	}
+	return nil, nil, true
+}
+
+// ForEach calls f for every key and value of the table in traversal order
+func (t *Table) ForEach(f func(key Value, value Value)) {
+	for i, v := range t.array {
+		if v != nil {
+			f(float64(i+1), v)
+		}
+	}
+	for _, entry := range t.entries {
+		if entry.value != nil {
+			f(entry.key, entry.value)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package lua
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// keys returns the keys of a table in the order Next walks them
+func keys(t *testing.T, table *Table) []Value {
+	t.Helper()
+	var result []Value
+	var key Value
+	for {
+		next, _, ok := table.Next(key)
+		if !ok {
+			t.Fatalf("Expected %v to be a key of the table", key)
+		}
+		if next == nil {
+			return result
+		}
+		result = append(result, next)
+		key = next
+	}
+}
+
+func TestTableArrayAndHash(t *testing.T) {
+	table := NewTable()
+	table.Set(float64(1), "a")
+	table.Set(float64(2), "b")
+	table.Set(float64(4), "d")
+	table.Set("y", true)
+	table.Set("x", true)
+	if n := table.Len(); n != 2 {
+		t.Errorf("Expected a length of 2, got %d", n)
+	}
+
+	// Filling the hole moves the following key to the array
+	table.Set(float64(3), "c")
+	if n := table.Len(); n != 4 {
+		t.Errorf("Expected a length of 4, got %d", n)
+	}
+	expected := []Value{float64(1), float64(2), float64(3), float64(4), "y", "x"}
+	if got := keys(t, table); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the keys %v, got %v", expected, got)
+	}
+
+	// Removing the last element shortens the array, so the length stays a border
+	table.Set(float64(4), nil)
+	table.Set(float64(3), nil)
+	if n := table.Len(); n != 2 || table.Get(float64(3)) != nil {
+		t.Errorf("Expected a length of 2, got %d", n)
+	}
+	table.Append("z")
+	if table.Get(float64(3)) != "z" {
+		t.Errorf("Expected Append to set the key 3, got %v", table.Get(float64(3)))
+	}
+}
+
+func TestTableKeys(t *testing.T) {
+	table := NewTable()
+	table.Set(math.Copysign(0, -1), "zero")
+	if v := table.Get(float64(0)); v != "zero" {
+		t.Errorf("Expected -0 and 0 to be the same key, got %v", v)
+	}
+	table.Set(1.5, "fraction")
+	if v := table.Get(1.5); v != "fraction" || table.Len() != 0 {
+		t.Errorf("Expected 1.5 to be a hash key, got %v and a length of %d", v, table.Len())
+	}
+	if _, _, ok := table.Next("missing"); ok {
+		t.Error("Expected Next to refuse a key the table doesn't have")
+	}
+}
+
+func TestTableRemovedKeysKeepTheOrder(t *testing.T) {
+	table := NewTable()
+	for i := 0; i < 40; i++ {
+		table.Set("k"+strconv.Itoa(i), float64(i))
+	}
+	// Removing most keys compacts the hash part once more keys are added
+	for i := 0; i < 40; i++ {
+		if i%4 != 0 {
+			table.Set("k"+strconv.Itoa(i), nil)
+		}
+	}
+	table.Set("last", true)
+
+	expected := []Value{"k0", "k4", "k8", "k12", "k16", "k20", "k24", "k28", "k32", "k36", "last"}
+	if got := keys(t, table); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the keys %v, got %v", expected, got)
+	}
+	if v := table.Get("k8"); v != float64(8) {
+		t.Errorf("Expected k8 to keep its value, got %v", v)
+	}
+}
+
+func TestNewArray(t *testing.T) {
+	table := NewArray([]Value{"a", "b"})
+	if table.Len() != 2 || table.Get(float64(2)) != "b" {
+		t.Errorf("Expected the array a, b, got a length of %d", table.Len())
+	}
+	var visited []Value
+	table.ForEach(func(key Value, value Value) {
+		visited = append(visited, key, value)
+	})
+	if expected := []Value{float64(1), "a", float64(2), "b"}; !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Expected %v, got %v", expected, visited)
+	}
+}
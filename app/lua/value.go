@@ -0,0 +1,179 @@
+// Package lua is an interpreter for the subset of Lua 5.1 that scripts run by EVAL and
+// FCALL use: the whole language but coroutines, with the base, string, table, math and cjson
+// libraries. Scripts are parsed to a tree whose variables are resolved to local slots,
+// upvalues and globals, then evaluated by walking it.
+package lua
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value is a Lua value: nil, bool, float64, string, *Table, *Function or *Userdata
+type Value = any
+
+// Userdata is an opaque value scripts can only compare, like cjson.null
+type Userdata struct {
+	Name string
+}
+
+// Function is a Lua function, either a closure of a parsed function or a Go function
+type Function struct {
+	Name     string // Name of a Go function, used in error messages
+	proto    *funcProto
+	upvalues []*Value
+	native   func(s *State, args []Value) ([]Value, error)
+}
+
+// NewFunction returns a Go function callable from Lua. It receives the arguments of the call
+// and returns its results, or an error raised in the script.
+func NewFunction(name string, fn func(s *State, args []Value) ([]Value, error)) *Function {
+	return &Function{Name: name, native: fn}
+}
+
+// Error is an error raised in a script, by the script itself or by a failing operation. Its
+// value is usually a string prefixed with the position of the error, but error() may raise
+// any value.
+type Error struct {
+	Value Value
+}
+
+func (e *Error) Error() string {
+	if s, ok := e.Value.(string); ok {
+		return s
+	}
+	if n, ok := e.Value.(float64); ok {
+		return FormatNumber(n)
+	}
+	return fmt.Sprintf("(error object is a %s value)", TypeName(e.Value))
+}
+
+// InterruptError stops a script when the Interrupt function of its State returns an error.
+// Unlike an *Error, pcall and xpcall don't catch it.
+type InterruptError struct {
+	Err error
+}
+
+func (e *InterruptError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InterruptError) Unwrap() error {
+	return e.Err
+}
+
+// TypeName returns the name of the type of a value, as type() returns it
+func TypeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *Table:
+		return "table"
+	case *Function:
+		return "function"
+	case *Userdata:
+		return "userdata"
+	}
+	return "userdata"
+}
+
+// Truthy reports whether a value counts as true in a condition: anything but nil and false
+func Truthy(v Value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+// FormatNumber formats a number like Lua 5.1 does, with up to 14 significant digits
+func FormatNumber(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "inf"
+	case math.IsInf(n, -1):
+		return "-inf"
+	case math.IsNaN(n):
+		return "nan"
+	case n == math.Trunc(n) && math.Abs(n) < 1e15:
+		return strconv.FormatInt(int64(n), 10)
+	}
+	return strconv.FormatFloat(n, 'g', 14, 64)
+}
+
+// ToNumber converts a number, or a string holding one, to a number
+func ToNumber(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		return ParseNumber(v)
+	}
+	return 0, false
+}
+
+// ParseNumber parses a decimal or hexadecimal number, surrounded by spaces or not
+func ParseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	body, negative := s, false
+	if body[0] == '-' || body[0] == '+' {
+		negative = body[0] == '-'
+		body = body[1:]
+	}
+	if len(body) > 2 && body[0] == '0' && (body[1] == 'x' || body[1] == 'X') {
+		n, err := strconv.ParseUint(body[2:], 16, 64)
+		if err != nil {
+			return 0, false
+		}
+		if negative {
+			return -float64(n), true
+		}
+		return float64(n), true
+	}
+	// Only plain decimal notation, ParseFloat also takes forms Lua doesn't
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if !(c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-') {
+			if lower := strings.ToLower(body); lower != "inf" && lower != "nan" {
+				return 0, false
+			}
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); !ok || numErr.Err != strconv.ErrRange {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// ToStringValue converts a string, or a number, to a string as concatenation does
+func ToStringValue(v Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return FormatNumber(v), true
+	}
+	return "", false
+}
+
+// rawEqual reports whether two values are equal without metamethods
+func rawEqual(a, b Value) bool {
+	return a == b
+}
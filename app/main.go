@@ -1,190 +1,310 @@
 package main
 
 import (
-	"crypto/rand"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
-
+	"syscall"
+
+	"github.com/codecrafters-io/redis-starter-go/app/benchmark"
+	"github.com/codecrafters-io/redis-starter-go/app/cli"
+	"github.com/codecrafters-io/redis-starter-go/app/commands"
+	"github.com/codecrafters-io/redis-starter-go/app/kv"
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/migrate"
 	"github.com/codecrafters-io/redis-starter-go/app/network"
-	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
-	"github.com/codecrafters-io/redis-starter-go/app/shared"
 	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
-const DEFAULT_PORT = "6379"
-
-var port = ""
-var replicaOf = ""
+// serverLog logs the messages of the server itself, without a subsystem prefix
+var serverLog = logger.New("")
 
-// generateReplID generates a random 40-character alphanumeric string for replication ID
-func generateReplID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
-	const length = 40
+// savePointsFlag collects --save options. Each use adds "<seconds> <changes>" pairs,
+// replacing the default save points; --save "" disables automatic saves.
+type savePointsFlag struct {
+	set bool
+}
 
-	b := make([]byte, length)
-	rand.Read(b)
+func (f *savePointsFlag) String() string {
+	return storage.FormatSavePoints(server.StoreState.SavePoints)
+}
 
-	for i := range b {
-		b[i] = charset[b[i]%byte(len(charset))]
+func (f *savePointsFlag) Set(value string) error {
+	points, err := storage.ParseSavePoints(value)
+	if err != nil {
+		return err
 	}
-
-	return string(b)
+	if !f.set {
+		server.StoreState.SavePoints = nil
+		f.set = true
+	}
+	server.StoreState.SavePoints = append(server.StoreState.SavePoints, points...)
+	return nil
 }
 
-// Parse command line arguments
-func parseArgs() string {
-	flag.StringVar(&port, "port", DEFAULT_PORT, "Port to listen on")
-	flag.StringVar(&replicaOf, "replicaof", "", "Replica of")
-	flag.StringVar(&server.StoreState.ConfigDir, "dir", server.StoreState.ConfigDir, "Directory where Redis stores its data")
-	flag.StringVar(&server.StoreState.ConfigDbfilename, "dbfilename", server.StoreState.ConfigDbfilename, "Database filename")
-	flag.Parse()
+// renameCommandFlag applies --rename-command options, "<command> <new-name>" renames a command
+// and "<command>" alone disables it
+type renameCommandFlag struct{}
 
-	if replicaOf != "" {
-		server.StoreState.Role = "slave"
-		server.StoreState.ReplicaOf = replicaOf
-	} else {
-		server.StoreState.Role = "master"
-	}
+func (renameCommandFlag) String() string {
+	return ""
+}
 
-	if server.StoreState.Role == "master" {
-		server.StoreState.MasterReplID = generateReplID()
+func (renameCommandFlag) Set(value string) error {
+	fields := strings.Fields(value)
+	switch len(fields) {
+	case 1:
+		return network.RenameCommand(fields[0], "")
+	case 2:
+		return network.RenameCommand(fields[0], fields[1])
 	}
-
-	return port
+	return fmt.Errorf("expected <command> [new-name]")
 }
 
-func main() {
-	port := parseArgs()
+// percentilesFlag applies the --latency-tracking-info-percentiles option, like "50 99 99.9"
+type percentilesFlag struct{}
 
-	fmt.Printf("Starting Redis server on port %s, role: %s\n", port, server.StoreState.Role)
+func (percentilesFlag) String() string {
+	return ""
+}
 
-	// Load RDB file if we're a master
-	if server.StoreState.Role == "master" {
-		if err := storage.LoadRDBFile(server.StoreState.ConfigDir, server.StoreState.ConfigDbfilename); err != nil {
-			fmt.Printf("Warning: Failed to load RDB file: %v\n", err)
+func (percentilesFlag) Set(value string) error {
+	fields := strings.Fields(value)
+	percentiles := make([]float64, len(fields))
+	for i, field := range fields {
+		percentile, err := strconv.ParseFloat(field, 64)
+		if err != nil || percentile < 0 || percentile > 100 {
+			return fmt.Errorf("expected percentiles between 0 and 100")
 		}
+		percentiles[i] = percentile
 	}
+	server.StoreState.LatencyTrackingInfoPercentiles = percentiles
+	return nil
+}
 
-	network.HandleReplicaMode(port, server.StoreState.Role, server.StoreState.ReplicaOf, network.ExecuteCommand)
+// outputBufferLimitFlag applies --client-output-buffer-limit options, like "pubsub 32mb 8mb 60".
+// Each use changes the limits of the classes it names.
+type outputBufferLimitFlag struct{}
+
+func (outputBufferLimitFlag) String() string {
+	return ""
+}
 
-	l, err := net.Listen("tcp", "0.0.0.0:"+port)
+func (outputBufferLimitFlag) Set(value string) error {
+	limits, err := commands.ParseOutputBufferLimits(value)
 	if err != nil {
-		fmt.Printf("Failed to bind to port %s\n", port)
-		os.Exit(1)
+		return err
 	}
+	server.StoreState.ClientOutputBufferLimits = limits
+	return nil
+}
 
-	defer l.Close()
+// benchmarkTestsFlag applies the --benchmark-tests option, a comma separated list of tests
+type benchmarkTestsFlag struct{}
 
-	for {
-		conn, err := l.Accept()
-		if err != nil {
-			fmt.Println("Error accepting connection: ", err.Error())
-			continue
-		}
-		go handleConnection(conn)
-	}
+func (benchmarkTestsFlag) String() string {
+	return strings.Join(benchmarkOpts.Tests, ",")
 }
 
-// registerConnection registers a connection and returns its ID
-func registerConnection(conn net.Conn) string {
-	connID := conn.RemoteAddr().String()
-	network.ConnectionsSet(connID, conn)
-	return connID
+func (benchmarkTestsFlag) Set(value string) error {
+	benchmarkOpts.Tests = nil
+	for _, test := range strings.Split(value, ",") {
+		test = strings.ToLower(strings.TrimSpace(test))
+		if _, ok := benchmark.Tests[test]; !ok {
+			return fmt.Errorf("unknown test %q", test)
+		}
+		benchmarkOpts.Tests = append(benchmarkOpts.Tests, test)
+	}
+	return nil
 }
 
-// readAndValidateCommand reads a command from the connection and validates it
-func readAndValidateCommand(conn net.Conn) (string, []protocol.Value, error) {
-	r := protocol.NewResp(conn)
-	value, err := r.Read()
-	if err != nil {
-		return "", nil, err
-	}
+// cliAddr is set by --cli to the address of the server to send commands to, instead of starting one
+var cliAddr string
 
-	if value.Typ != "array" {
-		return "", nil, fmt.Errorf("invalid request, expected array")
-	}
+// importOpts holds the --import-* options, --import-from copies the keys of a Redis server
+// to a running server instead of starting one
+var importOpts = migrate.DefaultOptions()
 
-	command := strings.ToUpper(value.Array[0].Bulk)
-	args := value.Array[1:]
-	return command, args, nil
-}
+// exportPath is set by --export-json to the file the RDB file is exported to, instead of starting a server
+var exportPath string
 
-// executeTransactionCommand executes a command within a transaction context
-func executeTransactionCommand(command string, connID string, args []protocol.Value, writer *protocol.Writer) {
-	if IsTransactionCommand(command) {
-		result := network.ExecuteCommand(command, connID, args)
+// runBenchmark is set by --benchmark, which benchmarks a running server instead of starting one
+var runBenchmark bool
 
-		// Propagate transaction commands to replicas
-		if network.IsWriteCommand(command) {
-			network.PropagateCommand(command, args)
-		}
+// benchmarkOpts holds the --benchmark-* options
+var benchmarkOpts = benchmark.DefaultOptions()
 
-		// Only write response if it's not a NO_RESPONSE type
-		if result.Typ != network.NO_RESPONSE {
-			writer.Write(result)
+// Parse command line arguments
+func parseArgs() {
+	flag.StringVar(&server.StoreState.Port, "port", server.StoreState.Port, "Port to listen on")
+	flag.StringVar(&server.StoreState.ReplicaOf, "replicaof", server.StoreState.ReplicaOf, "Replica of")
+	flag.StringVar(&server.StoreState.ConfigDir, "dir", server.StoreState.ConfigDir, "Directory where Redis stores its data")
+	flag.StringVar(&server.StoreState.ConfigDbfilename, "dbfilename", server.StoreState.ConfigDbfilename, "Database filename")
+	flag.IntVar(&server.StoreState.MinReplicasToWrite, "min-replicas-to-write", server.StoreState.MinReplicasToWrite, "Minimum number of good replicas required to accept writes")
+	flag.IntVar(&server.StoreState.MinReplicasMaxLag, "min-replicas-max-lag", server.StoreState.MinReplicasMaxLag, "Maximum replica lag in seconds for it to count as good")
+	flag.BoolVar(&server.StoreState.ReplicaServeStaleData, "replica-serve-stale-data", server.StoreState.ReplicaServeStaleData, "Serve possibly stale data while the link to the master is down")
+	flag.BoolVar(&server.StoreState.ReplDisklessSync, "repl-diskless-sync", server.StoreState.ReplDisklessSync, "Stream full resync snapshots to replicas from memory")
+	flag.IntVar(&server.StoreState.ReplDisklessSyncDelay, "repl-diskless-sync-delay", server.StoreState.ReplDisklessSyncDelay, "Seconds to wait for more replicas before a diskless transfer")
+	flag.BoolVar(&server.StoreState.RDBCompression, "rdbcompression", server.StoreState.RDBCompression, "Compress long strings with LZF when writing RDB files")
+	flag.BoolVar(&server.StoreState.RDBChecksum, "rdbchecksum", server.StoreState.RDBChecksum, "Write and verify CRC64 checksums of RDB files")
+	flag.BoolVar(&server.StoreState.AppendOnly, "appendonly", server.StoreState.AppendOnly, "Log every write to the append only file")
+	flag.StringVar(&server.StoreState.AppendFilename, "appendfilename", server.StoreState.AppendFilename, "Append only file name")
+	flag.StringVar(&server.StoreState.AppendDirname, "appenddirname", server.StoreState.AppendDirname, "Directory in dir holding the append only files")
+	flag.StringVar(&server.StoreState.AppendFsync, "appendfsync", server.StoreState.AppendFsync, "When to fsync the append only file: always, everysec or no")
+	flag.IntVar(&server.StoreState.AutoAOFRewritePercentage, "auto-aof-rewrite-percentage", server.StoreState.AutoAOFRewritePercentage, "Append only file growth percentage triggering a rewrite, 0 disables it")
+	flag.Int64Var(&server.StoreState.AutoAOFRewriteMinSize, "auto-aof-rewrite-min-size", server.StoreState.AutoAOFRewriteMinSize, "Minimum append only file size in bytes for an automatic rewrite")
+	flag.BoolVar(&server.StoreState.AOFUseRDBPreamble, "aof-use-rdb-preamble", server.StoreState.AOFUseRDBPreamble, "Write the base append only file as an RDB snapshot of the dataset")
+	flag.BoolVar(&server.StoreState.AOFTimestampEnabled, "aof-timestamp-enabled", server.StoreState.AOFTimestampEnabled, "Annotate the append only file with the time writes were made")
+	flag.IntVar(&server.StoreState.ShutdownTimeout, "shutdown-timeout", server.StoreState.ShutdownTimeout, "Seconds SHUTDOWN waits for replicas to catch up")
+	flag.IntVar(&server.StoreState.BusyReplyThreshold, "busy-reply-threshold", server.StoreState.BusyReplyThreshold, "Milliseconds a script runs before other clients get BUSY")
+	flag.Int64Var(&server.StoreState.MaxMemory, "maxmemory", server.StoreState.MaxMemory, "Memory limit in bytes, 0 means no limit")
+	flag.IntVar(&server.StoreState.SlowlogLogSlowerThan, "slowlog-log-slower-than", server.StoreState.SlowlogLogSlowerThan, "Microseconds a command must run to be logged in the slow log, negative disables it")
+	flag.IntVar(&server.StoreState.SlowlogMaxLen, "slowlog-max-len", server.StoreState.SlowlogMaxLen, "Maximum number of entries kept in the slow log")
+	flag.IntVar(&server.StoreState.RangeReplyMaxElements, "range-reply-max-elements", server.StoreState.RangeReplyMaxElements, "Most elements LRANGE and XRANGE may reply, 0 for no limit")
+	flag.IntVar(&server.StoreState.LatencyMonitorThreshold, "latency-monitor-threshold", server.StoreState.LatencyMonitorThreshold, "Milliseconds an event must take to be recorded as a latency spike, 0 disables it")
+	flag.BoolVar(&server.StoreState.LatencyTracking, "latency-tracking", server.StoreState.LatencyTracking, "Record the latency of every command in a histogram")
+	flag.Var(percentilesFlag{}, "latency-tracking-info-percentiles", "Space separated percentiles of the command latencies reported by INFO latencystats")
+	flag.StringVar(&server.StoreState.Bind, "bind", server.StoreState.Bind, "Space separated addresses to listen on, \"-\" prefixes optional ones")
+	flag.BoolVar(&server.StoreState.ProtectedMode, "protected-mode", server.StoreState.ProtectedMode, "Only serve loopback clients while no bind address and no password are set")
+	flag.IntVar(&server.StoreState.Timeout, "timeout", server.StoreState.Timeout, "Seconds a client may stay idle before it is disconnected, 0 disables it")
+	flag.IntVar(&server.StoreState.TCPKeepalive, "tcp-keepalive", server.StoreState.TCPKeepalive, "Seconds of silence before TCP keepalive probes are sent to clients, 0 disables them")
+	flag.IntVar(&server.StoreState.MetricsPort, "metrics-port", server.StoreState.MetricsPort, "Port serving Prometheus metrics over HTTP at /metrics, 0 disables it")
+	flag.IntVar(&server.StoreState.DebugPort, "debug-port", server.StoreState.DebugPort, "Port serving Go runtime profiles over HTTP at /debug/pprof/, 0 disables it")
+	flag.IntVar(&server.StoreState.HTTPPort, "http-port", server.StoreState.HTTPPort, "Port of the HTTP gateway serving keys, commands and subscriptions as JSON, 0 disables it")
+	flag.IntVar(&server.StoreState.MemcachedPort, "memcached-port", server.StoreState.MemcachedPort, "Port serving the get, set, delete and incr commands of the memcached text protocol, 0 disables it")
+	flag.StringVar(&server.StoreState.LogLevel, "loglevel", server.StoreState.LogLevel, "Minimum level of the logged messages: debug, verbose, notice or warning")
+	flag.StringVar(&server.StoreState.LogFile, "logfile", server.StoreState.LogFile, "File the log is appended to, empty logs to stdout")
+	flag.StringVar(&server.StoreState.RequirePass, "requirepass", server.StoreState.RequirePass, "Password of the default user, empty lets clients connect without AUTH")
+	flag.StringVar(&server.StoreState.ACLFile, "aclfile", server.StoreState.ACLFile, "File defining the ACL users")
+	flag.Int64Var(&server.StoreState.ProtoMaxBulkLen, "proto-max-bulk-len", server.StoreState.ProtoMaxBulkLen, "Longest bulk string in bytes accepted from clients")
+	flag.IntVar(&server.StoreState.ProtoMaxMultibulkLen, "proto-max-multibulk-len", server.StoreState.ProtoMaxMultibulkLen, "Most elements in an array accepted from clients")
+	flag.IntVar(&server.StoreState.ProtoMaxNestingDepth, "proto-max-nesting-depth", server.StoreState.ProtoMaxNestingDepth, "Deepest nesting of arrays accepted from clients")
+	flag.Int64Var(&server.StoreState.ClientQueryBufferLimit, "client-query-buffer-limit", server.StoreState.ClientQueryBufferLimit, "Most bytes of bulk strings in a command accepted from clients")
+	flag.Var(outputBufferLimitFlag{}, "client-output-buffer-limit", "Output buffer limit of a client class, given as \"<normal|replica|pubsub> <hard> <soft> <soft-seconds>\" (repeatable)")
+	flag.IntVar(&server.StoreState.ClientRateLimitCommands, "client-rate-limit-commands", server.StoreState.ClientRateLimitCommands, "Most commands a client may send per second, 0 for no limit")
+	flag.Int64Var(&server.StoreState.ClientRateLimitBytes, "client-rate-limit-bytes", server.StoreState.ClientRateLimitBytes, "Most bytes of arguments a client may send per second, 0 for no limit")
+	flag.IntVar(&server.StoreState.RateLimitCommands, "rate-limit-commands", server.StoreState.RateLimitCommands, "Most commands every client together may send per second, 0 for no limit")
+	flag.Int64Var(&server.StoreState.RateLimitBytes, "rate-limit-bytes", server.StoreState.RateLimitBytes, "Most bytes of arguments every client together may send per second, 0 for no limit")
+	flag.StringVar(&server.StoreState.AuditLogFile, "audit-log-file", server.StoreState.AuditLogFile, "File the write and administrative commands of clients are appended to, empty disables it")
+	flag.Int64Var(&server.StoreState.AuditLogMaxSize, "audit-log-max-size", server.StoreState.AuditLogMaxSize, "Size in bytes the audit log is rotated at, 0 never rotates it")
+	flag.IntVar(&server.StoreState.AuditLogMaxFiles, "audit-log-max-files", server.StoreState.AuditLogMaxFiles, "Rotated audit logs kept besides the current one")
+	flag.IntVar(&server.StoreState.ListMaxListpackSize, "list-max-listpack-size", server.StoreState.ListMaxListpackSize, "Most elements of a packed list when positive, -1 to -5 for 4kb to 64kb")
+	flag.IntVar(&server.StoreState.ZsetMaxListpackEntries, "zset-max-listpack-entries", server.StoreState.ZsetMaxListpackEntries, "Most members of a packed sorted set")
+	flag.IntVar(&server.StoreState.ZsetMaxListpackValue, "zset-max-listpack-value", server.StoreState.ZsetMaxListpackValue, "Longest member of a packed sorted set, in bytes")
+	flag.BoolVar(&server.StoreState.SingleWriter, "single-writer", server.StoreState.SingleWriter, "Run every command on a single goroutine, connections only parse commands and write replies")
+	flag.BoolVar(&server.StoreState.ClusterEnabled, "cluster-enabled", server.StoreState.ClusterEnabled, "Run in cluster mode, keys spread over 16384 hash slots")
+	flag.StringVar(&server.StoreState.ClusterAnnounceIP, "cluster-announce-ip", server.StoreState.ClusterAnnounceIP, "Address announced for this node in cluster replies")
+	flag.Var(renameCommandFlag{}, "rename-command", "Rename a command, given as \"<command> <new-name>\", or disable it when no new name is given (repeatable)")
+	flag.StringVar(&cliAddr, "cli", "", "Connect to the server at host:port and send it the commands typed, or the command following the options, instead of starting a server")
+	flag.StringVar(&importOpts.From, "import-from", "", "Copy the keys of the Redis server at host:port to the server at --import-to and exit instead of starting a server")
+	flag.StringVar(&importOpts.FromPassword, "import-from-password", "", "Password of the server keys are imported from")
+	flag.IntVar(&importOpts.DB, "import-db", importOpts.DB, "Database of the server keys are imported from")
+	flag.StringVar(&importOpts.To, "import-to", "", "Address of the server keys are imported to, 127.0.0.1 on --port when empty")
+	flag.StringVar(&importOpts.ToPassword, "import-to-password", "", "Password of the server keys are imported to")
+	flag.StringVar(&importOpts.Match, "import-match", importOpts.Match, "Glob pattern of the imported keys")
+	flag.BoolVar(&importOpts.Replace, "import-replace", importOpts.Replace, "Overwrite the imported keys that exist already instead of skipping them")
+	flag.IntVar(&importOpts.Batch, "import-batch", importOpts.Batch, "Number of keys copied in one round trip")
+	flag.StringVar(&exportPath, "export-json", "", "Export the keys of the RDB file in --dir to this JSON file, \"-\" for stdout, and exit instead of starting a server")
+	flag.BoolVar(&runBenchmark, "benchmark", false, "Benchmark the server at --benchmark-addr and exit instead of starting a server")
+	flag.StringVar(&benchmarkOpts.Addr, "benchmark-addr", "", "Address of the benchmarked server, 127.0.0.1 on --port when empty")
+	flag.StringVar(&benchmarkOpts.Password, "benchmark-password", "", "Password the benchmark clients authenticate with")
+	flag.IntVar(&benchmarkOpts.Clients, "benchmark-clients", benchmarkOpts.Clients, "Number of benchmark clients sending commands at once")
+	flag.IntVar(&benchmarkOpts.Requests, "benchmark-requests", benchmarkOpts.Requests, "Number of commands sent by each benchmark test")
+	flag.IntVar(&benchmarkOpts.Pipeline, "benchmark-pipeline", benchmarkOpts.Pipeline, "Number of commands a benchmark client sends before reading their replies")
+	flag.IntVar(&benchmarkOpts.DataSize, "benchmark-datasize", benchmarkOpts.DataSize, "Size in bytes of the values written by the benchmark")
+	flag.IntVar(&benchmarkOpts.KeySpace, "benchmark-keyspace", benchmarkOpts.KeySpace, "Number of distinct keys the benchmark picks from at random")
+	flag.Var(benchmarkTestsFlag{}, "benchmark-tests", "Comma separated benchmark tests among ping, set, get, incr, lpush and xadd")
+	flag.Var(&savePointsFlag{}, "save", "Save the dataset after <seconds> if at least <changes> writes happened (repeatable, \"\" disables)")
+
+	// A config file may be given as the first argument, options after it override its directives
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if err := commands.LoadConfigFile(args[0]); err != nil {
+			serverLog.Warningf("Failed to load the config file: %v", err)
+			os.Exit(1)
 		}
-	} else {
-		// Queue the command instead of executing it
-		transaction, _ := network.TransactionsGet(connID)
-		transaction.Commands = append(transaction.Commands, shared.QueuedCommand{
-			Command: command,
-			Args:    args,
-		})
-		network.TransactionsSet(connID, transaction)
-
-		// Return QUEUED response
-		result := protocol.Value{Typ: "string", Str: "QUEUED"}
-		writer.Write(result)
+		args = args[1:]
 	}
+	flag.CommandLine.Parse(args)
 }
 
-// executeNormalCommand executes a command outside of transaction context
-func executeNormalCommand(command string, connID string, args []protocol.Value, writer *protocol.Writer) {
-	result := network.ExecuteCommand(command, connID, args)
+func main() {
+	parseArgs()
 
-	// Propagate write commands to replicas
-	if network.IsWriteCommand(command) {
-		network.PropagateCommand(command, args)
+	if cliAddr != "" {
+		if err := cli.Run(cliAddr, flag.Args(), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Only write response if it's not a NO_RESPONSE type
-	if result.Typ != network.NO_RESPONSE {
-		writer.Write(result)
+	if importOpts.From != "" {
+		if importOpts.To == "" {
+			importOpts.To = net.JoinHostPort("127.0.0.1", server.StoreState.Port)
+		}
+		result, err := migrate.Import(importOpts, os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d keys imported, %d skipped, %d failed\n", result.Imported, result.Skipped, result.Failed)
+		return
 	}
-}
-
-func handleConnection(conn net.Conn) {
-	defer conn.Close()
 
-	// Register the connection (concurrency-safe)
-	connID := registerConnection(conn)
-	defer network.ConnectionsDelete(connID)
+	if exportPath != "" {
+		if err := exportRDBFile(exportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	for {
-		command, args, err := readAndValidateCommand(conn)
-		if err != nil {
-			if err == io.EOF {
-				fmt.Println("Client disconnected: ", conn.RemoteAddr().String())
-			} else {
-				fmt.Println("Error is: ", err)
-			}
-			return
+	if runBenchmark {
+		if benchmarkOpts.Addr == "" {
+			benchmarkOpts.Addr = net.JoinHostPort("127.0.0.1", server.StoreState.Port)
 		}
+		if err := benchmark.Run(benchmarkOpts, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Benchmark failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-		// Create a writer for the connection
-		writer := protocol.NewWriter(conn)
+	// SIGINT and SIGTERM stop the server like a canceled context
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		// Check if this connection is in a transaction (concurrency-safe)
-		if _, exists := network.TransactionsGet(connID); exists {
-			executeTransactionCommand(command, connID, args, writer)
-		} else {
-			// No active transaction, execute command normally
-			executeNormalCommand(command, connID, args, writer)
-		}
+	if err := kv.NewServer().Run(ctx); err != nil {
+		serverLog.Warningf("Fatal error: %v", err)
+		os.Exit(1)
+	}
+}
+
+// exportRDBFile loads the configured RDB file and writes its keys as JSON to path, or to stdout
+// when path is "-", so a dataset can be read without starting a server
+func exportRDBFile(path string) error {
+	dir, filename := server.StoreState.ConfigDir, server.StoreState.ConfigDbfilename
+	if _, err := os.Stat(filepath.Join(dir, filename)); err != nil {
+		return err
+	}
+	if err := storage.LoadRDBFile(dir, filename); err != nil {
+		return err
+	}
+	if path == "-" {
+		return storage.WriteJSON(os.Stdout, server.Memory.Clone())
+	}
+	// Unlike DEBUG EXPORT, a relative path is in the working directory
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
 	}
+	return storage.ExportJSONFile(path)
 }
@@ -0,0 +1,253 @@
+// Package migrate copies the keys of a running Redis server to this one, with their values
+// and expirations, to ease migrations: keys are listed with SCAN on the source, serialized with
+// DUMP and created on the target with RESTORE, which reads the payloads of Redis up to 7.4.
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// Options configures an import
+type Options struct {
+	From         string // Address of the source server, like 10.0.0.1:6379
+	FromPassword string // Password of the source server, empty for none
+	DB           int    // Database of the source server the keys are read from
+	To           string // Address of the target server, like 127.0.0.1:6379
+	ToPassword   string // Password of the target server, empty for none
+	Match        string // Glob pattern of the imported keys
+	Replace      bool   // Whether keys existing on the target are overwritten rather than skipped
+	Batch        int    // Number of keys read with a SCAN and copied in one round trip
+}
+
+// DefaultOptions returns the options of an import when none is changed
+func DefaultOptions() Options {
+	return Options{Match: "*", Batch: 500}
+}
+
+// Result counts the keys of an import
+type Result struct {
+	Imported int // Keys created on the target
+	Skipped  int // Keys that existed on the target without Replace, or expired while copied
+	Failed   int // Keys the target refused, like values of an unsupported type
+}
+
+// Import copies the keys of the source server to the target, writing its progress and the
+// keys that failed to log. Keys are copied while the source keeps serving clients, so a key
+// written during the import is copied as it was when its batch was read.
+//
+// Examples:
+//
+//	opts := migrate.DefaultOptions()
+//	opts.From, opts.To = "10.0.0.1:6379", "127.0.0.1:6379"
+//	result, err := migrate.Import(opts, os.Stderr)
+func Import(opts Options, log io.Writer) (Result, error) {
+	var result Result
+	if opts.Batch <= 0 {
+		return result, fmt.Errorf("the batch size must be positive")
+	}
+
+	source, err := dial(opts.From, opts.FromPassword)
+	if err != nil {
+		return result, fmt.Errorf("connecting to the source: %w", err)
+	}
+	defer source.Close()
+	if opts.DB != 0 {
+		replies, err := source.do([][]string{{"SELECT", strconv.Itoa(opts.DB)}})
+		if err == nil && replies[0].Typ == "error" {
+			err = fmt.Errorf("%s", replies[0].Str)
+		}
+		if err != nil {
+			return result, fmt.Errorf("selecting the source database: %w", err)
+		}
+	}
+	target, err := dial(opts.To, opts.ToPassword)
+	if err != nil {
+		return result, fmt.Errorf("connecting to the target: %w", err)
+	}
+	defer target.Close()
+
+	err = scanKeys(source, opts, func(keys []string) error {
+		if err := copyKeys(source, target, keys, opts.Replace, &result, log); err != nil {
+			return err
+		}
+		fmt.Fprintf(log, "Imported %d keys, %d skipped, %d failed\n", result.Imported, result.Skipped, result.Failed)
+		return nil
+	})
+	return result, err
+}
+
+// scanKeys calls f with the keys of the source matching opts.Match, a batch at a time. A
+// source without SCAN, like this server, has its keys listed at once with KEYS.
+func scanKeys(source *conn, opts Options, f func(keys []string) error) error {
+	cursor := "0"
+	for {
+		replies, err := source.do([][]string{{"SCAN", cursor, "MATCH", opts.Match, "COUNT", strconv.Itoa(opts.Batch)}})
+		if err != nil {
+			return fmt.Errorf("scanning the source: %w", err)
+		}
+		reply := replies[0]
+		if reply.Typ != "array" || len(reply.Array) != 2 {
+			if cursor == "0" {
+				return listKeys(source, opts, f)
+			}
+			return fmt.Errorf("scanning the source: unexpected reply %s", describe(reply))
+		}
+
+		var keys []string
+		for _, key := range reply.Array[1].Array {
+			keys = append(keys, key.Bulk)
+		}
+		if len(keys) > 0 {
+			if err := f(keys); err != nil {
+				return err
+			}
+		}
+		cursor = reply.Array[0].Bulk
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// listKeys calls f with the keys KEYS returns, opts.Batch at a time
+func listKeys(source *conn, opts Options, f func(keys []string) error) error {
+	replies, err := source.do([][]string{{"KEYS", opts.Match}})
+	if err != nil {
+		return fmt.Errorf("listing the keys of the source: %w", err)
+	}
+	if replies[0].Typ != "array" {
+		return fmt.Errorf("listing the keys of the source: unexpected reply %s", describe(replies[0]))
+	}
+	keys := make([]string, len(replies[0].Array))
+	for i, key := range replies[0].Array {
+		keys[i] = key.Bulk
+	}
+	for start := 0; start < len(keys); start += opts.Batch {
+		if err := f(keys[start:min(start+opts.Batch, len(keys))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyKeys reads the value and the time to live of keys on the source, and restores them on
+// the target
+func copyKeys(source, target *conn, keys []string, replace bool, result *Result, log io.Writer) error {
+	reads := make([][]string, 0, 2*len(keys))
+	for _, key := range keys {
+		reads = append(reads, []string{"PTTL", key}, []string{"DUMP", key})
+	}
+	replies, err := source.do(reads)
+	if err != nil {
+		return fmt.Errorf("reading keys from the source: %w", err)
+	}
+
+	var restored []string
+	var restores [][]string
+	for i, key := range keys {
+		ttl, payload := replies[2*i], replies[2*i+1]
+		if ttl.Typ != "integer" {
+			ttl.Num = -1 // A source without PTTL, the key is copied without expiration
+		}
+		switch {
+		case payload.Typ == "error":
+			fmt.Fprintf(log, "Failed to dump %q: %s\n", key, payload.Str)
+			result.Failed++
+			continue
+		case payload.Typ == "null" || ttl.Num == -2:
+			result.Skipped++ // Deleted or expired since it was listed
+			continue
+		}
+		restore := []string{"RESTORE", key, strconv.Itoa(max(ttl.Num, 0)), payload.Bulk}
+		if replace {
+			restore = append(restore, "REPLACE")
+		}
+		restored = append(restored, key)
+		restores = append(restores, restore)
+	}
+	if len(restores) == 0 {
+		return nil
+	}
+
+	replies, err = target.do(restores)
+	if err != nil {
+		return fmt.Errorf("restoring keys on the target: %w", err)
+	}
+	for i, reply := range replies {
+		switch {
+		case reply.Typ != "error":
+			result.Imported++
+		case strings.HasPrefix(reply.Str, "BUSYKEY"):
+			result.Skipped++
+		default:
+			fmt.Fprintf(log, "Failed to restore %q: %s\n", restored[i], reply.Str)
+			result.Failed++
+		}
+	}
+	return nil
+}
+
+// conn is a connection to a server sending pipelined commands
+type conn struct {
+	net.Conn
+	reader *protocol.Resp
+}
+
+// dial connects to the server at addr and authenticates when a password is given
+func dial(addr, password string) (*conn, error) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, reader: protocol.NewResp(nc)}
+	if password != "" {
+		replies, err := c.do([][]string{{"AUTH", password}})
+		if err == nil && replies[0].Typ == "error" {
+			err = fmt.Errorf("%s", replies[0].Str)
+		}
+		if err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// do sends commands at once and returns their replies in order
+func (c *conn) do(commands [][]string) ([]protocol.Value, error) {
+	var buf []byte
+	for _, command := range commands {
+		args := make([]protocol.Value, len(command)-1)
+		for i, arg := range command[1:] {
+			args[i] = protocol.Value{Typ: "bulk", Bulk: arg}
+		}
+		buf = protocol.AppendCommand(buf, command[0], args)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+
+	replies := make([]protocol.Value, len(commands))
+	for i := range replies {
+		reply, err := c.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// describe returns a reply as shown in errors
+func describe(v protocol.Value) string {
+	if v.Typ == "error" {
+		return v.Str
+	}
+	return v.Typ
+}
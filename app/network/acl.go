@@ -0,0 +1,550 @@
+package network
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// DefaultUser is the user connections are authenticated as until they run AUTH
+const DefaultUser = "default"
+
+// ACLCategories lists the command categories ACL rules and ACL CAT accept, without the @
+var ACLCategories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string", "bitmap", "hyperloglog",
+	"geo", "stream", "pubsub", "admin", "fast", "slow", "blocking", "dangerous", "connection",
+	"transaction", "scripting",
+}
+
+// ACLUser is a user connections can authenticate as, with the commands, keys and channels it may access
+type ACLUser struct {
+	Name            string
+	Enabled         bool            // Whether connections can authenticate as the user
+	NoPass          bool            // Whether any password is accepted
+	Passwords       []string        // SHA-256 digests of the passwords, in hexadecimal
+	Commands        map[string]bool // Lowercase names of the commands the user may run
+	CommandRules    []string        // Command rules in the order they were applied, as shown by ACL LIST
+	AllKeys         bool            // Whether every key is accessible
+	KeyPatterns     []string        // Glob patterns of the accessible keys
+	AllChannels     bool            // Whether every Pub/Sub channel is accessible
+	ChannelPatterns []string        // Glob patterns of the accessible channels
+}
+
+// aclUsersMu protects aclUsers
+var aclUsersMu sync.RWMutex
+
+// aclUsers maps user names to users
+var aclUsers = map[string]*ACLUser{DefaultUser: newDefaultUser()}
+
+// newACLUser returns a user created by ACL SETUSER: disabled, without password and without permissions
+func newACLUser(name string) *ACLUser {
+	return &ACLUser{Name: name, Commands: make(map[string]bool), CommandRules: []string{"-@all"}}
+}
+
+// newDefaultUser returns the default user, allowed to run every command on every key and channel
+func newDefaultUser() *ACLUser {
+	user := newACLUser(DefaultUser)
+	for _, rule := range []string{"on", "nopass", "~*", "&*", "+@all"} {
+		user.applyRule(rule)
+	}
+	return user
+}
+
+// HashPassword returns the SHA-256 digest of a password in hexadecimal, as stored in ACL users
+func HashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// clone returns a deep copy of the user, so rules can be applied without changing it
+func (u *ACLUser) clone() *ACLUser {
+	copied := *u
+	copied.Passwords = append([]string(nil), u.Passwords...)
+	copied.CommandRules = append([]string(nil), u.CommandRules...)
+	copied.KeyPatterns = append([]string(nil), u.KeyPatterns...)
+	copied.ChannelPatterns = append([]string(nil), u.ChannelPatterns...)
+	copied.Commands = make(map[string]bool, len(u.Commands))
+	for command := range u.Commands {
+		copied.Commands[command] = true
+	}
+	return &copied
+}
+
+// applyRule applies an ACL rule like on, >password, ~pattern or +@read to the user
+func (u *ACLUser) applyRule(rule string) error {
+	lower := strings.ToLower(rule)
+	switch {
+	case lower == "on":
+		u.Enabled = true
+	case lower == "off":
+		u.Enabled = false
+	case lower == "nopass":
+		u.NoPass = true
+		u.Passwords = nil
+	case lower == "resetpass":
+		u.NoPass = false
+		u.Passwords = nil
+	case strings.HasPrefix(rule, ">"):
+		u.addPassword(HashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "<"):
+		u.removePassword(HashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "#"):
+		if !isPasswordHash(rule[1:]) {
+			return fmt.Errorf("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+		}
+		u.addPassword(rule[1:])
+	case strings.HasPrefix(rule, "!"):
+		u.removePassword(rule[1:])
+	case lower == "allkeys" || rule == "~*":
+		u.AllKeys = true
+		u.KeyPatterns = nil
+	case lower == "resetkeys":
+		u.AllKeys = false
+		u.KeyPatterns = nil
+	case strings.HasPrefix(rule, "~"):
+		if !u.AllKeys {
+			u.KeyPatterns = append(u.KeyPatterns, rule[1:])
+		}
+	case lower == "allchannels" || rule == "&*":
+		u.AllChannels = true
+		u.ChannelPatterns = nil
+	case lower == "resetchannels":
+		u.AllChannels = false
+		u.ChannelPatterns = nil
+	case strings.HasPrefix(rule, "&"):
+		if !u.AllChannels {
+			u.ChannelPatterns = append(u.ChannelPatterns, rule[1:])
+		}
+	case lower == "allcommands" || lower == "+@all":
+		for i := range CommandTable {
+			u.Commands[CommandTable[i].Name] = true
+		}
+		u.CommandRules = []string{"+@all"}
+	case lower == "nocommands" || lower == "-@all":
+		u.Commands = make(map[string]bool)
+		u.CommandRules = []string{"-@all"}
+	case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"):
+		commands, ok := aclRuleCommands(lower[1:])
+		if !ok {
+			return fmt.Errorf("Unknown command or category name in ACL")
+		}
+		for _, command := range commands {
+			if rule[0] == '+' {
+				u.Commands[command] = true
+			} else {
+				delete(u.Commands, command)
+			}
+		}
+		u.CommandRules = append(u.CommandRules, lower)
+	case lower == "reset":
+		*u = *newACLUser(u.Name)
+	default:
+		return fmt.Errorf("Syntax error")
+	}
+	return nil
+}
+
+// aclRuleCommands returns the commands a +/- rule refers to: a command name, or @ followed by a category
+func aclRuleCommands(name string) ([]string, bool) {
+	if !strings.HasPrefix(name, "@") {
+		spec, ok := LookupCommand(name)
+		if !ok {
+			return nil, false
+		}
+		return []string{spec.Name}, true
+	}
+
+	category := name[1:]
+	if !IsACLCategory(category) {
+		return nil, false
+	}
+	return ACLCategoryCommands(category), true
+}
+
+// IsACLCategory reports whether category, without the @, is a known command category
+func IsACLCategory(category string) bool {
+	for _, known := range ACLCategories {
+		if known == category {
+			return true
+		}
+	}
+	return false
+}
+
+// ACLCategoryCommands returns the names of the commands in a category, given without the @
+func ACLCategoryCommands(category string) []string {
+	var commands []string
+	for i := range CommandTable {
+		for _, c := range CommandTable[i].Categories {
+			if c == "@"+category {
+				commands = append(commands, CommandTable[i].Name)
+				break
+			}
+		}
+	}
+	return commands
+}
+
+// isPasswordHash reports whether s is a SHA-256 digest in lowercase hexadecimal
+func isPasswordHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *ACLUser) addPassword(hash string) {
+	u.NoPass = false
+	for _, existing := range u.Passwords {
+		if existing == hash {
+			return
+		}
+	}
+	u.Passwords = append(u.Passwords, hash)
+}
+
+func (u *ACLUser) removePassword(hash string) {
+	for i, existing := range u.Passwords {
+		if existing == hash {
+			u.Passwords = append(u.Passwords[:i], u.Passwords[i+1:]...)
+			return
+		}
+	}
+}
+
+// CheckPassword reports whether password authenticates the user
+func (u *ACLUser) CheckPassword(password string) bool {
+	if !u.Enabled {
+		return false
+	}
+	if u.NoPass {
+		return true
+	}
+	hash := HashPassword(password)
+	for _, existing := range u.Passwords {
+		if existing == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessKey reports whether the user may access key
+func (u *ACLUser) CanAccessKey(key string) bool {
+	return u.AllKeys || matchesAnyPattern(u.KeyPatterns, key)
+}
+
+// CanAccessChannel reports whether the user may publish or subscribe to channel
+func (u *ACLUser) CanAccessChannel(channel string) bool {
+	return u.AllChannels || matchesAnyPattern(u.ChannelPatterns, channel)
+}
+
+// matchesAnyPattern reports whether s matches one of the glob patterns
+func matchesAnyPattern(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, s); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe returns the rules recreating the user, like "on nopass ~* &* +@all"
+func (u *ACLUser) Describe() string {
+	return strings.Join(u.DescribeRules(), " ")
+}
+
+// DescribeRules returns the rules recreating the user, one per element
+func (u *ACLUser) DescribeRules() []string {
+	var rules []string
+	if u.Enabled {
+		rules = append(rules, "on")
+	} else {
+		rules = append(rules, "off")
+	}
+	if u.NoPass {
+		rules = append(rules, "nopass")
+	}
+	for _, hash := range u.Passwords {
+		rules = append(rules, "#"+hash)
+	}
+	rules = append(rules, u.DescribeKeys()...)
+	rules = append(rules, u.DescribeChannels()...)
+	return append(rules, u.CommandRules...)
+}
+
+// DescribeKeys returns the key rules of the user, like ~* or ~cache:*
+func (u *ACLUser) DescribeKeys() []string {
+	if u.AllKeys {
+		return []string{"~*"}
+	}
+	rules := make([]string, 0, len(u.KeyPatterns))
+	for _, pattern := range u.KeyPatterns {
+		rules = append(rules, "~"+pattern)
+	}
+	return rules
+}
+
+// DescribeChannels returns the channel rules of the user, like &* or resetchannels &news.*
+func (u *ACLUser) DescribeChannels() []string {
+	if u.AllChannels {
+		return []string{"&*"}
+	}
+	rules := []string{"resetchannels"}
+	for _, pattern := range u.ChannelPatterns {
+		rules = append(rules, "&"+pattern)
+	}
+	return rules
+}
+
+// ACLSetUser creates or updates a user by applying rules in order. The rules are all
+// applied or none is: on error the user is left as it was.
+func ACLSetUser(name string, rules ...string) error {
+	aclUsersMu.Lock()
+	defer aclUsersMu.Unlock()
+
+	user, exists := aclUsers[name]
+	if exists {
+		user = user.clone()
+	} else {
+		user = newACLUser(name)
+	}
+	for _, rule := range rules {
+		if err := user.applyRule(rule); err != nil {
+			return fmt.Errorf("ERR Error in ACL SETUSER modifier '%s': %v", rule, err)
+		}
+	}
+	aclUsers[name] = user
+	return nil
+}
+
+// refreshACLCommands applies the command rules of every user again, so the commands
+// registered after the users were created follow the rules, like +@all or +@read, that cover them
+func refreshACLCommands() {
+	aclUsersMu.Lock()
+	defer aclUsersMu.Unlock()
+	for name, user := range aclUsers {
+		refreshed := user.clone()
+		refreshed.Commands = make(map[string]bool)
+		refreshed.CommandRules = nil
+		for _, rule := range user.CommandRules {
+			refreshed.applyRule(rule)
+		}
+		aclUsers[name] = refreshed
+	}
+}
+
+// ACLGetUser returns a copy of a user
+func ACLGetUser(name string) (*ACLUser, bool) {
+	aclUsersMu.RLock()
+	defer aclUsersMu.RUnlock()
+	user, exists := aclUsers[name]
+	if !exists {
+		return nil, false
+	}
+	return user.clone(), true
+}
+
+// ACLDeleteUser removes a user and disconnects the clients authenticated as it.
+// It reports whether the user existed.
+func ACLDeleteUser(name string) bool {
+	aclUsersMu.Lock()
+	_, exists := aclUsers[name]
+	delete(aclUsers, name)
+	aclUsersMu.Unlock()
+
+	if exists {
+		for _, connID := range ClientIDs() {
+			if info, ok := ClientInfoGet(connID); ok && info.User == name {
+				ClientKill(connID, false)
+			}
+		}
+	}
+	return exists
+}
+
+// ACLUserNames returns the names of every user, sorted
+func ACLUserNames() []string {
+	aclUsersMu.RLock()
+	names := make([]string, 0, len(aclUsers))
+	for name := range aclUsers {
+		names = append(names, name)
+	}
+	aclUsersMu.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// ACLAuthenticate checks the credentials of a user and, when they are valid, authenticates
+// the connection as it
+func ACLAuthenticate(connID, username, password string) error {
+	user, exists := ACLGetUser(username)
+	if !exists || !user.CheckPassword(password) {
+		return fmt.Errorf("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	ClientInfoUpdate(connID, func(info *shared.ClientInfo) {
+		info.User = username
+		info.Authenticated = true
+	})
+	return nil
+}
+
+// ACLDefaultUserAuthenticates reports whether new connections are authenticated as the
+// default user without running AUTH, which is the case while it is enabled without a password.
+// Protected mode checks it before every command, so the user is read in place, not copied.
+func ACLDefaultUserAuthenticates() bool {
+	aclUsersMu.RLock()
+	defer aclUsersMu.RUnlock()
+	user, exists := aclUsers[DefaultUser]
+	return exists && user.Enabled && user.NoPass
+}
+
+// ACLSetDefaultPassword makes password the only password of the default user,
+// an empty password lets anyone authenticate as it (requirepass)
+func ACLSetDefaultPassword(password string) {
+	rule := "nopass"
+	if password != "" {
+		rule = ">" + password
+	}
+	ACLSetUser(DefaultUser, "resetpass", rule)
+}
+
+// aclCheck returns the error replied to a client that may not run a command with these
+// arguments, or an empty string when it may. Internal connections without client
+// metadata, like the link to the master, are not checked.
+func aclCheck(connID, command string, args []protocol.Value) string {
+	info, exists := ClientInfoGet(connID)
+	if !exists {
+		return ""
+	}
+	spec, known := LookupCommand(command)
+	if !known || spec.HasFlag(CommandFlagNoAuth) {
+		return ""
+	}
+	if !info.Authenticated {
+		return shared.NoAuthMessage
+	}
+
+	aclUsersMu.RLock()
+	defer aclUsersMu.RUnlock()
+	user, exists := aclUsers[info.User]
+	if !exists {
+		return shared.NoAuthMessage
+	}
+	if !user.Commands[spec.Name] {
+		return fmt.Sprintf("NOPERM User %s has no permissions to run the '%s' command", user.Name, spec.Name)
+	}
+
+	// Users allowed every key or channel, like the default user, skip finding them
+	if !user.AllKeys {
+		keys, _ := ExtractKeys(command, args)
+		for _, key := range keys {
+			if !user.CanAccessKey(key) {
+				return "NOPERM No permissions to access a key"
+			}
+		}
+	}
+	if !user.AllChannels {
+		for _, channel := range commandChannels(spec, args) {
+			if !user.CanAccessChannel(channel) {
+				return "NOPERM No permissions to access a channel"
+			}
+		}
+	}
+	return ""
+}
+
+// commandChannels returns the Pub/Sub channels a command accesses
+func commandChannels(spec *CommandSpec, args []protocol.Value) []string {
+	switch spec.Name {
+	case "publish":
+		return []string{args[0].Bulk}
+	case "subscribe":
+		channels := make([]string, len(args))
+		for i, arg := range args {
+			channels[i] = arg.Bulk
+		}
+		return channels
+	}
+	return nil
+}
+
+// LoadACLFile replaces the users with those defined in an ACL file, one "user <name> <rules>"
+// line per user. The default user is reset to its initial rules unless the file defines it.
+// Nothing changes when the file has an error.
+func LoadACLFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Error loading ACLs, opening file '%s': %v", path, err)
+	}
+	defer file.Close()
+
+	users := make(map[string]*ACLUser)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] != "user" || len(fields) < 2 {
+			return fmt.Errorf("%s:%d: should start with user keyword", path, lineNumber)
+		}
+		if _, duplicate := users[fields[1]]; duplicate {
+			return fmt.Errorf("%s:%d: duplicate user '%s' found", path, lineNumber, fields[1])
+		}
+		user := newACLUser(fields[1])
+		for _, rule := range fields[2:] {
+			if err := user.applyRule(rule); err != nil {
+				return fmt.Errorf("%s:%d: %v. Error in user declaration '%s'", path, lineNumber, err, fields[1])
+			}
+		}
+		users[user.Name] = user
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if _, exists := users[DefaultUser]; !exists {
+		users[DefaultUser] = newDefaultUser()
+	}
+
+	aclUsersMu.Lock()
+	aclUsers = users
+	aclUsersMu.Unlock()
+	return nil
+}
+
+// SaveACLFile writes every user to an ACL file, replacing it at once
+func SaveACLFile(path string) error {
+	var content strings.Builder
+	for _, name := range ACLUserNames() {
+		if user, exists := ACLGetUser(name); exists {
+			fmt.Fprintf(&content, "user %s %s\n", user.Name, user.Describe())
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
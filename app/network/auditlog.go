@@ -0,0 +1,240 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// auditLog is the file of audit-log-file. Commands queue their line before they run and a
+// writer goroutine appends the lines through a buffer, flushed whenever the queue is empty,
+// so the file is written in batches off the command path.
+type auditLog struct {
+	path    string
+	lines   chan string        // Lines queued by commands, closed to stop the writer
+	flushes chan chan struct{} // Requests to write the queued lines, answered once written
+	done    chan struct{}      // Closed once the writer wrote every line and closed the file
+
+	// Owned by the writer goroutine
+	file   *os.File
+	buffer *bufio.Writer
+	size   int64 // Bytes in file, to rotate it once over audit-log-max-size
+}
+
+// auditLogQueue is the number of lines queued before the commands wait for the writer
+const auditLogQueue = 1024
+
+// auditLogMu protects currentAuditLog. Commands queue their line under the read lock, so the
+// log isn't stopped while they do.
+var auditLogMu sync.RWMutex
+var currentAuditLog *auditLog
+
+// SetAuditLogFile starts appending the write and administrative commands run by clients to
+// the file at path, or stops when path is empty. Each command is queued before it runs, and
+// written on a line like
+//
+//	1700000000.123456 [127.0.0.1:51234 default name=worker] "config" "set" "maxmemory" "1gb"
+//
+// with the client address, user and name. The arguments holding passwords, given to ACL
+// SETUSER and CONFIG SET requirepass, are redacted. Once the file reaches audit-log-max-size
+// it is renamed with a .1 suffix, shifting older files up to audit-log-max-files, and a new
+// file is started. The previous file is closed once its queued lines are written.
+func SetAuditLogFile(path string) error {
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+	if currentAuditLog != nil && currentAuditLog.path == path {
+		return nil
+	}
+
+	var next *auditLog
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open the audit log: %v", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to open the audit log: %v", err)
+		}
+		next = &auditLog{
+			path:    path,
+			lines:   make(chan string, auditLogQueue),
+			flushes: make(chan chan struct{}),
+			done:    make(chan struct{}),
+			file:    file,
+			buffer:  bufio.NewWriter(file),
+			size:    info.Size(),
+		}
+		go next.run()
+	}
+	if currentAuditLog != nil {
+		close(currentAuditLog.lines)
+		<-currentAuditLog.done
+	}
+	currentAuditLog = next
+	return nil
+}
+
+// FlushAuditLog waits until the lines queued so far are written to the audit log file
+func FlushAuditLog() {
+	auditLogMu.RLock()
+	defer auditLogMu.RUnlock()
+	if currentAuditLog == nil {
+		return
+	}
+	written := make(chan struct{})
+	currentAuditLog.flushes <- written
+	<-written
+}
+
+// writeAuditLog queues a command allowed to run for the audit log, when it is a write or an
+// administrative command from a client. Writes replicated by the master are left out.
+func writeAuditLog(connID string, command string, args []protocol.Value) {
+	if !(IsWriteCommand(command) || commandHasFlag(command, CommandFlagAdmin)) || connID == MasterLinkID() {
+		return
+	}
+	auditLogMu.RLock()
+	defer auditLogMu.RUnlock()
+	if currentAuditLog == nil {
+		return
+	}
+	currentAuditLog.lines <- auditLine(connID, command, redactAuditArgs(command, args), time.Now())
+}
+
+// run writes the queued lines until the queue is closed, then closes the file
+func (l *auditLog) run() {
+	defer close(l.done)
+	for {
+		select {
+		case line, ok := <-l.lines:
+			if !ok {
+				l.flush()
+				if l.file != nil {
+					l.file.Close()
+				}
+				return
+			}
+			l.write(line)
+			if len(l.lines) == 0 {
+				l.flush()
+			}
+		case written := <-l.flushes:
+			for len(l.lines) > 0 {
+				l.write(<-l.lines)
+			}
+			l.flush()
+			close(written)
+		}
+	}
+}
+
+// write appends a line, rotating the file first when it would grow over audit-log-max-size
+func (l *auditLog) write(line string) {
+	maxSize := server.StoreState.AuditLogMaxSize
+	if l.file == nil || (maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > maxSize) {
+		if err := l.rotate(); err != nil {
+			hooksLog.Warningf("Failed to write the audit log: %v", err)
+			return
+		}
+	}
+	n, _ := l.buffer.WriteString(line)
+	l.size += int64(n)
+}
+
+// flush writes the buffered lines to the file
+func (l *auditLog) flush() {
+	if l.file == nil {
+		return
+	}
+	if err := l.buffer.Flush(); err != nil {
+		hooksLog.Warningf("Failed to write the audit log: %v", err)
+		// The lines that failed are dropped, the next ones are written
+		l.buffer.Reset(l.file)
+	}
+}
+
+// rotate renames the file to path.1 after renaming path.N to path.N+1, dropping the files
+// past audit-log-max-files, and opens a new file at path. When the new file can't be opened,
+// the next write tries again.
+func (l *auditLog) rotate() error {
+	if l.file != nil {
+		l.flush()
+		l.file.Close()
+		l.file = nil
+	}
+	maxFiles := server.StoreState.AuditLogMaxFiles
+	os.Remove(l.path + "." + strconv.Itoa(maxFiles))
+	for i := maxFiles - 1; i >= 1; i-- {
+		os.Rename(l.path+"."+strconv.Itoa(i), l.path+"."+strconv.Itoa(i+1))
+	}
+	if maxFiles > 0 {
+		os.Rename(l.path, l.path+".1")
+	} else {
+		os.Remove(l.path)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open a new audit log: %v", err)
+	}
+	l.file, l.size = file, 0
+	l.buffer.Reset(file)
+	return nil
+}
+
+// auditLine formats a command run by a client as a line of an audit log
+func auditLine(connID string, command string, args []protocol.Value, now time.Time) string {
+	client := connID + " " + DefaultUser
+	if info, ok := ClientInfoGet(connID); ok {
+		client = connID + " " + info.User
+		if info.Name != "" {
+			client += " name=" + info.Name
+		}
+	}
+	var line strings.Builder
+	fmt.Fprintf(&line, "%d.%06d [%s] %s", now.Unix(), now.Nanosecond()/1000, client, strconv.Quote(strings.ToLower(command)))
+	for _, arg := range args {
+		line.WriteString(" " + strconv.Quote(arg.Bulk))
+	}
+	line.WriteString("\n")
+	return line.String()
+}
+
+// redactAuditArgs returns the arguments of a command with the passwords it holds replaced
+func redactAuditArgs(command string, args []protocol.Value) []protocol.Value {
+	var redacted []protocol.Value
+	redact := func(i int) {
+		if redacted == nil {
+			redacted = append([]protocol.Value(nil), args...)
+		}
+		redacted[i] = protocol.Value{Typ: "bulk", Bulk: "(redacted)"}
+	}
+
+	switch {
+	case command == "ACL" && len(args) > 2 && strings.EqualFold(args[0].Bulk, "SETUSER"):
+		// Passwords are given as >password, <password, #hash and !hash rules
+		for i := 2; i < len(args); i++ {
+			if rule := args[i].Bulk; rule != "" && strings.ContainsRune("><#!", rune(rule[0])) {
+				redact(i)
+			}
+		}
+	case command == "CONFIG" && len(args) > 2 && strings.EqualFold(args[0].Bulk, "SET"):
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i].Bulk, "requirepass") {
+				redact(i + 1)
+			}
+		}
+	}
+	if redacted == nil {
+		return args
+	}
+	return redacted
+}
@@ -0,0 +1,154 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// blockedClient is a client waiting in a blocking command for one of its keys to be ready
+type blockedClient struct {
+	keys []string
+	wake chan struct{} // Signaled when one of the keys is ready or the client is killed
+}
+
+// Blocked clients are registered by key, so a write only wakes the clients waiting on the keys
+// it changed. Each Client also keeps the command it waits in, so killing it wakes it.
+var blockedByKey = make(map[string]map[*blockedClient]struct{})
+var blockedMu sync.Mutex
+
+// blockedCount is the number of blocked clients, read without the lock by every write command
+var blockedCount atomic.Int64
+
+// BlockOnKeys serves a blocking command: it calls serve, and while serve reports nothing was
+// served, waits for a write to one of keys before calling it again. It stops waiting once
+// timeout passes, 0 waiting forever, or the client is killed or disconnects, and then
+// reports false.
+// Commands like BLPOP and XREAD BLOCK share it, so none of them polls the keyspace.
+//
+// Examples:
+//
+//	reply, ok := BlockOnKeys(client, []string{"queue"}, time.Second, popHead)   // ok is false after a second without a push
+func BlockOnKeys(client *Client, keys []string, timeout time.Duration, serve func() (protocol.Value, bool)) (protocol.Value, bool) {
+	// Registering before the first try, so a write between the try and the wait still wakes us
+	bc := blockClient(client, keys)
+	defer unblockClient(client, bc)
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ctx := client.Context()
+	ClientSetBlocked(client.ConnID, true)
+	defer ClientSetBlocked(client.ConnID, false)
+	for {
+		if reply, ok := serve(); ok {
+			return reply, true
+		}
+		woken := false
+		unlockWhileWaiting(client.ConnID, func() {
+			select {
+			case <-bc.wake:
+				woken = !client.Killed()
+			case <-deadline:
+			case <-ctx.Done():
+			}
+		})
+		if !woken {
+			return protocol.Value{}, false
+		}
+	}
+}
+
+// blockClient registers a client as waiting on keys
+func blockClient(client *Client, keys []string) *blockedClient {
+	bc := &blockedClient{keys: keys, wake: make(chan struct{}, 1)}
+	client.mu.Lock()
+	client.blocked = bc
+	client.mu.Unlock()
+
+	blockedMu.Lock()
+	defer blockedMu.Unlock()
+	for _, key := range keys {
+		waiting, ok := blockedByKey[key]
+		if !ok {
+			waiting = make(map[*blockedClient]struct{})
+			blockedByKey[key] = waiting
+		}
+		waiting[bc] = struct{}{}
+	}
+	blockedCount.Add(1)
+	return bc
+}
+
+// unblockClient forgets a client once it stopped waiting
+func unblockClient(client *Client, bc *blockedClient) {
+	client.mu.Lock()
+	if client.blocked == bc {
+		client.blocked = nil
+	}
+	client.mu.Unlock()
+
+	blockedMu.Lock()
+	defer blockedMu.Unlock()
+	for _, key := range bc.keys {
+		waiting := blockedByKey[key]
+		delete(waiting, bc)
+		if len(waiting) == 0 {
+			delete(blockedByKey, key)
+		}
+	}
+	blockedCount.Add(-1)
+}
+
+// signal wakes a blocked client without waiting, a pending signal being enough to wake it
+func (bc *blockedClient) signal() {
+	select {
+	case bc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SignalKeysReady wakes the clients waiting on keys after a write changed them. The woken
+// clients try to serve their command again and go back to waiting when another client was
+// served first.
+func SignalKeysReady(keys ...string) {
+	if blockedCount.Load() == 0 {
+		return
+	}
+	blockedMu.Lock()
+	defer blockedMu.Unlock()
+	for _, key := range keys {
+		for bc := range blockedByKey[key] {
+			bc.signal()
+		}
+	}
+}
+
+// wakeBlockedClient wakes a client waiting in a blocking command, so it notices it was killed
+func wakeBlockedClient(connID string) {
+	c, exists := ClientGet(connID)
+	if !exists {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.blocked != nil {
+		c.blocked.signal()
+	}
+}
+
+// signalWrittenKeys wakes the clients waiting on the keys of a write command that succeeded
+func signalWrittenKeys(command string, args []protocol.Value, result protocol.Value) {
+	if blockedCount.Load() == 0 || result.Typ == "error" || !IsWriteCommand(command) {
+		return
+	}
+	if keys, err := ExtractKeys(command, args); err == nil {
+		SignalKeysReady(keys...)
+	}
+}
@@ -0,0 +1,290 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Client holds the state of a connection: its socket, its metadata, its transaction, its
+// subscriptions, the blocking command it waits in, the changes of the command it runs and
+// whether it monitors the server. The connection loop keeps the Client of its connection and
+// command handlers are given it and use its methods, while the helpers taking a connection ID,
+// for the commands looking at other clients, find it in a single map.
+//
+// Its context is canceled once the client disconnected, so a command waiting for the client
+// stops as soon as nobody is left to reply to.
+type Client struct {
+	ConnID string
+
+	mu          sync.Mutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	conn        net.Conn
+	info        *shared.ClientInfo  // nil until the client is registered with ClientInfoRegister
+	transaction *shared.Transaction // nil outside of MULTI
+	monitor     bool
+	asking      bool           // Whether the client sent ASKING, for its next command only
+	rate        rateLimits     // Commands and bytes the client may still send, see RateLimited
+	channels    []string       // Channels the client subscribed to, in subscription order
+	subscribed  bool           // Whether the client is in subscribed mode
+	blocked     *blockedClient // Blocking command the client waits in, nil when none
+	dirty       int            // Changes made by the command the client runs, see MarkDirty
+	gone        bool           // Unregistered once its connection closed, never registered again
+}
+
+// clients maps connection IDs to their client
+var clients = make(map[string]*Client)
+var clientsMu sync.RWMutex
+
+// ClientRegister registers a new connection and returns its client
+func ClientRegister(connID string, conn net.Conn) *Client {
+	ConnectionsSet(connID, conn)
+	ClientInfoRegister(connID, conn)
+	c, _ := ClientGet(connID)
+	return c
+}
+
+// ClientUnregister forgets everything about a connection once it is closed
+func ClientUnregister(connID string) {
+	MonitorsDelete(connID)
+	clientsMu.Lock()
+	c, ok := clients[connID]
+	delete(clients, connID)
+	clientsMu.Unlock()
+	if ok {
+		c.mu.Lock()
+		c.gone = true
+		c.mu.Unlock()
+		c.Disconnected()
+	}
+}
+
+// ClientGet returns the client of a connection
+func ClientGet(connID string) (*Client, bool) {
+	clientsMu.RLock()
+	c, ok := clients[connID]
+	clientsMu.RUnlock()
+	return c, ok
+}
+
+// ClientOf returns the client of a connection, or a client of its own for a connection ID
+// that wasn't registered, like the loading of the append only file or a test. Either way
+// the helpers taking the connection ID see the same connection as the client.
+func ClientOf(connID string) *Client {
+	if c, ok := ClientGet(connID); ok {
+		return c
+	}
+	return &Client{ConnID: connID}
+}
+
+// clientFor returns the client of a connection, creating it when state is first recorded
+// for a connection ID that wasn't registered, like the link to the master
+func clientFor(connID string) *Client {
+	if c, ok := ClientGet(connID); ok {
+		return c
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	c, ok := clients[connID]
+	if !ok {
+		c = &Client{ConnID: connID}
+		clients[connID] = c
+	}
+	return c
+}
+
+// updateClient applies fn to the client of a connection, then forgets the client when nothing
+// is left recorded about it
+func updateClient(connID string, fn func(c *Client)) {
+	c := clientFor(connID)
+	c.update(func() { fn(c) })
+}
+
+// update applies fn to the client holding its lock. A client of a connection that wasn't
+// registered, like the ones ClientOf returns to tests, is recorded in clients while it holds
+// some state, so the helpers taking its connection ID see it, and forgotten once nothing is
+// left.
+func (c *Client) update(fn func()) {
+	c.mu.Lock()
+	fn()
+	empty := c.conn == nil && c.info == nil && c.transaction == nil && !c.monitor && !c.asking &&
+		len(c.channels) == 0 && !c.subscribed
+	gone := c.gone
+	c.mu.Unlock()
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	current, ok := clients[c.ConnID]
+	switch {
+	case empty && current == c:
+		delete(clients, c.ConnID)
+	case !empty && !ok && !gone:
+		clients[c.ConnID] = c
+	}
+}
+
+// Context returns the context of the client, canceled once it disconnected
+func (c *Client) Context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+	}
+	return c.ctx
+}
+
+// Disconnected cancels the context of the client, the commands it is waiting in return
+func (c *Client) Disconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+	}
+	c.cancel()
+}
+
+// ClientContext returns the context of a connection, canceled once it disconnected. Unknown
+// connections, like the ones of tests, get a context that is never canceled.
+func ClientContext(connID string) context.Context {
+	if c, exists := ClientGet(connID); exists {
+		return c.Context()
+	}
+	return context.Background()
+}
+
+// Conn returns the socket of the client, nil when it has none
+func (c *Client) Conn() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// Info returns a copy of the metadata of the client
+func (c *Client) Info() (shared.ClientInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.info == nil {
+		return shared.ClientInfo{}, false
+	}
+	return *c.info, true
+}
+
+// Protocol returns the RESP version replies to the client are encoded in
+func (c *Client) Protocol() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.info != nil && c.info.Protocol != 0 {
+		return c.info.Protocol
+	}
+	return protocol.RESP2
+}
+
+// Killed reports whether the client was killed with CLIENT KILL
+func (c *Client) Killed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info != nil && c.info.Killed
+}
+
+// StartTransaction starts queueing the commands of the client, for MULTI
+func (c *Client) StartTransaction() {
+	c.update(func() {
+		c.transaction = &shared.Transaction{Commands: []shared.QueuedCommand{}}
+	})
+}
+
+// Transaction returns a copy of the transaction of the client, reporting false outside of MULTI
+func (c *Client) Transaction() (shared.Transaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.transaction == nil {
+		return shared.Transaction{}, false
+	}
+	return *c.transaction, true
+}
+
+// EndTransaction discards the transaction of the client, for EXEC and DISCARD
+func (c *Client) EndTransaction() {
+	c.update(func() {
+		c.transaction = nil
+	})
+}
+
+// InTransaction reports whether the client is between MULTI and EXEC
+func (c *Client) InTransaction() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.transaction != nil
+}
+
+// Queue adds a command to the transaction of the client
+func (c *Client) Queue(command string, args []protocol.Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.transaction != nil {
+		c.transaction.Commands = append(c.transaction.Commands, shared.QueuedCommand{Command: command, Args: args})
+	}
+}
+
+// AbortTransaction marks the transaction of the client to be discarded by EXEC, after a
+// command failed to queue
+func (c *Client) AbortTransaction() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.transaction != nil {
+		c.transaction.Aborted = true
+	}
+}
+
+// IsMonitor reports whether the client ran MONITOR
+func (c *Client) IsMonitor() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.monitor
+}
+
+// MarkDirty records that the command the client runs changed the dataset. Handlers call it
+// with the number of changes they made (keys set, elements pushed, ...), and the dispatcher
+// uses it to decide whether the command must be propagated. The keys changed are reported to
+// server.NotifyKeyModified on their own.
+func (c *Client) MarkDirty(changes int) {
+	if changes <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.dirty += changes
+	c.mu.Unlock()
+}
+
+// TakeDirty returns the number of changes recorded for the client and resets it
+func (c *Client) TakeDirty() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changes := c.dirty
+	c.dirty = 0
+	return changes
+}
+
+// SetAsking lets the next command of the client access a slot this node is importing
+func (c *Client) SetAsking() {
+	c.update(func() {
+		c.asking = true
+	})
+}
+
+// takeAsking reports whether a connection sent ASKING before this command, and clears it
+func takeAsking(connID string) bool {
+	if _, ok := ClientGet(connID); !ok {
+		return false
+	}
+	asking := false
+	updateClient(connID, func(c *Client) {
+		asking = c.asking
+		c.asking = false
+	})
+	return asking
+}
@@ -0,0 +1,199 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// lastClientID is the ID given to the last accepted client
+var lastClientID atomic.Int64
+
+// Client info helpers
+func ClientInfoRegister(connID string, conn net.Conn) {
+	now := time.Now().UnixMilli()
+	info := &shared.ClientInfo{
+		ID:              lastClientID.Add(1),
+		Addr:            conn.RemoteAddr().String(),
+		LocalAddr:       conn.LocalAddr().String(),
+		CreatedAt:       now,
+		LastInteraction: now,
+		Protocol:        protocol.RESP2,
+		User:            DefaultUser,
+		Authenticated:   ACLDefaultUserAuthenticates(),
+	}
+
+	updateClient(connID, func(c *Client) {
+		c.info = info
+	})
+}
+
+// ClientInfoUpdate applies fn to the metadata of a client, it does nothing for unknown clients
+func ClientInfoUpdate(connID string, fn func(info *shared.ClientInfo)) {
+	if c, exists := ClientGet(connID); exists {
+		c.UpdateInfo(fn)
+	}
+}
+
+// UpdateInfo changes the information of the client under its lock
+func (c *Client) UpdateInfo(fn func(info *shared.ClientInfo)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.info != nil {
+		fn(c.info)
+	}
+}
+
+// ClientProtocol returns the RESP version replies to a client are encoded in
+func ClientProtocol(connID string) int {
+	if c, exists := ClientGet(connID); exists {
+		return c.Protocol()
+	}
+	return protocol.RESP2
+}
+
+func ClientInfoGet(connID string) (shared.ClientInfo, bool) {
+	c, exists := ClientGet(connID)
+	if !exists {
+		return shared.ClientInfo{}, false
+	}
+	return c.Info()
+}
+
+func ClientInfoDelete(connID string) {
+	updateClient(connID, func(c *Client) {
+		c.info = nil
+	})
+}
+
+// ClientIDs returns the connection IDs of all clients, ordered by client ID
+func ClientIDs() []string {
+	clientsMu.RLock()
+	all := make([]*Client, 0, len(clients))
+	for _, c := range clients {
+		all = append(all, c)
+	}
+	clientsMu.RUnlock()
+
+	ids := make(map[string]int64, len(all))
+	connIDs := make([]string, 0, len(all))
+	for _, c := range all {
+		if info, ok := c.Info(); ok {
+			ids[c.ConnID] = info.ID
+			connIDs = append(connIDs, c.ConnID)
+		}
+	}
+	sort.Slice(connIDs, func(i, j int) bool {
+		return ids[connIDs[i]] < ids[connIDs[j]]
+	})
+	return connIDs
+}
+
+// ClientKill marks a client as killed and closes its connection. With afterReply, used when
+// clients kill themselves, the connection loop closes it once the reply is written.
+func ClientKill(connID string, afterReply bool) {
+	ClientInfoUpdate(connID, func(info *shared.ClientInfo) {
+		info.Killed = true
+	})
+	wakeBlockedClient(connID)
+	if afterReply {
+		return
+	}
+	if conn, ok := ConnectionsGet(connID); ok {
+		conn.Close()
+	}
+}
+
+// ClientSetBlocked records a client starting or stopping to wait in a blocking command
+func ClientSetBlocked(connID string, blocked bool) {
+	ClientInfoUpdate(connID, func(info *shared.ClientInfo) {
+		info.Blocked = blocked
+	})
+	if blocked {
+		server.ClientBlocked(1)
+	} else {
+		server.ClientBlocked(-1)
+	}
+}
+
+// ClientKilled reports whether a client was killed with CLIENT KILL
+func ClientKilled(connID string) bool {
+	c, exists := ClientGet(connID)
+	return exists && c.Killed()
+}
+
+// Monitors helpers
+func MonitorsAdd(connID string) {
+	clientFor(connID).StartMonitor()
+}
+
+// StartMonitor makes the client a monitor, sent every command the server runs
+func (c *Client) StartMonitor() {
+	c.update(func() {
+		if !c.monitor {
+			c.monitor = true
+			monitorsCount.Add(1)
+		}
+	})
+}
+
+func MonitorsDelete(connID string) {
+	if _, exists := ClientGet(connID); !exists {
+		return
+	}
+	updateClient(connID, func(c *Client) {
+		if c.monitor {
+			c.monitor = false
+			monitorsCount.Add(-1)
+		}
+	})
+}
+
+func IsMonitor(connID string) bool {
+	c, exists := ClientGet(connID)
+	return exists && c.IsMonitor()
+}
+
+// monitorsCount is the number of clients that ran MONITOR, so commands only look for
+// monitors when there are some
+var monitorsCount atomic.Int64
+
+// FeedMonitors sends a command about to be executed to every monitoring connection, like
+// +1700000000.123456 [0 127.0.0.1:51234 name] "set" "key" "value". The client name is
+// only shown when it is set.
+func FeedMonitors(connID string, command string, args []protocol.Value) {
+	if monitorsCount.Load() == 0 {
+		return
+	}
+	var monitors []*Client
+	clientsMu.RLock()
+	for _, c := range clients {
+		monitors = append(monitors, c)
+	}
+	clientsMu.RUnlock()
+
+	source := connID
+	if info, exists := ClientInfoGet(connID); exists && info.Name != "" {
+		source += " " + info.Name
+	}
+	now := time.Now()
+	line := fmt.Sprintf("%d.%06d [0 %s] %s", now.Unix(), now.Nanosecond()/1000, source, strconv.Quote(strings.ToLower(command)))
+	for _, arg := range args {
+		line += " " + strconv.Quote(arg.Bulk)
+	}
+	message := protocol.Value{Typ: "string", Str: line}.Marshal()
+
+	for _, c := range monitors {
+		if conn := c.Conn(); conn != nil && c.IsMonitor() {
+			conn.Write(message)
+		}
+	}
+}
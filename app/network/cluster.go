@@ -0,0 +1,484 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// clusterLog logs the messages of the cluster subsystem
+var clusterLog = logger.New("cluster")
+
+// With cluster-enabled, the keyspace is split in ClusterSlots hash slots, a key belonging to
+// the slot of the CRC16 of its name or of its hash tag, and every slot is served by one node. The node starts
+// as a cluster of its own serving every slot, the commands of a client must then only keep
+// their keys in a single slot. Nodes learn about each other with CLUSTER MEET and slots move
+// between them with CLUSTER SETSLOT, clients being redirected with MOVED to the node serving
+// a slot, or with ASK to the node importing a slot for the keys already moved there.
+const ClusterSlots = 16384
+
+// Errors replied to commands refused in cluster mode
+const (
+	CrossSlotMessage     = "CROSSSLOT Keys in request don't hash to the same slot"
+	SlotNotServedMessage = "CLUSTERDOWN Hash slot not served"
+	TryAgainMessage      = "TRYAGAIN Multiple keys request during rehashing of slot"
+)
+
+// clusterMeetTimeout bounds connecting to a node and reading its ID with CLUSTER MEET
+const clusterMeetTimeout = 2 * time.Second
+
+// ClusterNode is a node of the cluster
+type ClusterNode struct {
+	ID   string // 40 hexadecimal characters, chosen by the node when it starts
+	IP   string // Address announced to clients, empty for the one they connected to
+	Port string // Port clients connect to
+}
+
+// ClusterSlotRange is a range of consecutive slots served by the same node
+type ClusterSlotRange struct {
+	Start int
+	End   int // Inclusive
+	Node  ClusterNode
+}
+
+// clusterMu protects clusterMyself, clusterNodes, clusterSlots, clusterMigrating and clusterImporting
+var clusterMu sync.RWMutex
+
+// clusterMyself is this node, nil until ClusterInit
+var clusterMyself *ClusterNode
+
+// clusterNodes indexes the known nodes by ID, this node included
+var clusterNodes = make(map[string]*ClusterNode)
+
+// clusterSlots holds the node serving each slot, nil for an unassigned slot
+var clusterSlots [ClusterSlots]*ClusterNode
+
+// clusterMigrating holds the node each slot served here is moving to, nil for a stable slot
+var clusterMigrating [ClusterSlots]*ClusterNode
+
+// clusterImporting holds the node each slot is moving here from, nil for a stable slot
+var clusterImporting [ClusterSlots]*ClusterNode
+
+// ClusterEnabled reports whether the server runs in cluster mode
+func ClusterEnabled() bool {
+	return server.StoreState.ClusterEnabled
+}
+
+// ClusterInit makes this node a cluster of its own with a new node ID, serving every slot
+func ClusterInit(ip string, port string) {
+	myself := &ClusterNode{ID: generateNodeID(), IP: ip, Port: port}
+
+	clusterMu.Lock()
+	defer clusterMu.Unlock()
+	clusterMyself = myself
+	clusterNodes = map[string]*ClusterNode{myself.ID: myself}
+	for slot := range clusterSlots {
+		clusterSlots[slot] = myself
+		clusterMigrating[slot] = nil
+		clusterImporting[slot] = nil
+	}
+	clusterLog.Noticef("No cluster configuration found, I'm %s", myself.ID)
+}
+
+// generateNodeID returns a random node ID of 40 hexadecimal characters
+func generateNodeID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ClusterMyself returns this node, false before ClusterInit
+func ClusterMyself() (ClusterNode, bool) {
+	clusterMu.RLock()
+	defer clusterMu.RUnlock()
+	if clusterMyself == nil {
+		return ClusterNode{}, false
+	}
+	return *clusterMyself, true
+}
+
+// ClusterKnownNodes returns the number of nodes of the cluster, this node included
+func ClusterKnownNodes() int {
+	clusterMu.RLock()
+	defer clusterMu.RUnlock()
+	return len(clusterNodes)
+}
+
+// ClusterSlotRanges returns the assigned slots as ranges of consecutive slots served by the
+// same node, in slot order
+func ClusterSlotRanges() []ClusterSlotRange {
+	clusterMu.RLock()
+	defer clusterMu.RUnlock()
+	var ranges []ClusterSlotRange
+	for slot := 0; slot < ClusterSlots; slot++ {
+		node := clusterSlots[slot]
+		if node == nil {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End == slot-1 && ranges[n-1].Node.ID == node.ID {
+			ranges[n-1].End = slot
+			continue
+		}
+		ranges = append(ranges, ClusterSlotRange{Start: slot, End: slot, Node: *node})
+	}
+	return ranges
+}
+
+// KeyHashSlot returns the slot of a key, the CRC16 of its name modulo the number of slots.
+// When the name holds a hash tag, a non-empty substring between the first { and the first }
+// after it, only the tag is hashed, so keys like {user1000}.following and
+// {user1000}.followers share a slot.
+func KeyHashSlot(key string) int {
+	return int(crc16(keyHashTag(key))) & (ClusterSlots - 1)
+}
+
+// keyHashTag returns the part of a key hashed to find its slot: its hash tag, or the whole
+// key when it has none or an empty one
+func keyHashTag(key string) string {
+	open := strings.IndexByte(key, '{')
+	if open < 0 {
+		return key
+	}
+	closing := strings.IndexByte(key[open+1:], '}')
+	if closing <= 0 {
+		return key
+	}
+	return key[open+1 : open+1+closing]
+}
+
+// crc16Table is the lookup table of the CRC16-CCITT (XMODEM) polynomial 0x1021
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// crc16 returns the CRC16-CCITT (XMODEM) checksum of s, the one Redis Cluster hashes keys with
+func crc16(s string) uint16 {
+	crc := uint16(0)
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// ClusterNodeIP returns the address announced by a node, or the one a client connected to
+// when the node announces none
+func ClusterNodeIP(connID string, node ClusterNode) string {
+	if node.IP != "" {
+		return node.IP
+	}
+	if conn, ok := ConnectionsGet(connID); ok {
+		if host, _, err := net.SplitHostPort(conn.LocalAddr().String()); err == nil {
+			return host
+		}
+	}
+	return "127.0.0.1"
+}
+
+// ClusterMeet adds the node listening at ip and port to the cluster. There is no cluster bus:
+// the node is asked its ID with CLUSTER MYID on its client port, and doesn't learn about
+// this node in return.
+func ClusterMeet(ip string, port string) (ClusterNode, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, port), clusterMeetTimeout)
+	if err != nil {
+		return ClusterNode{}, fmt.Errorf("ERR Can't reach node at %s:%s: %v", ip, port, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(clusterMeetTimeout))
+
+	err = protocol.NewWriter(conn).Write(protocol.Value{Typ: "array", Array: []protocol.Value{
+		{Typ: "bulk", Bulk: "CLUSTER"}, {Typ: "bulk", Bulk: "MYID"},
+	}})
+	if err != nil {
+		return ClusterNode{}, fmt.Errorf("ERR Can't reach node at %s:%s: %v", ip, port, err)
+	}
+	reply, err := protocol.NewResp(conn).Read()
+	if err != nil {
+		return ClusterNode{}, fmt.Errorf("ERR Can't reach node at %s:%s: %v", ip, port, err)
+	}
+	if reply.Typ != "bulk" || len(reply.Bulk) != 40 {
+		return ClusterNode{}, fmt.Errorf("ERR Node at %s:%s is not a cluster node", ip, port)
+	}
+
+	node := &ClusterNode{ID: reply.Bulk, IP: ip, Port: port}
+	clusterMu.Lock()
+	defer clusterMu.Unlock()
+	if node.ID == clusterMyself.ID {
+		return ClusterNode{}, fmt.Errorf("ERR Node at %s:%s is myself", ip, port)
+	}
+	if known, ok := clusterNodes[node.ID]; ok {
+		// The node moved, slots keep pointing at it
+		known.IP, known.Port = ip, port
+		return *known, nil
+	}
+	clusterNodes[node.ID] = node
+	clusterLog.Noticef("Node %s at %s:%s added to the cluster", node.ID, ip, port)
+	return *node, nil
+}
+
+// ClusterSetSlotImporting starts importing slot from the node serving it, so clients asking
+// with ASKING are served its keys here
+func ClusterSetSlotImporting(slot int, nodeID string) error {
+	clusterMu.Lock()
+	defer clusterMu.Unlock()
+	if clusterSlots[slot] == clusterMyself {
+		return fmt.Errorf("ERR I'm already the owner of hash slot %d", slot)
+	}
+	node, err := clusterLookupNode(nodeID)
+	if err != nil {
+		return err
+	}
+	if node == clusterMyself {
+		return fmt.Errorf("ERR I can't import hash slot %d from myself", slot)
+	}
+	clusterImporting[slot] = node
+	return nil
+}
+
+// ClusterSetSlotMigrating starts migrating slot to a node, clients being asked to retry there
+// for the keys no longer found here
+func ClusterSetSlotMigrating(slot int, nodeID string) error {
+	clusterMu.Lock()
+	defer clusterMu.Unlock()
+	if clusterSlots[slot] != clusterMyself {
+		return fmt.Errorf("ERR I'm not the owner of hash slot %d", slot)
+	}
+	node, err := clusterLookupNode(nodeID)
+	if err != nil {
+		return err
+	}
+	if node == clusterMyself {
+		return fmt.Errorf("ERR I can't migrate hash slot %d to myself", slot)
+	}
+	clusterMigrating[slot] = node
+	return nil
+}
+
+// ClusterSetSlotStable clears the migrating and importing states of slot
+func ClusterSetSlotStable(slot int) {
+	clusterMu.Lock()
+	defer clusterMu.Unlock()
+	clusterMigrating[slot] = nil
+	clusterImporting[slot] = nil
+}
+
+// ClusterSetSlotNode assigns slot to a node, ending its migration. A slot can't be given away
+// while keys of it are still here.
+func ClusterSetSlotNode(slot int, nodeID string) error {
+	keys := ClusterCountKeysInSlot(slot)
+
+	clusterMu.Lock()
+	defer clusterMu.Unlock()
+	node, err := clusterLookupNode(nodeID)
+	if err != nil {
+		return err
+	}
+	if clusterSlots[slot] == clusterMyself && node != clusterMyself && keys > 0 {
+		return fmt.Errorf("ERR Can't assign hashslot %d to a different node while I still hold keys for this hash slot.", slot)
+	}
+	if keys == 0 {
+		clusterMigrating[slot] = nil
+	}
+	if node == clusterMyself {
+		clusterImporting[slot] = nil
+	}
+	clusterSlots[slot] = node
+	return nil
+}
+
+// clusterLookupNode returns a known node, clusterMu must be held
+func clusterLookupNode(nodeID string) (*ClusterNode, error) {
+	node, ok := clusterNodes[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("ERR I don't know about node %s", nodeID)
+	}
+	return node, nil
+}
+
+// ClusterSlotState returns the node a slot is migrating to and the node it is importing from,
+// empty when the slot is stable
+func ClusterSlotState(slot int) (migratingTo string, importingFrom string) {
+	clusterMu.RLock()
+	defer clusterMu.RUnlock()
+	if node := clusterMigrating[slot]; node != nil {
+		migratingTo = node.ID
+	}
+	if node := clusterImporting[slot]; node != nil {
+		importingFrom = node.ID
+	}
+	return migratingTo, importingFrom
+}
+
+// ClusterKeysInSlot returns up to count keys of slot
+func ClusterKeysInSlot(slot int, count int) []string {
+	var keys []string
+	now := time.Now().UnixMilli()
+	server.Memory.Range(func(key string, entry shared.MemoryEntry) bool {
+		if len(keys) >= count {
+			return false
+		}
+		if (entry.Expires == 0 || entry.Expires >= now) && KeyHashSlot(key) == slot {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// ClusterCountKeysInSlot returns the number of keys of slot
+func ClusterCountKeysInSlot(slot int) int {
+	count := 0
+	now := time.Now().UnixMilli()
+	server.Memory.Range(func(key string, entry shared.MemoryEntry) bool {
+		if (entry.Expires == 0 || entry.Expires >= now) && KeyHashSlot(key) == slot {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// ParseClusterSlot parses a slot number, replying the error of an invalid one
+func ParseClusterSlot(arg string) (int, error) {
+	slot, err := strconv.Atoi(arg)
+	if err != nil || slot < 0 || slot >= ClusterSlots {
+		return 0, fmt.Errorf("ERR Invalid or out of range slot")
+	}
+	return slot, nil
+}
+
+// clusterCheck returns the error replied to a command whose keys can't be served here in
+// cluster mode: keys in different slots, in a slot no node serves, or in a slot served by
+// another node, which the client is redirected to. The master link is trusted.
+func clusterCheck(command string, connID string, args []protocol.Value) string {
+	if !ClusterEnabled() || connID == MasterLinkID() {
+		return ""
+	}
+	asking := takeAsking(connID)
+	keys, err := ExtractKeys(command, args)
+	if err != nil || len(keys) == 0 {
+		return ""
+	}
+	return clusterCheckKeys(connID, keys, asking, !IsWriteCommand(command) && clientReadOnly(connID))
+}
+
+// ClusterCheckTransaction returns the error replied to EXEC when the keys of the queued
+// commands can't be served together here in cluster mode
+func ClusterCheckTransaction(connID string, commands []shared.QueuedCommand) string {
+	if !ClusterEnabled() {
+		return ""
+	}
+	var keys []string
+	readOnly := clientReadOnly(connID)
+	for _, queued := range commands {
+		commandKeys, _ := ExtractKeys(queued.Command, queued.Args)
+		keys = append(keys, commandKeys...)
+		readOnly = readOnly && !IsWriteCommand(queued.Command)
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	return clusterCheckKeys(connID, keys, false, readOnly)
+}
+
+// clientReadOnly reports whether a client sent READONLY
+func clientReadOnly(connID string) bool {
+	info, _ := ClientInfoGet(connID)
+	return info.ReadOnly
+}
+
+// clusterCheckKeys checks that keys share a slot this node serves. While the slot migrates,
+// the keys already moved are served by the importing node, to clients that send ASKING first.
+// A replica serves the slots of its master to reads, readOnly, of clients that sent READONLY.
+func clusterCheckKeys(connID string, keys []string, asking bool, readOnly bool) string {
+	slot := KeyHashSlot(keys[0])
+	for _, key := range keys[1:] {
+		if KeyHashSlot(key) != slot {
+			return CrossSlotMessage
+		}
+	}
+
+	clusterMu.RLock()
+	owner, migratingTo, importingFrom := clusterSlots[slot], clusterMigrating[slot], clusterImporting[slot]
+	myself := clusterMyself
+	clusterMu.RUnlock()
+
+	switch {
+	case owner == nil:
+		return SlotNotServedMessage
+	case owner == myself && migratingTo != nil:
+		missing := countMissingKeys(keys)
+		if missing == 0 {
+			return ""
+		}
+		if missing < len(keys) {
+			return TryAgainMessage
+		}
+		return clusterRedirect("ASK", connID, slot, migratingTo)
+	case owner != myself && importingFrom != nil && asking:
+		if len(keys) > 1 && countMissingKeys(keys) > 0 {
+			return TryAgainMessage
+		}
+		return ""
+	case owner != myself && readOnly && clusterReplicates(owner):
+		return ""
+	case owner != myself:
+		return clusterRedirect("MOVED", connID, slot, owner)
+	}
+	return ""
+}
+
+// clusterReplicates reports whether this node is a replica of node. There is no cluster bus
+// telling nodes about replicas, so the master is recognized by the address it is replicated from.
+func clusterReplicates(node *ClusterNode) bool {
+	if server.StoreState.Role != "slave" {
+		return false
+	}
+	host, port, ok := strings.Cut(server.StoreState.ReplicaOf, " ")
+	if !ok {
+		return false
+	}
+	clusterMu.RLock()
+	defer clusterMu.RUnlock()
+	return node.Port == port && (node.IP == "" || node.IP == host)
+}
+
+// clusterRedirect returns a MOVED or ASK error sending the client to node for slot
+func clusterRedirect(kind string, connID string, slot int, node *ClusterNode) string {
+	clusterMu.RLock()
+	target := *node
+	clusterMu.RUnlock()
+	return kind + " " + strconv.Itoa(slot) + " " + net.JoinHostPort(ClusterNodeIP(connID, target), target.Port)
+}
+
+// countMissingKeys returns the number of keys that don't exist or expired
+func countMissingKeys(keys []string) int {
+	missing := 0
+	now := time.Now().UnixMilli()
+	for _, key := range keys {
+		if entry, ok := server.Memory.Get(key); !ok || (entry.Expires > 0 && entry.Expires < now) {
+			missing++
+		}
+	}
+	return missing
+}
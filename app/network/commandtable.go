@@ -0,0 +1,503 @@
+package network
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// CommandSpec describes a command: how many arguments it takes, where its keys are,
+// and the flags the dispatcher and the COMMAND command rely on
+type CommandSpec struct {
+	Name       string   // Lowercase command name
+	Arity      int      // Number of arguments including the command name, negative for a minimum
+	Flags      []string // Command flags, like write, readonly or stale
+	FirstKey   int      // Position of the first key argument, 0 when the command takes no key
+	LastKey    int      // Position of the last key argument, negative counts from the end
+	KeyStep    int      // Step between key arguments
+	Categories []string // ACL categories, like @write or @list
+	Summary    string   // One line description returned by COMMAND DOCS
+	Since      string   // Redis version that introduced the command
+	Group      string   // Documentation group, like string or list
+}
+
+// Command flags
+const (
+	CommandFlagWrite       = "write"       // Modifies the dataset, propagated to replicas and the append only file
+	CommandFlagReadonly    = "readonly"    // Only reads the dataset
+	CommandFlagDenyOOM     = "denyoom"     // May use more memory, refused when out of memory
+	CommandFlagAdmin       = "admin"       // Administrative command
+	CommandFlagPubsub      = "pubsub"      // Publish/subscribe command
+	CommandFlagNoscript    = "noscript"    // Not allowed in scripts
+	CommandFlagBlocking    = "blocking"    // May block the client
+	CommandFlagLoading     = "loading"     // Allowed while the dataset is loading
+	CommandFlagStale       = "stale"       // Allowed on a replica with stale data
+	CommandFlagFast        = "fast"        // Runs in constant or logarithmic time
+	CommandFlagMovableKeys = "movablekeys" // Key positions depend on the arguments
+	CommandFlagNoAuth      = "no-auth"     // Allowed before the client authenticated
+)
+
+// CommandTable lists every command the server implements, in alphabetical order. Specs are
+// held by pointer so the ones handed out stay valid when registering a command sorts the table.
+var CommandTable = []*CommandSpec{
+	{Name: "acl", Arity: -2, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "A container for Access List Control commands.", Since: "6.0.0", Group: "server"},
+	{Name: "asking", Arity: 1, Flags: []string{"fast"}, Categories: []string{"@fast", "@connection"},
+		Summary: "Signals that a cluster client is following an -ASK redirect.", Since: "3.0.0", Group: "cluster"},
+	{Name: "auth", Arity: -2, Flags: []string{"noscript", "loading", "stale", "fast", "no-auth"}, Categories: []string{"@fast", "@connection"},
+		Summary: "Authenticates the connection.", Since: "1.0.0", Group: "connection"},
+	{Name: "bgrewriteaof", Arity: 1, Flags: []string{"admin", "noscript"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "Asynchronously rewrites the append-only file to disk.", Since: "1.0.0", Group: "server"},
+	{Name: "bgsave", Arity: -1, Flags: []string{"admin", "noscript"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "Asynchronously saves the database(s) to disk.", Since: "1.0.0", Group: "server"},
+	{Name: "blpop", Arity: -3, Flags: []string{"write", "blocking"}, FirstKey: 1, LastKey: -2, KeyStep: 1, Categories: []string{"@write", "@list", "@slow", "@blocking"},
+		Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise.", Since: "2.0.0", Group: "list"},
+	{Name: "client", Arity: -2, Flags: []string{"noscript", "loading", "stale"}, Categories: []string{"@slow", "@connection"},
+		Summary: "A container for client connection commands.", Since: "2.4.0", Group: "connection"},
+	{Name: "cluster", Arity: -2, Flags: []string{"loading", "stale"}, Categories: []string{"@slow"},
+		Summary: "A container for Redis Cluster commands.", Since: "3.0.0", Group: "cluster"},
+	{Name: "command", Arity: -1, Flags: []string{"loading", "stale"}, Categories: []string{"@slow", "@connection"},
+		Summary: "Returns detailed information about all commands.", Since: "2.8.13", Group: "server"},
+	{Name: "config", Arity: -2, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "A container for server configuration commands.", Since: "2.0.0", Group: "server"},
+	{Name: "debug", Arity: -2, Flags: []string{"admin", "noscript", "loading"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "A container for debugging commands.", Since: "1.0.0", Group: "server"},
+	{Name: "del", Arity: -2, Flags: []string{"write"}, FirstKey: 1, LastKey: -1, KeyStep: 1, Categories: []string{"@keyspace", "@write", "@slow"},
+		Summary: "Deletes one or more keys.", Since: "1.0.0", Group: "generic"},
+	{Name: "discard", Arity: 1, Flags: []string{"noscript", "loading", "fast"}, Categories: []string{"@fast", "@transaction"},
+		Summary: "Discards a transaction.", Since: "2.0.0", Group: "transactions"},
+	{Name: "dump", Arity: 2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@keyspace", "@read", "@slow"},
+		Summary: "Returns a serialized representation of the value stored at a key.", Since: "2.6.0", Group: "generic"},
+	{Name: "echo", Arity: 2, Flags: []string{"stale", "fast"}, Categories: []string{"@fast", "@connection"},
+		Summary: "Returns the given string.", Since: "1.0.0", Group: "connection"},
+	{Name: "eval", Arity: -3, Flags: []string{"noscript", "stale", "movablekeys"}, Categories: []string{"@slow", "@scripting"},
+		Summary: "Executes a server-side Lua script.", Since: "2.6.0", Group: "scripting"},
+	{Name: "evalsha", Arity: -3, Flags: []string{"noscript", "stale", "movablekeys"}, Categories: []string{"@slow", "@scripting"},
+		Summary: "Executes a server-side Lua script by SHA1 digest.", Since: "2.6.0", Group: "scripting"},
+	{Name: "exec", Arity: 1, Flags: []string{"noscript", "loading"}, Categories: []string{"@slow", "@transaction"},
+		Summary: "Executes all commands in a transaction.", Since: "1.2.0", Group: "transactions"},
+	{Name: "failover", Arity: -1, Flags: []string{"admin", "noscript", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "Starts a coordinated failover from a server to one of its replicas.", Since: "6.2.0", Group: "server"},
+	{Name: "fcall", Arity: -3, Flags: []string{"noscript", "stale", "movablekeys"}, Categories: []string{"@slow", "@scripting"},
+		Summary: "Invokes a function.", Since: "7.0.0", Group: "scripting"},
+	{Name: "fcall_ro", Arity: -3, Flags: []string{"readonly", "noscript", "stale", "movablekeys"}, Categories: []string{"@slow", "@scripting"},
+		Summary: "Invokes a read-only function.", Since: "7.0.0", Group: "scripting"},
+	{Name: "function", Arity: -2, Flags: []string{"noscript"}, Categories: []string{"@slow", "@scripting"},
+		Summary: "A container for function commands.", Since: "7.0.0", Group: "scripting"},
+	{Name: "geoadd", Arity: -5, Flags: []string{"write", "denyoom"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@geo", "@slow"},
+		Summary: "Adds one or more members to a geospatial index.", Since: "3.2.0", Group: "geo"},
+	{Name: "geodist", Arity: -4, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@geo", "@slow"},
+		Summary: "Returns the distance between two members of a geospatial index.", Since: "3.2.0", Group: "geo"},
+	{Name: "geopos", Arity: -2, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@geo", "@slow"},
+		Summary: "Returns the longitude and latitude of members from a geospatial index.", Since: "3.2.0", Group: "geo"},
+	{Name: "geosearch", Arity: -7, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@geo", "@slow"},
+		Summary: "Queries a geospatial index for members inside an area of a box or a circle.", Since: "6.2.0", Group: "geo"},
+	{Name: "get", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@string", "@fast"},
+		Summary: "Returns the string value of a key.", Since: "1.0.0", Group: "string"},
+	{Name: "getset", Arity: 3, Flags: []string{"write", "denyoom", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@string", "@fast"},
+		Summary: "Returns the previous string value of a key after setting it to a new value.", Since: "1.0.0", Group: "string"},
+	{Name: "hello", Arity: -1, Flags: []string{"noscript", "loading", "stale", "fast", "no-auth"}, Categories: []string{"@fast", "@connection"},
+		Summary: "Handshakes with the Redis server.", Since: "6.0.0", Group: "connection"},
+	{Name: "incr", Arity: 2, Flags: []string{"write", "denyoom", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@string", "@fast"},
+		Summary: "Increments the integer value of a key by one.", Since: "1.0.0", Group: "string"},
+	{Name: "incrby", Arity: 3, Flags: []string{"write", "denyoom", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@string", "@fast"},
+		Summary: "Increments the integer value of a key by a number.", Since: "1.0.0", Group: "string"},
+	{Name: "info", Arity: -1, Flags: []string{"loading", "stale"}, Categories: []string{"@slow", "@dangerous"},
+		Summary: "Returns information and statistics about the server.", Since: "1.0.0", Group: "server"},
+	{Name: "keys", Arity: 2, Flags: []string{"readonly"}, Categories: []string{"@keyspace", "@read", "@slow", "@dangerous"},
+		Summary: "Returns all key names that match a pattern.", Since: "1.0.0", Group: "generic"},
+	{Name: "lastsave", Arity: 1, Flags: []string{"loading", "fast"}, Categories: []string{"@fast", "@dangerous"},
+		Summary: "Returns the Unix timestamp of the last successful save to disk.", Since: "1.0.0", Group: "server"},
+	{Name: "latency", Arity: -2, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "A container for latency diagnostics commands.", Since: "2.8.13", Group: "server"},
+	{Name: "llen", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@list", "@fast"},
+		Summary: "Returns the length of a list.", Since: "1.0.0", Group: "list"},
+	{Name: "lpop", Arity: -2, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@list", "@fast"},
+		Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", Since: "1.0.0", Group: "list"},
+	{Name: "lpush", Arity: -3, Flags: []string{"write", "denyoom", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@list", "@fast"},
+		Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0", Group: "list"},
+	{Name: "lrange", Arity: 4, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@list", "@slow"},
+		Summary: "Returns a range of elements from a list.", Since: "1.0.0", Group: "list"},
+	{Name: "memory", Arity: -2, Flags: []string{"readonly"}, Categories: []string{"@read", "@slow"},
+		Summary: "A container for memory diagnostics commands.", Since: "4.0.0", Group: "server"},
+	{Name: "monitor", Arity: 1, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "Listens for all requests received by the server in real-time.", Since: "1.0.0", Group: "server"},
+	{Name: "multi", Arity: 1, Flags: []string{"noscript", "loading", "fast"}, Categories: []string{"@fast", "@transaction"},
+		Summary: "Starts a transaction.", Since: "1.2.0", Group: "transactions"},
+	{Name: "object", Arity: -2, Flags: []string{"readonly"}, Categories: []string{"@keyspace", "@read", "@slow"},
+		Summary: "A container for object introspection commands.", Since: "2.2.3", Group: "generic"},
+	{Name: "pexpireat", Arity: -3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@keyspace", "@write", "@fast"},
+		Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", Since: "2.6.0", Group: "generic"},
+	{Name: "ping", Arity: -1, Flags: []string{"stale", "fast"}, Categories: []string{"@fast", "@connection"},
+		Summary: "Returns the server's liveliness response.", Since: "1.0.0", Group: "connection"},
+	{Name: "psync", Arity: -3, Flags: []string{"admin", "noscript", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "An internal command used in replication.", Since: "2.8.0", Group: "server"},
+	{Name: "publish", Arity: 3, Flags: []string{"pubsub", "loading", "stale", "fast"}, Categories: []string{"@pubsub", "@fast"},
+		Summary: "Posts a message to a channel.", Since: "2.0.0", Group: "pubsub"},
+	{Name: "readonly", Arity: 1, Flags: []string{"loading", "stale", "fast"}, Categories: []string{"@fast", "@connection"},
+		Summary: "Enables read-only queries for a connection to a Redis Cluster replica node.", Since: "3.0.0", Group: "cluster"},
+	{Name: "readwrite", Arity: 1, Flags: []string{"loading", "stale", "fast"}, Categories: []string{"@fast", "@connection"},
+		Summary: "Enables read-write queries for a connection to a Redis Cluster replica node.", Since: "3.0.0", Group: "cluster"},
+	{Name: "replconf", Arity: -1, Flags: []string{"admin", "noscript", "loading", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "An internal command for configuring the replication stream.", Since: "3.0.0", Group: "server"},
+	{Name: "restore", Arity: -4, Flags: []string{"write", "denyoom"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@keyspace", "@write", "@slow", "@dangerous"},
+		Summary: "Creates a key from the serialized representation of a value.", Since: "2.6.0", Group: "generic"},
+	{Name: "rpush", Arity: -3, Flags: []string{"write", "denyoom", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@list", "@fast"},
+		Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0", Group: "list"},
+	{Name: "save", Arity: 1, Flags: []string{"admin", "noscript"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "Synchronously saves the database(s) to disk.", Since: "1.0.0", Group: "server"},
+	{Name: "script", Arity: -2, Flags: []string{"noscript"}, Categories: []string{"@slow", "@scripting"},
+		Summary: "A container for Lua scripts management commands.", Since: "2.6.0", Group: "scripting"},
+	{Name: "set", Arity: -3, Flags: []string{"write", "denyoom"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@string", "@slow"},
+		Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Since: "1.0.0", Group: "string"},
+	{Name: "shutdown", Arity: -1, Flags: []string{"admin", "noscript", "loading"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "Synchronously saves the database(s) to disk and shuts down the Redis server.", Since: "1.0.0", Group: "server"},
+	{Name: "slowlog", Arity: -2, Flags: []string{"admin", "loading", "stale"}, Categories: []string{"@admin", "@slow", "@dangerous"},
+		Summary: "A container for slow log commands.", Since: "2.2.12", Group: "server"},
+	{Name: "subscribe", Arity: -2, Flags: []string{"pubsub", "noscript", "loading", "stale"}, Categories: []string{"@pubsub", "@slow"},
+		Summary: "Listens for messages published to channels.", Since: "2.0.0", Group: "pubsub"},
+	{Name: "type", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@keyspace", "@read", "@fast"},
+		Summary: "Determines the type of value stored at a key.", Since: "1.0.0", Group: "generic"},
+	{Name: "unsubscribe", Arity: -1, Flags: []string{"pubsub", "noscript", "loading", "stale"}, Categories: []string{"@pubsub", "@slow"},
+		Summary: "Stops listening to messages posted to channels.", Since: "2.0.0", Group: "pubsub"},
+	{Name: "wait", Arity: 3, Flags: []string{"noscript"}, Categories: []string{"@slow", "@connection"},
+		Summary: "Blocks until the asynchronous replication of all preceding write commands sent by the connection is completed.", Since: "3.0.0", Group: "generic"},
+	{Name: "xadd", Arity: -5, Flags: []string{"write", "denyoom", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@stream", "@fast"},
+		Summary: "Appends a new message to a stream. Creates the key if it doesn't exist.", Since: "5.0.0", Group: "stream"},
+	{Name: "xrange", Arity: -4, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@stream", "@slow"},
+		Summary: "Returns the messages from a stream within a range of IDs.", Since: "5.0.0", Group: "stream"},
+	{Name: "xread", Arity: -4, Flags: []string{"readonly", "blocking", "movablekeys"}, Categories: []string{"@read", "@stream", "@slow", "@blocking"},
+		Summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.", Since: "5.0.0", Group: "stream"},
+	{Name: "zadd", Arity: -4, Flags: []string{"write", "denyoom", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@sortedset", "@fast"},
+		Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.", Since: "1.2.0", Group: "sorted-set"},
+	{Name: "zcard", Arity: 2, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@sortedset", "@fast"},
+		Summary: "Returns the number of members in a sorted set.", Since: "1.2.0", Group: "sorted-set"},
+	{Name: "zrange", Arity: -4, Flags: []string{"readonly"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@sortedset", "@slow"},
+		Summary: "Returns members in a sorted set within a range of indexes.", Since: "1.2.0", Group: "sorted-set"},
+	{Name: "zrank", Arity: -3, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@sortedset", "@fast"},
+		Summary: "Returns the index of a member in a sorted set ordered by ascending scores.", Since: "2.0.0", Group: "sorted-set"},
+	{Name: "zrem", Arity: -3, Flags: []string{"write", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@write", "@sortedset", "@fast"},
+		Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.", Since: "1.2.0", Group: "sorted-set"},
+	{Name: "zscore", Arity: 3, Flags: []string{"readonly", "fast"}, FirstKey: 1, LastKey: 1, KeyStep: 1, Categories: []string{"@read", "@sortedset", "@fast"},
+		Summary: "Returns the score of a member in a sorted set.", Since: "1.2.0", Group: "sorted-set"},
+}
+
+// SubcommandTable lists the subcommands of the container commands, named like config|get, with
+// the number of arguments they take counting the command and the subcommand. The dispatcher
+// checks them like the arity of commands, so handlers only check what an arity can't express,
+// like the most arguments a subcommand with a negative arity takes.
+var SubcommandTable = []CommandSpec{
+	{Name: "acl|cat", Arity: -2},
+	{Name: "acl|deluser", Arity: -3},
+	{Name: "acl|getuser", Arity: 3},
+	{Name: "acl|list", Arity: 2},
+	{Name: "acl|load", Arity: 2},
+	{Name: "acl|save", Arity: 2},
+	{Name: "acl|setuser", Arity: -3},
+	{Name: "acl|users", Arity: 2},
+	{Name: "acl|whoami", Arity: 2},
+	{Name: "client|getname", Arity: 2},
+	{Name: "client|id", Arity: 2},
+	{Name: "client|info", Arity: 2},
+	{Name: "client|kill", Arity: -3},
+	{Name: "client|list", Arity: 2},
+	{Name: "client|no-evict", Arity: 3},
+	{Name: "client|no-touch", Arity: 3},
+	{Name: "client|setname", Arity: 3},
+	{Name: "cluster|countkeysinslot", Arity: 3},
+	{Name: "cluster|getkeysinslot", Arity: 4},
+	{Name: "cluster|info", Arity: 2},
+	{Name: "cluster|keyslot", Arity: 3},
+	{Name: "cluster|meet", Arity: 4},
+	{Name: "cluster|myid", Arity: 2},
+	{Name: "cluster|setslot", Arity: -4},
+	{Name: "cluster|shards", Arity: 2},
+	{Name: "cluster|slots", Arity: 2},
+	{Name: "command|count", Arity: 2},
+	{Name: "command|docs", Arity: -2},
+	{Name: "command|getkeys", Arity: -3},
+	{Name: "command|info", Arity: -2},
+	{Name: "config|get", Arity: -3},
+	{Name: "config|resetstat", Arity: 2},
+	{Name: "config|rewrite", Arity: 2},
+	{Name: "config|set", Arity: -4},
+	{Name: "debug|digest", Arity: 2},
+	{Name: "debug|digest-value", Arity: -2},
+	{Name: "debug|export", Arity: 3},
+	{Name: "debug|goroutines", Arity: 2},
+	{Name: "debug|heap", Arity: 2},
+	{Name: "debug|jmap", Arity: 2},
+	{Name: "debug|object", Arity: 3},
+	{Name: "debug|reload", Arity: 2},
+	{Name: "debug|set-active-expire", Arity: 3},
+	{Name: "debug|sleep", Arity: 3},
+	{Name: "debug|stringmatch-len", Arity: 2},
+	{Name: "function|delete", Arity: 3},
+	{Name: "function|dump", Arity: 2},
+	{Name: "function|flush", Arity: -2},
+	{Name: "function|kill", Arity: 2},
+	{Name: "function|list", Arity: -2},
+	{Name: "function|load", Arity: -3},
+	{Name: "function|restore", Arity: -3},
+	{Name: "latency|doctor", Arity: 2},
+	{Name: "latency|histogram", Arity: -2},
+	{Name: "latency|history", Arity: 3},
+	{Name: "latency|latest", Arity: 2},
+	{Name: "latency|reset", Arity: -2},
+	{Name: "memory|usage", Arity: -3},
+	{Name: "object|encoding", Arity: 3},
+	{Name: "object|help", Arity: 2},
+	{Name: "script|exists", Arity: -3},
+	{Name: "script|flush", Arity: -2},
+	{Name: "script|kill", Arity: 2},
+	{Name: "script|load", Arity: 3},
+	{Name: "slowlog|get", Arity: -2},
+	{Name: "slowlog|len", Arity: 2},
+	{Name: "slowlog|reset", Arity: 2},
+}
+
+// commandSpecs indexes CommandTable by uppercase command name
+var commandSpecs = make(map[string]*CommandSpec, len(CommandTable))
+
+// subcommandSpecs indexes SubcommandTable by uppercase name, like CONFIG|GET
+var subcommandSpecs = make(map[string]*CommandSpec, len(SubcommandTable))
+
+func init() {
+	indexCommandTable()
+	for i := range SubcommandTable {
+		subcommandSpecs[strings.ToUpper(SubcommandTable[i].Name)] = &SubcommandTable[i]
+	}
+}
+
+// indexCommandTable sorts CommandTable and indexes it in commandSpecs
+func indexCommandTable() {
+	sort.Slice(CommandTable, func(i, j int) bool { return CommandTable[i].Name < CommandTable[j].Name })
+	commandSpecs = make(map[string]*CommandSpec, len(CommandTable))
+	for i := range CommandTable {
+		commandSpecs[strings.ToUpper(CommandTable[i].Name)] = CommandTable[i]
+	}
+}
+
+// RegisterCommand adds a command implemented outside of the server to the command table,
+// run by handler with the arguments following its name. The spec gives its arity, flags and
+// key positions like the built-in commands, so arity checks, ACL rules, replication and
+// cluster redirections apply to it too. Commands are registered at startup, before clients
+// connect.
+func RegisterCommand(spec CommandSpec, handler CommandHandler) error {
+	spec.Name = strings.ToLower(spec.Name)
+	switch {
+	case spec.Name == "" || strings.ContainsAny(spec.Name, " |"):
+		return fmt.Errorf("invalid command name %q", spec.Name)
+	case handler == nil:
+		return fmt.Errorf("command %s has no handler", spec.Name)
+	case spec.Arity == 0:
+		return fmt.Errorf("command %s has an arity of 0", spec.Name)
+	case spec.FirstKey < 0 || (spec.FirstKey > 0 && spec.KeyStep <= 0):
+		return fmt.Errorf("command %s has invalid key positions", spec.Name)
+	}
+	if _, exists := LookupCommand(spec.Name); exists {
+		return fmt.Errorf("command %s already exists", spec.Name)
+	}
+	if _, exists := CommandHandlers[strings.ToUpper(spec.Name)]; exists {
+		return fmt.Errorf("command %s already exists", spec.Name)
+	}
+	if len(spec.Categories) == 0 {
+		spec.Categories = defaultCategories(spec)
+	}
+
+	CommandTable = append(CommandTable, &spec)
+	indexCommandTable()
+	CommandHandlers[strings.ToUpper(spec.Name)] = handler
+	registeredCommands[strings.ToUpper(spec.Name)] = true
+	refreshACLCommands()
+	return nil
+}
+
+// registeredCommands holds the uppercase names of the commands added with RegisterCommand
+var registeredCommands = make(map[string]bool)
+
+// UnregisterCommand removes a command added with RegisterCommand, like a test cleaning up
+// after itself. Built-in commands can't be removed.
+func UnregisterCommand(name string) error {
+	upper := strings.ToUpper(name)
+	if !registeredCommands[upper] {
+		return fmt.Errorf("command %s was not registered", strings.ToLower(name))
+	}
+	CommandTable = slices.DeleteFunc(CommandTable, func(spec *CommandSpec) bool { return spec == commandSpecs[upper] })
+	indexCommandTable()
+	delete(CommandHandlers, upper)
+	delete(registeredCommands, upper)
+	refreshACLCommands()
+	return nil
+}
+
+// defaultCategories returns the ACL categories of a registered command given without any,
+// found from its flags so rules like +@read or -@write cover it
+func defaultCategories(spec CommandSpec) []string {
+	var categories []string
+	if spec.HasFlag(CommandFlagWrite) {
+		categories = append(categories, "@write")
+	}
+	if spec.HasFlag(CommandFlagReadonly) {
+		categories = append(categories, "@read")
+	}
+	if spec.HasFlag(CommandFlagAdmin) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if spec.HasFlag(CommandFlagBlocking) {
+		categories = append(categories, "@blocking")
+	}
+	if spec.HasFlag(CommandFlagFast) {
+		return append(categories, "@fast")
+	}
+	return append(categories, "@slow")
+}
+
+// LookupCommand returns the spec of a command, the name is case-insensitive. The dispatcher
+// looks commands up several times each, already uppercase, so that case is not converted.
+func LookupCommand(command string) (*CommandSpec, bool) {
+	if spec, ok := commandSpecs[command]; ok {
+		return spec, true
+	}
+	spec, ok := commandSpecs[strings.ToUpper(command)]
+	return spec, ok
+}
+
+// LookupSubcommand returns the spec of the subcommand of a container command, like GET for
+// CONFIG, the names are case-insensitive
+func LookupSubcommand(command string, subcommand string) (*CommandSpec, bool) {
+	spec, ok := subcommandSpecs[strings.ToUpper(command+"|"+subcommand)]
+	return spec, ok
+}
+
+// ValidateCommand returns the error replied to a command the server doesn't know, or called
+// with a number of arguments its command table entry, or the entry of its subcommand, doesn't
+// accept, or an empty string when the command may be dispatched to its handler. Handlers can
+// count on the arguments their arity promises.
+func ValidateCommand(command string, args []protocol.Value) string {
+	if _, ok := CommandHandlers[command]; !ok {
+		return UnknownCommandError(strings.ToLower(command), args)
+	}
+	return ArityError(command, args)
+}
+
+// ArityError returns the wrong number of arguments error of a command, or of its subcommand,
+// called with args, or an empty string when their arity accepts them
+func ArityError(command string, args []protocol.Value) string {
+	spec, ok := LookupCommand(command)
+	if !ok {
+		return ""
+	}
+	if !spec.ArityOK(len(args) + 1) {
+		return shared.ErrWrongArity(spec.Name).Str
+	}
+	if len(args) > 0 {
+		if subcommand, ok := LookupSubcommand(command, args[0].Bulk); ok && !subcommand.ArityOK(len(args)+1) {
+			return shared.ErrWrongArity(subcommand.Name).Str
+		}
+	}
+	return ""
+}
+
+// commandName returns the lowercase name of a command, as the statistics record it
+func commandName(command string) string {
+	if spec, ok := LookupCommand(command); ok {
+		return spec.Name
+	}
+	return strings.ToLower(command)
+}
+
+// HasFlag reports whether the command has the given flag
+func (spec *CommandSpec) HasFlag(flag string) bool {
+	for _, f := range spec.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// ArityOK reports whether argc arguments, counting the command name, are accepted
+func (spec *CommandSpec) ArityOK(argc int) bool {
+	if spec.Arity >= 0 {
+		return argc == spec.Arity
+	}
+	return argc >= -spec.Arity
+}
+
+// commandHasFlag reports whether a command is in the table with the given flag
+func commandHasFlag(command string, flag string) bool {
+	spec, ok := LookupCommand(command)
+	return ok && spec.HasFlag(flag)
+}
+
+// ExtractKeys returns the keys a command accesses, given its arguments without the command name.
+// Key positions come from the command table, except for commands with movable keys, which
+// find them from their arguments. Commands taking no key return no key and no error.
+func ExtractKeys(command string, args []protocol.Value) ([]string, error) {
+	spec, ok := LookupCommand(command)
+	if !ok {
+		return nil, fmt.Errorf("ERR Invalid command specified")
+	}
+	argc := len(args) + 1
+	if !spec.ArityOK(argc) {
+		return nil, fmt.Errorf("ERR Invalid number of arguments specified for command")
+	}
+
+	if spec.HasFlag(CommandFlagMovableKeys) {
+		return extractMovableKeys(spec, args)
+	}
+	if spec.FirstKey == 0 {
+		return nil, nil
+	}
+
+	// Positions count the command name, so the key at position i is args[i-1]
+	last := spec.LastKey
+	if last < 0 {
+		last += argc
+	}
+	var keys []string
+	for i := spec.FirstKey; i <= last && i < argc; i += spec.KeyStep {
+		keys = append(keys, args[i-1].Bulk)
+	}
+	return keys, nil
+}
+
+// extractMovableKeys returns the keys of commands whose key positions depend on their arguments
+func extractMovableKeys(spec *CommandSpec, args []protocol.Value) ([]string, error) {
+	switch spec.Name {
+	case "eval", "evalsha", "fcall", "fcall_ro":
+		// EVAL script numkeys [key [key ...]] [arg [arg ...]], FCALL function numkeys ...
+		numKeys, err := strconv.Atoi(args[1].Bulk)
+		if err != nil || numKeys < 0 || numKeys > len(args)-2 {
+			break
+		}
+		keys := make([]string, numKeys)
+		for i, key := range args[2 : 2+numKeys] {
+			keys[i] = key.Bulk
+		}
+		return keys, nil
+	case "xread":
+		// XREAD [COUNT count] [BLOCK ms] STREAMS key [key ...] id [id ...]
+		for i, arg := range args {
+			if !strings.EqualFold(arg.Bulk, "STREAMS") {
+				continue
+			}
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				break
+			}
+			keys := make([]string, 0, len(rest)/2)
+			for _, key := range rest[:len(rest)/2] {
+				keys = append(keys, key.Bulk)
+			}
+			return keys, nil
+		}
+	}
+	return nil, fmt.Errorf("ERR Invalid arguments specified for command")
+}
@@ -3,11 +3,14 @@ package network
 import (
 	"fmt"
 	"net"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/pubsub"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
 // Function aliases for backward compatibility
@@ -17,70 +20,257 @@ var NewWriter = protocol.NewWriter
 // Constant aliases for backward compatibility
 const NO_RESPONSE = protocol.NO_RESPONSE
 
-// Connections is the global map of active connections.
-// The key is the connection ID.
-var Connections = make(map[string]net.Conn)
-
-// Transactions is the global map of transactions that are being executed.
-// The key is the connection ID.
-var Transactions = make(map[string]shared.Transaction)
-
-// Mutexes to protect concurrent access to global maps
-var connectionsMu sync.RWMutex
-var transactionsMu sync.RWMutex
+// CommandHandler runs a command for the client that sent it, with the arguments following its
+// name, and returns the reply
+type CommandHandler func(client *Client, args []protocol.Value) protocol.Value
 
 // CommandHandlers is a map of command names to their handler functions
-var CommandHandlers map[string]shared.CommandHandler
+var CommandHandlers map[string]CommandHandler
 
-// ExecuteCommand executes a command using the shared handlers map
+// ExecuteCommand executes a command for the client of connID using the shared handlers map
 func ExecuteCommand(command string, connID string, args []protocol.Value) protocol.Value {
+	return ClientOf(connID).Execute(command, args)
+}
+
+// Execute executes a command for the client using the shared handlers map, the handler is
+// given the client itself
+func (c *Client) Execute(command string, args []protocol.Value) protocol.Value {
+	connID := c.ConnID
+	// Monitors see every command before it runs
+	FeedMonitors(connID, command, args)
+	name := commandName(command)
+	c.mu.Lock()
+	if c.info != nil {
+		c.info.LastCommand = name
+		c.info.LastInteraction = time.Now().UnixMilli()
+	}
+	c.mu.Unlock()
+
+	handler, ok := CommandHandlers[command]
+	if err := rejectCommand(command, c, args); err != "" {
+		if ok {
+			server.RecordRejectedCall(name)
+		}
+		server.RecordErrorReply(err)
+		return protocol.Value{Typ: "error", Str: err}
+	}
+
+	start := time.Now()
+	result := handler(c, args)
+	elapsed := time.Since(start)
+	runAfterHooks(command, connID, args)
+	server.RecordCommand(name, elapsed)
+	if result.Typ == "error" {
+		server.RecordFailedCall(name)
+		server.RecordErrorReply(result.Str)
+	}
+	recordSlowCommand(command, connID, args, elapsed)
+	recordCommandLatency(command, elapsed)
+	signalWrittenKeys(command, args, result)
+	return result
+}
+
+// rejectCommand returns the error replied to a command refused before it runs, or an empty
+// string when it may run
+func rejectCommand(command string, c *Client, args []protocol.Value) string {
+	connID := c.ConnID
+	// Protected mode refuses every command from remote clients while the server is left open
+	if err := protectedModeCheck(connID); err != "" {
+		return err
+	}
+
+	// Only the commands flagged loading run while a replica loads the snapshot of its master
+	if storage.Loading() && !commandHasFlag(command, CommandFlagLoading) {
+		return "LOADING Redis is loading the dataset in memory"
+	}
+
 	// Check if client is in subscribed mode and command is not allowed
-	if pubsub.SubscribedModeGet(connID) && !pubsub.IsAllowedInSubscribedMode(command) {
-		return protocol.Value{Typ: "error", Str: fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", command)}
+	if c.Subscribed() && !pubsub.IsAllowedInSubscribedMode(command) {
+		return fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", command)
+	}
+
+	// Unknown commands and wrong numbers of arguments never reach the handlers
+	if err := ValidateCommand(command, args); err != "" {
+		return err
+	}
+
+	// The client must be authenticated as a user allowed to run the command on these keys and channels
+	if err := aclCheck(connID, command, args); err != "" {
+		return err
+	}
+
+	// In cluster mode the keys of a command must share a slot this node can serve
+	if err := clusterCheck(command, connID, args); err != "" {
+		return err
+	}
+
+	// A replica cut off from its master can be configured to refuse serving stale data
+	if server.StoreState.Role == "slave" && !server.StoreState.ReplicaServeStaleData && !MasterLinkUp() && !IsStaleCommand(command) {
+		return "MASTERDOWN Link with MASTER is down and replica-serve-stale-data is set to 'no'."
+	}
+
+	// Only the master may write to a replica's dataset
+	if IsWriteCommand(command) && server.StoreState.Role == "slave" && connID != MasterLinkID() {
+		return shared.ReadOnlyMessage
+	}
+
+	// Refuse writes on the master when not enough replicas are keeping up
+	if IsWriteCommand(command) && !CheckMinReplicas() {
+		return "NOREPLICAS Not enough good replicas to write."
+	}
+
+	// Hooks registered by the program embedding the server may refuse the command
+	if err := runBeforeHooks(command, connID, args); err != "" {
+		return err
+	}
+
+	// The audit log records the commands allowed to run before they do
+	writeAuditLog(connID, command, args)
+	return ""
+}
+
+// recordSlowCommand adds a command to the slow log when it ran long enough. Blocking commands
+// are left out since their duration is mostly waiting, and so is EXEC, whose queued commands
+// are logged one by one.
+func recordSlowCommand(command string, connID string, args []protocol.Value, elapsed time.Duration) {
+	if !server.SlowlogEnabled(elapsed) || command == "EXEC" || commandHasFlag(command, CommandFlagBlocking) {
+		return
+	}
+
+	argv := make([]string, 0, len(args)+1)
+	argv = append(argv, strings.ToLower(command))
+	for _, arg := range args {
+		argv = append(argv, arg.Bulk)
+	}
+	info, _ := ClientInfoGet(connID)
+	server.SlowlogRecord(argv, elapsed, info.Addr, info.Name)
+}
+
+// recordCommandLatency records a latency spike for a command that ran long enough,
+// blocking commands are left out since their duration is mostly waiting
+func recordCommandLatency(command string, elapsed time.Duration) {
+	if commandHasFlag(command, CommandFlagBlocking) {
+		return
+	}
+	event := server.LatencyEventCommand
+	if commandHasFlag(command, CommandFlagFast) {
+		event = server.LatencyEventFastCommand
+	}
+	server.LatencyAddSampleIfNeeded(event, elapsed)
+}
+
+// ExecuteAndPropagate executes a command and propagates its effects to replicas,
+// but only if it is a write command that actually changed the dataset.
+// Errors and no-ops (LPOP on a missing key, BLPOP timing out, ...) are not propagated.
+// With single-writer enabled, commands that can't wait run on the executor goroutine.
+// Writes wait while a failover is paused before they run, so they hold up neither the
+// executor nor the commands of the other clients.
+func ExecuteAndPropagate(command string, connID string, args []protocol.Value) protocol.Value {
+	return ClientOf(connID).ExecuteAndPropagate(command, args)
+}
+
+// ExecuteAndPropagate executes a command for the client like the package level
+// ExecuteAndPropagate, which finds the client of a connection ID
+func (c *Client) ExecuteAndPropagate(command string, args []protocol.Value) protocol.Value {
+	if pausedByFailover(command, c.ConnID) {
+		waitWritesUnpaused()
+	}
+	if !CommandMayWait(command) && !KillsScript(command, args) && ExecutorRunning() {
+		return executeExclusive(command, c, args)
 	}
+	return executeAndPropagate(command, c, args)
+}
+
+// executeAndPropagate runs ExecuteAndPropagate on the calling goroutine
+func executeAndPropagate(command string, c *Client, args []protocol.Value) protocol.Value {
+	unlock := lockCommand(command, c.ConnID, args)
+	defer unlock()
+	c.TakeDirty()
+	result := c.Execute(command, args)
 
-	if handler, ok := CommandHandlers[command]; ok {
-		return handler(connID, args)
+	if c.TakeDirty() > 0 && IsWriteCommand(command) {
+		PropagateEffects(command, args, result)
 	}
-	return protocol.Value{Typ: "string", Str: ""}
+	return result
 }
 
 // Connections helpers
 func ConnectionsSet(connID string, conn net.Conn) {
-	connectionsMu.Lock()
-	Connections[connID] = conn
-	connectionsMu.Unlock()
+	updateClient(connID, func(c *Client) {
+		c.conn = conn
+	})
 }
 
 func ConnectionsDelete(connID string) {
-	connectionsMu.Lock()
-	delete(Connections, connID)
-	connectionsMu.Unlock()
+	updateClient(connID, func(c *Client) {
+		c.conn = nil
+	})
 }
 
 func ConnectionsGet(connID string) (net.Conn, bool) {
-	connectionsMu.RLock()
-	c, ok := Connections[connID]
-	connectionsMu.RUnlock()
-	return c, ok
+	c, exists := ClientGet(connID)
+	if !exists {
+		return nil, false
+	}
+	conn := c.Conn()
+	return conn, conn != nil
+}
+
+func ConnectionsCount() int {
+	clientsMu.RLock()
+	all := make([]*Client, 0, len(clients))
+	for _, c := range clients {
+		all = append(all, c)
+	}
+	clientsMu.RUnlock()
+
+	count := 0
+	for _, c := range all {
+		if c.Conn() != nil {
+			count++
+		}
+	}
+	return count
 }
 
 // Transactions helpers
 func TransactionsGet(connID string) (shared.Transaction, bool) {
-	transactionsMu.RLock()
-	t, ok := Transactions[connID]
-	transactionsMu.RUnlock()
-	return t, ok
+	c, exists := ClientGet(connID)
+	if !exists {
+		return shared.Transaction{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.transaction == nil {
+		return shared.Transaction{}, false
+	}
+	return *c.transaction, true
 }
 
 func TransactionsSet(connID string, t shared.Transaction) {
-	transactionsMu.Lock()
-	Transactions[connID] = t
-	transactionsMu.Unlock()
+	updateClient(connID, func(c *Client) {
+		c.transaction = &t
+	})
 }
 
 func TransactionsDelete(connID string) {
-	transactionsMu.Lock()
-	delete(Transactions, connID)
-	transactionsMu.Unlock()
+	if _, exists := ClientGet(connID); !exists {
+		return
+	}
+	updateClient(connID, func(c *Client) {
+		c.transaction = nil
+	})
+}
+
+// TransactionsClear ends the transactions of every client
+func TransactionsClear() {
+	clientsMu.RLock()
+	connIDs := make([]string, 0, len(clients))
+	for connID := range clients {
+		connIDs = append(connIDs, connID)
+	}
+	clientsMu.RUnlock()
+	for _, connID := range connIDs {
+		TransactionsDelete(connID)
+	}
 }
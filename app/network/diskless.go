@@ -0,0 +1,154 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// disklessSyncMu protects disklessPending and disklessScheduled
+var disklessSyncMu sync.Mutex
+
+// disklessPending holds the replicas waiting for the next diskless transfer
+var disklessPending []*disklessTarget
+
+// disklessScheduled is true while a transfer is waiting for its delay to expire
+var disklessScheduled bool
+
+// disklessTarget is a replica receiving a snapshot streamed from memory.
+// Write errors are recorded instead of returned, so a replica dropping out
+// doesn't abort the snapshot pass for the others. The snapshot doesn't count
+// towards the output buffer limit of the replica.
+type disklessTarget struct {
+	connID string
+	conn   net.Conn
+	eof    bool // Replica supports the EOF-marker format, so the snapshot can be streamed unbuffered
+	err    error
+}
+
+func (t *disklessTarget) Write(p []byte) (int, error) {
+	if t.err == nil {
+		t.err = WriteSnapshot(t.conn, p)
+	}
+	return len(p), nil
+}
+
+// QueueDisklessSync schedules a full resync of a replica from an in-memory snapshot.
+// Replicas asking for a sync within repl-diskless-sync-delay seconds of each other
+// are served by the same snapshot pass.
+func QueueDisklessSync(connID string, conn net.Conn) {
+	info, _ := ReplicaInfoGet(connID)
+	target := &disklessTarget{
+		connID: connID,
+		conn:   conn,
+		eof:    slices.Contains(info.Capabilities, "eof"),
+	}
+
+	disklessSyncMu.Lock()
+	defer disklessSyncMu.Unlock()
+
+	disklessPending = append(disklessPending, target)
+	if disklessScheduled {
+		return
+	}
+	disklessScheduled = true
+
+	delay := time.Duration(server.StoreState.ReplDisklessSyncDelay) * time.Second
+	time.AfterFunc(delay, runDisklessSync)
+}
+
+// runDisklessSync serializes the dataset once and streams it to every pending replica.
+// Replicas that support the EOF-marker format receive the snapshot as it is produced;
+// the others need the length upfront, so their copy is buffered in memory first.
+func runDisklessSync() {
+	disklessSyncMu.Lock()
+	targets := disklessPending
+	disklessPending = nil
+	disklessScheduled = false
+	disklessSyncMu.Unlock()
+
+	// The snapshot is taken at the offset the replicas continue from, and the writes made
+	// since are kept for them until the transfer ends
+	conns := make(map[string]net.Conn, len(targets))
+	for _, target := range targets {
+		conns[target.connID] = target.conn
+	}
+	dataset, offset := startReplicaSync(conns)
+	defer dataset.Release()
+	fullResync := protocol.Value{Typ: "string", Str: fmt.Sprintf("FULLRESYNC %s %d", server.StoreState.MasterReplID, offset)}
+	mark := eofMark()
+
+	var buffered bytes.Buffer
+	var writers []*disklessTarget
+	needsBuffer := false
+	for _, target := range targets {
+		target.Write(fullResync.Marshal())
+		if target.eof {
+			target.Write([]byte("$EOF:" + mark + "\r\n"))
+			writers = append(writers, target)
+		} else {
+			needsBuffer = true
+		}
+	}
+
+	snapshot := &disklessSnapshot{targets: writers}
+	if needsBuffer {
+		snapshot.buffer = &buffered
+	}
+	if err := dataset.WriteRDB(snapshot); err != nil {
+		replicationLog.Warningf("Failed to serialize snapshot for diskless sync: %v", err)
+	}
+
+	for _, target := range targets {
+		if target.eof {
+			target.Write([]byte(mark))
+		} else {
+			target.Write([]byte("$" + strconv.Itoa(buffered.Len()) + "\r\n"))
+			target.Write(buffered.Bytes())
+		}
+
+		if target.err != nil {
+			replicationLog.Warningf("Failed to send snapshot to replica %s: %v", target.connID, target.err)
+			target.conn.Close()
+			ReplicasDelete(target.connID)
+			continue
+		}
+		if err := FinishReplicaSync(target.connID, offset); err != nil {
+			replicationLog.Warningf("Failed to resync replica %s: %v", target.connID, err)
+			target.conn.Close()
+		}
+	}
+}
+
+// disklessSnapshot fans a single snapshot pass out to the streaming replicas
+// and to the buffer used for the length-prefixed ones
+type disklessSnapshot struct {
+	targets []*disklessTarget
+	buffer  *bytes.Buffer
+}
+
+func (s *disklessSnapshot) Write(p []byte) (int, error) {
+	for _, target := range s.targets {
+		target.Write(p)
+	}
+	if s.buffer != nil {
+		s.buffer.Write(p)
+	}
+	return len(p), nil
+}
+
+// eofMark returns the random 40 character delimiter ending an EOF-marker transfer
+func eofMark() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
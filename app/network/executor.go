@@ -0,0 +1,178 @@
+package network
+
+import (
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// With single-writer enabled, every command runs on one executor goroutine while the
+// connections only parse commands and write replies, like the single-threaded Redis. A
+// command then never interleaves with another one, so EXEC and read-modify-write commands
+// are atomic with respect to every other client, not only for the keys they lock. The store
+// keeps its locks, which the executor always takes uncontended.
+var executorJobs chan func()
+
+// executorMu protects executorJobs. Senders hold it for reading while they hand over a job,
+// so the executor can't be stopped under them.
+var executorMu sync.RWMutex
+
+// StartExecutor starts the goroutine running every command, it does nothing when it already runs
+func StartExecutor() {
+	executorMu.Lock()
+	defer executorMu.Unlock()
+	if executorJobs != nil {
+		return
+	}
+	jobs := make(chan func())
+	go func() {
+		for job := range jobs {
+			job()
+		}
+	}()
+	executorJobs = jobs
+}
+
+// StopExecutor stops the executor goroutine, commands run on their connection again
+func StopExecutor() {
+	executorMu.Lock()
+	defer executorMu.Unlock()
+	if executorJobs != nil {
+		close(executorJobs)
+		executorJobs = nil
+	}
+}
+
+// ExecutorRunning reports whether commands run on the executor goroutine
+func ExecutorRunning() bool {
+	executorMu.RLock()
+	defer executorMu.RUnlock()
+	return executorJobs != nil
+}
+
+// RunExclusive runs fn holding server.CommandLock for writing, so no command runs meanwhile.
+// It runs on the executor goroutine when it is running. fn must not call RunExclusive.
+func RunExclusive(fn func()) {
+	runOnExecutor(func() {
+		server.CommandLock.Lock()
+		defer server.CommandLock.Unlock()
+		fn()
+	})
+}
+
+// runShared runs fn holding server.CommandLock for reading, like a command, on the executor
+// goroutine when it is running
+func runShared(fn func()) {
+	runOnExecutor(func() {
+		server.CommandLock.RLock()
+		defer server.CommandLock.RUnlock()
+		fn()
+	})
+}
+
+// runOnExecutor runs fn on the executor goroutine and waits for it, or runs it right away
+// when the executor isn't running
+func runOnExecutor(fn func()) {
+	executorMu.RLock()
+	jobs := executorJobs
+	if jobs == nil {
+		executorMu.RUnlock()
+		fn()
+		return
+	}
+	done := make(chan struct{})
+	jobs <- func() {
+		defer close(done)
+		fn()
+	}
+	executorMu.RUnlock()
+	<-done
+}
+
+// CommandMayWait reports whether a command may wait before replying, or writes to the
+// connection itself: blocking commands, WAIT, FAILOVER, PSYNC and SHUTDOWN. They never run
+// on the executor, where they would hold up every other client. Neither does REPLCONF, so the
+// acknowledgements WAIT and FAILOVER wait for are never queued behind other commands.
+func CommandMayWait(command string) bool {
+	if commandHasFlag(command, CommandFlagBlocking) {
+		return true
+	}
+	return command == "WAIT" || command == "FAILOVER" || command == "PSYNC" || command == "REPLCONF" || command == "SHUTDOWN"
+}
+
+// exclusiveCommands run holding server.CommandLock for writing, so scripts and transactions
+// are atomic with respect to the other writes whether or not the executor runs. Reads don't
+// take the lock, they may see part of a transaction unless single-writer is enabled.
+// BGREWRITEAOF and CONFIG, which may start an append only file rewrite, snapshot the dataset
+// with no write half propagated.
+var exclusiveCommands = map[string]bool{
+	"EVAL":         true,
+	"EVALSHA":      true,
+	"FCALL":        true,
+	"FCALL_RO":     true,
+	"EXEC":         true,
+	"BGREWRITEAOF": true,
+	"CONFIG":       true,
+}
+
+// executeExclusive runs ExecuteAndPropagate on the executor goroutine. Streamed replies are
+// collected there too, before another command can change what they read.
+func executeExclusive(command string, c *Client, args []protocol.Value) protocol.Value {
+	var result protocol.Value
+	runOnExecutor(func() {
+		result = executeAndPropagate(command, c, args).Materialize()
+	})
+	return result
+}
+
+// commandLockHolders are the connections running a blocking command while they hold
+// server.CommandLock for reading, which they release while they wait
+var commandLockHolders = make(map[string]bool)
+var commandLockHoldersMu sync.Mutex
+
+// lockCommand takes server.CommandLock for a command about to run and returns the function
+// releasing it. Only write commands, which are propagated, and the exclusive ones take it:
+// reads run without it, so they scale with the shards of the store. Commands that wait
+// without changing the dataset, like WAIT and PSYNC, run without it too: PSYNC takes it for
+// writing itself. So do SCRIPT KILL and FUNCTION KILL, which stop a script holding it.
+func lockCommand(command string, connID string, args []protocol.Value) func() {
+	if CommandMayWait(command) && !commandHasFlag(command, CommandFlagBlocking) || KillsScript(command, args) {
+		return func() {}
+	}
+	if exclusiveCommands[command] {
+		server.CommandLock.Lock()
+		return server.CommandLock.Unlock
+	}
+	if !IsWriteCommand(command) {
+		return func() {}
+	}
+	server.CommandLock.RLock()
+	if !commandHasFlag(command, CommandFlagBlocking) {
+		return server.CommandLock.RUnlock
+	}
+	commandLockHoldersMu.Lock()
+	commandLockHolders[connID] = true
+	commandLockHoldersMu.Unlock()
+	return func() {
+		commandLockHoldersMu.Lock()
+		delete(commandLockHolders, connID)
+		commandLockHoldersMu.Unlock()
+		server.CommandLock.RUnlock()
+	}
+}
+
+// unlockWhileWaiting runs wait without the command lock a blocking command of connID holds,
+// so a snapshot taken meanwhile doesn't wait for the command to be served
+func unlockWhileWaiting(connID string, wait func()) {
+	commandLockHoldersMu.Lock()
+	holds := commandLockHolders[connID]
+	commandLockHoldersMu.Unlock()
+	if !holds {
+		wait()
+		return
+	}
+	server.CommandLock.RUnlock()
+	defer server.CommandLock.RLock()
+	wait()
+}
@@ -0,0 +1,257 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// failoverLog logs the messages of the failover subsystem
+var failoverLog = logger.New("failover")
+
+// Failover states, as reported by INFO in master_failover_state
+const (
+	FailoverNone        = "no-failover"
+	FailoverWaitForSync = "waiting-for-sync"
+	FailoverInProgress  = "failover-in-progress"
+)
+
+// failoverMu protects failoverState and failoverAbort
+var failoverMu sync.Mutex
+var failoverState = FailoverNone
+
+// failoverAbort is closed by FAILOVER ABORT while waiting for the target to catch up
+var failoverAbort chan struct{}
+
+// Write pause used during a failover, so the target replica can catch up on the offset
+var writesPausedMu sync.Mutex
+var writesPausedCond = sync.NewCond(&writesPausedMu)
+var writesPaused bool
+
+// replicationPort and replicationExecute are what this server needs to connect to a master.
+// They are recorded at startup so a master demoted by FAILOVER can become a replica.
+var replicationPort string
+var replicationExecute func(string, string, []protocol.Value) protocol.Value
+
+// FailoverOptions holds the parsed arguments of a FAILOVER command
+type FailoverOptions struct {
+	Host    string        // Target replica host, empty to pick the first replica that catches up
+	Port    string        // Target replica listening port
+	Timeout time.Duration // Maximum time to wait for the target, 0 waits forever
+	Force   bool          // Fail over to the target even if it didn't catch up before the timeout
+}
+
+// FailoverStateGet returns the current failover state
+func FailoverStateGet() string {
+	failoverMu.Lock()
+	defer failoverMu.Unlock()
+	return failoverState
+}
+
+// PauseWrites makes write commands wait until UnpauseWrites is called
+func PauseWrites() {
+	writesPausedMu.Lock()
+	writesPaused = true
+	writesPausedMu.Unlock()
+}
+
+// UnpauseWrites releases every write command waiting on the pause
+func UnpauseWrites() {
+	writesPausedMu.Lock()
+	writesPaused = false
+	writesPausedMu.Unlock()
+	writesPausedCond.Broadcast()
+}
+
+// WritesPaused reports whether write commands are currently paused
+func WritesPaused() bool {
+	writesPausedMu.Lock()
+	defer writesPausedMu.Unlock()
+	return writesPaused
+}
+
+// waitWritesUnpaused blocks until writes are no longer paused
+func waitWritesUnpaused() {
+	writesPausedMu.Lock()
+	for writesPaused {
+		writesPausedCond.Wait()
+	}
+	writesPausedMu.Unlock()
+}
+
+// pausedByFailover reports whether a command waits while writes are paused for the target
+// of a failover to catch up: writes, transactions queuing a write and the scripts that may
+// write, like Redis pauses them
+func pausedByFailover(command string, connID string) bool {
+	switch command {
+	case "EXEC":
+		transaction, _ := TransactionsGet(connID)
+		for _, queued := range transaction.Commands {
+			if IsWriteCommand(queued.Command) {
+				return true
+			}
+		}
+		return false
+	case "EVAL", "EVALSHA", "FCALL":
+		return true
+	}
+	return IsWriteCommand(command)
+}
+
+// StartFailover validates a FAILOVER request, pauses writes and hands the master role
+// to a replica in the background once it has caught up on the replication offset.
+func StartFailover(opts FailoverOptions) error {
+	failoverMu.Lock()
+	defer failoverMu.Unlock()
+
+	if server.StoreState.Role != "master" {
+		return fmt.Errorf("ERR FAILOVER is not valid when server is a replica.")
+	}
+	if failoverState != FailoverNone {
+		return fmt.Errorf("ERR FAILOVER already in progress.")
+	}
+	if len(ReplicaIDs()) == 0 {
+		return fmt.Errorf("ERR FAILOVER requires connected replicas.")
+	}
+	if opts.Force && (opts.Host == "" || opts.Timeout == 0) {
+		return fmt.Errorf("ERR FAILOVER with force option requires both a timeout and target HOST and IP.")
+	}
+	if opts.Host != "" && findFailoverTarget(opts.Host, opts.Port, -1) == "" {
+		return fmt.Errorf("ERR FAILOVER target HOST and PORT is not a replica.")
+	}
+
+	failoverState = FailoverWaitForSync
+	failoverAbort = make(chan struct{})
+	PauseWrites()
+
+	go runFailover(opts, failoverAbort)
+	return nil
+}
+
+// AbortFailover cancels a failover that is still waiting for its target to catch up
+func AbortFailover() error {
+	failoverMu.Lock()
+	defer failoverMu.Unlock()
+
+	switch failoverState {
+	case FailoverNone:
+		return fmt.Errorf("ERR No failover in progress.")
+	case FailoverInProgress:
+		return fmt.Errorf("ERR FAILOVER can't be aborted once the target is being promoted.")
+	}
+
+	close(failoverAbort)
+	failoverAbort = nil
+	failoverState = FailoverNone
+	UnpauseWrites()
+	return nil
+}
+
+// findFailoverTarget returns the connection ID of a replica matching host and port
+// (any replica when host is empty) that acknowledged at least minOffset, or "" if none does.
+func findFailoverTarget(host, port string, minOffset int64) string {
+	for _, replicaID := range ReplicaIDs() {
+		info, ok := ReplicaInfoGet(replicaID)
+		if !ok {
+			continue
+		}
+		if host != "" && (info.IP != host || info.ListeningPort != port) {
+			continue
+		}
+		if minOffset >= 0 && (info.LastAck == 0 || info.AckOffset < minOffset) {
+			continue
+		}
+		return replicaID
+	}
+	return ""
+}
+
+// runFailover waits for a replica to reach the master offset, then demotes this server
+// and connects to the target with PSYNC ... FAILOVER, which makes the target promote itself.
+func runFailover(opts FailoverOptions, abort <-chan struct{}) {
+	// Writes are paused, so this is the offset the target has to reach
+	targetOffset := atomic.LoadInt64(&server.StoreState.MasterReplOffset)
+	SendReplconfGetack()
+
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(opts.Timeout)
+	}
+
+	var targetID string
+	for targetID == "" {
+		select {
+		case <-abort:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		targetID = findFailoverTarget(opts.Host, opts.Port, targetOffset)
+		if targetID == "" && !deadline.IsZero() && time.Now().After(deadline) {
+			if !opts.Force {
+				failoverLog.Warningf("FAILOVER timed out before the target replica caught up, aborting")
+				AbortFailover()
+				return
+			}
+			targetID = findFailoverTarget(opts.Host, opts.Port, -1)
+			if targetID == "" {
+				failoverLog.Warningf("FAILOVER target replica disconnected, aborting")
+				AbortFailover()
+				return
+			}
+		}
+	}
+
+	failoverMu.Lock()
+	select {
+	case <-abort:
+		failoverMu.Unlock()
+		return
+	default:
+	}
+	failoverState = FailoverInProgress
+	failoverMu.Unlock()
+
+	target, _ := ReplicaInfoGet(targetID)
+	demoteToReplica(target.IP, target.ListeningPort)
+
+	failoverMu.Lock()
+	failoverState = FailoverNone
+	failoverAbort = nil
+	failoverMu.Unlock()
+
+	// Writes blocked during the failover now see that this server is a replica
+	UnpauseWrites()
+}
+
+// demoteToReplica turns this master into a replica of host:port.
+// All replicas are disconnected; they are expected to follow the new master.
+func demoteToReplica(host, port string) {
+	server.StoreState.Role = "slave"
+	server.StoreState.ReplicaOf = host + " " + port
+
+	for _, replicaID := range ReplicaIDs() {
+		if conn, ok := ReplicasGet(replicaID); ok {
+			conn.Close()
+		}
+		ReplicasDelete(replicaID)
+	}
+
+	go performReplicationHandshake(net.JoinHostPort(host, port), replicationPort, true, replicationExecute)
+}
+
+// PromoteToMaster turns this replica into a master, dropping the link to its current master.
+// The replication ID and offset are kept so the old master can follow this server.
+func PromoteToMaster() {
+	if conn := masterLinkGet(); conn != nil {
+		conn.Close()
+	}
+	server.StoreState.Role = "master"
+	server.StoreState.ReplicaOf = ""
+}
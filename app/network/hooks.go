@@ -0,0 +1,89 @@
+package network
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// hooksLog logs the errors of the hooks run after commands
+var hooksLog = logger.New("hooks")
+
+// CommandHook intercepts a command run by a connection, with its uppercase name and its
+// arguments. Commands run by scripts, transactions, the link to the master and the loading
+// of the append only file go through the hooks too.
+type CommandHook func(connID string, command string, args []protocol.Value) error
+
+// commandHooks holds the hooks run before and after commands, replaced as a whole when a hook
+// is added so commands read them without a lock
+type commandHooks struct {
+	before []CommandHook
+	after  []CommandHook
+}
+
+// hooksMu serializes the changes to hooks
+var hooksMu sync.Mutex
+var hooks atomic.Pointer[commandHooks]
+
+func init() {
+	hooks.Store(&commandHooks{})
+}
+
+// AddBeforeCommandHook registers a hook run before every command allowed to run. A hook
+// returning an error refuses the command, the error is replied to the client: its message
+// should start with an error code like ERR.
+func AddBeforeCommandHook(hook CommandHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	current := hooks.Load()
+	hooks.Store(&commandHooks{before: append(append([]CommandHook(nil), current.before...), hook), after: current.after})
+}
+
+// AddAfterCommandHook registers a hook run after every command, the errors it returns are logged
+func AddAfterCommandHook(hook CommandHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	current := hooks.Load()
+	hooks.Store(&commandHooks{before: current.before, after: append(append([]CommandHook(nil), current.after...), hook)})
+}
+
+// ClearCommandHooks removes every hook
+func ClearCommandHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks.Store(&commandHooks{})
+}
+
+// runBeforeHooks runs the hooks registered before commands, and returns the error replied
+// when one refuses the command or an empty string
+func runBeforeHooks(command string, connID string, args []protocol.Value) string {
+	for _, hook := range hooks.Load().before {
+		if err := hook(connID, command, args); err != nil {
+			return hookErrorReply(err)
+		}
+	}
+	return ""
+}
+
+// runAfterHooks runs the hooks registered after commands
+func runAfterHooks(command string, connID string, args []protocol.Value) {
+	for _, hook := range hooks.Load().after {
+		if err := hook(connID, command, args); err != nil {
+			hooksLog.Warningf("Hook failed after %s from %s: %v", command, connID, err)
+		}
+	}
+}
+
+// hookErrorReply returns the error replied for a command a hook refused, prefixed with ERR
+// unless the message starts with an uppercase error code
+func hookErrorReply(err error) string {
+	message := err.Error()
+	code, _, _ := strings.Cut(message, " ")
+	if code == "" || strings.ToUpper(code) != code || strings.ToLower(code) == code {
+		return "ERR " + message
+	}
+	return message
+}
@@ -0,0 +1,254 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// closeSendTimeout is how long a connection closed after its output was sent waits for the
+// client to read it
+const closeSendTimeout = 10 * time.Second
+
+// errOutputBufferLimit is returned by the writes to a client disconnected for going over
+// its output buffer limit
+var errOutputBufferLimit = errors.New("output buffer limit reached")
+
+// outputConn is the socket of a client with its output buffer. The replies, published messages
+// and replication stream written to it are queued and sent by a goroutine of their own, so
+// nothing waits for a client reading slower than it is sent data: its output piles up in the
+// queue instead, until it goes over the client-output-buffer-limit of its class.
+type outputConn struct {
+	net.Conn
+	connID string
+
+	mu           sync.Mutex
+	cond         *sync.Cond // Signaled when output is queued or sent, and when the connection closes
+	queue        []outputChunk
+	pending      int64     // Bytes queued and not sent yet, snapshots excepted
+	queued       int64     // Chunks queued since the connection was accepted
+	sent         int64     // Chunks sent since the connection was accepted
+	softSince    time.Time // When pending went over the soft limit, zero while under it
+	closing      bool      // Whether the socket is closed once the queue is sent
+	closed       bool
+	limitReached bool // Whether the client was disconnected for going over its limit
+}
+
+// outputChunk is a write queued on a connection
+type outputChunk struct {
+	data    []byte
+	counted bool // Whether it counts towards the output buffer limit, snapshots don't
+}
+
+// TrackOutputBuffer returns the socket of a client with an output buffer, which disconnects
+// it once its output goes over the client-output-buffer-limit of its class
+func TrackOutputBuffer(connID string, conn net.Conn) net.Conn {
+	c := &outputConn{Conn: conn, connID: connID}
+	c.cond = sync.NewCond(&c.mu)
+	go c.sendLoop()
+	return c
+}
+
+// Write queues p to be sent to the client, it doesn't wait for the client to read it
+func (c *outputConn) Write(p []byte) (int, error) {
+	limit := clientOutputLimit(c.connID)
+
+	c.mu.Lock()
+	if c.closed || c.closing {
+		c.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	c.queue = append(c.queue, outputChunk{data: append([]byte(nil), p...), counted: true})
+	c.queued++
+	c.pending += int64(len(p))
+	over := c.overLimit(limit, time.Now())
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	if over {
+		c.disconnect()
+		return 0, errOutputBufferLimit
+	}
+	return len(p), nil
+}
+
+// Close closes the socket right away, dropping the output not sent yet
+func (c *outputConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.queue = nil
+	c.pending = 0
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// sendLoop sends the queued output to the client until the connection is closed
+func (c *outputConn) sendLoop() {
+	for {
+		c.mu.Lock()
+		for len(c.queue) == 0 && !c.closing && !c.closed {
+			c.cond.Wait()
+		}
+		if c.closed || len(c.queue) == 0 {
+			c.mu.Unlock()
+			c.Close()
+			return
+		}
+		chunks := c.queue
+		c.queue = nil
+		c.mu.Unlock()
+
+		buffers := make(net.Buffers, len(chunks))
+		counted := int64(0)
+		for i, chunk := range chunks {
+			buffers[i] = chunk.data
+			if chunk.counted {
+				counted += int64(len(chunk.data))
+			}
+		}
+		_, err := buffers.WriteTo(c.Conn)
+
+		c.mu.Lock()
+		c.pending -= counted
+		if c.pending < 0 {
+			c.pending = 0
+		}
+		if err == nil {
+			c.sent += int64(len(chunks))
+		}
+		c.cond.Broadcast()
+		c.mu.Unlock()
+		if err != nil {
+			c.Close()
+			return
+		}
+	}
+}
+
+// overLimit reports whether the pending output goes over the hard limit, or stayed over the
+// soft limit for longer than allowed. c.mu must be held.
+func (c *outputConn) overLimit(limit shared.OutputBufferLimit, now time.Time) bool {
+	if limit.Hard > 0 && c.pending >= limit.Hard {
+		return true
+	}
+	if limit.Soft > 0 && c.pending >= limit.Soft {
+		if c.softSince.IsZero() {
+			c.softSince = now
+		}
+		return now.Sub(c.softSince) > time.Duration(limit.SoftSeconds)*time.Second
+	}
+	c.softSince = time.Time{}
+	return false
+}
+
+// disconnect closes the connection of a client that went over its output buffer limit
+func (c *outputConn) disconnect() {
+	c.mu.Lock()
+	reached := c.limitReached
+	c.limitReached = true
+	c.mu.Unlock()
+	if reached {
+		return
+	}
+
+	server.OutputBufferLimitDisconnection()
+	clientsLog.Warningf("Client %s closed for overcoming of output buffer limits", c.connID)
+	ClientKill(c.connID, false)
+	c.Close()
+}
+
+// clientOutputLimit returns the output buffer limit of the class a client belongs to: slave
+// for replicas, pubsub for subscribers and normal for the others, monitors included
+func clientOutputLimit(connID string) shared.OutputBufferLimit {
+	class := "normal"
+	if _, isReplica := ReplicasGet(connID); isReplica {
+		class = "slave"
+	} else if SubscribedModeGet(connID) {
+		class = "pubsub"
+	}
+	return server.StoreState.ClientOutputBufferLimits[class]
+}
+
+// CloseAfterSent closes a connection once the output queued on it was sent, or after
+// closeSendTimeout when the client doesn't read it
+func CloseAfterSent(conn net.Conn) error {
+	c, ok := conn.(*outputConn)
+	if !ok {
+		return conn.Close()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closing = true
+	c.cond.Broadcast()
+	return c.Conn.SetWriteDeadline(time.Now().Add(closeSendTimeout))
+}
+
+// WriteSnapshot sends a snapshot to a replica after the output queued before it, and waits
+// until it was sent. Snapshots don't count towards the output buffer limit of the replica.
+func WriteSnapshot(conn net.Conn, p []byte) error {
+	c, ok := conn.(*outputConn)
+	if !ok {
+		_, err := conn.Write(p)
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.closing {
+		return net.ErrClosed
+	}
+	c.queue = append(c.queue, outputChunk{data: p})
+	c.queued++
+	chunk := c.queued
+	c.cond.Broadcast()
+	for c.sent < chunk && !c.closed {
+		c.cond.Wait()
+	}
+	if c.sent < chunk {
+		return net.ErrClosed
+	}
+	return nil
+}
+
+// OutputBufferPending returns the bytes queued on a client connection that were not sent yet
+func OutputBufferPending(connID string) int64 {
+	if conn, ok := ConnectionsGet(connID); ok {
+		if c, ok := conn.(*outputConn); ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.pending
+		}
+	}
+	return 0
+}
+
+// CloseOutputBufferOffenders disconnects the clients whose output stayed over the soft limit
+// of their class for too long while nothing more was written to them, and returns how many
+// were closed
+func CloseOutputBufferOffenders(now time.Time) int {
+	closed := 0
+	for _, connID := range ClientIDs() {
+		conn, ok := ConnectionsGet(connID)
+		if !ok {
+			continue
+		}
+		c, ok := conn.(*outputConn)
+		if !ok {
+			continue
+		}
+		limit := clientOutputLimit(connID)
+		c.mu.Lock()
+		over := c.pending > 0 && c.overLimit(limit, now)
+		c.mu.Unlock()
+		if over {
+			c.disconnect()
+			closed++
+		}
+	}
+	return closed
+}
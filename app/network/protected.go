@@ -0,0 +1,77 @@
+package network
+
+import (
+	"net"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// protectedModeError is replied to remote clients while protected mode is active
+const protectedModeError = "DENIED Redis is running in protected mode because protected mode is enabled and " +
+	"no password is set for the default user. In this mode connections are only accepted from the loopback interface. " +
+	"If you want to connect from external computers to Redis you may adopt one of the following solutions: " +
+	"1) Just disable protected mode sending the command 'CONFIG SET protected-mode no' from the loopback interface " +
+	"by connecting to Redis from the same host the server is running, however MAKE SURE Redis is not publicly accessible " +
+	"from internet if you do so. Use CONFIG REWRITE to make this change permanent. " +
+	"2) Alternatively you can just disable the protected mode by editing the Redis configuration file, and setting " +
+	"the protected mode option to 'no', and then restarting the server. " +
+	"3) If you started the server manually just for testing, restart it with the '--protected-mode no' option. " +
+	"4) Set up an authentication password for the default user. " +
+	"NOTE: You only need to do one of the above things in order for the server to start accepting connections from the outside."
+
+// ProtectedModeActive reports whether only loopback clients are served: protected mode is on,
+// no bind address was configured and the default user has no password
+func ProtectedModeActive() bool {
+	return server.StoreState.ProtectedMode && server.StoreState.Bind == "" && ACLDefaultUserAuthenticates()
+}
+
+// protectedModeCheck returns the error replied to a client refused by protected mode, or an
+// empty string when it may run commands. Connections without client metadata are not checked.
+func protectedModeCheck(connID string) string {
+	if !ProtectedModeActive() {
+		return ""
+	}
+	info, ok := ClientInfoGet(connID)
+	if !ok || isLoopbackAddr(info.Addr) {
+		return ""
+	}
+	return protectedModeError
+}
+
+// isLoopbackAddr reports whether a host:port address is on the loopback interface.
+// Addresses that are not IP addresses, like those of in-memory pipes, are local.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || ip.IsLoopback()
+}
+
+// BindAddresses returns the host:port addresses to listen on for a bind list like "127.0.0.1 ::1".
+// "*" stands for every IPv4 address and "::*" for every IPv6 address, an empty list listens
+// on every address. Addresses prefixed with "-" are optional: failing to listen on them is
+// not fatal.
+func BindAddresses(bind, port string) (addresses []string, optional []bool) {
+	fields := []string{"*"}
+	if bind != "" {
+		fields = strings.Fields(bind)
+	}
+	for _, field := range fields {
+		isOptional := len(field) > 1 && field[0] == '-'
+		if isOptional {
+			field = field[1:]
+		}
+		switch field {
+		case "*":
+			field = "0.0.0.0"
+		case "::*":
+			field = "::"
+		}
+		addresses = append(addresses, net.JoinHostPort(field, port))
+		optional = append(optional, isOptional)
+	}
+	return addresses, optional
+}
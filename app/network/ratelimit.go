@@ -0,0 +1,105 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// rateBucket is a token bucket refilled at a rate per second, holding at most a second of it,
+// so a client may send a second worth of commands at once before being slowed down
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allows refills the bucket up to limit, and reports whether it holds n tokens. A limit of
+// 0 allows anything.
+func (b *rateBucket) allows(n float64, limit int64, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+	if b.last.IsZero() {
+		b.tokens = float64(limit)
+	} else {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*float64(limit), float64(limit))
+	}
+	b.last = now
+	// A command larger than the whole bucket is let through once the bucket is full
+	return b.tokens >= min(n, float64(limit))
+}
+
+// take removes n tokens when the bucket is limited. It may go below zero for a command larger
+// than the limit, which then waits for the bucket to refill.
+func (b *rateBucket) take(n float64, limit int64) {
+	if limit > 0 {
+		b.tokens -= n
+	}
+}
+
+// rateLimits holds the buckets limiting a client or the whole server
+type rateLimits struct {
+	commands rateBucket
+	bytes    rateBucket
+}
+
+// globalRateMu protects globalRate
+var globalRateMu sync.Mutex
+
+// globalRate limits the commands of every client together
+var globalRate rateLimits
+
+// ResetRateLimits refills the buckets of the server-wide limits, so a changed limit applies
+// from a full second of commands
+func ResetRateLimits() error {
+	globalRateMu.Lock()
+	defer globalRateMu.Unlock()
+	globalRate = rateLimits{}
+	return nil
+}
+
+// RateLimited returns the error replied to a command read from the client when it goes over
+// the rate limits, or an empty string when it may run. client-rate-limit-commands and
+// client-rate-limit-bytes limit the commands and the bytes of arguments a client sends per
+// second, rate-limit-commands and rate-limit-bytes those of every client together: a client
+// over its own limits gets an ERR, and any client gets a BUSY while the server is over its
+// limits. Refused commands don't count against the limits.
+func (c *Client) RateLimited(command string, args []protocol.Value) string {
+	state := server.StoreState
+	if state.ClientRateLimitCommands <= 0 && state.ClientRateLimitBytes <= 0 && state.RateLimitCommands <= 0 && state.RateLimitBytes <= 0 {
+		return ""
+	}
+
+	size := len(command)
+	for _, arg := range args {
+		size += len(arg.Bulk)
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.rate.commands.allows(1, int64(state.ClientRateLimitCommands), now) {
+		server.RateLimitedCommand()
+		return fmt.Sprintf("ERR rate limit exceeded, this client may send %d commands per second", state.ClientRateLimitCommands)
+	}
+	if !c.rate.bytes.allows(float64(size), state.ClientRateLimitBytes, now) {
+		server.RateLimitedCommand()
+		return fmt.Sprintf("ERR rate limit exceeded, this client may send %d bytes per second", state.ClientRateLimitBytes)
+	}
+
+	globalRateMu.Lock()
+	defer globalRateMu.Unlock()
+	if !globalRate.commands.allows(1, int64(state.RateLimitCommands), now) || !globalRate.bytes.allows(float64(size), state.RateLimitBytes, now) {
+		server.RateLimitedCommand()
+		return "BUSY the server is over its rate limit, try again later"
+	}
+
+	c.rate.commands.take(1, int64(state.ClientRateLimitCommands))
+	c.rate.bytes.take(float64(size), state.ClientRateLimitBytes)
+	globalRate.commands.take(1, int64(state.RateLimitCommands))
+	globalRate.bytes.take(float64(size), state.RateLimitBytes)
+	return ""
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// renamedCommands maps the names given with rename-command to the commands they run
+var renamedCommands = make(map[string]string)
+
+// hiddenCommands holds the commands renamed or disabled with rename-command,
+// which clients can't call by their own name anymore
+var hiddenCommands = make(map[string]bool)
+
+// RenameCommand makes clients call a command by a new name, an empty name disables it.
+// Renames are applied at startup, before clients connect. Internal callers, like the link
+// to the master and the append only file loader, keep running commands by their own name.
+func RenameCommand(name, newName string) error {
+	name, newName = strings.ToUpper(name), strings.ToUpper(newName)
+	if _, ok := CommandHandlers[name]; !ok || hiddenCommands[name] {
+		return fmt.Errorf("No such command in rename-command")
+	}
+	if newName != "" {
+		if _, ok := renamedCommands[newName]; ok {
+			return fmt.Errorf("Target command name already exists")
+		}
+		if _, ok := CommandHandlers[newName]; ok && !hiddenCommands[newName] {
+			return fmt.Errorf("Target command name already exists")
+		}
+		renamedCommands[newName] = name
+	}
+	hiddenCommands[name] = true
+	return nil
+}
+
+// ResetCommandRenames drops every rename, as if the server started without rename-command
+func ResetCommandRenames() {
+	renamedCommands = make(map[string]string)
+	hiddenCommands = make(map[string]bool)
+}
+
+// ResolveCommand returns the command a client runs by calling name, uppercase. It reports
+// false when name is a command renamed or disabled with rename-command.
+func ResolveCommand(name string) (string, bool) {
+	name = strings.ToUpper(name)
+	if command, ok := renamedCommands[name]; ok {
+		return command, true
+	}
+	return name, !hiddenCommands[name]
+}
+
+// ClientCommandName returns the lowercase name clients call a command by, which is its new
+// name when it was renamed. It reports false when the command is disabled.
+func ClientCommandName(command string) (string, bool) {
+	command = strings.ToUpper(command)
+	if !hiddenCommands[command] {
+		return strings.ToLower(command), true
+	}
+	for newName, renamed := range renamedCommands {
+		if renamed == command {
+			return strings.ToLower(newName), true
+		}
+	}
+	return "", false
+}
+
+// UnknownCommandError returns the error replied to a command the server does not know
+func UnknownCommandError(command string, args []protocol.Value) string {
+	var quoted strings.Builder
+	for _, arg := range args {
+		fmt.Fprintf(&quoted, "'%s' ", arg.Bulk)
+	}
+	return fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", command, quoted.String())
+}
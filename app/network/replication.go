@@ -1,74 +1,228 @@
 package network
 
 import (
-	"fmt"
+	"io"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
 	"github.com/codecrafters-io/redis-starter-go/app/protocol"
 	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+	"github.com/codecrafters-io/redis-starter-go/app/storage"
 )
 
+// replicationLog logs the messages of the replication subsystem
+var replicationLog = logger.New("replication")
+
 // Mutexes to protect concurrent access to replication data
 var replicasMu sync.RWMutex
 var acknowledgedReplicasMu sync.RWMutex
+var replicaInfosMu sync.RWMutex
 
 // AcknowledgedReplicas tracks which replicas have acknowledged commands
 var AcknowledgedReplicas = make(map[string]bool)
 
+// ReplicaInfos holds the handshake details advertised by each replica.
+// The key is the connection ID.
+var ReplicaInfos = make(map[string]*shared.ReplicaInfo)
+
+// syncingReplicas are the replicas receiving their snapshot, protected by replicasMu
+var syncingReplicas = make(map[string]*replicaSync)
+
+// masterLink is the connection a replica receives the replication stream on
+var masterLinkMu sync.RWMutex
+var masterLink net.Conn
+
+// IsStaleCommand checks if a command may run on a replica whose link to the master is down,
+// even when replica-serve-stale-data is disabled
+func IsStaleCommand(command string) bool {
+	return commandHasFlag(command, CommandFlagStale)
+}
+
 // IsWriteCommand checks if a command modifies data and should be propagated to replicas
 func IsWriteCommand(command string) bool {
-	writeCommands := map[string]bool{
-		"SET":     true,
-		"LPUSH":   true,
-		"RPUSH":   true,
-		"LPOP":    true,
-		"BLPOP":   true,
-		"INCR":    true,
-		"XADD":    true,
-		"MULTI":   true,
-		"EXEC":    true,
-		"DISCARD": true,
-	}
-	return writeCommands[command]
-}
-
-// PropagateCommand sends a command to all connected replicas
+	return commandHasFlag(command, CommandFlagWrite)
+}
+
+// PropagationRewriter rewrites a command into the deterministic form replicas should apply.
+// It receives the original arguments and the result returned to the client, and returns
+// the command to propagate. Returning false skips propagation entirely.
+type PropagationRewriter func(args []protocol.Value, result protocol.Value) (string, []protocol.Value, bool)
+
+// PropagationRewriters maps command names to their propagation rewriters.
+// Commands without a rewriter are propagated verbatim.
+var PropagationRewriters = make(map[string]PropagationRewriter)
+
+// RewriteForPropagation returns the effect of a command as it should be sent to replicas.
+// For example SET ... PX becomes SET ... PXAT so replicas compute the same expiration.
+func RewriteForPropagation(command string, args []protocol.Value, result protocol.Value) (string, []protocol.Value, bool) {
+	if rewriter, ok := PropagationRewriters[command]; ok {
+		return rewriter(args, result)
+	}
+	return command, args, true
+}
+
+// PropagateEffects rewrites a command with RewriteForPropagation and sends the result to all replicas
+func PropagateEffects(command string, args []protocol.Value, result protocol.Value) {
+	if rewritten, rewrittenArgs, ok := RewriteForPropagation(command, args, result); ok {
+		PropagateCommand(rewritten, rewrittenArgs)
+	}
+}
+
+// PropagateCommand sends a command to all connected replicas and to the append only file
 func PropagateCommand(command string, args []protocol.Value) {
+	// Encoded once into a pooled buffer shared by the append only file and every replica,
+	// all of which write it before returning
+	buf := protocol.GetBuffer()
+	defer protocol.PutBuffer(buf)
+	*buf = protocol.AppendCommand(*buf, command, args)
+	bytes := *buf
+	storage.FeedAppendOnlyFile(bytes)
+
 	if server.StoreState.Role != "master" {
 		return
 	}
+	feedReplicas(bytes)
+}
 
-	// Build the command array for propagation
-	commandArray := make([]protocol.Value, len(args)+1)
-	commandArray[0] = protocol.Value{Typ: "bulk", Bulk: command}
-	for i, arg := range args {
-		commandArray[i+1] = arg
-	}
-
-	// Snapshot replicas under read lock to avoid concurrent map iteration/writes
+// feedReplicas sends a part of the replication stream to every replica and advances the
+// replication offset by its size. A replica feeds the stream of its master to its own
+// replicas the same way, so they all count the same offsets.
+func feedReplicas(bytes []byte) {
+	// Snapshot replicas under read lock to avoid concurrent map iteration/writes. The offset
+	// advances under it too, so a replica starting its sync counts this part or receives it.
 	replicasMu.RLock()
 	snapshot := make(map[string]net.Conn, len(server.StoreState.Replicas))
 	for id, c := range server.StoreState.Replicas {
 		snapshot[id] = c
 	}
+	syncing := make(map[string]*replicaSync, len(syncingReplicas))
+	for id, rs := range syncingReplicas {
+		syncing[id] = rs
+	}
+	atomic.AddInt64(&server.StoreState.MasterReplOffset, int64(len(bytes)))
 	replicasMu.RUnlock()
 
+	kept := make(map[*syncBacklog]bool, len(syncing))
+	for replicaID, rs := range syncing {
+		if err := rs.feed(bytes, kept); err != nil {
+			ReplicasDelete(replicaID)
+			replicationLog.Warningf("Failed to propagate command to replica %s: %v", replicaID, err)
+		}
+	}
+
 	// Send to all replicas using the snapshot
 	for replicaID, replicaConn := range snapshot {
-		bytes := protocol.Value{Typ: "array", Array: commandArray}.Marshal()
 		_, err := replicaConn.Write(bytes)
 		if err != nil {
 			// Remove failed replica connection
 			ReplicasDelete(replicaID)
-			fmt.Printf("Failed to propagate command to replica %s: %v\n", replicaID, err)
+			replicationLog.Warningf("Failed to propagate command to replica %s: %v", replicaID, err)
 		}
 	}
 }
 
+// syncBacklog is the replication stream that follows a snapshot, kept for the replicas the
+// snapshot is sent to. The replicas of a diskless sync share the snapshot, so they share a
+// single copy of the stream rather than one each.
+type syncBacklog struct {
+	mu     sync.Mutex
+	stream []byte
+}
+
+// replicaSync is a replica receiving its snapshot. The replication stream that follows the
+// snapshot is kept in its backlog until the snapshot is sent, then the replica receives it
+// as it comes.
+type replicaSync struct {
+	conn    net.Conn
+	backlog *syncBacklog
+	synced  bool // Protected by the lock of the backlog
+}
+
+// feed keeps a part of the replication stream until the snapshot is sent, or sends it. kept
+// holds the backlogs the part was already added to, for the other replicas sharing them.
+func (s *replicaSync) feed(bytes []byte, kept map[*syncBacklog]bool) error {
+	s.backlog.mu.Lock()
+	defer s.backlog.mu.Unlock()
+	if !s.synced {
+		if !kept[s.backlog] {
+			s.backlog.stream = append(s.backlog.stream, bytes...)
+			kept[s.backlog] = true
+		}
+		return nil
+	}
+	_, err := s.conn.Write(bytes)
+	return err
+}
+
+// StartReplicaSync takes the snapshot a replica is resynchronized from and the replication
+// offset it was taken at, with no write half done, and keeps the replication stream that
+// follows it for the replica. FinishReplicaSync sends that stream once the snapshot is sent.
+// The snapshot must be released.
+func StartReplicaSync(connID string, conn net.Conn) (*storage.Snapshot, int64) {
+	return startReplicaSync(map[string]net.Conn{connID: conn})
+}
+
+// startReplicaSync starts the sync of several replicas served by the same snapshot
+func startReplicaSync(conns map[string]net.Conn) (snapshot *storage.Snapshot, offset int64) {
+	RunExclusive(func() {
+		snapshot = storage.TakeSnapshot()
+		replicasMu.Lock()
+		defer replicasMu.Unlock()
+		offset = atomic.LoadInt64(&server.StoreState.MasterReplOffset)
+		backlog := &syncBacklog{}
+		for connID, conn := range conns {
+			syncingReplicas[connID] = &replicaSync{conn: conn, backlog: backlog}
+		}
+	})
+	return snapshot, offset
+}
+
+// FinishReplicaSync sends a replica that received its snapshot the replication stream
+// kept since, then registers it for propagation. A replica that can't be sent the stream
+// is dropped.
+func FinishReplicaSync(connID string, offset int64) error {
+	replicasMu.RLock()
+	rs, ok := syncingReplicas[connID]
+	replicasMu.RUnlock()
+	if !ok {
+		return net.ErrClosed
+	}
+
+	// The stream stays in the backlog for the replicas still receiving the snapshot, and is
+	// freed with it once they all left the syncing replicas
+	rs.backlog.mu.Lock()
+	var err error
+	if len(rs.backlog.stream) > 0 {
+		_, err = rs.conn.Write(rs.backlog.stream)
+	}
+	rs.synced = true
+	rs.backlog.mu.Unlock()
+	if err != nil {
+		ReplicasDelete(connID)
+		return err
+	}
+
+	replicasMu.Lock()
+	if syncingReplicas[connID] == rs {
+		delete(syncingReplicas, connID)
+		server.StoreState.Replicas[connID] = rs.conn
+	}
+	replicasMu.Unlock()
+	ReplicaInfoUpdate(connID, func(info *shared.ReplicaInfo) {
+		info.AckOffset = offset
+		info.LastAck = time.Now().UnixMilli()
+	})
+	return nil
+}
+
 // Replicas helpers
 func ReplicasGet(connID string) (net.Conn, bool) {
 	replicasMu.RLock()
@@ -86,7 +240,97 @@ func ReplicasSet(connID string, conn net.Conn) {
 func ReplicasDelete(connID string) {
 	replicasMu.Lock()
 	delete(server.StoreState.Replicas, connID)
+	delete(syncingReplicas, connID)
 	replicasMu.Unlock()
+	ReplicaInfoDelete(connID)
+}
+
+// ReplicaInfoUpdate applies fn to the replica's handshake record, creating it if needed.
+// The IP is filled in from the connection's remote address when the record is created.
+func ReplicaInfoUpdate(connID string, fn func(info *shared.ReplicaInfo)) {
+	replicaInfosMu.Lock()
+	defer replicaInfosMu.Unlock()
+
+	info, exists := ReplicaInfos[connID]
+	if !exists {
+		info = &shared.ReplicaInfo{}
+		if host, _, err := net.SplitHostPort(connID); err == nil {
+			info.IP = host
+		}
+		ReplicaInfos[connID] = info
+	}
+	fn(info)
+}
+
+// ReplicaInfoGet returns a copy of the replica's handshake record
+func ReplicaInfoGet(connID string) (shared.ReplicaInfo, bool) {
+	replicaInfosMu.RLock()
+	defer replicaInfosMu.RUnlock()
+
+	info, exists := ReplicaInfos[connID]
+	if !exists {
+		return shared.ReplicaInfo{}, false
+	}
+	snapshot := *info
+	snapshot.Capabilities = append([]string(nil), info.Capabilities...)
+	return snapshot, true
+}
+
+// ReplicaInfoDelete removes the replica's handshake record
+func ReplicaInfoDelete(connID string) {
+	replicaInfosMu.Lock()
+	delete(ReplicaInfos, connID)
+	replicaInfosMu.Unlock()
+}
+
+// GoodReplicasCount returns the number of replicas that acknowledged within the last maxLag seconds
+func GoodReplicasCount(maxLag int) int {
+	now := time.Now().UnixMilli()
+	maxLagMs := int64(maxLag) * 1000
+
+	count := 0
+	for _, replicaID := range ReplicaIDs() {
+		info, exists := ReplicaInfoGet(replicaID)
+		if exists && info.LastAck > 0 && now-info.LastAck <= maxLagMs {
+			count++
+		}
+	}
+	return count
+}
+
+// ReplicasAckedCount returns the number of replicas that acknowledged at least the given offset
+func ReplicasAckedCount(offset int64) int {
+	count := 0
+	for _, replicaID := range ReplicaIDs() {
+		info, exists := ReplicaInfoGet(replicaID)
+		if exists && info.LastAck > 0 && info.AckOffset >= offset {
+			count++
+		}
+	}
+	return count
+}
+
+// CheckMinReplicas reports whether enough good replicas are connected to accept a write,
+// according to the min-replicas-to-write and min-replicas-max-lag settings.
+func CheckMinReplicas() bool {
+	state := server.StoreState
+	if state.Role != "master" || state.MinReplicasToWrite <= 0 {
+		return true
+	}
+	return GoodReplicasCount(state.MinReplicasMaxLag) >= state.MinReplicasToWrite
+}
+
+// ReplicaIDs returns the connection IDs of all registered replicas in a stable order
+func ReplicaIDs() []string {
+	replicasMu.RLock()
+	ids := make([]string, 0, len(server.StoreState.Replicas))
+	for id := range server.StoreState.Replicas {
+		ids = append(ids, id)
+	}
+	replicasMu.RUnlock()
+
+	sort.Strings(ids)
+	return ids
 }
 
 // AcknowledgedReplicasSet marks a replica as having acknowledged
@@ -123,6 +367,9 @@ func SendReplconfGetack() {
 	}}
 	bytes := cmd.Marshal()
 
+	// GETACK is part of the replication stream, so it advances the master offset too
+	atomic.AddInt64(&server.StoreState.MasterReplOffset, int64(len(bytes)))
+
 	replicasMu.RLock()
 	replicas := make(map[string]net.Conn, len(server.StoreState.Replicas))
 	for id, c := range server.StoreState.Replicas {
@@ -134,24 +381,62 @@ func SendReplconfGetack() {
 		_, err := replicaConn.Write(bytes)
 		if err != nil {
 			ReplicasDelete(replicaID)
-			fmt.Printf("Failed to send GETACK to replica %s: %v\n", replicaID, err)
+			replicationLog.Warningf("Failed to send GETACK to replica %s: %v", replicaID, err)
 		}
 	}
 }
 
+// masterLinkGet returns the connection to the master, or nil when there is none
+func masterLinkGet() net.Conn {
+	masterLinkMu.RLock()
+	defer masterLinkMu.RUnlock()
+	return masterLink
+}
+
+// masterLinkSet records conn as the connection to the master
+func masterLinkSet(conn net.Conn) {
+	masterLinkMu.Lock()
+	masterLink = conn
+	masterLinkMu.Unlock()
+}
+
+// masterLinkClear forgets conn if it is still the connection to the master
+func masterLinkClear(conn net.Conn) {
+	masterLinkMu.Lock()
+	if masterLink == conn {
+		masterLink = nil
+	}
+	masterLinkMu.Unlock()
+}
+
+// MasterLinkUp reports whether this replica has completed its sync with the master
+// and is still connected to it
+func MasterLinkUp() bool {
+	return masterLinkGet() != nil
+}
+
+// MasterLinkID returns the connection ID commands from the master are executed with,
+// or "" when this server has no link to a master
+func MasterLinkID() string {
+	if conn := masterLinkGet(); conn != nil {
+		return conn.RemoteAddr().String()
+	}
+	return ""
+}
+
 // sendPing sends a PING command to the master
 func sendPing(conn net.Conn, writer *protocol.Writer, reader *protocol.Resp) {
 	err := writer.Write(protocol.Value{Typ: "array", Array: []protocol.Value{
 		{Typ: "bulk", Bulk: "PING"},
 	}})
 	if err != nil {
-		fmt.Printf("Failed to send PING: %s\n", err.Error())
+		replicationLog.Warningf("Failed to send PING: %s", err.Error())
 		conn.Close()
 		return
 	}
 	_, err = reader.Read()
 	if err != nil {
-		fmt.Printf("Failed to read PONG response: %s\n", err.Error())
+		replicationLog.Warningf("Failed to read PONG response: %s", err.Error())
 		conn.Close()
 		return
 	}
@@ -165,13 +450,13 @@ func sendReplConfListeningPort(conn net.Conn, writer *protocol.Writer, reader *p
 		{Typ: "bulk", Bulk: port},
 	}})
 	if err != nil {
-		fmt.Printf("Failed to send REPLCONF listening-port: %s\n", err.Error())
+		replicationLog.Warningf("Failed to send REPLCONF listening-port: %s", err.Error())
 		conn.Close()
 		return
 	}
 	_, err = reader.Read()
 	if err != nil {
-		fmt.Printf("Failed to read REPLCONF listening-port response: %s\n", err.Error())
+		replicationLog.Warningf("Failed to read REPLCONF listening-port response: %s", err.Error())
 		conn.Close()
 		return
 	}
@@ -185,60 +470,85 @@ func sendReplConfCapaPsync2(conn net.Conn, writer *protocol.Writer, reader *prot
 		{Typ: "bulk", Bulk: "psync2"},
 	}})
 	if err != nil {
-		fmt.Printf("Failed to send REPLCONF capa: %s\n", err.Error())
+		replicationLog.Warningf("Failed to send REPLCONF capa: %s", err.Error())
 		conn.Close()
 		return
 	}
 	_, err = reader.Read()
 	if err != nil {
-		fmt.Printf("Failed to read REPLCONF capa response: %s\n", err.Error())
+		replicationLog.Warningf("Failed to read REPLCONF capa response: %s", err.Error())
 		conn.Close()
 		return
 	}
 }
 
-// sendPsync sends a PSYNC command to the master
-func sendPsync(conn net.Conn, writer *protocol.Writer, reader *protocol.Resp, masterReplID string, masterReplOffset int64) {
-	err := writer.Write(protocol.Value{Typ: "array", Array: []protocol.Value{
+// sendPsync sends a PSYNC command to the master and returns the offset the master announced
+// in its FULLRESYNC reply, which is where the replica's own offset starts counting from.
+// With failover set, PSYNC carries the FAILOVER flag that tells the target to promote itself.
+func sendPsync(conn net.Conn, writer *protocol.Writer, reader *protocol.Resp, masterReplID string, masterReplOffset int64, failover bool) int64 {
+	psync := protocol.Value{Typ: "array", Array: []protocol.Value{
 		{Typ: "bulk", Bulk: "PSYNC"},
 		{Typ: "bulk", Bulk: masterReplID},
 		{Typ: "bulk", Bulk: strconv.FormatInt(masterReplOffset, 10)},
-	}})
+	}}
+	if failover {
+		psync.Array = append(psync.Array, protocol.Value{Typ: "bulk", Bulk: "FAILOVER"})
+	}
+	err := writer.Write(psync)
 	if err != nil {
-		fmt.Printf("Failed to send PSYNC: %s\n", err.Error())
+		replicationLog.Warningf("Failed to send PSYNC: %s", err.Error())
 		conn.Close()
-		return
+		return 0
 	}
 
-	// Read the FULLRESYNC response
-	_, err = reader.Read()
+	// Read the FULLRESYNC response: +FULLRESYNC <replid> <offset>
+	response, err := reader.Read()
 	if err != nil {
-		fmt.Printf("Failed to read PSYNC response: %s\n", err.Error())
+		replicationLog.Warningf("Failed to read PSYNC response: %s", err.Error())
 		conn.Close()
-		return
+		return 0
+	}
+
+	var offset int64
+	if fields := strings.Fields(response.Str); len(fields) == 3 && fields[0] == "FULLRESYNC" {
+		server.StoreState.MasterReplID = fields[1]
+		offset, _ = strconv.ParseInt(fields[2], 10, 64)
 	}
 
 	// Read the RDB file (this is binary data, not a command)
 	// Use the RESP reader to read it as a bulk string without trailing CRLF
-	_, err = reader.ReadBulkWithoutCRLF()
+	rdb, err := reader.ReadBulkWithoutCRLF()
 	if err != nil {
-		fmt.Printf("Failed to read RDB file: %s\n", err.Error())
+		replicationLog.Warningf("Failed to read RDB file: %s", err.Error())
 		conn.Close()
-		return
+		return 0
 	}
+
+	// The snapshot of the master replaces the dataset the replica started with
+	RunExclusive(func() { err = storage.LoadMasterSnapshot([]byte(rdb.Bulk)) })
+	if err != nil {
+		replicationLog.Warningf("Failed to load the RDB file of the master: %v", err)
+		conn.Close()
+		return 0
+	}
+	replicationLog.Noticef("Loaded the RDB file of the master, %d bytes", len(rdb.Bulk))
+
+	return offset
 }
 
-// performReplicationHandshake performs the complete replication handshake with master
-func performReplicationHandshake(address, port string, executeCommand func(string, string, []protocol.Value) protocol.Value) {
+// performReplicationHandshake performs the complete replication handshake with master.
+// A master demoted by FAILOVER sets failover so the target promotes itself before replying.
+func performReplicationHandshake(address, port string, failover bool, executeCommand func(string, string, []protocol.Value) protocol.Value) {
 	conn, err := net.Dial("tcp", address)
 	if err != nil {
-		fmt.Printf("Failed to connect to master %s: %s\n", address, err.Error())
+		replicationLog.Warningf("Failed to connect to master %s: %s", address, err.Error())
 		return // Don't exit, just return and let the server start
 	}
 	// Note: We don't close the connection here - keep it alive for replication
 
 	writer := protocol.NewWriter(conn)
-	reader := protocol.NewResp(conn)
+	stream := &masterStream{conn: conn}
+	reader := protocol.NewResp(stream)
 
 	// Step 1: Send PING and wait for PONG response
 	sendPing(conn, writer, reader)
@@ -250,17 +560,22 @@ func performReplicationHandshake(address, port string, executeCommand func(strin
 	sendReplConfCapaPsync2(conn, writer, reader)
 
 	// Step 4: Send PSYNC and wait for FULLRESYNC response
-	sendPsync(conn, writer, reader, "?", -1)
+	var offset int64
+	if failover {
+		offset = sendPsync(conn, writer, reader, server.StoreState.MasterReplID, atomic.LoadInt64(&server.StoreState.MasterReplOffset), true)
+	} else {
+		offset = sendPsync(conn, writer, reader, "?", -1, false)
+	}
 
 	// Step 5: Start listening for propagated commands
-	// Reuse the same RESP reader to avoid losing any buffered bytes
-	go processPropagatedCommands(conn, reader, executeCommand)
+	// Reuse the same RESP reader to avoid losing any buffered bytes, and the same writer
+	go processPropagatedCommands(conn, stream, reader, writer, offset, executeCommand)
 }
 
 func connectToMaster(replicaPort string, replicaOf string, executeCommand func(string, string, []protocol.Value) protocol.Value) {
 	parts := strings.Split(replicaOf, " ")
 	if len(parts) != 2 {
-		fmt.Println("Invalid replicaof format. Expected 'host port'")
+		replicationLog.Warningf("Invalid replicaof format. Expected 'host port'")
 		os.Exit(1)
 	}
 
@@ -268,64 +583,136 @@ func connectToMaster(replicaPort string, replicaOf string, executeCommand func(s
 	masterPort := parts[1]
 	address := host + ":" + masterPort
 
-	performReplicationHandshake(address, replicaPort, executeCommand)
+	performReplicationHandshake(address, replicaPort, false, executeCommand)
 }
 
-// processPropagatedCommands processes commands propagated from the master
-func processPropagatedCommands(conn net.Conn, reader *protocol.Resp, executeCommand func(string, string, []protocol.Value) protocol.Value) {
-	writer := protocol.NewWriter(conn)
-	var processedOffset int64 = 0
+// replicaAckInterval is how often a replica reports its offset to the master unprompted
+const replicaAckInterval = time.Second
+
+// sendReplicaAckHeartbeat sends REPLCONF ACK every replicaAckInterval until stop is closed
+func sendReplicaAckHeartbeat(stop <-chan struct{}, sendAck func() error) {
+	ticker := time.NewTicker(replicaAckInterval)
+	defer ticker.Stop()
 
 	for {
-		value, err := reader.Read()
-		if err != nil {
-			fmt.Printf("Error reading propagated command: %v\n", err)
+		select {
+		case <-stop:
 			return
+		case <-ticker.C:
+			if err := sendAck(); err != nil {
+				replicationLog.Warningf("Error sending REPLCONF ACK heartbeat: %v", err)
+				return
+			}
 		}
+	}
+}
 
-		if value.Typ != "array" {
-			continue
+// masterStream reads the replication stream of the master for the RESP reader, keeping the
+// bytes it hands over until the values read account for them, so the commands applied are
+// counted in the offset and fed to sub-replicas exactly as the master sent them
+type masterStream struct {
+	conn    io.Reader
+	pending []byte // Bytes read from the master beyond the values consumed so far
+}
+
+func (s *masterStream) Read(p []byte) (int, error) {
+	n, err := s.conn.Read(p)
+	s.pending = append(s.pending, p[:n]...)
+	return n, err
+}
+
+// consume returns the bytes of the values read since the last call, given the number of bytes
+// the RESP reader still buffers
+func (s *masterStream) consume(buffered int) []byte {
+	n := len(s.pending) - buffered
+	consumed := s.pending[:n:n]
+	s.pending = s.pending[n:]
+	return consumed
+}
+
+// processPropagatedCommands processes commands propagated from the master.
+// The replica's offset starts at the offset announced by FULLRESYNC and is reported
+// back to the master on every GETACK and once per second from a background ticker.
+// Every command read, GETACK included, advances the offset by the bytes the master sent
+// and is fed as is to the replicas of this replica.
+func processPropagatedCommands(conn net.Conn, stream *masterStream, reader *protocol.Resp, writer *protocol.Writer, initialOffset int64, executeCommand func(string, string, []protocol.Value) protocol.Value) {
+	masterLinkSet(conn)
+	defer masterLinkClear(conn)
+
+	// The handshake and the snapshot are not part of the stream
+	stream.consume(reader.Buffered())
+	atomic.StoreInt64(&server.StoreState.MasterReplOffset, initialOffset)
+
+	// The heartbeat goroutine and GETACK replies share the connection writer
+	var writeMu sync.Mutex
+	sendAck := func() error {
+		ack := protocol.Value{Typ: "array", Array: []protocol.Value{
+			{Typ: "bulk", Bulk: "REPLCONF"},
+			{Typ: "bulk", Bulk: "ACK"},
+			{Typ: "bulk", Bulk: strconv.FormatInt(atomic.LoadInt64(&server.StoreState.MasterReplOffset), 10)},
+		}}
+
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := writer.Write(ack); err != nil {
+			return err
 		}
+		// Flush to ensure the ACK is sent immediately
+		return writer.Flush()
+	}
 
-		if len(value.Array) == 0 {
-			continue
+	stop := make(chan struct{})
+	defer close(stop)
+	go sendReplicaAckHeartbeat(stop, sendAck)
+
+	for {
+		value, err := reader.Read()
+		if err != nil {
+			replicationLog.Warningf("Error reading propagated command: %v", err)
+			return
 		}
+		raw := stream.consume(reader.Buffered())
 
-		// Count bytes consumed by this command in RESP form
-		bytesConsumed := int64(len(value.Marshal()))
+		if value.Typ != "array" || len(value.Array) == 0 {
+			// Values that are not commands are still part of the stream
+			feedReplicas(raw)
+			continue
+		}
 
 		command := strings.ToUpper(value.Array[0].Bulk)
 		args := value.Array[1:]
 
 		// For REPLCONF GETACK, respond with current offset before including this command
 		if command == "REPLCONF" && len(args) >= 1 && strings.ToUpper(args[0].Bulk) == "GETACK" {
-			ack := protocol.Value{Typ: "array", Array: []protocol.Value{
-				{Typ: "bulk", Bulk: "REPLCONF"},
-				{Typ: "bulk", Bulk: "ACK"},
-				{Typ: "bulk", Bulk: strconv.FormatInt(processedOffset, 10)},
-			}}
-			if err := writer.Write(ack); err != nil {
-				fmt.Printf("Error writing REPLCONF GETACK response: %v\n", err)
+			if err := sendAck(); err != nil {
+				replicationLog.Warningf("Error writing REPLCONF GETACK response: %v", err)
 				return
 			}
-			// Flush to ensure the response is sent immediately
-			if err := writer.Flush(); err != nil {
-				fmt.Printf("Error flushing REPLCONF GETACK response: %v\n", err)
-				return
-			}
-			processedOffset += bytesConsumed
+			feedReplicas(raw)
 			continue
 		}
 
-		// Execute the command using the provided handler; ignore response to master
+		// Execute the command using the provided handler; ignore response to master.
+		// With single-writer enabled it runs on the executor like the commands of clients.
 		connID := conn.RemoteAddr().String()
-		_ = executeCommand(command, connID, args)
-		processedOffset += bytesConsumed
+		runShared(func() {
+			_ = executeCommand(command, connID, args)
+			if IsWriteCommand(command) {
+				// The master already sent the deterministic form of the write
+				storage.FeedAppendOnlyFile(raw)
+			}
+			// The offset is kept in the state, so a promoted replica continues from it
+			feedReplicas(raw)
+		})
 	}
 }
 
 // HandleReplicaMode sets up the server as a replica and connects to master
 func HandleReplicaMode(replicaPort string, role string, replicaOf string, executeCommand func(string, string, []protocol.Value) protocol.Value) {
+	// Remembered even on a master, which becomes a replica when it is demoted by FAILOVER
+	replicationPort = replicaPort
+	replicationExecute = executeCommand
+
 	if role == "slave" {
 		// Start replica connection in a goroutine so it doesn't block the server startup
 		go connectToMaster(replicaPort, replicaOf, executeCommand)
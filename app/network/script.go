@@ -0,0 +1,132 @@
+package network
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// RunningScript is the script or function in progress. Scripts run holding
+// server.CommandLock for writing, so there is at most one.
+type RunningScript struct {
+	function bool // Run by FCALL, so FUNCTION KILL stops it instead of SCRIPT KILL
+	start    time.Time
+
+	mu     sync.Mutex
+	wrote  bool // Changed the dataset, and can't be killed anymore
+	killed bool
+}
+
+var (
+	runningScriptMu sync.Mutex
+	runningScript   *RunningScript
+)
+
+// ErrScriptKilled stops a script killed with SCRIPT KILL
+var ErrScriptKilled = errors.New("ERR Script killed by user with SCRIPT KILL...")
+
+// ErrFunctionKilled stops a function killed with FUNCTION KILL
+var ErrFunctionKilled = errors.New("ERR Script killed by user with FUNCTION KILL...")
+
+// StartScript records a script starting to run, function telling whether FCALL runs it. Done
+// must be called once it returned.
+func StartScript(function bool) *RunningScript {
+	script := &RunningScript{function: function, start: time.Now()}
+	runningScriptMu.Lock()
+	runningScript = script
+	runningScriptMu.Unlock()
+	return script
+}
+
+// Done records the script has returned
+func (r *RunningScript) Done() {
+	runningScriptMu.Lock()
+	if runningScript == r {
+		runningScript = nil
+	}
+	runningScriptMu.Unlock()
+}
+
+// Wrote records the script changed the dataset: killing it would leave its writes half done
+func (r *RunningScript) Wrote() {
+	r.mu.Lock()
+	r.wrote = true
+	r.mu.Unlock()
+}
+
+// Killed returns the error stopping the script once SCRIPT KILL or FUNCTION KILL killed it,
+// nil while it may go on. The interpreter calls it regularly while the script runs.
+func (r *RunningScript) Killed() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.killed {
+		return nil
+	}
+	if r.function {
+		return ErrFunctionKilled
+	}
+	return ErrScriptKilled
+}
+
+// KillScript kills the script in progress for SCRIPT KILL, or the function for FUNCTION KILL
+// when function is set. It returns the error replied when there is none to kill.
+func KillScript(function bool) string {
+	runningScriptMu.Lock()
+	script := runningScript
+	runningScriptMu.Unlock()
+	if script == nil {
+		return "NOTBUSY No scripts in execution right now."
+	}
+	if script.function != function {
+		return busyScriptError(script)
+	}
+
+	script.mu.Lock()
+	defer script.mu.Unlock()
+	if script.wrote {
+		return "UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command."
+	}
+	script.killed = true
+	return ""
+}
+
+// busyScriptError returns the BUSY error replied while a script runs for too long
+func busyScriptError(script *RunningScript) string {
+	if script.function {
+		return "BUSY Redis is busy running a script. You can only call FUNCTION KILL or SHUTDOWN NOSAVE."
+	}
+	return "BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE."
+}
+
+// ScriptBusy returns the error replied to a command read from a client while a script has run
+// for longer than busy-reply-threshold, or an empty string when it may run. The commands
+// stopping the script and SHUTDOWN NOSAVE still run, the others would wait for the script.
+func ScriptBusy(command string, args []protocol.Value) string {
+	runningScriptMu.Lock()
+	script := runningScript
+	runningScriptMu.Unlock()
+	if script == nil || time.Since(script.start) < time.Duration(server.StoreState.BusyReplyThreshold)*time.Millisecond {
+		return ""
+	}
+	if KillsScript(command, args) {
+		return ""
+	}
+	if command == "SHUTDOWN" {
+		for _, arg := range args {
+			if strings.EqualFold(arg.Bulk, "NOSAVE") {
+				return ""
+			}
+		}
+	}
+	return busyScriptError(script)
+}
+
+// KillsScript reports whether a command is SCRIPT KILL or FUNCTION KILL. They run without
+// server.CommandLock, off the executor, since the script they stop holds both.
+func KillsScript(command string, args []protocol.Value) bool {
+	return (command == "SCRIPT" || command == "FUNCTION") && len(args) == 1 && strings.EqualFold(args[0].Bulk, "KILL")
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// listeners accept client connections, one per bind address, they are closed by SHUTDOWN
+var listenerMu sync.Mutex
+var listeners []net.Listener
+
+// ListenerSet records the client listeners so SHUTDOWN can close them
+func ListenerSet(ls ...net.Listener) {
+	listenerMu.Lock()
+	listeners = ls
+	listenerMu.Unlock()
+}
+
+// WaitReplicasForShutdown pauses writes and gives every replica up to timeout to
+// acknowledge the current replication offset. It returns the number of replicas that lagged behind.
+// Writes stay paused so nothing new is accepted; CancelShutdown resumes them.
+func WaitReplicasForShutdown(timeout time.Duration) int {
+	PauseWrites()
+
+	replicas := len(ReplicaIDs())
+	if replicas == 0 {
+		return 0
+	}
+
+	targetOffset := server.StoreState.MasterReplOffset
+	if ReplicasAckedCount(targetOffset) < replicas {
+		SendReplconfGetack()
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if ReplicasAckedCount(targetOffset) >= replicas {
+			return 0
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return replicas - ReplicasAckedCount(targetOffset)
+}
+
+// CancelShutdown resumes writes after a SHUTDOWN that failed
+func CancelShutdown() {
+	UnpauseWrites()
+}
+
+// CloseForShutdown stops accepting clients and closes the links to replicas and to the master
+func CloseForShutdown() {
+	listenerMu.Lock()
+	for _, l := range listeners {
+		l.Close()
+	}
+	listeners = nil
+	listenerMu.Unlock()
+
+	for _, replicaID := range ReplicaIDs() {
+		if conn, ok := ReplicasGet(replicaID); ok {
+			conn.Close()
+		}
+		ReplicasDelete(replicaID)
+	}
+
+	if conn := masterLinkGet(); conn != nil {
+		conn.Close()
+		masterLinkClear(conn)
+	}
+}
@@ -0,0 +1,176 @@
+package network
+
+import (
+	"slices"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+)
+
+// pubsubLog logs the messages of the pubsub subsystem
+var pubsubLog = logger.New("pubsub")
+
+// Subscribe subscribes the client to channels and puts it in subscribed mode. It returns the
+// number of channels the client is subscribed to.
+func (c *Client) Subscribe(channels ...string) int {
+	count := 0
+	c.update(func() {
+		for _, channel := range channels {
+			if !slices.Contains(c.channels, channel) {
+				c.channels = append(c.channels, channel)
+			}
+		}
+		c.subscribed = true
+		count = len(c.channels)
+	})
+	return count
+}
+
+// Channels returns the channels the client is subscribed to, in subscription order
+func (c *Client) Channels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.channels)
+}
+
+// SetChannels replaces the channels the client is subscribed to. A client left without any
+// leaves subscribed mode.
+func (c *Client) SetChannels(channels []string) {
+	c.update(func() {
+		c.channels = slices.Clone(channels)
+		if len(channels) == 0 {
+			c.subscribed = false
+		}
+	})
+}
+
+// Subscribed reports whether the client is in subscribed mode, where only the commands of
+// pubsub.IsAllowedInSubscribedMode run
+func (c *Client) Subscribed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscribed
+}
+
+// subscribedTo reports whether the client is subscribed to channel
+func (c *Client) subscribedTo(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Contains(c.channels, channel)
+}
+
+// Publish sends a message to every client subscribed to channel, as a push frame to the
+// ones that negotiated RESP3, and returns the number of clients it was sent to. A subscriber
+// its message can't be written to is disconnected from the channels.
+func Publish(channel string, message string) int {
+	var subscribers []*Client
+	clientsMu.RLock()
+	for _, c := range clients {
+		subscribers = append(subscribers, c)
+	}
+	clientsMu.RUnlock()
+
+	messagePush := protocol.Value{
+		Typ: "push",
+		Array: []protocol.Value{
+			{Typ: "bulk", Bulk: "message"},
+			{Typ: "bulk", Bulk: channel},
+			{Typ: "bulk", Bulk: message},
+		},
+	}
+
+	// The message is encoded once per protocol version
+	messageBytes := map[int][]byte{}
+	delivered := 0
+	for _, c := range subscribers {
+		if !c.subscribedTo(channel) {
+			continue
+		}
+		conn := c.Conn()
+		if conn == nil {
+			// Clients of tests have no connection, they count as delivered
+			delivered++
+			continue
+		}
+		version := c.Protocol()
+		if _, ok := messageBytes[version]; !ok {
+			messageBytes[version] = messagePush.MarshalProtocol(version)
+		}
+		if _, err := conn.Write(messageBytes[version]); err != nil {
+			c.SetChannels(nil)
+			ConnectionsDelete(c.ConnID)
+			pubsubLog.Warningf("Failed to send message to subscriber %s: %v", c.ConnID, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// Subscriptions helpers, for the connections a command has no Client of
+
+// SubscriptionsSet subscribes a connection to a channel, without changing its mode
+func SubscriptionsSet(connID string, channel string) {
+	updateClient(connID, func(c *Client) {
+		if !slices.Contains(c.channels, channel) {
+			c.channels = append(c.channels, channel)
+		}
+	})
+}
+
+// SubscriptionsGet returns the channels a connection is subscribed to, reporting false when
+// there are none
+func SubscriptionsGet(connID string) ([]string, bool) {
+	c, exists := ClientGet(connID)
+	if !exists {
+		return nil, false
+	}
+	channels := c.Channels()
+	return channels, len(channels) > 0
+}
+
+// SubscriptionsDelete unsubscribes a connection from every channel, without changing its mode
+func SubscriptionsDelete(connID string) {
+	if _, exists := ClientGet(connID); !exists {
+		return
+	}
+	updateClient(connID, func(c *Client) {
+		c.channels = nil
+	})
+}
+
+// SubscriptionsClear unsubscribes every client from every channel and ends subscribed mode
+func SubscriptionsClear() {
+	clientsMu.RLock()
+	all := make([]*Client, 0, len(clients))
+	for _, c := range clients {
+		all = append(all, c)
+	}
+	clientsMu.RUnlock()
+	for _, c := range all {
+		c.SetChannels(nil)
+	}
+}
+
+// SubscribedModeSet puts a connection in subscribed mode
+func SubscribedModeSet(connID string) {
+	updateClient(connID, func(c *Client) {
+		c.subscribed = true
+	})
+}
+
+// SubscribedModeGet reports whether a connection is in subscribed mode
+func SubscribedModeGet(connID string) bool {
+	c, exists := ClientGet(connID)
+	return exists && c.Subscribed()
+}
+
+// SubscribedModeDelete takes a connection out of subscribed mode
+func SubscribedModeDelete(connID string) {
+	if _, exists := ClientGet(connID); !exists {
+		return
+	}
+	updateClient(connID, func(c *Client) {
+		c.subscribed = false
+	})
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"net"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// clientsLog logs the messages of the clients subsystem
+var clientsLog = logger.New("clients")
+
+// StartClientReaper closes the clients idle for longer than timeout and the ones over their
+// output buffer limit, checking every second until the returned function is called
+func StartClientReaper() (stop func()) {
+	return server.Every(time.Second, func(now time.Time) {
+		CloseIdleClients(now)
+		CloseOutputBufferOffenders(now)
+	})
+}
+
+// CloseIdleClients closes the clients that sent no command for longer than timeout seconds
+// and returns how many were closed. Replicas, monitors, subscribers and clients waiting in
+// a blocking command are never idle. It does nothing when timeout is 0.
+func CloseIdleClients(now time.Time) int {
+	timeout := server.StoreState.Timeout
+	if timeout <= 0 {
+		return 0
+	}
+
+	closed := 0
+	deadline := now.Add(-time.Duration(timeout) * time.Second).UnixMilli()
+	for _, connID := range ClientIDs() {
+		info, ok := ClientInfoGet(connID)
+		if !ok || info.Blocked || info.LastInteraction > deadline {
+			continue
+		}
+		if _, isReplica := ReplicasGet(connID); isReplica || IsMonitor(connID) || SubscribedModeGet(connID) {
+			continue
+		}
+		clientsLog.Verbosef("Closing idle client %s", info.Addr)
+		ClientKill(connID, false)
+		closed++
+	}
+	return closed
+}
+
+// ApplyKeepalive configures TCP keepalive on an accepted connection: probes start after
+// tcp-keepalive seconds of silence and are repeated every third of that. 0 disables them.
+func ApplyKeepalive(conn net.Conn) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	seconds := server.StoreState.TCPKeepalive
+	if seconds <= 0 {
+		return tcpConn.SetKeepAlive(false)
+	}
+	idle := time.Duration(seconds) * time.Second
+	interval := idle / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return tcpConn.SetKeepAliveConfig(net.KeepAliveConfig{Enable: true, Idle: idle, Interval: interval, Count: 3})
+}
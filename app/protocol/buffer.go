@@ -0,0 +1,38 @@
+package protocol
+
+import "sync"
+
+// maxPooledBuffer is the capacity past which a buffer is dropped instead of going back to the
+// pool, so a single huge reply does not stay allocated for the life of the server
+const maxPooledBuffer = 64 * 1024
+
+// bufferPool holds the buffers values are encoded into before being written, reused across
+// replies and connections instead of allocating a byte slice per reply
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// GetBuffer returns an empty buffer from the pool. It must be handed back with PutBuffer once
+// its bytes were written, and not used after.
+//
+// Examples:
+//
+//	buf := GetBuffer()
+//	*buf = AppendCommand(*buf, "SET", args)
+//	conn.Write(*buf)
+//	PutBuffer(buf)
+func GetBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+// PutBuffer returns a buffer to the pool
+func PutBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBuffer {
+		return
+	}
+	*buf = (*buf)[:0]
+	bufferPool.Put(buf)
+}
@@ -1,86 +1,196 @@
 package protocol
 
 import (
+	"math"
 	"strconv"
 )
 
+// Marshal encodes the value in RESP2
 func (v Value) Marshal() []byte {
+	return v.MarshalProtocol(RESP2)
+}
+
+// MarshalProtocol encodes the value in the given protocol version. Under RESP2 maps are
+// flattened into arrays of alternating keys and values, sets are arrays, booleans are the
+// integers 1 and 0, and doubles, big numbers and verbatim strings are bulk strings, doubles
+// with the text in their Str when set.
+// Under RESP3 nulls are a single "_", and push values and attributes are written as their own
+// frames. Under RESP2 push values are arrays and attributes are left out.
+func (v Value) MarshalProtocol(version int) []byte {
+	return v.AppendProtocol(nil, version)
+}
+
+// AppendProtocol appends the value encoded in the given protocol version to dst and returns
+// the extended slice, like MarshalProtocol, so a buffer can be reused from one value to the next
+func (v Value) AppendProtocol(dst []byte, version int) []byte {
+	if len(v.Attributes) > 0 && version >= RESP3 {
+		dst = v.appendAttributes(dst)
+	}
+	return v.appendValue(dst, version)
+}
+
+// appendValue appends the value without its attributes
+func (v Value) appendValue(dst []byte, version int) []byte {
 	switch v.Typ {
 	case "array":
-		return v.marshalArray()
+		return v.appendArray(dst, version)
+	case "map":
+		if version >= RESP3 {
+			return appendAggregate(dst, MAP, len(v.Array)/2, v.Array)
+		}
+		return v.appendArray(dst, version)
+	case "set":
+		if version >= RESP3 {
+			return appendAggregate(dst, SET, len(v.Array), v.Array)
+		}
+		return v.appendArray(dst, version)
+	case "push":
+		if version >= RESP3 {
+			return appendAggregate(dst, PUSH, len(v.Array), v.Array)
+		}
+		return v.appendArray(dst, version)
+	case "double":
+		if version >= RESP3 {
+			return appendLine(dst, DOUBLE, FormatDouble(v.Double))
+		}
+		if v.Str != "" {
+			return appendBulk(dst, v.Str)
+		}
+		return appendBulk(dst, FormatDouble(v.Double))
+	case "boolean":
+		if version >= RESP3 {
+			b := byte('f')
+			if v.Bool {
+				b = 't'
+			}
+			return append(dst, BOOLEAN, b, '\r', '\n')
+		}
+		return appendInteger(dst, INTEGER, boolToInt(v.Bool))
+	case "big_number":
+		if version >= RESP3 {
+			return appendLine(dst, BIG_NUMBER, v.Str)
+		}
+		return appendBulk(dst, v.Str)
+	case "verbatim":
+		if version >= RESP3 {
+			return v.appendVerbatim(dst)
+		}
+		return appendBulk(dst, v.Bulk)
 	case "bulk":
-		return v.marshalBulk()
+		return appendBulk(dst, v.Bulk)
 	case "string":
-		return v.marshalString()
+		return appendLine(dst, STRING, v.Str)
 	case "integer":
-		return v.marshalInteger()
+		return appendInteger(dst, INTEGER, v.Num)
 	case "null":
-		return v.marshallNull()
-	case "null_array":
-		return v.marshallNullArray()
+		if version >= RESP3 {
+			return append(dst, NULL, '\r', '\n')
+		}
+		if v.NullArray {
+			return append(dst, "*-1\r\n"...)
+		}
+		return append(dst, "$-1\r\n"...)
 	case "error":
-		return v.marshallError()
+		return appendLine(dst, ERROR, v.Str)
 	default:
-		return []byte{}
+		return dst
 	}
 }
 
-func (v Value) marshalString() []byte {
-	var bytes []byte
-	bytes = append(bytes, STRING)
-	bytes = append(bytes, v.Str...)
-	bytes = append(bytes, '\r', '\n')
-
-	return bytes
+// appendLine appends a simple frame: its type byte, the text and CRLF
+func appendLine(dst []byte, typ byte, text string) []byte {
+	dst = append(dst, typ)
+	dst = append(dst, text...)
+	return append(dst, '\r', '\n')
 }
 
-func (v Value) marshalBulk() []byte {
-	var bytes []byte
-	bytes = append(bytes, BULK)
-	bytes = append(bytes, strconv.Itoa(len(v.Bulk))...)
-	bytes = append(bytes, '\r', '\n')
-	bytes = append(bytes, v.Bulk...)
-	bytes = append(bytes, '\r', '\n')
-
-	return bytes
+// appendInteger appends the frame of type typ holding n, like an integer or an array header
+func appendInteger(dst []byte, typ byte, n int) []byte {
+	dst = append(dst, typ)
+	dst = strconv.AppendInt(dst, int64(n), 10)
+	return append(dst, '\r', '\n')
 }
 
-func (v Value) marshalArray() []byte {
-	len := len(v.Array)
-	var bytes []byte
-	bytes = append(bytes, ARRAY)
-	bytes = append(bytes, strconv.Itoa(len)...)
-	bytes = append(bytes, '\r', '\n')
+// appendBulk appends a bulk string
+func appendBulk(dst []byte, s string) []byte {
+	dst = appendInteger(dst, BULK, len(s))
+	dst = append(dst, s...)
+	return append(dst, '\r', '\n')
+}
 
-	for i := 0; i < len; i++ {
-		bytes = append(bytes, v.Array[i].Marshal()...)
+func (v Value) appendArray(dst []byte, version int) []byte {
+	if v.Stream != nil {
+		return v.Materialize().appendArray(dst, version)
 	}
 
-	return bytes
+	dst = appendInteger(dst, ARRAY, len(v.Array))
+	for i := 0; i < len(v.Array); i++ {
+		dst = v.Array[i].AppendProtocol(dst, version)
+	}
+	return dst
 }
 
-func (v Value) marshallError() []byte {
-	var bytes []byte
-	bytes = append(bytes, ERROR)
-	bytes = append(bytes, v.Str...)
-	bytes = append(bytes, '\r', '\n')
+// appendAggregate appends a RESP3 map, set or push value of n entries. The elements of a map
+// alternate keys and values, so it has half as many entries as elements.
+func appendAggregate(dst []byte, typ byte, n int, elements []Value) []byte {
+	dst = appendInteger(dst, typ, n)
+	for _, element := range elements {
+		dst = element.AppendProtocol(dst, RESP3)
+	}
+	return dst
+}
 
-	return bytes
+// appendAttributes appends the RESP3 attribute frame written before the value,
+// Attributes holds alternating keys and values
+func (v Value) appendAttributes(dst []byte) []byte {
+	return appendAggregate(dst, ATTRIBUTE, len(v.Attributes)/2, v.Attributes)
 }
 
-func (v Value) marshallNull() []byte {
-	return []byte("$-1\r\n")
+// appendVerbatim appends a RESP3 verbatim string: its three letter format, a colon, then the text
+func (v Value) appendVerbatim(dst []byte) []byte {
+	format := v.Str
+	if format == "" {
+		format = "txt"
+	}
+	dst = appendInteger(dst, VERBATIM, len(format)+1+len(v.Bulk))
+	dst = append(dst, format...)
+	dst = append(dst, ':')
+	dst = append(dst, v.Bulk...)
+	return append(dst, '\r', '\n')
 }
 
-func (v Value) marshallNullArray() []byte {
-	return []byte("*-1\r\n")
+// AppendCommand appends a command with its arguments, encoded as an array of bulk strings,
+// without building the array first
+func AppendCommand(dst []byte, command string, args []Value) []byte {
+	dst = appendInteger(dst, ARRAY, len(args)+1)
+	dst = appendBulk(dst, command)
+	for _, arg := range args {
+		dst = arg.AppendProtocol(dst, RESP2)
+	}
+	return dst
 }
 
-func (v Value) marshalInteger() []byte {
-	var bytes []byte
-	bytes = append(bytes, INTEGER)
-	bytes = append(bytes, strconv.Itoa(v.Num)...)
-	bytes = append(bytes, '\r', '\n')
+// FormatDouble returns the text of a double reply: the shortest representation reading back
+// as the same number, without exponent for whole numbers below 1e17, and inf, -inf or nan for
+// the special values
+func FormatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	case f == math.Trunc(f) && math.Abs(f) < 1e17:
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
 
-	return bytes
+// boolToInt returns 1 for true and 0 for false
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
 }
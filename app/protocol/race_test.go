@@ -0,0 +1,6 @@
+//go:build race
+
+package protocol
+
+// raceEnabled reports whether the tests run under the race detector, which allocates on its own
+const raceEnabled = true
@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ReadReply reads the next reply of a server, which unlike the commands of clients may be of
+// any RESP3 type: maps, sets, nulls, doubles, booleans, big numbers, verbatim strings, blob
+// errors, push values and attributes, which are set on the value following them.
+func (r *Resp) ReadReply() (Value, error) {
+	if cap(r.arena) > maxArenaKeepLength {
+		r.arena = nil
+	}
+	r.arena = r.arena[:0]
+	r.frame = 0
+	return r.readReply(0)
+}
+
+// readReply reads a reply nested in depth aggregates
+func (r *Resp) readReply(depth int) (Value, error) {
+	_type, err := r.reader.ReadByte()
+	if err != nil {
+		return Value{}, err
+	}
+	if depth >= GetLimits().MaxDepth {
+		return Value{}, &ProtocolError{Reason: "too deep nesting of arrays"}
+	}
+
+	switch _type {
+	case ARRAY, SET, PUSH:
+		n, err := r.readLength(int64(GetLimits().MaxArrayLength), "invalid multibulk length")
+		if err != nil {
+			return Value{}, err
+		}
+		if n == -1 {
+			return NullArray(), nil
+		}
+		typ := "array"
+		if _type == SET {
+			typ = "set"
+		} else if _type == PUSH {
+			typ = "push"
+		}
+		return r.readAggregate(typ, n, depth)
+	case MAP, ATTRIBUTE:
+		n, err := r.readLength(int64(GetLimits().MaxArrayLength/2), "invalid multibulk length")
+		if err != nil {
+			return Value{}, err
+		}
+		v, err := r.readAggregate("map", 2*n, depth)
+		if err != nil || _type == MAP {
+			return v, err
+		}
+		// Attributes describe the reply following them
+		reply, err := r.readReply(depth)
+		reply.Attributes = v.Array
+		return reply, unexpectedEOF(err)
+	case BULK:
+		return r.readBulk()
+	case VERBATIM:
+		v, err := r.readBulk()
+		if err != nil || v.Typ == "null" {
+			return v, err
+		}
+		format, text, _ := strings.Cut(v.Bulk, ":")
+		return Value{Typ: "verbatim", Str: format, Bulk: text}, nil
+	case BLOB_ERROR:
+		v, err := r.readBulk()
+		return Value{Typ: "error", Str: v.Bulk}, err
+	case STRING:
+		return r.readString()
+	case ERROR:
+		v, err := r.readString()
+		v.Typ = "error"
+		return v, err
+	case INTEGER:
+		return r.readIntegerValue()
+	case NULL:
+		_, _, err := r.readLine()
+		return Null(), err
+	case BOOLEAN:
+		line, _, err := r.readLine()
+		if err == nil && string(line) != "t" && string(line) != "f" {
+			err = &ProtocolError{Reason: "invalid boolean"}
+		}
+		return Boolean(string(line) == "t"), err
+	case DOUBLE:
+		line, _, err := r.readLine()
+		if err != nil {
+			return Value{}, err
+		}
+		return parseDouble(string(line))
+	case BIG_NUMBER:
+		line, _, err := r.readLine()
+		return BigNumber(string(line)), err
+	default:
+		return Value{}, &ProtocolError{Reason: fmt.Sprintf("unknown type byte %q", _type)}
+	}
+}
+
+// readAggregate reads the n elements of an array, set, push value or map
+func (r *Resp) readAggregate(typ string, n int, depth int) (Value, error) {
+	v := Value{Typ: typ, Array: make([]Value, 0, min(n, r.reader.Size()/4))}
+	for range n {
+		element, err := r.readReply(depth + 1)
+		if err != nil {
+			return v, unexpectedEOF(err)
+		}
+		v.Array = append(v.Array, element)
+	}
+	return v, nil
+}
+
+// parseDouble parses the text of a double reply, inf, -inf and nan included
+func parseDouble(text string) (Value, error) {
+	switch strings.ToLower(text) {
+	case "inf":
+		return Double(math.Inf(1)), nil
+	case "-inf":
+		return Double(math.Inf(-1)), nil
+	case "nan":
+		return Double(math.NaN()), nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Value{}, &ProtocolError{Reason: "invalid double"}
+	}
+	return Double(f), nil
+}
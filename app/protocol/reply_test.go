@@ -0,0 +1,92 @@
+package protocol
+
+import (
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Value
+	}{
+		{"simple string", "+OK\r\n", Value{Typ: "string", Str: "OK"}},
+		{"error", "-ERR wrong\r\n", Value{Typ: "error", Str: "ERR wrong"}},
+		{"blob error", "!9\r\nERR wrong\r\n", Value{Typ: "error", Str: "ERR wrong"}},
+		{"integer", ":-42\r\n", Value{Typ: "integer", Num: -42}},
+		{"bulk", "$5\r\nhello\r\n", Value{Typ: "bulk", Bulk: "hello"}},
+		{"empty bulk", "$0\r\n\r\n", Value{Typ: "bulk", Bulk: ""}},
+		{"null bulk", "$-1\r\n", Null()},
+		{"null array", "*-1\r\n", NullArray()},
+		{"null", "_\r\n", Null()},
+		{"true", "#t\r\n", Boolean(true)},
+		{"false", "#f\r\n", Boolean(false)},
+		{"double", ",3.25\r\n", Double(3.25)},
+		{"double inf", ",-inf\r\n", Double(math.Inf(-1))},
+		{"big number", "(3492890328409238509324850943850943825024385\r\n", BigNumber("3492890328409238509324850943850943825024385")},
+		{"verbatim", "=15\r\ntxt:Some string\r\n", Value{Typ: "verbatim", Str: "txt", Bulk: "Some string"}},
+		{"array", "*2\r\n$1\r\na\r\n:1\r\n", Value{Typ: "array", Array: []Value{{Typ: "bulk", Bulk: "a"}, {Typ: "integer", Num: 1}}}},
+		{"empty array", "*0\r\n", Value{Typ: "array", Array: []Value{}}},
+		{"nested array", "*1\r\n*1\r\n+x\r\n", Value{Typ: "array", Array: []Value{{Typ: "array", Array: []Value{{Typ: "string", Str: "x"}}}}}},
+		{"set", "~1\r\n+a\r\n", Value{Typ: "set", Array: []Value{{Typ: "string", Str: "a"}}}},
+		{"push", ">2\r\n+message\r\n+hi\r\n", Value{Typ: "push", Array: []Value{{Typ: "string", Str: "message"}, {Typ: "string", Str: "hi"}}}},
+		{"map", "%1\r\n+key\r\n:7\r\n", Value{Typ: "map", Array: []Value{{Typ: "string", Str: "key"}, {Typ: "integer", Num: 7}}}},
+		{"attribute", "|1\r\n+ttl\r\n:3\r\n+value\r\n", Value{Typ: "string", Str: "value", Attributes: []Value{{Typ: "string", Str: "ttl"}, {Typ: "integer", Num: 3}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewResp(strings.NewReader(tt.input)).ReadReply()
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestReadReplyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected error
+	}{
+		{"unknown type", "?x\r\n", &ProtocolError{Reason: "unknown type byte '?'"}},
+		{"invalid boolean", "#x\r\n", &ProtocolError{Reason: "invalid boolean"}},
+		{"invalid double", ",abc\r\n", &ProtocolError{Reason: "invalid double"}},
+		{"truncated array", "*2\r\n+a\r\n", io.ErrUnexpectedEOF},
+		{"attribute without reply", "|1\r\n+ttl\r\n:3\r\n", io.ErrUnexpectedEOF},
+		{"empty input", "", io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewResp(strings.NewReader(tt.input)).ReadReply()
+			var protocolErr *ProtocolError
+			if errors.As(tt.expected, &protocolErr) {
+				var got *ProtocolError
+				if !errors.As(err, &got) || got.Reason != protocolErr.Reason {
+					t.Errorf("Expected the protocol error %q, got %v", protocolErr.Reason, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestReadReplyNaN(t *testing.T) {
+	result, err := NewResp(strings.NewReader(",nan\r\n")).ReadReply()
+	if err != nil || result.Typ != "double" || !math.IsNaN(result.Double) {
+		t.Errorf("Expected a NaN double, got %#v, %v", result, err)
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"iter"
+	"slices"
 	"strconv"
+	"sync/atomic"
+	"unsafe"
 )
 
 const (
@@ -13,15 +17,136 @@ const (
 	INTEGER = ':'
 	BULK    = '$'
 	ARRAY   = '*'
+	MAP     = '%'
+	NULL    = '_'
+
+	// RESP3 types, written as their RESP2 equivalent to clients that did not negotiate RESP3
+	SET        = '~'
+	DOUBLE     = ','
+	BOOLEAN    = '#'
+	BIG_NUMBER = '('
+	VERBATIM   = '='
+	PUSH       = '>'
+	BLOB_ERROR = '!'
+	ATTRIBUTE  = '|'
+)
+
+// Protocol versions negotiated with HELLO
+const (
+	RESP2 = 2
+	RESP3 = 3
 )
 
+// Value is a RESP value. Typ is one of string, error, integer, bulk, array, null and the RESP3
+// types map, set, double, boolean, big_number, verbatim and push. Maps hold alternating keys
+// and values in Array, doubles their value in Double and optionally the text written under
+// RESP2 in Str, big numbers their digits in Str, and verbatim strings their format,
+// like txt, in Str and their text in Bulk. Push values are out of band data, like pub/sub
+// messages, with their elements in Array.
 type Value struct {
-	Typ     string
-	Str     string
-	Num     int
-	Bulk    string
-	Array   []Value
-	Expires int64
+	Typ       string
+	Str       string
+	Num       int
+	Bulk      string
+	Array     []Value
+	Expires   int64
+	Double    float64
+	Bool      bool
+	NullArray bool // Whether a null is written *-1 rather than $-1 under RESP2
+
+	// Attributes are alternating keys and values describing the value, written as an
+	// attribute frame before it under RESP3 and left out under RESP2
+	Attributes []Value
+
+	// Stream produces the elements of an array as it is written, instead of Array
+	Stream *ArrayStream
+}
+
+// ArrayStream produces the elements of a large array reply one at a time while it is written,
+// so the reply is never held in memory at once. Elements yields Len values.
+type ArrayStream struct {
+	Len      int
+	Elements iter.Seq[Value]
+}
+
+// StreamArray returns an array reply of n elements produced by elements as it is written
+func StreamArray(n int, elements iter.Seq[Value]) Value {
+	return Value{Typ: "array", Stream: &ArrayStream{Len: n, Elements: elements}}
+}
+
+// Materialize returns the value with the elements of a streamed array collected in Array,
+// for a reply that is kept rather than written right away, like the replies of EXEC
+func (v Value) Materialize() Value {
+	if v.Stream == nil {
+		return v
+	}
+	v.Array = make([]Value, 0, v.Stream.Len)
+	v.Stream.each(func(element Value) bool {
+		v.Array = append(v.Array, element)
+		return true
+	})
+	v.Stream = nil
+	return v
+}
+
+// each calls f with exactly Len elements, nulls standing in for the elements missing when
+// the data shrank since the reply was created, so the header written before stays true.
+// It stops early when f returns false.
+func (s *ArrayStream) each(f func(Value) bool) {
+	n := 0
+	for element := range s.Elements {
+		if n == s.Len {
+			return
+		}
+		if !f(element) {
+			return
+		}
+		n++
+	}
+	for ; n < s.Len; n++ {
+		if !f(Null()) {
+			return
+		}
+	}
+}
+
+// Null returns the null reply of a command otherwise replying a bulk string,
+// written $-1 under RESP2 and _ under RESP3
+func Null() Value {
+	return Value{Typ: "null"}
+}
+
+// Double returns a double reply, written as a bulk string under RESP2
+func Double(f float64) Value {
+	return Value{Typ: "double", Double: f}
+}
+
+// Boolean returns a boolean reply, written as the integer 1 or 0 under RESP2
+func Boolean(b bool) Value {
+	return Value{Typ: "boolean", Bool: b}
+}
+
+// BigNumber returns a reply of an integer too big for 64 bits, given as its decimal digits,
+// written as a bulk string under RESP2
+func BigNumber(digits string) Value {
+	return Value{Typ: "big_number", Str: digits}
+}
+
+// NullArray returns the null reply of a command otherwise replying an array,
+// written *-1 under RESP2 and _ under RESP3
+func NullArray() Value {
+	return Value{Typ: "null", NullArray: true}
+}
+
+// Bytes returns the data of a bulk string without copying it, for parsing it without
+// allocating. The slice shares the memory of Bulk and must not be modified.
+func (v Value) Bytes() []byte {
+	return unsafe.Slice(unsafe.StringData(v.Bulk), len(v.Bulk))
+}
+
+// IsNull reports whether the value is a null, of either kind
+func (v Value) IsNull() bool {
+	return v.Typ == "null"
 }
 
 // Special value types
@@ -29,26 +154,120 @@ const (
 	NO_RESPONSE = "no_response"
 )
 
+// IOBufferSize is the size of the buffers connections read commands into and write replies
+// from. A pipelined batch is read, executed and replied at once up to that size.
+const IOBufferSize = 16 * 1024
+
+// maxLineLength is the longest line read, like the length of a bulk string
+const maxLineLength = 64 * 1024
+
+// bulkPreallocLength is the longest bulk string whose buffer is allocated from its length
+// header at once, longer ones grow as their data arrives
+const bulkPreallocLength = 64 * 1024
+
+// maxArenaKeepLength is the largest arena kept between commands, a bigger one grown by a
+// large command is dropped so an idle connection doesn't hold on to it
+const maxArenaKeepLength = 1024 * 1024
+
+// Limits bounds the input readers accept, so a crafted length header can't make the server
+// allocate gigabytes. Input beyond them is refused as malformed.
+type Limits struct {
+	MaxBulkLength  int64 // Longest bulk string, proto-max-bulk-len
+	MaxArrayLength int   // Most elements in an array, proto-max-multibulk-len
+	MaxDepth       int   // Deepest nesting of arrays, proto-max-nesting-depth
+	MaxFrameSize   int64 // Most bytes of bulk strings in a value, client-query-buffer-limit, 0 for no limit
+}
+
+// DefaultLimits are the limits readers start with
+var DefaultLimits = Limits{MaxBulkLength: 512 * 1024 * 1024, MaxArrayLength: 1024 * 1024, MaxDepth: 32, MaxFrameSize: 1024 * 1024 * 1024}
+
+// limits holds the limits currently applied by every reader
+var limits atomic.Pointer[Limits]
+
+func init() {
+	SetLimits(DefaultLimits)
+}
+
+// SetLimits changes the limits of every reader, including those of connected clients
+func SetLimits(l Limits) {
+	limits.Store(&l)
+}
+
+// GetLimits returns the limits readers apply
+func GetLimits() Limits {
+	return *limits.Load()
+}
+
+// ProtocolError is returned when the input is not valid RESP. The server replies it to the
+// client, then closes the connection since it can't tell where the next command starts,
+// unless the error is recoverable: the whole value was read, so the next one can be.
+type ProtocolError struct {
+	Reason      string
+	Recoverable bool
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Reason
+}
+
+// unexpectedEOF turns io.EOF in the middle of a value into io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Resp reads RESP values. Lines are parsed in place in the read buffer, and the data of bulk
+// strings is read into an arena reused from one value to the next, then copied once into
+// the strings of the value, so reading a command allocates little more than its strings.
 type Resp struct {
 	reader *bufio.Reader
+	line   []byte // Lines longer than the read buffer, gathered across reads
+	arena  []byte // Data of the bulk strings of the value being read
+	frame  int64  // Bytes of bulk strings read so far in the value being read
 }
 
 func NewResp(rd io.Reader) *Resp {
-	return &Resp{reader: bufio.NewReader(rd)}
+	return &Resp{reader: bufio.NewReaderSize(rd, IOBufferSize)}
 }
 
-func (r *Resp) readArray() (Value, error) {
+// Buffered returns the number of bytes already read from the underlying reader and not parsed
+// yet, more than 0 when the client pipelined more commands
+func (r *Resp) Buffered() int {
+	return r.reader.Buffered()
+}
+
+// WaitInput waits until the client sent more input, without consuming it, and returns the
+// error of the underlying reader when it failed first, like io.EOF once the client is gone
+func (r *Resp) WaitInput() error {
+	_, err := r.reader.Peek(1)
+	return err
+}
+
+// readArray reads an array nested in depth others
+func (r *Resp) readArray(depth int) (Value, error) {
 	v := Value{}
 	v.Typ = "array"
 
-	len, _, err := r.readInteger()
+	l := GetLimits()
+	if depth >= l.MaxDepth {
+		return v, &ProtocolError{Reason: "too deep nesting of arrays"}
+	}
+	len, err := r.readLength(int64(l.MaxArrayLength), "invalid multibulk length")
 	if err != nil {
 		return v, err
 	}
+	if len == -1 {
+		return NullArray(), nil
+	}
+	// Elements are allocated at once, up to as many as fit in the read buffer, so a length
+	// header alone does not make the server allocate a huge array
+	v.Array = make([]Value, 0, min(len, r.reader.Size()/4))
 	for range len {
-		val, err := r.Read()
+		val, err := r.read(depth + 1)
 		if err != nil {
-			return v, err
+			return v, unexpectedEOF(err)
 		}
 
 		v.Array = append(v.Array, val)
@@ -56,53 +275,128 @@ func (r *Resp) readArray() (Value, error) {
 	return v, nil
 }
 
+// readLine reads a line and returns it without its CRLF terminator. The line is only valid
+// until the next read. A line ended by a lone LF, or longer than maxLineLength, is a protocol
+// error.
 func (r *Resp) readLine() (line []byte, n int, err error) {
-	for {
-		b, err := r.reader.ReadByte()
-		if err != nil {
-			return nil, 0, err
-		}
-		n += 1
-		line = append(line, b)
-		if len(line) >= 2 && line[len(line)-2] == '\r' {
-			break
+	line, err = r.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		r.line = append(r.line[:0], line...)
+		for err == bufio.ErrBufferFull && len(r.line) <= maxLineLength+2 {
+			line, err = r.reader.ReadSlice('\n')
+			r.line = append(r.line, line...)
 		}
+		line = r.line
+	}
+	n = len(line)
+	if len(line) > maxLineLength+2 {
+		return nil, n, &ProtocolError{Reason: "too big line"}
+	}
+	if err != nil {
+		return nil, n, unexpectedEOF(err)
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, n, &ProtocolError{Reason: "expected CRLF at the end of the line"}
 	}
 	return line[:len(line)-2], n, nil
 }
 
+// readLength reads the length of an array or a bulk string, -1 for a null one. A length that
+// is not a number, below -1 or above max is a protocol error with the given reason.
+func (r *Resp) readLength(max int64, reason string) (int, error) {
+	length, _, err := r.readInteger()
+	if _, notNumber := err.(*strconv.NumError); notNumber || (err == nil && (length < -1 || int64(length) > max)) {
+		return 0, &ProtocolError{Reason: reason}
+	}
+	return length, err
+}
+
 // returns the integer from the buffer and the number of bytes in the buffer.
 func (r *Resp) readInteger() (x int, n int, err error) {
 	line, n, err := r.readLine()
 	if err != nil {
 		return 0, 0, err
 	}
-	i64, err := strconv.ParseInt(string(line), 10, 64)
-	if err != nil {
-		return 0, n, err
+	i64, ok := parseInt(line)
+	if !ok {
+		// The slow path tells why the number doesn't parse, or parses the longest ones
+		i64, err = strconv.ParseInt(string(line), 10, 64)
+		if err != nil {
+			return 0, n, err
+		}
 	}
 	return int(i64), n, nil
 }
 
+// parseInt parses a decimal integer of up to 18 digits, which can't overflow, without
+// allocating. It reports false for anything else.
+func parseInt(b []byte) (int64, bool) {
+	negative := len(b) > 0 && b[0] == '-'
+	if negative {
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > 18 {
+		return 0, false
+	}
+	var n int64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if negative {
+		n = -n
+	}
+	return n, true
+}
+
 func (r *Resp) readBulk() (Value, error) {
 	v := Value{}
 	v.Typ = "bulk"
 
-	len, _, err := r.readInteger()
+	len, err := r.readLength(GetLimits().MaxBulkLength, "invalid bulk length")
 	if err != nil {
 		return v, err
 	}
+	if len == -1 {
+		return Null(), nil
+	}
+	r.frame += int64(len)
+	if max := GetLimits().MaxFrameSize; max > 0 && r.frame > max {
+		return v, &ProtocolError{Reason: "too big request"}
+	}
 
-	bulk := make([]byte, len)
-	r.reader.Read(bulk)
-	v.Bulk = string(bulk)
-
-	// read the CRLF
-	r.readLine()
+	// The bulk string is followed by a CRLF
+	bulk, err := r.readIntoArena(len + 2)
+	if err != nil {
+		return v, unexpectedEOF(err)
+	}
+	if bulk[len] != '\r' || bulk[len+1] != '\n' {
+		return v, &ProtocolError{Reason: "expected CRLF after the bulk string"}
+	}
+	v.Bulk = string(bulk[:len])
 
 	return v, nil
 }
 
+// readIntoArena reads the next n bytes into the arena and returns them. The arena grows by
+// at most bulkPreallocLength beyond the data that arrived, so a length header alone doesn't
+// make the server allocate a long bulk string.
+func (r *Resp) readIntoArena(n int) ([]byte, error) {
+	start := len(r.arena)
+	for remaining := n; remaining > 0; {
+		chunk := min(remaining, bulkPreallocLength)
+		end := len(r.arena)
+		r.arena = slices.Grow(r.arena, chunk)[:end+chunk]
+		if _, err := io.ReadFull(r.reader, r.arena[end:]); err != nil {
+			return nil, err
+		}
+		remaining -= chunk
+	}
+	return r.arena[start:], nil
+}
+
 // ReadBulkWithoutCRLF reads a bulk string without expecting trailing CRLF
 // This is used for reading RDB files which don't have trailing CRLF
 func (r *Resp) ReadBulkWithoutCRLF() (Value, error) {
@@ -122,12 +416,14 @@ func (r *Resp) ReadBulkWithoutCRLF() (Value, error) {
 
 	lengthStr := string(line[1:])
 	len, err := strconv.Atoi(lengthStr)
-	if err != nil {
+	if err != nil || len < 0 {
 		return v, fmt.Errorf("failed to parse bulk string length: %s", lengthStr)
 	}
 
 	bulk := make([]byte, len)
-	r.reader.Read(bulk)
+	if _, err := io.ReadFull(r.reader, bulk); err != nil {
+		return v, unexpectedEOF(err)
+	}
 	v.Bulk = string(bulk)
 
 	// Don't read the CRLF - RDB files don't have it
@@ -148,7 +444,30 @@ func (r *Resp) readString() (Value, error) {
 	return v, nil
 }
 
+// readIntegerValue reads an integer value
+func (r *Resp) readIntegerValue() (Value, error) {
+	num, _, err := r.readInteger()
+	if _, notNumber := err.(*strconv.NumError); notNumber {
+		return Value{}, &ProtocolError{Reason: "invalid integer"}
+	}
+	return Value{Typ: "integer", Num: num}, err
+}
+
+// Read reads the next value. Malformed input, like an unknown type byte, a negative length,
+// a length beyond the limits, a value whose bulk strings add up to more than MaxFrameSize or
+// a missing CRLF, returns a *ProtocolError, and input ending in the middle of a value returns
+// io.ErrUnexpectedEOF.
 func (r *Resp) Read() (Value, error) {
+	if cap(r.arena) > maxArenaKeepLength {
+		r.arena = nil
+	}
+	r.arena = r.arena[:0]
+	r.frame = 0
+	return r.read(0)
+}
+
+// read reads a value nested in depth arrays
+func (r *Resp) read(depth int) (Value, error) {
 	_type, err := r.reader.ReadByte()
 	if err != nil {
 		return Value{}, err
@@ -156,13 +475,18 @@ func (r *Resp) Read() (Value, error) {
 
 	switch _type {
 	case ARRAY:
-		return r.readArray()
+		return r.readArray(depth)
 	case BULK:
 		return r.readBulk()
 	case STRING:
 		return r.readString()
+	case ERROR:
+		v, err := r.readString()
+		v.Typ = "error"
+		return v, err
+	case INTEGER:
+		return r.readIntegerValue()
 	default:
-		fmt.Printf("Unknown type: %v\n", string(_type))
-		return Value{}, nil
+		return Value{}, &ProtocolError{Reason: fmt.Sprintf("unknown type byte %q", _type)}
 	}
 }
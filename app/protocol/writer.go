@@ -3,22 +3,68 @@ package protocol
 import "io"
 
 type Writer struct {
-	writer io.Writer
+	writer  io.Writer
+	version func() int // Protocol version replies are encoded in, RESP2 when nil
 }
 
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{writer: w}
 }
 
+// NewProtocolWriter creates a writer encoding each value in the protocol version returned by
+// version at the time it is written, so a reply to HELLO already uses the negotiated version
+func NewProtocolWriter(w io.Writer, version func() int) *Writer {
+	return &Writer{writer: w, version: version}
+}
+
+// Write encodes a value to the underlying writer. Arrays are written element by element,
+// so the elements of a streamed array go out as they are produced
 func (w *Writer) Write(v Value) error {
-	var bytes = v.Marshal()
+	version := RESP2
+	if w.version != nil {
+		version = w.version()
+	}
+	return w.write(v, version)
+}
+
+func (w *Writer) write(v Value, version int) error {
+	// Values are encoded into a pooled buffer, free to reuse once the underlying writer
+	// returned since writers do not keep the slice they are given
+	buf := GetBuffer()
+	defer PutBuffer(buf)
 
-	_, err := w.writer.Write(bytes)
-	if err != nil {
+	if v.Typ != "array" {
+		*buf = v.AppendProtocol(*buf, version)
+		_, err := w.writer.Write(*buf)
 		return err
 	}
 
-	return nil
+	if len(v.Attributes) > 0 && version >= RESP3 {
+		*buf = v.appendAttributes(*buf)
+	}
+	if v.Stream == nil {
+		*buf = appendInteger(*buf, ARRAY, len(v.Array))
+		if _, err := w.writer.Write(*buf); err != nil {
+			return err
+		}
+		for _, element := range v.Array {
+			if err := w.write(element, version); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	*buf = appendInteger(*buf, ARRAY, v.Stream.Len)
+	if _, err := w.writer.Write(*buf); err != nil {
+		return err
+	}
+	var err error
+	v.Stream.each(func(element Value) bool {
+		err = w.write(element, version)
+		return err == nil
+	})
+	return err
 }
 
 // Flush flushes the underlying writer if it implements Flusher interface
@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriterPooledBuffers(t *testing.T) {
+	reply := Value{Typ: "array", Array: []Value{
+		{Typ: "bulk", Bulk: "value"},
+		{Typ: "integer", Num: 42},
+		{Typ: "map", Array: []Value{{Typ: "bulk", Bulk: "field"}, {Typ: "boolean", Bool: true}}},
+	}}
+
+	// Writing a value produces the same bytes as marshalling it, in both versions
+	for _, version := range []int{RESP2, RESP3} {
+		var out bytes.Buffer
+		writer := NewProtocolWriter(&out, func() int { return version })
+		if err := writer.Write(reply); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if expected := string(reply.MarshalProtocol(version)); out.String() != expected {
+			t.Errorf("RESP%d: expected %q, got %q", version, expected, out.String())
+		}
+	}
+
+	// A propagated command encodes like the array of its name and arguments
+	args := []Value{{Typ: "bulk", Bulk: "key"}, {Typ: "bulk", Bulk: "value"}}
+	command := Value{Typ: "array", Array: append([]Value{{Typ: "bulk", Bulk: "SET"}}, args...)}
+	if encoded := string(AppendCommand(nil, "SET", args)); encoded != string(command.Marshal()) {
+		t.Errorf("Expected %q, got %q", command.Marshal(), encoded)
+	}
+
+	// Replies are encoded into pooled buffers rather than a fresh slice each
+	if raceEnabled {
+		t.Skip("The race detector allocates on its own")
+	}
+	writer := NewWriter(io.Discard)
+	allocs := testing.AllocsPerRun(100, func() {
+		writer.Write(reply)
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations per reply, got %v", allocs)
+	}
+}
@@ -1,165 +1,7 @@
+// Package pubsub holds the rules of subscribed mode. The subscriptions themselves belong to
+// each client, see network.Client.Subscribe and network.Publish.
 package pubsub
 
-import (
-	"fmt"
-	"net"
-	"sync"
-
-	"github.com/codecrafters-io/redis-starter-go/app/protocol"
-)
-
-// subscriptionsMu is the mutex for the subscriptions map
-var subscriptionsMu sync.RWMutex
-
-// Subscriptions is the global map of subscriptions.
-// The key is the connection ID, the value is a slice of subscribed channels.
-var Subscriptions = make(map[string][]string)
-
-// subscribedModeMu is the mutex for the subscribed mode map
-var subscribedModeMu sync.RWMutex
-
-// SubscribedMode tracks which clients are in subscribed mode.
-// The key is the connection ID, the value is true if the client is in subscribed mode.
-var SubscribedMode = make(map[string]bool)
-
-// SubscriptionsSet adds a subscription for a connection ID
-func SubscriptionsSet(connID string, channel string) {
-	subscriptionsMu.Lock()
-	defer subscriptionsMu.Unlock()
-
-	// Check if connection already exists
-	if channels, exists := Subscriptions[connID]; exists {
-		// Check if channel is already subscribed (linear search is fine for small lists)
-		for _, existingChannel := range channels {
-			if existingChannel == channel {
-				return // Already subscribed to this channel
-			}
-		}
-		// Add new channel to existing list
-		Subscriptions[connID] = append(channels, channel)
-	} else {
-		// Create new subscription list
-		Subscriptions[connID] = []string{channel}
-	}
-}
-
-// SubscriptionsGet gets all subscriptions for a connection ID
-func SubscriptionsGet(connID string) ([]string, bool) {
-	subscriptionsMu.RLock()
-	defer subscriptionsMu.RUnlock()
-	channels, ok := Subscriptions[connID]
-	return channels, ok
-}
-
-// SubscriptionsDelete deletes a subscription for a connection ID
-func SubscriptionsDelete(connID string) {
-	subscriptionsMu.Lock()
-	delete(Subscriptions, connID)
-	subscriptionsMu.Unlock()
-}
-
-// SubscriptionsSetChannels sets the entire subscription list for a connection ID
-func SubscriptionsSetChannels(connID string, channels []string) {
-	subscriptionsMu.Lock()
-	Subscriptions[connID] = channels
-	subscriptionsMu.Unlock()
-}
-
-// SubscriptionsCountForChannel counts the number of clients subscribed to a specific channel
-func SubscriptionsCountForChannel(channel string) int {
-	subscriptionsMu.RLock()
-	defer subscriptionsMu.RUnlock()
-
-	count := 0
-	for _, channels := range Subscriptions {
-		for _, subscribedChannel := range channels {
-			if subscribedChannel == channel {
-				count++
-				break // Each connection can only be counted once per channel
-			}
-		}
-	}
-	return count
-}
-
-// SubscriptionsGetSubscribersForChannel returns all connection IDs subscribed to a specific channel
-func SubscriptionsGetSubscribersForChannel(channel string) []string {
-	subscriptionsMu.RLock()
-	defer subscriptionsMu.RUnlock()
-
-	var subscribers []string
-	for connID, channels := range Subscriptions {
-		for _, subscribedChannel := range channels {
-			if subscribedChannel == channel {
-				subscribers = append(subscribers, connID)
-				break // Each connection can only be counted once per channel
-			}
-		}
-	}
-	return subscribers
-}
-
-// SendMessageToSubscribers sends a message to all subscribers of a channel
-// This function requires access to the Connections map from the shared package
-func SendMessageToSubscribers(channel string, message string, connectionsGetter func(string) (net.Conn, bool), connectionsDeleter func(string), subscriptionsDeleter func(string), subscribedModeDeleter func(string)) int {
-	subscribers := SubscriptionsGetSubscribersForChannel(channel)
-
-	// Create the message array: ["message", channel, message]
-	messageArray := protocol.Value{
-		Typ: "array",
-		Array: []protocol.Value{
-			{Typ: "bulk", Bulk: "message"},
-			{Typ: "bulk", Bulk: channel},
-			{Typ: "bulk", Bulk: message},
-		},
-	}
-
-	messageBytes := messageArray.Marshal()
-	deliveredCount := 0
-
-	// Send message to each subscriber
-	for _, connID := range subscribers {
-		if conn, exists := connectionsGetter(connID); exists {
-			_, err := conn.Write(messageBytes)
-			if err != nil {
-				// Remove failed connection
-				connectionsDeleter(connID)
-				subscriptionsDeleter(connID)
-				subscribedModeDeleter(connID)
-				fmt.Printf("Failed to send message to subscriber %s: %v\n", connID, err)
-			} else {
-				deliveredCount++
-			}
-		} else {
-			// In test environment, connections might not exist
-			// Still count them as "delivered" for testing purposes
-			deliveredCount++
-		}
-	}
-
-	return deliveredCount
-}
-
-// SubscribedMode helpers
-func SubscribedModeSet(connID string) {
-	subscribedModeMu.Lock()
-	SubscribedMode[connID] = true
-	subscribedModeMu.Unlock()
-}
-
-// SubscribedModeGet gets the subscribed mode for a connection ID
-func SubscribedModeGet(connID string) bool {
-	subscribedModeMu.RLock()
-	defer subscribedModeMu.RUnlock()
-	return SubscribedMode[connID]
-}
-
-func SubscribedModeDelete(connID string) {
-	subscribedModeMu.Lock()
-	delete(SubscribedMode, connID)
-	subscribedModeMu.Unlock()
-}
-
 // IsAllowedInSubscribedMode checks if a command is allowed when client is in subscribed mode
 func IsAllowedInSubscribedMode(command string) bool {
 	allowedCommands := map[string]bool{
@@ -173,26 +15,3 @@ func IsAllowedInSubscribedMode(command string) bool {
 	}
 	return allowedCommands[command]
 }
-
-// Test helper functions to access global variables for testing
-// These are needed for backward compatibility with existing tests
-
-// GetSubscriptionsMap returns the global subscriptions map for testing
-func GetSubscriptionsMap() map[string][]string {
-	return Subscriptions
-}
-
-// GetSubscribedModeMap returns the global subscribed mode map for testing
-func GetSubscribedModeMap() map[string]bool {
-	return SubscribedMode
-}
-
-// SetSubscriptionsMap sets the global subscriptions map for testing
-func SetSubscriptionsMap(subscriptions map[string][]string) {
-	Subscriptions = subscriptions
-}
-
-// SetSubscribedModeMap sets the global subscribed mode map for testing
-func SetSubscribedModeMap(subscribedMode map[string]bool) {
-	SubscribedMode = subscribedMode
-}
@@ -0,0 +1,10 @@
+package server
+
+import "sync"
+
+// CommandLock is held for reading by every write command while it changes the dataset and
+// propagates the change, and for writing by the work that must not see a change that isn't
+// propagated yet, like taking the snapshot a replica continues from. A write is then either
+// in the snapshot or in the replication stream that follows it, never in both. Read commands
+// don't take it, the locks of the store are enough for them.
+var CommandLock sync.RWMutex
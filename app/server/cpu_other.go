@@ -0,0 +1,10 @@
+//go:build !unix
+
+package server
+
+import "time"
+
+// CPUTime returns the user and system CPU time used by the server, which is not available on this platform
+func CPUTime() (user, system time.Duration) {
+	return 0, 0
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"sync/atomic"
+)
+
+// dirty counts every change made to the dataset since the server started: the keys reported
+// to NotifyKeyModified and the changes without keys, like loaded functions. The changes of
+// the command a client runs are counted by its network.Client, to decide its propagation.
+var dirty atomic.Int64
+
+// AddDirty counts changes made to the dataset without changing keys, like a loaded function
+func AddDirty(changes int) {
+	dirty.Add(int64(changes))
+}
+
+// Dirty returns the total number of changes made to the dataset
+func Dirty() int64 {
+	return dirty.Load()
+}
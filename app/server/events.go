@@ -0,0 +1,92 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// KeyHook is called with a key removed from the dataset
+type KeyHook func(key string)
+
+// KeyEventHook is called with a key that changed and the event that changed it, the lowercase
+// name of the write command like "set" or "lpush", or "expired" and "evicted"
+type KeyEventHook func(key string, event string)
+
+// keyHooks holds the hooks called on key events, replaced as a whole when a hook is added so
+// the paths changing keys read them without a lock
+type keyHooks struct {
+	expired []KeyHook
+	evicted []KeyHook
+	changed []KeyEventHook
+}
+
+// keyHooksMu serializes the changes to currentKeyHooks
+var keyHooksMu sync.Mutex
+var currentKeyHooks atomic.Pointer[keyHooks]
+
+func init() {
+	currentKeyHooks.Store(&keyHooks{})
+}
+
+// updateKeyHooks replaces the hooks with a copy changed by f
+func updateKeyHooks(f func(h *keyHooks)) {
+	keyHooksMu.Lock()
+	defer keyHooksMu.Unlock()
+	current := currentKeyHooks.Load()
+	h := &keyHooks{
+		expired: append([]KeyHook(nil), current.expired...),
+		evicted: append([]KeyHook(nil), current.evicted...),
+		changed: append([]KeyEventHook(nil), current.changed...),
+	}
+	f(h)
+	currentKeyHooks.Store(h)
+}
+
+// AddExpiredHook registers a hook called with each key removed because its expiration passed,
+// whether it was found by a command or by the expire cycle
+func AddExpiredHook(hook KeyHook) {
+	updateKeyHooks(func(h *keyHooks) { h.expired = append(h.expired, hook) })
+}
+
+// AddEvictedHook registers a hook called with each key evicted to free memory
+func AddEvictedHook(hook KeyHook) {
+	updateKeyHooks(func(h *keyHooks) { h.evicted = append(h.evicted, hook) })
+}
+
+// AddKeyChangedHook registers a hook called with each key a write command changed, expired or
+// was evicted
+func AddKeyChangedHook(hook KeyEventHook) {
+	updateKeyHooks(func(h *keyHooks) { h.changed = append(h.changed, hook) })
+}
+
+// ClearKeyHooks removes every key hook
+func ClearKeyHooks() {
+	keyHooksMu.Lock()
+	defer keyHooksMu.Unlock()
+	currentKeyHooks.Store(&keyHooks{})
+}
+
+// NotifyKeyModified records a change to key in database db, the only one the server has being
+// 0. Every path changing the dataset calls it once for each key it changed, whether a command,
+// a transaction, a write replicated from the master, the expire cycle or an eviction, so the
+// dirty counter and the hooks waiting for changes, the way WATCH, client tracking and keyspace
+// notifications do, see every change. event is the lowercase name of the write command, like
+// "set" or "lpush", or "expired" and "evicted".
+func NotifyKeyModified(db int, key string, event string) {
+	dirty.Add(1)
+
+	hooks := currentKeyHooks.Load()
+	switch event {
+	case "expired":
+		for _, hook := range hooks.expired {
+			hook(key)
+		}
+	case "evicted":
+		for _, hook := range hooks.evicted {
+			hook(key)
+		}
+	}
+	for _, hook := range hooks.changed {
+		hook(key, event)
+	}
+}
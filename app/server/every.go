@@ -0,0 +1,34 @@
+package server
+
+import "time"
+
+// Every calls fn with the current time every interval on a goroutine of its own, until the
+// returned function is called. Stopping waits for a call in progress to return, so fn is
+// never called once stop returned.
+//
+// Examples:
+//
+//	stop := server.Every(time.Second, func(now time.Time) { checkSomething(now) })
+//	defer stop()
+func Every(interval time.Duration, fn func(now time.Time)) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				fn(now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
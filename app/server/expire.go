@@ -0,0 +1,77 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	activeExpireInterval        = 100 * time.Millisecond // Time between two expire cycles
+	activeExpireKeysPerLoop     = 20                     // Keys with an expiration sampled per loop
+	activeExpireAcceptableStale = 25                     // Percentage of expired keys in a sample below which a cycle stops
+	activeExpireCycleTimeLimit  = 25 * time.Millisecond  // Time an expire cycle may run for
+)
+
+// activeExpireEnabled toggles the expire cycle, set with DEBUG SET-ACTIVE-EXPIRE
+var activeExpireEnabled atomic.Bool
+
+func init() {
+	activeExpireEnabled.Store(true)
+}
+
+// SetActiveExpire enables or disables the expire cycle. Expired keys are still removed
+// when they are accessed.
+func SetActiveExpire(enabled bool) {
+	activeExpireEnabled.Store(enabled)
+}
+
+// ActiveExpireEnabled reports whether the expire cycle runs
+func ActiveExpireEnabled() bool {
+	return activeExpireEnabled.Load()
+}
+
+// StartActiveExpire runs the expire cycle in the background, removing keys whose expiration
+// passed even when nobody accesses them, until the returned function is called
+func StartActiveExpire() (stop func()) {
+	return Every(activeExpireInterval, func(time.Time) {
+		if ActiveExpireEnabled() {
+			ActiveExpireCycle()
+		}
+	})
+}
+
+// ActiveExpireCycle samples keys with an expiration and removes the expired ones. It keeps
+// sampling while more than activeExpireAcceptableStale percent of a sample was expired, for
+// at most activeExpireCycleTimeLimit, and returns the number of keys removed.
+func ActiveExpireCycle() int {
+	start := time.Now()
+	removed := 0
+	for {
+		now := time.Now().UnixMilli()
+		sampled, expired := 0, 0
+		// Only keys with an expiration are ranged, in a random order, so the keys visited form
+		// a different sample each loop. Expired keys are removed once the range releases the store.
+		var candidates []string
+		Memory.RangeExpires(func(key string, expires int64) bool {
+			sampled++
+			if expires <= now {
+				candidates = append(candidates, key)
+			}
+			return sampled < activeExpireKeysPerLoop
+		})
+		for _, key := range candidates {
+			// The key may have been written again since it was sampled
+			if Memory.DeleteIfExpired(key, now) {
+				KeyExpired(key)
+				expired++
+			}
+		}
+		removed += expired
+
+		if sampled == 0 || expired*100/sampled <= activeExpireAcceptableStale || time.Since(start) > activeExpireCycleTimeLimit {
+			break
+		}
+	}
+	LatencyAddSampleIfNeeded(LatencyEventExpireCycle, time.Since(start))
+	return removed
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// A LatencyHistogram counts durations in nanoseconds in log-linear buckets, like an HDR
+// histogram: values below 64 have a bucket each, and every power of two above is split in
+// 32 buckets of equal width, so a percentile is found within about 3% of the recorded value
+// whatever its magnitude, in constant memory. Counts are atomic, so calls running on
+// different connections record their duration without a lock.
+const (
+	histogramSubBucketBits = 5
+	histogramSubBuckets    = 1 << histogramSubBucketBits
+	histogramLinearMax     = 2 * histogramSubBuckets // Values below it have a bucket each
+	histogramBuckets       = histogramLinearMax + (64-histogramSubBucketBits-2)*histogramSubBuckets
+)
+
+// LatencyHistogram records the durations of a command. The zero value is an empty histogram.
+type LatencyHistogram struct {
+	counts [histogramBuckets]atomic.Int64
+	total  atomic.Int64 // Incremented after the bucket, so readers never see more than the buckets hold
+	max    atomic.Int64
+}
+
+// histogramIndex returns the bucket of a value
+func histogramIndex(v int64) int {
+	if v < histogramLinearMax {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - histogramSubBucketBits - 1
+	return histogramLinearMax + (shift-1)*histogramSubBuckets + int(v>>shift) - histogramSubBuckets
+}
+
+// histogramBucketMax returns the highest value of a bucket
+func histogramBucketMax(index int) int64 {
+	if index < histogramLinearMax {
+		return int64(index)
+	}
+	index -= histogramLinearMax
+	shift := index/histogramSubBuckets + 1
+	low := int64(index%histogramSubBuckets+histogramSubBuckets) << shift
+	return low + 1<<shift - 1
+}
+
+// Record adds a duration in nanoseconds, negative ones count as 0
+func (h *LatencyHistogram) Record(ns int64) {
+	ns = max(ns, 0)
+	h.counts[histogramIndex(ns)].Add(1)
+	h.total.Add(1)
+	for {
+		current := h.max.Load()
+		if ns <= current || h.max.CompareAndSwap(current, ns) {
+			return
+		}
+	}
+}
+
+// Count returns the number of recorded durations
+func (h *LatencyHistogram) Count() int64 {
+	return h.total.Load()
+}
+
+// Percentile returns the duration in nanoseconds that percentile percent of the recorded
+// durations don't exceed, 0 when none was recorded
+func (h *LatencyHistogram) Percentile(percentile float64) int64 {
+	total := h.total.Load()
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(percentile / 100 * float64(total)))
+	rank = min(max(rank, 1), total)
+	seen := int64(0)
+	for i := range h.counts {
+		seen += h.counts[i].Load()
+		if seen >= rank {
+			return min(histogramBucketMax(i), h.max.Load())
+		}
+	}
+	return h.max.Load()
+}
+
+// PowerOfTwoBuckets returns the cumulative number of durations up to each power of two of
+// microseconds, from 1 up to the one holding the longest duration, as LATENCY HISTOGRAM
+// reports them
+func (h *LatencyHistogram) PowerOfTwoBuckets() (bounds []int64, counts []int64) {
+	total := h.total.Load()
+	if total == 0 {
+		return nil, nil
+	}
+	seen := int64(0)
+	next := 0
+	for bound := int64(1); ; bound *= 2 {
+		// A bucket across a boundary is counted under the next one
+		for next < histogramBuckets && histogramBucketMax(next) < bound*1000 {
+			seen += h.counts[next].Load()
+			next++
+		}
+		bounds = append(bounds, bound)
+		counts = append(counts, seen)
+		if seen >= total || next == histogramBuckets {
+			return bounds, counts
+		}
+	}
+}
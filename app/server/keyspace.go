@@ -0,0 +1,43 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// keyspaceHits and keyspaceMisses count the lookups of read commands that found a key or not
+var keyspaceHits atomic.Int64
+var keyspaceMisses atomic.Int64
+
+// LookupKeyRead returns the entry of key for a read command, counting a keyspace hit or miss.
+// An expired key is removed and reported missing.
+func LookupKeyRead(key string) (shared.MemoryEntry, bool) {
+	entry, exists := Memory.Get(key)
+	if exists && entry.Expires > 0 && time.Now().UnixMilli() > entry.Expires {
+		start := time.Now()
+		if Memory.DeleteIfExpired(key, start.UnixMilli()) {
+			LatencyAddSampleIfNeeded(LatencyEventExpireDel, time.Since(start))
+			KeyExpired(key)
+		}
+		exists = false
+	}
+
+	if exists {
+		keyspaceHits.Add(1)
+	} else {
+		keyspaceMisses.Add(1)
+	}
+	return entry, exists
+}
+
+// KeyspaceHits returns the number of successful key lookups of read commands
+func KeyspaceHits() int64 {
+	return keyspaceHits.Load()
+}
+
+// KeyspaceMisses returns the number of failed key lookups of read commands
+func KeyspaceMisses() int64 {
+	return keyspaceMisses.Load()
+}
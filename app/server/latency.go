@@ -0,0 +1,125 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latency events
+const (
+	LatencyEventCommand     = "command"      // A slow command
+	LatencyEventFastCommand = "fast-command" // A slow command flagged as fast
+	LatencyEventExpireCycle = "expire-cycle" // A run of the active expire cycle
+	LatencyEventExpireDel   = "expire-del"   // Deleting a key whose expiration passed
+	LatencyEventSave        = "save"         // A synchronous save of the RDB file
+)
+
+// latencyHistoryLen is the number of samples kept per event
+const latencyHistoryLen = 160
+
+// LatencySample is a latency spike of an event
+type LatencySample struct {
+	Time    int64 // Unix timestamp in seconds of the spike
+	Latency int64 // Latency in milliseconds
+}
+
+// LatencyEventStats summarizes the spikes of an event
+type LatencyEventStats struct {
+	Name    string
+	Time    int64 // Unix timestamp in seconds of the latest spike
+	Latest  int64 // Latency in milliseconds of the latest spike
+	Max     int64 // Highest latency in milliseconds recorded since the last reset
+	Samples []LatencySample
+}
+
+// latencyMu protects latencyEvents
+var latencyMu sync.Mutex
+var latencyEvents = make(map[string]*LatencyEventStats)
+
+// LatencyAddSampleIfNeeded records a spike of event when d reaches latency-monitor-threshold.
+// A threshold of 0 disables latency monitoring.
+func LatencyAddSampleIfNeeded(event string, d time.Duration) {
+	threshold := int64(StoreState.LatencyMonitorThreshold)
+	latency := d.Milliseconds()
+	if threshold <= 0 || latency < threshold {
+		return
+	}
+	latencyAddSample(event, time.Now().Unix(), latency)
+}
+
+// latencyAddSample records a spike of event, spikes happening in the same second are merged
+// keeping the highest latency
+func latencyAddSample(event string, now, latency int64) {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+
+	stats, exists := latencyEvents[event]
+	if !exists {
+		stats = &LatencyEventStats{Name: event}
+		latencyEvents[event] = stats
+	}
+	stats.Time = now
+	stats.Latest = latency
+	if latency > stats.Max {
+		stats.Max = latency
+	}
+
+	if n := len(stats.Samples); n > 0 && stats.Samples[n-1].Time == now {
+		if latency > stats.Samples[n-1].Latency {
+			stats.Samples[n-1].Latency = latency
+		}
+		return
+	}
+	stats.Samples = append(stats.Samples, LatencySample{Time: now, Latency: latency})
+	if len(stats.Samples) > latencyHistoryLen {
+		stats.Samples = stats.Samples[len(stats.Samples)-latencyHistoryLen:]
+	}
+}
+
+// LatencyHistory returns the spikes of event, oldest first
+func LatencyHistory(event string) []LatencySample {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	stats, exists := latencyEvents[event]
+	if !exists {
+		return nil
+	}
+	return append([]LatencySample(nil), stats.Samples...)
+}
+
+// LatencyLatest returns the statistics of every event with recorded spikes, sorted by name
+func LatencyLatest() []LatencyEventStats {
+	latencyMu.Lock()
+	all := make([]LatencyEventStats, 0, len(latencyEvents))
+	for _, stats := range latencyEvents {
+		copied := *stats
+		copied.Samples = append([]LatencySample(nil), stats.Samples...)
+		all = append(all, copied)
+	}
+	latencyMu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// LatencyReset drops the spikes of the given events, or of every event when none is given,
+// and returns the number of events reset
+func LatencyReset(events ...string) int {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+
+	if len(events) == 0 {
+		reset := len(latencyEvents)
+		latencyEvents = make(map[string]*LatencyEventStats)
+		return reset
+	}
+	reset := 0
+	for _, event := range events {
+		if _, exists := latencyEvents[event]; exists {
+			delete(latencyEvents, event)
+			reset++
+		}
+	}
+	return reset
+}
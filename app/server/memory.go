@@ -0,0 +1,104 @@
+package server
+
+import (
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+const (
+	memorySamples       = 5  // Elements of a list, set or stream the size of the others is estimated from
+	memoryKeyOverhead   = 64 // Bytes of bookkeeping for every key, its map slot and entry header
+	memoryStringHeader  = 16 // Bytes of the header of a Go string
+	memoryMapSlot       = 16 // Bytes of overhead for every element of a Go map
+	memoryListNode      = 32 // Bytes of a list node besides its value
+	memorySortedSetNode = 8  // Bytes of the score of a sorted set member
+)
+
+// EntrySize returns the approximate number of bytes key and its entry use. Lists, sets,
+// sorted sets, hashes and streams are estimated from a few of their elements, like Redis
+// does for MEMORY USAGE, so the size of any entry is found in constant time. The store
+// keeps the size of every key up to date as it is written.
+func EntrySize(key string, entry shared.MemoryEntry) int64 {
+	size := int64(memoryKeyOverhead + len(key) + len(entry.Value))
+
+	if entry.List != nil && entry.List.Size > 0 {
+		sampled, bytes := 0, 0
+		for node := entry.List.Head; node != nil && sampled < memorySamples; node = node.Next {
+			bytes += memoryListNode + len(node.Value)
+			sampled++
+		}
+		size += estimate(entry.List.Size, sampled, bytes)
+	}
+
+	if len(entry.Array) > 0 {
+		bytes := 0
+		sampled := min(len(entry.Array), memorySamples)
+		for _, value := range entry.Array[:sampled] {
+			bytes += memoryStringHeader + len(value)
+		}
+		size += estimate(len(entry.Array), sampled, bytes)
+	}
+
+	if entry.SortedSet != nil && entry.SortedSet.Size > 0 {
+		// Packed sets have no map slot per member
+		overhead := memoryStringHeader + memorySortedSetNode
+		if !entry.SortedSet.Packed() {
+			overhead += memoryMapSlot
+		}
+		sampled, bytes := 0, 0
+		entry.SortedSet.Range(func(member string, _ float64) bool {
+			bytes += overhead + len(member)
+			sampled++
+			return sampled < memorySamples
+		})
+		size += estimate(entry.SortedSet.Size, sampled, bytes)
+	}
+
+	if len(entry.Set) > 0 {
+		sampled, bytes := 0, 0
+		for member := range entry.Set {
+			if sampled == memorySamples {
+				break
+			}
+			bytes += memoryMapSlot + memoryStringHeader + len(member)
+			sampled++
+		}
+		size += estimate(len(entry.Set), sampled, bytes)
+	}
+
+	if len(entry.Hash) > 0 {
+		size += mapSize(entry.Hash)
+	}
+
+	if len(entry.Stream) > 0 {
+		bytes := 0
+		sampled := min(len(entry.Stream), memorySamples)
+		for _, streamEntry := range entry.Stream[:sampled] {
+			bytes += memoryStringHeader + len(streamEntry.ID) + int(mapSize(streamEntry.Data))
+		}
+		size += estimate(len(entry.Stream), sampled, bytes)
+	}
+
+	return size
+}
+
+// mapSize returns the approximate number of bytes of a map of strings, estimated from a few
+// of its fields
+func mapSize(m map[string]string) int64 {
+	sampled, bytes := 0, 0
+	for field, value := range m {
+		if sampled == memorySamples {
+			break
+		}
+		bytes += memoryMapSlot + 2*memoryStringHeader + len(field) + len(value)
+		sampled++
+	}
+	return estimate(len(m), sampled, bytes)
+}
+
+// estimate scales the bytes of sampled elements to all n elements
+func estimate(n, sampled, bytes int) int64 {
+	if sampled == 0 {
+		return 0
+	}
+	return int64(bytes) * int64(n) / int64(sampled)
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	slowlogMaxArgs   = 32  // Arguments kept in an entry, the last one notes how many were left out
+	slowlogMaxArgLen = 128 // Bytes kept of each argument
+)
+
+// SlowlogEntry is a command whose execution took longer than slowlog-log-slower-than
+type SlowlogEntry struct {
+	ID         int64    // Unique, increasing identifier
+	Timestamp  int64    // Unix timestamp in seconds the command was executed
+	Duration   int64    // Execution time in microseconds
+	Args       []string // Command name and arguments, possibly shortened
+	ClientAddr string   // Address of the client that ran the command
+	ClientName string   // Name of the client set with CLIENT SETNAME
+}
+
+// slowlogMu protects slowlog and slowlogNextID
+var slowlogMu sync.Mutex
+
+// slowlog holds the logged entries, newest first
+var slowlog []SlowlogEntry
+var slowlogNextID int64
+
+// SlowlogEnabled reports whether a command that took d is slow enough to be logged
+func SlowlogEnabled(d time.Duration) bool {
+	threshold := StoreState.SlowlogLogSlowerThan
+	return threshold >= 0 && d.Microseconds() >= int64(threshold)
+}
+
+// SlowlogRecord logs a command that took d, when it took longer than slowlog-log-slower-than.
+// args holds the command name followed by its arguments.
+func SlowlogRecord(args []string, d time.Duration, clientAddr, clientName string) {
+	if !SlowlogEnabled(d) {
+		return
+	}
+
+	// Long commands are shortened, so the log never holds a huge payload
+	count := len(args)
+	if count > slowlogMaxArgs {
+		count = slowlogMaxArgs
+	}
+	kept := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if i == slowlogMaxArgs-1 && len(args) > slowlogMaxArgs {
+			kept = append(kept, fmt.Sprintf("... (%d more arguments)", len(args)-slowlogMaxArgs+1))
+			break
+		}
+		arg := args[i]
+		if len(arg) > slowlogMaxArgLen {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:slowlogMaxArgLen], len(arg)-slowlogMaxArgLen)
+		}
+		kept = append(kept, arg)
+	}
+
+	slowlogMu.Lock()
+	defer slowlogMu.Unlock()
+	entry := SlowlogEntry{
+		ID:         slowlogNextID,
+		Timestamp:  time.Now().Unix(),
+		Duration:   d.Microseconds(),
+		Args:       kept,
+		ClientAddr: clientAddr,
+		ClientName: clientName,
+	}
+	slowlogNextID++
+	slowlog = append([]SlowlogEntry{entry}, slowlog...)
+	if maxLen := StoreState.SlowlogMaxLen; len(slowlog) > maxLen {
+		slowlog = slowlog[:max(maxLen, 0)]
+	}
+}
+
+// SlowlogGet returns up to count entries, newest first, a negative count returns all of them
+func SlowlogGet(count int) []SlowlogEntry {
+	slowlogMu.Lock()
+	defer slowlogMu.Unlock()
+	if count < 0 || count > len(slowlog) {
+		count = len(slowlog)
+	}
+	entries := make([]SlowlogEntry, count)
+	copy(entries, slowlog)
+	return entries
+}
+
+// SlowlogLen returns the number of entries in the slow log
+func SlowlogLen() int {
+	slowlogMu.Lock()
+	defer slowlogMu.Unlock()
+	return len(slowlog)
+}
+
+// SlowlogReset empties the slow log, entry IDs keep increasing
+func SlowlogReset() {
+	slowlogMu.Lock()
+	defer slowlogMu.Unlock()
+	slowlog = nil
+}
@@ -9,16 +9,96 @@ import (
 // Global server state
 var StoreState = &shared.State{
 	Role:             "master",
+	Port:             "6379",
 	ReplicaOf:        "",
 	MasterReplID:     "",
 	MasterReplOffset: 0,
 	Replicas:         make(map[string]net.Conn),
 	ConfigDir:        "/tmp/redis-data",
 	ConfigDbfilename: "rdbfile",
+
+	MinReplicasToWrite: 0,
+	MinReplicasMaxLag:  10,
+
+	ReplicaServeStaleData: true,
+	ReplDisklessSync:      false,
+	ReplDisklessSyncDelay: 5,
+
+	RDBCompression: true,
+	RDBChecksum:    true,
+
+	SavePoints: []shared.SavePoint{{Seconds: 3600, Changes: 1}, {Seconds: 300, Changes: 100}, {Seconds: 60, Changes: 10000}},
+
+	AppendOnly:               false,
+	AppendFilename:           "appendonly.aof",
+	AppendDirname:            "appendonlydir",
+	AppendFsync:              "everysec",
+	AutoAOFRewritePercentage: 100,
+	AutoAOFRewriteMinSize:    64 * 1024 * 1024,
+	AOFUseRDBPreamble:        true,
+	AOFTimestampEnabled:      false,
+
+	ShutdownTimeout: 10,
+
+	BusyReplyThreshold: 5000,
+
+	MaxMemory: 0,
+
+	SlowlogLogSlowerThan: 10000,
+	SlowlogMaxLen:        128,
+
+	LatencyMonitorThreshold: 0,
+
+	LatencyTracking:                true,
+	LatencyTrackingInfoPercentiles: []float64{50, 99, 99.9},
+
+	Bind:          "",
+	ProtectedMode: true,
+
+	Timeout:      0,
+	TCPKeepalive: 300,
+
+	MetricsPort:   0,
+	DebugPort:     0,
+	HTTPPort:      0,
+	MemcachedPort: 0,
+
+	LogLevel: "notice",
+	LogFile:  "",
+
+	RequirePass: "",
+	ACLFile:     "",
+
+	ProtoMaxBulkLen:      512 * 1024 * 1024,
+	ProtoMaxMultibulkLen: 1024 * 1024,
+	ProtoMaxNestingDepth: 32,
+
+	ClientQueryBufferLimit: 1024 * 1024 * 1024,
+
+	ClientOutputBufferLimits: map[string]shared.OutputBufferLimit{
+		"normal": {Hard: 0, Soft: 0, SoftSeconds: 0},
+		"slave":  {Hard: 256 * 1024 * 1024, Soft: 64 * 1024 * 1024, SoftSeconds: 60},
+		"pubsub": {Hard: 32 * 1024 * 1024, Soft: 8 * 1024 * 1024, SoftSeconds: 60},
+	},
+
+	ClientRateLimitCommands: 0,
+	ClientRateLimitBytes:    0,
+	RateLimitCommands:       0,
+	RateLimitBytes:          0,
+
+	AuditLogFile:     "",
+	AuditLogMaxSize:  64 * 1024 * 1024,
+	AuditLogMaxFiles: 5,
+
+	ListMaxListpackSize:    -2,
+	ZsetMaxListpackEntries: 128,
+	ZsetMaxListpackValue:   64,
+
+	SingleWriter: false,
 }
 
 // Memory is the global in-memory database that stores all key-value pairs.
-var Memory = make(map[string]shared.MemoryEntry)
+var Memory Store = NewShardedStore()
 
 // Helper functions for test compatibility
 func SetStoreState(state shared.State) {
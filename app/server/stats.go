@@ -0,0 +1,315 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// startTime is when the server started, reported as uptime by INFO
+var startTime = time.Now()
+
+// totalConnections counts the client connections accepted since startup
+var totalConnections atomic.Int64
+
+// totalCommands counts the commands executed since startup
+var totalCommands atomic.Int64
+
+// expiredKeys counts the keys removed because their expiration passed
+var expiredKeys atomic.Int64
+
+// blockedClients is the number of clients waiting in a blocking command
+var blockedClients atomic.Int64
+
+// bigRangeReplies counts the range replies large enough to be streamed to the client
+var bigRangeReplies atomic.Int64
+
+// rejectedRangeReplies counts the range commands refused for replying more than range-reply-max-elements
+var rejectedRangeReplies atomic.Int64
+
+// rateLimitedCommands counts the commands refused for going over a rate limit
+var rateLimitedCommands atomic.Int64
+
+// outputBufferLimitDisconnections counts the clients disconnected for going over their output buffer limit
+var outputBufferLimitDisconnections atomic.Int64
+
+// CommandStat holds the execution statistics of a command
+type CommandStat struct {
+	Name          string // Lowercase command name
+	Calls         int64
+	Usec          int64 // Total execution time in microseconds
+	RejectedCalls int64 // Calls refused before running, like with the wrong number of arguments
+	FailedCalls   int64 // Calls that ran and replied with an error
+}
+
+// commandCounters holds the statistics of a command. They are updated without a lock, so
+// commands running on different connections don't wait for each other to be counted.
+type commandCounters struct {
+	calls         atomic.Int64
+	usec          atomic.Int64
+	rejectedCalls atomic.Int64
+	failedCalls   atomic.Int64
+	latency       atomic.Pointer[LatencyHistogram] // nil until latency-tracking records a call
+}
+
+// commandStats maps lowercase command names to their *commandCounters
+var commandStats sync.Map
+
+// CommandLatency holds latency percentiles of a command
+type CommandLatency struct {
+	Name        string    // Lowercase command name
+	Calls       int64     // Calls recorded in the histogram
+	Percentiles []float64 // Percentiles, like 99.9
+	Usec        []float64 // Microseconds the calls took at each percentile
+}
+
+// ErrorStat counts the error replies starting with a prefix, like ERR or WRONGTYPE
+type ErrorStat struct {
+	Prefix string
+	Count  int64
+}
+
+// maxErrorStats caps the number of distinct error prefixes tracked, later ones are only
+// counted in the total
+const maxErrorStats = 128
+
+// errorStatsMu protects errorStats and totalErrorReplies
+var errorStatsMu sync.Mutex
+var errorStats = make(map[string]int64)
+var totalErrorReplies int64
+
+// Uptime returns how long the server has been running
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
+
+// ConnectionReceived records an accepted client connection
+func ConnectionReceived() {
+	totalConnections.Add(1)
+}
+
+// TotalConnectionsReceived returns the number of client connections accepted since startup
+func TotalConnectionsReceived() int64 {
+	return totalConnections.Load()
+}
+
+// RecordCommand records an execution of command that took d
+func RecordCommand(command string, d time.Duration) {
+	totalCommands.Add(1)
+
+	counters := commandCountersFor(command)
+	counters.calls.Add(1)
+	counters.usec.Add(d.Microseconds())
+
+	if StoreState.LatencyTracking {
+		histogram := counters.latency.Load()
+		if histogram == nil {
+			counters.latency.CompareAndSwap(nil, &LatencyHistogram{})
+			histogram = counters.latency.Load()
+		}
+		histogram.Record(d.Nanoseconds())
+	}
+}
+
+// RecordRejectedCall records a call of command refused before it ran
+func RecordRejectedCall(command string) {
+	commandCountersFor(command).rejectedCalls.Add(1)
+}
+
+// RecordFailedCall records a call of command that replied with an error
+func RecordFailedCall(command string) {
+	commandCountersFor(command).failedCalls.Add(1)
+}
+
+// commandCountersFor returns the statistics of command, creating them on first use
+func commandCountersFor(command string) *commandCounters {
+	if counters, ok := commandStats.Load(command); ok {
+		return counters.(*commandCounters)
+	}
+	counters, _ := commandStats.LoadOrStore(command, &commandCounters{})
+	return counters.(*commandCounters)
+}
+
+// RecordErrorReply counts an error reply under its prefix, the first word of the message
+func RecordErrorReply(message string) {
+	prefix, _, _ := strings.Cut(message, " ")
+
+	errorStatsMu.Lock()
+	defer errorStatsMu.Unlock()
+	totalErrorReplies++
+	if _, exists := errorStats[prefix]; exists || len(errorStats) < maxErrorStats {
+		errorStats[prefix]++
+	}
+}
+
+// ErrorStats returns the number of error replies of every prefix seen, sorted by prefix
+func ErrorStats() []ErrorStat {
+	errorStatsMu.Lock()
+	stats := make([]ErrorStat, 0, len(errorStats))
+	for prefix, count := range errorStats {
+		stats = append(stats, ErrorStat{Prefix: prefix, Count: count})
+	}
+	errorStatsMu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Prefix < stats[j].Prefix })
+	return stats
+}
+
+// TotalErrorReplies returns the number of error replies sent since startup
+func TotalErrorReplies() int64 {
+	errorStatsMu.Lock()
+	defer errorStatsMu.Unlock()
+	return totalErrorReplies
+}
+
+// ResetStats zeroes the statistics reported by INFO, as done by CONFIG RESETSTAT
+func ResetStats() {
+	totalConnections.Store(0)
+	totalCommands.Store(0)
+	expiredKeys.Store(0)
+	keyspaceHits.Store(0)
+	keyspaceMisses.Store(0)
+	bigRangeReplies.Store(0)
+	rejectedRangeReplies.Store(0)
+	outputBufferLimitDisconnections.Store(0)
+	rateLimitedCommands.Store(0)
+
+	commandStats.Clear()
+
+	errorStatsMu.Lock()
+	errorStats = make(map[string]int64)
+	totalErrorReplies = 0
+	errorStatsMu.Unlock()
+}
+
+// TotalCommandsProcessed returns the number of commands executed since startup
+func TotalCommandsProcessed() int64 {
+	return totalCommands.Load()
+}
+
+// CommandStats returns the statistics of every command executed at least once, sorted by name
+func CommandStats() []CommandStat {
+	var stats []CommandStat
+	commandStats.Range(func(name, value any) bool {
+		counters := value.(*commandCounters)
+		stats = append(stats, CommandStat{
+			Name:          name.(string),
+			Calls:         counters.calls.Load(),
+			Usec:          counters.usec.Load(),
+			RejectedCalls: counters.rejectedCalls.Load(),
+			FailedCalls:   counters.failedCalls.Load(),
+		})
+		return true
+	})
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// CommandLatencies returns the given percentiles of the latency of every command tracked,
+// sorted by name
+func CommandLatencies(percentiles []float64) []CommandLatency {
+	var latencies []CommandLatency
+	commandStats.Range(func(name, value any) bool {
+		histogram := value.(*commandCounters).latency.Load()
+		if histogram == nil {
+			return true
+		}
+		latency := CommandLatency{Name: name.(string), Calls: histogram.Count(), Percentiles: percentiles, Usec: make([]float64, len(percentiles))}
+		for i, percentile := range percentiles {
+			latency.Usec[i] = float64(histogram.Percentile(percentile)) / 1000
+		}
+		latencies = append(latencies, latency)
+		return true
+	})
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i].Name < latencies[j].Name })
+	return latencies
+}
+
+// CommandHistogram returns the latency histogram of a command, which keeps recording its calls
+func CommandHistogram(command string) (*LatencyHistogram, bool) {
+	counters, ok := commandStats.Load(command)
+	if !ok {
+		return nil, false
+	}
+	histogram := counters.(*commandCounters).latency.Load()
+	return histogram, histogram != nil
+}
+
+// TrackedCommands returns the names of the commands with a latency histogram, sorted
+func TrackedCommands() []string {
+	var names []string
+	commandStats.Range(func(name, value any) bool {
+		if value.(*commandCounters).latency.Load() != nil {
+			names = append(names, name.(string))
+		}
+		return true
+	})
+
+	sort.Strings(names)
+	return names
+}
+
+// KeyExpired records a key removed because its expiration passed
+func KeyExpired(key string) {
+	expiredKeys.Add(1)
+	NotifyKeyModified(0, key, "expired")
+}
+
+// ExpiredKeys returns the number of keys removed because their expiration passed
+func ExpiredKeys() int64 {
+	return expiredKeys.Load()
+}
+
+// RateLimitedCommand records a command refused for going over a rate limit
+func RateLimitedCommand() {
+	rateLimitedCommands.Add(1)
+}
+
+// RateLimitedCommands returns the number of commands refused for going over a rate limit
+func RateLimitedCommands() int64 {
+	return rateLimitedCommands.Load()
+}
+
+// ClientBlocked records a client starting (delta 1) or stopping (delta -1) to wait in a blocking command
+func ClientBlocked(delta int64) {
+	blockedClients.Add(delta)
+}
+
+// BlockedClients returns the number of clients waiting in a blocking command
+func BlockedClients() int64 {
+	return blockedClients.Load()
+}
+
+// BigRangeReply records a range reply large enough to be streamed to the client
+func BigRangeReply() {
+	bigRangeReplies.Add(1)
+}
+
+// BigRangeReplies returns the number of range replies large enough to be streamed to the client
+func BigRangeReplies() int64 {
+	return bigRangeReplies.Load()
+}
+
+// RangeReplyRejected records a range command refused for replying more than range-reply-max-elements
+func RangeReplyRejected() {
+	rejectedRangeReplies.Add(1)
+}
+
+// RejectedRangeReplies returns the number of range commands refused for replying more than range-reply-max-elements
+func RejectedRangeReplies() int64 {
+	return rejectedRangeReplies.Load()
+}
+
+// OutputBufferLimitDisconnection records a client disconnected for going over its output buffer limit
+func OutputBufferLimitDisconnection() {
+	outputBufferLimitDisconnections.Add(1)
+}
+
+// OutputBufferLimitDisconnections returns the number of clients disconnected for going over their output buffer limit
+func OutputBufferLimitDisconnections() int64 {
+	return outputBufferLimitDisconnections.Load()
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Store holds the keyspace. Commands, persistence and the expire cycle all access keys
+// through it, so no two goroutines ever touch the same map at once. Commands that change
+// a key based on its current value, like INCR or LPUSH, do it within Update, so changes
+// made by two connections at once are both kept.
+//
+// Expirations are kept apart from the entries, by key name, so the expire cycle and
+// persistence visit only the keys with an expiration. Entries passed to and returned by the
+// store still carry their expiration in Expires, 0 for none.
+type Store interface {
+	// Get returns the entry of key, expired or not
+	Get(key string) (shared.MemoryEntry, bool)
+	// Set stores entry under key, replacing any previous one
+	Set(key string, entry shared.MemoryEntry)
+	// Delete removes key and reports whether it existed
+	Delete(key string) bool
+	// Update calls f with the entry of key, while no other goroutine can access the key, and
+	// stores the entry f returns when f reports it changed it. f must not access the store.
+	Update(key string, f func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool))
+	// DeleteIfExpired removes key when its expiration is before now, in milliseconds, and
+	// reports whether it did
+	DeleteIfExpired(key string, now int64) bool
+	// Len returns the number of keys, expired or not
+	Len() int
+	// Usage returns the approximate number of bytes key and its entry use
+	Usage(key string) (int64, bool)
+	// UsedMemory returns the approximate number of bytes every key uses, kept up to date as
+	// keys are written
+	UsedMemory() int64
+	// Range calls f for every key in a random order until f returns false. f must not
+	// write to the store.
+	Range(f func(key string, entry shared.MemoryEntry) bool)
+	// ExpiresLen returns the number of keys with an expiration, expired or not
+	ExpiresLen() int
+	// RangeExpires calls f for every key with an expiration, in a random order, until f
+	// returns false. f must not write to the store.
+	RangeExpires(f func(key string, expires int64) bool)
+	// KeyspaceStats returns the counters INFO keyspace reports, kept up to date as keys are
+	// written, with the time to live of the keys measured from now, in milliseconds
+	KeyspaceStats(now int64) KeyspaceStats
+	// Clone returns a copy of every key taken at a single point in time
+	Clone() map[string]shared.MemoryEntry
+	// CloneLocked calls f with a copy of every key taken at a single point in time, before
+	// any write can follow the copy, so the writes made after it see what f did. f must not
+	// access the store. Every shard stays locked while the top-level map is copied, so writes
+	// pause for a time proportional to the number of keys, once per BGSAVE, rewrite or sync.
+	CloneLocked(f func(clone map[string]shared.MemoryEntry))
+	// Clear removes every key
+	Clear()
+}
+
+// KeyspaceStats are the counters of a database. Keys whose expiration passed are counted
+// until they are removed, by a lookup or by the expire cycle, like Redis does.
+type KeyspaceStats struct {
+	Keys    int   // Number of keys
+	Expires int   // Number of keys with an expiration
+	AvgTTL  int64 // Average time to live of the keys with an expiration, in milliseconds
+}
+
+// expiresEpoch is the time the expirations summed by the shards are counted from
+var expiresEpoch = time.Now().UnixMilli()
+
+// storeShards is the number of shards of a ShardedStore, a power of two
+const storeShards = 64
+
+// storeShard is a part of the keyspace with its own lock. Entries are stored without their
+// expiration, which is in expires for the keys that have one. The size of every key, found
+// with EntrySize when it is written, is in sizes and summed in used. The expirations are
+// summed in expiresSum, as milliseconds from expiresEpoch so the sum of millions of them
+// doesn't overflow, and their average is found without visiting them.
+type storeShard struct {
+	mu         sync.RWMutex
+	entries    map[string]shared.MemoryEntry
+	expires    map[string]int64
+	sizes      map[string]int64
+	used       int64
+	expiresSum int64
+}
+
+// get returns the entry of key with its expiration. The shard must be locked.
+func (shard *storeShard) get(key string) (shared.MemoryEntry, bool) {
+	entry, exists := shard.entries[key]
+	if exists {
+		entry.Expires = shard.expires[key]
+	}
+	return entry, exists
+}
+
+// set stores entry under key, its expiration apart. The shard must be locked for writing.
+func (shard *storeShard) set(key string, entry shared.MemoryEntry) {
+	if expires, ok := shard.expires[key]; ok {
+		shard.expiresSum -= expires - expiresEpoch
+	}
+	if entry.Expires > 0 {
+		shard.expires[key] = entry.Expires
+		shard.expiresSum += entry.Expires - expiresEpoch
+		entry.Expires = 0
+	} else {
+		delete(shard.expires, key)
+	}
+	shard.entries[key] = entry
+
+	size := EntrySize(key, entry)
+	shard.used += size - shard.sizes[key]
+	shard.sizes[key] = size
+}
+
+// delete removes key and its expiration. The shard must be locked for writing.
+func (shard *storeShard) delete(key string) {
+	if expires, ok := shard.expires[key]; ok {
+		shard.expiresSum -= expires - expiresEpoch
+	}
+	delete(shard.entries, key)
+	delete(shard.expires, key)
+	shard.used -= shard.sizes[key]
+	delete(shard.sizes, key)
+}
+
+// ShardedStore splits the keyspace into shards picked by the hash of the key, each with its
+// own lock, so commands running on different connections rarely wait for each other.
+type ShardedStore struct {
+	shards [storeShards]storeShard
+}
+
+// NewShardedStore returns an empty store
+func NewShardedStore() *ShardedStore {
+	s := &ShardedStore{}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]shared.MemoryEntry)
+		s.shards[i].expires = make(map[string]int64)
+		s.shards[i].sizes = make(map[string]int64)
+	}
+	return s
+}
+
+// shard returns the shard of key, picked with the FNV-1a hash of the key
+func (s *ShardedStore) shard(key string) *storeShard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return &s.shards[hash&(storeShards-1)]
+}
+
+func (s *ShardedStore) Get(key string) (shared.MemoryEntry, bool) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	entry, exists := shard.get(key)
+	shard.mu.RUnlock()
+	return entry, exists
+}
+
+func (s *ShardedStore) Set(key string, entry shared.MemoryEntry) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	shard.set(key, entry)
+	shard.mu.Unlock()
+}
+
+func (s *ShardedStore) Delete(key string) bool {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	_, exists := shard.entries[key]
+	shard.delete(key)
+	shard.mu.Unlock()
+	return exists
+}
+
+func (s *ShardedStore) Update(key string, f func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool)) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, exists := shard.get(key)
+	if entry, changed := f(entry, exists); changed {
+		shard.set(key, entry)
+	}
+}
+
+func (s *ShardedStore) DeleteIfExpired(key string, now int64) bool {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	expires, ok := shard.expires[key]
+	if !ok || expires > now {
+		return false
+	}
+	shard.delete(key)
+	return true
+}
+
+func (s *ShardedStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+		n += len(s.shards[i].entries)
+		s.shards[i].mu.RUnlock()
+	}
+	return n
+}
+
+func (s *ShardedStore) Usage(key string) (int64, bool) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	size, exists := shard.sizes[key]
+	shard.mu.RUnlock()
+	return size, exists
+}
+
+func (s *ShardedStore) UsedMemory() int64 {
+	used := int64(0)
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+		used += s.shards[i].used
+		s.shards[i].mu.RUnlock()
+	}
+	return used
+}
+
+func (s *ShardedStore) Range(f func(key string, entry shared.MemoryEntry) bool) {
+	// Starting from a random shard, so callers stopping early, like the expire cycle, don't
+	// always visit the same shards
+	start := rand.IntN(storeShards)
+	for i := range storeShards {
+		shard := &s.shards[(start+i)&(storeShards-1)]
+		shard.mu.RLock()
+		for key, entry := range shard.entries {
+			entry.Expires = shard.expires[key]
+			if !f(key, entry) {
+				shard.mu.RUnlock()
+				return
+			}
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+func (s *ShardedStore) ExpiresLen() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+		n += len(s.shards[i].expires)
+		s.shards[i].mu.RUnlock()
+	}
+	return n
+}
+
+func (s *ShardedStore) RangeExpires(f func(key string, expires int64) bool) {
+	start := rand.IntN(storeShards)
+	for i := range storeShards {
+		shard := &s.shards[(start+i)&(storeShards-1)]
+		shard.mu.RLock()
+		for key, expires := range shard.expires {
+			if !f(key, expires) {
+				shard.mu.RUnlock()
+				return
+			}
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+func (s *ShardedStore) KeyspaceStats(now int64) KeyspaceStats {
+	var stats KeyspaceStats
+	expiresSum := int64(0)
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+		stats.Keys += len(s.shards[i].entries)
+		stats.Expires += len(s.shards[i].expires)
+		expiresSum += s.shards[i].expiresSum
+		s.shards[i].mu.RUnlock()
+	}
+	if stats.Expires > 0 {
+		stats.AvgTTL = max(expiresSum/int64(stats.Expires)+expiresEpoch-now, 0)
+	}
+	return stats
+}
+
+func (s *ShardedStore) Clone() map[string]shared.MemoryEntry {
+	var clone map[string]shared.MemoryEntry
+	s.CloneLocked(func(c map[string]shared.MemoryEntry) { clone = c })
+	return clone
+}
+
+func (s *ShardedStore) CloneLocked(f func(clone map[string]shared.MemoryEntry)) {
+	// Every shard stays locked until f returns, so the copy holds no half-applied write
+	for i := range s.shards {
+		s.shards[i].mu.RLock()
+	}
+	defer func() {
+		for i := range s.shards {
+			s.shards[i].mu.RUnlock()
+		}
+	}()
+	n := 0
+	for i := range s.shards {
+		n += len(s.shards[i].entries)
+	}
+	clone := make(map[string]shared.MemoryEntry, n)
+	for i := range s.shards {
+		for key, entry := range s.shards[i].entries {
+			entry.Expires = s.shards[i].expires[key]
+			clone[key] = entry
+		}
+	}
+	f(clone)
+}
+
+func (s *ShardedStore) Clear() {
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		clear(s.shards[i].entries)
+		clear(s.shards[i].expires)
+		clear(s.shards[i].sizes)
+		s.shards[i].used = 0
+		s.shards[i].expiresSum = 0
+		s.shards[i].mu.Unlock()
+	}
+}
@@ -0,0 +1,123 @@
+package shared
+
+import "sync/atomic"
+
+// EncodingLimits bounds the collections kept in a packed encoding: a contiguous slice scanned
+// linearly, which costs far less memory per element than a linked list or a map. A collection
+// growing past them is converted to its full structure.
+type EncodingLimits struct {
+	ListMaxListpackSize    int // list-max-listpack-size: most elements when positive, -1 to -5 for 4kb to 64kb
+	ZsetMaxListpackEntries int // zset-max-listpack-entries: most members of a packed sorted set
+	ZsetMaxListpackValue   int // zset-max-listpack-value: longest member of a packed sorted set
+}
+
+// DefaultEncodingLimits are the Redis defaults
+var DefaultEncodingLimits = EncodingLimits{ListMaxListpackSize: -2, ZsetMaxListpackEntries: 128, ZsetMaxListpackValue: 64}
+
+// encodingLimits holds the limits currently applied to collections
+var encodingLimits atomic.Pointer[EncodingLimits]
+
+func init() {
+	SetEncodingLimits(DefaultEncodingLimits)
+}
+
+// SetEncodingLimits changes the limits applied to collections from now on. Collections
+// already converted to their full structure stay converted.
+func SetEncodingLimits(l EncodingLimits) {
+	encodingLimits.Store(&l)
+}
+
+// GetEncodingLimits returns the limits applied to collections
+func GetEncodingLimits() EncodingLimits {
+	return *encodingLimits.Load()
+}
+
+const (
+	listpackHeaderSize    = 7  // Bytes of the listpack header and end marker
+	listpackEntryOverhead = 11 // Upper bound of the bytes a listpack adds to each element
+)
+
+// ListpackFits reports whether a list of values fits in a single listpack, following
+// list-max-listpack-size: a number of elements when positive, a size in bytes otherwise
+func ListpackFits(values []string) bool {
+	limit := GetEncodingLimits().ListMaxListpackSize
+	if limit > 0 {
+		return len(values) <= limit
+	}
+	return !ListpackFull(len(values), listpackSize(values), limit)
+}
+
+// ListpackFull reports whether a listpack of count elements taking size bytes is over limit,
+// a list-max-listpack-size value
+func ListpackFull(count, size, limit int) bool {
+	if limit > 0 {
+		return count > limit
+	}
+	return size > listpackMaxBytes(limit)
+}
+
+// listpackMaxBytes returns the bytes a negative list-max-listpack-size allows, 4kb for -1
+// doubling up to 64kb for -5
+func listpackMaxBytes(limit int) int {
+	limit = max(min(-limit, 5), 1)
+	return 4096 << (limit - 1)
+}
+
+// listpackSize returns the approximate number of bytes of a listpack holding values
+func listpackSize(values []string) int {
+	size := listpackHeaderSize
+	for _, value := range values {
+		size += len(value) + listpackEntryOverhead
+	}
+	return size
+}
+
+// SetList stores values as the list of the entry, packed in Array when they fit in a
+// listpack and in a linked list otherwise
+func (e *MemoryEntry) SetList(values []string) {
+	if ListpackFits(values) {
+		e.Array, e.List = values, nil
+	} else {
+		e.Array, e.List = nil, FromArray(values)
+	}
+}
+
+// PushList adds values to the head of the list of the entry, one at a time like LPUSH, or to
+// its tail, and returns the length of the list. A packed list growing past the listpack
+// limits is converted to a linked list.
+func (e *MemoryEntry) PushList(values []string, head bool) int {
+	if e.List == nil {
+		var packed []string
+		if head {
+			packed = make([]string, 0, len(values)+len(e.Array))
+			for i := len(values) - 1; i >= 0; i-- {
+				packed = append(packed, values[i])
+			}
+			packed = append(packed, e.Array...)
+		} else {
+			packed = append(e.Array, values...)
+		}
+		e.SetList(packed)
+		if e.List == nil {
+			return len(e.Array)
+		}
+		return e.List.Size
+	}
+
+	for _, value := range values {
+		if head {
+			e.List.AddToHead(value)
+		} else {
+			e.List.AddToTail(value)
+		}
+	}
+	return e.List.Size
+}
+
+// ListValues returns the elements of the list of the entry, packed or not
+func (e *MemoryEntry) ListValues() []string {
+	if e.List != nil {
+		return e.List.ToArray()
+	}
+	return e.Array
+}
@@ -0,0 +1,60 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error messages replied by many commands, kept here so their text stays the same everywhere
+const (
+	WrongTypeMessage  = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	NotIntegerMessage = "ERR value is not an integer or out of range"
+	NotFloatMessage   = "ERR value is not a valid float"
+	SyntaxMessage     = "ERR syntax error"
+	NoSuchKeyMessage  = "ERR no such key"
+	NoAuthMessage     = "NOAUTH Authentication required."
+	ReadOnlyMessage   = "READONLY You can't write against a read only replica."
+)
+
+// ErrorValue returns an error reply with the given message, which starts with its error code
+func ErrorValue(message string) Value {
+	return Value{Typ: "error", Str: message}
+}
+
+// ErrWrongType returns the error replied to a command run against a key of another type
+func ErrWrongType() Value {
+	return ErrorValue(WrongTypeMessage)
+}
+
+// ErrWrongArity returns the error replied to a command called with the wrong number of
+// arguments. Subcommands are named after their command, like "client|kill" or "config set".
+func ErrWrongArity(command string) Value {
+	name := strings.ToLower(strings.ReplaceAll(command, " ", "|"))
+	return ErrorValue(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+}
+
+// ErrNotInteger returns the error replied to an argument that should be an integer
+func ErrNotInteger() Value {
+	return ErrorValue(NotIntegerMessage)
+}
+
+// ErrNotFloat returns the error replied to an argument that should be a number
+func ErrNotFloat() Value {
+	return ErrorValue(NotFloatMessage)
+}
+
+// ErrSyntax returns the error replied to unknown options or options missing their value
+func ErrSyntax() Value {
+	return ErrorValue(SyntaxMessage)
+}
+
+// ErrNoSuchKey returns the error replied to a command that needs an existing key
+func ErrNoSuchKey() Value {
+	return ErrorValue(NoSuchKeyMessage)
+}
+
+// Moved returns the error redirecting a cluster client to the node at addr, like
+// 127.0.0.1:7001, which serves the hash slot of the key
+func Moved(slot int, addr string) Value {
+	return ErrorValue(fmt.Sprintf("MOVED %d %s", slot, addr))
+}
@@ -1,6 +1,8 @@
 package shared
 
 import (
+	"maps"
+	"slices"
 	"sort"
 	"sync"
 )
@@ -30,25 +32,79 @@ type SortedSetMember struct {
 	Member string
 }
 
-// SortedSet represents a Redis sorted set (ZSET)
+// less reports whether a sorts before b: by score, then by member name
+func (a SortedSetMember) less(b SortedSetMember) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Member < b.Member
+}
+
+// SortedSet represents a Redis sorted set (ZSET).
+//
+// Small sets are packed, like a listpack: their members are kept in a slice ordered by score
+// then name, and found with a linear scan. A set growing past zset-max-listpack-entries
+// members, or given a member longer than zset-max-listpack-value, is converted to a map of
+// member to score, and stays one.
 type SortedSet struct {
-	Members map[string]float64 // Map of member -> score for O(1) lookups
+	members map[string]float64 // Map of member -> score for O(1) lookups, nil while packed
+	packed  []SortedSetMember  // Members in order, while packed
 	Size    int                // Number of members
 }
 
 // NewSortedSet creates a new empty sorted set
 func NewSortedSet() *SortedSet {
-	return &SortedSet{
-		Members: make(map[string]float64),
-		Size:    0,
+	return &SortedSet{}
+}
+
+// Packed reports whether the set is still packed in a slice
+func (ss *SortedSet) Packed() bool {
+	return ss.members == nil
+}
+
+// packedIndex returns the position of member in a packed set, -1 when it isn't there
+func (ss *SortedSet) packedIndex(member string) int {
+	for i := range ss.packed {
+		if ss.packed[i].Member == member {
+			return i
+		}
+	}
+	return -1
+}
+
+// unpack converts a packed set to a map
+func (ss *SortedSet) unpack() {
+	ss.members = make(map[string]float64, len(ss.packed)+1)
+	for _, m := range ss.packed {
+		ss.members[m.Member] = m.Score
 	}
+	ss.packed = nil
 }
 
 // Add adds a member with a score to the sorted set
 // Returns true if the member was added (new), false if it was updated (existing)
 func (ss *SortedSet) Add(member string, score float64) bool {
-	_, exists := ss.Members[member]
-	ss.Members[member] = score
+	if ss.Packed() {
+		limits := GetEncodingLimits()
+		i := ss.packedIndex(member)
+		if i < 0 && (ss.Size >= limits.ZsetMaxListpackEntries || len(member) > limits.ZsetMaxListpackValue) {
+			ss.unpack()
+		} else {
+			if i >= 0 {
+				ss.packed = slices.Delete(ss.packed, i, i+1)
+			}
+			m := SortedSetMember{Score: score, Member: member}
+			at := sort.Search(len(ss.packed), func(j int) bool { return m.less(ss.packed[j]) })
+			ss.packed = slices.Insert(ss.packed, at, m)
+			if i < 0 {
+				ss.Size++
+			}
+			return i < 0
+		}
+	}
+
+	_, exists := ss.members[member]
+	ss.members[member] = score
 	if !exists {
 		ss.Size++
 		return true
@@ -58,7 +114,13 @@ func (ss *SortedSet) Add(member string, score float64) bool {
 
 // GetScore returns the score of a member, or 0 and false if not found
 func (ss *SortedSet) GetScore(member string) (float64, bool) {
-	score, exists := ss.Members[member]
+	if ss.Packed() {
+		if i := ss.packedIndex(member); i >= 0 {
+			return ss.packed[i].Score, true
+		}
+		return 0, false
+	}
+	score, exists := ss.members[member]
 	return score, exists
 }
 
@@ -71,7 +133,14 @@ type memberScore struct {
 // GetRank returns the rank (0-based index) of a member in the sorted set
 // Members are sorted by score in ascending order, then by member name alphabetically
 func (ss *SortedSet) GetRank(member string) (int, bool) {
-	_, exists := ss.Members[member]
+	if ss.Packed() {
+		if i := ss.packedIndex(member); i >= 0 {
+			return i, true
+		}
+		return 0, false
+	}
+
+	_, exists := ss.members[member]
 	if !exists {
 		return 0, false
 	}
@@ -82,12 +151,12 @@ func (ss *SortedSet) GetRank(member string) (int, bool) {
 
 	// Pre-allocate with exact capacity
 	members = members[:0]
-	if cap(members) < len(ss.Members) {
-		members = make([]memberScore, 0, len(ss.Members))
+	if cap(members) < len(ss.members) {
+		members = make([]memberScore, 0, len(ss.members))
 	}
 
 	// Create a slice of members with their scores for sorting
-	for m, s := range ss.Members {
+	for m, s := range ss.members {
 		members = append(members, memberScore{m, s})
 	}
 
@@ -109,46 +178,80 @@ func (ss *SortedSet) GetRank(member string) (int, bool) {
 	return 0, false
 }
 
+// Sorted returns the members with their scores, sorted by score then by member name.
+// The slice of a packed set is returned as is and must not be modified.
+func (ss *SortedSet) Sorted() []SortedSetMember {
+	if ss.Packed() {
+		return ss.packed
+	}
+	members := make([]SortedSetMember, 0, len(ss.members))
+	for m, s := range ss.members {
+		members = append(members, SortedSetMember{Score: s, Member: m})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].less(members[j]) })
+	return members
+}
+
 // GetSortedMembers returns all members of the sorted set in sorted order
 // Members are sorted by score (ascending), then by member name (alphabetically)
 func (ss *SortedSet) GetSortedMembers() []string {
-	// Create a slice of members with their scores for sorting
-	type memberScore struct {
-		member string
-		score  float64
-	}
-
-	members := make([]memberScore, 0, len(ss.Members))
-	for m, s := range ss.Members {
-		members = append(members, memberScore{m, s})
+	sorted := ss.Sorted()
+	result := make([]string, len(sorted))
+	for i, m := range sorted {
+		result[i] = m.Member
 	}
+	return result
+}
 
-	// Sort by score (ascending), then by member name (alphabetically)
-	for i := 0; i < len(members)-1; i++ {
-		for j := i + 1; j < len(members); j++ {
-			if members[i].score > members[j].score ||
-				(members[i].score == members[j].score && members[i].member > members[j].member) {
-				members[i], members[j] = members[j], members[i]
+// Range calls f for every member and its score, in no particular order, until f returns false
+func (ss *SortedSet) Range(f func(member string, score float64) bool) {
+	if ss.Packed() {
+		for _, m := range ss.packed {
+			if !f(m.Member, m.Score) {
+				return
 			}
 		}
+		return
 	}
-
-	// Extract just the member names
-	result := make([]string, len(members))
-	for i, ms := range members {
-		result[i] = ms.member
+	for member, score := range ss.members {
+		if !f(member, score) {
+			return
+		}
 	}
+}
 
-	return result
+// Scores returns a map of every member to its score
+func (ss *SortedSet) Scores() map[string]float64 {
+	scores := make(map[string]float64, ss.Size)
+	ss.Range(func(member string, score float64) bool {
+		scores[member] = score
+		return true
+	})
+	return scores
+}
+
+// Clone returns a copy of the set in the same encoding
+func (ss *SortedSet) Clone() *SortedSet {
+	return &SortedSet{members: maps.Clone(ss.members), packed: slices.Clone(ss.packed), Size: ss.Size}
 }
 
 // Remove removes a member from the sorted set
 func (ss *SortedSet) Remove(member string) bool {
-	_, exists := ss.Members[member]
+	if ss.Packed() {
+		i := ss.packedIndex(member)
+		if i < 0 {
+			return false
+		}
+		ss.packed = slices.Delete(ss.packed, i, i+1)
+		ss.Size--
+		return true
+	}
+
+	_, exists := ss.members[member]
 	if !exists {
 		return false
 	}
-	delete(ss.Members, member)
+	delete(ss.members, member)
 	ss.Size--
 	return true
 }
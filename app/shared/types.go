@@ -11,6 +11,18 @@ type Value = protocol.Value
 type Resp = protocol.Resp
 type Writer = protocol.Writer
 
+// Null reply constructors
+var Null = protocol.Null
+var NullArray = protocol.NullArray
+
+// Replies of the RESP3 kinds, written as their RESP2 equivalent to RESP2 clients
+var Double = protocol.Double
+var Boolean = protocol.Boolean
+var BigNumber = protocol.BigNumber
+
+// StreamArray builds an array reply whose elements are produced while it is written
+var StreamArray = protocol.StreamArray
+
 // StreamEntry represents a single entry in a Redis stream
 type StreamEntry struct {
 	ID   string            // Stream ID (e.g., "1526985054069-0")
@@ -20,12 +32,15 @@ type StreamEntry struct {
 // MemoryEntry represents a value stored in the in-memory database.
 // It can hold either a string value, an array of strings, or a linked list, with optional expiration.
 type MemoryEntry struct {
-	Value     string        // String value (used when Array is empty)
-	Array     []string      // Array of strings (used for list operations - kept for compatibility)
-	List      *LinkedList   // Linked list (used for optimized list operations)
-	Stream    []StreamEntry // Stream entries (used for stream operations)
-	SortedSet *SortedSet    // Sorted set (used for sorted set operations)
-	Expires   int64         // Unix timestamp in milliseconds, 0 means no expiry
+	Value     string              // String value (used when Array is empty)
+	Array     []string            // Array of strings (used for list operations - kept for compatibility)
+	List      *LinkedList         // Linked list (used for optimized list operations)
+	Stream    []StreamEntry       // Stream entries (used for stream operations)
+	SortedSet *SortedSet          // Sorted set (used for sorted set operations)
+	Set       map[string]struct{} // Set members (loaded from RDB files)
+	Hash      map[string]string   // Hash fields (loaded from RDB files)
+	Expires   int64               // Unix timestamp in milliseconds, 0 means no expiry
+	Raw       bool                // String value changed in place, which Redis keeps in the raw encoding
 }
 
 // QueuedCommand represents a command that is queued in a transaction.
@@ -37,18 +52,140 @@ type QueuedCommand struct {
 // Transaction represents a transaction that is being executed.
 type Transaction struct {
 	Commands []QueuedCommand
+	Aborted  bool // A command was refused while queued, so EXEC discards the transaction
+}
+
+// ReplicaInfo holds what a replica advertised about itself during the replication handshake.
+type ReplicaInfo struct {
+	IP            string   // Address the replica connected from
+	ListeningPort string   // Port sent with REPLCONF listening-port
+	Capabilities  []string // Flags sent with REPLCONF capa (e.g. "eof", "psync2")
+	AckOffset     int64    // Last offset reported with REPLCONF ACK
+	LastAck       int64    // Unix timestamp in milliseconds of the last ACK (or of the sync)
+}
+
+// ClientInfo holds the metadata of a client connection, as reported by CLIENT LIST
+type ClientInfo struct {
+	ID              int64  // Unique incremental ID, starting at 1
+	Addr            string // Address the client connected from
+	LocalAddr       string // Address of the server the client connected to
+	Name            string // Name set with CLIENT SETNAME, empty when unset
+	CreatedAt       int64  // Unix timestamp in milliseconds the connection was accepted
+	LastInteraction int64  // Unix timestamp in milliseconds of the last command
+	LastCommand     string // Lowercase name of the last command
+	Protocol        int    // RESP version negotiated with HELLO, 2 until the client switches
+	User            string // ACL user the client is authenticated as
+	Authenticated   bool   // Whether the client may run commands other than AUTH and HELLO
+	Blocked         bool   // Whether the client is waiting in a blocking command
+	Killed          bool   // Set by CLIENT KILL, blocking commands stop waiting and the connection is closed
+	ReadOnly        bool   // Set by READONLY in cluster mode, a replica serves the reads of the client for its master's slots
+}
+
+// OutputBufferLimit is the client-output-buffer-limit of a class of clients. A client is
+// disconnected once the replies written to it and not yet sent reach Hard bytes, or stay over
+// Soft bytes for SoftSeconds. 0 disables a limit.
+type OutputBufferLimit struct {
+	Hard        int64
+	Soft        int64
+	SoftSeconds int
 }
 
-// CommandHandler represents a function that handles a Redis command
-type CommandHandler func(string, []protocol.Value) protocol.Value
+// SavePoint is a "save <seconds> <changes>" rule: a background save starts once at least
+// Changes writes happened and Seconds have passed since the last successful save.
+type SavePoint struct {
+	Seconds int
+	Changes int
+}
 
 // State represents the server state including replication information
 type State struct {
 	Role             string
+	Port             string // Port the server listens on for clients
 	ReplicaOf        string
 	MasterReplID     string
 	MasterReplOffset int64
 	Replicas         map[string]net.Conn // Map of replica connection IDs to their connections
 	ConfigDir        string              // Directory where Redis stores its data
 	ConfigDbfilename string              // Database filename
+	ConfigFile       string              // Absolute path of the config file the server started with, if any
+
+	MinReplicasToWrite int // Minimum number of good replicas required to accept writes, 0 disables the check
+	MinReplicasMaxLag  int // Maximum seconds since a replica's last ACK for it to count as good
+
+	ReplicaServeStaleData bool // Whether a replica serves data commands while its link to the master is down
+	ReplDisklessSync      bool // Whether full resyncs stream a snapshot from memory instead of sending the RDB file
+	ReplDisklessSyncDelay int  // Seconds to wait for more replicas to share a diskless transfer
+
+	RDBCompression bool // Whether RDB files compress long strings with LZF
+	RDBChecksum    bool // Whether RDB files are written with a CRC64 checksum that is verified on load
+
+	SavePoints []SavePoint // Rules triggering automatic background saves, none disables them
+
+	AppendOnly               bool   // Whether writes are logged to the append only file
+	AppendFilename           string // Name of the append only file, its parts are named after it
+	AppendDirname            string // Directory in ConfigDir holding the parts of the append only file
+	AppendFsync              string // When the append only file is fsynced: always, everysec or no
+	AutoAOFRewritePercentage int    // Growth since the last rewrite triggering a new one, 0 disables it
+	AutoAOFRewriteMinSize    int64  // Minimum append only file size for an automatic rewrite
+	AOFUseRDBPreamble        bool   // Whether rewrites write the base file as an RDB snapshot
+	AOFTimestampEnabled      bool   // Whether "#TS:" annotations record when writes were appended
+
+	ShutdownTimeout int // Seconds SHUTDOWN waits for replicas to catch up before exiting
+
+	BusyReplyThreshold int // Milliseconds a script runs before other clients get BUSY and it can be killed
+
+	MaxMemory int64 // Memory limit in bytes, 0 means no limit
+
+	SlowlogLogSlowerThan int // Microseconds a command must run to be logged in the slow log, negative disables it
+	SlowlogMaxLen        int // Maximum number of entries kept in the slow log
+
+	LatencyMonitorThreshold int // Milliseconds an event must take to be recorded as a latency spike, 0 disables it
+
+	LatencyTracking                bool      // Whether the latency of every command is recorded in a histogram
+	LatencyTrackingInfoPercentiles []float64 // Percentiles reported by INFO latencystats and the metrics endpoint
+
+	RangeReplyMaxElements int // Most elements LRANGE and XRANGE may reply, 0 for no limit
+
+	Bind          string // Space separated addresses to listen on, like "127.0.0.1 -::1", empty listens on every address
+	ProtectedMode bool   // Whether only loopback clients are served while no bind address and no password are set
+
+	Timeout      int // Seconds a client may stay idle before it is disconnected, 0 disables it
+	TCPKeepalive int // Seconds of silence before TCP keepalive probes are sent to clients, 0 disables them
+
+	MetricsPort   int // Port of the HTTP listener serving Prometheus metrics at /metrics, 0 disables it
+	DebugPort     int // Port of the HTTP listener serving Go runtime profiles at /debug/pprof/, 0 disables it
+	HTTPPort      int // Port of the HTTP gateway serving keys, commands and subscriptions as JSON, 0 disables it
+	MemcachedPort int // Port serving the get, set, delete and incr commands of the memcached text protocol, 0 disables it
+
+	LogLevel string // Minimum level of the logged messages: debug, verbose, notice or warning
+	LogFile  string // File the log is appended to, empty logs to stdout
+
+	RequirePass string // Password of the default user, empty lets clients connect without AUTH
+	ACLFile     string // Path of the file defining the ACL users, empty keeps them in memory only
+
+	ProtoMaxBulkLen      int64 // Longest bulk string accepted from clients, in bytes
+	ProtoMaxMultibulkLen int   // Most elements in an array accepted from clients
+	ProtoMaxNestingDepth int   // Deepest nesting of arrays accepted from clients
+
+	ClientQueryBufferLimit int64 // Most bytes of bulk strings in a command accepted from clients
+
+	ClientOutputBufferLimits map[string]OutputBufferLimit // Output buffer limits of the normal, slave and pubsub client classes
+
+	ClientRateLimitCommands int   // Most commands a client may send per second, 0 for no limit
+	ClientRateLimitBytes    int64 // Most bytes of arguments a client may send per second, 0 for no limit
+	RateLimitCommands       int   // Most commands every client together may send per second, 0 for no limit
+	RateLimitBytes          int64 // Most bytes of arguments every client together may send per second, 0 for no limit
+
+	AuditLogFile     string // File the write and administrative commands of clients are appended to, empty disables it
+	AuditLogMaxSize  int64  // Size in bytes the audit log is rotated at, 0 never rotates it
+	AuditLogMaxFiles int    // Rotated audit logs kept besides the current one
+
+	ListMaxListpackSize    int // Most elements of a packed list when positive, -1 to -5 for 4kb to 64kb
+	ZsetMaxListpackEntries int // Most members of a packed sorted set
+	ZsetMaxListpackValue   int // Longest member of a packed sorted set, in bytes
+
+	SingleWriter bool // Whether every command runs on a single executor goroutine instead of its connection
+
+	ClusterEnabled    bool   // Whether the server runs in cluster mode, keys spread over hash slots
+	ClusterAnnounceIP string // Address announced for this node in cluster replies, empty for the one clients connected to
 }
@@ -0,0 +1,695 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/protocol"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// aofLog logs the messages of the aof subsystem
+var aofLog = logger.New("aof")
+
+// Fsync policies of the append only file (appendfsync)
+const (
+	AppendFsyncAlways   = "always"
+	AppendFsyncEverysec = "everysec"
+	AppendFsyncNo       = "no"
+)
+
+// aofRewriteItemsPerCmd is the number of elements written per command when a
+// rewrite regenerates a list or sorted set, so loading never builds huge commands
+const aofRewriteItemsPerCmd = 64
+
+// aofRewriteTempName is the file a rewrite writes the new base to before renaming it
+const aofRewriteTempName = "temp-rewriteaof.aof"
+
+// aofMu protects the append only file state below
+var aofMu sync.Mutex
+
+// aofFile is the open incremental file writes are appended to, nil while appendonly is off
+var aofFile *os.File
+
+// aofParts is the manifest of the open append only file
+var aofParts *aofManifest
+
+// aofCurrentSize is the size of the base and incremental files together
+var aofCurrentSize int64
+
+// aofBaseSize is the size of the append only file after the last rewrite (or at startup),
+// used to compute the growth that triggers an automatic rewrite
+var aofBaseSize int64
+
+// aofRewriting is set while a rewrite is generating a new base file
+var aofRewriting bool
+
+// aofRewriteIncrSeq is the first incremental file kept by the running rewrite.
+// Earlier files only hold writes the new base already contains.
+var aofRewriteIncrSeq int
+
+// aofNeedsSync is set when writes were made since the last fsync (everysec policy)
+var aofNeedsSync bool
+
+// aofLastTimestamp is the Unix time of the last timestamp annotation written to the incremental file
+var aofLastTimestamp int64
+
+// aofLastWriteFailed is set when the last write to the append only file failed
+var aofLastWriteFailed bool
+
+// aofLastRewriteFailed is set when the last rewrite failed
+var aofLastRewriteFailed bool
+
+// aofRewriteStartTime is the Unix time in seconds the running rewrite started at
+var aofRewriteStartTime int64
+
+// aofLastRewriteDuration is how many seconds the last rewrite took, -1 before the first one
+var aofLastRewriteDuration int64 = -1
+
+// OpenAppendOnlyFile opens the configured append only file so writes are appended to its
+// last incremental file. A single-file append only file from an older version is moved into
+// the append only directory first. If no file exists yet, a base is created from the dataset.
+func OpenAppendOnlyFile() error {
+	filename := server.StoreState.AppendFilename
+	manifest, err := loadAofManifest(aofDir(), filename)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		legacy := filepath.Join(server.StoreState.ConfigDir, filename)
+		if _, err := os.Stat(legacy); err == nil {
+			if manifest, err = upgradeAppendOnlyFile(server.StoreState.ConfigDir, filename); err != nil {
+				return err
+			}
+		} else if err := RewriteAppendOnlyFile(); err != nil {
+			return err
+		}
+	}
+
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	if manifest != nil {
+		aofParts = manifest
+	}
+	return openIncrFileLocked(true)
+}
+
+// openIncrFileLocked opens the incremental file writes are appended to, aofMu must be held.
+// The last incremental file of the manifest is reused when reuse is set, otherwise
+// a new one is created and added to the manifest.
+func openIncrFileLocked(reuse bool) error {
+	filename := server.StoreState.AppendFilename
+	if aofParts == nil {
+		aofParts = &aofManifest{}
+	}
+
+	manifest := *aofParts
+	if !reuse || len(manifest.incrs) == 0 {
+		manifest.incrSeq++
+		manifest.incrs = append(append([]aofManifestFile(nil), manifest.incrs...), aofManifestFile{
+			name: aofIncrName(filename, manifest.incrSeq),
+			seq:  manifest.incrSeq,
+			kind: aofIncrType,
+		})
+	}
+
+	incr := manifest.incrs[len(manifest.incrs)-1]
+	file, err := os.OpenFile(filepath.Join(aofDir(), incr.name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open append only file: %v", err)
+	}
+	if manifest.incrSeq != aofParts.incrSeq {
+		// The manifest lists the new file before anything is written to it
+		if err := writeAofManifest(aofDir(), filename, &manifest); err != nil {
+			file.Close()
+			os.Remove(filepath.Join(aofDir(), incr.name))
+			return err
+		}
+	}
+
+	if aofFile != nil {
+		aofFile.Sync()
+		aofFile.Close()
+	}
+	aofFile = file
+	aofParts = &manifest
+	aofLastTimestamp = 0
+	updateAppendOnlySizesLocked()
+	return nil
+}
+
+// updateAppendOnlySizesLocked recomputes the size from the files of the manifest and
+// makes it the base automatic rewrites measure growth from, aofMu must be held
+func updateAppendOnlySizesLocked() {
+	aofCurrentSize = 0
+	for _, file := range aofParts.files() {
+		if info, err := os.Stat(filepath.Join(aofDir(), file.name)); err == nil {
+			aofCurrentSize += info.Size()
+		}
+	}
+	aofBaseSize = aofCurrentSize
+}
+
+// StartAppendOnly turns the append only file on while the server runs. Writes are appended
+// to a new incremental file right away, and a background rewrite replaces the files the
+// directory held with a base written from the dataset.
+func StartAppendOnly() error {
+	manifest, err := loadAofManifest(aofDir(), server.StoreState.AppendFilename)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(aofDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create append only directory: %v", err)
+	}
+
+	aofMu.Lock()
+	aofParts = manifest
+	err = openIncrFileLocked(false)
+	aofMu.Unlock()
+	if err != nil {
+		return err
+	}
+	return BackgroundRewriteAppendOnlyFile()
+}
+
+// CloseAppendOnlyFile flushes and closes the append only file
+func CloseAppendOnlyFile() error {
+	aofMu.Lock()
+	defer aofMu.Unlock()
+
+	aofParts = nil
+	if aofFile == nil {
+		return nil
+	}
+	err := aofFile.Sync()
+	if closeErr := aofFile.Close(); err == nil {
+		err = closeErr
+	}
+	aofFile = nil
+	return err
+}
+
+// FeedAppendOnlyFile appends a command, in RESP form, to the append only file.
+// With aof-timestamp-enabled, a "#TS:<unix time>" annotation precedes the first command
+// of every second. It does nothing while appendonly is off.
+func FeedAppendOnlyFile(command []byte) {
+	aofMu.Lock()
+	defer aofMu.Unlock()
+
+	if aofFile == nil {
+		return
+	}
+	if server.StoreState.AOFTimestampEnabled {
+		if now := time.Now().Unix(); now > aofLastTimestamp {
+			command = append([]byte("#TS:"+strconv.FormatInt(now, 10)+"\r\n"), command...)
+			aofLastTimestamp = now
+		}
+	}
+
+	n, err := aofFile.Write(command)
+	aofCurrentSize += int64(n)
+	aofLastWriteFailed = err != nil
+	if err != nil {
+		aofLog.Warningf("Error writing to the append only file: %v", err)
+		return
+	}
+
+	switch server.StoreState.AppendFsync {
+	case AppendFsyncAlways:
+		if err := aofFile.Sync(); err != nil {
+			aofLog.Warningf("Error syncing the append only file: %v", err)
+		}
+	case AppendFsyncEverysec:
+		aofNeedsSync = true
+	}
+}
+
+// syncAppendOnlyFile fsyncs pending writes, called every second for the everysec policy
+func syncAppendOnlyFile() {
+	aofMu.Lock()
+	defer aofMu.Unlock()
+
+	if aofFile == nil || !aofNeedsSync {
+		return
+	}
+	aofNeedsSync = false
+	if err := aofFile.Sync(); err != nil {
+		aofLog.Warningf("Error syncing the append only file: %v", err)
+	}
+}
+
+// AppendOnlyRewriteInProgress reports whether an AOF rewrite is running
+func AppendOnlyRewriteInProgress() bool {
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	return aofRewriting
+}
+
+// BackgroundRewriteAppendOnlyFile writes a new base file from a snapshot of the dataset
+// in a goroutine. Writes made meanwhile go to a new incremental file, kept after the new base.
+// The caller holds server.CommandLock for writing, see startRewrite.
+func BackgroundRewriteAppendOnlyFile() error {
+	snapshot, err := startRewrite()
+	if err != nil {
+		return err
+	}
+
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		if err := finishRewrite(snapshot); err != nil {
+			aofLog.Warningf("Background AOF rewrite error: %v", err)
+			return
+		}
+		aofLog.Noticef("Background AOF rewrite terminated with success")
+	}()
+	return nil
+}
+
+// RewriteAppendOnlyFile writes a new base file from the dataset, synchronously
+func RewriteAppendOnlyFile() error {
+	snapshot, err := startRewrite()
+	if err != nil {
+		return err
+	}
+	return finishRewrite(snapshot)
+}
+
+// startRewrite snapshots the dataset and switches writes to a new incremental file. The caller
+// holds server.CommandLock for writing, so no command is between changing the dataset and
+// feeding the append only file: every write is either in the snapshot or in the new
+// incremental file, never in both or in neither.
+func startRewrite() (*Snapshot, error) {
+	aofMu.Lock()
+	defer aofMu.Unlock()
+
+	if aofRewriting {
+		return nil, fmt.Errorf("ERR Background append only file rewriting already in progress")
+	}
+
+	if aofFile != nil {
+		if err := openIncrFileLocked(false); err != nil {
+			return nil, err
+		}
+		aofRewriteIncrSeq = aofParts.incrSeq
+	} else {
+		// With appendonly off, the rewrite replaces whatever the directory holds
+		manifest, err := loadAofManifest(aofDir(), server.StoreState.AppendFilename)
+		if err != nil {
+			return nil, err
+		}
+		if manifest == nil {
+			manifest = &aofManifest{}
+		}
+		aofParts = manifest
+		aofRewriteIncrSeq = manifest.incrSeq + 1
+	}
+
+	aofRewriting = true
+	aofRewriteStartTime = time.Now().Unix()
+	return TakeSnapshot(), nil
+}
+
+// finishRewrite writes the snapshot as the new base file, as an RDB snapshot when
+// aof-use-rdb-preamble is set or as commands otherwise. The manifest then lists it with
+// the incremental files opened since the rewrite started, and the replaced files are deleted.
+func finishRewrite(snapshot *Snapshot) (err error) {
+	err = writeRewrittenFile(aofDir(), snapshot)
+	snapshot.Release()
+
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	defer func() {
+		aofRewriting = false
+		aofLastRewriteDuration = time.Now().Unix() - aofRewriteStartTime
+		aofLastRewriteFailed = err != nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	filename := server.StoreState.AppendFilename
+	manifest := &aofManifest{baseSeq: aofParts.baseSeq + 1, incrSeq: aofParts.incrSeq}
+	manifest.base = &aofManifestFile{
+		name: aofBaseName(filename, manifest.baseSeq, server.StoreState.AOFUseRDBPreamble),
+		seq:  manifest.baseSeq,
+		kind: aofBaseType,
+	}
+	var replaced []aofManifestFile
+	if aofParts.base != nil {
+		replaced = append(replaced, *aofParts.base)
+	}
+	for _, incr := range aofParts.incrs {
+		if incr.seq >= aofRewriteIncrSeq {
+			manifest.incrs = append(manifest.incrs, incr)
+		} else {
+			replaced = append(replaced, incr)
+		}
+	}
+	for _, file := range replaced {
+		file.kind = aofHistoryType
+		manifest.history = append(manifest.history, file)
+	}
+
+	tmp := filepath.Join(aofDir(), aofRewriteTempName)
+	if err := os.Rename(tmp, filepath.Join(aofDir(), manifest.base.name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rename rewritten AOF: %v", err)
+	}
+	if err := writeAofManifest(aofDir(), filename, manifest); err != nil {
+		os.Remove(filepath.Join(aofDir(), manifest.base.name))
+		return err
+	}
+
+	// The history files are only deleted once the new manifest no longer needs them
+	for _, file := range manifest.history {
+		if err := os.Remove(filepath.Join(aofDir(), file.name)); err != nil && !os.IsNotExist(err) {
+			aofLog.Warningf("Failed to remove replaced AOF file %s: %v", file.name, err)
+		}
+	}
+	manifest.history = nil
+	if err := writeAofManifest(aofDir(), filename, manifest); err != nil {
+		aofLog.Warningf("Failed to remove history from the AOF manifest: %v", err)
+	}
+
+	aofParts = manifest
+	updateAppendOnlySizesLocked()
+	return nil
+}
+
+// writeRewrittenFile writes the snapshot to the temporary rewrite file in dir
+func writeRewrittenFile(dir string, snapshot *Snapshot) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+
+	tmp := filepath.Join(dir, aofRewriteTempName)
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary AOF: %v", err)
+	}
+
+	w := bufio.NewWriter(file)
+	if server.StoreState.AOFUseRDBPreamble {
+		err = snapshot.WriteRDB(w)
+	} else {
+		if server.StoreState.AOFTimestampEnabled {
+			_, err = fmt.Fprintf(w, "#TS:%d\r\n", time.Now().Unix())
+		}
+		if err == nil {
+			err = writeAppendOnlyCommands(w, snapshot)
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write rewritten AOF: %v", err)
+	}
+	return nil
+}
+
+// WriteAppendOnlyCommands writes the shortest command stream rebuilding memory.
+// Expired keys are skipped, the others keep their expiration.
+func WriteAppendOnlyCommands(w io.Writer, memory map[string]shared.MemoryEntry) error {
+	return writeAppendOnlyCommands(w, snapshotOf(memory))
+}
+
+// writeAppendOnlyCommands writes the commands rebuilding the keys of a snapshot
+func writeAppendOnlyCommands(w io.Writer, s *Snapshot) error {
+	keys, _ := s.keys()
+	now := time.Now().UnixMilli()
+
+	for _, code := range s.functions {
+		if _, err := w.Write(commandValue("FUNCTION", "LOAD", "REPLACE", code).Marshal()); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for _, key := range keys {
+		s.visit(key, func(entry shared.MemoryEntry) {
+			if entry.Expires > 0 && entry.Expires <= now {
+				return
+			}
+			for _, command := range rewriteEntry(key, entry) {
+				if err == nil {
+					_, err = w.Write(command.Marshal())
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteEntry returns the commands recreating a single key. Strings carry their expiration
+// with SET PXAT, the other types get a PEXPIREAT after the commands filling them.
+func rewriteEntry(key string, entry shared.MemoryEntry) []protocol.Value {
+	var commands []protocol.Value
+	switch {
+	case entry.Stream != nil:
+		// An empty stream can't be created with XADD alone, so it is left out
+		for _, streamEntry := range entry.Stream {
+			args := []string{"XADD", key, streamEntry.ID}
+			for _, field := range sortedFields(streamEntry.Data) {
+				args = append(args, field, streamEntry.Data[field])
+			}
+			commands = append(commands, commandValue(args...))
+		}
+	case entry.SortedSet != nil:
+		var items []string
+		for _, m := range entry.SortedSet.Sorted() {
+			items = append(items, formatScore(m.Score), m.Member)
+		}
+		commands = batchCommands("ZADD", key, items, 2)
+	case entry.List != nil:
+		commands = batchCommands("RPUSH", key, entry.List.ToArray(), 1)
+	case entry.Array != nil:
+		commands = batchCommands("RPUSH", key, entry.Array, 1)
+	case entry.Set != nil || entry.Hash != nil:
+		// Sets and hashes only come from RDB files and have no write commands here yet,
+		// RESTORE recreates them from their DUMP payload
+		commands = []protocol.Value{commandValue("RESTORE", key, "0", string(DumpValue(entry)))}
+	default:
+		if entry.Expires > 0 {
+			return []protocol.Value{commandValue("SET", key, entry.Value, "PXAT", strconv.FormatInt(entry.Expires, 10))}
+		}
+		return []protocol.Value{commandValue("SET", key, entry.Value)}
+	}
+
+	if entry.Expires > 0 && len(commands) > 0 {
+		commands = append(commands, commandValue("PEXPIREAT", key, strconv.FormatInt(entry.Expires, 10)))
+	}
+	return commands
+}
+
+// batchCommands splits items over commands of up to aofRewriteItemsPerCmd elements,
+// each element being itemSize arguments
+func batchCommands(command, key string, items []string, itemSize int) []protocol.Value {
+	var commands []protocol.Value
+	batch := aofRewriteItemsPerCmd * itemSize
+	for start := 0; start < len(items); start += batch {
+		end := min(start+batch, len(items))
+		args := append([]string{command, key}, items[start:end]...)
+		commands = append(commands, commandValue(args...))
+	}
+	return commands
+}
+
+// commandValue builds a command as a RESP array of bulk strings
+func commandValue(args ...string) protocol.Value {
+	array := make([]protocol.Value, len(args))
+	for i, arg := range args {
+		array[i] = protocol.Value{Typ: "bulk", Bulk: arg}
+	}
+	return protocol.Value{Typ: "array", Array: array}
+}
+
+// LoadAppendOnlyFile replays the append only file named filename in dir through exec.
+// The files listed by its manifest in the append only directory are loaded in order;
+// without a manifest, a single-file append only file in dir is loaded instead.
+func LoadAppendOnlyFile(dir, filename string, exec func(command string, args []shared.Value) shared.Value) error {
+	manifest, err := loadAofManifest(filepath.Join(dir, server.StoreState.AppendDirname), filename)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	if manifest != nil {
+		for _, file := range manifest.files() {
+			paths = append(paths, filepath.Join(dir, server.StoreState.AppendDirname, file.name))
+		}
+	} else {
+		path := filepath.Join(dir, filename)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+		paths = append(paths, path)
+	}
+
+	server.Memory.Clear()
+	for i, path := range paths {
+		if err := loadAppendOnlyPart(path, i == len(paths)-1, exec); err != nil {
+			return err
+		}
+	}
+
+	// The loaded data is already persisted
+	lastSaveDirty.Store(server.Dirty())
+	return nil
+}
+
+// loadAppendOnlyPart replays the commands of one append only file through exec,
+// after loading the RDB preamble the file starts with, if any.
+// Transactions are applied when their EXEC is read, so an incomplete one at the end
+// of the file is dropped. Timestamp annotations are skipped. When the file is the last
+// one, a truncated last command is removed from it, like Redis does with aof-load-truncated,
+// so new writes are appended after valid data.
+func loadAppendOnlyPart(path string, last bool, exec func(command string, args []shared.Value) shared.Value) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read append only file %s: %v", path, err)
+	}
+
+	var valid int64
+
+	// A base file may be an RDB snapshot, possibly followed by commands
+	if bytes.HasPrefix(data, []byte("REDIS")) {
+		size, err := parseRDBPreamble(data)
+		if err != nil {
+			return fmt.Errorf("bad RDB preamble in the append only file: %v", err)
+		}
+		valid = int64(size)
+	}
+	reader := protocol.NewResp(bytes.NewReader(data[valid:]))
+	var transaction []shared.Value
+	inTransaction := false
+
+	for valid < int64(len(data)) {
+		// Annotations are lines starting with '#', the reader restarts after them
+		if data[valid] == '#' {
+			end := bytes.IndexByte(data[valid:], '\n')
+			if end < 0 {
+				if err := truncateAppendOnlyPart(path, valid, int64(len(data)), last); err != nil {
+					return err
+				}
+				break
+			}
+			valid += int64(end) + 1
+			reader = protocol.NewResp(bytes.NewReader(data[valid:]))
+			continue
+		}
+
+		value, err := reader.Read()
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("bad file format reading the append only file: %v", err)
+		}
+
+		// A command cut off by the end of the file is truncated
+		size := int64(len(value.Marshal()))
+		if err != nil || valid+size > int64(len(data)) {
+			if err := truncateAppendOnlyPart(path, valid, int64(len(data)), last); err != nil {
+				return err
+			}
+			break
+		}
+		if value.Typ != "array" || len(value.Array) == 0 {
+			return fmt.Errorf("bad file format reading the append only file at offset %d", valid)
+		}
+		valid += size
+
+		command := strings.ToUpper(value.Array[0].Bulk)
+		switch {
+		case command == "MULTI":
+			inTransaction = true
+			transaction = nil
+		case command == "EXEC" && inTransaction:
+			for _, queued := range transaction {
+				execLoaded(exec, queued)
+			}
+			inTransaction = false
+		case inTransaction:
+			transaction = append(transaction, value)
+		default:
+			execLoaded(exec, value)
+		}
+	}
+
+	if inTransaction {
+		aofLog.Warningf("Revert incomplete MULTI/EXEC transaction in AOF file")
+	}
+	return nil
+}
+
+// truncateAppendOnlyPart cuts a truncated command off the end of the last append only file.
+// Other files are never written to again, so a truncated one is an error.
+func truncateAppendOnlyPart(path string, valid, size int64, last bool) error {
+	if !last {
+		return fmt.Errorf("unexpected end of file in %s, which is not the last append only file", filepath.Base(path))
+	}
+	aofLog.Warningf("Truncated append only file, discarding the last %d bytes", size-valid)
+	if err := os.Truncate(path, valid); err != nil {
+		return fmt.Errorf("failed to truncate append only file: %v", err)
+	}
+	return nil
+}
+
+// execLoaded runs a command read from the append only file
+func execLoaded(exec func(command string, args []shared.Value) shared.Value, value shared.Value) {
+	command := strings.ToUpper(value.Array[0].Bulk)
+	if result := exec(command, value.Array[1:]); result.Typ == "error" {
+		aofLog.Warningf("Error replaying %s from the append only file: %s", command, result.Str)
+	}
+}
+
+// checkAppendOnlyRewrite starts a background rewrite when the append only file grew by
+// auto-aof-rewrite-percentage since the last rewrite and is at least auto-aof-rewrite-min-size.
+// It returns true when a rewrite was started.
+func checkAppendOnlyRewrite() bool {
+	state := server.StoreState
+	aofMu.Lock()
+	open, rewriting, size, base := aofFile != nil, aofRewriting, aofCurrentSize, aofBaseSize
+	aofMu.Unlock()
+
+	if !open || rewriting || state.AutoAOFRewritePercentage <= 0 || size < state.AutoAOFRewriteMinSize {
+		return false
+	}
+	if base == 0 {
+		base = 1
+	}
+	growth := (size - base) * 100 / base
+	if growth < int64(state.AutoAOFRewritePercentage) {
+		return false
+	}
+
+	aofLog.Noticef("Starting automatic rewriting of AOF on %d%% growth", growth)
+	server.CommandLock.Lock()
+	err := BackgroundRewriteAppendOnlyFile()
+	server.CommandLock.Unlock()
+	if err != nil {
+		aofLog.Warningf("Background AOF rewrite error: %v", err)
+		return false
+	}
+	return true
+}
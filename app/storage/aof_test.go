@@ -0,0 +1,551 @@
+package storage
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// setupAppendOnly points the append only file at a temporary directory
+func setupAppendOnly(t testing.TB) string {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:           "master",
+		Replicas:       make(map[string]net.Conn),
+		ConfigDir:      dir,
+		AppendOnly:     true,
+		AppendFilename: "appendonly.aof",
+		AppendDirname:  "appendonlydir",
+		AppendFsync:    AppendFsyncAlways,
+	})
+	server.Memory.Clear()
+	t.Cleanup(func() {
+		CloseAppendOnlyFile()
+		server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	})
+	return dir
+}
+
+// commandBytes returns a command in RESP form
+func commandBytes(args ...string) []byte {
+	return commandValue(args...).Marshal()
+}
+
+// recordExec returns an exec function recording the commands it receives
+func recordExec(commands *[]string) func(string, []shared.Value) shared.Value {
+	return func(command string, args []shared.Value) shared.Value {
+		parts := []string{command}
+		for _, arg := range args {
+			parts = append(parts, arg.Bulk)
+		}
+		*commands = append(*commands, strings.Join(parts, " "))
+		return shared.Value{Typ: "string", Str: "OK"}
+	}
+}
+
+func TestWriteAppendOnlyCommands(t *testing.T) {
+	zset := shared.NewSortedSet()
+	zset.Add("a", 1.5)
+	zset.Add("b", 2)
+	longList := make([]string, aofRewriteItemsPerCmd+1)
+	for i := range longList {
+		longList[i] = "x"
+	}
+
+	tests := []struct {
+		name     string
+		memory   map[string]shared.MemoryEntry
+		expected []string
+	}{
+		{
+			name:     "String",
+			memory:   map[string]shared.MemoryEntry{"k": {Value: "v"}},
+			expected: []string{"SET k v"},
+		},
+		{
+			name:     "String with expiry",
+			memory:   map[string]shared.MemoryEntry{"k": {Value: "v", Expires: 4102444800000}},
+			expected: []string{"SET k v PXAT 4102444800000"},
+		},
+		{
+			name:     "Expired key is skipped",
+			memory:   map[string]shared.MemoryEntry{"k": {Value: "v", Expires: 1}},
+			expected: nil,
+		},
+		{
+			name:     "List",
+			memory:   map[string]shared.MemoryEntry{"l": {List: shared.FromArray([]string{"a", "b"})}},
+			expected: []string{"RPUSH l a b"},
+		},
+		{
+			name:     "Long list is split",
+			memory:   map[string]shared.MemoryEntry{"l": {Array: longList}},
+			expected: []string{"RPUSH l " + strings.TrimSpace(strings.Repeat("x ", aofRewriteItemsPerCmd)), "RPUSH l x"},
+		},
+		{
+			name:     "Sorted set",
+			memory:   map[string]shared.MemoryEntry{"z": {SortedSet: zset}},
+			expected: []string{"ZADD z 1.5 a 2 b"},
+		},
+		{
+			name: "Stream",
+			memory: map[string]shared.MemoryEntry{"s": {Stream: []shared.StreamEntry{
+				{ID: "1-1", Data: map[string]string{"b": "2", "a": "1"}},
+				{ID: "1-2", Data: map[string]string{"c": "3"}},
+			}}},
+			expected: []string{"XADD s 1-1 a 1 b 2", "XADD s 1-2 c 3"},
+		},
+		{
+			name:     "Collections keep their expiry",
+			memory:   map[string]shared.MemoryEntry{"l": {List: shared.FromArray([]string{"a"}), Expires: 4102444800000}},
+			expected: []string{"RPUSH l a", "PEXPIREAT l 4102444800000"},
+		},
+		{
+			name:     "Set",
+			memory:   map[string]shared.MemoryEntry{"s": {Set: map[string]struct{}{"a": {}}, Expires: 4102444800000}},
+			expected: []string{"RESTORE s 0 " + string(DumpValue(shared.MemoryEntry{Set: map[string]struct{}{"a": {}}})), "PEXPIREAT s 4102444800000"},
+		},
+		{
+			name:     "Hash",
+			memory:   map[string]shared.MemoryEntry{"h": {Hash: map[string]string{"f": "v"}}},
+			expected: []string{"RESTORE h 0 " + string(DumpValue(shared.MemoryEntry{Hash: map[string]string{"f": "v"}}))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteAppendOnlyCommands(&buf, tt.memory); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "test.aof"), buf.Bytes(), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			var commands []string
+			if err := LoadAppendOnlyFile(dir, "test.aof", recordExec(&commands)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(commands, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, commands)
+			}
+		})
+	}
+}
+
+func TestLoadAppendOnlyFile(t *testing.T) {
+	set := commandBytes("SET", "a", "1")
+	multi := commandBytes("MULTI")
+	incr := commandBytes("INCR", "a")
+	exec := commandBytes("EXEC")
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected []string
+		size     int // Expected file size after loading
+		wantErr  bool
+	}{
+		{
+			name:     "Plain commands",
+			data:     join(set, incr),
+			expected: []string{"SET a 1", "INCR a"},
+			size:     len(set) + len(incr),
+		},
+		{
+			name:     "Transaction",
+			data:     join(set, multi, incr, incr, exec),
+			expected: []string{"SET a 1", "INCR a", "INCR a"},
+			size:     len(set) + len(multi) + 2*len(incr) + len(exec),
+		},
+		{
+			name:     "Incomplete transaction is dropped",
+			data:     join(set, multi, incr),
+			expected: []string{"SET a 1"},
+			size:     len(set) + len(multi) + len(incr),
+		},
+		{
+			name:     "Truncated last command",
+			data:     join(set, incr[:len(incr)-4]),
+			expected: []string{"SET a 1"},
+			size:     len(set),
+		},
+		{
+			name:     "Truncated length line",
+			data:     join(set, []byte("*2\r\n$3")),
+			expected: []string{"SET a 1"},
+			size:     len(set),
+		},
+		{
+			name:    "Not a command",
+			data:    join(set, []byte("+OK\r\n")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "test.aof")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			var commands []string
+			err := LoadAppendOnlyFile(dir, "test.aof", recordExec(&commands))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(commands, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, commands)
+			}
+			if info, _ := os.Stat(path); info.Size() != int64(tt.size) {
+				t.Errorf("Expected file size %d after loading, got %d", tt.size, info.Size())
+			}
+		})
+	}
+
+	// A missing file is an empty dataset
+	if err := LoadAppendOnlyFile(t.TempDir(), "missing.aof", recordExec(new([]string))); err != nil {
+		t.Errorf("Unexpected error for a missing file: %v", err)
+	}
+}
+
+func TestFeedAppendOnlyFile(t *testing.T) {
+	dir := setupAppendOnly(t)
+
+	// Without an open file, feeding does nothing
+	FeedAppendOnlyFile(commandBytes("SET", "ignored", "1"))
+
+	server.Memory.Set("existing", shared.MemoryEntry{Value: "v"})
+	if err := OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	FeedAppendOnlyFile(commandBytes("SET", "a", "1"))
+
+	var commands []string
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(&commands)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"SET existing v", "SET a 1"}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, commands)
+	}
+}
+
+func TestRewriteAppendOnlyFile(t *testing.T) {
+	dir := setupAppendOnly(t)
+	if err := OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Many writes to the same key collapse into a single SET
+	for i := 0; i < 100; i++ {
+		FeedAppendOnlyFile(commandBytes("SET", "counter", "x"))
+	}
+	server.Memory.Set("counter", shared.MemoryEntry{Value: "x"})
+
+	snapshot, err := startRewrite()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := BackgroundRewriteAppendOnlyFile(); err == nil {
+		t.Errorf("Expected an error while a rewrite is running")
+	}
+
+	// Writes made during the rewrite end up in the new file
+	FeedAppendOnlyFile(commandBytes("SET", "during", "1"))
+	if err := finishRewrite(snapshot); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	FeedAppendOnlyFile(commandBytes("SET", "after", "1"))
+
+	var commands []string
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(&commands)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"SET counter x", "SET during 1", "SET after 1"}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, commands)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "appendonlydir", aofRewriteTempName)); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be renamed")
+	}
+
+	// The new base replaced the first one, writes since the rewrite started are kept
+	manifest, err := loadAofManifest(filepath.Join(dir, "appendonlydir"), "appendonly.aof")
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	expectedManifest := "file appendonly.aof.2.base.aof seq 2 type b\nfile appendonly.aof.2.incr.aof seq 2 type i\n"
+	if manifest.String() != expectedManifest {
+		t.Errorf("Expected manifest %q, got %q", expectedManifest, manifest.String())
+	}
+	for _, name := range []string{"appendonly.aof.1.base.aof", "appendonly.aof.1.incr.aof"} {
+		if _, err := os.Stat(filepath.Join(dir, "appendonlydir", name)); !os.IsNotExist(err) {
+			t.Errorf("Expected replaced file %s to be deleted", name)
+		}
+	}
+}
+
+func TestRewriteAppendOnlyFileRDBPreamble(t *testing.T) {
+	dir := setupAppendOnly(t)
+	server.StoreState.AOFUseRDBPreamble = true
+	server.StoreState.RDBChecksum = true
+	server.Memory.Set("string", shared.MemoryEntry{Value: "value", Expires: 4102444800000})
+	server.Memory.Set("set", shared.MemoryEntry{Set: map[string]struct{}{"a": {}}})
+	server.Memory.Set("hash", shared.MemoryEntry{Hash: map[string]string{"f": "v"}})
+	if err := OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	FeedAppendOnlyFile(commandBytes("SET", "after", "1"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "appendonlydir", "appendonly.aof.1.base.rdb"))
+	if err != nil {
+		t.Fatalf("Failed to read base file: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("REDIS")) {
+		t.Fatalf("Expected the base file to be an RDB snapshot, got %q", data[:10])
+	}
+
+	var commands []string
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(&commands)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"SET after 1"}; !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %q after the preamble, got %q", expected, commands)
+	}
+	if entry := getEntry("string"); entry.Value != "value" || entry.Expires != 4102444800000 {
+		t.Errorf("Expected string from the preamble, got %+v", entry)
+	}
+	if entry := getEntry("set"); len(entry.Set) != 1 {
+		t.Errorf("Expected set from the preamble, got %+v", entry)
+	}
+	if entry := getEntry("hash"); entry.Hash["f"] != "v" {
+		t.Errorf("Expected hash from the preamble, got %+v", entry)
+	}
+
+	// A damaged preamble fails the checksum
+	data[len("REDIS0011")+3] ^= 0xFF
+	if err := os.WriteFile(filepath.Join(dir, "broken.aof"), data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := LoadAppendOnlyFile(dir, "broken.aof", recordExec(new([]string))); err == nil {
+		t.Errorf("Expected an error for a damaged preamble")
+	}
+}
+
+func TestAppendOnlyManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "Base and incremental files",
+			data: "file appendonly.aof.1.base.rdb seq 1 type b\nfile appendonly.aof.1.incr.aof seq 1 type i\nfile appendonly.aof.2.incr.aof seq 2 type i\n",
+		},
+		{
+			name: "Comments, blank lines and history",
+			data: "# written by Redis\n\nfile appendonly.aof.2.base.aof seq 2 type b\nfile appendonly.aof.1.base.aof seq 1 type h\n",
+		},
+		{name: "Empty", data: "# nothing\n", wantErr: true},
+		{name: "Odd number of fields", data: "file appendonly.aof.1.base.rdb seq\n", wantErr: true},
+		{name: "Unknown type", data: "file appendonly.aof.1.base.rdb seq 1 type x\n", wantErr: true},
+		{name: "Two bases", data: "file a seq 1 type b\nfile b seq 2 type b\n", wantErr: true},
+		{name: "Decreasing sequence", data: "file a seq 2 type i\nfile b seq 1 type i\n", wantErr: true},
+		{name: "Path in file name", data: "file ../a seq 1 type b\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := parseAofManifest(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Formatting gives back the same files, without comments
+			again, err := parseAofManifest(manifest.String())
+			if err != nil || !reflect.DeepEqual(again, manifest) {
+				t.Errorf("Expected %q to round-trip, got %+v (%v)", manifest.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestLoadAppendOnlyFileParts(t *testing.T) {
+	dir := setupAppendOnly(t)
+	aofDir := filepath.Join(dir, "appendonlydir")
+	os.MkdirAll(aofDir, 0755)
+	files := map[string][]byte{
+		"appendonly.aof.manifest":   []byte("file appendonly.aof.1.base.aof seq 1 type b\nfile appendonly.aof.1.incr.aof seq 1 type i\nfile appendonly.aof.2.incr.aof seq 2 type i\n"),
+		"appendonly.aof.1.base.aof": commandBytes("SET", "a", "1"),
+		"appendonly.aof.1.incr.aof": append([]byte("#TS:1700000000\r\n"), commandBytes("INCR", "a")...),
+		"appendonly.aof.2.incr.aof": append(commandBytes("SET", "b", "2"), commandBytes("INCR", "a")[:6]...),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(aofDir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	// The parts are replayed in order, annotations skipped and the last one truncated
+	var commands []string
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(&commands)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"SET a 1", "INCR a", "SET b 2"}; !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, commands)
+	}
+	if info, _ := os.Stat(filepath.Join(aofDir, "appendonly.aof.2.incr.aof")); info.Size() != int64(len(commandBytes("SET", "b", "2"))) {
+		t.Errorf("Expected the last file to be truncated, got %d bytes", info.Size())
+	}
+
+	// Only the last file may be truncated
+	os.WriteFile(filepath.Join(aofDir, "appendonly.aof.1.incr.aof"), commandBytes("INCR", "a")[:6], 0644)
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(new([]string))); err == nil {
+		t.Errorf("Expected an error for a truncated file that is not the last one")
+	}
+
+	// A file listed in the manifest must exist
+	os.Remove(filepath.Join(aofDir, "appendonly.aof.1.incr.aof"))
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(new([]string))); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestOpenAppendOnlyFileUpgrade(t *testing.T) {
+	dir := setupAppendOnly(t)
+	legacy := commandBytes("SET", "old", "1")
+	if err := os.WriteFile(filepath.Join(dir, "appendonly.aof"), legacy, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// The single file becomes the base of the new layout
+	if err := OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	FeedAppendOnlyFile(commandBytes("SET", "new", "1"))
+	if _, err := os.Stat(filepath.Join(dir, "appendonly.aof")); !os.IsNotExist(err) {
+		t.Errorf("Expected the old file to be moved")
+	}
+
+	var commands []string
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(&commands)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"SET old 1", "SET new 1"}; !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, commands)
+	}
+	if stats := GetPersistenceStats(); stats.AOFCurrentSize != int64(2*len(legacy)) {
+		t.Errorf("Expected the size of both files, got %d", stats.AOFCurrentSize)
+	}
+}
+
+func TestFeedAppendOnlyFileTimestamps(t *testing.T) {
+	dir := setupAppendOnly(t)
+	server.StoreState.AOFTimestampEnabled = true
+	if err := OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	FeedAppendOnlyFile(commandBytes("SET", "a", "1"))
+	FeedAppendOnlyFile(commandBytes("SET", "b", "1"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "appendonlydir", "appendonly.aof.1.incr.aof"))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("#TS:")) || bytes.Count(data, []byte("#TS:")) > 2 {
+		t.Errorf("Expected a timestamp annotation before the writes, got %q", data)
+	}
+
+	var commands []string
+	if err := LoadAppendOnlyFile(dir, "appendonly.aof", recordExec(&commands)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"SET a 1", "SET b 1"}; !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, commands)
+	}
+}
+
+func TestCheckAppendOnlyRewrite(t *testing.T) {
+	setupAppendOnly(t)
+	server.StoreState.AutoAOFRewritePercentage = 100
+	server.StoreState.AutoAOFRewriteMinSize = 1024
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+	if err := OpenAppendOnlyFile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Below the minimum size
+	FeedAppendOnlyFile(commandBytes("SET", "key", "value"))
+	if checkAppendOnlyRewrite() {
+		t.Fatalf("Expected no rewrite below the minimum size")
+	}
+
+	for i := 0; i < 100; i++ {
+		FeedAppendOnlyFile(commandBytes("SET", "key", "value"))
+	}
+	if !checkAppendOnlyRewrite() {
+		t.Fatalf("Expected a rewrite once the file doubled and passed the minimum size")
+	}
+	deadline := time.Now().Add(time.Second)
+	for AppendOnlyRewriteInProgress() {
+		if time.Now().After(deadline) {
+			t.Fatalf("AOF rewrite did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	aofMu.Lock()
+	size, base := aofCurrentSize, aofBaseSize
+	aofMu.Unlock()
+	if size != base || size != int64(len(commandBytes("SET", "key", "value"))) {
+		t.Errorf("Expected the rewritten file to hold one command, got size %d base %d", size, base)
+	}
+
+	// Disabled by a zero percentage
+	server.StoreState.AutoAOFRewritePercentage = 0
+	for i := 0; i < 100; i++ {
+		FeedAppendOnlyFile(commandBytes("SET", "key", "value"))
+	}
+	if checkAppendOnlyRewrite() {
+		t.Errorf("Expected no rewrite when auto-aof-rewrite-percentage is 0")
+	}
+}
+
+func BenchmarkFeedAppendOnlyFile(b *testing.B) {
+	setupAppendOnly(b)
+	server.StoreState.AppendFsync = AppendFsyncNo
+	if err := OpenAppendOnlyFile(); err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	command := commandBytes("SET", "key", "value")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FeedAppendOnlyFile(command)
+	}
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// Types of the files listed in an AOF manifest
+const (
+	aofBaseType    = "b" // Snapshot of the dataset written by the last rewrite
+	aofIncrType    = "i" // Writes made after the base was taken
+	aofHistoryType = "h" // Files replaced by a rewrite, waiting to be deleted
+)
+
+// aofManifestFile is one file of a multi-part append only file
+type aofManifestFile struct {
+	name string
+	seq  int
+	kind string
+}
+
+// aofManifest lists the files making up the append only file, like Redis 7 does:
+// a base file followed by incremental files, replayed in order
+type aofManifest struct {
+	base    *aofManifestFile
+	incrs   []aofManifestFile
+	history []aofManifestFile
+	baseSeq int // Sequence number of the latest base file
+	incrSeq int // Sequence number of the latest incremental file
+}
+
+// aofDir returns the directory holding the append only files (appenddirname)
+func aofDir() string {
+	return filepath.Join(server.StoreState.ConfigDir, server.StoreState.AppendDirname)
+}
+
+// aofManifestName returns the name of the manifest for the given append only file name
+func aofManifestName(filename string) string {
+	return filename + ".manifest"
+}
+
+// aofBaseName returns the name of a base file, ending in .rdb when it is an RDB snapshot
+func aofBaseName(filename string, seq int, rdb bool) string {
+	if rdb {
+		return fmt.Sprintf("%s.%d.base.rdb", filename, seq)
+	}
+	return fmt.Sprintf("%s.%d.base.aof", filename, seq)
+}
+
+// aofIncrName returns the name of an incremental file
+func aofIncrName(filename string, seq int) string {
+	return fmt.Sprintf("%s.%d.incr.aof", filename, seq)
+}
+
+// files returns the base and incremental files in the order they are replayed
+func (m *aofManifest) files() []aofManifestFile {
+	var files []aofManifestFile
+	if m.base != nil {
+		files = append(files, *m.base)
+	}
+	return append(files, m.incrs...)
+}
+
+// String formats the manifest, one "file <name> seq <seq> type <type>" line per file
+func (m *aofManifest) String() string {
+	var sb strings.Builder
+	for _, file := range append(m.files(), m.history...) {
+		fmt.Fprintf(&sb, "file %s seq %d type %s\n", file.name, file.seq, file.kind)
+	}
+	return sb.String()
+}
+
+// parseAofManifest parses the content of a manifest file
+func parseAofManifest(data string) (*aofManifest, error) {
+	m := &aofManifest{}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields)%2 != 0 {
+			return nil, fmt.Errorf("invalid AOF manifest line: %q", line)
+		}
+		file := aofManifestFile{}
+		for i := 0; i < len(fields); i += 2 {
+			switch fields[i] {
+			case "file":
+				file.name = fields[i+1]
+			case "seq":
+				seq, err := strconv.Atoi(fields[i+1])
+				if err != nil || seq < 0 {
+					return nil, fmt.Errorf("invalid AOF manifest line: %q", line)
+				}
+				file.seq = seq
+			case "type":
+				file.kind = fields[i+1]
+			}
+		}
+		if file.name == "" || strings.ContainsAny(file.name, `/\`) {
+			return nil, fmt.Errorf("invalid AOF manifest line: %q", line)
+		}
+
+		switch file.kind {
+		case aofBaseType:
+			if m.base != nil {
+				return nil, fmt.Errorf("found duplicate base file information in the AOF manifest")
+			}
+			m.base = &file
+			m.baseSeq = file.seq
+		case aofIncrType:
+			if file.seq <= m.incrSeq {
+				return nil, fmt.Errorf("found a non-monotonic sequence number in the AOF manifest")
+			}
+			m.incrs = append(m.incrs, file)
+			m.incrSeq = file.seq
+		case aofHistoryType:
+			m.history = append(m.history, file)
+		default:
+			return nil, fmt.Errorf("unknown AOF file type %q in the AOF manifest", file.kind)
+		}
+	}
+	if m.base == nil && len(m.incrs) == 0 {
+		return nil, fmt.Errorf("the AOF manifest is empty")
+	}
+	return m, nil
+}
+
+// loadAofManifest reads the manifest of filename in dir, it returns nil if there is none
+func loadAofManifest(dir, filename string) (*aofManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, aofManifestName(filename)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the AOF manifest: %v", err)
+	}
+	return parseAofManifest(string(data))
+}
+
+// writeAofManifest replaces the manifest of filename in dir.
+// It is written to a temporary file first, so a crash never leaves a partial manifest.
+func writeAofManifest(dir, filename string, m *aofManifest) error {
+	tmp := filepath.Join(dir, "temp-"+aofManifestName(filename))
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write the AOF manifest: %v", err)
+	}
+	_, err = file.WriteString(m.String())
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, aofManifestName(filename)))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write the AOF manifest: %v", err)
+	}
+	return nil
+}
+
+// upgradeAppendOnlyFile moves a single-file append only file from dir into the
+// append only directory and makes it the base of a new manifest
+func upgradeAppendOnlyFile(dir, filename string) (*aofManifest, error) {
+	if err := os.MkdirAll(aofDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %v", aofDir(), err)
+	}
+	if err := os.Rename(filepath.Join(dir, filename), filepath.Join(aofDir(), filename)); err != nil {
+		return nil, fmt.Errorf("failed to move the append only file: %v", err)
+	}
+
+	m := &aofManifest{base: &aofManifestFile{name: filename, seq: 1, kind: aofBaseType}, baseSeq: 1}
+	if err := writeAofManifest(aofDir(), filename, m); err != nil {
+		return nil, err
+	}
+	aofLog.Noticef("Successfully migrated an old-style AOF into the AOF directory")
+	return m, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// digest is a SHA1 sized accumulator, the digests of keys and of unordered elements are
+// combined with xor so the order they are visited in doesn't change the result
+type digest [sha1.Size]byte
+
+// xor combines the SHA1 of data into d, in any order
+func (d *digest) xor(data string) {
+	sum := sha1.Sum([]byte(data))
+	for i := range d {
+		d[i] ^= sum[i]
+	}
+}
+
+// mix combines data into d, in order: mixing the same data in another order changes d
+func (d *digest) mix(data string) {
+	d.xor(data)
+	*d = sha1.Sum(d[:])
+}
+
+// String returns the digest in hex, like DEBUG DIGEST replies it
+func (d digest) String() string {
+	return hex.EncodeToString(d[:])
+}
+
+// Digest returns the digest of the dataset in hex, see Snapshot.Digest
+func Digest() string {
+	snapshot := TakeSnapshot()
+	defer snapshot.Release()
+	return snapshot.Digest()
+}
+
+// Digest returns a digest of the keys of the snapshot, their values and whether they expire,
+// in hex. Datasets holding the same keys and values have the same digest whatever the order
+// they were written in, so a master and its replicas can be compared. An empty dataset has a
+// digest of zeros.
+func (s *Snapshot) Digest() string {
+	var d digest
+	keys, _ := s.keys()
+	now := time.Now().UnixMilli()
+	for _, key := range keys {
+		s.visit(key, func(entry shared.MemoryEntry) {
+			if entry.Expires > 0 && entry.Expires <= now {
+				return
+			}
+			var keyDigest digest
+			keyDigest.mix(key)
+			mixValueDigest(&keyDigest, entry)
+			if entry.Expires > 0 {
+				keyDigest.xor("!!expire!!")
+			}
+			d.xor(string(keyDigest[:]))
+		})
+	}
+	return d.String()
+}
+
+// ValueDigest returns the digest of the value of entry in hex, like DEBUG DIGEST-VALUE
+// replies it. It doesn't depend on the key or the expiration.
+func ValueDigest(entry shared.MemoryEntry) string {
+	var d digest
+	mixValueDigest(&d, entry)
+	return d.String()
+}
+
+// mixValueDigest combines the value of entry into d. Lists and streams are mixed in order,
+// the members of sets, sorted sets and hashes are combined in any order.
+func mixValueDigest(d *digest, entry shared.MemoryEntry) {
+	switch {
+	case entry.Stream != nil:
+		for _, e := range entry.Stream {
+			d.mix(e.ID)
+			for _, field := range sortedFields(e.Data) {
+				d.mix(field)
+				d.mix(e.Data[field])
+			}
+		}
+	case entry.SortedSet != nil:
+		entry.SortedSet.Range(func(member string, score float64) bool {
+			var element digest
+			element.mix(member)
+			element.mix(formatScore(score))
+			d.xor(string(element[:]))
+			return true
+		})
+	case entry.List != nil:
+		for _, value := range entry.List.ToArray() {
+			d.mix(value)
+		}
+	case entry.Array != nil:
+		for _, value := range entry.Array {
+			d.mix(value)
+		}
+	case entry.Set != nil:
+		for member := range entry.Set {
+			d.xor(member)
+		}
+	case entry.Hash != nil:
+		for field, value := range entry.Hash {
+			var element digest
+			element.mix(field)
+			element.mix(value)
+			d.xor(string(element[:]))
+		}
+	default:
+		d.mix(entry.Value)
+	}
+}
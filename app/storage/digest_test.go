@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestDigest(t *testing.T) {
+	if digest := snapshotOf(map[string]shared.MemoryEntry{}).Digest(); digest != strings.Repeat("0", 40) {
+		t.Errorf("Expected an empty dataset to digest to zeros, got %s", digest)
+	}
+
+	dataset := func(members ...string) map[string]shared.MemoryEntry {
+		zset := shared.NewSortedSet()
+		set := map[string]struct{}{}
+		hash := map[string]string{}
+		for i, member := range members {
+			zset.Add(member, float64(i%2))
+			set[member] = struct{}{}
+			hash[member] = "v"
+		}
+		return map[string]shared.MemoryEntry{
+			"string": {Value: "hello"},
+			"list":   {Array: []string{"a", "b"}},
+			"zset":   {SortedSet: zset},
+			"set":    {Set: set},
+			"hash":   {Hash: hash},
+		}
+	}
+	digest := snapshotOf(dataset("a", "b", "c", "d")).Digest()
+	if len(digest) != 40 {
+		t.Fatalf("Expected 40 hex digits, got %q", digest)
+	}
+	if other := snapshotOf(dataset("c", "b", "a", "d")).Digest(); other != digest {
+		t.Errorf("Expected the insertion order not to change the digest, got %s and %s", digest, other)
+	}
+
+	changes := map[string]func(map[string]shared.MemoryEntry){
+		"value":      func(m map[string]shared.MemoryEntry) { m["string"] = shared.MemoryEntry{Value: "hellO"} },
+		"list order": func(m map[string]shared.MemoryEntry) { m["list"] = shared.MemoryEntry{Array: []string{"b", "a"}} },
+		"key name":   func(m map[string]shared.MemoryEntry) { m["string2"] = m["string"]; delete(m, "string") },
+		"expiration": func(m map[string]shared.MemoryEntry) {
+			m["string"] = shared.MemoryEntry{Value: "hello", Expires: time.Now().Add(time.Hour).UnixMilli()}
+		},
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			memory := dataset("a", "b", "c", "d")
+			change(memory)
+			if other := snapshotOf(memory).Digest(); other == digest {
+				t.Errorf("Expected the digest to change")
+			}
+		})
+	}
+
+	expired := dataset("a", "b", "c", "d")
+	expired["gone"] = shared.MemoryEntry{Value: "x", Expires: time.Now().Add(-time.Second).UnixMilli()}
+	if other := snapshotOf(expired).Digest(); other != digest {
+		t.Errorf("Expected expired keys to be left out, got %s and %s", digest, other)
+	}
+}
+
+func TestValueDigest(t *testing.T) {
+	a := ValueDigest(shared.MemoryEntry{Hash: map[string]string{"f1": "v1", "f2": "v2"}})
+	b := ValueDigest(shared.MemoryEntry{Hash: map[string]string{"f2": "v2", "f1": "v1"}})
+	if a != b {
+		t.Errorf("Expected equal hashes to have the same digest, got %s and %s", a, b)
+	}
+	if c := ValueDigest(shared.MemoryEntry{Hash: map[string]string{"f1": "v2", "f2": "v1"}}); c == a {
+		t.Errorf("Expected swapped values to change the digest")
+	}
+	if ValueDigest(shared.MemoryEntry{Value: "v"}) != ValueDigest(shared.MemoryEntry{Value: "v", Expires: 1}) {
+		t.Errorf("Expected the expiration to be left out of the value digest")
+	}
+}
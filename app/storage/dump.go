@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// ErrBadDumpPayload is returned for a RESTORE payload that is truncated, from a newer format
+// or whose checksum doesn't match
+var ErrBadDumpPayload = errors.New("ERR DUMP payload version or checksum are wrong")
+
+// DumpValue serializes the value of entry for DUMP: its type and value as an RDB file saves
+// them, followed by the RDB version and a CRC64 of the payload. The expiration is left out,
+// RESTORE is given it apart.
+func DumpValue(entry shared.MemoryEntry) []byte {
+	var buf bytes.Buffer
+	rw := NewRDBWriter(&buf)
+	rw.writeByte(rdbValueType(entry))
+	rw.writeValue(entry)
+	var version [2]byte
+	binary.LittleEndian.PutUint16(version[:], rdbVersion)
+	rw.write(version[:])
+	var checksum [8]byte
+	binary.LittleEndian.PutUint64(checksum[:], rw.crc)
+	rw.write(checksum[:])
+	rw.flush()
+	return buf.Bytes()
+}
+
+// RestoreValue returns the value in a DUMP payload. Payloads dumped by Redis up to 7.4, of
+// RDB versions up to 12, are read like the RDB files they write, so keys can be migrated.
+func RestoreValue(payload []byte) (shared.MemoryEntry, error) {
+	if len(payload) < 11 {
+		return shared.MemoryEntry{}, ErrBadDumpPayload
+	}
+	footer := len(payload) - 10
+	version := int(binary.LittleEndian.Uint16(payload[footer:]))
+	if version > 12 {
+		return shared.MemoryEntry{}, ErrBadDumpPayload
+	}
+	if server.StoreState.RDBChecksum && crc64Update(0, payload[:footer+2]) != binary.LittleEndian.Uint64(payload[footer+2:]) {
+		return shared.MemoryEntry{}, ErrBadDumpPayload
+	}
+
+	p := NewRDBParser(payload[:footer])
+	p.version = version
+	valueType, _ := p.readByte()
+	entry, err := p.readValue(valueType)
+	if err != nil || p.pos != footer {
+		return shared.MemoryEntry{}, errors.New("ERR Bad data format")
+	}
+	return entry, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestDumpValueRoundTrip(t *testing.T) {
+	zset := shared.NewSortedSet()
+	zset.Add("a", 1.5)
+	zset.Add("b", -2)
+	list := shared.MemoryEntry{}
+	list.SetList([]string{"x", "y", "z"})
+
+	entries := map[string]shared.MemoryEntry{
+		"string":     {Value: "hello"},
+		"integer":    {Value: "12345"},
+		"list":       list,
+		"set":        {Set: map[string]struct{}{"m1": {}, "m2": {}}},
+		"hash":       {Hash: map[string]string{"f1": "v1", "f2": "v2"}},
+		"sorted set": {SortedSet: zset},
+		"stream":     {Stream: []shared.StreamEntry{{ID: "1-1", Data: map[string]string{"f": "v"}}}},
+	}
+	for name, entry := range entries {
+		t.Run(name, func(t *testing.T) {
+			restored, err := RestoreValue(DumpValue(entry))
+			if err != nil {
+				t.Fatalf("Expected the payload to restore, got %v", err)
+			}
+			if !reflect.DeepEqual(DumpValue(restored), DumpValue(entry)) {
+				t.Errorf("Expected the restored value to dump the same")
+			}
+		})
+	}
+}
+
+func TestRestoreValueFromRedis(t *testing.T) {
+	defer func(checksum bool) { server.StoreState.RDBChecksum = checksum }(server.StoreState.RDBChecksum)
+	server.StoreState.RDBChecksum = true
+	// DUMP of the integer 10 by Redis 5, with RDB version 9
+	payload := []byte("\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n")
+	entry, err := RestoreValue(payload)
+	if err != nil || entry.Value != "10" {
+		t.Fatalf("Expected 10, got %q, %v", entry.Value, err)
+	}
+
+	corrupted := append([]byte(nil), payload...)
+	corrupted[1] = 0xc1
+	if _, err := RestoreValue(corrupted); err != ErrBadDumpPayload {
+		t.Errorf("Expected a wrong checksum to be refused, got %v", err)
+	}
+	newer := DumpValue(shared.MemoryEntry{Value: "v"})
+	newer[len(newer)-10] = 13
+	if _, err := RestoreValue(newer); err != ErrBadDumpPayload {
+		t.Errorf("Expected a newer RDB version to be refused, got %v", err)
+	}
+	if _, err := RestoreValue([]byte("short")); err != ErrBadDumpPayload {
+		t.Errorf("Expected a truncated payload to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// exportedKey is a key as WriteJSON writes it
+type exportedKey struct {
+	Key       string `json:"key"`
+	Type      string `json:"type"`
+	ExpiresAt int64  `json:"expires_at,omitempty"` // Unix time in milliseconds, left out without expiration
+	Value     any    `json:"value"`
+}
+
+// exportedMember is a member of a sorted set, with its score as a number, or "inf" and "-inf"
+// which JSON numbers can't hold
+type exportedMember struct {
+	Member string `json:"member"`
+	Score  any    `json:"score"`
+}
+
+// exportedStreamEntry is an entry of a stream
+type exportedStreamEntry struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+// ExportJSONFile writes a snapshot of the dataset to path as JSON, see Snapshot.WriteJSON. A
+// relative path is in the configured dir, like the RDB file. The export is written to a
+// temporary file renamed over path, so a previous export is replaced only once complete.
+func ExportJSONFile(path string) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(server.StoreState.ConfigDir, path)
+	}
+	snapshot := TakeSnapshot()
+	defer snapshot.Release()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "temp-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary export file: %v", err)
+	}
+	err = snapshot.WriteJSON(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+	return nil
+}
+
+// WriteJSON writes memory to w as JSON, see Snapshot.WriteJSON
+func WriteJSON(w io.Writer, memory map[string]shared.MemoryEntry) error {
+	return snapshotOf(memory).WriteJSON(w)
+}
+
+// WriteJSON writes the snapshot to w as a JSON array holding an object per key, with its name,
+// type, expiration and value. Keys are sorted and written one per line, set members and hash
+// fields are sorted too, so exports of the same dataset are identical and two datasets can be
+// compared with diff. Expirations are absolute, so exporting twice doesn't change them, and
+// keys that have expired are left out.
+//
+// Values are JSON strings, so bytes that are not valid UTF-8 are replaced: the export is meant
+// to be read, an RDB file is the one to load back.
+//
+// Examples:
+//
+//	[
+//	{"key":"counter","type":"string","value":"10"},
+//	{"key":"queue","type":"list","expires_at":1767225600000,"value":["a","b"]},
+//	{"key":"scores","type":"zset","value":[{"member":"alice","score":1.5}]}
+//	]
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	keys, _ := s.keys()
+	now := time.Now().UnixMilli()
+
+	bw.WriteString("[")
+	first := true
+	var err error
+	for _, key := range keys {
+		s.visit(key, func(entry shared.MemoryEntry) {
+			if err != nil || (entry.Expires > 0 && entry.Expires <= now) {
+				return
+			}
+			var line []byte
+			line, err = json.Marshal(exportEntry(key, entry))
+			if err != nil {
+				return
+			}
+			if !first {
+				bw.WriteString(",")
+			}
+			first = false
+			bw.WriteString("\n")
+			bw.Write(line)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	bw.WriteString("\n]\n")
+	return bw.Flush()
+}
+
+// exportEntry returns the key holding entry as WriteJSON writes it
+func exportEntry(key string, entry shared.MemoryEntry) exportedKey {
+	exported := exportedKey{Key: key, ExpiresAt: entry.Expires}
+	switch {
+	case entry.Stream != nil:
+		entries := make([]exportedStreamEntry, len(entry.Stream))
+		for i, e := range entry.Stream {
+			entries[i] = exportedStreamEntry{ID: e.ID, Fields: e.Data}
+		}
+		exported.Type, exported.Value = "stream", entries
+	case entry.SortedSet != nil:
+		members := []exportedMember{}
+		for _, m := range entry.SortedSet.Sorted() {
+			var score any = m.Score
+			if math.IsInf(m.Score, 0) {
+				score = formatScore(m.Score)
+			}
+			members = append(members, exportedMember{Member: m.Member, Score: score})
+		}
+		exported.Type, exported.Value = "zset", members
+	case entry.List != nil:
+		exported.Type, exported.Value = "list", entry.List.ToArray()
+	case entry.Array != nil:
+		exported.Type, exported.Value = "list", entry.Array
+	case entry.Set != nil:
+		members := make([]string, 0, len(entry.Set))
+		for member := range entry.Set {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		exported.Type, exported.Value = "set", members
+	case entry.Hash != nil:
+		// encoding/json writes the fields of a map in sorted order
+		exported.Type, exported.Value = "hash", entry.Hash
+	default:
+		exported.Type, exported.Value = "string", entry.Value
+	}
+	return exported
+}
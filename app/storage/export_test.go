@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestWriteJSON(t *testing.T) {
+	zset := shared.NewSortedSet()
+	zset.Add("a", 1.5)
+	zset.Add("b", math.Inf(1))
+	list := shared.MemoryEntry{}
+	list.SetList([]string{"x", "y"})
+	future := time.Now().Add(time.Hour).UnixMilli()
+
+	memory := map[string]shared.MemoryEntry{
+		"string":  {Value: "hello", Expires: future},
+		"expired": {Value: "gone", Expires: time.Now().Add(-time.Second).UnixMilli()},
+		"list":    list,
+		"set":     {Set: map[string]struct{}{"m2": {}, "m1": {}}},
+		"hash":    {Hash: map[string]string{"f2": "v2", "f1": "v1"}},
+		"zset":    {SortedSet: zset},
+		"stream":  {Stream: []shared.StreamEntry{{ID: "1-1", Data: map[string]string{"f": "v"}}}},
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, memory); err != nil {
+		t.Fatalf("Expected the export to succeed, got %v", err)
+	}
+
+	expected := "[\n" +
+		`{"key":"hash","type":"hash","value":{"f1":"v1","f2":"v2"}},` + "\n" +
+		`{"key":"list","type":"list","value":["x","y"]},` + "\n" +
+		`{"key":"set","type":"set","value":["m1","m2"]},` + "\n" +
+		`{"key":"stream","type":"stream","value":[{"id":"1-1","fields":{"f":"v"}}]},` + "\n" +
+		`{"key":"string","type":"string","expires_at":` + strconv.FormatInt(future, 10) + `,"value":"hello"},` + "\n" +
+		`{"key":"zset","type":"zset","value":[{"member":"a","score":1.5},{"member":"b","score":"inf"}]}` + "\n" +
+		"]\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, map[string]shared.MemoryEntry{}); err != nil || buf.String() != "[\n]\n" {
+		t.Errorf("Expected an empty array, got %q, %v", buf.String(), err)
+	}
+}
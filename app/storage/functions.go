@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// rdbOpcodeFunction2 precedes the code of a function library, saved before the dataset
+const rdbOpcodeFunction2 = 0xF5
+
+// rdbVersion is the RDB format version written in files and function dump payloads
+const rdbVersion = 11
+
+// ErrBadFunctionsPayload is returned for a FUNCTION RESTORE payload that is truncated,
+// from a newer format or whose checksum doesn't match
+var ErrBadFunctionsPayload = errors.New("ERR payload version or checksum are wrong")
+
+// functionLibraries returns the code of the function libraries saved with the dataset, and
+// loadFunctionLibraries replaces them with the ones of a loaded RDB file. The scripting engine,
+// which owns the libraries, sets them with SetFunctionLibraries.
+var (
+	functionLibraries     = func() []string { return nil }
+	loadFunctionLibraries = func(codes []string) error { return nil }
+)
+
+// SetFunctionLibraries connects the function libraries of the scripting engine to RDB files:
+// libraries returns the code of every library, load replaces them all
+func SetFunctionLibraries(libraries func() []string, load func(codes []string) error) {
+	functionLibraries = libraries
+	loadFunctionLibraries = load
+}
+
+// writeFunctions writes the code of each library after the FUNCTION2 opcode
+func (rw *RDBWriter) writeFunctions(codes []string) {
+	for _, code := range codes {
+		rw.writeByte(rdbOpcodeFunction2)
+		rw.writeString(code)
+	}
+}
+
+// DumpFunctions serializes the code of function libraries for FUNCTION DUMP: the libraries as
+// an RDB file saves them, followed by the RDB version and a CRC64 of the payload
+func DumpFunctions(codes []string) []byte {
+	var buf bytes.Buffer
+	rw := NewRDBWriter(&buf)
+	rw.writeFunctions(codes)
+	var version [2]byte
+	binary.LittleEndian.PutUint16(version[:], rdbVersion)
+	rw.write(version[:])
+	var checksum [8]byte
+	binary.LittleEndian.PutUint64(checksum[:], rw.crc)
+	rw.write(checksum[:])
+	rw.flush()
+	return buf.Bytes()
+}
+
+// RestoreFunctions returns the code of the function libraries in a FUNCTION DUMP payload
+func RestoreFunctions(payload []byte) ([]string, error) {
+	if len(payload) < 10 {
+		return nil, ErrBadFunctionsPayload
+	}
+	footer := len(payload) - 10
+	if binary.LittleEndian.Uint16(payload[footer:]) > rdbVersion {
+		return nil, ErrBadFunctionsPayload
+	}
+	if server.StoreState.RDBChecksum && crc64Update(0, payload[:footer+2]) != binary.LittleEndian.Uint64(payload[footer+2:]) {
+		return nil, ErrBadFunctionsPayload
+	}
+
+	p := NewRDBParser(payload[:footer])
+	var codes []string
+	for p.pos < len(p.data) {
+		opcode, _ := p.readByte()
+		if opcode != rdbOpcodeFunction2 {
+			return nil, errors.New("ERR given type is not a function")
+		}
+		code, err := p.readLengthEncodedString()
+		if err != nil {
+			return nil, fmt.Errorf("ERR failed loading library: %v", err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
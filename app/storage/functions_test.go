@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// fakeFunctionLibraries stands in for the scripting engine, keeping the code of libraries as is
+func fakeFunctionLibraries(t *testing.T, codes []string) *[]string {
+	loaded := &codes
+	SetFunctionLibraries(func() []string { return *loaded }, func(codes []string) error {
+		*loaded = codes
+		return nil
+	})
+	t.Cleanup(func() {
+		SetFunctionLibraries(func() []string { return nil }, func([]string) error { return nil })
+	})
+	return loaded
+}
+
+func TestWriteRDBFunctions(t *testing.T) {
+	codes := []string{"#!lua name=a\nredis.register_function('f', function() return 1 end)", "#!lua name=b\n" + strings.Repeat("-- padding\n", 100)}
+	loaded := fakeFunctionLibraries(t, codes)
+
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, map[string]shared.MemoryEntry{"key": {Value: "value"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	*loaded = nil
+	if err := ParseRDBData(buf.Bytes()); err != nil {
+		t.Fatalf("Failed to parse written RDB: %v", err)
+	}
+	if !reflect.DeepEqual(*loaded, codes) {
+		t.Errorf("Expected libraries %q, got %q", codes, *loaded)
+	}
+
+	// A file without libraries removes the loaded ones
+	*loaded = nil
+	buf.Reset()
+	if err := WriteRDB(&buf, map[string]shared.MemoryEntry{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	*loaded = codes
+	if err := ParseRDBData(buf.Bytes()); err != nil {
+		t.Fatalf("Failed to parse written RDB: %v", err)
+	}
+	if len(*loaded) != 0 {
+		t.Errorf("Expected no libraries, got %q", *loaded)
+	}
+}
+
+func TestDumpRestoreFunctions(t *testing.T) {
+	defer func(checksum bool) { server.StoreState.RDBChecksum = checksum }(server.StoreState.RDBChecksum)
+	server.StoreState.RDBChecksum = true
+
+	codes := []string{"#!lua name=a\nreturn", "#!lua name=b\nreturn"}
+	payload := DumpFunctions(codes)
+
+	restored, err := RestoreFunctions(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(restored, codes) {
+		t.Errorf("Expected %q, got %q", codes, restored)
+	}
+
+	if restored, err := RestoreFunctions(DumpFunctions(nil)); err != nil || len(restored) != 0 {
+		t.Errorf("Expected no libraries, got %q (%v)", restored, err)
+	}
+
+	corrupted := append([]byte(nil), payload...)
+	corrupted[3] ^= 0xFF
+	if _, err := RestoreFunctions(corrupted); err != ErrBadFunctionsPayload {
+		t.Errorf("Expected %v for a corrupted payload, got %v", ErrBadFunctionsPayload, err)
+	}
+	if _, err := RestoreFunctions(payload[:5]); err != ErrBadFunctionsPayload {
+		t.Errorf("Expected %v for a truncated payload, got %v", ErrBadFunctionsPayload, err)
+	}
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// listpackEnd terminates every listpack
+const listpackEnd = 0xFF
+
+// listpackBuilder builds a listpack, the compact encoding Redis uses for
+// quicklist nodes, small sorted sets and stream nodes.
+type listpackBuilder struct {
+	entries []byte
+	count   int
+}
+
+// appendString appends an element, using the integer encoding when the string
+// is the canonical representation of an int64, like Redis does.
+func (lb *listpackBuilder) appendString(s string) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(n, 10) == s {
+		lb.appendInt(n)
+		return
+	}
+
+	start := len(lb.entries)
+	switch length := len(s); {
+	case length < 1<<6:
+		lb.entries = append(lb.entries, 0x80|byte(length))
+	case length < 1<<12:
+		lb.entries = append(lb.entries, 0xE0|byte(length>>8), byte(length))
+	default:
+		lb.entries = append(lb.entries, 0xF0)
+		lb.entries = binary.LittleEndian.AppendUint32(lb.entries, uint32(length))
+	}
+	lb.entries = append(lb.entries, s...)
+	lb.appendBacklen(len(lb.entries) - start)
+}
+
+// appendInt appends an integer element using the smallest integer encoding that fits
+func (lb *listpackBuilder) appendInt(n int64) {
+	start := len(lb.entries)
+	switch {
+	case n >= 0 && n <= 127:
+		lb.entries = append(lb.entries, byte(n))
+	case n >= -4096 && n <= 4095:
+		u := uint16(n) & 0x1FFF
+		lb.entries = append(lb.entries, 0xC0|byte(u>>8), byte(u))
+	case n >= -32768 && n <= 32767:
+		lb.entries = append(lb.entries, 0xF1)
+		lb.entries = binary.LittleEndian.AppendUint16(lb.entries, uint16(n))
+	case n >= -8388608 && n <= 8388607:
+		u := uint32(n)
+		lb.entries = append(lb.entries, 0xF2, byte(u), byte(u>>8), byte(u>>16))
+	case n >= -2147483648 && n <= 2147483647:
+		lb.entries = append(lb.entries, 0xF3)
+		lb.entries = binary.LittleEndian.AppendUint32(lb.entries, uint32(n))
+	default:
+		lb.entries = append(lb.entries, 0xF4)
+		lb.entries = binary.LittleEndian.AppendUint64(lb.entries, uint64(n))
+	}
+	lb.appendBacklen(len(lb.entries) - start)
+}
+
+// appendBacklen appends the length of the previous entry, readable from right to left
+func (lb *listpackBuilder) appendBacklen(length int) {
+	switch {
+	case length <= 127:
+		lb.entries = append(lb.entries, byte(length))
+	case length < 16383:
+		lb.entries = append(lb.entries, byte(length>>7), byte(length&127)|128)
+	case length < 2097151:
+		lb.entries = append(lb.entries, byte(length>>14), byte((length>>7)&127)|128, byte(length&127)|128)
+	case length < 268435455:
+		lb.entries = append(lb.entries, byte(length>>21), byte((length>>14)&127)|128, byte((length>>7)&127)|128, byte(length&127)|128)
+	default:
+		lb.entries = append(lb.entries, byte(length>>28), byte((length>>21)&127)|128, byte((length>>14)&127)|128, byte((length>>7)&127)|128, byte(length&127)|128)
+	}
+	lb.count++
+}
+
+// size returns the number of bytes the listpack currently takes
+func (lb *listpackBuilder) size() int {
+	return 6 + len(lb.entries) + 1
+}
+
+// bytes returns the complete listpack: header, entries and terminator
+func (lb *listpackBuilder) bytes() []byte {
+	buf := make([]byte, 0, lb.size())
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(lb.size()))
+	count := lb.count
+	if count > 65535 {
+		// The element count saturates; readers then have to walk the listpack
+		count = 65535
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(count))
+	buf = append(buf, lb.entries...)
+	return append(buf, listpackEnd)
+}
+
+// decodeListpack returns every element of a listpack as a string
+func decodeListpack(data []byte) ([]string, error) {
+	if len(data) < 7 {
+		return nil, fmt.Errorf("listpack too short")
+	}
+	if total := binary.LittleEndian.Uint32(data[0:4]); int(total) != len(data) {
+		return nil, fmt.Errorf("listpack size mismatch: header %d, actual %d", total, len(data))
+	}
+
+	var values []string
+	pos := 6
+	for {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("listpack is missing its terminator")
+		}
+		if data[pos] == listpackEnd {
+			return values, nil
+		}
+
+		value, entryLen, err := decodeListpackEntry(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		pos += entryLen + backlenSize(entryLen)
+	}
+}
+
+// decodeListpackEntry decodes the entry at the start of data and returns its value
+// and the size of its encoding and content, without the backlen
+func decodeListpackEntry(data []byte) (string, int, error) {
+	b := data[0]
+	need := func(n int) error {
+		if len(data) < n {
+			return fmt.Errorf("listpack entry truncated")
+		}
+		return nil
+	}
+
+	switch {
+	case b&0x80 == 0: // 7 bit unsigned integer
+		return strconv.Itoa(int(b)), 1, nil
+	case b&0xC0 == 0x80: // 6 bit string length
+		length := int(b & 0x3F)
+		if err := need(1 + length); err != nil {
+			return "", 0, err
+		}
+		return string(data[1 : 1+length]), 1 + length, nil
+	case b&0xE0 == 0xC0: // 13 bit signed integer
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		u := uint16(b&0x1F)<<8 | uint16(data[1])
+		n := int64(u)
+		if u >= 1<<12 {
+			n -= 1 << 13
+		}
+		return strconv.FormatInt(n, 10), 2, nil
+	case b&0xF0 == 0xE0: // 12 bit string length
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		length := int(b&0x0F)<<8 | int(data[1])
+		if err := need(2 + length); err != nil {
+			return "", 0, err
+		}
+		return string(data[2 : 2+length]), 2 + length, nil
+	}
+
+	switch b {
+	case 0xF0: // 32 bit string length
+		if err := need(5); err != nil {
+			return "", 0, err
+		}
+		length := int(binary.LittleEndian.Uint32(data[1:5]))
+		if err := need(5 + length); err != nil {
+			return "", 0, err
+		}
+		return string(data[5 : 5+length]), 5 + length, nil
+	case 0xF1: // 16 bit integer
+		if err := need(3); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(data[1:3]))), 10), 3, nil
+	case 0xF2: // 24 bit integer
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		n := int32(uint32(data[1])<<8|uint32(data[2])<<16|uint32(data[3])<<24) >> 8
+		return strconv.FormatInt(int64(n), 10), 4, nil
+	case 0xF3: // 32 bit integer
+		if err := need(5); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(data[1:5]))), 10), 5, nil
+	case 0xF4: // 64 bit integer
+		if err := need(9); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(data[1:9])), 10), 9, nil
+	}
+
+	return "", 0, fmt.Errorf("unknown listpack encoding: 0x%02X", b)
+}
+
+// backlenSize returns how many bytes the backlen of an entry of the given size takes
+func backlenSize(length int) int {
+	switch {
+	case length <= 127:
+		return 1
+	case length < 16383:
+		return 2
+	case length < 2097151:
+		return 3
+	case length < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestListpackRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+	}{
+		{name: "Empty listpack", values: nil},
+		{name: "7 bit integers", values: []string{"0", "1", "127"}},
+		{name: "13 bit integers", values: []string{"128", "4095", "-1", "-4096"}},
+		{name: "16 bit integers", values: []string{"4096", "32767", "-32768"}},
+		{name: "24 bit integers", values: []string{"32768", "8388607", "-8388608"}},
+		{name: "32 bit integers", values: []string{"8388608", "2147483647", "-2147483648"}},
+		{name: "64 bit integers", values: []string{"2147483648", "9223372036854775807", "-9223372036854775808"}},
+		{name: "Non canonical integers stay strings", values: []string{"007", "-0", "+1", "1.5", ""}},
+		{name: "Short strings", values: []string{"a", "hello", strings.Repeat("x", 63)}},
+		{name: "Medium strings", values: []string{strings.Repeat("y", 64), strings.Repeat("y", 4095)}},
+		{name: "Long strings", values: []string{strings.Repeat("z", 4096), strings.Repeat("z", 70000)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := &listpackBuilder{}
+			for _, value := range tt.values {
+				lp.appendString(value)
+			}
+
+			decoded, err := decodeListpack(lp.bytes())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(decoded) != len(tt.values) {
+				t.Fatalf("Expected %d elements, got %d", len(tt.values), len(decoded))
+			}
+			for i := range tt.values {
+				if decoded[i] != tt.values[i] {
+					t.Errorf("Element %d: expected %q, got %q", i, tt.values[i], decoded[i])
+				}
+			}
+		})
+	}
+}
+
+func TestListpackEncoding(t *testing.T) {
+	// Header (15 bytes, 3 elements), "a" as a 6 bit string, 1 as a 7 bit integer,
+	// -1 as a 13 bit integer, each followed by its backlen, then the terminator
+	lp := &listpackBuilder{}
+	lp.appendString("a")
+	lp.appendString("1")
+	lp.appendString("-1")
+
+	expected := "0f00000003008161020101dfff02ff"
+	if got := hex.EncodeToString(lp.bytes()); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestDecodeListpackErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Too short", data: []byte{0x07, 0x00}},
+		{name: "Size mismatch", data: []byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF}},
+		{name: "Missing terminator", data: []byte{0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x01}},
+		{name: "Truncated string", data: []byte{0x09, 0x00, 0x00, 0x00, 0x01, 0x00, 0x85, 0x61, 0xFF}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeListpack(tt.data); err == nil {
+				t.Errorf("Expected error decoding %x", tt.data)
+			}
+		})
+	}
+}
+
+func BenchmarkListpackBuild(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		lp := &listpackBuilder{}
+		for j := 0; j < 100; j++ {
+			lp.appendString("value")
+			lp.appendInt(int64(j))
+		}
+		_ = bytes.Clone(lp.bytes())
+	}
+}
@@ -0,0 +1,164 @@
+package storage
+
+import "fmt"
+
+// LZF is the compression Redis applies to strings in RDB files. A compressed
+// stream is a sequence of literal runs and back references:
+//
+//	000LLLLL <L+1 bytes>              literal run of 1 to 32 bytes
+//	LLLooooo oooooooo                 back reference of L+2 bytes, L from 1 to 6
+//	111ooooo LLLLLLLL oooooooo        back reference of L+9 bytes
+//
+// where the offset o counts back from the current output position, minus one.
+const (
+	lzfMaxLiteral = 32
+	lzfMaxOffset  = 1 << 13
+	lzfMaxRef     = 7 + 255 + 2
+	lzfHashBits   = 14
+)
+
+// lzfMinCompressLen is the shortest string worth compressing, like Redis
+const lzfMinCompressLen = 20
+
+// lzfCompress compresses in and returns nil if the result isn't smaller than the input
+func lzfCompress(in []byte) []byte {
+	if len(in) < 4 {
+		return nil
+	}
+
+	var table [1 << lzfHashBits]int // Last position + 1 of each 3 byte sequence
+	hash := func(i int) int {
+		v := uint32(in[i])<<16 | uint32(in[i+1])<<8 | uint32(in[i+2])
+		return int((v * 2654435761) >> (32 - lzfHashBits))
+	}
+
+	out := make([]byte, 0, len(in))
+	litPos := len(out)
+	out = append(out, 0) // Control byte of the current literal run
+	lit := 0
+
+	i := 0
+	for i+2 < len(in) {
+		h := hash(i)
+		ref := table[h] - 1
+		table[h] = i + 1
+
+		if ref >= 0 && i-ref-1 < lzfMaxOffset && in[ref] == in[i] && in[ref+1] == in[i+1] && in[ref+2] == in[i+2] {
+			maxLen := min(len(in)-i, lzfMaxRef)
+			length := 3
+			for length < maxLen && in[ref+length] == in[i+length] {
+				length++
+			}
+
+			// Close the pending literal run, or drop its unused control byte
+			if lit > 0 {
+				out[litPos] = byte(lit - 1)
+			} else {
+				out = out[:len(out)-1]
+			}
+
+			off := i - ref - 1
+			if n := length - 2; n < 7 {
+				out = append(out, byte(n<<5)|byte(off>>8), byte(off))
+			} else {
+				out = append(out, 7<<5|byte(off>>8), byte(n-7), byte(off))
+			}
+
+			for j := i + 1; j < i+length && j+2 < len(in); j++ {
+				table[hash(j)] = j + 1
+			}
+			i += length
+
+			litPos = len(out)
+			out = append(out, 0)
+			lit = 0
+		} else {
+			out, lit, litPos = lzfAppendLiteral(out, in[i], lit, litPos)
+			i++
+		}
+
+		if len(out) >= len(in) {
+			return nil
+		}
+	}
+	for ; i < len(in); i++ {
+		out, lit, litPos = lzfAppendLiteral(out, in[i], lit, litPos)
+	}
+
+	if lit > 0 {
+		out[litPos] = byte(lit - 1)
+	} else {
+		out = out[:len(out)-1]
+	}
+	if len(out) >= len(in) {
+		return nil
+	}
+	return out
+}
+
+// lzfAppendLiteral adds a byte to the current literal run, starting a new run when it is full
+func lzfAppendLiteral(out []byte, b byte, lit, litPos int) ([]byte, int, int) {
+	out = append(out, b)
+	lit++
+	if lit == lzfMaxLiteral {
+		out[litPos] = lzfMaxLiteral - 1
+		litPos = len(out)
+		out = append(out, 0)
+		lit = 0
+	}
+	return out, lit, litPos
+}
+
+// lzfDecompress decompresses in, which must expand to exactly outLen bytes
+func lzfDecompress(in []byte, outLen int) ([]byte, error) {
+	out := make([]byte, 0, outLen)
+	i := 0
+	for i < len(in) {
+		ctrl := int(in[i])
+		i++
+
+		if ctrl < lzfMaxLiteral {
+			length := ctrl + 1
+			if i+length > len(in) {
+				return nil, fmt.Errorf("lzf literal run truncated")
+			}
+			if len(out)+length > outLen {
+				return nil, fmt.Errorf("lzf output exceeds expected length %d", outLen)
+			}
+			out = append(out, in[i:i+length]...)
+			i += length
+			continue
+		}
+
+		length := ctrl >> 5
+		if length == 7 {
+			if i >= len(in) {
+				return nil, fmt.Errorf("lzf back reference truncated")
+			}
+			length += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, fmt.Errorf("lzf back reference truncated")
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(in[i]) - 1
+		i++
+		length += 2
+
+		if ref < 0 {
+			return nil, fmt.Errorf("lzf back reference before start of output")
+		}
+		if len(out)+length > outLen {
+			return nil, fmt.Errorf("lzf output exceeds expected length %d", outLen)
+		}
+		// Copy byte by byte: the reference may overlap the bytes being written
+		for j := 0; j < length; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != outLen {
+		return nil, fmt.Errorf("lzf output is %d bytes, expected %d", len(out), outLen)
+	}
+	return out, nil
+}
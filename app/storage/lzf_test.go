@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLZFRoundTrip(t *testing.T) {
+	random := make([]byte, 9000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name       string
+		input      []byte
+		compresses bool
+	}{
+		{name: "Repeated byte", input: bytes.Repeat([]byte("a"), 1000), compresses: true},
+		{name: "Repeated phrase", input: []byte(strings.Repeat("hello world, ", 50)), compresses: true},
+		{name: "Long match", input: []byte(strings.Repeat("0123456789", 100) + "x" + strings.Repeat("0123456789", 100)), compresses: true},
+		{name: "Short input", input: []byte("abc"), compresses: false},
+		{name: "Incompressible", input: random, compresses: false},
+		{name: "Repeat beyond offset window", input: append(append([]byte{}, random...), random[:1000]...), compresses: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := lzfCompress(tt.input)
+			if (compressed != nil) != tt.compresses {
+				t.Fatalf("Expected compression %v, got %d bytes for %d", tt.compresses, len(compressed), len(tt.input))
+			}
+			if compressed == nil {
+				return
+			}
+			if len(compressed) >= len(tt.input) {
+				t.Errorf("Expected compressed size below %d, got %d", len(tt.input), len(compressed))
+			}
+
+			decompressed, err := lzfDecompress(compressed, len(tt.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(decompressed, tt.input) {
+				t.Errorf("Round trip mismatch")
+			}
+		})
+	}
+}
+
+func TestLZFDecompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		hexData  string
+		outLen   int
+		expected string
+		wantErr  bool
+	}{
+		{
+			// Literal "ab", then a back reference of 6 bytes at offset 2
+			name:     "Literal and overlapping reference",
+			hexData:  "01616280" + "01",
+			outLen:   8,
+			expected: "abababab",
+		},
+		{
+			// Literal "a", then a long back reference of 7+3+2 bytes at offset 1
+			name:     "Long reference",
+			hexData:  "0061" + "e00300",
+			outLen:   13,
+			expected: strings.Repeat("a", 13),
+		},
+		{
+			name:    "Truncated literal",
+			hexData: "0561",
+			outLen:  6,
+			wantErr: true,
+		},
+		{
+			name:    "Reference before start",
+			hexData: "0061" + "2005",
+			outLen:  4,
+			wantErr: true,
+		},
+		{
+			name:    "Output longer than expected",
+			hexData: "026162630000",
+			outLen:  3,
+			wantErr: true,
+		},
+		{
+			name:    "Output shorter than expected",
+			hexData: "00610000",
+			outLen:  5,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hexData)
+			if err != nil {
+				t.Fatalf("Failed to decode hex data: %v", err)
+			}
+
+			result, err := lzfDecompress(data, tt.outLen)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func BenchmarkLZFCompress(b *testing.B) {
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog ", 100))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lzfCompress(data)
+	}
+}
+
+func BenchmarkLZFDecompress(b *testing.B) {
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog ", 100))
+	compressed := lzfCompress(data)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lzfDecompress(compressed, len(data))
+	}
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Encoding thresholds of the in-memory encodings reported by ObjectEncoding, matching the Redis defaults
+const (
+	embstrMaxLen            = 44  // Longest string stored with the embstr encoding
+	setMaxIntsetEntries     = 512 // set-max-intset-entries
+	setMaxListpackEntries   = 128 // set-max-listpack-entries
+	setMaxListpackValue     = 64  // set-max-listpack-value
+	hashMaxListpackEntries  = 128 // hash-max-listpack-entries
+	hashMaxListpackValue    = 64  // hash-max-listpack-value
+)
+
+// ObjectEncoding returns the name of the encoding Redis would use to store the value of entry in
+// memory, like embstr or listpack, as reported by OBJECT ENCODING and DEBUG OBJECT
+func ObjectEncoding(entry shared.MemoryEntry) string {
+	switch {
+	case entry.Stream != nil:
+		return "stream"
+	case entry.SortedSet != nil && entry.SortedSet.Packed():
+		return "listpack"
+	case entry.SortedSet != nil:
+		return "skiplist"
+	case entry.List != nil:
+		return listEncoding(entry.List.ToArray())
+	case entry.Array != nil:
+		return listEncoding(entry.Array)
+	case entry.Set != nil:
+		return setEncoding(entry.Set)
+	case entry.Hash != nil:
+		for field, value := range entry.Hash {
+			if len(field) > hashMaxListpackValue || len(value) > hashMaxListpackValue {
+				return "hashtable"
+			}
+		}
+		if len(entry.Hash) > hashMaxListpackEntries {
+			return "hashtable"
+		}
+		return "listpack"
+	case entry.Raw:
+		return "raw"
+	default:
+		return stringEncoding(entry.Value)
+	}
+}
+
+// stringEncoding returns int for the canonical form of a 64 bit integer, embstr for short
+// strings and raw for the others
+func stringEncoding(s string) string {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(n, 10) == s {
+		return "int"
+	}
+	if len(s) <= embstrMaxLen {
+		return "embstr"
+	}
+	return "raw"
+}
+
+// listEncoding returns listpack for a list fitting in a single quicklist node, quicklist otherwise
+func listEncoding(values []string) string {
+	if !shared.ListpackFits(values) {
+		return "quicklist"
+	}
+	return "listpack"
+}
+
+// setEncoding returns intset for small sets of integers, listpack for small sets of short
+// members and hashtable otherwise
+func setEncoding(set map[string]struct{}) string {
+	allInts := true
+	shortMembers := true
+	for member := range set {
+		if n, err := strconv.ParseInt(member, 10, 64); err != nil || strconv.FormatInt(n, 10) != member {
+			allInts = false
+		}
+		if len(member) > setMaxListpackValue {
+			shortMembers = false
+		}
+	}
+	switch {
+	case allInts && len(set) <= setMaxIntsetEntries:
+		return "intset"
+	case shortMembers && len(set) <= setMaxListpackEntries:
+		return "listpack"
+	default:
+		return "hashtable"
+	}
+}
+
+// SerializedLength returns the number of bytes the value of entry takes in an RDB file
+func SerializedLength(entry shared.MemoryEntry) int {
+	counter := &countingWriter{}
+	rw := NewRDBWriter(counter)
+	rw.writeValue(entry)
+	rw.flush()
+	return counter.n
+}
+
+// countingWriter discards what is written to it, counting the bytes
+type countingWriter struct {
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestObjectEncoding(t *testing.T) {
+	bigList := make([]string, 1000)
+	for i := range bigList {
+		bigList[i] = strings.Repeat("x", 20)
+	}
+	bigZset := shared.NewSortedSet()
+	for i := 0; i < 200; i++ {
+		bigZset.Add("member"+strconv.Itoa(i), float64(i))
+	}
+
+	tests := []struct {
+		name     string
+		entry    shared.MemoryEntry
+		expected string
+	}{
+		{name: "Integer", entry: shared.MemoryEntry{Value: "-42"}, expected: "int"},
+		{name: "Non canonical integer", entry: shared.MemoryEntry{Value: "042"}, expected: "embstr"},
+		{name: "Short string", entry: shared.MemoryEntry{Value: "hello"}, expected: "embstr"},
+		{name: "Long string", entry: shared.MemoryEntry{Value: strings.Repeat("x", 45)}, expected: "raw"},
+		{name: "Small list", entry: shared.MemoryEntry{List: shared.FromArray([]string{"a", "b"})}, expected: "listpack"},
+		{name: "Big list", entry: shared.MemoryEntry{List: shared.FromArray(bigList)}, expected: "quicklist"},
+		{name: "Big sorted set", entry: shared.MemoryEntry{SortedSet: bigZset}, expected: "skiplist"},
+		{name: "Integer set", entry: shared.MemoryEntry{Set: map[string]struct{}{"1": {}, "2": {}}}, expected: "intset"},
+		{name: "Small set", entry: shared.MemoryEntry{Set: map[string]struct{}{"a": {}}}, expected: "listpack"},
+		{name: "Small hash", entry: shared.MemoryEntry{Hash: map[string]string{"f": "v"}}, expected: "listpack"},
+		{name: "Hash with a long value", entry: shared.MemoryEntry{Hash: map[string]string{"f": strings.Repeat("v", 65)}}, expected: "hashtable"},
+		{name: "Stream", entry: shared.MemoryEntry{Stream: []shared.StreamEntry{{ID: "1-1", Data: map[string]string{"f": "v"}}}}, expected: "stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if encoding := ObjectEncoding(tt.entry); encoding != tt.expected {
+				t.Errorf("Expected encoding %q, got %q", tt.expected, encoding)
+			}
+		})
+	}
+}
+
+func TestSerializedLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    shared.MemoryEntry
+		expected int
+	}{
+		{name: "8 bit integer", entry: shared.MemoryEntry{Value: "12"}, expected: 2},
+		{name: "Short string", entry: shared.MemoryEntry{Value: "hello"}, expected: 6},
+		{name: "Set", entry: shared.MemoryEntry{Set: map[string]struct{}{"a": {}, "b": {}}}, expected: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if length := SerializedLength(tt.entry); length != tt.expected {
+				t.Errorf("Expected serialized length %d, got %d", tt.expected, length)
+			}
+		})
+	}
+}
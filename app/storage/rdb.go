@@ -4,8 +4,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/codecrafters-io/redis-starter-go/app/server"
 	"github.com/codecrafters-io/redis-starter-go/app/shared"
@@ -13,8 +16,10 @@ import (
 
 // RDBParser handles parsing RDB files
 type RDBParser struct {
-	data []byte
-	pos  int
+	data      []byte
+	pos       int
+	version   int      // RDB format version from the header
+	functions []string // Code of the function libraries saved before the dataset
 }
 
 // NewRDBParser creates a new RDB parser
@@ -42,6 +47,46 @@ func LoadRDBFile(dir, filename string) error {
 	return ParseRDBData(data)
 }
 
+// loading is set while a replica replaces its dataset with the snapshot of its master
+var loading atomic.Bool
+
+// Loading reports whether the dataset is being replaced by the snapshot of the master
+func Loading() bool {
+	return loading.Load()
+}
+
+// LoadMasterSnapshot replaces the dataset with the RDB file a master sent with FULLRESYNC,
+// discarding the keys the replica loaded at startup or received before. Clients are refused
+// with LOADING meanwhile, so none of them sees a half loaded dataset, and the append only
+// file, when enabled, is rewritten from the new dataset.
+func LoadMasterSnapshot(data []byte) error {
+	loading.Store(true)
+	defer loading.Store(false)
+
+	if err := ParseRDBData(data); err != nil {
+		return err
+	}
+	if server.StoreState.AppendOnly {
+		return BackgroundRewriteAppendOnlyFile()
+	}
+	return nil
+}
+
+// parseRDBPreamble loads the RDB payload at the start of data and returns its size,
+// so the data following it (like the commands of an AOF) can be read next
+func parseRDBPreamble(data []byte) (int, error) {
+	server.Memory.Clear()
+
+	parser := NewRDBParser(data)
+	if err := parser.parse(); err != nil {
+		return 0, err
+	}
+	if err := loadFunctionLibraries(parser.functions); err != nil {
+		return 0, err
+	}
+	return parser.pos, nil
+}
+
 // ParseRDBData parses RDB data and loads it into memory
 func ParseRDBData(data []byte) error {
 	if len(data) == 0 {
@@ -49,10 +94,14 @@ func ParseRDBData(data []byte) error {
 	}
 
 	// Clear existing memory before loading RDB data
-	server.Memory = make(map[string]shared.MemoryEntry)
+	server.Memory.Clear()
 
 	parser := NewRDBParser(data)
-	return parser.parse()
+	if err := parser.parse(); err != nil {
+		return err
+	}
+	// The libraries of the file replace the loaded ones, like the keys do
+	return loadFunctionLibraries(parser.functions)
 }
 
 // parse parses the RDB data
@@ -87,6 +136,14 @@ func (p *RDBParser) parse() error {
 			if err := p.parseSelectDB(); err != nil {
 				return fmt.Errorf("failed to parse SELECTDB: %v", err)
 			}
+			// RESIZEDB is optional (files before version 7 don't have it), so key-value
+			// pairs may follow directly; parseKeyValuePairs handles both
+			if err := p.parseKeyValuePairs(); err != nil {
+				return fmt.Errorf("failed to parse key-value pairs: %v", err)
+			}
+			if p.pos >= len(p.data) || p.data[p.pos] != 0xFF {
+				return nil // Truncated file, keep what was loaded
+			}
 		case 0xFB: // RESIZEDB
 			if err := p.parseResizeDB(); err != nil {
 				return fmt.Errorf("failed to parse RESIZEDB: %v", err)
@@ -95,10 +152,17 @@ func (p *RDBParser) parse() error {
 			if err := p.parseKeyValuePairs(); err != nil {
 				return fmt.Errorf("failed to parse key-value pairs: %v", err)
 			}
-			// We're done parsing this database
-			return nil
+			if p.pos >= len(p.data) || p.data[p.pos] != 0xFF {
+				return nil // Truncated file, keep what was loaded
+			}
 		case 0xFF: // EOF
-			// End of file, we're done
+			// End of file, only the checksum follows
+			if err := p.verifyChecksum(); err != nil {
+				return err
+			}
+			if p.version >= 5 {
+				p.pos += 8
+			}
 			return nil
 		default:
 			return fmt.Errorf("unexpected opcode: 0x%02X", opcode)
@@ -108,17 +172,43 @@ func (p *RDBParser) parse() error {
 	return nil
 }
 
-// checkHeader checks if the RDB header is valid
+// verifyChecksum checks the CRC64 following the EOF opcode against the data before it.
+// Files before version 5 have no checksum, and a zero checksum means the writer
+// had rdbchecksum disabled.
+func (p *RDBParser) verifyChecksum() error {
+	if p.version < 5 || !server.StoreState.RDBChecksum {
+		return nil
+	}
+	if p.pos+8 > len(p.data) {
+		return fmt.Errorf("RDB file is missing its checksum")
+	}
+
+	expected := binary.LittleEndian.Uint64(p.data[p.pos : p.pos+8])
+	if expected == 0 {
+		return nil
+	}
+	if actual := crc64Update(0, p.data[:p.pos]); actual != expected {
+		return fmt.Errorf("wrong RDB checksum expected: (%016x) got: (%016x)", expected, actual)
+	}
+	return nil
+}
+
+// checkHeader checks if the RDB header is valid: "REDIS" followed by a 4 digit version.
+// Files written by Redis 2.x up to 7.4 (versions 1 to 12) are accepted.
 func (p *RDBParser) checkHeader() bool {
-	if len(p.data) < 9 {
+	if len(p.data) < 9 || string(p.data[0:5]) != "REDIS" {
 		return false
 	}
 
-	header := string(p.data[0:9])
-	return header == "REDIS0011"
+	version, err := strconv.Atoi(string(p.data[5:9]))
+	if err != nil || version < 1 || version > 12 {
+		return false
+	}
+	p.version = version
+	return true
 }
 
-// skipMetadata skips the metadata section
+// skipMetadata skips the metadata section, keeping the function libraries it holds
 func (p *RDBParser) skipMetadata() error {
 	p.pos = 9 // Skip "REDIS0011"
 
@@ -137,11 +227,21 @@ func (p *RDBParser) skipMetadata() error {
 			if err := p.skipAuxiliaryField(); err != nil {
 				return err
 			}
-		case 0xFE: // SELECTDB - start of database data
+		case 0xFE, 0xFF: // SELECTDB or EOF of an empty dataset - start of database data
 			p.pos-- // Back up one byte
 			return nil
-		case 0x40: // Skip this byte (appears after redis-bits)
-			continue
+		case rdbOpcodeFunction2: // The code of a function library
+			code, err := p.readLengthEncodedString()
+			if err != nil {
+				return err
+			}
+			p.functions = append(p.functions, code)
+		case 0xF4: // SLOT_INFO, cluster slot sizes
+			for i := 0; i < 3; i++ {
+				if _, err := p.readLength(); err != nil {
+					return err
+				}
+			}
 		default:
 			return fmt.Errorf("unexpected opcode in metadata: 0x%02X", opcode)
 		}
@@ -203,6 +303,20 @@ func (p *RDBParser) parseKeyValuePairs() error {
 			return err
 		}
 
+		// Every database is loaded into the same keyspace
+		switch valueType {
+		case 0xFE: // SELECTDB
+			if err := p.parseSelectDB(); err != nil {
+				return err
+			}
+			continue
+		case 0xFB: // RESIZEDB
+			if err := p.parseResizeDB(); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Parse a key-value pair with the correct type
 		if err := p.parseKeyValue(valueType); err != nil {
 			if err == io.EOF {
@@ -224,81 +338,535 @@ func (p *RDBParser) parseKeyValuePairs() error {
 	}
 }
 
-// parseKeyValue parses a key-value pair
+// parseKeyValue parses a key-value pair, with the expiry and eviction hints that may precede it
 func (p *RDBParser) parseKeyValue(opcode byte) error {
 	var expires int64 = 0
 	var valueType byte = opcode
 
-	// Handle expiration opcodes first
-	switch opcode {
-	case 0xFC: // Expiry time in seconds
-		// Skip the first 3 bytes (00 0c 28 or 00 9c ef)
-		if p.pos+3 > len(p.data) {
-			return io.EOF
-		}
-		p.pos += 3
-		// Read the expiration timestamp (4 bytes)
-		if p.pos+4 > len(p.data) {
-			return io.EOF
+	for {
+		var err error
+		switch valueType {
+		case 0xFC: // Expiry time in milliseconds (8 bytes, little-endian)
+			if p.pos+8 > len(p.data) {
+				return io.EOF
+			}
+			expires = int64(binary.LittleEndian.Uint64(p.data[p.pos : p.pos+8]))
+			p.pos += 8
+		case 0xFD: // Expiry time in seconds (4 bytes, little-endian)
+			if p.pos+4 > len(p.data) {
+				return io.EOF
+			}
+			expires = int64(binary.LittleEndian.Uint32(p.data[p.pos:p.pos+4])) * 1000
+			p.pos += 4
+		case 0xF8: // LRU idle time, not used here
+			_, err = p.readLength()
+		case 0xF9: // LFU frequency, not used here
+			_, err = p.readByte()
+		default:
+			return p.parseValue(valueType, expires)
 		}
-		expiresVal, err := p.readUint32BigEndian()
 		if err != nil {
 			return err
 		}
-		// Convert from seconds to milliseconds (absolute timestamp)
-		expires = expiresVal * 1000
-		// Skip the remaining 2 bytes (00 00)
-		if p.pos+2 > len(p.data) {
-			return io.EOF
-		}
-		p.pos += 2
-		// The value type is implicit (0x00 for string)
-		valueType = 0x00
 
-	case 0xFD: // Expiry time in milliseconds
-		var err error
-		expires, err = p.readUint32BigEndian()
-		if err != nil {
-			return err
-		}
 		valueType, err = p.readByte()
 		if err != nil {
 			return err
 		}
 	}
+}
 
+// parseValue reads a key and its value of the given type and stores them in memory
+func (p *RDBParser) parseValue(valueType byte, expires int64) error {
 	// Read key
 	key, err := p.readLengthEncodedString()
 	if err != nil {
 		return err
 	}
 
-	// Read value based on type
-	var value string
+	entry, err := p.readValue(valueType)
+	if err != nil {
+		return err
+	}
+	entry.Expires = expires
+
+	// Store in memory
+	server.Memory.Set(key, entry)
+
+	return nil
+}
+
+// readValue reads a value of the given type, as found in RDB files and DUMP payloads
+func (p *RDBParser) readValue(valueType byte) (shared.MemoryEntry, error) {
+	var err error
+	entry := shared.MemoryEntry{}
 	switch valueType {
 	case 0x00: // String
-		value, err = p.readLengthEncodedString()
+		entry.Value, err = p.readLengthEncodedString()
+	case 0x01: // List
+		var values []string
+		values, err = p.readStringList()
+		entry.SetList(values)
+	case 0x02: // Set
+		var members []string
+		members, err = p.readStringList()
+		entry.Set = newSet(members)
+	case 0x03: // Sorted set with string scores
+		entry.SortedSet, err = p.readSortedSetStringScores()
+	case 0x04: // Hash
+		var fields []string
+		fields, err = p.readStringPairs()
+		entry.Hash = newHash(fields)
+	case 0x05: // Sorted set with binary double scores
+		entry.SortedSet, err = p.readSortedSet()
+	case 0x09: // Hash as a zipmap
+		var fields []string
+		fields, err = p.readEncoded(decodeZipmap)
+		entry.Hash = newHash(fields)
+	case 0x0A: // List as a ziplist
+		var values []string
+		values, err = p.readEncoded(decodeZiplist)
+		entry.SetList(values)
+	case 0x0B: // Set as an intset
+		var members []string
+		members, err = p.readEncoded(decodeIntset)
+		entry.Set = newSet(members)
+	case 0x0C: // Sorted set as a ziplist
+		var elements []string
+		elements, err = p.readEncoded(decodeZiplist)
+		if err == nil {
+			entry.SortedSet, err = sortedSetFromPairs(elements)
+		}
+	case 0x0D: // Hash as a ziplist
+		var fields []string
+		fields, err = p.readEncoded(decodeZiplist)
+		entry.Hash = newHash(fields)
+	case 0x0E: // List as a quicklist of ziplists
+		var values []string
+		values, err = p.readZiplistQuicklist()
+		entry.SetList(values)
+	case 0x10: // Hash as a listpack
+		var fields []string
+		fields, err = p.readEncoded(decodeListpack)
+		entry.Hash = newHash(fields)
+	case 0x11: // Sorted set as a listpack
+		var elements []string
+		elements, err = p.readEncoded(decodeListpack)
+		if err == nil {
+			entry.SortedSet, err = sortedSetFromPairs(elements)
+		}
+	case 0x12: // List as a quicklist of listpacks
+		var values []string
+		values, err = p.readQuicklist()
+		entry.SetList(values)
+	case 0x14: // Set as a listpack
+		var members []string
+		members, err = p.readEncoded(decodeListpack)
+		entry.Set = newSet(members)
+	case 0x0F, 0x13, 0x15: // Stream as listpacks (versions 1 to 3)
+		entry.Stream, err = p.readStream(valueType)
+	default:
+		return entry, fmt.Errorf("unsupported value type: 0x%02X", valueType)
+	}
+	return entry, err
+}
+
+// newSet builds a set from its members
+func newSet(members []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		set[member] = struct{}{}
+	}
+	return set
+}
+
+// newHash builds a hash from alternating fields and values
+func newHash(fields []string) map[string]string {
+	hash := make(map[string]string, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		hash[fields[i]] = fields[i+1]
+	}
+	return hash
+}
+
+// readStringList reads a length followed by that many length-encoded strings
+func (p *RDBParser) readStringList() ([]string, error) {
+	length, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, length)
+	for i := 0; i < length; i++ {
+		value, err := p.readLengthEncodedString()
 		if err != nil {
-			return err
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// readStringPairs reads a number of pairs followed by that many pairs of strings
+func (p *RDBParser) readStringPairs() ([]string, error) {
+	length, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, 2*length)
+	for i := 0; i < 2*length; i++ {
+		value, err := p.readLengthEncodedString()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// readEncoded reads a compact encoding (listpack, ziplist, intset, zipmap) stored as a string
+// and returns its elements
+func (p *RDBParser) readEncoded(decode func([]byte) ([]string, error)) ([]string, error) {
+	data, err := p.readLengthEncodedString()
+	if err != nil {
+		return nil, err
+	}
+	return decode([]byte(data))
+}
+
+// readQuicklist reads a list stored as quicklist nodes.
+// Each node is either a listpack (container 2) or a single plain element (container 1).
+func (p *RDBParser) readQuicklist() ([]string, error) {
+	nodes, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for i := 0; i < nodes; i++ {
+		container, err := p.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if container == 1 {
+			value, err := p.readLengthEncodedString()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			continue
+		}
+
+		elements, err := p.readEncoded(decodeListpack)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, elements...)
+	}
+	return values, nil
+}
+
+// readZiplistQuicklist reads a list stored as quicklist nodes holding ziplists
+func (p *RDBParser) readZiplistQuicklist() ([]string, error) {
+	nodes, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for i := 0; i < nodes; i++ {
+		elements, err := p.readEncoded(decodeZiplist)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, elements...)
+	}
+	return values, nil
+}
+
+// sortedSetFromPairs builds a sorted set from alternating members and scores,
+// as stored in ziplists and listpacks
+func sortedSetFromPairs(elements []string) (*shared.SortedSet, error) {
+	if len(elements)%2 != 0 {
+		return nil, fmt.Errorf("sorted set has an odd number of elements")
+	}
+
+	ss := shared.NewSortedSet()
+	for i := 0; i < len(elements); i += 2 {
+		score, err := strconv.ParseFloat(elements[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sorted set score %q", elements[i+1])
+		}
+		ss.Add(elements[i], score)
+	}
+	return ss, nil
+}
+
+// readStream reads a stream stored as listpack nodes followed by its metadata.
+// Every node starts with a master entry holding the fields its entries usually share,
+// and entry IDs are stored as deltas from the node key.
+func (p *RDBParser) readStream(valueType byte) ([]shared.StreamEntry, error) {
+	nodes, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []shared.StreamEntry{}
+	for i := 0; i < nodes; i++ {
+		nodeKey, err := p.readLengthEncodedString()
+		if err != nil {
+			return nil, err
+		}
+		if len(nodeKey) != 16 {
+			return nil, fmt.Errorf("invalid stream node key length %d", len(nodeKey))
+		}
+		masterMs := binary.BigEndian.Uint64([]byte(nodeKey[0:8]))
+		masterSeq := binary.BigEndian.Uint64([]byte(nodeKey[8:16]))
+
+		elements, err := p.readEncoded(decodeListpack)
+		if err != nil {
+			return nil, err
 		}
-	case 0x01: // String (alternative encoding)
-		value, err = p.readLengthEncodedString()
+		nodeEntries, err := decodeStreamNode(elements, masterMs, masterSeq)
 		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, nodeEntries...)
+	}
+
+	// Length and last ID, then first ID, max deleted ID and entries added since version 2
+	metadata := 3
+	if valueType != 0x0F {
+		metadata += 5
+	}
+	for i := 0; i < metadata; i++ {
+		if _, err := p.readLength(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Consumer groups are not supported, so they are read and dropped
+	groups, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < groups; i++ {
+		if err := p.skipStreamConsumerGroup(valueType); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// skipStreamConsumerGroup skips a consumer group: its name and last delivered ID,
+// its pending entries list and its consumers with their own pending entries
+func (p *RDBParser) skipStreamConsumerGroup(valueType byte) error {
+	if err := p.skipLengthEncodedString(); err != nil {
+		return err
+	}
+
+	// Last delivered ID, plus the entries read counter since version 2
+	fields := 2
+	if valueType != 0x0F {
+		fields++
+	}
+	for i := 0; i < fields; i++ {
+		if _, err := p.readLength(); err != nil {
 			return err
 		}
-	default:
-		return fmt.Errorf("unsupported value type: 0x%02X", valueType)
 	}
 
-	// Store in memory
-	server.Memory[key] = shared.MemoryEntry{
-		Value:   value,
-		Expires: expires,
+	// Pending entries: raw 128 bit ID, delivery time and delivery count
+	pending, err := p.readLength()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < pending; i++ {
+		if err := p.skipBytes(16 + 8); err != nil {
+			return err
+		}
+		if _, err := p.readLength(); err != nil {
+			return err
+		}
 	}
 
+	consumers, err := p.readLength()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < consumers; i++ {
+		if err := p.skipLengthEncodedString(); err != nil {
+			return err
+		}
+		// Seen time, plus active time since version 3
+		times := 8
+		if valueType == 0x15 {
+			times += 8
+		}
+		if err := p.skipBytes(times); err != nil {
+			return err
+		}
+
+		// The consumer's pending entries only reference IDs from the group's list
+		consumerPending, err := p.readLength()
+		if err != nil {
+			return err
+		}
+		if err := p.skipBytes(16 * consumerPending); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// decodeStreamNode rebuilds the entries of a stream listpack node
+func decodeStreamNode(elements []string, masterMs, masterSeq uint64) ([]shared.StreamEntry, error) {
+	pos := 0
+	next := func() (int64, error) {
+		if pos >= len(elements) {
+			return 0, fmt.Errorf("stream node truncated")
+		}
+		n, err := strconv.ParseInt(elements[pos], 10, 64)
+		pos++
+		return n, err
+	}
+
+	// Master entry: count, deleted, number of master fields, the fields, terminator
+	if _, err := next(); err != nil {
+		return nil, err
+	}
+	if _, err := next(); err != nil {
+		return nil, err
+	}
+	numMasterFields, err := next()
+	if err != nil {
+		return nil, err
+	}
+	if pos+int(numMasterFields)+1 > len(elements) {
+		return nil, fmt.Errorf("stream node truncated")
+	}
+	masterFields := elements[pos : pos+int(numMasterFields)]
+	pos += int(numMasterFields) + 1
+
+	var entries []shared.StreamEntry
+	for pos < len(elements) {
+		flags, err := next()
+		if err != nil {
+			return nil, err
+		}
+		msDiff, err := next()
+		if err != nil {
+			return nil, err
+		}
+		seqDiff, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		data := make(map[string]string)
+		if flags&2 != 0 { // Same fields as the master entry, only values are stored
+			if pos+len(masterFields) > len(elements) {
+				return nil, fmt.Errorf("stream node truncated")
+			}
+			for _, field := range masterFields {
+				data[field] = elements[pos]
+				pos++
+			}
+		} else {
+			numFields, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if pos+2*int(numFields) > len(elements) {
+				return nil, fmt.Errorf("stream node truncated")
+			}
+			for j := 0; j < int(numFields); j++ {
+				data[elements[pos]] = elements[pos+1]
+				pos += 2
+			}
+		}
+
+		// Skip the lp-count used to walk the node backwards
+		if _, err := next(); err != nil {
+			return nil, err
+		}
+
+		if flags&1 != 0 { // Deleted entry
+			continue
+		}
+		id := strconv.FormatUint(masterMs+uint64(msDiff), 10) + "-" + strconv.FormatUint(masterSeq+uint64(seqDiff), 10)
+		entries = append(entries, shared.StreamEntry{ID: id, Data: data})
+	}
+	return entries, nil
+}
+
+// readSortedSetStringScores reads a sorted set whose scores are stored as strings.
+// Each score has a one byte length, with 253, 254 and 255 standing for NaN, +inf and -inf.
+func (p *RDBParser) readSortedSetStringScores() (*shared.SortedSet, error) {
+	length, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	ss := shared.NewSortedSet()
+	for i := 0; i < length; i++ {
+		member, err := p.readLengthEncodedString()
+		if err != nil {
+			return nil, err
+		}
+		scoreLen, err := p.readByte()
+		if err != nil {
+			return nil, err
+		}
+
+		var score float64
+		switch scoreLen {
+		case 253:
+			score = math.NaN()
+		case 254:
+			score = math.Inf(1)
+		case 255:
+			score = math.Inf(-1)
+		default:
+			if p.pos+int(scoreLen) > len(p.data) {
+				return nil, io.EOF
+			}
+			score, err = strconv.ParseFloat(string(p.data[p.pos:p.pos+int(scoreLen)]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sorted set score %q", p.data[p.pos:p.pos+int(scoreLen)])
+			}
+			p.pos += int(scoreLen)
+		}
+		ss.Add(member, score)
+	}
+	return ss, nil
+}
+
+// readSortedSet reads a sorted set stored as members followed by 8 byte little-endian double scores
+func (p *RDBParser) readSortedSet() (*shared.SortedSet, error) {
+	length, err := p.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	ss := shared.NewSortedSet()
+	for i := 0; i < length; i++ {
+		member, err := p.readLengthEncodedString()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos+8 > len(p.data) {
+			return nil, io.EOF
+		}
+		score := math.Float64frombits(binary.LittleEndian.Uint64(p.data[p.pos : p.pos+8]))
+		p.pos += 8
+		ss.Add(member, score)
+	}
+	return ss, nil
+}
+
 // Helper methods
 
 func (p *RDBParser) readByte() (byte, error) {
@@ -331,21 +899,36 @@ func (p *RDBParser) readLength() (int, error) {
 			return 0, err
 		}
 		return int((uint16(firstByte&0x3F) << 8) | uint16(secondByte)), nil
-	case 2: // 32 bit length
+	case 2: // 32 bit (0x80) or 64 bit (0x81) length
+		if firstByte == 0x81 {
+			if p.pos+8 > len(p.data) {
+				return 0, io.EOF
+			}
+			length := binary.BigEndian.Uint64(p.data[p.pos : p.pos+8])
+			p.pos += 8
+			return int(length), nil
+		}
 		if p.pos+4 > len(p.data) {
 			return 0, io.EOF
 		}
 		length := binary.BigEndian.Uint32(p.data[p.pos : p.pos+4])
 		p.pos += 4
 		return int(length), nil
-	default: // Special encoding (11xxxxxx)
-		// For now, just return the lower 6 bits
-		// This handles cases like 0xC0 (192) which should be treated as length 0
-		return int(firstByte & 0x3F), nil
+	default: // Special encoding (11xxxxxx), only valid where a string is expected
+		return 0, fmt.Errorf("unexpected string encoding 0x%02X where a length was expected", firstByte)
 	}
 }
 
 func (p *RDBParser) readLengthEncodedString() (string, error) {
+	if p.pos < len(p.data) && p.data[p.pos]>>6 == 3 {
+		encoding := p.data[p.pos]
+		p.pos++
+		if encoding == rdbEncodingLZF {
+			return p.readCompressedString()
+		}
+		return p.readIntegerString(encoding)
+	}
+
 	length, err := p.readLength()
 	if err != nil {
 		return "", err
@@ -360,17 +943,85 @@ func (p *RDBParser) readLengthEncodedString() (string, error) {
 	return str, nil
 }
 
-func (p *RDBParser) readUint32BigEndian() (int64, error) {
-	if p.pos+4 > len(p.data) {
-		return 0, io.EOF
+func (p *RDBParser) skipBytes(n int) error {
+	if n < 0 || p.pos+n > len(p.data) {
+		return io.EOF
+	}
+	p.pos += n
+	return nil
+}
+
+// readCompressedString reads an LZF compressed string: compressed length,
+// original length and the compressed data
+func (p *RDBParser) readCompressedString() (string, error) {
+	compressedLen, err := p.readLength()
+	if err != nil {
+		return "", err
+	}
+	length, err := p.readLength()
+	if err != nil {
+		return "", err
+	}
+	if p.pos+compressedLen > len(p.data) {
+		return "", io.EOF
+	}
+
+	data, err := lzfDecompress(p.data[p.pos:p.pos+compressedLen], length)
+	if err != nil {
+		return "", err
 	}
+	p.pos += compressedLen
+	return string(data), nil
+}
 
-	value := binary.BigEndian.Uint32(p.data[p.pos : p.pos+4])
-	p.pos += 4
-	return int64(value), nil
+// readIntegerString reads a string stored as a little endian 8, 16 or 32 bit integer
+// and returns its decimal representation
+func (p *RDBParser) readIntegerString(encoding byte) (string, error) {
+	var size int
+	switch encoding {
+	case rdbEncodingInt8:
+		size = 1
+	case rdbEncodingInt16:
+		size = 2
+	case rdbEncodingInt32:
+		size = 4
+	default:
+		return "", fmt.Errorf("unknown RDB string encoding 0x%02X", encoding)
+	}
+	if p.pos+size > len(p.data) {
+		return "", io.EOF
+	}
+
+	buf := p.data[p.pos : p.pos+size]
+	p.pos += size
+	switch size {
+	case 1:
+		return strconv.Itoa(int(int8(buf[0]))), nil
+	case 2:
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(buf)))), nil
+	default:
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(buf)))), nil
+	}
 }
 
 func (p *RDBParser) skipLengthEncodedString() error {
+	if p.pos < len(p.data) && p.data[p.pos]>>6 == 3 {
+		encoding := p.data[p.pos]
+		p.pos++
+		if encoding == rdbEncodingLZF {
+			compressedLen, err := p.readLength()
+			if err != nil {
+				return err
+			}
+			if _, err := p.readLength(); err != nil {
+				return err
+			}
+			return p.skipBytes(compressedLen)
+		}
+		_, err := p.readIntegerString(encoding)
+		return err
+	}
+
 	length, err := p.readLength()
 	if err != nil {
 		return err
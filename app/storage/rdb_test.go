@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/codecrafters-io/redis-starter-go/app/server"
@@ -107,7 +111,7 @@ func TestRDBParser(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear memory before each test
-			server.Memory = make(map[string]shared.MemoryEntry)
+			server.Memory.Clear()
 
 			var data []byte
 			var err error
@@ -135,7 +139,7 @@ func TestRDBParser(t *testing.T) {
 
 			// Check that all expected keys are present
 			for expectedKey, expectedValue := range tt.expected {
-				entry, exists := server.Memory[expectedKey]
+				entry, exists := server.Memory.Get(expectedKey)
 				if !exists {
 					t.Errorf("Expected key '%s' not found in memory", expectedKey)
 					continue
@@ -146,11 +150,12 @@ func TestRDBParser(t *testing.T) {
 			}
 
 			// Check that no unexpected keys are present
-			if len(server.Memory) != len(tt.expected) {
-				t.Errorf("Expected %d keys in memory, got %d", len(tt.expected), len(server.Memory))
-				for key, entry := range server.Memory {
+			if server.Memory.Len() != len(tt.expected) {
+				t.Errorf("Expected %d keys in memory, got %d", len(tt.expected), server.Memory.Len())
+				server.Memory.Range(func(key string, entry shared.MemoryEntry) bool {
 					t.Errorf("Unexpected key: %s = %s", key, entry.Value)
-				}
+					return true
+				})
 			}
 		})
 	}
@@ -196,7 +201,7 @@ func TestLoadRDBFile(t *testing.T) {
 			tempFile := filepath.Join(tempDir, "test.rdb")
 
 			// Clear memory before each test
-			server.Memory = make(map[string]shared.MemoryEntry)
+			server.Memory.Clear()
 
 			// Setup file if needed
 			if tt.setupFile {
@@ -233,7 +238,7 @@ func TestLoadRDBFile(t *testing.T) {
 
 			// Check that all expected keys are present
 			for expectedKey, expectedValue := range tt.expected {
-				entry, exists := server.Memory[expectedKey]
+				entry, exists := server.Memory.Get(expectedKey)
 				if !exists {
 					t.Errorf("Expected key '%s' not found in memory", expectedKey)
 					continue
@@ -244,11 +249,12 @@ func TestLoadRDBFile(t *testing.T) {
 			}
 
 			// Check that no unexpected keys are present
-			if len(server.Memory) != len(tt.expected) {
-				t.Errorf("Expected %d keys in memory, got %d", len(tt.expected), len(server.Memory))
-				for key, entry := range server.Memory {
+			if server.Memory.Len() != len(tt.expected) {
+				t.Errorf("Expected %d keys in memory, got %d", len(tt.expected), server.Memory.Len())
+				server.Memory.Range(func(key string, entry shared.MemoryEntry) bool {
 					t.Errorf("Unexpected key: %s = %s", key, entry.Value)
-				}
+					return true
+				})
 			}
 		})
 	}
@@ -267,7 +273,7 @@ func TestRDBParserEdgeCases(t *testing.T) {
 		},
 		{
 			name:    "RDB with empty database",
-			hexData: "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fe00fb000000ff17353b458361d7a0",
+			hexData: "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fe00fb0000ff4a9b7c342edffd27",
 			wantErr: false,
 		},
 	}
@@ -275,7 +281,7 @@ func TestRDBParserEdgeCases(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear memory before each test
-			server.Memory = make(map[string]shared.MemoryEntry)
+			server.Memory.Clear()
 
 			data, err := hex.DecodeString(tt.hexData)
 			if err != nil {
@@ -305,7 +311,7 @@ func TestRDBParserLengthEncoding(t *testing.T) {
 	}{
 		{
 			name:    "6-bit length encoding",
-			hexData: "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fe00fb01000003666f6f03626172ff17353b458361d7a0",
+			hexData: "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fe00fb01000003666f6f03626172ffef3e5c0f0cd2d0d5",
 			expected: map[string]string{
 				"foo": "bar",
 			},
@@ -315,7 +321,7 @@ func TestRDBParserLengthEncoding(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear memory before each test
-			server.Memory = make(map[string]shared.MemoryEntry)
+			server.Memory.Clear()
 
 			data, err := hex.DecodeString(tt.hexData)
 			if err != nil {
@@ -330,7 +336,7 @@ func TestRDBParserLengthEncoding(t *testing.T) {
 
 			// Check that all expected keys are present
 			for expectedKey, expectedValue := range tt.expected {
-				entry, exists := server.Memory[expectedKey]
+				entry, exists := server.Memory.Get(expectedKey)
 				if !exists {
 					t.Errorf("Expected key '%s' not found in memory", expectedKey)
 					continue
@@ -343,6 +349,437 @@ func TestRDBParserLengthEncoding(t *testing.T) {
 	}
 }
 
+// getEntry returns the entry of key, or an empty entry when it doesn't exist
+func getEntry(key string) shared.MemoryEntry {
+	entry, _ := server.Memory.Get(key)
+	return entry
+}
+
+// rdbFixture wraps key-value payloads into a database of an RDB file of the given version
+func rdbFixture(version string, payload ...[]byte) []byte {
+	data := []byte("REDIS" + version)
+	data = append(data, 0xFE, 0x00)
+	for _, p := range payload {
+		data = append(data, p...)
+	}
+	data = append(data, 0xFF)
+	return append(data, make([]byte, 8)...)
+}
+
+// rdbString returns a short length-prefixed RDB string
+func rdbString(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+// rdbValue builds a key-value payload: type, key and the already encoded value
+func rdbValue(valueType byte, key string, value ...[]byte) []byte {
+	data := append([]byte{valueType}, rdbString(key)...)
+	for _, v := range value {
+		data = append(data, v...)
+	}
+	return data
+}
+
+// ziplistFixture builds a ziplist from already encoded entries
+func ziplistFixture(entries ...[]byte) []byte {
+	var body []byte
+	prev := 0
+	for _, entry := range entries {
+		body = append(body, byte(prev))
+		body = append(body, entry...)
+		prev = 1 + len(entry)
+	}
+	data := binary.LittleEndian.AppendUint32(nil, uint32(10+len(body)+1))
+	data = binary.LittleEndian.AppendUint32(data, uint32(10+len(body)-prev))
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(entries)))
+	data = append(data, body...)
+	return append(data, 0xFF)
+}
+
+func TestRDBParserValueTypes(t *testing.T) {
+	listpackOf := func(values ...string) []byte {
+		lp := &listpackBuilder{}
+		for _, v := range values {
+			lp.appendString(v)
+		}
+		return rdbString(string(lp.bytes()))
+	}
+	ziplist := ziplistFixture(
+		rdbString("abc"),
+		[]byte{0xF6},                         // Immediate 5
+		[]byte{0xC0, 0xE8, 0x03},             // int16 1000
+		[]byte{0xFE, 0xFD},                   // int8 -3
+		[]byte{0xF0, 0x00, 0x00, 0x80},       // int24 -8388608
+		[]byte{0xD0, 0x40, 0x42, 0x0F, 0x00}, // int32 1000000
+	)
+	intset := []byte{0x02, 0, 0, 0, 0x03, 0, 0, 0, 0x01, 0x00, 0xFE, 0xFF, 0x2C, 0x01}
+	zipmap := []byte{0x02, 0x01, 'a', 0x01, 0x00, 'x', 0x02, 'b', 'b', 0x02, 0x02, 'y', 'z', 0, 0, 0xFF}
+
+	data := rdbFixture("0011",
+		rdbValue(0x01, "list", []byte{0x02}, rdbString("a"), rdbString("b")),
+		rdbValue(0x02, "set", []byte{0x02}, rdbString("a"), rdbString("b")),
+		rdbValue(0x03, "zset", []byte{0x02}, rdbString("a"), rdbString("1.5"), rdbString("b"), []byte{254}),
+		rdbValue(0x04, "hash", []byte{0x01}, rdbString("field"), rdbString("value")),
+		rdbValue(0x09, "zipmap", rdbString(string(zipmap))),
+		rdbValue(0x0A, "ziplist", rdbString(string(ziplist))),
+		rdbValue(0x0B, "intset", rdbString(string(intset))),
+		rdbValue(0x0C, "zsetziplist", rdbString(string(ziplistFixture(rdbString("m"), []byte{0xF3})))),
+		rdbValue(0x0D, "hashziplist", rdbString(string(ziplistFixture(rdbString("f"), rdbString("v"))))),
+		rdbValue(0x0E, "quicklist", []byte{0x01}, rdbString(string(ziplistFixture(rdbString("q"))))),
+		rdbValue(0x10, "hashlistpack", listpackOf("f", "v", "g", "12")),
+		rdbValue(0x11, "zsetlistpack", listpackOf("m", "-1")),
+		rdbValue(0x14, "setlistpack", listpackOf("x", "7")),
+		[]byte{0xF8, 0x05, 0xF9, 0x03}, // LRU idle time and LFU frequency before the next key
+		rdbValue(0x00, "string", rdbString("value")),
+	)
+
+	server.Memory.Clear()
+	if err := ParseRDBData(data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lists := map[string][]string{
+		"list":      {"a", "b"},
+		"ziplist":   {"abc", "5", "1000", "-3", "-8388608", "1000000"},
+		"quicklist": {"q"},
+	}
+	for key, expected := range lists {
+		if entry := getEntry(key); !reflect.DeepEqual(entry.ListValues(), expected) {
+			t.Errorf("Expected %s to be %v, got %+v", key, expected, getEntry(key))
+		}
+	}
+
+	sets := map[string]map[string]struct{}{
+		"set":         {"a": {}, "b": {}},
+		"intset":      {"1": {}, "-2": {}, "300": {}},
+		"setlistpack": {"x": {}, "7": {}},
+	}
+	for key, expected := range sets {
+		if got := getEntry(key).Set; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, got)
+		}
+	}
+
+	hashes := map[string]map[string]string{
+		"hash":         {"field": "value"},
+		"zipmap":       {"a": "x", "bb": "yz"},
+		"hashziplist":  {"f": "v"},
+		"hashlistpack": {"f": "v", "g": "12"},
+	}
+	for key, expected := range hashes {
+		if got := getEntry(key).Hash; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, got)
+		}
+	}
+
+	zsets := map[string]map[string]float64{
+		"zset":         {"a": 1.5, "b": math.Inf(1)},
+		"zsetziplist":  {"m": 2},
+		"zsetlistpack": {"m": -1},
+	}
+	for key, expected := range zsets {
+		if got := getEntry(key).SortedSet; got == nil || !reflect.DeepEqual(got.Scores(), expected) {
+			t.Errorf("Expected %s to be %v, got %+v", key, expected, getEntry(key))
+		}
+	}
+
+	if entry := getEntry("string"); entry.Value != "value" {
+		t.Errorf("Expected string after idle and frequency opcodes, got %+v", entry)
+	}
+}
+
+func TestRDBParserVersionsAndDatabases(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		keys    []string
+		wantErr bool
+	}{
+		{
+			name: "Version 6 file",
+			data: rdbFixture("0006", rdbValue(0x00, "foo", rdbString("bar"))),
+			keys: []string{"foo"},
+		},
+		{
+			name: "Keys in several databases",
+			data: rdbFixture("0011",
+				rdbValue(0x00, "db0", rdbString("a")),
+				[]byte{0xFE, 0x03, 0xFB, 0x01, 0x00},
+				rdbValue(0x00, "db3", rdbString("b")),
+			),
+			keys: []string{"db0", "db3"},
+		},
+		{
+			name: "Empty dataset",
+			data: append([]byte("REDIS0011\xff"), make([]byte, 8)...),
+		},
+		{
+			name:    "Unsupported future version",
+			data:    rdbFixture("0099", rdbValue(0x00, "foo", rdbString("bar"))),
+			wantErr: true,
+		},
+		{
+			name:    "Unknown value type",
+			data:    rdbFixture("0011", rdbValue(0x07, "foo", rdbString("bar"))),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.Memory.Clear()
+
+			err := ParseRDBData(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if server.Memory.Len() != len(tt.keys) {
+				t.Errorf("Expected %d keys, got %d", len(tt.keys), server.Memory.Len())
+			}
+			for _, key := range tt.keys {
+				if _, exists := server.Memory.Get(key); !exists {
+					t.Errorf("Expected key %q to be loaded", key)
+				}
+			}
+		})
+	}
+}
+
+func TestRDBParserStreamConsumerGroups(t *testing.T) {
+	stream := []shared.StreamEntry{{ID: "1-1", Data: map[string]string{"a": "b"}}}
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, map[string]shared.MemoryEntry{"stream": {Stream: stream}, "zzz": {Value: "after"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The stream is written with no consumer groups; splice one in before the next key
+	data := buf.Bytes()
+	next := bytes.Index(data, append([]byte{0x00}, rdbString("zzz")...))
+	if next < 1 || data[next-1] != 0x00 {
+		t.Fatalf("Could not locate the consumer group count")
+	}
+	group := []byte{0x01}
+	group = append(group, rdbString("group")...)
+	group = append(group, 0x01, 0x01, 0x01)      // Last delivered ID and entries read
+	group = append(group, 0x01)                  // One pending entry
+	group = append(group, make([]byte, 16+8)...) // Its ID and delivery time
+	group = append(group, 0x01)                  // Delivery count
+	group = append(group, 0x01)                  // One consumer
+	group = append(group, rdbString("consumer")...)
+	group = append(group, make([]byte, 8+8)...) // Seen and active times
+	group = append(group, 0x01)                 // One pending entry
+	group = append(group, make([]byte, 16)...)
+	patched := append(append(append([]byte{}, data[:next-1]...), group...), data[next:]...)
+	binary.LittleEndian.PutUint64(patched[len(patched)-8:], crc64Update(0, patched[:len(patched)-8]))
+
+	server.Memory.Clear()
+	if err := ParseRDBData(patched); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := getEntry("stream").Stream; !reflect.DeepEqual(got, stream) {
+		t.Errorf("Expected stream to be loaded, got %+v", got)
+	}
+	if entry := getEntry("zzz"); entry.Value != "after" {
+		t.Errorf("Expected key after the stream to be loaded, got %+v", entry)
+	}
+}
+
+func TestRDBParserChecksum(t *testing.T) {
+	defer func(checksum bool) { server.StoreState.RDBChecksum = checksum }(server.StoreState.RDBChecksum)
+	server.StoreState.RDBChecksum = true
+
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, map[string]shared.MemoryEntry{"foo": {Value: "bar"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	valid := buf.Bytes()
+	corrupt := func(mutate func([]byte) []byte) []byte {
+		return mutate(append([]byte{}, valid...))
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		checksum bool
+		wantErr  bool
+	}{
+		{name: "Valid checksum", data: valid, checksum: true},
+		{
+			name:     "Corrupted value",
+			data:     corrupt(func(d []byte) []byte { d[bytes.Index(d, []byte("bar"))] = 'c'; return d }),
+			checksum: true,
+			wantErr:  true,
+		},
+		{
+			name:     "Corrupted checksum",
+			data:     corrupt(func(d []byte) []byte { d[len(d)-1] ^= 0xFF; return d }),
+			checksum: true,
+			wantErr:  true,
+		},
+		{
+			name:     "Missing checksum",
+			data:     valid[:len(valid)-8],
+			checksum: true,
+			wantErr:  true,
+		},
+		{
+			name:     "Checksum written as zero",
+			data:     corrupt(func(d []byte) []byte { copy(d[len(d)-8:], make([]byte, 8)); return d }),
+			checksum: true,
+		},
+		{
+			name:     "Verification disabled",
+			data:     corrupt(func(d []byte) []byte { d[len(d)-1] ^= 0xFF; return d }),
+			checksum: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.StoreState.RDBChecksum = tt.checksum
+			server.Memory.Clear()
+
+			err := ParseRDBData(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteRDBWithoutChecksum(t *testing.T) {
+	defer func(checksum bool) { server.StoreState.RDBChecksum = checksum }(server.StoreState.RDBChecksum)
+	server.StoreState.RDBChecksum = false
+
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, map[string]shared.MemoryEntry{"foo": {Value: "bar"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+	if checksum := binary.LittleEndian.Uint64(data[len(data)-8:]); checksum != 0 {
+		t.Errorf("Expected a zero checksum, got 0x%x", checksum)
+	}
+
+	// Files without a checksum still load when verification is enabled
+	server.StoreState.RDBChecksum = true
+	if err := ParseRDBData(data); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestLoadMasterSnapshot(t *testing.T) {
+	server.Memory.Clear()
+	defer server.Memory.Clear()
+
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, map[string]shared.MemoryEntry{"fromMaster": {Value: "1"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server.Memory.Set("local", shared.MemoryEntry{Value: "2"})
+
+	if err := LoadMasterSnapshot(buf.Bytes()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, exists := server.Memory.Get("local"); exists {
+		t.Errorf("Expected the local key to be discarded")
+	}
+	if entry, exists := server.Memory.Get("fromMaster"); !exists || entry.Value != "1" {
+		t.Errorf("Expected fromMaster to be loaded, got %v (exists: %v)", entry.Value, exists)
+	}
+	if Loading() {
+		t.Errorf("Expected loading to be over")
+	}
+}
+
+func TestRDBParserIntegerStrings(t *testing.T) {
+	server.Memory.Clear()
+
+	data := rdbFixture("0011",
+		rdbValue(0x00, "int8", []byte{0xC0, 0xF6}),
+		rdbValue(0x00, "int16", []byte{0xC1, 0xE8, 0x03}),
+		rdbValue(0x00, "int32", []byte{0xC2, 0x00, 0x94, 0x35, 0x77}),
+		rdbValue(0x00, "negative", []byte{0xC2, 0xFF, 0xFF, 0xFF, 0xFF}),
+		append([]byte{0x00, 0xC1, 0x39, 0x30}, rdbString("integer key")...),
+	)
+	if err := ParseRDBData(data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"int8":     "-10",
+		"int16":    "1000",
+		"int32":    "2000000000",
+		"negative": "-1",
+		"12345":    "integer key",
+	}
+	for key, value := range expected {
+		if got := getEntry(key).Value; got != value {
+			t.Errorf("Expected %q for %s, got %q", value, key, got)
+		}
+	}
+
+	// Integer encoded auxiliary fields are skipped like any other string
+	data = []byte("REDIS0011")
+	data = append(data, 0xFA)
+	data = append(data, rdbString("redis-bits")...)
+	data = append(data, 0xC0, 0x40, 0xFA)
+	data = append(data, rdbString("ctime")...)
+	data = append(data, 0xC2, 0x01, 0x02, 0x03, 0x04)
+	data = append(data, rdbFixture("", rdbValue(0x00, "k", rdbString("v")))[5:]...)
+	if err := ParseRDBData(data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if getEntry("k").Value != "v" {
+		t.Errorf("Expected k to be loaded after integer auxiliary fields, got %v", server.Memory.Clone())
+	}
+
+	for name, value := range map[string][]byte{
+		"Unknown string encoding": {0xC4, 0x00},
+		"Truncated int32":         {0xC2, 0x01, 0x02},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parser := NewRDBParser(value)
+			if _, err := parser.readLengthEncodedString(); err == nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestDecodeEncodingErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func([]byte) ([]string, error)
+		data   []byte
+	}{
+		{name: "Short ziplist", decode: decodeZiplist, data: []byte{0x01}},
+		{name: "Ziplist without terminator", decode: decodeZiplist, data: ziplistFixture(rdbString("a"))[:13]},
+		{name: "Bad intset encoding", decode: decodeIntset, data: []byte{0x03, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "Truncated intset", decode: decodeIntset, data: []byte{0x02, 0, 0, 0, 0x02, 0, 0, 0, 0x01, 0x00}},
+		{name: "Zipmap without terminator", decode: decodeZipmap, data: []byte{0x01, 0x01, 'a', 0x01, 0x00, 'b'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.decode(tt.data); err == nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	}
+}
+
 func BenchmarkRDBParser(b *testing.B) {
 	// Test data with single key
 	hexData := "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fe00fb01000009626c75656265727279066f72616e6765ff17353b458361d7a0"
@@ -351,24 +788,34 @@ func BenchmarkRDBParser(b *testing.B) {
 		b.Fatalf("Failed to decode hex data: %v", err)
 	}
 
+	// A fixture the parser refuses would only measure how fast it fails
+	if err := ParseRDBData(data); err != nil {
+		b.Fatalf("Failed to parse the RDB data: %v", err)
+	}
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		server.Memory = make(map[string]shared.MemoryEntry)
+		server.Memory.Clear()
 		ParseRDBData(data)
 	}
 }
 
 func BenchmarkRDBParserLarge(b *testing.B) {
-	// Create a larger RDB file with multiple keys
-	hexData := "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fe00fb100000036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616c036b65790376616cff17353b458361d7a0"
+	// RDB file with 16 keys, key00 to key15 set to val00 to val15, and its checksum
+	hexData := "524544495330303131fa0972656469732d76657205372e322e30fa0a72656469732d62697473c040fe00fb100000056b657930300576616c303000056b657930310576616c303100056b657930320576616c303200056b657930330576616c303300056b657930340576616c303400056b657930350576616c303500056b657930360576616c303600056b657930370576616c303700056b657930380576616c303800056b657930390576616c303900056b657931300576616c313000056b657931310576616c313100056b657931320576616c313200056b657931330576616c313300056b657931340576616c313400056b657931350576616c3135ff940213c3aca9d267"
 	data, err := hex.DecodeString(hexData)
 	if err != nil {
 		b.Fatalf("Failed to decode hex data: %v", err)
 	}
 
+	// A fixture the parser refuses would only measure how fast it fails
+	if err := ParseRDBData(data); err != nil {
+		b.Fatalf("Failed to parse the RDB data: %v", err)
+	}
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		server.Memory = make(map[string]shared.MemoryEntry)
+		server.Memory.Clear()
 		ParseRDBData(data)
 	}
 }
@@ -0,0 +1,457 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc64"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// RDB opcodes and value types written by RDBWriter
+const (
+	rdbOpcodeAux          = 0xFA
+	rdbOpcodeResizeDB     = 0xFB
+	rdbOpcodeExpireTimeMs = 0xFC
+	rdbOpcodeSelectDB     = 0xFE
+	rdbOpcodeEOF          = 0xFF
+
+	rdbTypeString           = 0x00
+	rdbTypeSet              = 0x02
+	rdbTypeHash             = 0x04
+	rdbTypeZset2            = 0x05
+	rdbTypeZsetListpack     = 0x11
+	rdbTypeListQuicklist2   = 0x12
+	rdbTypeStreamListpacks3 = 0x15
+
+	quicklistNodeContainerLP = 2
+
+	rdbEncodingInt8  = 0xC0 // Special string encoding: 8 bit integer
+	rdbEncodingInt16 = 0xC1 // Special string encoding: 16 bit little endian integer
+	rdbEncodingInt32 = 0xC2 // Special string encoding: 32 bit little endian integer
+	rdbEncodingLZF   = 0xC3 // Special string encoding: LZF compressed
+)
+
+// Encoding thresholds, matching the Redis defaults
+const (
+	streamNodeMaxEntries = 100 // stream-node-max-entries
+)
+
+// Stream entry flags stored in stream listpacks
+const (
+	streamItemFlagDeleted    = 1
+	streamItemFlagSameFields = 2
+)
+
+// crc64Table is the Jones polynomial table used by Redis for RDB checksums.
+// hash/crc64 expects the polynomial in reversed form.
+var crc64Table = crc64.MakeTable(0x95AC9329AC4BC9B5)
+
+// crc64Update extends a Redis CRC64 checksum with data.
+// Redis doesn't invert the checksum before and after like hash/crc64 does.
+func crc64Update(crc uint64, data []byte) uint64 {
+	return ^crc64.Update(^crc, crc64Table, data)
+}
+
+// RDBWriter serializes the dataset in RDB format
+type RDBWriter struct {
+	w        *bufio.Writer
+	crc      uint64 // Checksum of everything written so far
+	err      error
+	compress bool // LZF compress strings longer than lzfMinCompressLen (rdbcompression)
+}
+
+// NewRDBWriter creates a new RDB writer on top of w
+func NewRDBWriter(w io.Writer) *RDBWriter {
+	return &RDBWriter{w: bufio.NewWriter(w), compress: server.StoreState.RDBCompression}
+}
+
+// WriteRDB writes memory to w as a complete RDB file
+func WriteRDB(w io.Writer, memory map[string]shared.MemoryEntry) error {
+	return writeRDB(w, snapshotOf(memory))
+}
+
+// writeRDB writes the keys of a snapshot to w as a complete RDB file
+func writeRDB(w io.Writer, s *Snapshot) error {
+	rw := NewRDBWriter(w)
+	rw.writeHeader()
+	rw.writeFunctions(s.functions)
+
+	keys, expiresCount := s.keys()
+
+	rw.writeByte(rdbOpcodeSelectDB)
+	rw.writeLength(0)
+	rw.writeByte(rdbOpcodeResizeDB)
+	rw.writeLength(len(keys))
+	rw.writeLength(expiresCount)
+
+	for _, key := range keys {
+		s.visit(key, func(entry shared.MemoryEntry) {
+			rw.writeEntry(key, entry)
+		})
+	}
+
+	rw.writeByte(rdbOpcodeEOF)
+	var checksum [8]byte
+	if server.StoreState.RDBChecksum {
+		// Readers skip verification when the checksum is zero
+		binary.LittleEndian.PutUint64(checksum[:], rw.crc)
+	}
+	rw.write(checksum[:])
+
+	return rw.flush()
+}
+
+// writeHeader writes the magic string, the version and the aux fields
+func (rw *RDBWriter) writeHeader() {
+	rw.write([]byte("REDIS0011"))
+	rw.writeAux("redis-ver", "7.2.0")
+	rw.writeAux("redis-bits", "64")
+	rw.writeAux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+}
+
+// writeAux writes an auxiliary field
+func (rw *RDBWriter) writeAux(key, value string) {
+	rw.writeByte(rdbOpcodeAux)
+	rw.writeString(key)
+	rw.writeString(value)
+}
+
+// writeEntry writes a key with its expiration and value
+func (rw *RDBWriter) writeEntry(key string, entry shared.MemoryEntry) {
+	if entry.Expires > 0 {
+		rw.writeByte(rdbOpcodeExpireTimeMs)
+		var expires [8]byte
+		binary.LittleEndian.PutUint64(expires[:], uint64(entry.Expires))
+		rw.write(expires[:])
+	}
+
+	rw.writeByte(rdbValueType(entry))
+	rw.writeString(key)
+	rw.writeValue(entry)
+}
+
+// rdbValueType returns the RDB type byte the value of entry is written with
+func rdbValueType(entry shared.MemoryEntry) byte {
+	switch {
+	case entry.Stream != nil:
+		return rdbTypeStreamListpacks3
+	case entry.SortedSet != nil && entry.SortedSet.Packed():
+		return rdbTypeZsetListpack
+	case entry.SortedSet != nil:
+		return rdbTypeZset2
+	case entry.List != nil, entry.Array != nil:
+		return rdbTypeListQuicklist2
+	case entry.Set != nil:
+		return rdbTypeSet
+	case entry.Hash != nil:
+		return rdbTypeHash
+	default:
+		return rdbTypeString
+	}
+}
+
+// writeValue writes the value of entry, in the format rdbValueType returns
+func (rw *RDBWriter) writeValue(entry shared.MemoryEntry) {
+	switch {
+	case entry.Stream != nil:
+		rw.writeStream(entry.Stream)
+	case entry.SortedSet != nil && entry.SortedSet.Packed():
+		rw.writeSortedSetListpack(entry.SortedSet)
+	case entry.SortedSet != nil:
+		rw.writeSortedSet(entry.SortedSet)
+	case entry.List != nil:
+		rw.writeQuicklist(entry.List.ToArray())
+	case entry.Array != nil:
+		rw.writeQuicklist(entry.Array)
+	case entry.Set != nil:
+		members := make([]string, 0, len(entry.Set))
+		for member := range entry.Set {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		rw.writeLength(len(members))
+		for _, member := range members {
+			rw.writeString(member)
+		}
+	case entry.Hash != nil:
+		fields := sortedFields(entry.Hash)
+		rw.writeLength(len(fields))
+		for _, field := range fields {
+			rw.writeString(field)
+			rw.writeString(entry.Hash[field])
+		}
+	default:
+		rw.writeString(entry.Value)
+	}
+}
+
+// writeQuicklist writes a list as quicklist nodes, each holding a listpack within
+// list-max-listpack-size
+func (rw *RDBWriter) writeQuicklist(values []string) {
+	limit := shared.GetEncodingLimits().ListMaxListpackSize
+	var nodes []*listpackBuilder
+	node := &listpackBuilder{}
+	for _, value := range values {
+		if node.count > 0 && shared.ListpackFull(node.count+1, node.size()+len(value), limit) {
+			nodes = append(nodes, node)
+			node = &listpackBuilder{}
+		}
+		node.appendString(value)
+	}
+	if node.count > 0 {
+		nodes = append(nodes, node)
+	}
+
+	rw.writeLength(len(nodes))
+	for _, node := range nodes {
+		rw.writeLength(quicklistNodeContainerLP)
+		rw.writeString(string(node.bytes()))
+	}
+}
+
+// writeSortedSetListpack writes a small sorted set as a listpack of member, score pairs
+// ordered by score
+func (rw *RDBWriter) writeSortedSetListpack(ss *shared.SortedSet) {
+	lp := &listpackBuilder{}
+	for _, m := range ss.Sorted() {
+		lp.appendString(m.Member)
+		lp.appendString(formatScore(m.Score))
+	}
+	rw.writeString(string(lp.bytes()))
+}
+
+// formatScore formats a score the way Redis stores it in listpacks
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'g', 17, 64)
+}
+
+// writeStream writes a stream as listpack nodes of up to streamNodeMaxEntries entries,
+// followed by its metadata. Consumer groups are not supported, so none are written.
+func (rw *RDBWriter) writeStream(entries []shared.StreamEntry) {
+	type nodeData struct {
+		masterMs, masterSeq uint64
+		lp                  *listpackBuilder
+	}
+	var nodes []nodeData
+
+	for start := 0; start < len(entries); start += streamNodeMaxEntries {
+		end := min(start+streamNodeMaxEntries, len(entries))
+		masterMs, masterSeq := parseStreamID(entries[start].ID)
+		masterFields := sortedFields(entries[start].Data)
+
+		// Master entry: count, deleted, fields shared by the entries, terminator
+		lp := &listpackBuilder{}
+		lp.appendInt(int64(end - start))
+		lp.appendInt(0)
+		lp.appendInt(int64(len(masterFields)))
+		for _, field := range masterFields {
+			lp.appendString(field)
+		}
+		lp.appendInt(0)
+
+		for _, entry := range entries[start:end] {
+			ms, seq := parseStreamID(entry.ID)
+			fields := sortedFields(entry.Data)
+			sameFields := equalFields(fields, masterFields)
+
+			flags := int64(0)
+			if sameFields {
+				flags = streamItemFlagSameFields
+			}
+			lp.appendInt(flags)
+			lp.appendInt(int64(ms - masterMs))
+			lp.appendInt(int64(seq - masterSeq))
+
+			lpCount := int64(len(fields) + 3)
+			if sameFields {
+				for _, field := range fields {
+					lp.appendString(entry.Data[field])
+				}
+			} else {
+				lp.appendInt(int64(len(fields)))
+				for _, field := range fields {
+					lp.appendString(field)
+					lp.appendString(entry.Data[field])
+				}
+				lpCount += int64(len(fields) + 1)
+			}
+			lp.appendInt(lpCount)
+		}
+
+		nodes = append(nodes, nodeData{masterMs, masterSeq, lp})
+	}
+
+	rw.writeLength(len(nodes))
+	for _, node := range nodes {
+		var nodeKey [16]byte
+		binary.BigEndian.PutUint64(nodeKey[0:8], node.masterMs)
+		binary.BigEndian.PutUint64(nodeKey[8:16], node.masterSeq)
+		rw.writeString(string(nodeKey[:]))
+		rw.writeString(string(node.lp.bytes()))
+	}
+
+	var firstMs, firstSeq, lastMs, lastSeq uint64
+	if len(entries) > 0 {
+		firstMs, firstSeq = parseStreamID(entries[0].ID)
+		lastMs, lastSeq = parseStreamID(entries[len(entries)-1].ID)
+	}
+	rw.writeLength(len(entries))
+	rw.writeLength64(lastMs)
+	rw.writeLength64(lastSeq)
+	rw.writeLength64(firstMs)
+	rw.writeLength64(firstSeq)
+	rw.writeLength(0) // Max deleted entry ID
+	rw.writeLength(0)
+	rw.writeLength(len(entries)) // Entries added
+	rw.writeLength(0)            // Consumer groups
+}
+
+// parseStreamID splits a "<ms>-<seq>" stream ID
+func parseStreamID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msVal, _ := strconv.ParseUint(ms, 10, 64)
+	seqVal, _ := strconv.ParseUint(seq, 10, 64)
+	return msVal, seqVal
+}
+
+// sortedFields returns the field names of a stream entry in a stable order
+func sortedFields(data map[string]string) []string {
+	fields := make([]string, 0, len(data))
+	for field := range data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// equalFields reports whether two field lists are identical
+func equalFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeSortedSet writes the set size followed by each member and its binary double score
+func (rw *RDBWriter) writeSortedSet(ss *shared.SortedSet) {
+	scores := ss.Scores()
+	members := make([]string, 0, len(scores))
+	for member := range scores {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+
+	rw.writeLength(len(members))
+	for _, member := range members {
+		rw.writeString(member)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(scores[member]))
+		rw.write(buf[:])
+	}
+}
+
+// Helper methods
+
+func (rw *RDBWriter) write(data []byte) {
+	if rw.err != nil {
+		return
+	}
+	rw.crc = crc64Update(rw.crc, data)
+	_, rw.err = rw.w.Write(data)
+}
+
+func (rw *RDBWriter) writeByte(b byte) {
+	rw.write([]byte{b})
+}
+
+// writeLength writes a length using the same encoding readLength understands:
+// 6 bit, 14 bit, 32 bit or 64 bit depending on the value
+func (rw *RDBWriter) writeLength(length int) {
+	rw.writeLength64(uint64(length))
+}
+
+// writeLength64 writes a length that may not fit in an int, like stream ID parts
+func (rw *RDBWriter) writeLength64(length uint64) {
+	switch {
+	case length < 1<<6:
+		rw.writeByte(byte(length))
+	case length < 1<<14:
+		rw.write([]byte{byte(length>>8) | 0x40, byte(length)})
+	case length <= math.MaxUint32:
+		var buf [5]byte
+		buf[0] = 0x80
+		binary.BigEndian.PutUint32(buf[1:], uint32(length))
+		rw.write(buf[:])
+	default:
+		var buf [9]byte
+		buf[0] = 0x81
+		binary.BigEndian.PutUint64(buf[1:], length)
+		rw.write(buf[:])
+	}
+}
+
+func (rw *RDBWriter) writeString(s string) {
+	if rw.writeIntegerString(s) {
+		return
+	}
+	if rw.compress && len(s) > lzfMinCompressLen {
+		if compressed := lzfCompress([]byte(s)); compressed != nil {
+			rw.writeByte(rdbEncodingLZF)
+			rw.writeLength(len(compressed))
+			rw.writeLength(len(s))
+			rw.write(compressed)
+			return
+		}
+	}
+
+	rw.writeLength(len(s))
+	rw.write([]byte(s))
+}
+
+// writeIntegerString writes s as an 8, 16 or 32 bit integer when it is the canonical
+// decimal form of one, and reports whether it did
+func (rw *RDBWriter) writeIntegerString(s string) bool {
+	if len(s) == 0 || len(s) > 11 {
+		return false
+	}
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || strconv.FormatInt(n, 10) != s {
+		return false
+	}
+
+	switch {
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		rw.write([]byte{rdbEncodingInt8, byte(n)})
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		rw.write([]byte{rdbEncodingInt16, byte(n), byte(n >> 8)})
+	default:
+		rw.write([]byte{rdbEncodingInt32, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)})
+	}
+	return true
+}
+
+func (rw *RDBWriter) flush() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	return rw.w.Flush()
+}
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestWriteRDB(t *testing.T) {
+	tests := []struct {
+		name     string
+		memory   map[string]shared.MemoryEntry
+		expected map[string]string
+	}{
+		{
+			name:     "Empty dataset",
+			memory:   map[string]shared.MemoryEntry{},
+			expected: map[string]string{},
+		},
+		{
+			name: "String values",
+			memory: map[string]shared.MemoryEntry{
+				"foo":       {Value: "bar"},
+				"blueberry": {Value: "orange"},
+			},
+			expected: map[string]string{"foo": "bar", "blueberry": "orange"},
+		},
+		{
+			name: "Long string value",
+			memory: map[string]shared.MemoryEntry{
+				"long": {Value: strings.Repeat("x", 20000)},
+			},
+			expected: map[string]string{"long": strings.Repeat("x", 20000)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteRDB(&buf, tt.memory); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data := buf.Bytes()
+			if !bytes.HasPrefix(data, []byte("REDIS0011")) {
+				t.Errorf("Expected RDB header, got %q", data[:9])
+			}
+
+			if err := ParseRDBData(data); err != nil {
+				t.Fatalf("Failed to parse written RDB: %v", err)
+			}
+			if server.Memory.Len() != len(tt.expected) {
+				t.Errorf("Expected %d keys, got %d", len(tt.expected), server.Memory.Len())
+			}
+			for key, value := range tt.expected {
+				if entry := getEntry(key); entry.Value != value {
+					t.Errorf("Expected %q for key %q, got %q", value, key, entry.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteRDBRoundTrip(t *testing.T) {
+	zset := shared.NewSortedSet()
+	zset.Add("one", 1)
+	zset.Add("pi", 3.14159)
+	memory := map[string]shared.MemoryEntry{
+		"string":  {Value: "value", Expires: 1956528000000},
+		"list":    {List: shared.FromArray([]string{"a", "b", "c"})},
+		"array":   {Array: []string{"x", "y"}},
+		"zset":    {SortedSet: zset},
+		"expired": {Value: "gone", Expires: 1640995200000},
+		"set":     {Set: map[string]struct{}{"a": {}, "b": {}}},
+		"hash":    {Hash: map[string]string{"field": "value", "n": "1"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, memory); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := ParseRDBData(buf.Bytes()); err != nil {
+		t.Fatalf("Failed to parse written RDB: %v", err)
+	}
+
+	if entry := getEntry("string"); entry.Value != "value" || entry.Expires != 1956528000000 {
+		t.Errorf("Expected string with expiry, got %+v", entry)
+	}
+	if entry := getEntry("expired"); entry.Expires != 1640995200000 {
+		t.Errorf("Expected expiry to be preserved, got %+v", entry)
+	}
+	if entry := getEntry("list"); strings.Join(entry.ListValues(), ",") != "a,b,c" {
+		t.Errorf("Expected list a,b,c, got %+v", entry)
+	}
+	if entry := getEntry("array"); strings.Join(entry.ListValues(), ",") != "x,y" {
+		t.Errorf("Expected list x,y, got %+v", entry)
+	}
+	if entry := getEntry("set"); !reflect.DeepEqual(entry.Set, memory["set"].Set) {
+		t.Errorf("Expected set to round-trip, got %+v", entry)
+	}
+	if entry := getEntry("hash"); !reflect.DeepEqual(entry.Hash, memory["hash"].Hash) {
+		t.Errorf("Expected hash to round-trip, got %+v", entry)
+	}
+	entry := getEntry("zset")
+	if entry.SortedSet == nil || entry.SortedSet.Size != 2 {
+		t.Fatalf("Expected sorted set with 2 members, got %+v", entry)
+	}
+	if score, _ := entry.SortedSet.GetScore("pi"); score != 3.14159 {
+		t.Errorf("Expected score 3.14159, got %v", score)
+	}
+}
+
+func TestWriteRDBEncodings(t *testing.T) {
+	bigZset := shared.NewSortedSet()
+	for i := 0; i < shared.DefaultEncodingLimits.ZsetMaxListpackEntries+1; i++ {
+		bigZset.Add(strconv.Itoa(i), float64(i)/3)
+	}
+	smallZset := shared.NewSortedSet()
+	smallZset.Add("a", 1.5)
+	smallZset.Add("b", -2)
+	smallZset.Add("c", math.Inf(1))
+
+	longList := make([]string, 3000)
+	for i := range longList {
+		longList[i] = "element-" + strconv.Itoa(i)
+	}
+
+	var stream []shared.StreamEntry
+	for i := 0; i < 250; i++ {
+		data := map[string]string{"temperature": strconv.Itoa(i), "humidity": "42"}
+		if i%7 == 0 {
+			data = map[string]string{"event": "reset"}
+		}
+		stream = append(stream, shared.StreamEntry{ID: "1526919030474-" + strconv.Itoa(i), Data: data})
+	}
+
+	memory := map[string]shared.MemoryEntry{
+		"bigzset":   {SortedSet: bigZset},
+		"smallzset": {SortedSet: smallZset},
+		"longlist":  {List: shared.FromArray(longList)},
+		"stream":    {Stream: stream},
+		"empty":     {Stream: []shared.StreamEntry{}},
+		"bigid":     {Stream: []shared.StreamEntry{{ID: "18446744073709551615-3", Data: map[string]string{"a": "b"}}}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, memory); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := ParseRDBData(buf.Bytes()); err != nil {
+		t.Fatalf("Failed to parse written RDB: %v", err)
+	}
+
+	for _, key := range []string{"bigzset", "smallzset"} {
+		loaded := getEntry(key).SortedSet
+		if loaded == nil || !reflect.DeepEqual(loaded.Scores(), memory[key].SortedSet.Scores()) {
+			t.Errorf("Expected %s to round-trip, got %+v", key, getEntry(key))
+		}
+	}
+	if list := getEntry("longlist").List; list == nil || !reflect.DeepEqual(list.ToArray(), longList) {
+		t.Errorf("Expected longlist to round-trip")
+	}
+	if got := getEntry("stream").Stream; !reflect.DeepEqual(got, stream) {
+		t.Errorf("Expected stream to round-trip, got %d entries", len(got))
+	}
+	if entry, exists := server.Memory.Get("empty"); !exists || entry.Stream == nil || len(entry.Stream) != 0 {
+		t.Errorf("Expected empty stream to be loaded, got %+v", entry)
+	}
+	if got := getEntry("bigid").Stream; len(got) != 1 || got[0].ID != "18446744073709551615-3" {
+		t.Errorf("Expected stream ID to round-trip, got %+v", got)
+	}
+}
+
+func TestWriteRDBChecksum(t *testing.T) {
+	defer func(checksum bool) { server.StoreState.RDBChecksum = checksum }(server.StoreState.RDBChecksum)
+	server.StoreState.RDBChecksum = true
+
+	// Reference value of the Redis CRC64 implementation
+	if crc := crc64Update(0, []byte("123456789")); crc != 0xe9c6d914c4b8d9ca {
+		t.Errorf("Expected CRC64 0xe9c6d914c4b8d9ca, got 0x%x", crc)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRDB(&buf, map[string]shared.MemoryEntry{"foo": {Value: "bar"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The file ends with the checksum of everything before it
+	data := buf.Bytes()
+	expected := crc64Update(0, data[:len(data)-8])
+	if checksum := binary.LittleEndian.Uint64(data[len(data)-8:]); checksum != expected {
+		t.Errorf("Expected checksum 0x%x, got 0x%x", expected, checksum)
+	}
+}
+
+func TestWriteRDBCompression(t *testing.T) {
+	defer func(compress bool) { server.StoreState.RDBCompression = compress }(server.StoreState.RDBCompression)
+
+	longList := make([]string, 500)
+	for i := range longList {
+		longList[i] = "element-" + strconv.Itoa(i)
+	}
+	memory := map[string]shared.MemoryEntry{
+		"long":  {Value: strings.Repeat("abcdefgh", 1000)},
+		"short": {Value: "not worth compressing"},
+		"list":  {List: shared.FromArray(longList)},
+	}
+
+	sizes := make(map[bool]int)
+	for _, compress := range []bool{false, true} {
+		server.StoreState.RDBCompression = compress
+
+		var buf bytes.Buffer
+		if err := WriteRDB(&buf, memory); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sizes[compress] = buf.Len()
+
+		// The raw value is only in the file when compression is off
+		if raw := bytes.Contains(buf.Bytes(), []byte(memory["long"].Value)); raw == compress {
+			t.Errorf("Expected raw value present %v with rdbcompression %v", !compress, compress)
+		}
+
+		if err := ParseRDBData(buf.Bytes()); err != nil {
+			t.Fatalf("Failed to parse written RDB: %v", err)
+		}
+		for _, key := range []string{"long", "short"} {
+			if entry := getEntry(key); entry.Value != memory[key].Value {
+				t.Errorf("Expected %s to round-trip with compression %v", key, compress)
+			}
+		}
+		if list := getEntry("list").List; list == nil || !reflect.DeepEqual(list.ToArray(), longList) {
+			t.Errorf("Expected list to round-trip with compression %v", compress)
+		}
+	}
+
+	if sizes[true] >= sizes[false] {
+		t.Errorf("Expected compressed file to be smaller: %d >= %d", sizes[true], sizes[false])
+	}
+}
+
+func BenchmarkWriteRDB(b *testing.B) {
+	memory := make(map[string]shared.MemoryEntry)
+	for i := 0; i < 1000; i++ {
+		memory[strings.Repeat("k", i%50+1)+string(rune('a'+i%26))] = shared.MemoryEntry{Value: "value"}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		WriteRDB(&buf, memory)
+	}
+}
+
+func TestWriteRDBIntegerStrings(t *testing.T) {
+	tests := []struct {
+		value   string
+		encoded []byte
+	}{
+		{value: "0", encoded: []byte{0xC0, 0x00}},
+		{value: "-128", encoded: []byte{0xC0, 0x80}},
+		{value: "1000", encoded: []byte{0xC1, 0xE8, 0x03}},
+		{value: "-2147483648", encoded: []byte{0xC2, 0x00, 0x00, 0x00, 0x80}},
+		// Values that would not read back byte for byte stay raw strings
+		{value: "2147483648", encoded: []byte{0x0A, '2', '1', '4', '7', '4', '8', '3', '6', '4', '8'}},
+		{value: "007", encoded: []byte{0x03, '0', '0', '7'}},
+		{value: "+1", encoded: []byte{0x02, '+', '1'}},
+		{value: "-0", encoded: []byte{0x02, '-', '0'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var buf bytes.Buffer
+			rw := NewRDBWriter(&buf)
+			rw.writeString(tt.value)
+			if err := rw.flush(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.encoded) {
+				t.Errorf("Expected %x, got %x", tt.encoded, buf.Bytes())
+			}
+
+			parser := NewRDBParser(buf.Bytes())
+			if got, err := parser.readLengthEncodedString(); err != nil || got != tt.value {
+				t.Errorf("Expected %q to read back, got %q (%v)", tt.value, got, err)
+			}
+		})
+	}
+}
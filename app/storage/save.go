@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/logger"
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+)
+
+// rdbLog logs the messages of the rdb subsystem
+var rdbLog = logger.New("rdb")
+
+// bgsaveInProgress is set while a BGSAVE goroutine is writing the RDB file
+var bgsaveInProgress atomic.Bool
+
+// backgroundJobs counts the background saves and AOF rewrites still writing their file
+var backgroundJobs sync.WaitGroup
+
+// lastSaveTime is the Unix time in seconds of the last successful save, or of startup
+var lastSaveTime atomic.Int64
+
+// lastSaveDirty is the value of the dirty counter captured by the last successful save
+var lastSaveDirty atomic.Int64
+
+// lastBgsaveFailed is set when the last background save failed
+var lastBgsaveFailed atomic.Bool
+
+// bgsaveStartTime is the Unix time in seconds the running BGSAVE started at
+var bgsaveStartTime atomic.Int64
+
+// lastBgsaveDuration is how many seconds the last BGSAVE took, -1 before the first one
+var lastBgsaveDuration atomic.Int64
+
+func init() {
+	lastSaveTime.Store(time.Now().Unix())
+	lastBgsaveDuration.Store(-1)
+}
+
+// SaveRDBFile writes a snapshot of the dataset to dir/filename.
+// The snapshot goes to a temporary file in dir first, which is fsynced and renamed over the
+// old one, then dir is fsynced so the rename itself is durable: a crash during a save leaves
+// either the previous file or the complete new one, never a truncated file that fails to load.
+func SaveRDBFile(dir, filename string, snapshot *Snapshot) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "temp-*.rdb")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary RDB file: %v", err)
+	}
+
+	err = snapshot.WriteRDB(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write RDB file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, filename)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to rename temporary RDB file: %v", err)
+	}
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("failed to fsync directory %s: %v", dir, err)
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory, so the files created or renamed in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Save synchronously writes the dataset to the configured RDB file
+func Save() error {
+	if bgsaveInProgress.Load() {
+		return fmt.Errorf("ERR Background save already in progress")
+	}
+
+	dirty := server.Dirty()
+	start := time.Now()
+	// Other connections keep running while SAVE blocks the caller, so the dataset is copied
+	if err := SaveRDBFile(server.StoreState.ConfigDir, server.StoreState.ConfigDbfilename, snapshotOf(server.Memory.Clone())); err != nil {
+		return err
+	}
+	server.LatencyAddSampleIfNeeded(server.LatencyEventSave, time.Since(start))
+	// A successful SAVE clears a previous BGSAVE error, like in Redis
+	lastBgsaveFailed.Store(false)
+	recordSave(dirty)
+	return nil
+}
+
+// BackgroundSave snapshots the dataset and writes it to the configured RDB file from a goroutine
+func BackgroundSave() error {
+	if !bgsaveInProgress.CompareAndSwap(false, true) {
+		return fmt.Errorf("ERR Background save already in progress")
+	}
+
+	snapshot := TakeSnapshot()
+	dirty := server.Dirty()
+	dir := server.StoreState.ConfigDir
+	filename := server.StoreState.ConfigDbfilename
+	start := time.Now().Unix()
+	bgsaveStartTime.Store(start)
+
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		defer bgsaveInProgress.Store(false)
+		defer snapshot.Release()
+		defer func() { lastBgsaveDuration.Store(time.Now().Unix() - start) }()
+
+		if err := SaveRDBFile(dir, filename, snapshot); err != nil {
+			lastBgsaveFailed.Store(true)
+			rdbLog.Warningf("Background saving error: %v", err)
+			return
+		}
+		lastBgsaveFailed.Store(false)
+		recordSave(dirty)
+		rdbLog.Noticef("Background saving terminated with success")
+	}()
+	return nil
+}
+
+// recordSave marks the changes up to dirty as persisted
+func recordSave(dirty int64) {
+	lastSaveDirty.Store(dirty)
+	lastSaveTime.Store(time.Now().Unix())
+}
+
+// LastSaveTime returns the Unix time in seconds of the last successful save
+func LastSaveTime() int64 {
+	return lastSaveTime.Load()
+}
+
+// ChangesSinceLastSave returns the number of changes not yet written to the RDB file
+func ChangesSinceLastSave() int64 {
+	return server.Dirty() - lastSaveDirty.Load()
+}
+
+// WaitBackgroundJobs waits for the background save and the AOF rewrite in progress, if any,
+// to finish writing their file
+func WaitBackgroundJobs() {
+	backgroundJobs.Wait()
+}
+
+// BackgroundSaveInProgress reports whether a BGSAVE is currently writing the RDB file
+func BackgroundSaveInProgress() bool {
+	return bgsaveInProgress.Load()
+}
+
+// Reload writes the dataset to the configured RDB file and loads it back in place of memory
+func Reload() error {
+	if err := Save(); err != nil {
+		return err
+	}
+	if err := LoadRDBFile(server.StoreState.ConfigDir, server.StoreState.ConfigDbfilename); err != nil {
+		return fmt.Errorf("ERR Error trying to load the RDB dump: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// bgsaveRetryDelay is how long save points wait after a failed background save
+// before trying again, so a full disk doesn't start a save every second
+const bgsaveRetryDelay = 5
+
+// lastBgsaveTry is the Unix time in seconds of the last save started by a save point
+var lastBgsaveTry int64
+
+// ParseSavePoints parses "<seconds> <changes>" pairs, as used by the save option.
+// An empty string returns no save points, which disables automatic saves.
+func ParseSavePoints(s string) ([]shared.SavePoint, error) {
+	fields := strings.Fields(s)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid save parameters")
+	}
+
+	points := make([]shared.SavePoint, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err := strconv.Atoi(fields[i])
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid save parameters")
+		}
+		changes, err := strconv.Atoi(fields[i+1])
+		if err != nil || changes < 0 {
+			return nil, fmt.Errorf("invalid save parameters")
+		}
+		points = append(points, shared.SavePoint{Seconds: seconds, Changes: changes})
+	}
+	return points, nil
+}
+
+// FormatSavePoints returns save points in the form accepted by ParseSavePoints
+func FormatSavePoints(points []shared.SavePoint) string {
+	parts := make([]string, 0, len(points)*2)
+	for _, point := range points {
+		parts = append(parts, strconv.Itoa(point.Seconds), strconv.Itoa(point.Changes))
+	}
+	return strings.Join(parts, " ")
+}
+
+// StartSaveScheduler runs the persistence checks every second: it starts a background save
+// when a save point matches, fsyncs the append only file and triggers automatic AOF rewrites,
+// until the returned function is called
+func StartSaveScheduler() (stop func()) {
+	return server.Every(time.Second, func(now time.Time) {
+		checkSavePoints(now.Unix())
+		syncAppendOnlyFile()
+		checkAppendOnlyRewrite()
+	})
+}
+
+// checkSavePoints starts a background save if a save point matches at the given time.
+// It returns true when a save was started.
+func checkSavePoints(now int64) bool {
+	if BackgroundSaveInProgress() {
+		return false
+	}
+	if lastBgsaveFailed.Load() && now-lastBgsaveTry < bgsaveRetryDelay {
+		return false
+	}
+
+	changes := ChangesSinceLastSave()
+	elapsed := now - LastSaveTime()
+	for _, point := range server.StoreState.SavePoints {
+		if changes < int64(point.Changes) || elapsed < int64(point.Seconds) {
+			continue
+		}
+
+		rdbLog.Noticef("%d changes in %d seconds. Saving...", point.Changes, point.Seconds)
+		lastBgsaveTry = now
+		if err := BackgroundSave(); err != nil {
+			rdbLog.Warningf("Background saving error: %v", err)
+			return false
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestParseSavePoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []shared.SavePoint
+		format   string
+		wantErr  bool
+	}{
+		{
+			name:     "Single save point",
+			input:    "900 1",
+			expected: []shared.SavePoint{{Seconds: 900, Changes: 1}},
+			format:   "900 1",
+		},
+		{
+			name:     "Multiple save points",
+			input:    "3600 1 300 100  60 10000",
+			expected: []shared.SavePoint{{Seconds: 3600, Changes: 1}, {Seconds: 300, Changes: 100}, {Seconds: 60, Changes: 10000}},
+			format:   "3600 1 300 100 60 10000",
+		},
+		{
+			name:     "Empty disables saving",
+			input:    "",
+			expected: []shared.SavePoint{},
+		},
+		{name: "Odd number of values", input: "900 1 300", wantErr: true},
+		{name: "Negative seconds", input: "-1 1", wantErr: true},
+		{name: "Not a number", input: "900 many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, err := ParseSavePoints(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(points, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, points)
+			}
+			if formatted := FormatSavePoints(points); formatted != tt.format {
+				t.Errorf("Expected %q to be formatted as %q, got %q", tt.input, tt.format, formatted)
+			}
+		})
+	}
+}
+
+func TestCheckSavePoints(t *testing.T) {
+	dir := t.TempDir()
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        dir,
+		ConfigDbfilename: "dump.rdb",
+		SavePoints:       []shared.SavePoint{{Seconds: 60, Changes: 10}, {Seconds: 300, Changes: 1}},
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	server.Memory.Clear()
+	server.Memory.Set("key", shared.MemoryEntry{Value: "value"})
+
+	now := time.Now().Unix()
+	recordSave(server.Dirty())
+	lastSaveTime.Store(now - 120)
+	server.AddDirty(5)
+
+	// 5 changes in 120 seconds match neither rule
+	if checkSavePoints(now) {
+		t.Fatalf("Expected no save with 5 changes after 120 seconds")
+	}
+	if changes := ChangesSinceLastSave(); changes != 5 {
+		t.Errorf("Expected 5 changes since last save, got %d", changes)
+	}
+
+	// The second rule matches once enough time has passed
+	if !checkSavePoints(now + 180) {
+		t.Fatalf("Expected a save with 5 changes after 300 seconds")
+	}
+	deadline := time.Now().Add(time.Second)
+	for BackgroundSaveInProgress() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Background save did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); err != nil {
+		t.Fatalf("Expected RDB file to be written: %v", err)
+	}
+	if changes := ChangesSinceLastSave(); changes != 0 {
+		t.Errorf("Expected no changes after the save, got %d", changes)
+	}
+	if LastSaveTime() < now {
+		t.Errorf("Expected last save time to be updated, got %d", LastSaveTime())
+	}
+
+	// Without new changes, no rule matches
+	if checkSavePoints(now + 1000) {
+		t.Errorf("Expected no save without changes")
+	}
+}
+
+func TestCheckSavePointsRetryDelay(t *testing.T) {
+	// A file in place of the directory makes the save fail
+	dir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(dir, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	server.SetStoreState(shared.State{
+		Role:             "master",
+		Replicas:         make(map[string]net.Conn),
+		ConfigDir:        dir,
+		ConfigDbfilename: "dump.rdb",
+		SavePoints:       []shared.SavePoint{{Seconds: 0, Changes: 1}},
+	})
+	defer server.SetStoreState(shared.State{Role: "master", Replicas: make(map[string]net.Conn)})
+	defer lastBgsaveFailed.Store(false)
+	server.Memory.Clear()
+
+	recordSave(server.Dirty())
+	server.AddDirty(1)
+
+	now := time.Now().Unix()
+	if !checkSavePoints(now) {
+		t.Fatalf("Expected a save to start")
+	}
+	for BackgroundSaveInProgress() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if checkSavePoints(now + 1) {
+		t.Errorf("Expected no retry right after a failed save")
+	}
+	if !checkSavePoints(now + bgsaveRetryDelay) {
+		t.Errorf("Expected a retry after the delay")
+	}
+	for BackgroundSaveInProgress() {
+		time.Sleep(time.Millisecond)
+	}
+}
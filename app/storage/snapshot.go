@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+// Snapshot is a point-in-time view of the dataset that can be serialized while writes continue.
+//
+// Taking a snapshot only copies the top-level map, with every shard of the store locked, so
+// writes pause for a time that grows with the number of keys, not with the size of the
+// values. Lists, sorted sets, sets and hashes are still shared with the live dataset:
+// commands call CopyOnWrite under the lock of the key, within server.Memory.Update, before
+// changing one in place, which gives every active snapshot that hasn't serialized the key
+// yet a private copy of it. Serializing a key that was not written copies it as well, so
+// no lock is held while it is written out.
+type Snapshot struct {
+	mu        sync.Mutex
+	entries   map[string]shared.MemoryEntry // The dataset when the snapshot was taken
+	settled   map[string]bool               // Keys that no longer share values with the live dataset
+	functions []string                      // Code of the function libraries when the snapshot was taken
+}
+
+// activeSnapshots are the snapshots being serialized, CopyOnWrite preserves keys for them
+var activeSnapshotsMu sync.Mutex
+var activeSnapshots = make(map[*Snapshot]struct{})
+var activeSnapshotsCount atomic.Int32
+
+// snapshotOf wraps memory in a snapshot without registering it for copy-on-write,
+// for datasets nothing writes to while they are serialized
+func snapshotOf(memory map[string]shared.MemoryEntry) *Snapshot {
+	return &Snapshot{entries: memory, settled: make(map[string]bool), functions: functionLibraries()}
+}
+
+// TakeSnapshot captures the current dataset. Release must be called once it is serialized.
+func TakeSnapshot() *Snapshot {
+	var s *Snapshot
+	// Registered before any write follows the copy, so no write changes a shared value
+	// without copying it first
+	server.Memory.CloneLocked(func(clone map[string]shared.MemoryEntry) {
+		s = snapshotOf(clone)
+		activeSnapshotsMu.Lock()
+		activeSnapshots[s] = struct{}{}
+		activeSnapshotsCount.Store(int32(len(activeSnapshots)))
+		activeSnapshotsMu.Unlock()
+	})
+	return s
+}
+
+// Release stops preserving keys for the snapshot
+func (s *Snapshot) Release() {
+	activeSnapshotsMu.Lock()
+	delete(activeSnapshots, s)
+	activeSnapshotsCount.Store(int32(len(activeSnapshots)))
+	activeSnapshotsMu.Unlock()
+}
+
+// CopyOnWrite must be called before a command changes the value of key in place, under the
+// lock of the key. Active snapshots that still share the value get their own copy first.
+func CopyOnWrite(key string) {
+	if activeSnapshotsCount.Load() == 0 {
+		return
+	}
+
+	activeSnapshotsMu.Lock()
+	defer activeSnapshotsMu.Unlock()
+	for s := range activeSnapshots {
+		s.preserve(key)
+	}
+}
+
+// preserve replaces the snapshot's entry for key with a private copy, unless it has one already
+func (s *Snapshot) preserve(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.settled[key] {
+		return
+	}
+	if entry, exists := s.entries[key]; exists {
+		s.entries[key] = cloneEntry(entry)
+	}
+	s.settled[key] = true
+}
+
+// keys returns the keys of the snapshot in sorted order, so the same dataset always serializes the same way.
+// It also returns how many of them have an expiration.
+func (s *Snapshot) keys() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.entries))
+	expiresCount := 0
+	for key, entry := range s.entries {
+		keys = append(keys, key)
+		if entry.Expires > 0 {
+			expiresCount++
+		}
+	}
+	sort.Strings(keys)
+	return keys, expiresCount
+}
+
+// visit calls fn with the snapshot's entry for key, after which the snapshot is done with it
+// and writers don't need to copy it anymore. A value still shared with the live dataset is
+// copied first, so fn runs without any lock: serializing to a slow destination, like a replica
+// during a diskless sync, doesn't hold up the writes to the key.
+func (s *Snapshot) visit(key string, fn func(shared.MemoryEntry)) {
+	s.mu.Lock()
+	entry, exists := s.entries[key]
+	if exists && !s.settled[key] {
+		entry = cloneEntry(entry)
+	}
+	s.settled[key] = true
+	s.mu.Unlock()
+
+	if exists {
+		fn(entry)
+	}
+}
+
+// WriteRDB writes the snapshot to w as a complete RDB file
+func (s *Snapshot) WriteRDB(w io.Writer) error {
+	return writeRDB(w, s)
+}
+
+// cloneEntry deep copies the values commands modify in place
+func cloneEntry(entry shared.MemoryEntry) shared.MemoryEntry {
+	if entry.List != nil {
+		entry.List = shared.FromArray(entry.List.ToArray())
+	}
+	if entry.Array != nil {
+		entry.Array = slices.Clone(entry.Array)
+	}
+	if entry.Stream != nil {
+		entry.Stream = slices.Clone(entry.Stream)
+	}
+	if entry.SortedSet != nil {
+		entry.SortedSet = entry.SortedSet.Clone()
+	}
+	if entry.Set != nil {
+		entry.Set = maps.Clone(entry.Set)
+	}
+	if entry.Hash != nil {
+		entry.Hash = maps.Clone(entry.Hash)
+	}
+	return entry
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/server"
+	"github.com/codecrafters-io/redis-starter-go/app/shared"
+)
+
+func TestSnapshotCopyOnWrite(t *testing.T) {
+	ss := shared.NewSortedSet()
+	ss.Add("a", 1)
+	server.Memory.Clear()
+	server.Memory.Set("string", shared.MemoryEntry{Value: "before"})
+	server.Memory.Set("list", shared.MemoryEntry{List: shared.FromArray([]string{"a", "b"})})
+	server.Memory.Set("zset", shared.MemoryEntry{SortedSet: ss})
+
+	snapshot := TakeSnapshot()
+	defer snapshot.Release()
+
+	// Writes after the snapshot, like the commands make them
+	server.Memory.Set("string", shared.MemoryEntry{Value: "after"})
+	CopyOnWrite("list")
+	getEntry("list").List.AddToTail("c")
+	CopyOnWrite("zset")
+	getEntry("zset").SortedSet.Add("b", 2)
+	CopyOnWrite("new")
+	server.Memory.Set("new", shared.MemoryEntry{Value: "new"})
+	server.Memory.Delete("string")
+
+	var buf bytes.Buffer
+	if err := snapshot.WriteRDB(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := ParseRDBData(buf.Bytes()); err != nil {
+		t.Fatalf("Failed to parse snapshot: %v", err)
+	}
+
+	if server.Memory.Len() != 3 {
+		t.Errorf("Expected the 3 keys of the snapshot, got %v", server.Memory.Clone())
+	}
+	if getEntry("string").Value != "before" {
+		t.Errorf("Expected string from before the snapshot, got %v", getEntry("string"))
+	}
+	if entry := getEntry("list"); !reflect.DeepEqual(entry.ListValues(), []string{"a", "b"}) {
+		t.Errorf("Expected list from before the snapshot, got %v", getEntry("list"))
+	}
+	if zset := getEntry("zset").SortedSet; zset == nil || zset.Size != 1 {
+		t.Errorf("Expected sorted set from before the snapshot, got %v", getEntry("zset"))
+	}
+}
+
+func TestSnapshotWhileWriting(t *testing.T) {
+	server.Memory.Clear()
+	defer server.Memory.Clear()
+	server.Memory.Set("list", shared.MemoryEntry{List: shared.FromArray([]string{"a"})})
+
+	// A writer pushes to the list in place, like RPUSH, while snapshots are taken
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			server.Memory.Update("list", func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+				CopyOnWrite("list")
+				entry.List.AddToTail("x")
+				return entry, true
+			})
+		}
+	}()
+
+	var files []bytes.Buffer
+	for range 50 {
+		snapshot := TakeSnapshot()
+		var buf bytes.Buffer
+		if err := snapshot.WriteRDB(&buf); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		snapshot.Release()
+		files = append(files, buf)
+	}
+	close(stop)
+	wg.Wait()
+
+	// Every snapshot holds the list as it was at a single point in time
+	for _, file := range files {
+		if err := ParseRDBData(file.Bytes()); err != nil {
+			t.Fatalf("Failed to parse snapshot: %v", err)
+		}
+		entry := getEntry("list")
+		if values := entry.ListValues(); len(values) == 0 || values[0] != "a" {
+			t.Fatalf("Expected the list to start with a, got %v", values)
+		}
+	}
+}
+
+func TestSnapshotVisitedKeysAreNotCopied(t *testing.T) {
+	list := shared.FromArray([]string{"a"})
+	server.Memory.Clear()
+	server.Memory.Set("list", shared.MemoryEntry{List: list})
+
+	snapshot := TakeSnapshot()
+	if err := snapshot.WriteRDB(&bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	CopyOnWrite("list")
+	if snapshot.entries["list"].List != list {
+		t.Errorf("Expected a serialized key to keep sharing its value")
+	}
+
+	// Once released, the snapshot doesn't preserve anything anymore
+	other := TakeSnapshot()
+	other.Release()
+	CopyOnWrite("list")
+	if other.entries["list"].List != list {
+		t.Errorf("Expected a released snapshot to be left alone")
+	}
+	snapshot.Release()
+	if activeSnapshotsCount.Load() != 0 {
+		t.Errorf("Expected no active snapshots, got %d", activeSnapshotsCount.Load())
+	}
+}
+
+func TestSnapshotConcurrentWrites(t *testing.T) {
+	list := shared.NewLinkedList()
+	for i := 0; i < 1000; i++ {
+		list.AddToTail(strconv.Itoa(i))
+	}
+	server.Memory.Clear()
+	server.Memory.Set("list", shared.MemoryEntry{List: list})
+	expected := list.ToArray()
+
+	snapshot := TakeSnapshot()
+	defer snapshot.Release()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			CopyOnWrite("list")
+			list.RemoveFromHead()
+			list.AddToTail("new")
+		}
+	}()
+
+	var buf bytes.Buffer
+	err := snapshot.WriteRDB(&buf)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := ParseRDBData(buf.Bytes()); err != nil {
+		t.Fatalf("Failed to parse snapshot: %v", err)
+	}
+	if got := getEntry("list").List; got == nil || !reflect.DeepEqual(got.ToArray(), expected) {
+		t.Errorf("Expected the list as it was when the snapshot was taken")
+	}
+}
+
+func BenchmarkTakeSnapshot(b *testing.B) {
+	server.Memory.Clear()
+	for i := 0; i < 10000; i++ {
+		server.Memory.Set("key"+strconv.Itoa(i), shared.MemoryEntry{List: shared.FromArray([]string{"a", "b", "c"})})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		TakeSnapshot().Release()
+	}
+}
+
+func TestSnapshotSlowWriterDoesNotBlockWrites(t *testing.T) {
+	server.Memory.Clear()
+	defer server.Memory.Clear()
+	server.Memory.Set("list", shared.MemoryEntry{List: shared.FromArray([]string{"a"})})
+
+	snapshot := TakeSnapshot()
+	defer snapshot.Release()
+
+	// The destination blocks while the key is serialized, like a replica reading slowly
+	visiting, resume := make(chan struct{}), make(chan struct{})
+	var visited []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		snapshot.visit("list", func(entry shared.MemoryEntry) {
+			close(visiting)
+			<-resume
+			visited = entry.ListValues()
+		})
+	}()
+	<-visiting
+
+	written := make(chan struct{})
+	go func() {
+		server.Memory.Update("list", func(entry shared.MemoryEntry, exists bool) (shared.MemoryEntry, bool) {
+			CopyOnWrite("list")
+			entry.List.AddToTail("b")
+			return entry, true
+		})
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		close(resume)
+		t.Fatal("Expected the write not to wait for the snapshot to be serialized")
+	}
+
+	close(resume)
+	<-done
+	if !reflect.DeepEqual(visited, []string{"a"}) {
+		t.Errorf("Expected the list as it was when the snapshot was taken, got %v", visited)
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import "time"
+
+// PersistenceStats is a snapshot of the state of RDB saves and of the append only file,
+// reported by INFO persistence
+type PersistenceStats struct {
+	Loading                   bool
+	ChangesSinceLastSave      int64
+	BgsaveInProgress          bool
+	LastSaveTime              int64 // Unix time in seconds
+	LastBgsaveOK              bool
+	LastBgsaveDuration        int64 // Seconds, -1 before the first BGSAVE
+	CurrentBgsaveDuration     int64 // Seconds, -1 when no BGSAVE is running
+	AOFEnabled                bool
+	AOFRewriteInProgress      bool
+	AOFLastRewriteOK          bool
+	AOFLastWriteOK            bool
+	AOFLastRewriteDuration    int64 // Seconds, -1 before the first rewrite
+	AOFCurrentRewriteDuration int64 // Seconds, -1 when no rewrite is running
+	AOFCurrentSize            int64
+	AOFBaseSize               int64
+}
+
+// GetPersistenceStats returns the current persistence state
+func GetPersistenceStats() PersistenceStats {
+	now := time.Now().Unix()
+	stats := PersistenceStats{
+		Loading:               Loading(),
+		ChangesSinceLastSave:  ChangesSinceLastSave(),
+		BgsaveInProgress:      BackgroundSaveInProgress(),
+		LastSaveTime:          LastSaveTime(),
+		LastBgsaveOK:          !lastBgsaveFailed.Load(),
+		LastBgsaveDuration:    lastBgsaveDuration.Load(),
+		CurrentBgsaveDuration: -1,
+	}
+	if stats.BgsaveInProgress {
+		stats.CurrentBgsaveDuration = now - bgsaveStartTime.Load()
+	}
+
+	aofMu.Lock()
+	defer aofMu.Unlock()
+	stats.AOFEnabled = aofFile != nil
+	stats.AOFRewriteInProgress = aofRewriting
+	stats.AOFLastRewriteOK = !aofLastRewriteFailed
+	stats.AOFLastWriteOK = !aofLastWriteFailed
+	stats.AOFLastRewriteDuration = aofLastRewriteDuration
+	stats.AOFCurrentRewriteDuration = -1
+	if aofRewriting {
+		stats.AOFCurrentRewriteDuration = now - aofRewriteStartTime
+	}
+	stats.AOFCurrentSize = aofCurrentSize
+	stats.AOFBaseSize = aofBaseSize
+	return stats
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// The encodings below were replaced by listpacks in Redis 7, but RDB files written
+// by older versions still use them, so they are only ever decoded.
+
+// decodeZiplist returns every element of a ziplist as a string
+func decodeZiplist(data []byte) ([]string, error) {
+	if len(data) < 11 {
+		return nil, fmt.Errorf("ziplist too short")
+	}
+	if total := binary.LittleEndian.Uint32(data[0:4]); int(total) != len(data) {
+		return nil, fmt.Errorf("ziplist size mismatch: header %d, actual %d", total, len(data))
+	}
+
+	var values []string
+	pos := 10
+	for {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("ziplist is missing its terminator")
+		}
+		if data[pos] == 0xFF {
+			return values, nil
+		}
+
+		// Skip the length of the previous entry: 1 byte, or 0xFE followed by 4 bytes
+		if data[pos] == 0xFE {
+			pos += 5
+		} else {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, fmt.Errorf("ziplist entry truncated")
+		}
+
+		value, size, err := decodeZiplistEntry(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		pos += size
+	}
+}
+
+// decodeZiplistEntry decodes the encoding and content at the start of data
+// and returns the value with the number of bytes it took
+func decodeZiplistEntry(data []byte) (string, int, error) {
+	b := data[0]
+	need := func(n int) error {
+		if len(data) < n {
+			return fmt.Errorf("ziplist entry truncated")
+		}
+		return nil
+	}
+
+	switch b >> 6 {
+	case 0: // String with a 6 bit length
+		length := int(b & 0x3F)
+		if err := need(1 + length); err != nil {
+			return "", 0, err
+		}
+		return string(data[1 : 1+length]), 1 + length, nil
+	case 1: // String with a 14 bit big-endian length
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		length := int(b&0x3F)<<8 | int(data[1])
+		if err := need(2 + length); err != nil {
+			return "", 0, err
+		}
+		return string(data[2 : 2+length]), 2 + length, nil
+	case 2: // String with a 32 bit big-endian length
+		if err := need(5); err != nil {
+			return "", 0, err
+		}
+		length := int(binary.BigEndian.Uint32(data[1:5]))
+		if err := need(5 + length); err != nil {
+			return "", 0, err
+		}
+		return string(data[5 : 5+length]), 5 + length, nil
+	}
+
+	switch b {
+	case 0xC0: // int16
+		if err := need(3); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(data[1:3]))), 10), 3, nil
+	case 0xD0: // int32
+		if err := need(5); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(data[1:5]))), 10), 5, nil
+	case 0xE0: // int64
+		if err := need(9); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(data[1:9])), 10), 9, nil
+	case 0xF0: // int24
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		n := int32(uint32(data[1])<<8|uint32(data[2])<<16|uint32(data[3])<<24) >> 8
+		return strconv.FormatInt(int64(n), 10), 4, nil
+	case 0xFE: // int8
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		return strconv.Itoa(int(int8(data[1]))), 2, nil
+	}
+
+	// 1111xxxx: immediate value xxxx - 1, between 0 and 12
+	if b >= 0xF1 && b <= 0xFD {
+		return strconv.Itoa(int(b&0x0F) - 1), 1, nil
+	}
+	return "", 0, fmt.Errorf("unknown ziplist encoding: 0x%02X", b)
+}
+
+// decodeIntset returns the members of an intset as strings
+func decodeIntset(data []byte) ([]string, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("intset too short")
+	}
+	encoding := int(binary.LittleEndian.Uint32(data[0:4]))
+	length := int(binary.LittleEndian.Uint32(data[4:8]))
+	if encoding != 2 && encoding != 4 && encoding != 8 {
+		return nil, fmt.Errorf("invalid intset encoding %d", encoding)
+	}
+	if len(data) != 8+encoding*length {
+		return nil, fmt.Errorf("intset size mismatch")
+	}
+
+	members := make([]string, 0, length)
+	for i := 0; i < length; i++ {
+		item := data[8+i*encoding : 8+(i+1)*encoding]
+		var n int64
+		switch encoding {
+		case 2:
+			n = int64(int16(binary.LittleEndian.Uint16(item)))
+		case 4:
+			n = int64(int32(binary.LittleEndian.Uint32(item)))
+		case 8:
+			n = int64(binary.LittleEndian.Uint64(item))
+		}
+		members = append(members, strconv.FormatInt(n, 10))
+	}
+	return members, nil
+}
+
+// decodeZipmap returns the alternating fields and values of a zipmap
+func decodeZipmap(data []byte) ([]string, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("zipmap too short")
+	}
+
+	pos := 1 // Skip the element count, which saturates at 254
+	readLen := func() (int, error) {
+		if pos >= len(data) {
+			return 0, fmt.Errorf("zipmap truncated")
+		}
+		if data[pos] < 254 {
+			pos++
+			return int(data[pos-1]), nil
+		}
+		if data[pos] == 254 && pos+5 <= len(data) {
+			length := int(binary.LittleEndian.Uint32(data[pos+1 : pos+5]))
+			pos += 5
+			return length, nil
+		}
+		return 0, fmt.Errorf("invalid zipmap length")
+	}
+
+	var fields []string
+	for {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("zipmap is missing its terminator")
+		}
+		if data[pos] == 0xFF {
+			return fields, nil
+		}
+
+		keyLen, err := readLen()
+		if err != nil {
+			return nil, err
+		}
+		if pos+keyLen > len(data) {
+			return nil, fmt.Errorf("zipmap truncated")
+		}
+		key := string(data[pos : pos+keyLen])
+		pos += keyLen
+
+		valueLen, err := readLen()
+		if err != nil {
+			return nil, err
+		}
+		// One byte of free space count follows the value length
+		if pos >= len(data) {
+			return nil, fmt.Errorf("zipmap truncated")
+		}
+		free := int(data[pos])
+		pos++
+		if pos+valueLen+free > len(data) {
+			return nil, fmt.Errorf("zipmap truncated")
+		}
+		value := string(data[pos : pos+valueLen])
+		pos += valueLen + free
+
+		fields = append(fields, key, value)
+	}
+}